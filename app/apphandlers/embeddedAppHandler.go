@@ -2,6 +2,7 @@ package apphandlers
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
 
@@ -24,7 +25,9 @@ import (
 	destinationdebugger "github.com/rudderlabs/rudder-server/services/debugger/destination"
 	sourcedebugger "github.com/rudderlabs/rudder-server/services/debugger/source"
 	transformationdebugger "github.com/rudderlabs/rudder-server/services/debugger/transformation"
+	"github.com/rudderlabs/rudder-server/services/dlq"
 	"github.com/rudderlabs/rudder-server/services/multitenant"
+	"github.com/rudderlabs/rudder-server/services/replay"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/utils/types"
 	"github.com/rudderlabs/rudder-server/utils/types/servermode"
@@ -72,6 +75,11 @@ func (embedded *EmbeddedApp) StartRudderCore(ctx context.Context, options *app.O
 	migrationMode := embedded.App.Options().MigrationMode
 	reportingI := embedded.App.Features().Reporting.GetReportingInstance()
 
+	if jobsdb.UsesLocalBackend() {
+		return embedded.startLocalPipeline(ctx, g, options)
+	}
+	setupReadonlyDBs()
+
 	//IMP NOTE: All the jobsdb setups must happen before migrator setup.
 	// This gwDBForProcessor should only be used by processor as this is supposed to be stopped and started with the
 	//Processor.
@@ -206,6 +214,7 @@ func (embedded *EmbeddedApp) StartRudderCore(ctx context.Context, options *app.O
 		defer replayDB.TearDown()
 		embedded.App.Features().Replay.Setup(&replayDB, gwDBForProcessor, routerDB, batchRouterDB)
 	}
+	dlq.Setup(ctx, routerDB, batchRouterDB)
 
 	if enableGateway {
 		rateLimiter := ratelimiter.HandleT{}
@@ -237,6 +246,12 @@ func (embedded *EmbeddedApp) StartRudderCore(ctx context.Context, options *app.O
 			return gw.StartWebHandler(ctx)
 		})
 	}
+	// jobs are replayed into gw through the jobsdb of the gateway, which manages its datasets, gwDBForProcessor only reads them
+	var gatewayWriteDB *jobsdb.HandleT
+	if enableGateway {
+		gatewayWriteDB = &gatewayDB
+	}
+	replay.Setup(gwDBForProcessor, gatewayWriteDB, routerDB, errDB, backendconfig.DefaultBackendConfig)
 
 	g.Go(func() error {
 		// This should happen only after setupDatabaseTables() is called and journal table migrations are done
@@ -248,6 +263,101 @@ func (embedded *EmbeddedApp) StartRudderCore(ctx context.Context, options *app.O
 	return g.Wait()
 }
 
+/*
+startLocalPipeline runs the gateway, processor and routers on jobsdb instances keeping their jobs on local disk, see jobsdb.NewLocal.
+Postgres is still needed for the metadata of the node, but not for the jobs. The features built on the postgres jobsdb,
+i.e. migrations, replays, the dead-letter queue and the multitenant pickup of jobs, are not available.
+Reporting is disabled too, since it writes its metrics in the postgres transactions of the jobs.
+The admin handlers and the pending events endpoint of the gateway read the local jobsdb instances,
+whose sql debugging queries return an error.
+*/
+func (embedded *EmbeddedApp) startLocalPipeline(ctx context.Context, g *errgroup.Group, options *app.Options) error {
+	pkgLogger.Info("Keeping the jobs of the pipeline on local disk")
+	var reportingI types.ReportingI = &localReportingT{}
+	newLocalDB := func(tablePrefix string) *jobsdb.LocalHandleT {
+		return jobsdb.NewLocal(tablePrefix, jobsdb.WithLocalClearDB(options.ClearDB), jobsdb.WithLocalStatusHandler())
+	}
+	gatewayDB := newLocalDB("gw")
+	defer gatewayDB.TearDown()
+	routerDB := newLocalDB("rt")
+	defer routerDB.TearDown()
+	batchRouterDB := newLocalDB("batch_rt")
+	defer batchRouterDB.TearDown()
+	errDB := newLocalDB("proc_error")
+	defer errDB.TearDown()
+
+	multitenantStats := multitenant.WithLegacyPickupJobs(multitenant.NewStats(map[string]jobsdb.MultiTenantJobsDB{
+		"rt":       routerDB,
+		"batch_rt": batchRouterDB,
+	}))
+
+	var modeProvider *state.StaticProvider
+	if enableProcessor && enableRouter {
+		modeProvider = state.NewStaticProvider(servermode.NormalMode)
+	} else {
+		modeProvider = state.NewStaticProvider(servermode.DegradedMode)
+	}
+
+	proc := processor.New(ctx, &options.ClearDB, gatewayDB, routerDB, batchRouterDB, errDB, multitenantStats, reportingI)
+	rtFactory := &router.Factory{
+		Reporting:     reportingI,
+		Multitenant:   multitenantStats,
+		BackendConfig: backendconfig.DefaultBackendConfig,
+		RouterDB:      routerDB,
+		ProcErrorDB:   errDB,
+	}
+	brtFactory := &batchrouter.Factory{
+		Reporting:     reportingI,
+		Multitenant:   multitenantStats,
+		BackendConfig: backendconfig.DefaultBackendConfig,
+		RouterDB:      batchRouterDB,
+		ProcErrorDB:   errDB,
+	}
+	rt := routerManager.New(rtFactory, brtFactory, backendconfig.DefaultBackendConfig)
+
+	dm := cluster.Dynamic{
+		Provider:        modeProvider,
+		GatewayDB:       gatewayDB,
+		RouterDB:        routerDB,
+		BatchRouterDB:   batchRouterDB,
+		ErrorDB:         errDB,
+		Processor:       proc,
+		Router:          rt,
+		MultiTenantStat: multitenantStats,
+	}
+
+	processor.RegisterAdminHandlers(errDB)
+	router.RegisterAdminHandlers(routerDB, batchRouterDB)
+
+	rateLimiter := ratelimiter.HandleT{}
+	rateLimiter.SetUp()
+	gw := gateway.HandleT{}
+	// the gateway shares the gw jobsdb with the processor, since the local store can only be opened once,
+	// and reads the pending events from the local jobsdb instances instead of postgres
+	gw.SetReadonlyDBs(gatewayDB, routerDB, batchRouterDB)
+	gw.Setup(embedded.App, backendconfig.DefaultBackendConfig, gatewayDB, &rateLimiter, embedded.VersionHandler)
+	defer gw.Shutdown()
+
+	g.Go(func() error {
+		return gw.StartAdminHandler(ctx)
+	})
+	g.Go(func() error {
+		return gw.StartWebHandler(ctx)
+	})
+	g.Go(func() error {
+		return dm.Run(ctx)
+	})
+
+	return g.Wait()
+}
+
+//localReportingT discards the metrics of the pipeline running on the local jobsdb, which has no postgres transactions to report them in
+type localReportingT struct{}
+
+func (*localReportingT) Report(metrics []*types.PUReportedMetric, txn *sql.Tx) {}
+func (*localReportingT) WaitForSetup(ctx context.Context, clientName string)   {}
+func (*localReportingT) AddClient(ctx context.Context, c types.Config)         {}
+
 func (embedded *EmbeddedApp) HandleRecovery(options *app.Options) {
 	db.HandleEmbeddedRecovery(options.NormalMode, options.DegradedMode, options.StandByMode, options.MigrationMode, misc.AppStartTime, app.EMBEDDED)
 }
@@ -259,6 +369,7 @@ func (embedded *EmbeddedApp) LegacyStart(ctx context.Context, options *app.Optio
 	rudderCoreWorkSpaceTableSetup()
 	rudderCoreNodeSetup()
 	rudderCoreBaseSetup()
+	setupReadonlyDBs()
 
 	g, ctx := errgroup.WithContext(ctx)
 
@@ -359,6 +470,7 @@ func (embedded *EmbeddedApp) LegacyStart(ctx context.Context, options *app.Optio
 	}))
 
 	if enableProcessor {
+		dlq.Setup(ctx, &routerDB, &batchRouterDB)
 		g.Go(func() error {
 			StartProcessor(ctx, &options.ClearDB, &gatewayDB, &routerDB, &batchRouterDB, &procErrorDB, reportingI, multitenantStats)
 			return nil
@@ -377,6 +489,9 @@ func (embedded *EmbeddedApp) LegacyStart(ctx context.Context, options *app.Optio
 		defer replayDB.TearDown()
 		embedded.App.Features().Replay.Setup(&replayDB, &gatewayDB, &routerDB, &batchRouterDB)
 	}
+	if enableProcessor || enableReplay {
+		replay.Setup(&gatewayDB, &gatewayDB, &routerDB, &procErrorDB, backendconfig.DefaultBackendConfig)
+	}
 
 	if enableGateway {
 		var gateway gateway.HandleT
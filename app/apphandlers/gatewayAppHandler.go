@@ -36,6 +36,7 @@ func (gatewayApp *GatewayApp) StartRudderCore(ctx context.Context, options *app.
 	rudderCoreDBValidator()
 	rudderCoreWorkSpaceTableSetup()
 	rudderCoreBaseSetup()
+	setupReadonlyDBs()
 
 	pkgLogger.Info("Clearing DB ", options.ClearDB)
 
@@ -96,6 +97,7 @@ func (gatewayApp *GatewayApp) LegacyStart(ctx context.Context, options *app.Opti
 	rudderCoreDBValidator()
 	rudderCoreWorkSpaceTableSetup()
 	rudderCoreBaseSetup()
+	setupReadonlyDBs()
 
 	var gatewayDB jobsdb.HandleT
 	pkgLogger.Info("Clearing DB ", options.ClearDB)
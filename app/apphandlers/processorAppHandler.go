@@ -25,7 +25,9 @@ import (
 	"github.com/rudderlabs/rudder-server/services/db"
 	destinationdebugger "github.com/rudderlabs/rudder-server/services/debugger/destination"
 	transformationdebugger "github.com/rudderlabs/rudder-server/services/debugger/transformation"
+	"github.com/rudderlabs/rudder-server/services/dlq"
 	"github.com/rudderlabs/rudder-server/services/multitenant"
+	"github.com/rudderlabs/rudder-server/services/replay"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/utils/types"
 	"github.com/rudderlabs/rudder-server/utils/types/servermode"
@@ -74,6 +76,7 @@ func (processor *ProcessorApp) StartRudderCore(ctx context.Context, options *app
 	rudderCoreWorkSpaceTableSetup()
 	rudderCoreNodeSetup()
 	rudderCoreBaseSetup()
+	setupReadonlyDBs()
 	g, ctx := errgroup.WithContext(ctx)
 
 	//Setting up reporting client
@@ -223,6 +226,9 @@ func (processor *ProcessorApp) StartRudderCore(ctx context.Context, options *app
 		defer replayDB.TearDown()
 		processor.App.Features().Replay.Setup(&replayDB, gwDBForProcessor, routerDB, batchRouterDB)
 	}
+	// there is no gateway writing the gw jobsdb on processor servers, jobs are only replayed from gw there
+	replay.Setup(gwDBForProcessor, nil, routerDB, errDB, backendconfig.DefaultBackendConfig)
+	dlq.Setup(ctx, routerDB, batchRouterDB)
 
 	g.Go(func() error {
 		return startHealthWebHandler(ctx)
@@ -283,6 +289,7 @@ func (processor *ProcessorApp) LegacyStart(ctx context.Context, options *app.Opt
 	rudderCoreWorkSpaceTableSetup()
 	rudderCoreNodeSetup()
 	rudderCoreBaseSetup()
+	setupReadonlyDBs()
 	g, ctx := errgroup.WithContext(ctx)
 
 	//Setting up reporting client
@@ -375,6 +382,7 @@ func (processor *ProcessorApp) LegacyStart(ctx context.Context, options *app.Opt
 	}))
 
 	if enableProcessor {
+		dlq.Setup(ctx, &routerDB, &batchRouterDB)
 		g.Go(func() error {
 			StartProcessor(ctx, &options.ClearDB, &gatewayDB, &routerDB, &batchRouterDB, &procErrorDB, reportingI, multitenantStats)
 			return nil
@@ -393,6 +401,9 @@ func (processor *ProcessorApp) LegacyStart(ctx context.Context, options *app.Opt
 		defer replayDB.TearDown()
 		processor.App.Features().Replay.Setup(&replayDB, &gatewayDB, &routerDB, &batchRouterDB)
 	}
+	if enableProcessor || enableReplay {
+		replay.Setup(&gatewayDB, nil, &routerDB, &procErrorDB, backendconfig.DefaultBackendConfig)
+	}
 
 	g.Go(func() error {
 		return startHealthWebHandler(ctx)
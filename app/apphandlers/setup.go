@@ -101,7 +101,10 @@ func rudderCoreBaseSetup() {
 
 	//Reload Config
 	loadConfig()
+}
 
+//setupReadonlyDBs connects the readonly jobsdb handles to postgres and serves them on the admin handlers of the processor and routers
+func setupReadonlyDBs() {
 	readonlyGatewayDB.Setup("gw")
 	readonlyRouterDB.Setup("rt")
 	readonlyBatchRouterDB.Setup("batch_rt")
@@ -129,6 +129,32 @@ type ConfigT struct {
 	Sources         []SourceT       `json:"sources"`
 	Libraries       LibrariesT      `json:"libraries"`
 	ConnectionFlags ConnectionFlags `json:"flags"`
+	Settings        SettingsT       `json:"settings"`
+
+	// WorkspaceSettings holds the settings of every workspace of configs merging the configs of multiple workspaces
+	WorkspaceSettings map[string]SettingsT `json:"-"`
+}
+
+//SettingsT holds the settings of a workspace
+type SettingsT struct {
+	EventRateLimit *RateLimitT `json:"eventRateLimit,omitempty"`
+}
+
+//RateLimitT limits the number of events in a rolling window
+type RateLimitT struct {
+	EventLimit      int `json:"eventLimit"`
+	WindowInSeconds int `json:"windowInSeconds"`
+}
+
+//SettingsOfWorkspace returns the settings of a workspace of the config
+func (config *ConfigT) SettingsOfWorkspace(workspaceID string) (SettingsT, bool) {
+	if settings, ok := config.WorkspaceSettings[workspaceID]; ok {
+		return settings, true
+	}
+	if workspaceID != "" && workspaceID == config.WorkspaceID {
+		return config.Settings, true
+	}
+	return SettingsT{}, false
 }
 
 type ConnectionFlags struct {
@@ -103,7 +103,9 @@ func (multiWorkspaceConfig *MultiWorkspaceConfig) Get(workspaceArr string) (Conf
 	workspaceIDToLibrariesMap := make(map[string]LibrariesT)
 	sourcesJSON := ConfigT{}
 	sourcesJSON.Sources = make([]SourceT, 0)
+	sourcesJSON.WorkspaceSettings = make(map[string]SettingsT)
 	for workspaceID, workspaceConfig := range workspaces.WorkspaceSourcesMap {
+		sourcesJSON.WorkspaceSettings[workspaceID] = workspaceConfig.Settings
 		for _, source := range workspaceConfig.Sources {
 			writeKeyToWorkspaceIDMap[source.WriteKey] = workspaceID
 			sourceIDToWorkspaceIDMap[source.ID] = workspaceID
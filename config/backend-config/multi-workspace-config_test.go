@@ -76,6 +76,7 @@ var _ = Describe("workspace-config", func() {
 			Expect(ok).To(BeTrue())
 			mutliConfig := SampleBackendConfig
 			mutliConfig.ConnectionFlags.Services = map[string]bool{"warehouse": true}
+			mutliConfig.WorkspaceSettings = map[string]SettingsT{workspaceId: {}}
 			Expect(config).To(Equal(mutliConfig))
 		})
 
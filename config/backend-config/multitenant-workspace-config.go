@@ -100,7 +100,9 @@ func (workspaceConfig *MultiTenantWorkspaceConfig) getFromAPI(workspaceArr strin
 	workspaceIDToLibrariesMap := make(map[string]LibrariesT)
 	sourcesJSON := ConfigT{}
 	sourcesJSON.Sources = make([]SourceT, 0)
+	sourcesJSON.WorkspaceSettings = make(map[string]SettingsT)
 	for workspaceID, workspaceConfig := range workspaces.WorkspaceSourcesMap {
+		sourcesJSON.WorkspaceSettings[workspaceID] = workspaceConfig.Settings
 		for _, source := range workspaceConfig.Sources {
 			writeKeyToWorkspaceIDMap[source.WriteKey] = workspaceID
 			sourceToWorkspaceIDMap[source.ID] = workspaceID
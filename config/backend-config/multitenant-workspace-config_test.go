@@ -74,6 +74,7 @@ var _ = Describe("workspace-config", func() {
 			Expect(backendConfig.GetWorkspaceIDForWriteKey("d")).To(Equal("testWordSpaceId"))
 			Expect(ok).To(BeTrue())
 			multiConfig := SampleBackendConfig
+			multiConfig.WorkspaceSettings = map[string]SettingsT{workspaceId: {}}
 			Expect(config).To(Equal(multiConfig))
 		})
 
@@ -87,7 +87,8 @@ func (r *SqlRunner) getAvgBatchSize() (float64, error) {
 	var batchSize sql.NullFloat64
 	var avgBatchSize float64
 	var err error
-	avgBatchSizeStmt := fmt.Sprintf(`select avg(jsonb_array_length(batch)) from (select event_payload->'batch' as batch from %s) t`, r.jobTableName)
+	// batch is null for compressed payloads, whose event_count is the number of events in the batch
+	avgBatchSizeStmt := fmt.Sprintf(`select avg(coalesce(jsonb_array_length(batch), event_count)) from (select event_payload->'batch' as batch, event_count from %s) t`, r.jobTableName)
 	err = runSQL(r, avgBatchSizeStmt, &batchSize)
 	if batchSize.Valid {
 		avgBatchSize = batchSize.Float64
@@ -11,6 +11,12 @@ func loadConfig() {
 	config.RegisterIntConfigVariable(8080, &webPort, false, 1, "Gateway.webPort")
 	//Port where AdminHandler is running
 	config.RegisterIntConfigVariable(8089, &adminWebPort, false, 1, "Gateway.adminWebPort")
+	//Enables ingestion over gRPC, next to the http handlers
+	config.RegisterBoolConfigVariable(false, &enableGRPC, false, "Gateway.enableGRPC")
+	//Port where the gRPC ingestion service is running
+	config.RegisterIntConfigVariable(8090, &grpcPort, false, 1, "Gateway.grpcPort")
+	//Number of messages of a gRPC stream being processed at the same time, the stream isn't read further until the oldest of them is acked
+	config.RegisterIntConfigVariable(128, &maxPendingStreamAcks, false, 1, "Gateway.grpc.maxPendingStreamAcks")
 	//Number of incoming requests that are batched before handing off to write workers
 	config.RegisterIntConfigVariable(128, &maxUserWebRequestBatchSize, false, 1, "Gateway.maxUserRequestBatchSize")
 	//Number of userWorkerBatchRequest that are batched before initiating write
@@ -38,6 +44,14 @@ func loadConfig() {
 	// Enables accepting requests without user id and anonymous id. This is added to prevent client 4xx retries.
 	config.RegisterBoolConfigVariable(false, &allowReqsWithoutUserIDAndAnonymousID, true, "Gateway.allowReqsWithoutUserIDAndAnonymousID")
 	config.RegisterBoolConfigVariable(true, &gwAllowPartialWriteWithErrors, true, "Gateway.allowPartialWriteWithErrors")
+	// Enables replaying the response of requests repeating an Idempotency-Key header. false by default
+	config.RegisterBoolConfigVariable(false, &enableIdempotency, false, "Gateway.enableIdempotency")
+	// Time window for which the responses of requests with an Idempotency-Key header are remembered
+	config.RegisterDurationConfigVariable(time.Duration(3600), &idempotencyWindow, true, time.Second, "Gateway.idempotencyWindow")
+	// Time requests wait for a request in flight with the same Idempotency-Key, in case the gateway handling it goes away
+	config.RegisterDurationConfigVariable(time.Duration(30), &idempotencyInflightTimeout, true, time.Second, "Gateway.idempotencyInflightTimeout")
+	// Maximum length of the Idempotency-Key header
+	config.RegisterIntConfigVariable(255, &maxIdempotencyKeyLength, true, 1, "Gateway.maxIdempotencyKeyLength")
 	config.RegisterDurationConfigVariable(time.Duration(0), &ReadTimeout, false, time.Second, []string{"ReadTimeout", "ReadTimeOutInSec"}...)
 	config.RegisterDurationConfigVariable(time.Duration(0), &ReadHeaderTimeout, false, time.Second, []string{"ReadHeaderTimeout", "ReadHeaderTimeoutInSec"}...)
 	config.RegisterDurationConfigVariable(time.Duration(10), &WriteTimeout, false, time.Second, []string{"WriteTimeout", "WriteTimeOutInSec"}...)
@@ -19,12 +19,15 @@ import (
 
 	"github.com/rudderlabs/rudder-server/admin"
 	"github.com/rudderlabs/rudder-server/app"
+	"github.com/rudderlabs/rudder-server/gateway/policy"
 	"github.com/rudderlabs/rudder-server/gateway/response"
 	"github.com/rudderlabs/rudder-server/gateway/webhook"
 	operationmanager "github.com/rudderlabs/rudder-server/operation-manager"
 	"github.com/rudderlabs/rudder-server/router"
 	recovery "github.com/rudderlabs/rudder-server/services/db"
 	"github.com/rudderlabs/rudder-server/services/diagnostics"
+	"github.com/rudderlabs/rudder-server/services/kvstoremanager"
+	"github.com/rudderlabs/rudder-server/services/replay"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 	"golang.org/x/sync/errgroup"
 
@@ -68,6 +71,7 @@ type webRequestT struct {
 	requestPayload []byte
 	writeKey       string
 	ipAddr         string
+	rateLimits     *rateLimitHeadersT
 }
 
 type batchWebRequestT struct {
@@ -81,10 +85,20 @@ var (
 	enabledWriteKeysSourceMap                                                 map[string]backendconfig.SourceT
 	enabledWriteKeyWebhookMap                                                 map[string]string
 	enabledWriteKeyWorkspaceMap                                               map[string]string
+	enabledWriteKeyPolicyMap                                                  map[string]*policy.PolicyT
+	enabledWriteKeyRateLimitMap                                               map[string]ratelimiter.LimitT
+	workspaceRateLimitMap                                                     map[string]ratelimiter.LimitT
 	sourceIDToNameMap                                                         map[string]string
 	configSubscriberLock                                                      sync.RWMutex
 	maxReqSize                                                                int
 	enableRateLimit                                                           bool
+	enableGRPC                                                                bool
+	enableIdempotency                                                         bool
+	idempotencyWindow                                                         time.Duration
+	maxIdempotencyKeyLength                                                   int
+	idempotencyInflightTimeout                                                time.Duration
+	grpcPort                                                                  int
+	maxPendingStreamAcks                                                      int
 	enableSuppressUserFeature                                                 bool
 	enableEventSchemasFeature                                                 bool
 	diagnosisTickerTime                                                       time.Duration
@@ -177,6 +191,7 @@ type HandleT struct {
 	netHandle                                                  *http.Client
 	httpTimeout                                                time.Duration
 	httpWebServer                                              *http.Server
+	idempotencyStore                                           idempotencyStoreI
 
 	backgroundCancel context.CancelFunc
 	backgroundWait   func() error
@@ -456,9 +471,10 @@ func (gateway *HandleT) userWebRequestWorkerProcess(userWebRequestWorker *userWe
 			}
 
 			if enableRateLimit {
-				//In case of "batch" requests, if ratelimiter returns true for LimitReached, just drop the event batch and continue.
-				restrictorKey := gateway.backendConfig.GetWorkspaceIDForWriteKey(writeKey)
-				if gateway.rateLimiter.LimitReached(restrictorKey) {
+				//In case of "batch" requests, if the events of the batch exceed the limits, just drop the event batch and continue.
+				rateLimitResult := gateway.checkRateLimits(writeKey, totalEventsInReq)
+				req.rateLimits.observe(rateLimitResult)
+				if !rateLimitResult.Allowed {
 					req.done <- response.GetStatus(response.TooManyRequests)
 					preDbStoreCount++
 					misc.IncrementMapByKey(workspaceDropRequestStats, sourceTag, 1)
@@ -466,6 +482,19 @@ func (gateway *HandleT) userWebRequestWorkerProcess(userWebRequestWorker *userWe
 				}
 			}
 
+			configSubscriberLock.RLock()
+			sourcePolicy := enabledWriteKeyPolicyMap[writeKey]
+			configSubscriberLock.RUnlock()
+			if sourcePolicy != nil {
+				if reason := sourcePolicy.Validate(gjson.GetBytes(body, "batch").Array()); reason != "" {
+					req.done <- fmt.Sprintf("%s: %s", response.GetStatus(response.IngestionPolicyViolation), reason)
+					preDbStoreCount++
+					misc.IncrementMapByKey(sourceFailStats, sourceTag, 1)
+					misc.IncrementMapByKey(sourceFailEventStats, sourceTag, totalEventsInReq)
+					continue
+				}
+			}
+
 			// set anonymousId if not set in payload
 			result := gjson.GetBytes(body, "batch")
 			out := []map[string]interface{}{}
@@ -1123,9 +1152,12 @@ func (gateway *HandleT) webRequestHandler(rh RequestHandler, w http.ResponseWrit
 
 	gateway.logger.LogRequest(r)
 	atomic.AddUint64(&gateway.recvCount, 1)
+	rateLimits := &rateLimitHeadersT{}
+	r = r.WithContext(withRateLimitHeaders(r.Context(), rateLimits))
 	var errorMessage string
 	defer func() {
 		if errorMessage != "" {
+			rateLimits.write(w)
 			if strings.Contains(errorMessage, response.GetStatus(response.TooManyRequests)) {
 				gateway.logger.Infof("IP: %s -- %s -- Response: %d, %s", misc.GetIPFromReq(r), r.URL.Path, http.StatusTooManyRequests, errorMessage)
 				http.Error(w, errorMessage, http.StatusTooManyRequests)
@@ -1140,6 +1172,20 @@ func (gateway *HandleT) webRequestHandler(rh RequestHandler, w http.ResponseWrit
 		errorMessage = err.Error()
 		return
 	}
+	if idempotencyKey := r.Header.Get(idempotencyKeyHeader); idempotencyKey != "" && gateway.idempotencyStore != nil {
+		if len(idempotencyKey) > maxIdempotencyKeyLength {
+			errorMessage = response.GetStatus(response.IdempotencyKeyTooLong)
+			return
+		}
+		originalResponse, found, end := gateway.beginIdempotentRequest(writeKey, idempotencyKey, reqType)
+		if found {
+			atomic.AddUint64(&gateway.ackCount, 1)
+			gateway.logger.Debugf("IP: %s -- %s -- Response: 200, replaying response of idempotency key %q", misc.GetIPFromReq(r), r.URL.Path, idempotencyKey)
+			w.Write(originalResponse)
+			return
+		}
+		defer func() { end(errorMessage) }()
+	}
 	errorMessage = rh.ProcessRequest(gateway, &w, r, reqType, payload, writeKey)
 	atomic.AddUint64(&gateway.ackCount, 1)
 	gateway.trackRequestMetrics(errorMessage)
@@ -1150,6 +1196,7 @@ func (gateway *HandleT) webRequestHandler(rh RequestHandler, w http.ResponseWrit
 
 	httpWriteTime := gateway.stats.NewTaggedStat("gateway.http_write_time", stats.TimerType, stats.Tags{"reqType": reqType})
 	httpWriteStartTime := time.Now()
+	rateLimits.write(w)
 	w.Write([]byte(response.GetStatus(response.Ok)))
 	httpWriteTime.Since(httpWriteStartTime)
 }
@@ -1411,6 +1458,7 @@ func (gateway *HandleT) StartWebHandler(ctx context.Context) error {
 
 	//todo: remove in next release
 	srvMux.HandleFunc("/v1/pending-events", gateway.stat(gateway.pendingEventsHandler)).Methods("POST")
+	srvMux.PathPrefix("/v1/replay").HandlerFunc(gateway.stat(replay.HTTPHandler))
 	srvMux.HandleFunc("/v1/clear", gateway.stat(gateway.ClearHandler)).Methods("POST")
 	srvMux.HandleFunc("/v1/failed-events", gateway.stat(gateway.fetchFailedEventsHandler)).Methods("POST")
 	srvMux.HandleFunc("/v1/clear-failed-events", gateway.stat(gateway.clearFailedEventsHandler)).Methods("POST")
@@ -1444,6 +1492,11 @@ func (gateway *HandleT) StartWebHandler(ctx context.Context) error {
 	g.Go(func() error {
 		return gateway.httpWebServer.ListenAndServe()
 	})
+	if enableGRPC {
+		g.Go(func() error {
+			return gateway.startGRPCHandler(ctx)
+		})
+	}
 
 	return g.Wait()
 }
@@ -1500,6 +1553,9 @@ func (gateway *HandleT) backendConfigSubscriber() {
 		enabledWriteKeysSourceMap = map[string]backendconfig.SourceT{}
 		enabledWriteKeyWebhookMap = map[string]string{}
 		enabledWriteKeyWorkspaceMap = map[string]string{}
+		enabledWriteKeyPolicyMap = map[string]*policy.PolicyT{}
+		enabledWriteKeyRateLimitMap = map[string]ratelimiter.LimitT{}
+		workspaceRateLimitMap = map[string]ratelimiter.LimitT{}
 		sources := config.Data.(backendconfig.ConfigT)
 		sourceIDToNameMap = map[string]string{}
 		for _, source := range sources.Sources {
@@ -1507,6 +1563,21 @@ func (gateway *HandleT) backendConfigSubscriber() {
 			if source.Enabled {
 				enabledWriteKeysSourceMap[source.WriteKey] = source
 				enabledWriteKeyWorkspaceMap[source.WriteKey] = source.WorkspaceID
+				sourcePolicy, err := policy.FromSourceConfig(source.Config)
+				if err != nil {
+					gateway.logger.Errorf("Ignoring invalid ingestion policy of source %s: %v", source.ID, err)
+				} else if sourcePolicy != nil {
+					enabledWriteKeyPolicyMap[source.WriteKey] = sourcePolicy
+				}
+				sourceRateLimit, err := rateLimitFromSourceConfig(source.Config)
+				if err != nil {
+					gateway.logger.Errorf("Ignoring invalid rate limit of source %s: %v", source.ID, err)
+				} else if sourceRateLimit != nil {
+					enabledWriteKeyRateLimitMap[source.WriteKey] = toLimit(*sourceRateLimit)
+				}
+				if settings, ok := sources.SettingsOfWorkspace(source.WorkspaceID); ok && settings.EventRateLimit != nil {
+					workspaceRateLimitMap[source.WorkspaceID] = toLimit(*settings.EventRateLimit)
+				}
 				if source.SourceDefinition.Category == "webhook" {
 					enabledWriteKeyWebhookMap[source.WriteKey] = source.SourceDefinition.Name
 					gateway.webhookHandler.Register(source.SourceDefinition.Name)
@@ -1527,15 +1598,19 @@ Finds the worker for a particular userID and queues the webrequest with the work
 They are further batched together in userWebRequestBatcher
 */
 func (gateway *HandleT) addToWebRequestQ(writer *http.ResponseWriter, req *http.Request, done chan string, reqType string, requestPayload []byte, writeKey string) {
-	userIDHeader := req.Header.Get("AnonymousId")
+	gateway.enqueueWebRequest(req.Header.Get("AnonymousId"), misc.GetIPFromReq(req), done, reqType, requestPayload, writeKey, rateLimitHeadersFromContext(req.Context()))
+}
+
+//enqueueWebRequest queues the webrequest with the worker of userID, or with a random worker if userID is empty.
+//The results of its rate limit checks are observed by rateLimits, if not nil.
+func (gateway *HandleT) enqueueWebRequest(userID, ipAddr string, done chan string, reqType string, requestPayload []byte, writeKey string, rateLimits *rateLimitHeadersT) {
 	//If necessary fetch userID from request body.
-	if userIDHeader == "" {
+	if userID == "" {
 		//If the request comes through proxy, proxy would already send this. So this shouldn't be happening in that case
-		userIDHeader = uuid.Must(uuid.NewV4()).String()
+		userID = uuid.Must(uuid.NewV4()).String()
 	}
-	userWebRequestWorker := gateway.findUserWebRequestWorker(userIDHeader)
-	ipAddr := misc.GetIPFromReq(req)
-	webReq := webRequestT{done: done, reqType: reqType, requestPayload: requestPayload, writeKey: writeKey, ipAddr: ipAddr}
+	userWebRequestWorker := gateway.findUserWebRequestWorker(userID)
+	webReq := webRequestT{done: done, reqType: reqType, requestPayload: requestPayload, writeKey: writeKey, ipAddr: ipAddr, rateLimits: rateLimits}
 	userWebRequestWorker.webRequestQ <- &webReq
 }
 
@@ -1615,6 +1690,10 @@ func (gateway *HandleT) Setup(application app.Interface, backendConfig backendco
 	gateway.irh = &ImportRequestHandler{}
 	gateway.rrh = &RegularRequestHandler{}
 
+	if enableIdempotency {
+		gateway.idempotencyStore = newRedisIdempotencyStore(kvstoremanager.NewRedisClient(idempotencyRedisConfig()), &idempotencyWindow, &idempotencyInflightTimeout)
+	}
+
 	gateway.webhookHandler = webhook.Setup(gateway)
 	gatewayAdmin := GatewayAdmin{handle: gateway}
 	gatewayRPCHandler := GatewayRPCHandler{jobsDB: gateway.jobsDB, readOnlyJobsDB: gateway.readonlyGatewayDB}
@@ -1681,4 +1760,8 @@ func (gateway *HandleT) Shutdown() {
 	}
 
 	gateway.backgroundWait()
+
+	if gateway.idempotencyStore != nil {
+		gateway.idempotencyStore.Close()
+	}
 }
@@ -2,13 +2,16 @@ package gateway
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"time"
 
 	uuid "github.com/gofrs/uuid"
@@ -18,6 +21,8 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
 
 	"github.com/rudderlabs/rudder-server/admin"
 	"github.com/rudderlabs/rudder-server/app"
@@ -30,6 +35,8 @@ import (
 	mocksJobsDB "github.com/rudderlabs/rudder-server/mocks/jobsdb"
 	mocksRateLimiter "github.com/rudderlabs/rudder-server/mocks/rate-limiter"
 	mocksTypes "github.com/rudderlabs/rudder-server/mocks/utils/types"
+	proto "github.com/rudderlabs/rudder-server/proto/gateway"
+	ratelimiter "github.com/rudderlabs/rudder-server/rate-limiter"
 	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/utils/logger"
 	"github.com/rudderlabs/rudder-server/utils/misc"
@@ -44,6 +51,8 @@ const (
 	WriteKeyEmpty             = ""
 	SourceIDEnabled           = "enabled-source"
 	SourceIDDisabled          = "disabled-source"
+	WriteKeyWithPolicy        = "write-key-with-policy"
+	SourceIDWithPolicy        = "source-with-policy"
 	TestRemoteAddressWithPort = "test.com:80"
 	TestRemoteAddress         = "test.com"
 
@@ -70,6 +79,17 @@ var sampleBackendConfig = backendconfig.ConfigT{
 			WriteKey: WriteKeyEnabled,
 			Enabled:  true,
 		},
+		{
+			ID:       SourceIDWithPolicy,
+			WriteKey: WriteKeyWithPolicy,
+			Enabled:  true,
+			Config: map[string]interface{}{
+				"ingestionPolicy": map[string]interface{}{
+					"allowedEventTypes": []interface{}{"track"},
+					"requiredFields":    []interface{}{"event"},
+				},
+			},
+		},
 		// {
 		// 	ID:       SecondEnabledSourceID,
 		// 	WriteKey: SecondEnabledWriteKey,
@@ -389,9 +409,9 @@ var _ = Describe("Gateway", func() {
 			tFunc := c.asyncHelper.ExpectAndNotifyCallbackWithName("")
 			mockCall.Do(func(interface{}) { tFunc() })
 
-			mockCall = c.mockRateLimiter.EXPECT().LimitReached(workspaceID).Return(false).Times(1)
+			mockCall = c.mockRateLimiter.EXPECT().Allow("workspace:"+workspaceID, 1, gomock.Any()).Return(ratelimiter.ResultT{Allowed: true, Limit: 10, Remaining: 9, Reset: time.Minute}).Times(1)
 			tFunc = c.asyncHelper.ExpectAndNotifyCallbackWithName("")
-			mockCall.Do(func(interface{}) { tFunc() })
+			mockCall.Do(func(interface{}, interface{}, interface{}) { tFunc() })
 
 			mockCall = c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).Times(1)
 			tFunc = c.asyncHelper.ExpectAndNotifyCallbackWithName("")
@@ -407,12 +427,28 @@ var _ = Describe("Gateway", func() {
 			tFunc := c.asyncHelper.ExpectAndNotifyCallbackWithName("")
 			mockCall.Do(func(interface{}) { tFunc() })
 
-			c.mockRateLimiter.EXPECT().LimitReached(workspaceID).Return(true).Times(1)
+			c.mockRateLimiter.EXPECT().Allow("workspace:"+workspaceID, 1, gomock.Any()).Return(ratelimiter.ResultT{Limit: 10, Reset: time.Minute, RetryAfter: 1500 * time.Millisecond}).Times(1)
 			tFunc = c.asyncHelper.ExpectAndNotifyCallbackWithName("")
 			mockCall.Do(func(interface{}) { tFunc() })
 
 			expectHandlerResponse(gateway.webAliasHandler, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString("{}")), 429, response.TooManyRequests+"\n")
 		})
+
+		It("should count the events of batches and set the rate limit headers", func() {
+			workspaceID := "some-workspace-id"
+
+			c.mockBackendConfig.EXPECT().GetWorkspaceIDForWriteKey(WriteKeyEnabled).Return(workspaceID).AnyTimes()
+			c.mockRateLimiter.EXPECT().Allow("workspace:"+workspaceID, 2, ratelimiter.DefaultLimit()).Return(ratelimiter.ResultT{Limit: 10, Remaining: 1, Reset: time.Minute, RetryAfter: 1500 * time.Millisecond}).Times(1)
+
+			rr := httptest.NewRecorder()
+			gateway.webBatchHandler(rr, authorizedRequest(WriteKeyEnabled, bytes.NewBufferString(`{"batch": [{"type": "track", "userId": "dummyId"}, {"type": "track", "userId": "dummyId"}]}`)))
+
+			Expect(rr.Result().StatusCode).To(Equal(429))
+			Expect(rr.Header().Get("X-RateLimit-Limit")).To(Equal("10"))
+			Expect(rr.Header().Get("X-RateLimit-Remaining")).To(Equal("1"))
+			Expect(rr.Header().Get("X-RateLimit-Reset")).To(Equal("60"))
+			Expect(rr.Header().Get("Retry-After")).To(Equal("2"))
+		})
 	})
 
 	Context("Invalid requests", func() {
@@ -489,6 +525,212 @@ var _ = Describe("Gateway", func() {
 			assertHandler(handlerType, handler)
 		}
 	})
+
+	Context("Ingestion policies", func() {
+		var (
+			gateway = &HandleT{}
+		)
+
+		BeforeEach(func() {
+			gateway.Setup(c.mockApp, c.mockBackendConfig, c.mockJobsDB, nil, c.mockVersionHandler)
+		})
+
+		It("should store requests conforming to the policy of their source", func() {
+			mockCall := c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).Times(1)
+			tFunc := c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")
+			mockCall.Do(func(interface{}) { tFunc() })
+
+			expectHandlerResponse(gateway.webTrackHandler, authorizedRequest(WriteKeyWithPolicy, bytes.NewBufferString(`{"userId":"dummyId","event":"Demo Track"}`)), 200, "OK")
+		})
+
+		It("should reject requests violating the policy of their source with the reason", func() {
+			expectHandlerResponse(gateway.webIdentifyHandler, authorizedRequest(WriteKeyWithPolicy, bytes.NewBufferString(`{"userId":"dummyId"}`)), 400,
+				response.IngestionPolicyViolation+`: batch.0: event type "identify" is not allowed`+"\n")
+			expectHandlerResponse(gateway.webBatchHandler, authorizedRequest(WriteKeyWithPolicy, bytes.NewBufferString(`{"batch":[{"type":"track","userId":"dummyId","event":"Demo Track"},{"type":"track","userId":"dummyId"}]}`)), 400,
+				response.IngestionPolicyViolation+`: batch.1: required field "event" is missing`+"\n")
+		})
+	})
+
+	Context("Idempotency keys", func() {
+		var gateway = &HandleT{}
+
+		BeforeEach(func() {
+			gateway.Setup(c.mockApp, c.mockBackendConfig, c.mockJobsDB, nil, c.mockVersionHandler)
+			gateway.idempotencyStore = newMemoryIdempotencyStore()
+		})
+
+		AfterEach(func() {
+			gateway.idempotencyStore = nil
+		})
+
+		idempotentRequest := func(writeKey, idempotencyKey, body string) *http.Request {
+			req := authorizedRequest(writeKey, bytes.NewBufferString(body))
+			req.Header.Set("Idempotency-Key", idempotencyKey)
+			return req
+		}
+
+		It("should replay the response of a repeated request without storing it again", func() {
+			mockCall := c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).Times(1)
+			tFunc := c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")
+			mockCall.Do(func(interface{}) { tFunc() })
+
+			expectHandlerResponse(gateway.webTrackHandler, idempotentRequest(WriteKeyEnabled, "key-1", `{"userId":"dummyId"}`), 200, "OK")
+			expectHandlerResponse(gateway.webTrackHandler, idempotentRequest(WriteKeyEnabled, "key-1", `{"userId":"dummyId"}`), 200, "OK")
+		})
+
+		It("should process a repeat of a failed request again", func() {
+			expectHandlerResponse(gateway.webTrackHandler, idempotentRequest(WriteKeyEnabled, "key-2", `not-a-valid-json`), 400, response.InvalidJSON+"\n")
+			expectHandlerResponse(gateway.webTrackHandler, idempotentRequest(WriteKeyEnabled, "key-2", `not-a-valid-json`), 400, response.InvalidJSON+"\n")
+		})
+
+		It("should reject idempotency keys longer than the max length", func() {
+			expectHandlerResponse(gateway.webTrackHandler, idempotentRequest(WriteKeyEnabled, strings.Repeat("k", maxIdempotencyKeyLength+1), `{"userId":"dummyId"}`), 400, response.IdempotencyKeyTooLong+"\n")
+		})
+	})
+
+	Context("gRPC requests", func() {
+		var (
+			gateway = &HandleT{}
+			client  proto.GatewayClient
+			conn    *grpc.ClientConn
+			srv     *grpc.Server
+		)
+
+		BeforeEach(func() {
+			gateway.Setup(c.mockApp, c.mockBackendConfig, c.mockJobsDB, nil, c.mockVersionHandler)
+
+			listener := bufconn.Listen(1024 * 1024)
+			srv = grpc.NewServer()
+			proto.RegisterGatewayServer(srv, &grpcHandlerT{gateway: gateway})
+			go srv.Serve(listener)
+
+			var err error
+			conn, err = grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+				return listener.Dial()
+			}))
+			Expect(err).To(BeNil())
+			client = proto.NewGatewayClient(conn)
+		})
+
+		AfterEach(func() {
+			conn.Close()
+			srv.Stop()
+		})
+
+		It("should ack stored messages with the status of the http handlers", func() {
+			c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).
+				DoAndReturn(func(jobs []*jobsdb.JobT) map[uuid.UUID]string {
+					Expect(jobs).To(HaveLen(1))
+					Expect(gjson.GetBytes(jobs[0].EventPayload, "writeKey").String()).To(Equal(WriteKeyEnabled))
+					Expect(gjson.GetBytes(jobs[0].EventPayload, "batch.0.type").String()).To(Equal("track"))
+					c.asyncHelper.ExpectAndNotifyCallbackWithName("jobsdb_store")()
+					return jobsToEmptyErrors(jobs)
+				}).Times(1)
+
+			ack, err := client.Ingest(context.Background(), &proto.IngestRequest{
+				MessageId: "message-1",
+				WriteKey:  WriteKeyEnabled,
+				Type:      "track",
+				Payload:   []byte(`{"userId":"dummyId"}`),
+			})
+			Expect(err).To(BeNil())
+			Expect(ack.MessageId).To(Equal("message-1"))
+			Expect(ack.StatusCode).To(BeEquivalentTo(200))
+			Expect(ack.Status).To(Equal(response.Ok))
+		})
+
+		It("should reject invalid messages", func() {
+			for _, tc := range []struct {
+				request *proto.IngestRequest
+				status  string
+			}{
+				{&proto.IngestRequest{Payload: []byte(`{"userId":"dummyId"}`)}, response.NoWriteKeyInBasicAuth},
+				{&proto.IngestRequest{WriteKey: WriteKeyEnabled}, response.RequestBodyNil},
+				{&proto.IngestRequest{WriteKey: WriteKeyEnabled, Type: "unknown", Payload: []byte(`{"userId":"dummyId"}`)}, response.InvalidRequestType},
+				{&proto.IngestRequest{WriteKey: WriteKeyEnabled, Payload: []byte(`not-a-valid-json`)}, response.InvalidJSON},
+				{&proto.IngestRequest{WriteKey: WriteKeyInvalid, Payload: []byte(`{"batch":[{"userId":"dummyId"}]}`)}, response.InvalidWriteKey},
+			} {
+				ack, err := client.Ingest(context.Background(), tc.request)
+				Expect(err).To(BeNil())
+				Expect(ack.StatusCode).To(BeEquivalentTo(400))
+				Expect(ack.Status).To(Equal(tc.status))
+			}
+		})
+
+		It("should ack every message of a stream in order", func() {
+			c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).AnyTimes()
+
+			stream, err := client.IngestStream(context.Background())
+			Expect(err).To(BeNil())
+			Expect(stream.Send(&proto.IngestRequest{MessageId: "message-1", WriteKey: WriteKeyEnabled, Type: "identify", Payload: []byte(`{"userId":"dummyId"}`)})).To(BeNil())
+			Expect(stream.Send(&proto.IngestRequest{MessageId: "message-2", WriteKey: WriteKeyDisabled, Payload: []byte(`{"batch":[{"userId":"dummyId"}]}`)})).To(BeNil())
+			Expect(stream.Send(&proto.IngestRequest{MessageId: "message-3", WriteKey: WriteKeyEnabled, Payload: []byte(`{"batch":[{"anonymousId":"anonId"}]}`)})).To(BeNil())
+			Expect(stream.CloseSend()).To(BeNil())
+
+			var acks []*proto.IngestAck
+			for {
+				ack, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).To(BeNil())
+				acks = append(acks, ack)
+			}
+			Expect(acks).To(HaveLen(3))
+			Expect(acks[0].MessageId).To(Equal("message-1"))
+			Expect(acks[0].StatusCode).To(BeEquivalentTo(200))
+			Expect(acks[1].MessageId).To(Equal("message-2"))
+			Expect(acks[1].StatusCode).To(BeEquivalentTo(400))
+			Expect(acks[1].Status).To(Equal(response.InvalidWriteKey))
+			Expect(acks[2].MessageId).To(Equal("message-3"))
+			Expect(acks[2].StatusCode).To(BeEquivalentTo(200))
+		})
+
+		It("should ack a repeated message without storing it again", func() {
+			c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).Times(1)
+			gateway.idempotencyStore = newMemoryIdempotencyStore()
+			defer func() { gateway.idempotencyStore = nil }()
+
+			for i := 0; i < 2; i++ {
+				ack, err := client.Ingest(context.Background(), &proto.IngestRequest{
+					MessageId:      "message-1",
+					WriteKey:       WriteKeyEnabled,
+					Type:           "track",
+					Payload:        []byte(`{"userId":"dummyId"}`),
+					IdempotencyKey: "key-1",
+				})
+				Expect(err).To(BeNil())
+				Expect(ack.StatusCode).To(BeEquivalentTo(200))
+				Expect(ack.Status).To(Equal(response.Ok))
+			}
+
+			ack, err := client.Ingest(context.Background(), &proto.IngestRequest{
+				WriteKey:       WriteKeyEnabled,
+				Payload:        []byte(`{"userId":"dummyId"}`),
+				IdempotencyKey: strings.Repeat("k", maxIdempotencyKeyLength+1),
+			})
+			Expect(err).To(BeNil())
+			Expect(ack.StatusCode).To(BeEquivalentTo(400))
+			Expect(ack.Status).To(Equal(response.IdempotencyKeyTooLong))
+		})
+
+		It("should ack a message of a stream before the stream is closed", func() {
+			c.mockJobsDB.EXPECT().StoreWithRetryEach(gomock.Any()).DoAndReturn(jobsToEmptyErrors).AnyTimes()
+
+			stream, err := client.IngestStream(context.Background())
+			Expect(err).To(BeNil())
+			for _, messageID := range []string{"message-1", "message-2"} {
+				Expect(stream.Send(&proto.IngestRequest{MessageId: messageID, WriteKey: WriteKeyEnabled, Type: "identify", Payload: []byte(`{"userId":"dummyId"}`)})).To(BeNil())
+				ack, err := stream.Recv()
+				Expect(err).To(BeNil())
+				Expect(ack.MessageId).To(Equal(messageID))
+				Expect(ack.StatusCode).To(BeEquivalentTo(200))
+			}
+			Expect(stream.CloseSend()).To(BeNil())
+			_, err = stream.Recv()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
 })
 
 func unauthorizedRequest(body io.Reader) *http.Request {
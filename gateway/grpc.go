@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	proto "github.com/rudderlabs/rudder-server/proto/gateway"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+//grpcRequestTypes are the event types which can be ingested over gRPC
+var grpcRequestTypes = map[string]struct{}{
+	"batch":        {},
+	"identify":     {},
+	"track":        {},
+	"page":         {},
+	"screen":       {},
+	"alias":        {},
+	"merge":        {},
+	"group":        {},
+	"audiencelist": {},
+}
+
+//grpcHandlerT serves the gateway gRPC service.
+//
+//Every message goes through the same webRequestQ as the http requests, so it is validated and stored by userWebRequestWorkerProcess,
+//and is acked with the status the http handlers would respond with.
+type grpcHandlerT struct {
+	proto.UnimplementedGatewayServer
+	gateway *HandleT
+}
+
+//Ingest stores a single message
+func (h *grpcHandlerT) Ingest(ctx context.Context, req *proto.IngestRequest) (*proto.IngestAck, error) {
+	reqHandlerTime := h.gateway.stats.NewTaggedStat("gateway.grpc_req_handler_time", stats.TimerType, stats.Tags{"method": "Ingest"})
+	reqHandlerStartTime := time.Now()
+	defer reqHandlerTime.Since(reqHandlerStartTime)
+
+	done := make(chan string, 1)
+	h.gateway.processGRPCRequest(ctx, req, done)
+	return h.gateway.grpcAck(req, <-done), nil
+}
+
+//pendingAckT is a message of a stream which is processed by the gateway and not yet acked
+type pendingAckT struct {
+	req  *proto.IngestRequest
+	done chan string
+}
+
+//IngestStream stores the messages of the stream and acks each one of them as soon as it is processed, in the order they were received.
+//Up to Gateway.grpc.maxPendingStreamAcks messages are processed at the same time, the stream isn't read further until the oldest of them is acked
+func (h *grpcHandlerT) IngestStream(stream proto.Gateway_IngestStreamServer) error {
+	reqHandlerTime := h.gateway.stats.NewTaggedStat("gateway.grpc_req_handler_time", stats.TimerType, stats.Tags{"method": "IngestStream"})
+	reqHandlerStartTime := time.Now()
+	defer reqHandlerTime.Since(reqHandlerStartTime)
+
+	ctx := stream.Context()
+	pending := make(chan pendingAckT, maxPendingStreamAcks)
+	recvErr := make(chan error, 1)
+	rruntime.Go(func() {
+		defer close(pending)
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					recvErr <- err
+				}
+				return
+			}
+			done := make(chan string, 1)
+			h.gateway.processGRPCRequest(ctx, req, done)
+			select {
+			case pending <- pendingAckT{req: req, done: done}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+
+	for p := range pending {
+		if err := stream.Send(h.gateway.grpcAck(p.req, <-p.done)); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
+	}
+}
+
+//processGRPCRequest throws a gRPC message into the webRequestQ. The error message, if any, is sent over done
+func (gateway *HandleT) processGRPCRequest(ctx context.Context, req *proto.IngestRequest, done chan string) {
+	atomic.AddUint64(&gateway.recvCount, 1)
+
+	reqType := req.GetType()
+	if reqType == "" {
+		reqType = "batch"
+	}
+	switch {
+	case req.GetWriteKey() == "":
+		done <- response.GetStatus(response.NoWriteKeyInBasicAuth)
+	case len(req.GetPayload()) == 0:
+		done <- response.GetStatus(response.RequestBodyNil)
+	default:
+		if _, ok := grpcRequestTypes[reqType]; !ok {
+			done <- response.GetStatus(response.InvalidRequestType)
+			return
+		}
+		if idempotencyKey := req.GetIdempotencyKey(); idempotencyKey != "" && gateway.idempotencyStore != nil {
+			if len(idempotencyKey) > maxIdempotencyKeyLength {
+				done <- response.GetStatus(response.IdempotencyKeyTooLong)
+				return
+			}
+			_, found, end := gateway.beginIdempotentRequest(req.GetWriteKey(), idempotencyKey, reqType)
+			if found {
+				//only successful responses are remembered
+				done <- ""
+				return
+			}
+			processed, ack := make(chan string, 1), done
+			rruntime.Go(func() {
+				errorMessage := <-processed
+				end(errorMessage)
+				ack <- errorMessage
+			})
+			done = processed
+		}
+		start := time.Now()
+		gateway.enqueueWebRequest(req.GetAnonymousId(), getIPFromGRPCContext(ctx), done, reqType, req.GetPayload(), req.GetWriteKey(), nil)
+		gateway.addToWebRequestQWaitTime.SendTiming(time.Since(start))
+	}
+}
+
+//grpcAck acks a gRPC message with the status code webRequestHandler responds with for the same error message
+func (gateway *HandleT) grpcAck(req *proto.IngestRequest, errorMessage string) *proto.IngestAck {
+	atomic.AddUint64(&gateway.ackCount, 1)
+	gateway.trackRequestMetrics(errorMessage)
+
+	ack := &proto.IngestAck{MessageId: req.GetMessageId(), StatusCode: http.StatusOK, Status: response.GetStatus(response.Ok)}
+	if errorMessage != "" {
+		ack.Status = errorMessage
+		ack.StatusCode = http.StatusBadRequest
+		if strings.Contains(errorMessage, response.GetStatus(response.TooManyRequests)) {
+			ack.StatusCode = http.StatusTooManyRequests
+		}
+		gateway.logger.Infof("gRPC -- %s -- Response: %d, %s", req.GetMessageId(), ack.StatusCode, errorMessage)
+	}
+	return ack
+}
+
+//getIPFromGRPCContext returns the ip of the client, preferring X-Forwarded-For if the request comes through a proxy
+func getIPFromGRPCContext(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if forwardedFor := md.Get("x-forwarded-for"); len(forwardedFor) > 0 && forwardedFor[0] != "" {
+			return strings.TrimSpace(strings.Split(forwardedFor[0], ",")[0])
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+		return p.Addr.String()
+	}
+	return ""
+}
+
+//startGRPCHandler serves the gateway gRPC service on grpcPort until ctx is cancelled
+func (gateway *HandleT) startGRPCHandler(ctx context.Context) error {
+	gateway.logger.Infof("Starting gRPC handler in %d", grpcPort)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		return fmt.Errorf("listen on gRPC port %d: %w", grpcPort, err)
+	}
+
+	srv := grpc.NewServer()
+	proto.RegisterGatewayServer(srv, &grpcHandlerT{gateway: gateway})
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.Go(func() error {
+		<-ctx.Done()
+		srv.GracefulStop()
+		return nil
+	})
+	g.Go(func() error {
+		return srv.Serve(listener)
+	})
+	return g.Wait()
+}
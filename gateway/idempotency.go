@@ -0,0 +1,159 @@
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+	uuid "github.com/gofrs/uuid"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/gateway/response"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/types"
+)
+
+/*
+Idempotency keys
+
+Clients can send an Idempotency-Key header with their http requests, or an idempotency_key with their gRPC messages.
+The response of a successful request is remembered per write key and idempotency key for idempotencyWindow,
+and is returned as it is to requests repeating the key, without storing a new gateway job.
+
+Keys are kept in redis, so they are shared by all the gateways behind the same load balancer.
+Only successful responses are remembered, so a repeat of a failed request is processed again.
+Concurrent requests with the same key wait for the one in flight to finish, for up to idempotencyInflightTimeout.
+The request in flight marks the key with a token of its own, and only replaces or deletes the key if it still holds its token,
+so a request outliving its marker doesn't overwrite the key of the next request.
+*/
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+//idempotencyInflightPrefix prefixes the token marking the keys of requests in flight. Responses are gateway statuses, so they can't be mistaken for it
+const idempotencyInflightPrefix = "inflight:"
+
+//errIdempotencyKeyNotOwned is returned when ending a request whose key was marked by another request after its marker expired
+var errIdempotencyKeyNotOwned = errors.New("idempotency key is in flight for another request")
+
+//idempotencyPollInterval is how often a request checks whether the request in flight with the same key is done
+var idempotencyPollInterval = 50 * time.Millisecond
+
+//idempotencyStoreI remembers the responses of requests by their idempotency key
+type idempotencyStoreI interface {
+	//begin returns the remembered response of the key, if any.
+	//Otherwise it marks the key as in flight, and end must be called with the key and the token once the request is handled.
+	begin(key string) (response []byte, found bool, token string, err error)
+	//end remembers the response of the key, unless it is nil, and releases the requests waiting for the key,
+	//provided the key is still marked with the token
+	end(key string, token string, response []byte) error
+	Close()
+}
+
+//redisIdempotencyStoreT persists the responses of requests by their idempotency key in redis, with a ttl of the window
+type redisIdempotencyStoreT struct {
+	client          redis.UniversalClient
+	window          *time.Duration
+	inflightTimeout *time.Duration
+}
+
+func idempotencyRedisConfig() types.ConfigT {
+	return types.ConfigT{
+		"address":       config.GetString("Gateway.idempotency.redis.address", "localhost:6379"),
+		"password":      config.GetString("Gateway.idempotency.redis.password", ""),
+		"database":      config.GetString("Gateway.idempotency.redis.database", "0"),
+		"clusterMode":   config.GetBool("Gateway.idempotency.redis.clusterMode", false),
+		"secure":        config.GetBool("Gateway.idempotency.redis.secure", false),
+		"skipVerify":    config.GetBool("Gateway.idempotency.redis.skipVerify", false),
+		"caCertificate": config.GetString("Gateway.idempotency.redis.caCertificate", ""),
+	}
+}
+
+func newRedisIdempotencyStore(client redis.UniversalClient, window, inflightTimeout *time.Duration) *redisIdempotencyStoreT {
+	return &redisIdempotencyStoreT{client: client, window: window, inflightTimeout: inflightTimeout}
+}
+
+func idempotencyStoreKey(writeKey, idempotencyKey string) string {
+	return "gw_idempotency" + DELIMITER + writeKey + DELIMITER + idempotencyKey
+}
+
+func (s *redisIdempotencyStoreT) begin(key string) ([]byte, bool, string, error) {
+	token := uuid.Must(uuid.NewV4()).String()
+	for {
+		marked, err := s.client.SetNX(key, idempotencyInflightPrefix+token, *s.inflightTimeout).Result()
+		if err != nil {
+			return nil, false, "", err
+		}
+		if marked {
+			return nil, false, token, nil
+		}
+
+		response, err := s.client.Get(key).Bytes()
+		if err == redis.Nil {
+			//the key expired in between
+			continue
+		}
+		if err != nil {
+			return nil, false, "", err
+		}
+		if !strings.HasPrefix(string(response), idempotencyInflightPrefix) {
+			return response, true, "", nil
+		}
+		//the marker expires after inflightTimeout, if the gateway handling the request in flight goes away
+		time.Sleep(idempotencyPollInterval)
+	}
+}
+
+//endScript replaces the value of KEYS[1] with ARGV[2] for ARGV[3] milliseconds, or deletes it if ARGV[2] is empty,
+//if KEYS[1] still holds the marker ARGV[1]. It returns 0 otherwise
+var endScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+if ARGV[2] == '' then
+	redis.call('DEL', KEYS[1])
+else
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+end
+return 1
+`)
+
+func (s *redisIdempotencyStoreT) end(key string, token string, response []byte) error {
+	ended, err := endScript.Run(s.client, []string{key}, idempotencyInflightPrefix+token, string(response), s.window.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ended == 0 {
+		return errIdempotencyKeyNotOwned
+	}
+	return nil
+}
+
+func (s *redisIdempotencyStoreT) Close() {
+	s.client.Close()
+}
+
+//beginIdempotentRequest returns the remembered response of the idempotency key of the write key, if any.
+//Otherwise end must be called with the error message of the request once it is handled.
+//Requests are handled as if they had no idempotency key if the store fails.
+func (gateway *HandleT) beginIdempotentRequest(writeKey, idempotencyKey, reqType string) (originalResponse []byte, found bool, end func(errorMessage string)) {
+	key := idempotencyStoreKey(writeKey, idempotencyKey)
+	originalResponse, found, token, err := gateway.idempotencyStore.begin(key)
+	if err != nil {
+		gateway.logger.Errorf("Failed to look up idempotency key %q of write key %q: %v", idempotencyKey, writeKey, err)
+		return nil, false, func(string) {}
+	}
+	if found {
+		gateway.stats.NewTaggedStat("gateway.idempotent_replays", stats.CountType, stats.Tags{"writeKey": writeKey, "reqType": reqType}).Increment()
+		return originalResponse, true, nil
+	}
+	return nil, false, func(errorMessage string) {
+		var successResponse []byte
+		if errorMessage == "" {
+			successResponse = []byte(response.GetStatus(response.Ok))
+		}
+		if err := gateway.idempotencyStore.end(key, token, successResponse); err != nil {
+			gateway.logger.Errorf("Failed to store idempotency key %q of write key %q: %v", idempotencyKey, writeKey, err)
+		}
+	}
+}
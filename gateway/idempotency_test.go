@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/require"
+
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+)
+
+// memoryIdempotencyStoreT keeps the responses of requests in memory, for the tests of the handlers
+type memoryIdempotencyStoreT struct {
+	lock      sync.Mutex
+	responses map[string][]byte
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStoreT {
+	return &memoryIdempotencyStoreT{responses: map[string][]byte{}}
+}
+
+func (s *memoryIdempotencyStoreT) begin(key string) ([]byte, bool, string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	response, found := s.responses[key]
+	return response, found, "", nil
+}
+
+func (s *memoryIdempotencyStoreT) end(key string, _ string, response []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if response != nil {
+		s.responses[key] = response
+	}
+	return nil
+}
+
+func (*memoryIdempotencyStoreT) Close() {}
+
+func TestRedisIdempotencyStore(t *testing.T) {
+	address := testutils.SetupRedis(t)
+	window, inflightTimeout := time.Hour, 2*time.Second
+	newStore := func() *redisIdempotencyStoreT {
+		store := newRedisIdempotencyStore(redis.NewClient(&redis.Options{Addr: address}), &window, &inflightTimeout)
+		t.Cleanup(store.Close)
+		return store
+	}
+	// gateways behind the same load balancer
+	store1, store2 := newStore(), newStore()
+
+	t.Run("responses are shared by the gateways", func(t *testing.T) {
+		key := idempotencyStoreKey("writeKey", "key-1")
+		_, found, token, err := store1.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+		require.NoError(t, store1.end(key, token, []byte("OK")))
+
+		response, found, _, err := store2.begin(key)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "OK", string(response))
+	})
+
+	t.Run("failed requests are not remembered", func(t *testing.T) {
+		key := idempotencyStoreKey("writeKey", "key-2")
+		_, found, token, err := store1.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+		require.NoError(t, store1.end(key, token, nil))
+
+		_, found, token, err = store2.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+		require.NoError(t, store2.end(key, token, nil))
+	})
+
+	t.Run("requests wait for the request in flight on another gateway", func(t *testing.T) {
+		key := idempotencyStoreKey("writeKey", "key-3")
+		_, found, token, err := store1.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+
+		type resultT struct {
+			response []byte
+			found    bool
+			err      error
+		}
+		result := make(chan resultT, 1)
+		go func() {
+			response, found, _, err := store2.begin(key)
+			result <- resultT{response, found, err}
+		}()
+		select {
+		case <-result:
+			t.Fatal("the request didn't wait for the request in flight")
+		case <-time.After(200 * time.Millisecond):
+		}
+
+		require.NoError(t, store1.end(key, token, []byte("OK")))
+		r := <-result
+		require.NoError(t, r.err)
+		require.True(t, r.found)
+		require.Equal(t, "OK", string(r.response))
+	})
+
+	t.Run("requests in flight expire", func(t *testing.T) {
+		key := idempotencyStoreKey("writeKey", "key-4")
+		_, found, _, err := store1.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+
+		start := time.Now()
+		_, found, _, err = store2.begin(key)
+		require.NoError(t, err)
+		require.False(t, found, "the gateway handling the request went away")
+		require.GreaterOrEqual(t, time.Since(start), inflightTimeout-100*time.Millisecond)
+	})
+
+	t.Run("requests outliving their marker don't end the next request", func(t *testing.T) {
+		key := idempotencyStoreKey("writeKey", "key-5")
+		_, found, expiredToken, err := store1.begin(key)
+		require.NoError(t, err)
+		require.False(t, found)
+
+		_, found, token, err := store2.begin(key)
+		require.NoError(t, err)
+		require.False(t, found, "the marker of the first request expired")
+
+		require.ErrorIs(t, store1.end(key, expiredToken, nil), errIdempotencyKeyNotOwned)
+		require.ErrorIs(t, store1.end(key, expiredToken, []byte("STALE")), errIdempotencyKeyNotOwned)
+		require.NoError(t, store2.end(key, token, []byte("OK")))
+
+		response, found, _, err := store1.begin(key)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "OK", string(response))
+	})
+}
@@ -0,0 +1,109 @@
+/*
+Package policy enforces the ingestion policies of sources at the gateway.
+
+The policy of a source is configured under the ingestionPolicy key of its config:
+
+	{
+		"ingestionPolicy": {
+			"maxEventsPerBatch": 100,
+			"maxEventSizeInKB": 32,
+			"requiredFields": ["userId", "context.library.name"],
+			"allowedEventTypes": ["track", "identify"],
+			"jsonSchema": {"type": "object", "required": ["event"]}
+		}
+	}
+
+All settings are optional, and sources without a policy accept everything the gateway accepts.
+*/
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+//ConfigKey is the key of the ingestion policy in the config of a source
+const ConfigKey = "ingestionPolicy"
+
+//PolicyT is the ingestion policy of a source
+type PolicyT struct {
+	MaxEventsPerBatch int
+	MaxEventSize      int
+	RequiredFields    []string
+	AllowedEventTypes map[string]struct{}
+	Schema            *SchemaT
+}
+
+type policyConfigT struct {
+	MaxEventsPerBatch int             `json:"maxEventsPerBatch"`
+	MaxEventSizeInKB  int             `json:"maxEventSizeInKB"`
+	RequiredFields    []string        `json:"requiredFields"`
+	AllowedEventTypes []string        `json:"allowedEventTypes"`
+	JSONSchema        json.RawMessage `json:"jsonSchema"`
+}
+
+//FromSourceConfig returns the ingestion policy configured in the config of a source, nil if there is none
+func FromSourceConfig(sourceConfig map[string]interface{}) (*PolicyT, error) {
+	rawPolicy, ok := sourceConfig[ConfigKey]
+	if !ok || rawPolicy == nil {
+		return nil, nil
+	}
+	marshalledPolicy, err := json.Marshal(rawPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("marshal ingestion policy: %w", err)
+	}
+	var policyConfig policyConfigT
+	if err := json.Unmarshal(marshalledPolicy, &policyConfig); err != nil {
+		return nil, fmt.Errorf("unmarshal ingestion policy: %w", err)
+	}
+
+	policy := &PolicyT{
+		MaxEventsPerBatch: policyConfig.MaxEventsPerBatch,
+		MaxEventSize:      policyConfig.MaxEventSizeInKB * 1024,
+		RequiredFields:    policyConfig.RequiredFields,
+	}
+	if len(policyConfig.AllowedEventTypes) > 0 {
+		policy.AllowedEventTypes = make(map[string]struct{}, len(policyConfig.AllowedEventTypes))
+		for _, eventType := range policyConfig.AllowedEventTypes {
+			policy.AllowedEventTypes[strings.ToLower(eventType)] = struct{}{}
+		}
+	}
+	if len(policyConfig.JSONSchema) > 0 && string(policyConfig.JSONSchema) != "null" {
+		if policy.Schema, err = CompileSchema(policyConfig.JSONSchema); err != nil {
+			return nil, fmt.Errorf("compile json schema of ingestion policy: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+//Validate returns the reason the batch of events violates the policy, or an empty string if it does not
+func (policy *PolicyT) Validate(batch []gjson.Result) string {
+	if policy.MaxEventsPerBatch > 0 && len(batch) > policy.MaxEventsPerBatch {
+		return fmt.Sprintf("batch has %d events, more than the max of %d", len(batch), policy.MaxEventsPerBatch)
+	}
+	for idx, event := range batch {
+		if policy.MaxEventSize > 0 && len(event.Raw) > policy.MaxEventSize {
+			return fmt.Sprintf("batch.%d: event size of %d bytes exceeds the max of %d bytes", idx, len(event.Raw), policy.MaxEventSize)
+		}
+		if policy.AllowedEventTypes != nil {
+			eventType := strings.ToLower(strings.TrimSpace(event.Get("type").String()))
+			if _, ok := policy.AllowedEventTypes[eventType]; !ok {
+				return fmt.Sprintf("batch.%d: event type %q is not allowed", idx, eventType)
+			}
+		}
+		for _, field := range policy.RequiredFields {
+			if value := event.Get(field); !value.Exists() || value.Type == gjson.Null {
+				return fmt.Sprintf("batch.%d: required field %q is missing", idx, field)
+			}
+		}
+		if policy.Schema != nil {
+			if reason := policy.Schema.Validate(event.Value()); reason != "" {
+				return fmt.Sprintf("batch.%d%s", idx, reason)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package policy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/rudderlabs/rudder-server/gateway/policy"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+)
+
+func sourceConfig(t *testing.T, rawPolicy string) map[string]interface{} {
+	var sourceConfig map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"ingestionPolicy":`+rawPolicy+`}`), &sourceConfig))
+	return sourceConfig
+}
+
+func batch(events string) []gjson.Result {
+	return gjson.Parse(events).Array()
+}
+
+func TestFromSourceConfig(t *testing.T) {
+	p, err := policy.FromSourceConfig(map[string]interface{}{})
+	require.NoError(t, err)
+	require.Nil(t, p, "sources without a policy accept everything")
+
+	p, err = policy.FromSourceConfig(sourceConfig(t, `{"maxEventsPerBatch": 2, "maxEventSizeInKB": 1, "requiredFields": ["userId"], "allowedEventTypes": ["Track"]}`))
+	require.NoError(t, err)
+	require.Equal(t, 2, p.MaxEventsPerBatch)
+	require.Equal(t, 1024, p.MaxEventSize)
+	require.Equal(t, []string{"userId"}, p.RequiredFields)
+	require.Contains(t, p.AllowedEventTypes, "track")
+	require.Nil(t, p.Schema)
+
+	_, err = policy.FromSourceConfig(sourceConfig(t, `{"jsonSchema": {"type": "object", "oneOf": []}}`))
+	require.Error(t, err, "schemas with unsupported keywords are rejected")
+	_, err = policy.FromSourceConfig(sourceConfig(t, `{"maxEventsPerBatch": "many"}`))
+	require.Error(t, err)
+}
+
+func TestPolicyValidate(t *testing.T) {
+	p, err := policy.FromSourceConfig(sourceConfig(t, `{
+		"maxEventsPerBatch": 2,
+		"maxEventSizeInKB": 1,
+		"requiredFields": ["userId", "context.library.name"],
+		"allowedEventTypes": ["track", "identify"]
+	}`))
+	require.NoError(t, err)
+
+	valid := `{"type": "track", "userId": "user", "context": {"library": {"name": "sdk"}}}`
+	require.Empty(t, p.Validate(batch(`[`+valid+`, `+valid+`]`)))
+	require.Equal(t, "batch has 3 events, more than the max of 2", p.Validate(batch(`[`+valid+`, `+valid+`, `+valid+`]`)))
+	require.Equal(t, `batch.1: event type "page" is not allowed`, p.Validate(batch(`[`+valid+`, {"type": "page", "userId": "user", "context": {"library": {"name": "sdk"}}}]`)))
+	require.Equal(t, `batch.0: required field "context.library.name" is missing`, p.Validate(batch(`[{"type": "track", "userId": "user"}]`)))
+	require.Equal(t, `batch.0: required field "userId" is missing`, p.Validate(batch(`[{"type": "track", "userId": null, "context": {"library": {"name": "sdk"}}}]`)))
+
+	large, _ := json.Marshal(map[string]interface{}{"type": "track", "userId": "user", "context": map[string]interface{}{"library": map[string]string{"name": "sdk"}}, "properties": map[string]string{"text": string(make([]byte, 1024))}})
+	require.Contains(t, p.Validate(batch(`[`+string(large)+`]`)), "batch.0: event size of")
+}
+
+func TestSchemaValidate(t *testing.T) {
+	p, err := policy.FromSourceConfig(sourceConfig(t, `{"jsonSchema": {
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type": "object",
+		"required": ["event", "properties"],
+		"properties": {
+			"event": {"type": "string", "enum": ["Order Completed", "Product Viewed"]},
+			"properties": {
+				"type": "object",
+				"additionalProperties": false,
+				"properties": {
+					"price": {"type": "number", "minimum": 0},
+					"quantity": {"type": "integer", "exclusiveMinimum": 0},
+					"sku": {"type": "string", "pattern": "^SKU-[0-9]+$", "maxLength": 10},
+					"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+				}
+			}
+		}
+	}}`))
+	require.NoError(t, err)
+
+	require.Empty(t, p.Validate(batch(`[{"event": "Order Completed", "properties": {"price": 9.99, "quantity": 1, "sku": "SKU-1", "tags": ["a"]}}]`)))
+	for events, reason := range map[string]string{
+		`[{"properties": {}}]`:                                                    `batch.0: required property "event" is missing`,
+		`[{"event": "Signed Up", "properties": {}}]`:                              `batch.0.event: value is not one of the allowed values`,
+		`[{"event": "Order Completed", "properties": []}]`:                        `batch.0.properties: expected object, got array`,
+		`[{"event": "Order Completed", "properties": {"x": 1}}]`:                  `batch.0.properties: additional property "x" is not allowed`,
+		`[{"event": "Order Completed", "properties": {"price": -1}}]`:             `batch.0.properties.price: expected a minimum of 0, got -1`,
+		`[{"event": "Order Completed", "properties": {"quantity": 1.5}}]`:         `batch.0.properties.quantity: expected integer, got number`,
+		`[{"event": "Order Completed", "properties": {"sku": "1"}}]`:              `batch.0.properties.sku: value does not match pattern "^SKU-[0-9]+$"`,
+		`[{"event": "Order Completed", "properties": {"tags": ["a", 1]}}]`:        `batch.0.properties.tags.1: expected string, got integer`,
+		`[{"event": "Order Completed", "properties": {"tags": ["a", "b", "c"]}}]`: `batch.0.properties.tags: expected at most 2 items, got 3`,
+	} {
+		require.Equal(t, reason, p.Validate(batch(events)), events)
+	}
+}
@@ -0,0 +1,340 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+SchemaT is a compiled JSON Schema, supporting the subset of the specification needed to validate events:
+
+	type, enum, const,
+	properties, required, additionalProperties,
+	items, minItems, maxItems,
+	minLength, maxLength, pattern,
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum
+
+Annotations ($schema, $id, $comment, title, description, default, examples) are ignored.
+Any other keyword fails the compilation, so that a schema is never enforced partially.
+*/
+type SchemaT struct {
+	types                []string
+	enum                 []interface{}
+	constValue           *interface{}
+	properties           map[string]*SchemaT
+	required             []string
+	additionalProperties *SchemaT
+	noAdditionalProps    bool
+	items                *SchemaT
+	minItems, maxItems   *int
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+	minimum, maximum     *float64
+	exclusiveMinimum     *float64
+	exclusiveMaximum     *float64
+}
+
+var schemaAnnotations = map[string]struct{}{
+	"$schema":     {},
+	"$id":         {},
+	"$comment":    {},
+	"title":       {},
+	"description": {},
+	"default":     {},
+	"examples":    {},
+}
+
+var schemaTypes = map[string]struct{}{
+	"object":  {},
+	"array":   {},
+	"string":  {},
+	"number":  {},
+	"integer": {},
+	"boolean": {},
+	"null":    {},
+}
+
+//CompileSchema compiles a JSON Schema
+func CompileSchema(rawSchema json.RawMessage) (*SchemaT, error) {
+	var schema interface{}
+	if err := json.Unmarshal(rawSchema, &schema); err != nil {
+		return nil, fmt.Errorf("unmarshal json schema: %w", err)
+	}
+	return compileSchema(schema, "#")
+}
+
+func compileSchema(rawSchema interface{}, path string) (*SchemaT, error) {
+	if b, ok := rawSchema.(bool); ok {
+		// true accepts everything and false nothing, i.e. it is an empty enum
+		if b {
+			return &SchemaT{}, nil
+		}
+		return &SchemaT{enum: []interface{}{}}, nil
+	}
+	keywords, ok := rawSchema.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", path)
+	}
+
+	schema := &SchemaT{}
+	for keyword, value := range keywords {
+		var err error
+		switch keyword {
+		case "type":
+			err = schema.compileType(value)
+		case "enum":
+			values, ok := value.([]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an array")
+			}
+			schema.enum = values
+		case "const":
+			constValue := value
+			schema.constValue = &constValue
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an object")
+				break
+			}
+			schema.properties = make(map[string]*SchemaT, len(properties))
+			for name, property := range properties {
+				if schema.properties[name], err = compileSchema(property, path+"/properties/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			values, ok := value.([]interface{})
+			if !ok {
+				err = fmt.Errorf("must be an array of strings")
+				break
+			}
+			for _, v := range values {
+				name, ok := v.(string)
+				if !ok {
+					err = fmt.Errorf("must be an array of strings")
+					break
+				}
+				schema.required = append(schema.required, name)
+			}
+		case "additionalProperties":
+			if b, ok := value.(bool); ok {
+				schema.noAdditionalProps = !b
+				break
+			}
+			schema.additionalProperties, err = compileSchema(value, path+"/additionalProperties")
+		case "items":
+			schema.items, err = compileSchema(value, path+"/items")
+		case "minItems":
+			schema.minItems, err = compileNonNegativeInt(value)
+		case "maxItems":
+			schema.maxItems, err = compileNonNegativeInt(value)
+		case "minLength":
+			schema.minLength, err = compileNonNegativeInt(value)
+		case "maxLength":
+			schema.maxLength, err = compileNonNegativeInt(value)
+		case "pattern":
+			pattern, ok := value.(string)
+			if !ok {
+				err = fmt.Errorf("must be a string")
+				break
+			}
+			schema.pattern, err = regexp.Compile(pattern)
+		case "minimum":
+			schema.minimum, err = compileNumber(value)
+		case "maximum":
+			schema.maximum, err = compileNumber(value)
+		case "exclusiveMinimum":
+			schema.exclusiveMinimum, err = compileNumber(value)
+		case "exclusiveMaximum":
+			schema.exclusiveMaximum, err = compileNumber(value)
+		default:
+			if _, ok := schemaAnnotations[keyword]; !ok {
+				err = fmt.Errorf("keyword is not supported")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", path, keyword, err)
+		}
+	}
+	return schema, nil
+}
+
+func (schema *SchemaT) compileType(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		schema.types = []string{v}
+	case []interface{}:
+		for _, t := range v {
+			name, ok := t.(string)
+			if !ok {
+				return fmt.Errorf("must be a string or an array of strings")
+			}
+			schema.types = append(schema.types, name)
+		}
+	default:
+		return fmt.Errorf("must be a string or an array of strings")
+	}
+	for _, t := range schema.types {
+		if _, ok := schemaTypes[t]; !ok {
+			return fmt.Errorf("unknown type %q", t)
+		}
+	}
+	return nil
+}
+
+func compileNonNegativeInt(value interface{}) (*int, error) {
+	f, ok := value.(float64)
+	if !ok || f < 0 || f != math.Trunc(f) {
+		return nil, fmt.Errorf("must be a non-negative integer")
+	}
+	i := int(f)
+	return &i, nil
+}
+
+func compileNumber(value interface{}) (*float64, error) {
+	f, ok := value.(float64)
+	if !ok {
+		return nil, fmt.Errorf("must be a number")
+	}
+	return &f, nil
+}
+
+//Validate returns the reason value, as decoded by encoding/json, does not conform to the schema, or an empty string if it does.
+//The reason starts with the path of the invalid value, relative to the validated one.
+func (schema *SchemaT) Validate(value interface{}) string {
+	return schema.validate(value, "")
+}
+
+func (schema *SchemaT) validate(value interface{}, path string) string {
+	fail := func(format string, args ...interface{}) string {
+		return path + ": " + fmt.Sprintf(format, args...)
+	}
+
+	if len(schema.types) > 0 && !matchesAnyType(value, schema.types) {
+		return fail("expected %s, got %s", strings.Join(schema.types, " or "), typeOf(value))
+	}
+	if schema.enum != nil && !containsValue(schema.enum, value) {
+		return fail("value is not one of the allowed values")
+	}
+	if schema.constValue != nil && !reflect.DeepEqual(*schema.constValue, value) {
+		return fail("value does not equal the constant value")
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.required {
+			if _, ok := v[name]; !ok {
+				return fail("required property %q is missing", name)
+			}
+		}
+		// validating in order of names keeps the reported violation deterministic
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.properties[name]; ok {
+				if reason := property.validate(v[name], path+"."+name); reason != "" {
+					return reason
+				}
+				continue
+			}
+			if schema.noAdditionalProps {
+				return fail("additional property %q is not allowed", name)
+			}
+			if schema.additionalProperties != nil {
+				if reason := schema.additionalProperties.validate(v[name], path+"."+name); reason != "" {
+					return reason
+				}
+			}
+		}
+	case []interface{}:
+		if schema.minItems != nil && len(v) < *schema.minItems {
+			return fail("expected at least %d items, got %d", *schema.minItems, len(v))
+		}
+		if schema.maxItems != nil && len(v) > *schema.maxItems {
+			return fail("expected at most %d items, got %d", *schema.maxItems, len(v))
+		}
+		if schema.items != nil {
+			for idx, item := range v {
+				if reason := schema.items.validate(item, fmt.Sprintf("%s.%d", path, idx)); reason != "" {
+					return reason
+				}
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if schema.minLength != nil && length < *schema.minLength {
+			return fail("expected at least %d characters, got %d", *schema.minLength, length)
+		}
+		if schema.maxLength != nil && length > *schema.maxLength {
+			return fail("expected at most %d characters, got %d", *schema.maxLength, length)
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(v) {
+			return fail("value does not match pattern %q", schema.pattern.String())
+		}
+	case float64:
+		if schema.minimum != nil && v < *schema.minimum {
+			return fail("expected a minimum of %v, got %v", *schema.minimum, v)
+		}
+		if schema.maximum != nil && v > *schema.maximum {
+			return fail("expected a maximum of %v, got %v", *schema.maximum, v)
+		}
+		if schema.exclusiveMinimum != nil && v <= *schema.exclusiveMinimum {
+			return fail("expected more than %v, got %v", *schema.exclusiveMinimum, v)
+		}
+		if schema.exclusiveMaximum != nil && v >= *schema.exclusiveMaximum {
+			return fail("expected less than %v, got %v", *schema.exclusiveMaximum, v)
+		}
+	}
+	return ""
+}
+
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	valueType := typeOf(value)
+	for _, t := range types {
+		if t == valueType || (t == "number" && valueType == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	ratelimiter "github.com/rudderlabs/rudder-server/rate-limiter"
+)
+
+//rateLimitConfigKey is the key of the event rate limit in the config of a source
+const rateLimitConfigKey = "rateLimit"
+
+type rateLimitHeadersKeyT struct{}
+
+//rateLimitHeadersT collects the results of the rate limit checks of the requests a web request is split to,
+//keeping the most restrictive one for the X-RateLimit-* and Retry-After headers of the response
+type rateLimitHeadersT struct {
+	lock   sync.Mutex
+	result *ratelimiter.ResultT
+}
+
+func withRateLimitHeaders(ctx context.Context, headers *rateLimitHeadersT) context.Context {
+	return context.WithValue(ctx, rateLimitHeadersKeyT{}, headers)
+}
+
+func rateLimitHeadersFromContext(ctx context.Context) *rateLimitHeadersT {
+	headers, _ := ctx.Value(rateLimitHeadersKeyT{}).(*rateLimitHeadersT)
+	return headers
+}
+
+func (headers *rateLimitHeadersT) observe(result ratelimiter.ResultT) {
+	if headers == nil || result.Limit == 0 {
+		return
+	}
+	headers.lock.Lock()
+	defer headers.lock.Unlock()
+	if headers.result == nil || moreRestrictive(result, *headers.result) {
+		headers.result = &result
+	}
+}
+
+func (headers *rateLimitHeadersT) write(w http.ResponseWriter) {
+	headers.lock.Lock()
+	defer headers.lock.Unlock()
+	if headers.result == nil {
+		return
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(headers.result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(headers.result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(headers.result.Reset)))
+	if !headers.result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(headers.result.RetryAfter)))
+	}
+}
+
+func moreRestrictive(result, than ratelimiter.ResultT) bool {
+	if result.Allowed != than.Allowed {
+		return !result.Allowed
+	}
+	if !result.Allowed {
+		return result.RetryAfter > than.RetryAfter
+	}
+	return result.Remaining < than.Remaining
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+//rateLimitFromSourceConfig returns the event rate limit configured in the config of a source
+func rateLimitFromSourceConfig(sourceConfig map[string]interface{}) (*backendconfig.RateLimitT, error) {
+	rawLimit, ok := sourceConfig[rateLimitConfigKey]
+	if !ok || rawLimit == nil {
+		return nil, nil
+	}
+	marshalledLimit, err := json.Marshal(rawLimit)
+	if err != nil {
+		return nil, err
+	}
+	var limit backendconfig.RateLimitT
+	if err := json.Unmarshal(marshalledLimit, &limit); err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func toLimit(limit backendconfig.RateLimitT) ratelimiter.LimitT {
+	return ratelimiter.LimitT{Events: limit.EventLimit, Window: time.Duration(limit.WindowInSeconds) * time.Second}
+}
+
+//checkRateLimits adds the events of a request to the rolling windows of its write key, if it has a limit of its own, and of its workspace.
+//The events are allowed only if both windows allow them, and the most restrictive result is returned.
+//Events rejected by the window of the workspace still count in the window of the write key.
+func (gateway *HandleT) checkRateLimits(writeKey string, events int) ratelimiter.ResultT {
+	workspaceID := gateway.backendConfig.GetWorkspaceIDForWriteKey(writeKey)
+	configSubscriberLock.RLock()
+	writeKeyLimit, hasWriteKeyLimit := enabledWriteKeyRateLimitMap[writeKey]
+	workspaceLimit, hasWorkspaceLimit := workspaceRateLimitMap[workspaceID]
+	configSubscriberLock.RUnlock()
+
+	result := ratelimiter.ResultT{Allowed: true}
+	if hasWriteKeyLimit {
+		result = gateway.rateLimiter.Allow("writeKey:"+writeKey, events, writeKeyLimit)
+		if !result.Allowed {
+			return result
+		}
+	}
+	if !hasWorkspaceLimit {
+		workspaceLimit = ratelimiter.DefaultLimit()
+	}
+	workspaceResult := gateway.rateLimiter.Allow("workspace:"+workspaceID, events, workspaceLimit)
+	if result.Limit == 0 || moreRestrictive(workspaceResult, result) {
+		return workspaceResult
+	}
+	return result
+}
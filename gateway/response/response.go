@@ -12,6 +12,8 @@ const (
 	RequestBodyNil = "Request body is nil"
 	//InvalidRequestMethod - Request Method is invalid
 	InvalidRequestMethod = "Invalid HTTP Request Method"
+	//InvalidRequestType - Request type is not one of the supported event types
+	InvalidRequestType = "Invalid request type"
 	//TooManyRequests - too many requests
 	TooManyRequests = "Max Requests Limit reached"
 	//NoWriteKeyInBasicAuth - Failed to read writeKey from header
@@ -22,6 +24,8 @@ const (
 	RequestBodyReadFailed = "Failed to read body from request"
 	//RequestBodyTooLarge - Request size exceeds max limit
 	RequestBodyTooLarge = "Request size exceeds max limit"
+	//IdempotencyKeyTooLong - Idempotency key exceeds max length
+	IdempotencyKeyTooLong = "Idempotency key exceeds max length"
 	//InvalidWriteKey - Invalid Write Key
 	InvalidWriteKey = "Invalid Write Key"
 	//InvalidJSON - Invalid JSON
@@ -40,6 +44,8 @@ const (
 	SourceTransformerInvalidOutputFormatInResponse = "Invalid output format in source transformer response"
 	//SourceTransformerInvalidOutputJSON - Invalid output json in source transformer response
 	SourceTransformerInvalidOutputJSON = "Invalid output json in source transformer response"
+	//IngestionPolicyViolation - Request violates the ingestion policy of the source
+	IngestionPolicyViolation = "Request violates the ingestion policy of the source"
 	//NonIdentifiableRequest - Request neither has anonymousId nor userId
 	NonIdentifiableRequest = "Request neither has anonymousId nor userId"
 	//ErrorInMarshal - Error while marshalling
@@ -69,11 +75,13 @@ func loadStatusMap() {
 	statusMap[Ok] = ResponseStatus{message: Ok, code: http.StatusOK}
 	statusMap[RequestBodyNil] = ResponseStatus{message: RequestBodyNil, code: http.StatusBadRequest}
 	statusMap[InvalidRequestMethod] = ResponseStatus{message: InvalidRequestMethod, code: http.StatusBadRequest}
+	statusMap[InvalidRequestType] = ResponseStatus{message: InvalidRequestType, code: http.StatusBadRequest}
 	statusMap[TooManyRequests] = ResponseStatus{message: TooManyRequests, code: http.StatusTooManyRequests}
 	statusMap[NoWriteKeyInBasicAuth] = ResponseStatus{message: NoWriteKeyInBasicAuth, code: http.StatusUnauthorized}
 	statusMap[NoWriteKeyInQueryParams] = ResponseStatus{message: NoWriteKeyInQueryParams, code: http.StatusUnauthorized}
 	statusMap[RequestBodyReadFailed] = ResponseStatus{message: RequestBodyReadFailed, code: http.StatusBadRequest}
 	statusMap[RequestBodyTooLarge] = ResponseStatus{message: RequestBodyTooLarge, code: http.StatusRequestEntityTooLarge}
+	statusMap[IdempotencyKeyTooLong] = ResponseStatus{message: IdempotencyKeyTooLong, code: http.StatusBadRequest}
 	statusMap[InvalidWriteKey] = ResponseStatus{message: InvalidWriteKey, code: http.StatusUnauthorized}
 	statusMap[InvalidJSON] = ResponseStatus{message: InvalidJSON, code: http.StatusBadRequest}
 	// webhook specific status
@@ -84,6 +92,7 @@ func loadStatusMap() {
 	statusMap[SourceTransformerInvalidResponseFormat] = ResponseStatus{message: SourceTransformerInvalidResponseFormat, code: http.StatusBadRequest}
 	statusMap[SourceTransformerInvalidOutputFormatInResponse] = ResponseStatus{message: SourceTransformerInvalidOutputFormatInResponse, code: http.StatusBadRequest}
 	statusMap[SourceTransformerInvalidOutputJSON] = ResponseStatus{message: SourceTransformerInvalidOutputJSON, code: http.StatusBadRequest}
+	statusMap[IngestionPolicyViolation] = ResponseStatus{message: IngestionPolicyViolation, code: http.StatusBadRequest}
 	statusMap[NonIdentifiableRequest] = ResponseStatus{message: NonIdentifiableRequest, code: http.StatusBadRequest}
 	statusMap[ErrorInMarshal] = ResponseStatus{message: ErrorInMarshal, code: http.StatusBadRequest}
 	statusMap[ErrorInParseForm] = ResponseStatus{message: ErrorInParseForm, code: http.StatusBadRequest}
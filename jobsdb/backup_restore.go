@@ -0,0 +1,518 @@
+package jobsdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/tidwall/gjson"
+)
+
+/*
+Restoring jobs from backups
+
+backupDSLoop uploads the jobs and job status tables of every dataset it drops under
+<JOBS_BACKUP_PREFIX>/<pathPrefix>/<INSTANCE_ID>/, as
+
+	<tablePrefix>_jobs_<index>.<minJobID>.<maxJobID>.<minCreatedAt>.<maxCreatedAt>.gz
+	<tablePrefix>_job_status_<index>.gz
+	<tablePrefix>_job_status_<index>_aborted.gz (failedOnly backups, rows of failed & aborted jobs along with their statuses)
+
+RestoreFromBackup reads these files back and stores the jobs matching RestoreOptsT as new, unprocessed jobs,
+so that they are processed again. Restored jobs get new job ids and are stored in a new dataset.
+*/
+
+// backupTimeLayout is the format of the TIMESTAMP columns in the json rows of backup files
+const backupTimeLayout = "2006-01-02T15:04:05.999999"
+
+// RestoreOptsT filters the jobs restored from backups, or selected by SelectJobs.
+// Empty filters match all jobs.
+type RestoreOptsT struct {
+	// From and To limit the jobs to the ones created in [From, To)
+	From time.Time
+	To   time.Time
+	// InstanceID whose backups are restored, defaults to INSTANCE_ID
+	InstanceID     string
+	SourceIDs      []string
+	DestinationIDs []string
+	WorkspaceIDs   []string
+	// States of the jobs when they were backed up. Jobs without any status are in NotProcessed state
+	States []string
+}
+
+// RestoreRequestT is the argument of JobsdbUtilsHandler.RestoreFromBackup
+type RestoreRequestT struct {
+	TablePrefix string
+	RestoreOptsT
+}
+
+type backupFileT struct {
+	key      string
+	dsIndex  string
+	isStatus bool
+	// failedOnly backups contain jobs along with their statuses
+	failedOnly bool
+	minTime    time.Time
+	maxTime    time.Time
+}
+
+type backupRowT struct {
+	JobID         int64           `json:"job_id"`
+	WorkspaceID   string          `json:"workspace_id"`
+	UUID          uuid.UUID       `json:"uuid"`
+	UserID        string          `json:"user_id"`
+	Parameters    json.RawMessage `json:"parameters"`
+	CustomVal     string          `json:"custom_val"`
+	EventPayload  json.RawMessage `json:"event_payload"`
+	EventCount    int             `json:"event_count"`
+	CreatedAt     string          `json:"created_at"`
+	ID            int64           `json:"id"`
+	JobState      string          `json:"job_state"`
+	ErrorResponse json.RawMessage `json:"error_response"`
+}
+
+var (
+	restorableJobsDBsLock sync.RWMutex
+	restorableJobsDBs     = map[string]*HandleT{}
+)
+
+// registerRestorable makes jd available to the RestoreFromBackup admin rpc
+func registerRestorable(jd *HandleT) {
+	restorableJobsDBsLock.Lock()
+	defer restorableJobsDBsLock.Unlock()
+	restorableJobsDBs[jd.tablePrefix] = jd
+}
+
+/*
+RestoreFromBackup downloads the backup files of this jobsdb overlapping opts.From and opts.To
+and stores the jobs matching opts as unprocessed jobs in a new dataset created for them.
+Once the first batch is read, the dataset is created and the migration and dataset list locks are held
+till the restore finishes, so the dataset is neither migrated nor written by other stores meanwhile:
+stores and reads of this jobsdb wait for the restore. A new dataset is then added after it for the next stores.
+It returns the number of restored jobs.
+*/
+func (jd *HandleT) RestoreFromBackup(ctx context.Context, opts RestoreOptsT) (int, error) {
+	if jd.ownerType == Read {
+		return 0, fmt.Errorf("cannot restore jobs into %s jobsdb opened for reading", jd.tablePrefix)
+	}
+
+	restoredStat := stats.NewTaggedStat("jobsdb_restored_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix})
+	var restoreDS *dataSetT
+	defer func() {
+		if restoreDS == nil {
+			return
+		}
+		//the jobs stored after the restore go to a new dataset, so they don't get mixed with the restored ones
+		jd.addNewDS(newDataSet(jd.tablePrefix, jd.computeNewIdxForAppend()))
+		jd.dsListLock.Unlock()
+		jd.dsMigrationLock.Unlock()
+	}()
+	var restored int
+	_, err := jd.ReadBackedUpJobs(ctx, opts, func(jobs []*JobT) error {
+		if restoreDS == nil {
+			//same lock order as migrateDSLoop
+			jd.dsMigrationLock.Lock()
+			jd.dsListLock.Lock()
+			ds := newDataSet(jd.tablePrefix, jd.computeNewIdxForAppend())
+			jd.addNewDS(ds)
+			restoreDS = &ds
+		}
+		if err := jd.storeJobsDS(*restoreDS, jobs); err != nil {
+			return err
+		}
+		restored += len(jobs)
+		restoredStat.Count(len(jobs))
+		return nil
+	})
+	return restored, err
+}
+
+/*
+ReadBackedUpJobs downloads the backup files of this jobsdb overlapping opts.From and opts.To
+and calls fn with batches of the jobs matching opts, ready to be stored as new jobs.
+It returns the number of jobs read.
+*/
+func (jd *HandleT) ReadBackedUpJobs(ctx context.Context, opts RestoreOptsT, fn func(jobs []*JobT) error) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+
+	fileManager, err := jd.getFileUploader()
+	if err != nil {
+		return 0, fmt.Errorf("creating file manager: %w", err)
+	}
+
+	files, err := jd.listBackupFiles(ctx, fileManager, opts)
+	if err != nil {
+		return 0, err
+	}
+	jd.logger.Infof("[[ %s ]]: Reading jobs from %d backup files between %v and %v", jd.tablePrefix, len(files), opts.From, opts.To)
+	if len(files) == 0 {
+		return 0, nil
+	}
+
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		return 0, err
+	}
+	restoreDir, err := os.MkdirTemp(tmpDirPath, jd.tablePrefix+"_restore_")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(restoreDir)
+
+	var read int
+	for _, file := range files {
+		if file.isStatus {
+			continue
+		}
+		var states map[int64]string
+		if len(opts.States) > 0 && !file.failedOnly {
+			if states, err = jd.lastBackedUpStates(ctx, fileManager, restoreDir, files, file.dsIndex); err != nil {
+				return read, err
+			}
+		}
+
+		count, err := jd.readBackedUpJobsFile(ctx, fileManager, restoreDir, file, states, opts, fn)
+		read += count
+		if err != nil {
+			return read, fmt.Errorf("reading jobs of %s: %w", file.key, err)
+		}
+		jd.logger.Infof("[[ %s ]]: Read %d jobs from %s", jd.tablePrefix, count, file.key)
+	}
+	return read, nil
+}
+
+// validate checks the time range and states of opts, defaulting To to now
+func (opts *RestoreOptsT) validate() error {
+	if opts.To.IsZero() {
+		opts.To = time.Now()
+	}
+	if !opts.From.Before(opts.To) {
+		return fmt.Errorf("invalid time range [%v, %v)", opts.From, opts.To)
+	}
+	for _, state := range opts.States {
+		if !isRestorableState(state) {
+			return fmt.Errorf("invalid job state %q", state)
+		}
+	}
+	return nil
+}
+
+// listBackupFiles returns the backup files of this jobsdb which might contain jobs created in the time range of opts
+func (jd *HandleT) listBackupFiles(ctx context.Context, fileManager filemanager.FileManager, opts RestoreOptsT) ([]backupFileT, error) {
+	instanceID := opts.InstanceID
+	if instanceID == "" {
+		instanceID = config.GetEnv("INSTANCE_ID", "1")
+	}
+	var prefixes []string
+	for _, prefix := range []string{fileManager.GetConfiguredPrefix(), jd.BackupSettings.PathPrefix, instanceID} {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	listPrefix := strings.Join(prefixes, "/") + "/"
+
+	seen := make(map[string]struct{})
+	var files []backupFileT
+	for {
+		objects, err := fileManager.ListFilesWithPrefix(ctx, listPrefix, restoreListBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("listing backup files under %s: %w", listPrefix, err)
+		}
+		var newObjects int
+		for _, object := range objects {
+			if _, ok := seen[object.Key]; ok {
+				continue
+			}
+			seen[object.Key] = struct{}{}
+			newObjects++
+
+			file, ok := parseBackupFileKey(jd.tablePrefix, object.Key)
+			if !ok {
+				continue
+			}
+			if !file.minTime.IsZero() && (file.maxTime.Before(opts.From) || !file.minTime.Before(opts.To)) {
+				continue
+			}
+			files = append(files, file)
+		}
+		// some file managers return all files at once, others page through them on every call
+		if newObjects == 0 || len(objects) < int(restoreListBatchSize) {
+			break
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].minTime.Equal(files[j].minTime) {
+			return files[i].key < files[j].key
+		}
+		return files[i].minTime.Before(files[j].minTime)
+	})
+	return files, nil
+}
+
+// parseBackupFileKey parses the name of a backup file uploaded by backupTable
+func parseBackupFileKey(tablePrefix, key string) (backupFileT, bool) {
+	name := strings.TrimSuffix(filepath.Base(key), ".gz")
+	if name == filepath.Base(key) {
+		return backupFileT{}, false
+	}
+	file := backupFileT{key: key}
+
+	jobsPrefix := tablePrefix + "_jobs_"
+	statusPrefix := tablePrefix + "_job_status_"
+	switch {
+	case strings.HasPrefix(name, jobsPrefix):
+		parts := strings.Split(strings.TrimPrefix(name, jobsPrefix), ".")
+		if len(parts) != 5 {
+			return backupFileT{}, false
+		}
+		minCreatedAt, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return backupFileT{}, false
+		}
+		maxCreatedAt, err := strconv.ParseInt(parts[4], 10, 64)
+		if err != nil {
+			return backupFileT{}, false
+		}
+		file.dsIndex = parts[0]
+		file.minTime = time.Unix(0, minCreatedAt*int64(time.Millisecond))
+		file.maxTime = time.Unix(0, maxCreatedAt*int64(time.Millisecond))
+	case strings.HasPrefix(name, statusPrefix):
+		index := strings.TrimPrefix(name, statusPrefix)
+		if strings.HasSuffix(index, "_"+Aborted.State) {
+			file.dsIndex = strings.TrimSuffix(index, "_"+Aborted.State)
+			file.failedOnly = true
+		} else {
+			file.dsIndex = index
+			file.isStatus = true
+		}
+	default:
+		return backupFileT{}, false
+	}
+	return file, true
+}
+
+// lastBackedUpStates returns the last state of every job of a dataset, according to its status backup file
+func (jd *HandleT) lastBackedUpStates(ctx context.Context, fileManager filemanager.FileManager, dir string, files []backupFileT, dsIndex string) (map[int64]string, error) {
+	states := make(map[int64]string)
+	lastStatusIDs := make(map[int64]int64)
+	for _, file := range files {
+		if !file.isStatus || file.dsIndex != dsIndex {
+			continue
+		}
+		err := jd.readBackupFile(ctx, fileManager, dir, file, func(row *backupRowT) error {
+			if row.ID >= lastStatusIDs[row.JobID] {
+				lastStatusIDs[row.JobID] = row.ID
+				states[row.JobID] = row.JobState
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading job statuses from %s: %w", file.key, err)
+		}
+	}
+	return states, nil
+}
+
+// readBackedUpJobsFile calls fn with batches of the jobs of file matching opts
+func (jd *HandleT) readBackedUpJobsFile(ctx context.Context, fileManager filemanager.FileManager, dir string, file backupFileT, states map[int64]string, opts RestoreOptsT, fn func(jobs []*JobT) error) (int, error) {
+	var read int
+	var jobs []*JobT
+	flushJobs := func() error {
+		if len(jobs) == 0 {
+			return nil
+		}
+		if err := fn(jobs); err != nil {
+			return err
+		}
+		read += len(jobs)
+		jobs = nil
+		return nil
+	}
+
+	// failedOnly backups might contain multiple statuses of the same job, sorted by job_id and exec_time
+	failedJobs := make(map[int64]*JobT)
+	failedJobIDs := make(map[int64]struct{})
+
+	err := jd.readBackupFile(ctx, fileManager, dir, file, func(row *backupRowT) error {
+		createdAt, err := time.Parse(backupTimeLayout, row.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("parsing created_at of job %d: %w", row.JobID, err)
+		}
+		if createdAt.Before(opts.From) || !createdAt.Before(opts.To) {
+			return nil
+		}
+
+		state := NotProcessed.State
+		switch {
+		case file.failedOnly:
+			state = row.JobState
+		case states != nil:
+			if s, ok := states[row.JobID]; ok {
+				state = s
+			}
+		}
+		if !matchesRestoreOpts(row, state, opts) {
+			if file.failedOnly {
+				// a later status of the job decides
+				delete(failedJobs, row.JobID)
+			}
+			return nil
+		}
+
+		job := &JobT{
+			UUID:         row.UUID,
+			UserID:       row.UserID,
+			CreatedAt:    createdAt,
+			Parameters:   row.Parameters,
+			CustomVal:    row.CustomVal,
+			EventPayload: row.EventPayload,
+			EventCount:   row.EventCount,
+			WorkspaceId:  row.WorkspaceID,
+		}
+		if file.failedOnly {
+			failedJobIDs[row.JobID] = struct{}{}
+			failedJobs[row.JobID] = job
+			return nil
+		}
+
+		jobs = append(jobs, job)
+		if len(jobs) >= restoreBatchSize {
+			return flushJobs()
+		}
+		return nil
+	})
+	if err != nil {
+		return read, err
+	}
+
+	sortedFailedJobIDs := make([]int64, 0, len(failedJobIDs))
+	for jobID := range failedJobIDs {
+		sortedFailedJobIDs = append(sortedFailedJobIDs, jobID)
+	}
+	sort.Slice(sortedFailedJobIDs, func(i, j int) bool { return sortedFailedJobIDs[i] < sortedFailedJobIDs[j] })
+	for _, jobID := range sortedFailedJobIDs {
+		job, ok := failedJobs[jobID]
+		if !ok {
+			continue
+		}
+		jobs = append(jobs, job)
+		if len(jobs) >= restoreBatchSize {
+			if err := flushJobs(); err != nil {
+				return read, err
+			}
+		}
+	}
+	return read, flushJobs()
+}
+
+func isRestorableState(state string) bool {
+	if state == NotProcessed.State {
+		return true
+	}
+	for _, js := range jobStates {
+		if js.State == state {
+			return js.isValid
+		}
+	}
+	return false
+}
+
+func matchesRestoreOpts(row *backupRowT, state string, opts RestoreOptsT) bool {
+	if len(opts.WorkspaceIDs) > 0 && !misc.ContainsString(opts.WorkspaceIDs, row.WorkspaceID) {
+		return false
+	}
+	if len(opts.SourceIDs) > 0 && !misc.ContainsString(opts.SourceIDs, gjson.GetBytes(row.Parameters, "source_id").String()) {
+		return false
+	}
+	if len(opts.DestinationIDs) > 0 && !misc.ContainsString(opts.DestinationIDs, gjson.GetBytes(row.Parameters, "destination_id").String()) {
+		return false
+	}
+	if len(opts.States) > 0 && !misc.ContainsString(opts.States, state) {
+		return false
+	}
+	return true
+}
+
+// readBackupFile downloads file into dir and calls fn for every row in it
+func (jd *HandleT) readBackupFile(ctx context.Context, fileManager filemanager.FileManager, dir string, file backupFileT, fn func(row *backupRowT) error) error {
+	path := filepath.Join(dir, filepath.Base(file.key))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	defer f.Close()
+
+	if err = fileManager.Download(ctx, f, file.key); err != nil {
+		return fmt.Errorf("downloading %s: %w", file.key, err)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	gzReader, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzReader.Close()
+
+	reader := bufio.NewReader(gzReader)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var row backupRowT
+			if jsonErr := json.Unmarshal(line, &row); jsonErr != nil {
+				return fmt.Errorf("parsing backup row: %w", jsonErr)
+			}
+			if fnErr := fn(&row); fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// RestoreFromBackup restores the jobs of a jobsdb, opened for writing by this server, from its backups
+func (handler *JobsdbUtilsHandler) RestoreFromBackup(request RestoreRequestT, reply *string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			pkgLogger.Error(r)
+			err = fmt.Errorf("internal Rudder server error: %v", r)
+		}
+	}()
+
+	restorableJobsDBsLock.RLock()
+	jd, ok := restorableJobsDBs[request.TablePrefix]
+	restorableJobsDBsLock.RUnlock()
+	if !ok {
+		return fmt.Errorf("no jobsdb with table prefix %q is open for writing", request.TablePrefix)
+	}
+
+	restored, err := jd.RestoreFromBackup(context.Background(), request.RestoreOptsT)
+	*reply = fmt.Sprintf("restored %d jobs", restored)
+	return err
+}
@@ -0,0 +1,107 @@
+package jobsdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	mock_filemanager "github.com/rudderlabs/rudder-server/mocks/services/filemanager"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseBackupFileKey(t *testing.T) {
+	file, ok := parseBackupFileKey("gw", "backups/gw/1/gw_jobs_1_1.1.100.1650000000000.1650003600000.gz")
+	require.True(t, ok)
+	require.Equal(t, "1_1", file.dsIndex)
+	require.False(t, file.isStatus)
+	require.False(t, file.failedOnly)
+	require.Equal(t, time.Unix(1650000000, 0), file.minTime)
+	require.Equal(t, time.Unix(1650003600, 0), file.maxTime)
+
+	file, ok = parseBackupFileKey("gw", "backups/gw/1/gw_job_status_1_1.gz")
+	require.True(t, ok)
+	require.Equal(t, "1_1", file.dsIndex)
+	require.True(t, file.isStatus)
+
+	file, ok = parseBackupFileKey("rt", "rt/1/rt_job_status_2_aborted.gz")
+	require.True(t, ok)
+	require.Equal(t, "2", file.dsIndex)
+	require.True(t, file.failedOnly)
+	require.False(t, file.isStatus)
+
+	_, ok = parseBackupFileKey("gw", "rt/1/rt_jobs_1.1.100.1650000000000.1650003600000.gz")
+	require.False(t, ok, "files of other jobsdbs are skipped")
+	_, ok = parseBackupFileKey("gw", "gw/1/gw_jobs_1.1.100.gz")
+	require.False(t, ok, "malformed file names are skipped")
+	_, ok = parseBackupFileKey("gw", "gw/1/gw_jobs_1.1.100.1650000000000.1650003600000.json")
+	require.False(t, ok, "only gzipped files are backups")
+}
+
+func Test_listBackupFiles(t *testing.T) {
+	restoreListBatchSize = 2
+	defer func() { restoreListBatchSize = 1000 }()
+
+	ctrl := gomock.NewController(t)
+	fm := mock_filemanager.NewMockFileManager(ctrl)
+	fm.EXPECT().GetConfiguredPrefix().Return("backups/").AnyTimes()
+	gomock.InOrder(
+		fm.EXPECT().ListFilesWithPrefix(gomock.Any(), "backups/gw/1/", int64(2)).Return([]*filemanager.FileObject{
+			{Key: "backups/gw/1/gw_jobs_2.101.200.1650003600000.1650007200000.gz"},
+			{Key: "backups/gw/1/gw_job_status_2.gz"},
+		}, nil),
+		fm.EXPECT().ListFilesWithPrefix(gomock.Any(), "backups/gw/1/", int64(2)).Return([]*filemanager.FileObject{
+			{Key: "backups/gw/1/gw_jobs_1.1.100.1650000000000.1650003600000.gz"},
+			{Key: "backups/gw/1/gw_jobs_3.201.300.1650007200001.1650010800000.gz"},
+		}, nil),
+		fm.EXPECT().ListFilesWithPrefix(gomock.Any(), "backups/gw/1/", int64(2)).Return(nil, nil),
+	)
+
+	jd := &HandleT{tablePrefix: "gw", BackupSettings: &BackupSettingsT{PathPrefix: "gw"}}
+	files, err := jd.listBackupFiles(context.Background(), fm, RestoreOptsT{
+		From:       time.Unix(1650000000, 0),
+		To:         time.Unix(1650007200, 0),
+		InstanceID: "1",
+	})
+	require.NoError(t, err)
+
+	var keys []string
+	for _, file := range files {
+		keys = append(keys, file.key)
+	}
+	require.Equal(t, []string{
+		"backups/gw/1/gw_job_status_2.gz",
+		"backups/gw/1/gw_jobs_1.1.100.1650000000000.1650003600000.gz",
+		"backups/gw/1/gw_jobs_2.101.200.1650003600000.1650007200000.gz",
+	}, keys)
+}
+
+func Test_matchesRestoreOpts(t *testing.T) {
+	row := &backupRowT{WorkspaceID: "workspace", Parameters: []byte(`{"source_id":"source","destination_id":"destination"}`)}
+
+	require.True(t, matchesRestoreOpts(row, Aborted.State, RestoreOptsT{}))
+	require.True(t, matchesRestoreOpts(row, Aborted.State, RestoreOptsT{
+		SourceIDs:      []string{"other", "source"},
+		WorkspaceIDs:   []string{"workspace"},
+		DestinationIDs: []string{"destination"},
+		States:         []string{Aborted.State},
+	}))
+	require.False(t, matchesRestoreOpts(row, Aborted.State, RestoreOptsT{SourceIDs: []string{"other"}}))
+	require.False(t, matchesRestoreOpts(row, Aborted.State, RestoreOptsT{WorkspaceIDs: []string{"other"}}))
+	require.False(t, matchesRestoreOpts(row, Aborted.State, RestoreOptsT{DestinationIDs: []string{"other"}}))
+	require.False(t, matchesRestoreOpts(row, Succeeded.State, RestoreOptsT{States: []string{Aborted.State, NotProcessed.State}}))
+
+	require.True(t, isRestorableState(NotProcessed.State))
+	require.True(t, isRestorableState(Failed.State))
+	require.False(t, isRestorableState("unknown"))
+}
+
+func Test_validateRestoreOpts(t *testing.T) {
+	opts := RestoreOptsT{From: time.Now().Add(-time.Hour)}
+	require.NoError(t, opts.validate())
+	require.False(t, opts.To.IsZero(), "To defaults to now")
+
+	require.Error(t, (&RestoreOptsT{From: opts.To, To: opts.From}).validate())
+	require.Error(t, (&RestoreOptsT{From: opts.From, States: []string{"unknown"}}).validate())
+}
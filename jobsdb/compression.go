@@ -0,0 +1,150 @@
+package jobsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+/*
+Payload compression
+
+Event payloads are stored in the JSONB event_payload column. When compression is enabled for a jobsdb
+(JobsDB.<tablePrefix>.payloadCompression), the compressed payload is stored wrapped in a json envelope:
+
+	{"_rs_compression": "zstd", "_rs_payload": "<base64 of the compressed payload>"}
+
+Payloads which are not wrapped in an envelope are returned as they are, so datasets written
+before compression was enabled (or with a different algorithm) remain readable.
+Since the envelope hides the payload from postgres, queries must not use json operators on
+event_payload for compressed jobs (use event_count instead).
+*/
+
+const (
+	NoCompression     = ""
+	ZstdCompression   = "zstd"
+	SnappyCompression = "snappy"
+
+	compressionEnvelopeKey = "_rs_compression"
+)
+
+var (
+	compressionEnvelopeMarker = []byte(`"` + compressionEnvelopeKey + `"`)
+	zstdEncoder, _            = zstd.NewWriter(nil)
+	zstdDecoder, _            = zstd.NewReader(nil)
+)
+
+type compressedPayloadT struct {
+	Compression string `json:"_rs_compression"`
+	Payload     []byte `json:"_rs_payload"`
+}
+
+func isValidCompression(compression string) bool {
+	switch compression {
+	case NoCompression, ZstdCompression, SnappyCompression:
+		return true
+	}
+	return false
+}
+
+// isCompressedPayload reports whether payload is wrapped in a compression envelope
+func isCompressedPayload(payload []byte) bool {
+	return bytes.Contains(payload, compressionEnvelopeMarker)
+}
+
+// compressPayload compresses payload with the given algorithm and wraps it in a compression envelope.
+// Payloads which are already compressed are returned as they are.
+func compressPayload(compression string, payload []byte) ([]byte, error) {
+	if compression == NoCompression || len(payload) == 0 || isCompressedPayload(payload) {
+		return payload, nil
+	}
+
+	var compressed []byte
+	switch compression {
+	case ZstdCompression:
+		compressed = zstdEncoder.EncodeAll(payload, make([]byte, 0, len(payload)/2))
+	case SnappyCompression:
+		compressed = snappy.Encode(nil, payload)
+	default:
+		return nil, fmt.Errorf("unsupported payload compression: %q", compression)
+	}
+	return json.Marshal(compressedPayloadT{Compression: compression, Payload: compressed})
+}
+
+// compressPayload compresses payload with the compression configured for this jobsdb.
+// An unsupported compression is logged and the payload is stored uncompressed.
+func (jd *HandleT) compressPayload(payload []byte) ([]byte, error) {
+	compression := jd.payloadCompression
+	if !isValidCompression(compression) {
+		jd.logger.Errorf("[[ %s ]]: Unsupported payload compression %q, storing payloads uncompressed", jd.tablePrefix, compression)
+		return payload, nil
+	}
+	return compressPayload(compression, payload)
+}
+
+// decompressPayload returns the original payload of a compressed payload.
+// Uncompressed payloads are returned as they are.
+func decompressPayload(payload []byte) ([]byte, error) {
+	if !isCompressedPayload(payload) {
+		return payload, nil
+	}
+
+	var envelope compressedPayloadT
+	if err := json.Unmarshal(payload, &envelope); err != nil || envelope.Compression == NoCompression {
+		// the marker was part of a regular payload
+		return payload, nil
+	}
+
+	switch envelope.Compression {
+	case ZstdCompression:
+		return zstdDecoder.DecodeAll(envelope.Payload, nil)
+	case SnappyCompression:
+		return snappy.Decode(nil, envelope.Payload)
+	default:
+		return nil, fmt.Errorf("unsupported payload compression: %q", envelope.Compression)
+	}
+}
+
+// decompressJobPayload replaces the event payload of job with its decompressed version
+func decompressJobPayload(job *JobT) error {
+	payload, err := decompressPayload(job.EventPayload)
+	if err != nil {
+		return fmt.Errorf("decompressing payload of job %d: %w", job.JobID, err)
+	}
+	job.EventPayload = payload
+	return nil
+}
+
+// decompressBackupRows decompresses the event payloads of newline separated rows dumped as json objects
+func decompressBackupRows(rows []byte) ([]byte, error) {
+	if !isCompressedPayload(rows) {
+		return rows, nil
+	}
+
+	lines := bytes.Split(rows, []byte("\n"))
+	for i, line := range lines {
+		if !isCompressedPayload(line) {
+			continue
+		}
+		var row map[string]json.RawMessage
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, err
+		}
+		payload, ok := row["event_payload"]
+		if !ok {
+			continue
+		}
+		decompressed, err := decompressPayload(payload)
+		if err != nil {
+			return nil, err
+		}
+		row["event_payload"] = decompressed
+		if lines[i], err = json.Marshal(row); err != nil {
+			return nil, err
+		}
+	}
+	return bytes.Join(lines, []byte("\n")), nil
+}
@@ -0,0 +1,64 @@
+package jobsdb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_payloadCompression(t *testing.T) {
+	payload := []byte(`{"batch":[{"type":"track","event":"Demo Track","properties":{"value":5}},{"type":"track","event":"Demo Track","properties":{"value":5}}],"writeKey":"writeKey"}`)
+
+	for _, compression := range []string{ZstdCompression, SnappyCompression} {
+		t.Run(compression, func(t *testing.T) {
+			compressed, err := compressPayload(compression, payload)
+			require.NoError(t, err)
+			require.True(t, json.Valid(compressed), "compressed payload must be storable as jsonb")
+			require.True(t, isCompressedPayload(compressed))
+
+			recompressed, err := compressPayload(compression, compressed)
+			require.NoError(t, err)
+			require.Equal(t, compressed, recompressed, "compressed payloads must not be compressed twice")
+
+			decompressed, err := decompressPayload(compressed)
+			require.NoError(t, err)
+			require.Equal(t, payload, decompressed)
+		})
+	}
+
+	t.Run("no compression", func(t *testing.T) {
+		compressed, err := compressPayload(NoCompression, payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, compressed)
+	})
+
+	t.Run("unsupported compression", func(t *testing.T) {
+		_, err := compressPayload("gzip", payload)
+		require.Error(t, err)
+
+		_, err = decompressPayload([]byte(`{"_rs_compression":"gzip","_rs_payload":""}`))
+		require.Error(t, err)
+	})
+
+	t.Run("uncompressed payloads are returned as they are", func(t *testing.T) {
+		decompressed, err := decompressPayload(payload)
+		require.NoError(t, err)
+		require.Equal(t, payload, decompressed)
+
+		withMarker := []byte(`{"properties":{"_rs_compression":"zstd"}}`)
+		decompressed, err = decompressPayload(withMarker)
+		require.NoError(t, err)
+		require.Equal(t, withMarker, decompressed)
+	})
+
+	t.Run("backup rows", func(t *testing.T) {
+		compressed, err := compressPayload(ZstdCompression, payload)
+		require.NoError(t, err)
+
+		rows := []byte(`{"job_id":1,"event_payload":` + string(compressed) + `}` + "\n" + `{"job_id":2,"event_payload":` + string(payload) + `}`)
+		decompressed, err := decompressBackupRows(rows)
+		require.NoError(t, err)
+		require.Equal(t, `{"event_payload":`+string(payload)+`,"job_id":1}`+"\n"+`{"job_id":2,"event_payload":`+string(payload)+`}`, string(decompressed))
+	})
+}
@@ -584,3 +584,75 @@ func consume(t testing.TB, db *jobsdb.HandleT, count int) {
 	err := db.UpdateJobStatus(status, []string{}, []jobsdb.ParameterFilterT{})
 	require.NoError(t, err)
 }
+
+func TestJobsDB_SelectJobs(t *testing.T) {
+	initJobsDB()
+	stats.Setup()
+
+	// selected payloads must be decompressed
+	config.SetString("JobsDB.select.payloadCompression", jobsdb.ZstdCompression)
+	defer config.SetString("JobsDB.select.payloadCompression", jobsdb.NoCompression)
+
+	triggerAddNewDS := make(chan time.Time)
+	maxDSSize := 10
+	jobDB := jobsdb.HandleT{
+		MaxDSSize: &maxDSSize,
+		TriggerAddNewDS: func() <-chan time.Time {
+			return triggerAddNewDS
+		},
+	}
+	jobDB.Setup(jobsdb.ReadWrite, true, "select", time.Minute*5, "", true, jobsdb.QueryFiltersT{})
+	defer jobDB.TearDown()
+
+	job := func(sourceID, destinationID string) *jobsdb.JobT {
+		return &jobsdb.JobT{
+			Parameters:   []byte(fmt.Sprintf(`{"source_id":%q,"destination_id":%q}`, sourceID, destinationID)),
+			EventPayload: []byte(`{"type": "track"}`),
+			UserID:       "user",
+			UUID:         uuid.Must(uuid.NewV4()),
+			CustomVal:    "MOCKDS",
+			EventCount:   1,
+			WorkspaceId:  "workspaceID",
+		}
+	}
+	from := time.Now().Add(-time.Minute)
+	require.NoError(t, jobDB.Store([]*jobsdb.JobT{job("source", "destination"), job("source", "other")}))
+	triggerAddNewDS <- time.Now()
+	triggerAddNewDS <- time.Now() //Second time, waits for the first loop to finish
+	require.NoError(t, jobDB.Store([]*jobsdb.JobT{job("source", "destination"), job("other", "destination")}))
+
+	aborted := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{"MOCKDS"}, JobCount: 1})
+	require.Len(t, aborted, 1)
+	require.NoError(t, jobDB.UpdateJobStatus([]*jobsdb.JobStatusT{{
+		JobID:         aborted[0].JobID,
+		JobState:      jobsdb.Aborted.State,
+		AttemptNum:    1,
+		ExecTime:      time.Now(),
+		RetryTime:     time.Now(),
+		ErrorResponse: []byte(`{}`),
+		Parameters:    []byte(`{}`),
+	}}, []string{"MOCKDS"}, []jobsdb.ParameterFilterT{}))
+
+	selectJobs := func(opts jobsdb.RestoreOptsT) []*jobsdb.JobT {
+		var jobs []*jobsdb.JobT
+		count, err := jobDB.SelectJobs(context.Background(), opts, func(selected []*jobsdb.JobT) error {
+			jobs = append(jobs, selected...)
+			// jobs stored while selecting are not selected
+			return jobDB.Store([]*jobsdb.JobT{job("source", "destination")})
+		})
+		require.NoError(t, err)
+		require.Equal(t, len(jobs), count)
+		return jobs
+	}
+
+	require.Len(t, selectJobs(jobsdb.RestoreOptsT{From: from, SourceIDs: []string{"source"}, DestinationIDs: []string{"destination"}, States: []string{jobsdb.NotProcessed.State}}), 1)
+	abortedJobs := selectJobs(jobsdb.RestoreOptsT{From: from, States: []string{jobsdb.Aborted.State}})
+	require.Len(t, abortedJobs, 1)
+	require.Equal(t, aborted[0].JobID, abortedJobs[0].JobID)
+	require.Equal(t, jobsdb.Aborted.State, abortedJobs[0].LastJobStatus.JobState)
+	require.JSONEq(t, `{"type": "track"}`, string(abortedJobs[0].EventPayload))
+	require.Empty(t, selectJobs(jobsdb.RestoreOptsT{From: from.Add(-time.Hour), To: from}))
+
+	_, err := jobDB.SelectJobs(context.Background(), jobsdb.RestoreOptsT{From: from, States: []string{"unknown"}}, nil)
+	require.Error(t, err)
+}
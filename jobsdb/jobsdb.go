@@ -323,6 +323,7 @@ type HandleT struct {
 	dsListLock                    sync.RWMutex
 	dsMigrationLock               sync.RWMutex
 	dsRetentionPeriod             time.Duration
+	workspaceRetention            func(workspaceID string) time.Duration
 	dsEmptyResultCache            map[dataSetT]map[string]map[string]map[string]map[string]cacheEntry //DS -> workspace -> customVal -> params -> state -> cacheEntry
 	dsCacheLock                   sync.Mutex
 	BackupSettings                *BackupSettingsT
@@ -351,6 +352,13 @@ type HandleT struct {
 	queryFilterKeys               QueryFiltersT
 	backgroundCancel              context.CancelFunc
 	backgroundGroup               *errgroup.Group
+	backgroundCtx                 context.Context
+	subscriptionGroup             sync.WaitGroup
+	subscriptionLock              sync.Mutex
+	subscribers                   map[chan struct{}]struct{}
+	storeListener                 *pq.Listener
+	enableStoreNotifications      bool
+	payloadCompression            string
 	maxBackupRetryTime            time.Duration
 
 	// skipSetupDBSetup is useful for testing as we mock the database client
@@ -501,6 +509,7 @@ var (
 	Aborted     = jobStateT{isValid: true, isTerminal: true, State: "aborted"}
 	Migrated    = jobStateT{isValid: true, isTerminal: true, State: "migrated"}
 	WontMigrate = jobStateT{isValid: true, isTerminal: true, State: "wont_migrate"}
+	Expired     = jobStateT{isValid: true, isTerminal: true, State: "expired"}
 )
 
 //Adding a new state to this list, will require an enum change in postgres db.
@@ -516,6 +525,7 @@ var jobStates []jobStateT = []jobStateT{
 	Migrated,
 	WontMigrate,
 	Importing,
+	Expired,
 }
 
 //OwnerType for this jobsdb instance
@@ -566,6 +576,14 @@ var (
 	backupRowsBatchSize                          int64
 	pkgLogger                                    logger.LoggerI
 	useNewCacheBurst                             bool
+	subscriptionPollInterval                     time.Duration
+	restoreBatchSize                             int
+	restoreListBatchSize                         int64
+	backend                                      string
+	localPath                                    string
+	localMaxTableSize                            int64
+	localTerminalJobsRetention                   time.Duration
+	localGCSleepDuration                         time.Duration
 )
 
 // Loads db config and migration related config from config file
@@ -606,6 +624,22 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(time.Duration(60), &cacheExpiration, true, time.Minute, []string{"JobsDB.cacheExpiration"}...)
 	useJoinForUnprocessed = config.GetBool("JobsDB.useJoinForUnprocessed", true)
 	config.RegisterBoolConfigVariable(true, &useNewCacheBurst, true, "JobsDB.useNewCacheBurst")
+	config.RegisterDurationConfigVariable(time.Duration(1), &subscriptionPollInterval, true, time.Second, []string{"JobsDB.subscriptionPollInterval", "JobsDB.subscriptionPollIntervalInS"}...)
+	config.RegisterIntConfigVariable(1000, &restoreBatchSize, true, 1, "JobsDB.restore.batchSize")
+	config.RegisterInt64ConfigVariable(1000, &restoreListBatchSize, true, 1, "JobsDB.restore.listBatchSize")
+
+	/*Local driver related parameters
+	backend: Storage of the jobsdb instances of the pipeline of the embedded app, postgres or local
+	localPath: Directory under which local (badger backed) jobsdb instances keep their data. Defaults to a directory in RUDDER_TMPDIR
+	localMaxTableSizeInMB: Size of the tables of local jobsdb instances, which bounds the size of a batch stored or updated atomically to about 15% of it
+	localTerminalJobsRetention: How long jobs in a terminal state are kept before they expire
+	localGCSleepDuration: How often the value log of local jobsdb instances is garbage collected
+	*/
+	backend = config.GetString("JobsDB.backend", PostgresBackend)
+	localPath = config.GetString("JobsDB.local.path", "")
+	localMaxTableSize = config.GetInt64("JobsDB.local.maxTableSizeInMB", 64) << 20
+	config.RegisterDurationConfigVariable(time.Duration(60), &localTerminalJobsRetention, true, time.Minute, "JobsDB.local.terminalJobsRetention")
+	config.RegisterDurationConfigVariable(time.Duration(5), &localGCSleepDuration, false, time.Minute, "JobsDB.local.gcSleepDuration")
 }
 
 func Init2() {
@@ -636,6 +670,14 @@ func WithRetention(period time.Duration) OptsFunc {
 	}
 }
 
+// WithWorkspaceRetention overrides the retention of jobs per workspace, configured by JobsDB.<tablePrefix>.workspaceRetention.<workspaceID>.
+// Jobs which are not processed within the retention of their workspace are expired.
+func WithWorkspaceRetention(retention func(workspaceID string) time.Duration) OptsFunc {
+	return func(jd *HandleT) {
+		jd.workspaceRetention = retention
+	}
+}
+
 func WithQueryFilterKeys(filters QueryFiltersT) OptsFunc {
 	return func(jd *HandleT) {
 		jd.queryFilterKeys = filters
@@ -774,6 +816,10 @@ func (jd *HandleT) workersAndAuxSetup() {
 	config.RegisterIntConfigVariable(1, &jd.maxWriters, false, 1, maxWritersKeys...)
 	maxReadersKeys := []string{"JobsDB." + jd.tablePrefix + "." + "maxReaders", "JobsDB." + "maxReaders"}
 	config.RegisterIntConfigVariable(3, &jd.maxReaders, false, 1, maxReadersKeys...)
+	enableStoreNotificationsKeys := []string{"JobsDB." + jd.tablePrefix + "." + "enableStoreNotifications", "JobsDB." + "enableStoreNotifications"}
+	config.RegisterBoolConfigVariable(false, &jd.enableStoreNotifications, true, enableStoreNotificationsKeys...)
+	payloadCompressionKeys := []string{"JobsDB." + jd.tablePrefix + "." + "payloadCompression", "JobsDB." + "payloadCompression"}
+	config.RegisterStringConfigVariable(NoCompression, &jd.payloadCompression, true, payloadCompressionKeys...)
 }
 
 // Start starts the jobsdb worker and housekeeping (migration, archive) threads.
@@ -787,6 +833,7 @@ func (jd *HandleT) Start() {
 
 	jd.backgroundCancel = cancel
 	jd.backgroundGroup = g
+	jd.backgroundCtx = ctx
 
 	g.Go(func() error {
 		jd.initDBWriters(ctx)
@@ -812,9 +859,11 @@ func (jd *HandleT) setUpForOwnerType(ctx context.Context, ownerType OwnerType, c
 	case Write:
 		jd.setupDatabaseTables(clearAll)
 		jd.writerSetup(ctx)
+		registerRestorable(jd)
 	case ReadWrite:
 		jd.setupDatabaseTables(clearAll)
 		jd.readerWriterSetup(ctx)
+		registerRestorable(jd)
 	}
 }
 
@@ -944,6 +993,9 @@ func (jd *HandleT) dbReader(ctx context.Context) {
 // Only Start and Close can be called after Stop.
 func (jd *HandleT) Stop() {
 	jd.backgroundCancel()
+	jd.stopStoreListener()
+	// subscribers might still be querying, wait for them before closing the request channels
+	jd.subscriptionGroup.Wait()
 	close(jd.readChannel)
 	close(jd.writeChannel)
 	jd.backgroundGroup.Wait()
@@ -1451,7 +1503,8 @@ func (jd *HandleT) createDS(newDS dataSetT, refreshList bool) {
                                       event_payload JSONB NOT NULL,
 									  event_count INTEGER NOT NULL DEFAULT 1,
                                       created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-                                      expire_at TIMESTAMP NOT NULL DEFAULT NOW());`, newDS.JobTable)
+                                      expire_at TIMESTAMP NOT NULL DEFAULT NOW(),
+                                      retention INTERVAL);`, newDS.JobTable)
 
 	_, err = jd.dbHandle.Exec(sqlStatement)
 	jd.assertError(err)
@@ -2002,7 +2055,7 @@ func (jd *HandleT) copyJobsDSInTxn(txHandler transactionHandler, ds dataSetT, jo
 	var err error
 
 	stmt, err = txHandler.Prepare(pq.CopyIn(ds.JobTable, "job_id", "uuid", "user_id", "custom_val", "parameters",
-		"event_payload", "event_count", "created_at", "expire_at", "workspace_id"))
+		"event_payload", "event_count", "created_at", "expire_at", "workspace_id", "retention"))
 
 	if err != nil {
 		return err
@@ -2010,14 +2063,21 @@ func (jd *HandleT) copyJobsDSInTxn(txHandler transactionHandler, ds dataSetT, jo
 
 	defer stmt.Close()
 
+	//Copied jobs keep their created_at, so they expire after the current retention of their workspace since their creation
+	retentions := make(map[string]interface{})
 	for _, job := range jobList {
 		eventCount := 1
 		if job.EventCount > 1 {
 			eventCount = job.EventCount
 		}
 
+		payload, err := jd.compressPayload(job.EventPayload)
+		if err != nil {
+			return err
+		}
+
 		_, err = stmt.Exec(job.JobID, job.UUID, job.UserID, job.CustomVal, string(job.Parameters),
-			string(job.EventPayload), eventCount, job.CreatedAt, job.ExpireAt, job.WorkspaceId)
+			string(payload), eventCount, job.CreatedAt, job.ExpireAt, job.WorkspaceId, jd.retentionInterval(retentions, job.WorkspaceId))
 
 		if err != nil {
 			return err
@@ -2035,43 +2095,58 @@ func (jd *HandleT) copyJobsDSInTxn(txHandler transactionHandler, ds dataSetT, jo
 	return err
 }
 
-func (*HandleT) storeJobsDSInTxn(txHandler transactionHandler, ds dataSetT, jobList []*JobT) error {
+func (jd *HandleT) storeJobsDSInTxn(txHandler transactionHandler, ds dataSetT, jobList []*JobT) error {
 	var stmt *sql.Stmt
 	var err error
 
-	stmt, err = txHandler.Prepare(pq.CopyIn(ds.JobTable, "uuid", "user_id", "custom_val", "parameters", "event_payload", "event_count", "workspace_id"))
+	stmt, err = txHandler.Prepare(pq.CopyIn(ds.JobTable, "uuid", "user_id", "custom_val", "parameters", "event_payload", "event_count", "workspace_id", "retention"))
 	if err != nil {
 		return err
 	}
 
 	defer stmt.Close()
 
+	retentions := make(map[string]interface{})
 	for _, job := range jobList {
 		eventCount := 1
 		if job.EventCount > 1 {
 			eventCount = job.EventCount
 		}
 
-		if _, err = stmt.Exec(job.UUID, job.UserID, job.CustomVal, string(job.Parameters), string(job.EventPayload), eventCount, job.WorkspaceId); err != nil {
+		payload, err := jd.compressPayload(job.EventPayload)
+		if err != nil {
+			return err
+		}
+
+		if _, err = stmt.Exec(job.UUID, job.UserID, job.CustomVal, string(job.Parameters), string(payload), eventCount, job.WorkspaceId, jd.retentionInterval(retentions, job.WorkspaceId)); err != nil {
 			return err
 		}
 	}
-	_, err = stmt.Exec()
-	return err
+	if _, err = stmt.Exec(); err != nil {
+		return err
+	}
+	return jd.notifyStoreInTxn(txHandler)
 }
 
 func (jd *HandleT) storeJobDS(ds dataSetT, job *JobT) (err error) {
-	sqlStatement := fmt.Sprintf(`INSERT INTO "%s" (uuid, user_id, custom_val, parameters, event_payload, workspace_id)
-	                                   VALUES ($1, $2, $3, $4, (regexp_replace($5::text, '\\u0000', '', 'g'))::json , $6) RETURNING job_id`, ds.JobTable)
+	sqlStatement := fmt.Sprintf(`INSERT INTO "%s" (uuid, user_id, custom_val, parameters, event_payload, workspace_id, retention)
+	                                   VALUES ($1, $2, $3, $4, (regexp_replace($5::text, '\\u0000', '', 'g'))::json , $6, $7::interval) RETURNING job_id`, ds.JobTable)
+	payload, err := jd.compressPayload(job.EventPayload)
+	if err != nil {
+		return err
+	}
 	stmt, err := jd.dbHandle.Prepare(sqlStatement)
 	jd.assertError(err)
 	defer stmt.Close()
-	_, err = stmt.Exec(job.UUID, job.UserID, job.CustomVal, string(job.Parameters), string(job.EventPayload), job.WorkspaceId)
+	_, err = stmt.Exec(job.UUID, job.UserID, job.CustomVal, string(job.Parameters), string(payload), job.WorkspaceId, jd.retentionInterval(nil, job.WorkspaceId))
 	if err == nil {
 		//Empty customValFilters means we want to clear for all
 		jd.markClearEmptyResult(ds, allWorkspaces, []string{}, []string{}, nil, hasJobs, nil)
 		jd.markClearEmptyResult(ds, job.WorkspaceId, []string{}, []string{}, nil, hasJobs, nil)
 		// fmt.Println("Bursting CACHE")
+		if notifyErr := jd.notifyStoreInTxn(jd.dbHandle); notifyErr != nil {
+			jd.logger.Errorf("[[ %s ]]: Failed to notify subscribers about stored job: %v", jd.tablePrefix, notifyErr)
+		}
 		return
 	}
 	pqErr, ok := err.(*pq.Error)
@@ -2360,6 +2435,7 @@ func (jd *HandleT) getProcessedJobsDS(ds dataSetT, getAll bool, limitCount int,
 			&job.LastJobStatus.ExecTime, &job.LastJobStatus.RetryTime,
 			&job.LastJobStatus.ErrorCode, &job.LastJobStatus.ErrorResponse, &job.LastJobStatus.Parameters)
 		jd.assertError(err)
+		jd.assertError(decompressJobPayload(&job))
 		jobList = append(jobList, &job)
 	}
 
@@ -2465,6 +2541,7 @@ func (jd *HandleT) getUnprocessedJobsDS(ds dataSetT, order bool, count int, para
 		err := rows.Scan(&job.JobID, &job.UUID, &job.UserID, &job.Parameters, &job.CustomVal,
 			&job.EventPayload, &job.EventCount, &job.CreatedAt, &job.ExpireAt, &job.WorkspaceId, &_null)
 		jd.assertError(err)
+		jd.assertError(decompressJobPayload(&job))
 		jobList = append(jobList, &job)
 	}
 
@@ -2672,6 +2749,8 @@ func (jd *HandleT) migrateDSLoop(ctx context.Context) {
 				break
 			}
 
+			//Expired jobs are terminal, so they are not copied forward and their datasets become eligible for migration
+			jd.expireJobs(ds)
 			ifMigrate, remCount := jd.checkIfMigrateDS(ds)
 			jd.logger.Debugf("[[ %s : migrateDSLoop ]]: Migrate check %v, ds: %v", jd.tablePrefix, ifMigrate, ds)
 
@@ -2982,7 +3061,12 @@ func (jd *HandleT) backupTable(ctx context.Context, backupDSRange *dataSetRangeT
 		//Asserting that the first character is '[' and last character is ']'
 		jd.assert(rawJSONRows[0] == byte('[') && rawJSONRows[len(rawJSONRows)-1] == byte(']'), "json agg output is not in the expected format. Excepted format: JSON Array [{}]")
 		rawJSONRows = rawJSONRows[1 : len(rawJSONRows)-1] //stripping starting '[' and ending ']'
-		rawJSONRows = append(rawJSONRows, '\n')           //appending '\n'
+		if !isJobStatusTable || jd.BackupSettings.FailedOnly {
+			// backups contain the original payloads, even if they are stored compressed
+			rawJSONRows, err = decompressBackupRows(rawJSONRows)
+			jd.assertError(err)
+		}
+		rawJSONRows = append(rawJSONRows, '\n') //appending '\n'
 
 		gzWriter.Write(rawJSONRows)
 		offset += backupRowsBatchSize
@@ -3499,6 +3583,21 @@ func (jd *HandleT) store(jobList []*JobT) error {
 	return err
 }
 
+/*
+StoreInTxn stores new jobs in the passed transaction, which must be of the database of this jobsdb.
+IMP NOTE: AcquireStoreLock Should be called before calling this function, and released once the transaction is done
+*/
+func (jd *HandleT) StoreInTxn(txn *sql.Tx, jobList []*JobT) error {
+	queryStat := jd.getTimerStat("store_jobs", nil)
+	queryStat.Start()
+	defer queryStat.End()
+
+	dsList := jd.getDSList(false)
+	ds := dsList[len(dsList)-1]
+	defer jd.clearCache(ds, jobList)
+	return jd.storeJobsDSInTxn(txn, ds, jobList)
+}
+
 func (jd *HandleT) StoreWithRetryEach(jobList []*JobT) map[uuid.UUID]string {
 	command := func() interface{} {
 		return jd.storeWithRetryEach(jobList)
@@ -3957,5 +4056,6 @@ func (jd *HandleT) GetLastJob() *JobT {
 	if err != nil && err != sql.ErrNoRows {
 		jd.assertError(err)
 	}
+	jd.assertError(decompressJobPayload(&job))
 	return &job
 }
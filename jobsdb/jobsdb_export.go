@@ -208,6 +208,7 @@ func (jd *HandleT) getNonMigratedJobsFromDS(ds dataSetT, count int) ([]*JobT, er
 			job.LastJobStatus.JobID = job.JobID
 			jd.assertError(err)
 		}
+		jd.assertError(decompressJobPayload(&job))
 		jobList = append(jobList, &job)
 	}
 
@@ -265,8 +265,12 @@ func (handler *JobsdbUtilsHandler) RunSQLQuery(argString string, reply *string)
 }
 
 func (jd *HandleT) getTimerStat(stat string, tags *StatTagsT) stats.RudderStats {
+	return newTimerStat(jd.tablePrefix, stat, tags)
+}
+
+func newTimerStat(tablePrefix, stat string, tags *StatTagsT) stats.RudderStats {
 	timingTags := map[string]string{
-		"tablePrefix": jd.tablePrefix,
+		"tablePrefix": tablePrefix,
 	}
 	if tags != nil {
 		customValTag := strings.Join(tags.CustomValFilters, "_")
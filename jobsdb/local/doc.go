@@ -0,0 +1,5 @@
+/*
+Package local holds the tests of jobsdb.LocalHandleT. They are kept apart from the jobsdb tests, which need a postgres
+started with docker, since the local jobsdb runs without one.
+*/
+package local
@@ -0,0 +1,219 @@
+package local_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v2"
+	uuid "github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/admin"
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+func initJobsDB() {
+	config.Load()
+	logger.Init()
+	admin.Init()
+	jobsdb.Init()
+	jobsdb.Init2()
+	jobsdb.Init3()
+}
+
+func genJobs(customVal string, jobCount, eventsPerJob int) []*jobsdb.JobT {
+	js := make([]*jobsdb.JobT, jobCount)
+	for i := range js {
+		js[i] = &jobsdb.JobT{
+			Parameters:   []byte(`{"batch_id":1,"source_id":"sourceID","source_job_run_id":""}`),
+			EventPayload: []byte(`{"writeKey":"writeKey","batch":[{"anonymousId":"anon_id","event":"Demo Track","messageId":"b96f3d8a-7c26-4329-9671-4e3202f42f15","type":"track"}]}`),
+			UserID:       "a-292e-4e79-9880-f8009e0ae4a3",
+			UUID:         uuid.Must(uuid.NewV4()),
+			CustomVal:    customVal,
+			EventCount:   eventsPerJob,
+			WorkspaceId:  "workspaceID",
+		}
+	}
+	return js
+}
+
+func TestLocalJobsDB(t *testing.T) {
+	// small tables to fail big batches, see "batches are stored atomically"
+	t.Setenv(config.TransformKey("JobsDB.local.maxTableSizeInMB"), "1")
+	initJobsDB()
+	stats.Setup()
+
+	path := t.TempDir()
+	customVal := "MOCKDS"
+
+	jobDB := jobsdb.NewLocal("local", jobsdb.WithLocalPath(path))
+
+	require.Empty(t, jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 1}))
+
+	require.NoError(t, jobDB.Store(genJobs(customVal, 5, 2)))
+	require.NoError(t, jobDB.Store(genJobs("OTHER", 2, 1)))
+
+	t.Run("unprocessed jobs are returned in job id order", func(t *testing.T) {
+		unprocessed := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.Len(t, unprocessed, 5)
+		for i, job := range unprocessed {
+			require.Equal(t, int64(i+1), job.JobID)
+			require.Equal(t, customVal, job.CustomVal)
+		}
+	})
+
+	t.Run("event count limits the returned jobs", func(t *testing.T) {
+		unprocessed := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10, EventCount: 3})
+		require.Len(t, unprocessed, 2)
+	})
+
+	t.Run("batches are stored atomically", func(t *testing.T) {
+		bigJobs := genJobs("BIG", 1000, 1)
+		for _, job := range bigJobs {
+			job.EventPayload = []byte(`{"padding":"` + strings.Repeat("a", 500) + `"}`)
+		}
+		err := jobDB.Store(bigJobs)
+		require.ErrorIs(t, err, badger.ErrTxnTooBig)
+		require.Empty(t, jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{"BIG"}, JobCount: 1000}), "no job of a failed batch is stored")
+	})
+
+	t.Run("parameter filters", func(t *testing.T) {
+		unprocessed := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{
+			JobCount:         10,
+			ParameterFilters: []jobsdb.ParameterFilterT{{Name: "source_id", Value: "sourceID"}},
+		})
+		require.Len(t, unprocessed, 7)
+
+		unprocessed = jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{
+			JobCount:         10,
+			ParameterFilters: []jobsdb.ParameterFilterT{{Name: "source_id", Value: "other"}},
+		})
+		require.Empty(t, unprocessed)
+
+		unprocessed = jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{
+			JobCount:         10,
+			ParameterFilters: []jobsdb.ParameterFilterT{{Name: "destination_id", Value: "destID", Optional: true}},
+		})
+		require.Len(t, unprocessed, 7)
+	})
+
+	t.Run("jobs move between states", func(t *testing.T) {
+		unprocessed := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 3})
+		var statuses []*jobsdb.JobStatusT
+		for i, job := range unprocessed {
+			state := jobsdb.Executing.State
+			if i == 0 {
+				state = jobsdb.Failed.State
+			}
+			statuses = append(statuses, &jobsdb.JobStatusT{
+				JobID:         job.JobID,
+				JobState:      state,
+				AttemptNum:    1,
+				ExecTime:      time.Now(),
+				RetryTime:     time.Now(),
+				ErrorResponse: []byte(`{}`),
+				Parameters:    []byte(`{}`),
+				WorkspaceId:   job.WorkspaceId,
+			})
+		}
+		require.NoError(t, jobDB.UpdateJobStatus(statuses, []string{customVal}, nil))
+
+		require.Len(t, jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10}), 2)
+
+		toRetry := jobDB.GetToRetry(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.Len(t, toRetry, 1)
+		require.Equal(t, int64(1), toRetry[0].JobID)
+		require.Equal(t, jobsdb.Failed.State, toRetry[0].LastJobStatus.JobState)
+
+		executing := jobDB.GetExecuting(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.Len(t, executing, 2)
+
+		statMap := map[string]map[string]int{}
+		jobDB.GetPileUpCounts(statMap)
+		require.Equal(t, map[string]map[string]int{"workspaceID": {customVal: 3, "OTHER": 2}}, statMap)
+
+		jobDB.DeleteExecuting(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: -1})
+		require.Empty(t, jobDB.GetExecuting(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10}))
+		unprocessed = jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.Len(t, unprocessed, 4)
+		for _, job := range unprocessed {
+			require.Equal(t, customVal, job.CustomVal, "jobs of reverted executing statuses are kept")
+			require.JSONEq(t, string(genJobs(customVal, 1, 1)[0].EventPayload), string(job.EventPayload))
+		}
+	})
+
+	t.Run("all jobs are picked up to the count of the workspaces", func(t *testing.T) {
+		jobs := jobDB.GetAllJobs(map[string]int{"workspaceID": 3}, jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}}, 0)
+		require.Len(t, jobs, 3)
+		require.Equal(t, int64(1), jobs[0].JobID, "jobs to retry come first")
+		require.Equal(t, jobsdb.Failed.State, jobs[0].LastJobStatus.JobState)
+		for _, job := range jobs[1:] {
+			require.Empty(t, job.LastJobStatus.JobState)
+		}
+	})
+
+	t.Run("pending jobs", func(t *testing.T) {
+		ctx := context.Background()
+		pending, err := jobDB.HavePendingJobs(ctx, []string{customVal}, -1, nil)
+		require.NoError(t, err)
+		require.True(t, pending)
+
+		pending, err = jobDB.HavePendingJobs(ctx, []string{"OTHER"}, -1, []jobsdb.ParameterFilterT{{Name: "source_id", Value: "other"}})
+		require.NoError(t, err)
+		require.False(t, pending)
+
+		others := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{"OTHER"}, JobCount: 10})
+		require.Len(t, others, 2)
+		statuses := []*jobsdb.JobStatusT{
+			{JobID: others[0].JobID, JobState: jobsdb.Failed.State, RetryTime: time.Now().Add(time.Hour), ErrorResponse: []byte(`{}`), Parameters: []byte(`{}`)},
+			{JobID: others[1].JobID, JobState: jobsdb.Succeeded.State, ErrorResponse: []byte(`{}`), Parameters: []byte(`{}`)},
+		}
+		require.NoError(t, jobDB.UpdateJobStatus(statuses, []string{"OTHER"}, nil))
+		pending, err = jobDB.HavePendingJobs(ctx, []string{"OTHER"}, -1, nil)
+		require.NoError(t, err)
+		require.True(t, pending, "failed jobs are pending before their retry time")
+
+		statuses = statuses[:1]
+		statuses[0].JobState = jobsdb.Aborted.State
+		require.NoError(t, jobDB.UpdateJobStatus(statuses, []string{"OTHER"}, nil))
+		pending, err = jobDB.HavePendingJobs(ctx, []string{"OTHER"}, -1, nil)
+		require.NoError(t, err)
+		require.False(t, pending)
+
+		_, err = jobDB.GetDSListString()
+		require.Error(t, err, "sql debugging queries are not supported")
+	})
+
+	t.Run("updating the status of an unknown job fails", func(t *testing.T) {
+		err := jobDB.UpdateJobStatus([]*jobsdb.JobStatusT{{JobID: 1000, JobState: jobsdb.Succeeded.State}}, nil, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("journal", func(t *testing.T) {
+		opID := jobDB.JournalMarkStart(jobsdb.RawDataDestUploadOperation, []byte(`{}`))
+		entries := jobDB.GetJournalEntries(jobsdb.RawDataDestUploadOperation)
+		require.Len(t, entries, 1)
+		require.Equal(t, opID, entries[0].OpID)
+
+		jobDB.JournalDeleteEntry(opID)
+		require.Empty(t, jobDB.GetJournalEntries(jobsdb.RawDataDestUploadOperation))
+	})
+
+	t.Run("jobs survive a restart", func(t *testing.T) {
+		jobDB.TearDown()
+		jobDB = jobsdb.NewLocal("local", jobsdb.WithLocalPath(path))
+
+		require.Len(t, jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10}), 4)
+		require.NoError(t, jobDB.Store(genJobs(customVal, 1, 1)))
+		unprocessed := jobDB.GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.Len(t, unprocessed, 5)
+		require.Greater(t, unprocessed[4].JobID, int64(7))
+	})
+
+	jobDB.TearDown()
+}
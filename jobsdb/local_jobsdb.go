@@ -0,0 +1,861 @@
+package jobsdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	badger "github.com/dgraph-io/badger/v2"
+	uuid "github.com/gofrs/uuid"
+	"github.com/rudderlabs/rudder-server/admin"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/tidwall/gjson"
+)
+
+/*
+LocalHandleT is a JobsDB implementation which keeps jobs in an embedded,
+log-structured key-value store (badger) on local disk instead of postgres.
+It is meant for deployments where postgres is not available and for tests
+which need a JobsDB without a database container.
+
+Every job is stored once under its job id. The latest state of each job is
+tracked through a secondary index (state -> job id), so reading jobs of a
+given state is a prefix scan ordered by job id, just like the datasets of the
+postgres implementation. Jobs reaching a terminal state are kept for
+terminalJobsRetention and then expire; the value log is garbage collected in
+the background.
+
+Transactions are not shared across jobsdb instances: BeginGlobalTransaction
+returns a nil transaction and the *InTxn methods apply their changes directly.
+*/
+type LocalHandleT struct {
+	tablePrefix           string
+	path                  string
+	clearAll              bool
+	registerStatusHandler bool
+	logger                logger.LoggerI
+
+	db         *badger.DB
+	jobSeq     *badger.Sequence
+	journalSeq *badger.Sequence
+	// writeLock serialises writers, so that concurrent status updates
+	// never conflict while moving a job between state indexes
+	writeLock sync.Mutex
+
+	close  chan struct{}
+	gcDone chan struct{}
+}
+
+var (
+	_ JobsDB            = &LocalHandleT{}
+	_ MultiTenantJobsDB = &LocalHandleT{}
+	_ ReadonlyJobsDB    = &LocalHandleT{}
+)
+
+var (
+	localJobPrefix     = []byte("job/")
+	localStatusPrefix  = []byte("status/")
+	localStatePrefix   = []byte("state/")
+	localJournalPrefix = []byte("journal/")
+	localJobSeqKey     = []byte("seq/job")
+	localJournalSeqKey = []byte("seq/journal")
+)
+
+// storage backends of the jobsdb instances of the pipeline, set by JobsDB.backend
+const (
+	PostgresBackend = "postgres"
+	LocalBackend    = "local"
+)
+
+// UsesLocalBackend returns true if the jobsdb instances of the pipeline keep their jobs on local disk, see NewLocal
+func UsesLocalBackend() bool {
+	return backend == LocalBackend
+}
+
+type LocalOptsFunc func(jd *LocalHandleT)
+
+// WithLocalPath sets the directory the store is kept in. Defaults to JobsDB.local.path/<tablePrefix>
+func WithLocalPath(path string) LocalOptsFunc {
+	return func(jd *LocalHandleT) {
+		jd.path = path
+	}
+}
+
+// WithLocalClearDB, if set to true it will remove all existing jobs
+func WithLocalClearDB(clearDB bool) LocalOptsFunc {
+	return func(jd *LocalHandleT) {
+		jd.clearAll = clearDB
+	}
+}
+
+func WithLocalStatusHandler() LocalOptsFunc {
+	return func(jd *LocalHandleT) {
+		jd.registerStatusHandler = true
+	}
+}
+
+func defaultLocalPath() string {
+	if localPath != "" {
+		return localPath
+	}
+	tmpDirPath, err := misc.CreateTMPDIR()
+	if err != nil {
+		panic(err)
+	}
+	return filepath.Join(tmpDirPath, "rudder-jobsdb")
+}
+
+// NewLocal opens (or creates) the local store for tablePrefix and starts its housekeeping
+func NewLocal(tablePrefix string, opts ...LocalOptsFunc) *LocalHandleT {
+	jd := &LocalHandleT{
+		tablePrefix: tablePrefix,
+		close:       make(chan struct{}),
+		gcDone:      make(chan struct{}),
+	}
+	for _, fn := range opts {
+		fn(jd)
+	}
+	jd.assert(jd.tablePrefix != "", "tablePrefix received is empty")
+	if jd.path == "" {
+		jd.path = filepath.Join(defaultLocalPath(), jd.tablePrefix)
+	}
+	jd.logger = pkgLogger.Child("local-" + jd.tablePrefix)
+
+	var err error
+	badgerOpts := badger.
+		DefaultOptions(jd.path).
+		WithTruncate(true).
+		WithMaxTableSize(localMaxTableSize).
+		WithLogger(loggerForBadger{jd.logger})
+	jd.db, err = badger.Open(badgerOpts)
+	jd.assertError(err)
+
+	if jd.clearAll {
+		jd.assertError(jd.db.DropAll())
+	}
+	jd.jobSeq, err = jd.db.GetSequence(localJobSeqKey, 1000)
+	jd.assertError(err)
+	jd.journalSeq, err = jd.db.GetSequence(localJournalSeqKey, 10)
+	jd.assertError(err)
+
+	if jd.registerStatusHandler {
+		admin.RegisterStatusHandler(jd.tablePrefix+"-jobsdb", jd)
+	}
+	rruntime.Go(func() {
+		jd.gcLoop()
+		close(jd.gcDone)
+	})
+	jd.logger.Infof("Opened local %s DB at %s", jd.tablePrefix, jd.path)
+	return jd
+}
+
+type loggerForBadger struct {
+	logger.LoggerI
+}
+
+func (l loggerForBadger) Warningf(fmt string, args ...interface{}) {
+	l.Warnf(fmt, args...)
+}
+
+func (jd *LocalHandleT) gcLoop() {
+	for {
+		select {
+		case <-jd.close:
+			return
+		case <-time.After(localGCSleepDuration):
+		}
+	again:
+		// One call would only result in removal of at max one log file.
+		if err := jd.db.RunValueLogGC(0.5); err == nil {
+			goto again
+		}
+	}
+}
+
+// Start is a no-op, the store is open from NewLocal until TearDown
+func (*LocalHandleT) Start() {}
+
+// Stop is a no-op, see Start
+func (*LocalHandleT) Stop() {}
+
+// TearDown stops the housekeeping goroutine and closes the store
+func (jd *LocalHandleT) TearDown() {
+	close(jd.close)
+	<-jd.gcDone
+	_ = jd.jobSeq.Release()
+	_ = jd.journalSeq.Release()
+	jd.assertError(jd.db.Close())
+}
+
+func (jd *LocalHandleT) assertError(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (jd *LocalHandleT) assert(cond bool, errorString string) {
+	if !cond {
+		panic(fmt.Errorf("[[ %s ]]: %s", jd.tablePrefix, errorString))
+	}
+}
+
+func localIDBytes(id int64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(id))
+	return buf[:]
+}
+
+func localKey(prefix []byte, id int64) []byte {
+	return append(append([]byte{}, prefix...), localIDBytes(id)...)
+}
+
+func localStateKey(state string, id int64) []byte {
+	return localKey(localStateIndexPrefix(state), id)
+}
+
+func localStateIndexPrefix(state string) []byte {
+	return append(append([]byte{}, localStatePrefix...), []byte(state+"/")...)
+}
+
+func isTerminalState(state string) bool {
+	for _, js := range jobStates {
+		if js.State == state {
+			return js.isTerminal
+		}
+	}
+	return false
+}
+
+// errLocalTxnTooBig fails batches which don't fit in a single transaction, since every batch is applied atomically
+var errLocalTxnTooBig = fmt.Errorf("batch is too big for a single transaction of the local jobsdb, raise JobsDB.local.maxTableSizeInMB: %w", badger.ErrTxnTooBig)
+
+func localTxnError(err error) error {
+	if err == badger.ErrTxnTooBig {
+		return errLocalTxnTooBig
+	}
+	return err
+}
+
+func (*LocalHandleT) setEntry(txn *badger.Txn, e *badger.Entry) error {
+	return localTxnError(txn.SetEntry(e))
+}
+
+func (*LocalHandleT) deleteKey(txn *badger.Txn, key []byte) error {
+	return localTxnError(txn.Delete(key))
+}
+
+// update applies f in a single transaction, so that all of its changes are committed atomically or not at all
+func (jd *LocalHandleT) update(f func(txn *badger.Txn) error) error {
+	jd.writeLock.Lock()
+	defer jd.writeLock.Unlock()
+
+	txn := jd.db.NewTransaction(true)
+	defer txn.Discard()
+	if err := f(txn); err != nil {
+		return err
+	}
+	return localTxnError(txn.Commit())
+}
+
+/*
+Store call is used to create new Jobs
+*/
+func (jd *LocalHandleT) Store(jobList []*JobT) error {
+	queryStat := jd.getTimerStat("store_jobs", nil)
+	queryStat.Start()
+	defer queryStat.End()
+
+	return jd.update(func(txn *badger.Txn) error {
+		for _, job := range jobList {
+			if err := jd.storeJobInTxn(txn, job); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (jd *LocalHandleT) storeJobInTxn(txn *badger.Txn, job *JobT) error {
+	if !json.Valid(job.EventPayload) {
+		return fmt.Errorf("Invalid JSON")
+	}
+	next, err := jd.jobSeq.Next()
+	if err != nil {
+		return err
+	}
+	stored := *job
+	stored.JobID = int64(next) + 1
+	stored.LastJobStatus = JobStatusT{}
+	if stored.EventCount < 1 {
+		stored.EventCount = 1
+	}
+	now := time.Now()
+	if stored.CreatedAt.IsZero() {
+		stored.CreatedAt = now
+	}
+	if stored.ExpireAt.IsZero() {
+		stored.ExpireAt = now
+	}
+	value, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	if err = jd.setEntry(txn, badger.NewEntry(localKey(localJobPrefix, stored.JobID), value)); err != nil {
+		return err
+	}
+	return jd.setEntry(txn, badger.NewEntry(localStateKey(NotProcessed.State, stored.JobID), nil))
+}
+
+/*
+StoreWithRetryEach stores the jobs one by one, returning error messages for the jobs which failed to store
+*/
+func (jd *LocalHandleT) StoreWithRetryEach(jobList []*JobT) map[uuid.UUID]string {
+	var errorMessagesMap map[uuid.UUID]string
+	for _, job := range jobList {
+		err := jd.update(func(txn *badger.Txn) error {
+			return jd.storeJobInTxn(txn, job)
+		})
+		if err != nil {
+			if errorMessagesMap == nil {
+				errorMessagesMap = make(map[uuid.UUID]string)
+			}
+			errorMessagesMap[job.UUID] = err.Error()
+		}
+	}
+	return errorMessagesMap
+}
+
+// BeginGlobalTransaction returns a nil transaction, local jobsdb instances don't share transactions
+func (*LocalHandleT) BeginGlobalTransaction() *sql.Tx {
+	return nil
+}
+
+// CommitTransaction is a no-op, see BeginGlobalTransaction
+func (*LocalHandleT) CommitTransaction(*sql.Tx) {}
+
+func (*LocalHandleT) AcquireStoreLock()            {}
+func (*LocalHandleT) ReleaseStoreLock()            {}
+func (*LocalHandleT) AcquireUpdateJobStatusLocks() {}
+func (*LocalHandleT) ReleaseUpdateJobStatusLocks() {}
+
+// CheckPGHealth returns true as long as the local store is open
+func (jd *LocalHandleT) CheckPGHealth() bool {
+	return !jd.db.IsClosed()
+}
+
+// UpdateJobStatusInTxn ignores the passed transaction and updates the statuses directly
+func (jd *LocalHandleT) UpdateJobStatusInTxn(_ *sql.Tx, statusList []*JobStatusT, customValFilters []string, parameterFilters []ParameterFilterT) error {
+	return jd.UpdateJobStatus(statusList, customValFilters, parameterFilters)
+}
+
+/*
+UpdateJobStatus appends the statuses to the jobs' history and moves each job to the index of its new state
+*/
+func (jd *LocalHandleT) UpdateJobStatus(statusList []*JobStatusT, customValFilters []string, parameterFilters []ParameterFilterT) error {
+	if len(statusList) == 0 {
+		return nil
+	}
+	tags := StatTagsT{CustomValFilters: customValFilters, ParameterFilters: parameterFilters}
+	queryStat := jd.getTimerStat("update_job_status_time", &tags)
+	queryStat.Start()
+	defer queryStat.End()
+
+	return jd.update(func(txn *badger.Txn) error {
+		for _, status := range statusList {
+			if err := jd.updateJobStatusInTxn(txn, status); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (jd *LocalHandleT) updateJobStatusInTxn(txn *badger.Txn, status *JobStatusT) error {
+	checkValidJobState(jd, []string{status.JobState})
+
+	jobValue, err := jd.getJobValue(txn, status.JobID)
+	if err != nil {
+		return err
+	}
+	history, err := jd.getStatusHistory(txn, status.JobID)
+	if err != nil {
+		return err
+	}
+	previousState := NotProcessed.State
+	if len(history) > 0 {
+		previousState = history[len(history)-1].JobState
+	}
+	history = append(history, *status)
+	return jd.writeJobState(txn, status.JobID, jobValue, previousState, history)
+}
+
+// getJobValue returns the stored job, which is rewritten with a ttl once the job reaches a terminal state
+func (jd *LocalHandleT) getJobValue(txn *badger.Txn, jobID int64) ([]byte, error) {
+	jobItem, err := txn.Get(localKey(localJobPrefix, jobID))
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("job %d not found in %s jobsdb", jobID, jd.tablePrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jobItem.ValueCopy(nil)
+}
+
+// writeJobState persists the status history of a job and moves it from previousState's index
+// to the index of its latest state. Terminal jobs are written with a ttl so that they expire.
+func (jd *LocalHandleT) writeJobState(txn *badger.Txn, jobID int64, jobValue []byte, previousState string, history []JobStatusT) error {
+	if err := jd.deleteKey(txn, localStateKey(previousState, jobID)); err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		if err := jd.deleteKey(txn, localKey(localStatusPrefix, jobID)); err != nil {
+			return err
+		}
+		return jd.setEntry(txn, badger.NewEntry(localStateKey(NotProcessed.State, jobID), nil))
+	}
+	state := history[len(history)-1].JobState
+	historyValue, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	entries := []*badger.Entry{
+		badger.NewEntry(localKey(localStatusPrefix, jobID), historyValue),
+		badger.NewEntry(localStateKey(state, jobID), nil),
+	}
+	if isTerminalState(state) {
+		entries = append(entries, badger.NewEntry(localKey(localJobPrefix, jobID), jobValue))
+		for _, e := range entries {
+			e.WithTTL(localTerminalJobsRetention)
+		}
+	}
+	for _, e := range entries {
+		if err := jd.setEntry(txn, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (*LocalHandleT) getStatusHistory(txn *badger.Txn, jobID int64) ([]JobStatusT, error) {
+	var history []JobStatusT
+	item, err := txn.Get(localKey(localStatusPrefix, jobID))
+	if err == badger.ErrKeyNotFound {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &history)
+	})
+	return history, err
+}
+
+func (jd *LocalHandleT) getJob(txn *badger.Txn, jobID int64) (*JobT, error) {
+	item, err := txn.Get(localKey(localJobPrefix, jobID))
+	if err != nil {
+		return nil, err
+	}
+	var job JobT
+	if err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &job)
+	}); err != nil {
+		return nil, err
+	}
+	history, err := jd.getStatusHistory(txn, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > 0 {
+		job.LastJobStatus = history[len(history)-1]
+	}
+	return &job, nil
+}
+
+// matchesParameterFilters has the same semantics as constructParameterJSONQuery:
+// every filter has to match, but optional filters may also be missing from the job's parameters
+func matchesParameterFilters(parameters []byte, parameterFilters []ParameterFilterT) bool {
+	for _, filter := range parameterFilters {
+		value := gjson.GetBytes(parameters, filter.Name)
+		if !value.Exists() && filter.Optional {
+			continue
+		}
+		if value.String() != filter.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (jd *LocalHandleT) matchesFilters(job *JobT, params GetQueryParamsT) bool {
+	if len(params.CustomValFilters) > 0 && !params.IgnoreCustomValFiltersInQuery && !misc.ContainsString(params.CustomValFilters, job.CustomVal) {
+		return false
+	}
+	if params.UseTimeFilter && !job.CreatedAt.Before(params.Before) {
+		return false
+	}
+	if !job.LastJobStatus.RetryTime.Before(getTimeNowFunc()) {
+		return false
+	}
+	return matchesParameterFilters(job.Parameters, params.ParameterFilters)
+}
+
+/*
+getJobs scans the indexes of the given states in job id order and returns the
+jobs matching params. A negative JobCount means all the matching jobs.
+*/
+func (jd *LocalHandleT) getJobs(states []string, params GetQueryParamsT) []*JobT {
+	states = misc.Unique(states)
+	for _, state := range states {
+		if state != NotProcessed.State {
+			checkValidJobState(jd, []string{state})
+		}
+	}
+
+	outJobs := make([]*JobT, 0)
+	err := jd.db.View(func(txn *badger.Txn) error {
+		iterators := make([]*badger.Iterator, 0, len(states))
+		prefixes := make([][]byte, 0, len(states))
+		for _, state := range states {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = localStateIndexPrefix(state)
+			it := txn.NewIterator(opts)
+			defer it.Close()
+			it.Rewind()
+			iterators = append(iterators, it)
+			prefixes = append(prefixes, opts.Prefix)
+		}
+
+		var eventCount int
+		for params.JobCount < 0 || len(outJobs) < params.JobCount {
+			// pick the iterator pointing at the smallest job id
+			next := -1
+			for i, it := range iterators {
+				if !it.Valid() {
+					continue
+				}
+				if next == -1 || bytes.Compare(it.Item().Key()[len(prefixes[i]):], iterators[next].Item().Key()[len(prefixes[next]):]) < 0 {
+					next = i
+				}
+			}
+			if next == -1 {
+				break
+			}
+			jobID := int64(binary.BigEndian.Uint64(iterators[next].Item().Key()[len(prefixes[next]):]))
+			iterators[next].Next()
+
+			job, err := jd.getJob(txn, jobID)
+			if err == badger.ErrKeyNotFound {
+				// expired together with its index entry
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if !jd.matchesFilters(job, params) {
+				continue
+			}
+			outJobs = append(outJobs, job)
+			eventCount += job.EventCount
+			// received event count could exceed the requested event count, by the spillover of the last selected job
+			if params.EventCount > 0 && eventCount >= params.EventCount {
+				break
+			}
+		}
+		return nil
+	})
+	jd.assertError(err)
+	return outJobs
+}
+
+/*
+GetUnprocessed returns the unprocessed events. Unprocessed events are
+those whose state hasn't been marked in the DB.
+*/
+func (jd *LocalHandleT) GetUnprocessed(params GetQueryParamsT) []*JobT {
+	if params.JobCount == 0 {
+		return []*JobT{}
+	}
+	tags := StatTagsT{CustomValFilters: params.CustomValFilters, ParameterFilters: params.ParameterFilters}
+	queryStat := jd.getTimerStat("unprocessed_jobs_time", &tags)
+	queryStat.Start()
+	defer queryStat.End()
+
+	return jd.getJobs([]string{NotProcessed.State}, params)
+}
+
+/*
+GetProcessed returns events of a given state, see HandleT.GetProcessed
+*/
+func (jd *LocalHandleT) GetProcessed(params GetQueryParamsT) []*JobT {
+	if params.JobCount == 0 {
+		return []*JobT{}
+	}
+	tags := StatTagsT{CustomValFilters: params.CustomValFilters, StateFilters: params.StateFilters, ParameterFilters: params.ParameterFilters}
+	queryStat := jd.getTimerStat("processed_jobs_time", &tags)
+	queryStat.Start()
+	defer queryStat.End()
+
+	states := params.StateFilters
+	if len(states) == 0 {
+		states = append(getValidNonTerminalStates(), getValidTerminalStates()...)
+	}
+	return jd.getJobs(states, params)
+}
+
+// GetToRetry returns events which need to be retried.
+func (jd *LocalHandleT) GetToRetry(params GetQueryParamsT) []*JobT {
+	params.StateFilters = []string{Failed.State}
+	return jd.GetProcessed(params)
+}
+
+// GetWaiting returns events which are under processing
+func (jd *LocalHandleT) GetWaiting(params GetQueryParamsT) []*JobT {
+	params.StateFilters = []string{Waiting.State}
+	return jd.GetProcessed(params)
+}
+
+// GetExecuting returns events which are in executing state
+func (jd *LocalHandleT) GetExecuting(params GetQueryParamsT) []*JobT {
+	params.StateFilters = []string{Executing.State}
+	return jd.GetProcessed(params)
+}
+
+// GetImportingList returns events which are being imported
+func (jd *LocalHandleT) GetImportingList(params GetQueryParamsT) []*JobT {
+	params.StateFilters = []string{Importing.State}
+	return jd.GetProcessed(params)
+}
+
+/*
+DeleteExecuting deletes the latest (executing) status of jobs, so that they go back to their previous state.
+This is only done during recovery, which happens during the server start.
+*/
+func (jd *LocalHandleT) DeleteExecuting(params GetQueryParamsT) {
+	if params.JobCount == 0 {
+		return
+	}
+	params.StateFilters = []string{Executing.State}
+	jobs := jd.getJobs(params.StateFilters, params)
+	err := jd.update(func(txn *badger.Txn) error {
+		for _, job := range jobs {
+			history, err := jd.getStatusHistory(txn, job.JobID)
+			if err != nil {
+				return err
+			}
+			if len(history) == 0 || history[len(history)-1].JobState != Executing.State {
+				continue
+			}
+			jobValue, err := jd.getJobValue(txn, job.JobID)
+			if err != nil {
+				return err
+			}
+			history = history[:len(history)-1]
+			if err = jd.writeJobState(txn, job.JobID, jobValue, Executing.State, history); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	jd.assertError(err)
+}
+
+/*
+GetAllJobs returns the jobs to retry, then the waiting and the unprocessed ones, up to the total count of jobs of the workspaces,
+like MultiTenantLegacy does for postgres
+*/
+func (jd *LocalHandleT) GetAllJobs(workspaceCount map[string]int, params GetQueryParamsT, _ int) []*JobT {
+	toQuery := 0
+	for workspace := range workspaceCount {
+		toQuery += workspaceCount[workspace]
+	}
+	retryList := jd.GetToRetry(GetQueryParamsT{CustomValFilters: params.CustomValFilters, JobCount: toQuery})
+	toQuery -= len(retryList)
+	waitList := jd.GetWaiting(GetQueryParamsT{CustomValFilters: params.CustomValFilters, JobCount: toQuery})
+	toQuery -= len(waitList)
+	unprocessedList := jd.GetUnprocessed(GetQueryParamsT{CustomValFilters: params.CustomValFilters, JobCount: toQuery})
+
+	var list []*JobT
+	list = append(list, retryList...)
+	list = append(list, waitList...)
+	list = append(list, unprocessedList...)
+	return list
+}
+
+/*
+GetPileUpCounts adds the number of jobs which are still to be processed, by workspace and customVal
+*/
+func (jd *LocalHandleT) GetPileUpCounts(statMap map[string]map[string]int) {
+	var states []string
+	for _, js := range jobStates {
+		if !js.isTerminal && js.State != Executing.State {
+			states = append(states, js.State)
+		}
+	}
+	for _, job := range jd.getJobs(states, GetQueryParamsT{JobCount: -1}) {
+		if _, ok := statMap[job.WorkspaceId]; !ok {
+			statMap[job.WorkspaceId] = make(map[string]int)
+		}
+		statMap[job.WorkspaceId][job.CustomVal]++
+	}
+}
+
+/*
+HavePendingJobs returns true if there are jobs which are unprocessed or not in a terminal state, see ReadonlyHandleT.HavePendingJobs.
+Unlike GetToRetry, failed jobs are pending whatever their retry time.
+*/
+func (jd *LocalHandleT) HavePendingJobs(_ context.Context, customValFilters []string, _ int, parameterFilters []ParameterFilterT) (bool, error) {
+	states := []string{NotProcessed.State, Failed.State, Waiting.State, Executing.State, Importing.State}
+	var pending bool
+	err := jd.db.View(func(txn *badger.Txn) error {
+		for _, state := range states {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = localStateIndexPrefix(state)
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid() && !pending; it.Next() {
+				job, err := jd.getJob(txn, int64(binary.BigEndian.Uint64(it.Item().Key()[len(opts.Prefix):])))
+				if err == badger.ErrKeyNotFound {
+					continue
+				}
+				if err != nil {
+					it.Close()
+					return err
+				}
+				if len(customValFilters) > 0 && !misc.ContainsString(customValFilters, job.CustomVal) {
+					continue
+				}
+				pending = matchesParameterFilters(job.Parameters, parameterFilters)
+			}
+			it.Close()
+			if pending {
+				return nil
+			}
+		}
+		return nil
+	})
+	return pending, err
+}
+
+// errLocalReadonlyUnsupported is returned by the debugging queries of ReadonlyJobsDB, which are written in sql for the postgres datasets
+var errLocalReadonlyUnsupported = errors.New("not supported by the local jobsdb")
+
+func (*LocalHandleT) GetJobSummaryCount(string, string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetLatestFailedJobs(string, string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetJobIDsForUser([]string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetFailedStatusErrorCodeCountsByDestination([]string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetDSListString() (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetJobIDStatus(string, string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+func (*LocalHandleT) GetJobByID(string, string) (string, error) {
+	return "", errLocalReadonlyUnsupported
+}
+
+// GetIdentifier returns the identifier of the jobsdb. Here it is tablePrefix.
+func (jd *LocalHandleT) GetIdentifier() string {
+	return jd.tablePrefix
+}
+
+func (jd *LocalHandleT) Status() interface{} {
+	jobCounts := make(map[string]int)
+	err := jd.db.View(func(txn *badger.Txn) error {
+		for _, js := range jobStates {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			opts.Prefix = localStateIndexPrefix(js.State)
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				jobCounts[js.State]++
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	lsmSize, vlogSize := jd.db.Size()
+	return map[string]interface{}{
+		"driver":     "local",
+		"path":       jd.path,
+		"job-counts": jobCounts,
+		"lsm-size":   lsmSize,
+		"vlog-size":  vlogSize,
+	}
+}
+
+// JournalMarkStart adds a not yet done journal entry for opType
+func (jd *LocalHandleT) JournalMarkStart(opType string, opPayload json.RawMessage) int64 {
+	next, err := jd.journalSeq.Next()
+	jd.assertError(err)
+	entry := JournalEntryT{OpID: int64(next) + 1, OpType: opType, OpPayload: opPayload}
+	value, err := json.Marshal(&entry)
+	jd.assertError(err)
+	err = jd.update(func(txn *badger.Txn) error {
+		return jd.setEntry(txn, badger.NewEntry(localKey(localJournalPrefix, entry.OpID), value))
+	})
+	jd.assertError(err)
+	return entry.OpID
+}
+
+func (jd *LocalHandleT) JournalDeleteEntry(opID int64) {
+	err := jd.update(func(txn *badger.Txn) error {
+		return jd.deleteKey(txn, localKey(localJournalPrefix, opID))
+	})
+	jd.assertError(err)
+}
+
+func (jd *LocalHandleT) GetJournalEntries(opType string) (entries []JournalEntryT) {
+	err := jd.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = localJournalPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			var entry JournalEntryT
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &entry)
+			}); err != nil {
+				return err
+			}
+			if entry.OpType == opType && !entry.OpDone {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	jd.assertError(err)
+	return
+}
+
+func (jd *LocalHandleT) getTimerStat(stat string, tags *StatTagsT) stats.RudderStats {
+	return newTimerStat(jd.tablePrefix, stat, tags)
+}
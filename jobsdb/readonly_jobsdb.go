@@ -232,7 +232,7 @@ func (jd *ReadonlyHandleT) getUnprocessedJobsDSCount(ctx context.Context, ds dat
 
 	var selectColumn string
 	if jd.tablePrefix == "gw" {
-		selectColumn = "event_payload->'batch' as batch"
+		selectColumn = "event_payload->'batch' as batch, event_count"
 	} else {
 		selectColumn = "COUNT(*)"
 	}
@@ -249,7 +249,8 @@ func (jd *ReadonlyHandleT) getUnprocessedJobsDSCount(ctx context.Context, ds dat
 	}
 
 	if jd.tablePrefix == "gw" {
-		sqlStatement = fmt.Sprintf("select sum(jsonb_array_length(batch)) from (%s) t", sqlStatement)
+		// batch is null for compressed payloads, whose event_count is the number of events in the batch
+		sqlStatement = fmt.Sprintf("select sum(coalesce(jsonb_array_length(batch), event_count)) from (%s) t", sqlStatement)
 	}
 
 	jd.logger.Debug(sqlStatement)
@@ -377,7 +378,7 @@ func (jd *ReadonlyHandleT) getProcessedJobsDSCount(ctx context.Context, ds dataS
 
 	var selectColumn string
 	if jd.tablePrefix == "gw" {
-		selectColumn = fmt.Sprintf("%[1]s.event_payload->'batch' as batch", ds.JobTable)
+		selectColumn = fmt.Sprintf("%[1]s.event_payload->'batch' as batch, %[1]s.event_count", ds.JobTable)
 	} else {
 		selectColumn = fmt.Sprintf("COUNT(%[1]s.job_id)", ds.JobTable)
 	}
@@ -392,7 +393,8 @@ func (jd *ReadonlyHandleT) getProcessedJobsDSCount(ctx context.Context, ds dataS
 		ds.JobTable, ds.JobStatusTable, stateQuery, customValQuery, sourceQuery, selectColumn)
 
 	if jd.tablePrefix == "gw" {
-		sqlStatement = fmt.Sprintf("select sum(jsonb_array_length(batch)) from (%s) t", sqlStatement)
+		// batch is null for compressed payloads, whose event_count is the number of events in the batch
+		sqlStatement = fmt.Sprintf("select sum(coalesce(jsonb_array_length(batch), event_count)) from (%s) t", sqlStatement)
 	}
 
 	jd.logger.Debug(sqlStatement)
@@ -639,6 +641,9 @@ func (jd *ReadonlyHandleT) GetJobByID(job_id string, prefix string) (string, err
 				return "", err1
 			}
 		}
+		if err = decompressJobPayload(&event); err != nil {
+			return "", err
+		}
 		response, err = json.MarshalIndent(event, "", " ")
 		if err != nil {
 			return "", err
@@ -0,0 +1,109 @@
+package jobsdb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/stats"
+)
+
+/*
+Workspace retention
+
+Every job is stored with the retention of its workspace in its retention column, which is NULL for the jobs of
+workspaces without retention, which never expire. The expiry of a job, created_at + retention, is computed by postgres,
+created_at being the start of the transaction storing the job. The legacy expire_at column is not used for retention.
+Jobs copied forward by the migration keep their created_at and get the current retention of their workspace.
+
+migrateDSLoop marks the jobs which are still not in a terminal state after their expiry as expired,
+so they are not copied forward by the migration, but are dropped along with their dataset (and backed up if enabled).
+Scanning a dataset for expired jobs is not indexed, so only the datasets probed for migration are scanned, right before
+their migration check, which scans them anyway. The latest datasets, which are being written, are never scanned:
+their jobs expire once newer datasets are added.
+*/
+
+// getWorkspaceRetention returns the retention of the jobs of a workspace, zero if they are retained until they are processed
+func (jd *HandleT) getWorkspaceRetention(workspaceID string) time.Duration {
+	if jd.workspaceRetention != nil {
+		return jd.workspaceRetention(workspaceID)
+	}
+	if workspaceID == "" {
+		return 0
+	}
+	for _, key := range []string{
+		"JobsDB." + jd.tablePrefix + ".workspaceRetention." + workspaceID,
+		"JobsDB.workspaceRetention." + workspaceID,
+	} {
+		if config.IsSet(key) {
+			return config.GetDuration(key, 0, time.Hour)
+		}
+	}
+	return 0
+}
+
+// retentionInterval returns the value of the retention column for the jobs of a workspace, nil if they never expire.
+// Intervals are cached in retentions if it is not nil
+func (jd *HandleT) retentionInterval(retentions map[string]interface{}, workspaceID string) interface{} {
+	if interval, ok := retentions[workspaceID]; ok {
+		return interval
+	}
+	var interval interface{}
+	if retention := jd.getWorkspaceRetention(workspaceID); retention > 0 {
+		interval = fmt.Sprintf("%d milliseconds", retention.Milliseconds())
+	}
+	if retentions != nil {
+		retentions[workspaceID] = interval
+	}
+	return interval
+}
+
+// expireJobs marks the jobs past their retention as expired in a dataset
+func (jd *HandleT) expireJobs(ds dataSetT) {
+	queryStat := stats.NewTaggedStat("jobsdb_expire_jobs_time", stats.TimerType, stats.Tags{"customVal": jd.tablePrefix})
+	queryStat.Start()
+	defer queryStat.End()
+
+	if expired := jd.expireJobsDS(ds); expired > 0 {
+		jd.logger.Infof("[[ %s : migrateDSLoop ]]: Expired %d jobs past their workspace retention in %v", jd.tablePrefix, expired, ds)
+		stats.NewTaggedStat("jobsdb_expired_jobs", stats.CountType, stats.Tags{"customVal": jd.tablePrefix}).Count(int(expired))
+	}
+}
+
+func (jd *HandleT) expireJobsDS(ds dataSetT) int64 {
+	//The order of lock is very important. The migrateDSLoop
+	//takes lock in this order so reversing this will cause
+	//deadlocks
+	jd.dsMigrationLock.RLock()
+	jd.dsListLock.RLock()
+	defer jd.dsMigrationLock.RUnlock()
+	defer jd.dsListLock.RUnlock()
+
+	var hasExpiredJobs bool
+	sqlStatement := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s" WHERE retention IS NOT NULL AND created_at + retention < NOW())`, ds.JobTable)
+	jd.assertError(jd.dbHandle.QueryRow(sqlStatement).Scan(&hasExpiredJobs))
+	if !hasExpiredJobs {
+		return 0
+	}
+
+	sqlStatement = fmt.Sprintf(`INSERT INTO "%[2]s" (job_id, job_state, attempt, exec_time, retry_time, error_code, error_response, parameters)
+		SELECT jobs.job_id, '%[3]s', COALESCE(job_latest_state.attempt, 0), NOW(), NOW(), '', '{"reason": "job expired its workspace retention"}', '{}'
+		FROM "%[1]s" AS jobs
+		LEFT JOIN (SELECT DISTINCT ON (job_id) job_id, job_state, attempt FROM "%[2]s" ORDER BY job_id, id DESC) AS job_latest_state
+		ON jobs.job_id = job_latest_state.job_id
+		WHERE jobs.retention IS NOT NULL AND jobs.created_at + jobs.retention < NOW()
+		AND (job_latest_state.job_state IS NULL OR job_latest_state.job_state NOT IN ('%[4]s'))`,
+		ds.JobTable, ds.JobStatusTable, Expired.State, strings.Join(getValidTerminalStates(), "', '"))
+
+	res, err := jd.dbHandle.Exec(sqlStatement)
+	jd.assertError(err)
+	expired, err := res.RowsAffected()
+	jd.assertError(err)
+
+	if expired > 0 {
+		//Empty stateFilters and customValFilters clear the cache of the whole dataset
+		jd.markClearEmptyResult(ds, allWorkspaces, []string{}, []string{}, nil, hasJobs, nil)
+	}
+	return expired
+}
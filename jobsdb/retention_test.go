@@ -0,0 +1,68 @@
+package jobsdb
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_getWorkspaceRetention(t *testing.T) {
+	config.SetString("JobsDB.rt.workspaceRetention.workspace-1", "24h")
+	config.SetString("JobsDB.workspaceRetention.workspace-1", "1h")
+	config.SetString("JobsDB.workspaceRetention.workspace-2", "72")
+
+	jd := &HandleT{tablePrefix: "rt"}
+	require.Equal(t, 24*time.Hour, jd.getWorkspaceRetention("workspace-1"), "table prefix specific retention takes precedence")
+	require.Equal(t, 72*time.Hour, jd.getWorkspaceRetention("workspace-2"), "retention is configured in hours by default")
+	require.Zero(t, jd.getWorkspaceRetention("workspace-3"), "jobs are retained until processed by default")
+	require.Zero(t, jd.getWorkspaceRetention(""))
+
+	jd = &HandleT{tablePrefix: "rt"}
+	WithWorkspaceRetention(func(workspaceID string) time.Duration { return time.Minute })(jd)
+	require.Equal(t, time.Minute, jd.getWorkspaceRetention("workspace-1"))
+}
+
+func TestExpireJobs(t *testing.T) {
+	initJobsDB()
+	stats.Setup()
+
+	jobDB := &HandleT{}
+	WithWorkspaceRetention(func(workspaceID string) time.Duration {
+		if workspaceID == "retained" {
+			return time.Hour
+		}
+		return 0
+	})(jobDB)
+	jobDB.Setup(ReadWrite, true, "retention", time.Minute*5, "", true, QueryFiltersT{})
+	defer jobDB.TearDown()
+
+	newJob := func(workspaceID string) *JobT {
+		return &JobT{
+			WorkspaceId:  workspaceID,
+			UUID:         uuid.Must(uuid.NewV4()),
+			UserID:       "user",
+			CustomVal:    "MOCKDS",
+			Parameters:   []byte(`{}`),
+			EventPayload: []byte(`{"type":"track"}`),
+			// set like the processor does, it has no effect on the expiry
+			ExpireAt: time.Now(),
+		}
+	}
+	require.NoError(t, jobDB.Store([]*JobT{newJob("retained"), newJob("unretained")}))
+	require.NoError(t, jobDB.Store([]*JobT{newJob("retained")}))
+
+	dsList := jobDB.getDSList(true)
+	jobDB.expireJobs(dsList[0])
+	require.Len(t, jobDB.GetUnprocessed(GetQueryParamsT{CustomValFilters: []string{"MOCKDS"}, JobCount: 10}), 3, "jobs are not expired before their retention")
+
+	_, err := jobDB.dbHandle.Exec(`UPDATE "` + dsList[0].JobTable + `" SET created_at = NOW() - INTERVAL '2 hours'`)
+	require.NoError(t, err)
+	jobDB.expireJobs(dsList[0])
+	unprocessed := jobDB.GetUnprocessed(GetQueryParamsT{CustomValFilters: []string{"MOCKDS"}, JobCount: 10})
+	require.Len(t, unprocessed, 1, "only jobs with retention expire")
+	require.Equal(t, "unretained", unprocessed[0].WorkspaceId)
+}
@@ -0,0 +1,149 @@
+package jobsdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+/*
+SelectJobs calls fn with batches of the jobs of this jobsdb matching opts, ordered by job id.
+opts.States are matched against the last status of the jobs, jobs without any status are in NotProcessed state.
+Jobs stored after SelectJobs is called are not selected, so fn can store jobs into this jobsdb.
+Event payloads of the selected jobs are decompressed. It returns the number of selected jobs.
+*/
+func (jd *HandleT) SelectJobs(ctx context.Context, opts RestoreOptsT, fn func(jobs []*JobT) error) (int, error) {
+	if err := opts.validate(); err != nil {
+		return 0, err
+	}
+
+	maxJobID, err := jd.selectMaxJobID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var selected int
+	var afterJobID int64
+	for afterJobID < maxJobID {
+		if err := ctx.Err(); err != nil {
+			return selected, err
+		}
+		jobs, err := jd.selectJobsPage(ctx, opts, afterJobID, maxJobID, restoreBatchSize)
+		if err != nil {
+			return selected, err
+		}
+		if len(jobs) == 0 {
+			break
+		}
+		if err := fn(jobs); err != nil {
+			return selected, err
+		}
+		selected += len(jobs)
+		afterJobID = jobs[len(jobs)-1].JobID
+	}
+	return selected, nil
+}
+
+//selectableDSList returns all the datasets of this jobsdb, not only the ones the owner type of this jobsdb keeps track of
+func (jd *HandleT) selectableDSList() []dataSetT {
+	return getDSList(jd, jd.dbHandle, jd.tablePrefix)
+}
+
+//selectMaxJobID returns the highest job id of this jobsdb
+func (jd *HandleT) selectMaxJobID(ctx context.Context) (int64, error) {
+	jd.dsMigrationLock.RLock()
+	jd.dsListLock.RLock()
+	defer jd.dsMigrationLock.RUnlock()
+	defer jd.dsListLock.RUnlock()
+
+	dsList := jd.selectableDSList()
+	for i := len(dsList) - 1; i >= 0; i-- {
+		var maxJobID sql.NullInt64
+		sqlStatement := fmt.Sprintf(`SELECT MAX(job_id) FROM "%s"`, dsList[i].JobTable)
+		if err := jd.dbHandle.QueryRowContext(ctx, sqlStatement).Scan(&maxJobID); err != nil {
+			return 0, fmt.Errorf("selecting max job id of %s: %w", dsList[i].JobTable, err)
+		}
+		if maxJobID.Valid {
+			return maxJobID.Int64, nil
+		}
+	}
+	return 0, nil
+}
+
+//selectJobsPage returns up to limit jobs matching opts with ids in (afterJobID, maxJobID], ordered by job id
+func (jd *HandleT) selectJobsPage(ctx context.Context, opts RestoreOptsT, afterJobID, maxJobID int64, limit int) ([]*JobT, error) {
+	//The order of lock is very important. The migrateDSLoop
+	//takes lock in this order so reversing this will cause
+	//deadlocks
+	jd.dsMigrationLock.RLock()
+	jd.dsListLock.RLock()
+	defer jd.dsMigrationLock.RUnlock()
+	defer jd.dsListLock.RUnlock()
+
+	var jobs []*JobT
+	for _, ds := range jd.selectableDSList() {
+		dsJobs, err := jd.selectJobsDS(ctx, ds, opts, afterJobID, maxJobID, limit-len(jobs))
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, dsJobs...)
+		if len(jobs) >= limit {
+			break
+		}
+	}
+	return jobs, nil
+}
+
+func (jd *HandleT) selectJobsDS(ctx context.Context, ds dataSetT, opts RestoreOptsT, afterJobID, maxJobID int64, limit int) ([]*JobT, error) {
+	// jobs being migrated are selected from the dataset they are migrated to
+	sqlStatement := fmt.Sprintf(`SELECT jobs.job_id, jobs.uuid, jobs.user_id, jobs.parameters, jobs.custom_val, jobs.event_payload, jobs.event_count, jobs.created_at, jobs.expire_at, jobs.workspace_id, jobs.job_state FROM (`+
+		`SELECT j.*, COALESCE(s.job_state, '%[3]s') AS job_state FROM "%[1]s" AS j `+
+		`LEFT JOIN LATERAL (SELECT job_state FROM "%[2]s" WHERE job_id = j.job_id ORDER BY id DESC LIMIT 1) AS s ON true `+
+		`WHERE j.job_id > $1 AND j.job_id <= $2 AND j.created_at >= $3 AND j.created_at < $4`,
+		ds.JobTable, ds.JobStatusTable, NotProcessed.State)
+	args := []interface{}{afterJobID, maxJobID, opts.From, opts.To}
+
+	if len(opts.WorkspaceIDs) > 0 {
+		args = append(args, pq.Array(opts.WorkspaceIDs))
+		sqlStatement += fmt.Sprintf(" AND j.workspace_id = ANY($%d)", len(args))
+	}
+	if len(opts.SourceIDs) > 0 {
+		args = append(args, pq.Array(opts.SourceIDs))
+		sqlStatement += fmt.Sprintf(" AND j.parameters->>'source_id' = ANY($%d)", len(args))
+	}
+	if len(opts.DestinationIDs) > 0 {
+		args = append(args, pq.Array(opts.DestinationIDs))
+		sqlStatement += fmt.Sprintf(" AND j.parameters->>'destination_id' = ANY($%d)", len(args))
+	}
+	sqlStatement += fmt.Sprintf(") AS jobs WHERE jobs.job_state <> '%s'", Migrated.State)
+	if len(opts.States) > 0 {
+		args = append(args, pq.Array(opts.States))
+		sqlStatement += fmt.Sprintf(" AND jobs.job_state = ANY($%d)", len(args))
+	}
+	args = append(args, limit)
+	sqlStatement += fmt.Sprintf(" ORDER BY jobs.job_id LIMIT $%d", len(args))
+
+	rows, err := jd.dbHandle.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("selecting jobs from %s: %w", ds.JobTable, err)
+	}
+	defer rows.Close()
+
+	var jobs []*JobT
+	for rows.Next() {
+		var job JobT
+		err := rows.Scan(&job.JobID, &job.UUID, &job.UserID, &job.Parameters, &job.CustomVal,
+			&job.EventPayload, &job.EventCount, &job.CreatedAt, &job.ExpireAt, &job.WorkspaceId, &job.LastJobStatus.JobState)
+		if err != nil {
+			return nil, fmt.Errorf("scanning jobs of %s: %w", ds.JobTable, err)
+		}
+		if err := decompressJobPayload(&job); err != nil {
+			return nil, err
+		}
+		job.LastJobStatus.JobID = job.JobID
+		jobs = append(jobs, &job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,187 @@
+package jobsdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+)
+
+// SubscriberI is implemented by the jobsdbs consumers can subscribe to, instead of polling them for unprocessed jobs
+type SubscriberI interface {
+	// StoreNotificationsEnabled returns whether the jobs stored are announced to the subscribers,
+	// consumers keep polling otherwise, as subscribers would only look for jobs every subscriptionPollInterval
+	StoreNotificationsEnabled() bool
+	Subscribe(ctx context.Context, params GetQueryParamsT) <-chan []*JobT
+}
+
+// StoreNotificationsEnabled returns whether JobsDB.<tablePrefix>.enableStoreNotifications is set, false by default.
+// It must be set for both the writers and the readers of the jobsdb
+func (jd *HandleT) StoreNotificationsEnabled() bool {
+	return jd.enableStoreNotifications
+}
+
+/*
+Subscribe returns a channel on which batches of unprocessed jobs matching params
+are delivered, instead of the caller polling GetUnprocessed in a loop.
+
+Writers notify readers about new jobs through postgres LISTEN/NOTIFY as part of
+the transaction storing them, so a subscriber wakes up as soon as the jobs are
+committed. Only storing new jobs wakes up subscribers, they also look for jobs
+every subscriptionPollInterval in case a notification was missed.
+
+A batch is only delivered after none of the jobs of the previous batch are
+unprocessed anymore, i.e. the consumer must update the status of every job
+it receives before it gets the next batch, which is delivered on the next
+notification or poll. The channel is closed once ctx is done or the jobsdb is stopped,
+consumers should poll the jobsdb from then on. Subscribe must be called after Start,
+the channel is closed right away otherwise.
+*/
+func (jd *HandleT) Subscribe(ctx context.Context, params GetQueryParamsT) <-chan []*JobT {
+	jobsCh := make(chan []*JobT)
+	stopCtx := jd.backgroundCtx
+	if stopCtx == nil {
+		jd.logger.Errorf("[[ %s ]]: Subscribed before the jobsdb was started, subscriber must poll", jd.tablePrefix)
+		close(jobsCh)
+		return jobsCh
+	}
+	wakeUp := jd.addSubscriber()
+
+	jd.subscriptionGroup.Add(1)
+	go misc.WithBugsnag(func() error {
+		defer jd.subscriptionGroup.Done()
+		defer close(jobsCh)
+		defer jd.removeSubscriber(wakeUp)
+
+		pending := make(map[int64]struct{})
+		for {
+			select {
+			case <-stopCtx.Done():
+				return nil
+			default:
+			}
+			jobs := jd.GetUnprocessed(params)
+
+			inFlight := false
+			for _, job := range jobs {
+				if _, ok := pending[job.JobID]; ok {
+					inFlight = true
+					break
+				}
+			}
+
+			if len(jobs) > 0 && !inFlight {
+				select {
+				case jobsCh <- jobs:
+				case <-ctx.Done():
+					return nil
+				case <-stopCtx.Done():
+					return nil
+				}
+				pending = make(map[int64]struct{}, len(jobs))
+				for _, job := range jobs {
+					pending[job.JobID] = struct{}{}
+				}
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-stopCtx.Done():
+				return nil
+			case <-wakeUp:
+			case <-time.After(subscriptionPollInterval):
+			}
+		}
+	})()
+
+	return jobsCh
+}
+
+// storeNotificationChannel is the postgres channel jobs stored through this jobsdb are announced on
+func (jd *HandleT) storeNotificationChannel() string {
+	return fmt.Sprintf("%s_jobs_stored", jd.tablePrefix)
+}
+
+// notifyStoreInTxn announces the jobs stored in txn to subscribers of other jobsdb instances.
+// Postgres delivers the notification only once txn is committed.
+func (jd *HandleT) notifyStoreInTxn(txHandler transactionHandler) error {
+	if !jd.enableStoreNotifications {
+		return nil
+	}
+	_, err := txHandler.Exec(`SELECT pg_notify($1, '')`, jd.storeNotificationChannel())
+	return err
+}
+
+func (jd *HandleT) addSubscriber() chan struct{} {
+	jd.subscriptionLock.Lock()
+	defer jd.subscriptionLock.Unlock()
+
+	if jd.subscribers == nil {
+		jd.subscribers = make(map[chan struct{}]struct{})
+	}
+	if jd.storeListener == nil {
+		jd.startStoreListener()
+	}
+	wakeUp := make(chan struct{}, 1)
+	jd.subscribers[wakeUp] = struct{}{}
+	return wakeUp
+}
+
+func (jd *HandleT) removeSubscriber(wakeUp chan struct{}) {
+	jd.subscriptionLock.Lock()
+	defer jd.subscriptionLock.Unlock()
+
+	delete(jd.subscribers, wakeUp)
+}
+
+// wakeUpSubscribers makes every subscriber look for unprocessed jobs again.
+// Subscribers which already have a pending wake up are skipped.
+func (jd *HandleT) wakeUpSubscribers() {
+	jd.subscriptionLock.Lock()
+	defer jd.subscriptionLock.Unlock()
+
+	for wakeUp := range jd.subscribers {
+		select {
+		case wakeUp <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// startStoreListener opens the connection which listens for store notifications.
+// Caller must hold subscriptionLock.
+func (jd *HandleT) startStoreListener() {
+	listener := pq.NewListener(GetConnectionString(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			jd.logger.Errorf("[[ %s ]]: Store notification listener event %d: %v", jd.tablePrefix, event, err)
+		}
+	})
+	jd.storeListener = listener
+
+	jd.backgroundGroup.Go(misc.WithBugsnag(func() error {
+		if err := listener.Listen(jd.storeNotificationChannel()); err != nil {
+			jd.logger.Errorf("[[ %s ]]: Failed to listen for store notifications, subscribers will poll every %v: %v", jd.tablePrefix, subscriptionPollInterval, err)
+			return nil
+		}
+		// the channel is closed by listener.Close() during Stop.
+		// A nil notification is sent after re-connecting, when notifications might have been missed
+		for range listener.Notify {
+			jd.wakeUpSubscribers()
+		}
+		return nil
+	}))
+}
+
+func (jd *HandleT) stopStoreListener() {
+	jd.subscriptionLock.Lock()
+	defer jd.subscriptionLock.Unlock()
+
+	if jd.storeListener != nil {
+		_ = jd.storeListener.Close()
+		jd.storeListener = nil
+	}
+}
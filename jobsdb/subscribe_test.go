@@ -0,0 +1,122 @@
+package jobsdb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribe(t *testing.T) {
+	// subscribers only look for jobs when they are notified, so the test doesn't pass by polling
+	t.Setenv(config.TransformKey("JobsDB.subscriptionPollInterval"), "1h")
+	t.Setenv(config.TransformKey("JobsDB.enableStoreNotifications"), "true")
+	initJobsDB()
+	stats.Setup()
+
+	customVal := "MOCKDS"
+
+	readDB := jobsdb.NewForReadWrite("subscribe")
+	readDB.Start()
+	defer readDB.TearDown()
+
+	writeDB := jobsdb.NewForWrite("subscribe")
+	writeDB.Start()
+	defer writeDB.TearDown()
+
+	require.True(t, readDB.StoreNotificationsEnabled())
+	t.Run("subscription is closed if the jobsdb is not started", func(t *testing.T) {
+		unstartedDB := jobsdb.NewForRead("subscribe")
+		defer unstartedDB.Close()
+		_, ok := <-unstartedDB.Subscribe(context.Background(), jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+		require.False(t, ok)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription := readDB.Subscribe(ctx, jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal}, JobCount: 10})
+
+	receive := func(t *testing.T) []*jobsdb.JobT {
+		t.Helper()
+		select {
+		case jobs, ok := <-subscription:
+			require.True(t, ok, "subscription closed unexpectedly")
+			return jobs
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "timeout waiting for jobs")
+		}
+		return nil
+	}
+
+	markSucceeded := func(t *testing.T, jobs []*jobsdb.JobT) {
+		t.Helper()
+		statuses := make([]*jobsdb.JobStatusT, len(jobs))
+		for i, job := range jobs {
+			statuses[i] = &jobsdb.JobStatusT{
+				JobID:         job.JobID,
+				JobState:      jobsdb.Succeeded.State,
+				AttemptNum:    1,
+				ExecTime:      time.Now(),
+				RetryTime:     time.Now(),
+				ErrorResponse: []byte(`{}`),
+				Parameters:    []byte(`{}`),
+				WorkspaceId:   job.WorkspaceId,
+			}
+		}
+		require.NoError(t, readDB.UpdateJobStatus(statuses, []string{customVal}, nil))
+	}
+
+	t.Run("no jobs are delivered before they are stored", func(t *testing.T) {
+		select {
+		case <-subscription:
+			require.FailNow(t, "received a batch without jobs stored")
+		case <-time.After(time.Second):
+		}
+	})
+
+	t.Run("jobs stored by another instance are delivered once notified", func(t *testing.T) {
+		require.NoError(t, writeDB.Store(genJobs(customVal, 3, 1)))
+		jobs := receive(t)
+		require.Len(t, jobs, 3)
+		requireSequential(t, jobs)
+		markSucceeded(t, jobs)
+	})
+
+	t.Run("next batch is delivered only after the previous one is processed", func(t *testing.T) {
+		require.NoError(t, writeDB.Store(genJobs(customVal, 2, 1)))
+		jobs := receive(t)
+		require.Len(t, jobs, 2)
+
+		require.NoError(t, writeDB.Store(genJobs(customVal, 2, 1)))
+		select {
+		case <-subscription:
+			require.FailNow(t, "received a batch while the previous one was still unprocessed")
+		case <-time.After(2 * time.Second):
+		}
+
+		markSucceeded(t, jobs)
+		select {
+		case <-subscription:
+			require.FailNow(t, "updating job statuses woke up the subscriber")
+		case <-time.After(2 * time.Second):
+		}
+
+		require.NoError(t, writeDB.Store(genJobs(customVal, 1, 1)))
+		jobs = receive(t)
+		require.Len(t, jobs, 3, "the jobs left unprocessed are delivered with the ones stored next")
+		markSucceeded(t, jobs)
+	})
+
+	t.Run("subscription is closed once the context is done", func(t *testing.T) {
+		cancel()
+		select {
+		case _, ok := <-subscription:
+			require.False(t, ok)
+		case <-time.After(10 * time.Second):
+			require.FailNow(t, "subscription was not closed")
+		}
+	})
+}
@@ -181,6 +181,7 @@ func (mj *MultiTenantHandleT) getUnionDS(ds dataSetT, workspaceCount map[string]
 				&_nullA, &_nullET, &_nullRT, &_nullEC, &_nullER, &_nullSP)
 		}
 		mj.assertError(err)
+		mj.assertError(decompressJobPayload(&job))
 		jobList = append(jobList, &job)
 
 		workspaceCount[job.WorkspaceId] -= 1
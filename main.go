@@ -69,7 +69,9 @@ import (
 
 	destination_connection_tester "github.com/rudderlabs/rudder-server/services/destination-connection-tester"
 	"github.com/rudderlabs/rudder-server/services/diagnostics"
+	"github.com/rudderlabs/rudder-server/services/dlq"
 	"github.com/rudderlabs/rudder-server/services/pgnotifier"
+	"github.com/rudderlabs/rudder-server/services/replay"
 	"github.com/rudderlabs/rudder-server/services/stats"
 
 	"github.com/rudderlabs/rudder-server/utils/logger"
@@ -81,6 +83,7 @@ import (
 	"github.com/rudderlabs/rudder-server/warehouse/postgres"
 	"github.com/rudderlabs/rudder-server/warehouse/redshift"
 	"github.com/rudderlabs/rudder-server/warehouse/snowflake"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
 
 	"github.com/rudderlabs/rudder-server/warehouse"
@@ -186,6 +189,7 @@ func runAllInit() {
 	postgres.Init()
 	redshift.Init()
 	snowflake.Init()
+	sqlite.Init()
 	deltalake.Init()
 	transformer.Init()
 	webhook.Init()
@@ -208,6 +212,8 @@ func runAllInit() {
 	operationmanager.Init2()
 	ratelimiter.Init()
 	sourcedebugger.Init()
+	replay.Init()
+	dlq.Init()
 	gateway.Init()
 	apphandlers.Init()
 	apphandlers.Init2()
@@ -8,6 +8,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	ratelimiter "github.com/rudderlabs/rudder-server/rate-limiter"
 )
 
 // MockRateLimiter is a mock of RateLimiter interface.
@@ -33,16 +34,16 @@ func (m *MockRateLimiter) EXPECT() *MockRateLimiterMockRecorder {
 	return m.recorder
 }
 
-// LimitReached mocks base method.
-func (m *MockRateLimiter) LimitReached(arg0 string) bool {
+// Allow mocks base method.
+func (m *MockRateLimiter) Allow(arg0 string, arg1 int, arg2 ratelimiter.LimitT) ratelimiter.ResultT {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "LimitReached", arg0)
-	ret0, _ := ret[0].(bool)
+	ret := m.ctrl.Call(m, "Allow", arg0, arg1, arg2)
+	ret0, _ := ret[0].(ratelimiter.ResultT)
 	return ret0
 }
 
-// LimitReached indicates an expected call of LimitReached.
-func (mr *MockRateLimiterMockRecorder) LimitReached(arg0 interface{}) *gomock.Call {
+// Allow indicates an expected call of Allow.
+func (mr *MockRateLimiterMockRecorder) Allow(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LimitReached", reflect.TypeOf((*MockRateLimiter)(nil).LimitReached), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockRateLimiter)(nil).Allow), arg0, arg1, arg2)
 }
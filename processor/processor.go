@@ -62,6 +62,7 @@ type HandleT struct {
 	transformer         transformer.Transformer
 	lastJobID           int64
 	gatewayDB           jobsdb.JobsDB
+	gatewayJobs         <-chan []*jobsdb.JobT // subscription to the gatewayDB, nil if it is polled
 	routerDB            jobsdb.JobsDB
 	batchRouterDB       jobsdb.JobsDB
 	errorDB             jobsdb.JobsDB
@@ -280,6 +281,11 @@ func (proc *HandleT) Status() interface{} {
 	for _, pqUserEvent := range proc.stats.userTransformEventsByTimeTaken {
 		statusRes["user-transformer"] = append(statusRes["user-transformer"], *pqUserEvent)
 	}
+	if breakers, ok := proc.transformer.(transformer.CircuitBreakers); ok {
+		for _, breakerStatus := range breakers.CircuitBreakerStatus() {
+			statusRes["transformer-circuit-breakers"] = append(statusRes["transformer-circuit-breakers"], breakerStatus)
+		}
+	}
 
 	if enableDedup {
 		proc.dedupHandler.PrintHistogram()
@@ -1444,11 +1450,13 @@ type transformationMessage struct {
 	hasMore bool
 }
 
-func (proc *HandleT) transformations(in transformationMessage) storeMessage {
+//transformations transforms the events of the message. The events are left untransformed once ctx is done,
+//so the message must not be stored then: its jobs are left executing and picked up again by crashRecover
+func (proc *HandleT) transformations(ctx context.Context, in transformationMessage) storeMessage {
 	//Now do the actual transformation. We call it in batches, once
 	//for each destination ID
 
-	ctx, task := trace.NewTask(context.Background(), "transformations")
+	ctx, task := trace.NewTask(ctx, "transformations")
 	defer task.End()
 
 	var procErrorJobsByDestID = make(map[string][]*jobsdb.JobT)
@@ -2102,20 +2110,60 @@ func (proc *HandleT) addToTransformEventByTimePQ(event *TransformRequestT, pq *t
 	}
 }
 
-func (proc *HandleT) getJobs() []*jobsdb.JobT {
-	s := time.Now()
-
-	proc.logger.Debugf("Processor DB Read size: %d", maxEventsToProcess)
+// transformerCircuitOpen returns whether the circuit to the transformer is open
+func (proc *HandleT) transformerCircuitOpen() bool {
+	breakers, ok := proc.transformer.(transformer.CircuitBreakers)
+	return ok && breakers.CircuitOpen()
+}
 
+func gatewayQueryParams() jobsdb.GetQueryParamsT {
 	eventCount := maxEventsToProcess
 	if !enableEventCount {
 		eventCount = 0
 	}
-	unprocessedList := proc.gatewayDB.GetUnprocessed(jobsdb.GetQueryParamsT{
+	return jobsdb.GetQueryParamsT{
 		CustomValFilters: []string{GWCustomVal},
 		JobCount:         maxEventsToProcess,
 		EventCount:       eventCount,
-	})
+	}
+}
+
+// subscribeToGatewayDB makes getJobs wait for the jobs stored in the gatewayDB instead of polling it,
+// if the gatewayDB announces the jobs stored (see jobsdb.SubscriberI)
+func (proc *HandleT) subscribeToGatewayDB(ctx context.Context) {
+	if subscriber, ok := proc.gatewayDB.(jobsdb.SubscriberI); ok && subscriber.StoreNotificationsEnabled() {
+		proc.logger.Info("Subscribing to the jobs stored in the gatewayDB")
+		proc.gatewayJobs = subscriber.Subscribe(ctx, gatewayQueryParams())
+	}
+}
+
+func (proc *HandleT) getJobs() []*jobsdb.JobT {
+	// jobs are left unprocessed while the transformer is failing, instead of waiting for it in the pipeline
+	if proc.transformerCircuitOpen() {
+		proc.logger.Debugf("Circuit to transformer is open. Not reading GW Jobs.")
+		return nil
+	}
+	s := time.Now()
+
+	proc.logger.Debugf("Processor DB Read size: %d", maxEventsToProcess)
+
+	var unprocessedList []*jobsdb.JobT
+	subscribed := proc.gatewayJobs != nil
+	if subscribed {
+		// the subscription delivers the jobs as soon as they are stored, waiting for them isn't part of the read time
+		select {
+		case unprocessedList, subscribed = <-proc.gatewayJobs:
+			if !subscribed {
+				proc.logger.Info("Subscription to the gatewayDB was closed, polling it")
+				proc.gatewayJobs = nil
+			}
+		case <-time.After(proc.maxLoopSleep):
+		}
+		s = time.Now()
+	}
+	if !subscribed {
+		unprocessedList = proc.gatewayDB.GetUnprocessed(gatewayQueryParams())
+	}
 	totalEvents := 0
 	totalPayloadBytes := 0
 	for i, job := range unprocessedList {
@@ -2196,7 +2244,7 @@ func (proc *HandleT) markExecuting(jobs []*jobsdb.JobT) error {
 
 // handlePendingGatewayJobs is checking for any pending gateway jobs (failed and unprocessed), and routes them appropriately
 // Returns true if any job is handled, otherwise returns false.
-func (proc *HandleT) handlePendingGatewayJobs() bool {
+func (proc *HandleT) handlePendingGatewayJobs(ctx context.Context) bool {
 	s := time.Now()
 
 	unprocessedList := proc.getJobs()
@@ -2205,14 +2253,17 @@ func (proc *HandleT) handlePendingGatewayJobs() bool {
 		return false
 	}
 
-	proc.Store(
-		proc.transformations(
-			proc.processJobsForDest(subJob{
-				subJobs: unprocessedList,
-				hasMore: false,
-			}, nil),
-		),
+	transformed := proc.transformations(
+		ctx,
+		proc.processJobsForDest(subJob{
+			subJobs: unprocessedList,
+			hasMore: false,
+		}, nil),
 	)
+	if ctx.Err() != nil {
+		return false
+	}
+	proc.Store(transformed)
 	proc.stats.statLoopTime.Since(s)
 
 	return true
@@ -2239,7 +2290,7 @@ func (proc *HandleT) mainLoop(ctx context.Context) {
 		case <-time.After(mainLoopTimeout):
 			proc.paused = false
 			if isUnLocked {
-				found := proc.handlePendingGatewayJobs()
+				found := proc.handlePendingGatewayJobs(ctx)
 				if found {
 					currLoopSleep = time.Duration(0)
 				} else {
@@ -2276,6 +2327,8 @@ func (proc *HandleT) pipelineWithPause(ctx context.Context, fn func(ctx context.
 		if pause == nil {
 			panic("`pause` should not be nil")
 		}
+		// the jobs of the batches left untransformed by the stopped pipeline are picked up again once resumed
+		proc.crashRecover()
 		proc.paused = true
 		pause.respChannel <- true
 		<-proc.resumeChannel
@@ -2335,6 +2388,7 @@ func (proc *HandleT) mainPipeline(ctx context.Context) {
 	chProc := make(chan subJob, bufferSize)
 	wg.Add(1)
 
+	proc.subscribeToGatewayDB(ctx)
 	go func() {
 		defer wg.Done()
 		defer close(chProc)
@@ -2351,6 +2405,11 @@ func (proc *HandleT) mainPipeline(ctx context.Context) {
 				}
 				dbReadStart := time.Now()
 				jobs := proc.getJobs()
+				if len(jobs) == 0 && proc.gatewayJobs != nil && !proc.transformerCircuitOpen() {
+					// getJobs already waited for the subscription
+					nextSleepTime = 0
+					continue
+				}
 				if len(jobs) == 0 {
 					// no jobs found, double sleep time until maxLoopSleep
 					nextSleepTime = 2 * nextSleepTime
@@ -2379,6 +2438,9 @@ func (proc *HandleT) mainPipeline(ctx context.Context) {
 				// nextSleepTime is dependent on the number of events read in this loop
 				emptyRatio := 1.0 - math.Min(1, float64(events)/float64(maxEventsToProcess))
 				nextSleepTime = time.Duration(emptyRatio * float64(proc.readLoopSleep))
+				if proc.gatewayJobs != nil {
+					nextSleepTime = 0
+				}
 
 				subJobs := jobSplitter(jobs)
 				for _, subJob := range subJobs {
@@ -2405,7 +2467,12 @@ func (proc *HandleT) mainPipeline(ctx context.Context) {
 		defer wg.Done()
 		defer close(chStore)
 		for msg := range chTrans {
-			chStore <- proc.transformations(msg)
+			transformed := proc.transformations(ctx, msg)
+			//the jobs of the messages not transformed are left executing, along with the other jobs of their batch
+			if ctx.Err() != nil {
+				continue
+			}
+			chStore <- transformed
 		}
 	}()
 
@@ -325,7 +325,7 @@ var _ = Describe("Processor", func() {
 
 			c.mockGatewayJobsDB.EXPECT().GetUnprocessed(jobsdb.GetQueryParamsT{CustomValFilters: gatewayCustomVal, JobCount: c.dbReadBatchSize, EventCount: c.processEventSize}).Return(emptyJobsList).Times(1)
 
-			didWork := processor.handlePendingGatewayJobs()
+			didWork := processor.handlePendingGatewayJobs(context.Background())
 			Expect(didWork).To(Equal(false))
 		})
 
@@ -1057,6 +1057,65 @@ var _ = Describe("Processor", func() {
 
 			processorSetupAndAssertJobHandling(processor, c, false, false)
 		})
+
+		It("should leave the jobs untransformed once the pipeline is stopped", func() {
+			message := mockEventData{
+				id:                        "1",
+				jobid:                     1010,
+				originalTimestamp:         "2000-01-02T01:23:45",
+				expectedOriginalTimestamp: "2000-01-02T01:23:45.000Z",
+				sentAt:                    "2000-01-02 01:23",
+				expectedSentAt:            "2000-01-02T01:23:00.000Z",
+				expectedReceivedAt:        "2001-01-02T02:23:45.000Z",
+				integrations:              map[string]bool{"All": false, "enabled-destination-b-definition-display-name": true},
+			}
+			unprocessedJobsList := []*jobsdb.JobT{
+				{
+					UUID:          uuid.Must(uuid.NewV4()),
+					JobID:         1010,
+					CreatedAt:     time.Date(2020, 04, 28, 23, 26, 00, 00, time.UTC),
+					ExpireAt:      time.Date(2020, 04, 28, 23, 26, 00, 00, time.UTC),
+					CustomVal:     gatewayCustomVal[0],
+					EventPayload:  createBatchPayload(WriteKeyEnabled, "2001-01-02T02:23:45.000Z", []mockEventData{message}),
+					LastJobStatus: jobsdb.JobStatusT{},
+					Parameters:    createBatchParameters(SourceIDEnabled),
+				},
+			}
+
+			c.mockGatewayJobsDB.EXPECT().DeleteExecuting(jobsdb.GetQueryParamsT{CustomValFilters: gatewayCustomVal, JobCount: -1}).Times(1)
+
+			mockTransformer := mocksTransformer.NewMockTransformer(c.mockCtrl)
+			mockTransformer.EXPECT().Setup().Times(1)
+
+			c.mockGatewayJobsDB.EXPECT().GetUnprocessed(jobsdb.GetQueryParamsT{
+				CustomValFilters: gatewayCustomVal,
+				JobCount:         c.dbReadBatchSize,
+				EventCount:       c.processEventSize,
+			}).Return(unprocessedJobsList).Times(1)
+
+			// the transformer waits until the pipeline is stopped, leaving the events untransformed
+			ctx, cancel := context.WithCancel(context.Background())
+			mockTransformer.EXPECT().Transform(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(1).
+				DoAndReturn(func(context.Context, []transformer.TransformerEventT, string, int) transformer.ResponseT {
+					cancel()
+					return transformer.ResponseT{}
+				})
+
+			// no status is written and no event is stored
+			c.mockGatewayJobsDB.EXPECT().UpdateJobStatusInTxn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+			c.mockProcErrorsDB.EXPECT().Store(gomock.Any()).Times(0)
+			c.mockRouterJobsDB.EXPECT().Store(gomock.Any()).Times(0)
+			c.mockBatchRouterJobsDB.EXPECT().Store(gomock.Any()).Times(0)
+			c.mockBackendConfig.EXPECT().GetWorkspaceIDForWriteKey(WriteKeyEnabled).Return(WorkspaceID).AnyTimes()
+			c.mockBackendConfig.EXPECT().GetWorkspaceLibrariesForWorkspaceID(WorkspaceID).Return(backendconfig.LibrariesT{}).AnyTimes()
+
+			processor := &HandleT{
+				transformer: mockTransformer,
+			}
+			Setup(processor, c, false, false)
+			processor.multitenantI = c.MockMultitenantHandle
+			Expect(processor.handlePendingGatewayJobs(ctx)).To(BeFalse())
+		})
 	})
 
 	Context("Pause and Resume Function Tests", func() {
@@ -1262,9 +1321,68 @@ var _ = Describe("Processor", func() {
 
 			processor.Start(ctx)
 		})
+
+		It("Should wait for the jobs of the gatewayDB subscription instead of polling it", func() {
+			mockTransformer := mocksTransformer.NewMockTransformer(c.mockCtrl)
+			mockTransformer.EXPECT().Setup().Times(1)
+			processor := &HandleT{
+				transformer: mockTransformer,
+			}
+			c.mockGatewayJobsDB.EXPECT().DeleteExecuting(jobsdb.GetQueryParamsT{CustomValFilters: gatewayCustomVal, JobCount: -1}).Times(1)
+			gatewayDB := &subscribableJobsDB{MockJobsDB: c.mockGatewayJobsDB, jobs: make(chan []*jobsdb.JobT, 1)}
+			processor.Setup(c.mockBackendConfig, gatewayDB, c.mockRouterJobsDB, c.mockBatchRouterJobsDB, c.mockProcErrorsDB, &clearDB, c.MockReportingI, c.MockMultitenantHandle)
+			defer processor.Shutdown()
+			processor.maxLoopSleep = 10 * time.Millisecond
+
+			c.mockGatewayJobsDB.EXPECT().GetUnprocessed(gomock.Any()).Times(0)
+			processor.subscribeToGatewayDB(context.Background())
+			Expect(gatewayDB.params).To(Equal(gatewayQueryParams()))
+
+			Expect(processor.getJobs()).To(BeEmpty(), "getJobs stops waiting after maxLoopSleep")
+
+			jobs := []*jobsdb.JobT{{JobID: 1, EventPayload: []byte(`{}`)}}
+			gatewayDB.jobs <- jobs
+			Expect(processor.getJobs()).To(Equal(jobs))
+		})
+
+		It("Should poll the gatewayDB once its subscription is closed", func() {
+			mockTransformer := mocksTransformer.NewMockTransformer(c.mockCtrl)
+			mockTransformer.EXPECT().Setup().Times(1)
+			processor := &HandleT{
+				transformer: mockTransformer,
+			}
+			c.mockGatewayJobsDB.EXPECT().DeleteExecuting(jobsdb.GetQueryParamsT{CustomValFilters: gatewayCustomVal, JobCount: -1}).Times(1)
+			gatewayDB := &subscribableJobsDB{MockJobsDB: c.mockGatewayJobsDB, jobs: make(chan []*jobsdb.JobT)}
+			processor.Setup(c.mockBackendConfig, gatewayDB, c.mockRouterJobsDB, c.mockBatchRouterJobsDB, c.mockProcErrorsDB, &clearDB, c.MockReportingI, c.MockMultitenantHandle)
+			defer processor.Shutdown()
+			processor.maxLoopSleep = time.Hour
+
+			processor.subscribeToGatewayDB(context.Background())
+			close(gatewayDB.jobs)
+
+			jobs := []*jobsdb.JobT{{JobID: 1, EventPayload: []byte(`{}`)}}
+			c.mockGatewayJobsDB.EXPECT().GetUnprocessed(gatewayQueryParams()).Return(jobs).Times(2)
+			Expect(processor.getJobs()).To(Equal(jobs), "getJobs doesn't wait for a closed subscription")
+			Expect(processor.gatewayJobs).To(BeNil())
+			Expect(processor.getJobs()).To(Equal(jobs))
+		})
 	})
 })
 
+// subscribableJobsDB is a gatewayDB announcing the jobs stored on jobs
+type subscribableJobsDB struct {
+	*mocksJobsDB.MockJobsDB
+	jobs   chan []*jobsdb.JobT
+	params jobsdb.GetQueryParamsT
+}
+
+func (*subscribableJobsDB) StoreNotificationsEnabled() bool { return true }
+
+func (db *subscribableJobsDB) Subscribe(_ context.Context, params jobsdb.GetQueryParamsT) <-chan []*jobsdb.JobT {
+	db.params = params
+	return db.jobs
+}
+
 var _ = Describe("Static Function Tests", func() {
 	initProcessor()
 
@@ -1715,7 +1833,7 @@ func Setup(processor *HandleT, c *testContext, enableDedup, enableReporting bool
 }
 
 func handlePendingGatewayJobs(processor *HandleT) {
-	didWork := processor.handlePendingGatewayJobs()
+	didWork := processor.handlePendingGatewayJobs(context.Background())
 	Expect(didWork).To(Equal(true))
 }
 
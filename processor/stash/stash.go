@@ -14,6 +14,7 @@ import (
 	uuid "github.com/gofrs/uuid"
 	"github.com/rudderlabs/rudder-server/config"
 	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/dlq"
 	"github.com/rudderlabs/rudder-server/services/filemanager"
 	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/utils/logger"
@@ -54,6 +55,7 @@ type HandleT struct {
 	stats           stats.Stats
 	statErrDBR      stats.RudderStats
 	statErrDBW      stats.RudderStats
+	statDLQIndexErr stats.RudderStats
 	logger          logger.LoggerI
 }
 
@@ -67,6 +69,7 @@ func (st *HandleT) Setup(errorDB jobsdb.JobsDB) {
 	st.stats = stats.DefaultStats
 	st.statErrDBR = st.stats.NewStat("processor.err_db_read_time", stats.TimerType)
 	st.statErrDBW = st.stats.NewStat("processor.err_db_write_time", stats.TimerType)
+	st.statDLQIndexErr = st.stats.NewStat("processor.dlq_index_errors", stats.CountType)
 	st.crashRecover()
 }
 
@@ -244,6 +247,13 @@ func (st *HandleT) readErrJobsLoop(ctx context.Context) {
 				continue
 			}
 
+			// jobs to retry were indexed when they were read unprocessed.
+			// jobs failing to be indexed are still stashed, the dead-letter queue must not hold back proc_error jobs
+			if err := dlq.Index(unprocessedList); err != nil {
+				st.logger.Errorf("Failed to index %d proc_error jobs into the dead-letter queue. Err: %v", len(unprocessedList), err)
+				st.statDLQIndexErr.Count(len(unprocessedList))
+			}
+
 			hasFileUploader := st.errFileUploader != nil
 
 			jobState := jobsdb.Executing.State
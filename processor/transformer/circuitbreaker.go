@@ -0,0 +1,216 @@
+package transformer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/processor/integrations"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+)
+
+const (
+	CircuitClosed = "closed"
+	CircuitOpen   = "open"
+)
+
+var (
+	enableCircuitBreaker    bool
+	circuitFailureThreshold int
+	circuitProbeInterval    time.Duration
+)
+
+func loadCircuitBreakerConfig() {
+	config.RegisterBoolConfigVariable(false, &enableCircuitBreaker, false, "Processor.transformerCircuitBreaker.enabled")
+	config.RegisterIntConfigVariable(5, &circuitFailureThreshold, true, 1, "Processor.transformerCircuitBreaker.failureThreshold")
+	config.RegisterDurationConfigVariable(time.Duration(5), &circuitProbeInterval, true, time.Second, []string{"Processor.transformerCircuitBreaker.probeInterval", "Processor.transformerCircuitBreaker.probeIntervalInS"}...)
+}
+
+//CircuitBreakers is implemented by transformers which stop sending requests to the transformer while it is failing
+type CircuitBreakers interface {
+	//CircuitOpen returns true if any circuit to the transformer is open
+	CircuitOpen() bool
+	//CircuitBreakerStatus returns the state of the circuit of every url and stage
+	CircuitBreakerStatus() []CircuitBreakerStatusT
+}
+
+//CircuitBreakerStatusT is the state of the circuit to a url of the transformer
+type CircuitBreakerStatusT struct {
+	URL                 string    `json:"url"`
+	Stage               string    `json:"stage"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenedAt            time.Time `json:"openedAt,omitempty"`
+}
+
+//circuitBreakerT opens the circuit to a url of the transformer after circuitFailureThreshold consecutive failed requests.
+//While it is open, requests wait for it to be closed, which happens once a probe request to the same url is answered by the transformer.
+type circuitBreakerT struct {
+	lock                sync.Mutex
+	url                 string
+	stage               string
+	state               string
+	consecutiveFailures int
+	openedAt            time.Time
+	// closed is closed while the circuit is closed, so that requests waiting for it are released
+	closed chan struct{}
+}
+
+func newCircuitBreaker(url, stage string) *circuitBreakerT {
+	closed := make(chan struct{})
+	close(closed)
+	return &circuitBreakerT{url: url, stage: stage, state: CircuitClosed, closed: closed}
+}
+
+//waitClosed blocks while the circuit is open, returning the error of ctx if it is done first
+func (breaker *circuitBreakerT) waitClosed(ctx context.Context) error {
+	breaker.lock.Lock()
+	closed := breaker.closed
+	breaker.lock.Unlock()
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//fail records a failed request, returning true if it opened the circuit
+func (breaker *circuitBreakerT) fail() bool {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	breaker.consecutiveFailures++
+	if breaker.state == CircuitOpen || breaker.consecutiveFailures < circuitFailureThreshold {
+		return false
+	}
+	breaker.state = CircuitOpen
+	breaker.openedAt = time.Now()
+	breaker.closed = make(chan struct{})
+	return true
+}
+
+//succeed records a successful request, or probe, closing the circuit
+func (breaker *circuitBreakerT) succeed() {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	breaker.consecutiveFailures = 0
+	if breaker.state == CircuitOpen {
+		breaker.state = CircuitClosed
+		breaker.openedAt = time.Time{}
+		close(breaker.closed)
+	}
+}
+
+func (breaker *circuitBreakerT) status() CircuitBreakerStatusT {
+	breaker.lock.Lock()
+	defer breaker.lock.Unlock()
+	return CircuitBreakerStatusT{
+		URL:                 breaker.url,
+		Stage:               breaker.stage,
+		State:               breaker.state,
+		ConsecutiveFailures: breaker.consecutiveFailures,
+		OpenedAt:            breaker.openedAt,
+	}
+}
+
+func (breaker *circuitBreakerT) stateStat() stats.RudderStats {
+	return stats.NewTaggedStat("processor.transformer_circuit_open", stats.GaugeType, stats.Tags{"stage": breaker.stage, "url": breaker.url})
+}
+
+//stageOfURL returns the stage of the pipeline the url of the transformer is called at
+func stageOfURL(url string) string {
+	switch url {
+	case integrations.GetUserTransformURL():
+		return UserTransformerStage
+	case integrations.GetTrackingPlanValidationURL():
+		return TrackingPlanValidationStage
+	default:
+		return DestTransformerStage
+	}
+}
+
+func (trans *HandleT) circuitBreaker(url string) *circuitBreakerT {
+	trans.circuitBreakersLock.Lock()
+	defer trans.circuitBreakersLock.Unlock()
+	breaker, ok := trans.circuitBreakers[url]
+	if !ok {
+		breaker = newCircuitBreaker(url, stageOfURL(url))
+		trans.circuitBreakers[url] = breaker
+	}
+	return breaker
+}
+
+//failRequest records a failed request to the url of the breaker, probing the url if it opened the circuit
+func (trans *HandleT) failRequest(breaker *circuitBreakerT) {
+	if !breaker.fail() {
+		return
+	}
+	trans.logger.Errorf("Opened circuit to transformer after %d consecutive failures, URL: %v", circuitFailureThreshold, breaker.url)
+	stats.NewTaggedStat("processor.transformer_circuit_opened", stats.CountType, stats.Tags{"stage": breaker.stage, "url": breaker.url}).Increment()
+	breaker.stateStat().Gauge(1)
+	rruntime.Go(func() {
+		trans.probe(breaker)
+	})
+}
+
+/*
+probe sends an empty batch to the url of the breaker every circuitProbeInterval, until the transformer answers it and the circuit is closed.
+The url itself is probed, rather than the health endpoint of the transformer, since the circuit of a url can be open while the transformer is up,
+e.g. if the proxy in front of it can't reach the service transforming for that url.
+*/
+func (trans *HandleT) probe(breaker *circuitBreakerT) {
+	for {
+		time.Sleep(circuitProbeInterval)
+		resp, err := trans.Client.Post(breaker.url, "application/json; charset=utf-8", bytes.NewBufferString("[]"))
+		if err != nil {
+			trans.logger.Debugf("Transformer probe failed, URL: %v Error: %v", breaker.url, err)
+			continue
+		}
+		resp.Body.Close()
+		if isTransformerUnavailable(resp.StatusCode) {
+			trans.logger.Debugf("Transformer probe failed, URL: %v Status: %d", breaker.url, resp.StatusCode)
+			continue
+		}
+		breaker.succeed()
+		breaker.stateStat().Gauge(0)
+		trans.logger.Infof("Closed circuit to transformer after successful probe, URL: %v", breaker.url)
+		return
+	}
+}
+
+//CircuitOpen returns true if any circuit to the transformer is open
+func (trans *HandleT) CircuitOpen() bool {
+	for _, status := range trans.CircuitBreakerStatus() {
+		if status.State == CircuitOpen {
+			return true
+		}
+	}
+	return false
+}
+
+//CircuitBreakerStatus returns the state of the circuit of every url and stage, ordered by url
+func (trans *HandleT) CircuitBreakerStatus() []CircuitBreakerStatusT {
+	trans.circuitBreakersLock.Lock()
+	breakers := make([]*circuitBreakerT, 0, len(trans.circuitBreakers))
+	for _, breaker := range trans.circuitBreakers {
+		breakers = append(breakers, breaker)
+	}
+	trans.circuitBreakersLock.Unlock()
+
+	statuses := make([]CircuitBreakerStatusT, 0, len(breakers))
+	for _, breaker := range breakers {
+		statuses = append(statuses, breaker.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
+//isTransformerUnavailable returns true for the status codes of proxies in front of a transformer which is down
+func isTransformerUnavailable(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
@@ -0,0 +1,347 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/integrations"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+const (
+	//InProcessTransformer is the value of the transformer setting of destination definitions transformed in-process
+	InProcessTransformer = "inProcess"
+	//InProcessTransformationConfig is the setting of destination definitions with the javascript code of their in-process transformation.
+	//The code defines a transform(event) function, returning an object, an array of objects, or null to filter the event.
+	InProcessTransformationConfig = "inProcessTransformation"
+)
+
+var (
+	inProcessTimeout          time.Duration
+	inProcessMaxConcurrency   int
+	inProcessMaxOutputSize    int
+	inProcessMaxCallStackSize int
+	inProcessMaxHeapSize      int
+	inProcessMemoryCheckEvery time.Duration
+)
+
+func loadInProcessConfig() {
+	config.RegisterDurationConfigVariable(time.Duration(1000), &inProcessTimeout, true, time.Millisecond, []string{"Processor.inProcessTransformer.timeout", "Processor.inProcessTransformer.timeoutInMS"}...)
+	config.RegisterIntConfigVariable(64, &inProcessMaxConcurrency, false, 1, "Processor.inProcessTransformer.maxConcurrency")
+	config.RegisterIntConfigVariable(1024, &inProcessMaxOutputSize, true, 1024, "Processor.inProcessTransformer.maxOutputSizeInKB")
+	config.RegisterIntConfigVariable(1024, &inProcessMaxCallStackSize, true, 1, "Processor.inProcessTransformer.maxCallStackSize")
+	config.RegisterIntConfigVariable(2048, &inProcessMaxHeapSize, true, 1024*1024, "Processor.inProcessTransformer.maxHeapSizeInMB")
+	config.RegisterDurationConfigVariable(time.Duration(10), &inProcessMemoryCheckEvery, false, time.Millisecond, "Processor.inProcessTransformer.memoryCheckIntervalInMS")
+}
+
+//UsesInProcessTransformer returns true if the events of the destination definition are transformed in-process.
+//It is set by the transformer setting of the destination definition, overridden by Processor.<destination type>.transformer
+func UsesInProcessTransformer(destinationDefinition backendconfig.DestinationDefinitionT) bool {
+	transformer, _ := destinationDefinition.Config["transformer"].(string)
+	if key := "Processor." + destinationDefinition.Name + ".transformer"; config.IsSet(key) {
+		transformer = config.GetString(key, "")
+	}
+	return transformer == InProcessTransformer
+}
+
+//InProcessT transforms the events of destinations in-process, running the javascript transformation of their destination definition.
+//
+//Every event is transformed in a new javascript runtime, on a copy of itself, so that transformations can't affect each other.
+//Runtimes are sandboxed: they only have the ECMAScript builtins, without any access to the network, the filesystem or timers.
+//Transformations exceeding the timeout are interrupted, the call stack is limited and outputs exceeding the max size are dropped.
+//The runtime can't account the memory allocated by each transformation, so the heap of the process is sampled while transformations run:
+//once it exceeds the max heap size, all running transformations are interrupted before they can exhaust the memory.
+//The heap size includes the garbage not collected yet, so the max should leave room for twice the heap the processor usually needs.
+//The events transformed concurrently are limited to bound CPU usage.
+type InProcessT struct {
+	guardConcurrency chan struct{}
+	logger           logger.LoggerI
+
+	programsLock sync.RWMutex
+	programs     map[string]inProcessProgramT
+}
+
+var (
+	errNoInProcessTransformation = errors.New("no in-process transformation defined")
+	errInProcessMemoryLimit      = errors.New("heap size exceeded the max of in-process transformations")
+)
+
+//inProcessRuntimesT are the runtimes of the transformations running in a batch
+type inProcessRuntimesT struct {
+	lock     sync.Mutex
+	runtimes map[*goja.Runtime]struct{}
+}
+
+func (r *inProcessRuntimesT) add(vm *goja.Runtime) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.runtimes[vm] = struct{}{}
+}
+
+func (r *inProcessRuntimesT) remove(vm *goja.Runtime) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.runtimes, vm)
+}
+
+func (r *inProcessRuntimesT) interruptAll(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for vm := range r.runtimes {
+		vm.Interrupt(err)
+	}
+}
+
+//inProcessProgramT is a compiled in-process transformation, or the error compiling it
+type inProcessProgramT struct {
+	program *goja.Program
+	err     error
+}
+
+//NewInProcessTransformer creates a new in-process transformer
+func NewInProcessTransformer() *InProcessT {
+	return &InProcessT{}
+}
+
+//Setup initializes this class
+func (trans *InProcessT) Setup() {
+	trans.logger = pkgLogger.Child("inprocess")
+	trans.guardConcurrency = make(chan struct{}, inProcessMaxConcurrency)
+	trans.programs = make(map[string]inProcessProgramT)
+}
+
+//Transform transforms the events with the in-process transformation of their destination definition.
+//The url and batchSize are ignored, since there are no requests to the transformer.
+func (trans *InProcessT) Transform(ctx context.Context, clientEvents []TransformerEventT, _ string, _ int) ResponseT {
+	if len(clientEvents) == 0 {
+		return ResponseT{}
+	}
+	sTags := statsTags(clientEvents[0])
+	s := time.Now()
+	defer stats.NewTaggedStat("processor.in_process_transformation_time", stats.TimerType, sTags).Since(s)
+
+	runtimes := &inProcessRuntimesT{runtimes: make(map[*goja.Runtime]struct{})}
+	done, watched := make(chan struct{}), make(chan struct{})
+	go func() {
+		trans.watchMemory(runtimes, done)
+		close(watched)
+	}()
+	defer func() {
+		close(done)
+		<-watched
+	}()
+
+	transformResponse := make([][]TransformerResponseT, len(clientEvents))
+	wg := sync.WaitGroup{}
+	wg.Add(len(clientEvents))
+	for i := range clientEvents {
+		i := i
+		trans.guardConcurrency <- struct{}{}
+		go func() {
+			transformResponse[i] = trans.transformEvent(ctx, runtimes, &clientEvents[i])
+			<-trans.guardConcurrency
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+
+	var outClientEvents []TransformerResponseT
+	var failedEvents []TransformerResponseT
+	for _, responses := range transformResponse {
+		for _, transformerResponse := range responses {
+			if transformerResponse.StatusCode != http.StatusOK {
+				failedEvents = append(failedEvents, transformerResponse)
+				continue
+			}
+			outClientEvents = append(outClientEvents, transformerResponse)
+		}
+	}
+	stats.NewTaggedStat("processor.in_process_transformer_failed", stats.CountType, sTags).Count(len(failedEvents))
+	return ResponseT{
+		Events:       outClientEvents,
+		FailedEvents: failedEvents,
+	}
+}
+
+//Validate fails all events, since tracking plans are only validated by the transformer
+func (trans *InProcessT) Validate(clientEvents []TransformerEventT, _ string, _ int) ResponseT {
+	var failedEvents []TransformerResponseT
+	for i := range clientEvents {
+		failedEvents = append(failedEvents, TransformerResponseT{
+			StatusCode: http.StatusNotFound,
+			Error:      "Tracking plans can't be validated by the in-process transformer",
+			Metadata:   clientEvents[i].Metadata,
+		})
+	}
+	return ResponseT{FailedEvents: failedEvents}
+}
+
+//watchMemory interrupts the running transformations once the heap exceeds its max size, until done is closed
+func (trans *InProcessT) watchMemory(runtimes *inProcessRuntimesT, done <-chan struct{}) {
+	ticker := time.NewTicker(inProcessMemoryCheckEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if size := heapSize(); size > uint64(inProcessMaxHeapSize) {
+			trans.logger.Warnf("Interrupting in-process transformations, heap size of %d bytes exceeds the max of %d bytes", size, inProcessMaxHeapSize)
+			runtimes.interruptAll(errInProcessMemoryLimit)
+		}
+	}
+}
+
+//heapSize returns the bytes occupied by the objects of the heap, including the dead ones not swept yet
+func heapSize() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+//program returns the compiled transformation of a destination definition, compiling it once
+func (trans *InProcessT) program(destinationDefinition backendconfig.DestinationDefinitionT) (*goja.Program, error) {
+	code, _ := destinationDefinition.Config[InProcessTransformationConfig].(string)
+	if code == "" {
+		return nil, fmt.Errorf("%w for %s", errNoInProcessTransformation, destinationDefinition.Name)
+	}
+	trans.programsLock.RLock()
+	compiled, ok := trans.programs[code]
+	trans.programsLock.RUnlock()
+	if ok {
+		return compiled.program, compiled.err
+	}
+
+	compiled.program, compiled.err = goja.Compile(destinationDefinition.Name, code, true)
+	trans.programsLock.Lock()
+	trans.programs[code] = compiled
+	trans.programsLock.Unlock()
+	return compiled.program, compiled.err
+}
+
+func (trans *InProcessT) transformEvent(ctx context.Context, runtimes *inProcessRuntimesT, event *TransformerEventT) (responses []TransformerResponseT) {
+	failed := func(statusCode int, err error) []TransformerResponseT {
+		return []TransformerResponseT{{StatusCode: statusCode, Error: err.Error(), Metadata: event.Metadata}}
+	}
+
+	destinationDefinition := event.Destination.DestinationDefinition
+	program, err := trans.program(destinationDefinition)
+	if errors.Is(err, errNoInProcessTransformation) {
+		return failed(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return failed(http.StatusBadRequest, fmt.Errorf("compiling transformation: %w", err))
+	}
+
+	// transformations get a copy of the event, so that they can't modify the events of the processor
+	var isolatedEvent map[string]interface{}
+	rawEvent, err := jsonfast.Marshal(event)
+	if err == nil {
+		err = jsonfast.Unmarshal(rawEvent, &isolatedEvent)
+	}
+	if err != nil {
+		return failed(http.StatusBadRequest, fmt.Errorf("copying event: %w", err))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			trans.logger.Errorf("In-process transformation of %s panicked for message %s: %v", destinationDefinition.Name, event.Metadata.MessageID, r)
+			responses = failed(http.StatusInternalServerError, fmt.Errorf("transformation panicked: %v", r))
+		}
+	}()
+
+	vm := goja.New()
+	vm.SetMaxCallStackSize(inProcessMaxCallStackSize)
+	runtimes.add(vm)
+	defer runtimes.remove(vm)
+	ctx, cancel := context.WithTimeout(ctx, inProcessTimeout)
+	defer cancel()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			vm.Interrupt(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	result, err := trans.run(vm, program, isolatedEvent)
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) && interrupted.Value() == errInProcessMemoryLimit {
+		stats.NewTaggedStat("processor.in_process_transformer_memory_limited", stats.CountType, statsTags(*event)).Increment()
+		return failed(http.StatusRequestEntityTooLarge, fmt.Errorf("transformation interrupted: %w", errInProcessMemoryLimit))
+	}
+	if errors.As(err, &interrupted) {
+		stats.NewTaggedStat("processor.in_process_transformer_timeouts", stats.CountType, statsTags(*event)).Increment()
+		return failed(http.StatusGatewayTimeout, fmt.Errorf("transformation did not complete within %v: %w", inProcessTimeout, ctx.Err()))
+	}
+	if err != nil {
+		return failed(http.StatusBadRequest, err)
+	}
+
+	rawOutputs, err := jsonfast.Marshal(result)
+	if err != nil {
+		return failed(http.StatusBadRequest, fmt.Errorf("marshalling output: %w", err))
+	}
+	if len(rawOutputs) > inProcessMaxOutputSize {
+		return failed(http.StatusRequestEntityTooLarge, fmt.Errorf("output size of %d bytes exceeds the max of %d bytes", len(rawOutputs), inProcessMaxOutputSize))
+	}
+	var outputs []map[string]interface{}
+	switch result.(type) {
+	case nil:
+	case []interface{}:
+		err = jsonfast.Unmarshal(rawOutputs, &outputs)
+	default:
+		var output map[string]interface{}
+		err = jsonfast.Unmarshal(rawOutputs, &output)
+		outputs = append(outputs, output)
+	}
+	if err != nil {
+		return failed(http.StatusBadRequest, fmt.Errorf("output is not an object or an array of objects: %w", err))
+	}
+
+	responses = make([]TransformerResponseT, 0, len(outputs))
+	for _, output := range outputs {
+		responses = append(responses, TransformerResponseT{Output: output, Metadata: event.Metadata, StatusCode: http.StatusOK})
+	}
+	return responses
+}
+
+//run runs the transformation in vm, returning the exported result of its transform function
+func (*InProcessT) run(vm *goja.Runtime, program *goja.Program, event map[string]interface{}) (interface{}, error) {
+	if _, err := vm.RunProgram(program); err != nil {
+		return nil, err
+	}
+	transform, ok := goja.AssertFunction(vm.Get("transform"))
+	if !ok {
+		return nil, errors.New("transformation does not define a transform function")
+	}
+	result, err := transform(goja.Undefined(), vm.ToValue(event))
+	if err != nil {
+		return nil, err
+	}
+	return result.Export(), nil
+}
+
+//transformsInProcess returns true if the events sent to url are transformed in-process,
+//i.e. url is the destination transformation of a destination definition using the in-process transformer.
+//The first event is assumed to be representative of all.
+func transformsInProcess(clientEvents []TransformerEventT, url string) bool {
+	if len(clientEvents) == 0 {
+		return false
+	}
+	destinationDefinition := clientEvents[0].Destination.DestinationDefinition
+	return UsesInProcessTransformer(destinationDefinition) && url == integrations.GetDestinationURL(destinationDefinition.Name)
+}
@@ -0,0 +1,152 @@
+package transformer_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/processor/integrations"
+	"github.com/rudderlabs/rudder-server/processor/transformer"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	inProcessDestType       = "IN_PROCESS_TEST"
+	inProcessTransformation = `
+function transform(event) {
+	var message = event.message;
+	switch (message.event) {
+	case "fail":
+		throw new Error("event can't be transformed");
+	case "block":
+		for (;;) {}
+	case "recurse":
+		var recurse = function() { return recurse(); };
+		return recurse();
+	case "large":
+		return {text: "x".repeat(2048)};
+	case "hog":
+		var hog = [], chunk = "x";
+		for (;;) { hog.push(chunk += chunk); }
+	case "split":
+		return [{part: 1}, {part: 2}];
+	case "filter":
+		return null;
+	}
+	message.transformed = true;
+	return message;
+}`
+)
+
+func inProcessEvent(messageID, eventName string) transformer.TransformerEventT {
+	return transformer.TransformerEventT{
+		Metadata: transformer.MetadataT{MessageID: messageID},
+		Message:  map[string]interface{}{"event": eventName},
+		Destination: backendconfig.DestinationT{
+			DestinationDefinition: backendconfig.DestinationDefinitionT{
+				Name: inProcessDestType,
+				Config: map[string]interface{}{
+					"transformer": transformer.InProcessTransformer,
+					transformer.InProcessTransformationConfig: inProcessTransformation,
+				},
+			},
+		},
+	}
+}
+
+func Test_InProcessTransformer(t *testing.T) {
+	t.Setenv(config.TransformKey("Processor.inProcessTransformer.timeout"), "100ms")
+	t.Setenv(config.TransformKey("Processor.inProcessTransformer.maxOutputSizeInKB"), "1")
+	t.Setenv(config.TransformKey("Processor.inProcessTransformer.maxCallStackSize"), "100")
+	t.Setenv(config.TransformKey("Processor.inProcessTransformer.maxHeapSizeInMB"), "100")
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	integrations.Init()
+	transformer.Init()
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	tr := transformer.NewTransformer()
+	tr.Client = srv.Client()
+	tr.Setup()
+
+	events := []transformer.TransformerEventT{
+		inProcessEvent("ok", "track"),
+		inProcessEvent("split", "split"),
+		inProcessEvent("filter", "filter"),
+		inProcessEvent("fail", "fail"),
+		inProcessEvent("block", "block"),
+		inProcessEvent("recurse", "recurse"),
+		inProcessEvent("large", "large"),
+	}
+	runtime.GC() // so that the heap doesn't exceed its max with the garbage of previous runs
+	rsp := tr.Transform(context.TODO(), events, integrations.GetDestinationURL(inProcessDestType), 10)
+	require.Zero(t, requests, "events of in-process destinations are not sent to the transformer")
+
+	require.Equal(t, []transformer.TransformerResponseT{
+		{Output: map[string]interface{}{"event": "track", "transformed": true}, Metadata: transformer.MetadataT{MessageID: "ok"}, StatusCode: 200},
+		{Output: map[string]interface{}{"part": float64(1)}, Metadata: transformer.MetadataT{MessageID: "split"}, StatusCode: 200},
+		{Output: map[string]interface{}{"part": float64(2)}, Metadata: transformer.MetadataT{MessageID: "split"}, StatusCode: 200},
+	}, rsp.Events)
+	require.Equal(t, map[string]interface{}{"event": "track"}, map[string]interface{}(events[0].Message), "transformations get a copy of the events")
+
+	statusCodes := map[string]int{}
+	for _, failed := range rsp.FailedEvents {
+		require.NotEmpty(t, failed.Error)
+		statusCodes[failed.Metadata.MessageID] = failed.StatusCode
+	}
+	require.Equal(t, map[string]int{
+		"fail":    http.StatusBadRequest,
+		"block":   http.StatusGatewayTimeout,
+		"recurse": http.StatusBadRequest,
+		"large":   http.StatusRequestEntityTooLarge,
+	}, statusCodes)
+
+	rsp = tr.Transform(context.TODO(), []transformer.TransformerEventT{inProcessEvent("hog", "hog")}, integrations.GetDestinationURL(inProcessDestType), 10)
+	require.Len(t, rsp.FailedEvents, 1)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rsp.FailedEvents[0].StatusCode, "transformations exceeding the max heap size are interrupted before the timeout")
+
+	undefined := inProcessEvent("undefined", "track")
+	undefined.Destination.DestinationDefinition.Name = "UNDEFINED"
+	delete(undefined.Destination.DestinationDefinition.Config, transformer.InProcessTransformationConfig)
+	rsp = tr.Transform(context.TODO(), []transformer.TransformerEventT{undefined}, integrations.GetDestinationURL("UNDEFINED"), 10)
+	require.Zero(t, requests)
+	require.Len(t, rsp.FailedEvents, 1)
+	require.Equal(t, http.StatusNotFound, rsp.FailedEvents[0].StatusCode)
+
+	invalid := inProcessEvent("invalid", "track")
+	invalid.Destination.DestinationDefinition.Config[transformer.InProcessTransformationConfig] = "function transform(event) {"
+	rsp = tr.Transform(context.TODO(), []transformer.TransformerEventT{invalid}, integrations.GetDestinationURL(inProcessDestType), 10)
+	require.Len(t, rsp.FailedEvents, 1)
+	require.Equal(t, http.StatusBadRequest, rsp.FailedEvents[0].StatusCode)
+
+	sandboxed := inProcessEvent("sandboxed", "track")
+	sandboxed.Destination.DestinationDefinition.Config[transformer.InProcessTransformationConfig] = `function transform(event) { return require("fs"); }`
+	rsp = tr.Transform(context.TODO(), []transformer.TransformerEventT{sandboxed}, integrations.GetDestinationURL(inProcessDestType), 10)
+	require.Len(t, rsp.FailedEvents, 1, "transformations have no access to modules")
+	require.Equal(t, http.StatusBadRequest, rsp.FailedEvents[0].StatusCode)
+
+	rsp = tr.InProcess.Validate(events[:1], integrations.GetTrackingPlanValidationURL(), 10)
+	require.Len(t, rsp.FailedEvents, 1, "tracking plans are not validated in-process")
+}
+
+func Test_UsesInProcessTransformer(t *testing.T) {
+	config.Load()
+	require.False(t, transformer.UsesInProcessTransformer(backendconfig.DestinationDefinitionT{Name: "WEBHOOK"}))
+	require.True(t, transformer.UsesInProcessTransformer(backendconfig.DestinationDefinitionT{Name: "WEBHOOK", Config: map[string]interface{}{"transformer": "inProcess"}}))
+
+	t.Setenv(config.TransformKey("Processor.WEBHOOK.transformer"), "remote")
+	require.False(t, transformer.UsesInProcessTransformer(backendconfig.DestinationDefinitionT{Name: "WEBHOOK", Config: map[string]interface{}{"transformer": "inProcess"}}))
+}
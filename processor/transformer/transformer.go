@@ -87,7 +87,13 @@ type HandleT struct {
 
 	Client *http.Client
 
+	//InProcess transforms the events of destinations using the in-process transformer
+	InProcess Transformer
+
 	guardConcurrency chan struct{}
+
+	circuitBreakersLock sync.Mutex
+	circuitBreakers     map[string]*circuitBreakerT
 }
 
 //Transformer provides methods to transform events
@@ -120,6 +126,8 @@ func loadConfig() {
 
 	config.RegisterIntConfigVariable(30, &maxRetry, true, 1, "Processor.maxRetry")
 	config.RegisterDurationConfigVariable(time.Duration(100), &retrySleep, true, time.Millisecond, []string{"Processor.retrySleep", "Processor.retrySleepInMS"}...)
+	loadInProcessConfig()
+	loadCircuitBreakerConfig()
 }
 
 type TransformerResponseT struct {
@@ -146,6 +154,7 @@ func (trans *HandleT) Setup() {
 	trans.transformTimerStat = stats.NewStat("processor.transformation_time", stats.TimerType)
 
 	trans.guardConcurrency = make(chan struct{}, maxConcurrency)
+	trans.circuitBreakers = make(map[string]*circuitBreakerT)
 	trans.perfStats = &misc.PerfStats{}
 	trans.perfStats.Setup("JS Call")
 
@@ -158,6 +167,10 @@ func (trans *HandleT) Setup() {
 			},
 		}
 	}
+	if trans.InProcess == nil {
+		trans.InProcess = NewInProcessTransformer()
+	}
+	trans.InProcess.Setup()
 }
 
 //ResponseT represents a Transformer response
@@ -200,6 +213,9 @@ func (trans *HandleT) Transform(ctx context.Context, clientEvents []TransformerE
 	if len(clientEvents) == 0 {
 		return ResponseT{}
 	}
+	if transformsInProcess(clientEvents, url) {
+		return trans.InProcess.Transform(ctx, clientEvents, url, batchSize)
+	}
 
 	sTags := statsTags(clientEvents[0])
 
@@ -316,7 +332,19 @@ func (trans *HandleT) request(ctx context.Context, url string, data []Transforme
 
 	// assume that the first event is representative
 
+	breaker := trans.circuitBreaker(url)
 	for {
+		if enableCircuitBreaker {
+			//the events are left untransformed once ctx is done, the processor does not store them
+			waitErr := breaker.waitClosed(ctx)
+			if waitErr == nil {
+				waitErr = ctx.Err()
+			}
+			if waitErr != nil {
+				trans.logger.Infof("Stopped waiting for the circuit to transformer to close, URL: %v Error: %v", url, waitErr)
+				return nil
+			}
+		}
 		s := time.Now()
 		trace.WithRegion(ctx, "request/post", func() {
 			resp, err = trans.Client.Post(url, "application/json; charset=utf-8", bytes.NewBuffer(rawJSON))
@@ -327,12 +355,18 @@ func (trans *HandleT) request(ctx context.Context, url string, data []Transforme
 			respData, err = io.ReadAll(resp.Body)
 			resp.Body.Close()
 		}
+		if err == nil && enableCircuitBreaker && isTransformerUnavailable(resp.StatusCode) {
+			err = fmt.Errorf("transformer is unavailable, status code: %d", resp.StatusCode)
+		}
 
 		if err != nil {
 			trans.requestTime(statsTags(data[0]), time.Since(s))
 			reqFailed = true
 			trans.logger.Errorf("JS HTTP connection error: URL: %v Error: %+v", url, err)
-			if retryCount > maxRetry {
+			//With the circuit breaker, requests wait for the transformer to recover between retries, until ctx is done
+			if enableCircuitBreaker {
+				trans.failRequest(breaker)
+			} else if retryCount > maxRetry {
 				panic(fmt.Errorf("JS HTTP connection error: URL: %v Error: %+v", url, err))
 			}
 			retryCount++
@@ -343,6 +377,9 @@ func (trans *HandleT) request(ctx context.Context, url string, data []Transforme
 		if reqFailed {
 			trans.logger.Errorf("Failed request succeeded after %v retries, URL: %v", retryCount, url)
 		}
+		if enableCircuitBreaker {
+			breaker.succeed()
+		}
 
 		// perform version compatability check only on success
 		if resp.StatusCode == http.StatusOK {
@@ -6,9 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/processor/integrations"
 	"github.com/rudderlabs/rudder-server/processor/transformer"
 	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/utils/logger"
@@ -128,3 +131,84 @@ func Test_Transformer(t *testing.T) {
 		require.Equal(t, expectedResponse, rsp)
 	}
 }
+
+func Test_TransformerCircuitBreaker(t *testing.T) {
+	var up, healthChecks int32
+	ft := &fakeTransformer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			atomic.AddInt32(&healthChecks, 1)
+		}
+		if atomic.LoadInt32(&up) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ft.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	t.Setenv("DEST_TRANSFORM_URL", srv.URL)
+	t.Setenv(config.TransformKey("Processor.transformerCircuitBreaker.enabled"), "true")
+	t.Setenv(config.TransformKey("Processor.transformerCircuitBreaker.failureThreshold"), "2")
+	t.Setenv(config.TransformKey("Processor.transformerCircuitBreaker.probeInterval"), "10ms")
+	t.Setenv(config.TransformKey("Processor.retrySleep"), "1ms")
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	integrations.Init()
+	transformer.Init()
+
+	tr := transformer.NewTransformer()
+	tr.Client = srv.Client()
+	tr.Setup()
+
+	url := integrations.GetDestinationURL("WEBHOOK")
+	events := []transformer.TransformerEventT{{
+		Metadata: transformer.MetadataT{MessageID: "messageID-1"},
+		Message:  map[string]interface{}{"src-key-1": "messageID-1", "forceStatusCode": 200},
+	}}
+	done := make(chan transformer.ResponseT)
+	go func() {
+		done <- tr.Transform(context.TODO(), events, url, 10)
+	}()
+
+	require.Eventually(t, tr.CircuitOpen, time.Second, time.Millisecond, "circuit opens after consecutive failures")
+	status := tr.CircuitBreakerStatus()
+	require.Len(t, status, 1)
+	require.Equal(t, url, status[0].URL)
+	require.Equal(t, transformer.DestTransformerStage, status[0].Stage)
+	require.Equal(t, transformer.CircuitOpen, status[0].State)
+	require.GreaterOrEqual(t, status[0].ConsecutiveFailures, 2)
+
+	select {
+	case <-done:
+		t.Fatal("requests wait while the circuit is open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan transformer.ResponseT)
+	go func() {
+		cancelled <- tr.Transform(ctx, events, url, 10)
+	}()
+	cancel()
+	select {
+	case rsp := <-cancelled:
+		require.Empty(t, rsp.Events, "events are left untransformed once their context is done")
+		require.Empty(t, rsp.FailedEvents, "events are left untransformed once their context is done")
+	case <-time.After(time.Second):
+		t.Fatal("requests stop waiting once their context is done")
+	}
+
+	atomic.StoreInt32(&up, 1)
+	select {
+	case rsp := <-done:
+		require.Len(t, rsp.Events, 1)
+		require.Empty(t, rsp.FailedEvents)
+	case <-time.After(time.Second):
+		t.Fatal("requests are sent once the probe closes the circuit")
+	}
+	require.False(t, tr.CircuitOpen())
+	require.Equal(t, 0, tr.CircuitBreakerStatus()[0].ConsecutiveFailures)
+	require.Zero(t, atomic.LoadInt32(&healthChecks), "the url of the circuit is probed, not the health of the transformer")
+}
@@ -26,6 +26,7 @@ type WarehouseClient interface {
 	TriggerWHUpload(ctx context.Context, in *WHUploadRequest, opts ...grpc.CallOption) (*TriggerWhUploadsResponse, error)
 	TriggerWHUploads(ctx context.Context, in *WHUploadsRequest, opts ...grpc.CallOption) (*TriggerWhUploadsResponse, error)
 	Validate(ctx context.Context, in *WHValidationRequest, opts ...grpc.CallOption) (*WHValidationResponse, error)
+	ApplyWHUploadAction(ctx context.Context, in *WHUploadActionRequest, opts ...grpc.CallOption) (*TriggerWhUploadsResponse, error)
 }
 
 type warehouseClient struct {
@@ -90,6 +91,15 @@ func (c *warehouseClient) Validate(ctx context.Context, in *WHValidationRequest,
 	return out, nil
 }
 
+func (c *warehouseClient) ApplyWHUploadAction(ctx context.Context, in *WHUploadActionRequest, opts ...grpc.CallOption) (*TriggerWhUploadsResponse, error) {
+	out := new(TriggerWhUploadsResponse)
+	err := c.cc.Invoke(ctx, "/proto.Warehouse/ApplyWHUploadAction", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // WarehouseServer is the server API for Warehouse service.
 // All implementations must embed UnimplementedWarehouseServer
 // for forward compatibility
@@ -100,6 +110,7 @@ type WarehouseServer interface {
 	TriggerWHUpload(context.Context, *WHUploadRequest) (*TriggerWhUploadsResponse, error)
 	TriggerWHUploads(context.Context, *WHUploadsRequest) (*TriggerWhUploadsResponse, error)
 	Validate(context.Context, *WHValidationRequest) (*WHValidationResponse, error)
+	ApplyWHUploadAction(context.Context, *WHUploadActionRequest) (*TriggerWhUploadsResponse, error)
 	mustEmbedUnimplementedWarehouseServer()
 }
 
@@ -125,6 +136,9 @@ func (UnimplementedWarehouseServer) TriggerWHUploads(context.Context, *WHUploads
 func (UnimplementedWarehouseServer) Validate(context.Context, *WHValidationRequest) (*WHValidationResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
 }
+func (UnimplementedWarehouseServer) ApplyWHUploadAction(context.Context, *WHUploadActionRequest) (*TriggerWhUploadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ApplyWHUploadAction not implemented")
+}
 func (UnimplementedWarehouseServer) mustEmbedUnimplementedWarehouseServer() {}
 
 // UnsafeWarehouseServer may be embedded to opt out of forward compatibility for this service.
@@ -246,6 +260,24 @@ func _Warehouse_Validate_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Warehouse_ApplyWHUploadAction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WHUploadActionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WarehouseServer).ApplyWHUploadAction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Warehouse/ApplyWHUploadAction",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WarehouseServer).ApplyWHUploadAction(ctx, req.(*WHUploadActionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Warehouse_ServiceDesc is the grpc.ServiceDesc for Warehouse service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -277,6 +309,10 @@ var Warehouse_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Validate",
 			Handler:    _Warehouse_Validate_Handler,
 		},
+		{
+			MethodName: "ApplyWHUploadAction",
+			Handler:    _Warehouse_ApplyWHUploadAction_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/warehouse/warehouse.proto",
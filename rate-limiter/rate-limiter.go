@@ -3,28 +3,64 @@ package ratelimiter
 //go:generate mockgen -destination=../mocks/rate-limiter/mock_ratelimiter.go -package=mocks_ratelimiter github.com/rudderlabs/rudder-server/rate-limiter RateLimiter
 
 import (
+	"math"
+	"sync"
 	"time"
 
-	"github.com/EagleChen/restrictor"
 	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/kvstoremanager"
 	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/types"
 )
 
 var (
 	eventLimit            int
 	rateLimitWindowInMins time.Duration
 	noOfBucketsInWindow   int
+	storeType             string
+	storeRetryInterval    time.Duration
 	pkgLogger             logger.LoggerI
 )
 
+const (
+	//MemoryStore keeps the events of the rolling windows in memory, so every instance enforces its limits separately
+	MemoryStore = "memory"
+	//RedisStore keeps the events of the rolling windows in redis, so the limits are shared by all instances
+	RedisStore = "redis"
+)
+
 //RateLimiter is an interface for rate limiting functions
 type RateLimiter interface {
-	LimitReached(key string) bool
+	//Allow adds events to the rolling window of key, unless they exceed the limit
+	Allow(key string, events int, limit LimitT) ResultT
+}
+
+//LimitT is the max number of events allowed in a rolling window
+type LimitT struct {
+	Events int
+	Window time.Duration
+}
+
+//ResultT is the state of the rolling window of a key, after trying to add events to it
+type ResultT struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	//Reset is the time until the oldest events of the window leave it
+	Reset time.Duration
+	//RetryAfter is the time until the events which were not allowed would fit in the window
+	RetryAfter time.Duration
 }
 
 //HandleT is a Handle for event limiter
 type HandleT struct {
-	restrictor restrictor.Restrictor
+	store    storeI
+	fallback *memoryStoreT
+	now      func() time.Time
+
+	storeLock sync.RWMutex
+	//storeFailedAt is when the store last failed, the fallback is used until storeRetryInterval after it
+	storeFailedAt time.Time
 }
 
 func Init() {
@@ -39,19 +75,140 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(time.Duration(60), &rateLimitWindowInMins, false, time.Minute, []string{"RateLimit.rateLimitWindow", "RateLimit.rateLimitWindowInMins"}...)
 	// Number of buckets in time window. 12 by default
 	config.RegisterIntConfigVariable(12, &noOfBucketsInWindow, false, 1, "RateLimit.noOfBucketsInWindow")
+	// Store of the rolling windows, memory or redis. memory by default
+	config.RegisterStringConfigVariable(MemoryStore, &storeType, false, "RateLimit.store")
+	// Time the rolling windows are kept in memory after the store fails, before trying the store again. 10 secs by default
+	config.RegisterDurationConfigVariable(time.Duration(10), &storeRetryInterval, false, time.Second, "RateLimit.redis.retryInterval")
+}
+
+//DefaultLimit is the limit of keys without a limit of their own
+func DefaultLimit() LimitT {
+	return LimitT{Events: eventLimit, Window: rateLimitWindowInMins}
+}
+
+func redisConfig() types.ConfigT {
+	return types.ConfigT{
+		"address":       config.GetString("RateLimit.redis.address", "localhost:6379"),
+		"password":      config.GetString("RateLimit.redis.password", ""),
+		"database":      config.GetString("RateLimit.redis.database", "0"),
+		"clusterMode":   config.GetBool("RateLimit.redis.clusterMode", false),
+		"secure":        config.GetBool("RateLimit.redis.secure", false),
+		"skipVerify":    config.GetBool("RateLimit.redis.skipVerify", false),
+		"caCertificate": config.GetString("RateLimit.redis.caCertificate", ""),
+	}
 }
 
 //SetUp eventLimiter
 func (rateLimiter *HandleT) SetUp() {
-	store, err := restrictor.NewMemoryStore()
-	if err != nil {
-		pkgLogger.Error("memory store failed")
+	rateLimiter.fallback = newMemoryStore()
+	rateLimiter.store = rateLimiter.fallback
+	rateLimiter.now = time.Now
+	switch storeType {
+	case RedisStore:
+		rateLimiter.store = newRedisStore(kvstoremanager.NewRedisClient(redisConfig()))
+	case MemoryStore:
+	default:
+		pkgLogger.Errorf("Unknown rate limit store %q, using the %s store", storeType, MemoryStore)
+	}
+}
+
+//Allow adds events to the rolling window of key, unless they exceed the limit.
+//Limits without events or window are unlimited.
+//
+//The window is split in noOfBucketsInWindow buckets, and the events of a bucket leave the window all together.
+//If the store fails, e.g. redis is not reachable, the window is kept in memory for storeRetryInterval before trying the store again,
+//so that an unreachable store neither slows down every request nor floods the logs.
+func (rateLimiter *HandleT) Allow(key string, events int, limit LimitT) ResultT {
+	if limit.Events <= 0 || limit.Window <= 0 {
+		return ResultT{Allowed: true}
 	}
 
-	rateLimiter.restrictor = restrictor.NewRestrictor(rateLimitWindowInMins, uint32(eventLimit), uint32(noOfBucketsInWindow), store)
+	span := bucketSpan(limit.Window)
+	noOfBuckets := int(math.Ceil(float64(limit.Window) / float64(span)))
+	now := rateLimiter.now()
+	bucket := now.UnixNano() / int64(span)
+
+	var (
+		counts  []int
+		allowed bool
+		err     error
+	)
+	useStore := rateLimiter.useStore(now)
+	if useStore {
+		counts, allowed, err = rateLimiter.store.add(key, bucket, noOfBuckets, events, limit.Events, limit.Window+span)
+		if err != nil {
+			rateLimiter.storeFailed(now, err)
+		}
+	}
+	if !useStore || err != nil {
+		counts, allowed, _ = rateLimiter.fallback.add(key, bucket, noOfBuckets, events, limit.Events, limit.Window+span)
+	}
+
+	// leaves returns the time until the i-th bucket of counts leaves the window
+	oldestBucket := bucket - int64(noOfBuckets) + 1
+	leaves := func(i int) time.Duration {
+		return time.Duration((oldestBucket+int64(i)+int64(noOfBuckets))*int64(span) - now.UnixNano())
+	}
+
+	var total int
+	for _, count := range counts {
+		total += count
+	}
+	result := ResultT{Allowed: allowed, Limit: limit.Events}
+	if total < limit.Events {
+		result.Remaining = limit.Events - total
+	}
+	for i, count := range counts {
+		if count > 0 {
+			result.Reset = leaves(i)
+			break
+		}
+	}
+	if !allowed {
+		result.RetryAfter = limit.Window
+		var freed int
+		for i, count := range counts {
+			freed += count
+			if total-freed+events <= limit.Events {
+				result.RetryAfter = leaves(i)
+				break
+			}
+		}
+	}
+	return result
 }
 
-//LimitReached returns true if number of events in the rolling window is less than the max events allowed, else false
-func (rateLimiter *HandleT) LimitReached(key string) bool {
-	return rateLimiter.restrictor.LimitReached(key)
+//useStore returns whether the store is used at now, or the fallback because the store failed less than storeRetryInterval before
+func (rateLimiter *HandleT) useStore(now time.Time) bool {
+	if rateLimiter.store == rateLimiter.fallback {
+		return true
+	}
+	rateLimiter.storeLock.RLock()
+	defer rateLimiter.storeLock.RUnlock()
+	return rateLimiter.storeFailedAt.IsZero() || now.Sub(rateLimiter.storeFailedAt) >= storeRetryInterval
+}
+
+//storeFailed switches to the fallback for storeRetryInterval. It logs once per interval, as the store is not used in between
+func (rateLimiter *HandleT) storeFailed(now time.Time, err error) {
+	rateLimiter.storeLock.Lock()
+	defer rateLimiter.storeLock.Unlock()
+	if !rateLimiter.storeFailedAt.IsZero() && now.Sub(rateLimiter.storeFailedAt) < storeRetryInterval {
+		//a concurrent request already switched to the fallback
+		return
+	}
+	rateLimiter.storeFailedAt = now
+	pkgLogger.Errorf("Failed to rate limit in the %s store, falling back to memory for %v: %v", storeType, storeRetryInterval, err)
+}
+
+//bucketSpan is the time span of each bucket of the window, rounded up to seconds
+func bucketSpan(window time.Duration) time.Duration {
+	buckets := noOfBucketsInWindow
+	if buckets <= 0 {
+		buckets = 1
+	}
+	span := (window/time.Duration(buckets) + time.Second - 1).Truncate(time.Second)
+	if span < time.Second {
+		span = time.Second
+	}
+	return span
 }
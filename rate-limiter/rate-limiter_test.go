@@ -0,0 +1,98 @@
+package ratelimiter
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+type failingStoreT struct {
+	calls int
+}
+
+func (s *failingStoreT) add(string, int64, int, int, int, time.Duration) ([]int, bool, error) {
+	s.calls++
+	return nil, false, errors.New("store is not reachable")
+}
+
+var _ = Describe("RateLimiter", func() {
+	var (
+		rateLimiter *HandleT
+		now         time.Time
+		limit       = LimitT{Events: 10, Window: time.Minute}
+	)
+
+	BeforeEach(func() {
+		config.Load()
+		logger.Init()
+		Init()
+		noOfBucketsInWindow = 6
+		now = time.Unix(1_000_000, 0)
+		rateLimiter = &HandleT{}
+		rateLimiter.SetUp()
+		rateLimiter.now = func() time.Time { return now }
+	})
+
+	It("should allow events within the limit", func() {
+		result := rateLimiter.Allow("key", 4, limit)
+		Expect(result).To(Equal(ResultT{Allowed: true, Limit: 10, Remaining: 6, Reset: time.Minute}))
+
+		result = rateLimiter.Allow("key", 6, limit)
+		Expect(result.Allowed).To(BeTrue())
+		Expect(result.Remaining).To(Equal(0))
+	})
+
+	It("should reject events exceeding the limit until they fit in the window", func() {
+		Expect(rateLimiter.Allow("key", 4, limit).Allowed).To(BeTrue())
+		now = now.Add(25 * time.Second)
+		Expect(rateLimiter.Allow("key", 5, limit).Allowed).To(BeTrue())
+
+		result := rateLimiter.Allow("key", 2, limit)
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.Remaining).To(Equal(1))
+		Expect(result.Reset).To(Equal(35 * time.Second))
+		Expect(result.RetryAfter).To(Equal(35*time.Second), "the events of the first bucket leave the window")
+
+		result = rateLimiter.Allow("key", 7, limit)
+		Expect(result.RetryAfter).To(Equal(55*time.Second), "the events of both buckets leave the window")
+
+		now = now.Add(35 * time.Second)
+		Expect(rateLimiter.Allow("key", 2, limit).Allowed).To(BeTrue())
+	})
+
+	It("should keep separate windows for separate keys", func() {
+		Expect(rateLimiter.Allow("key", 10, limit).Allowed).To(BeTrue())
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeFalse())
+		Expect(rateLimiter.Allow("other-key", 1, limit).Allowed).To(BeTrue())
+	})
+
+	It("should not limit keys without a limit", func() {
+		Expect(rateLimiter.Allow("key", 1000, LimitT{})).To(Equal(ResultT{Allowed: true}))
+	})
+
+	It("should fall back to memory if the store fails", func() {
+		rateLimiter.store = &failingStoreT{}
+		Expect(rateLimiter.Allow("key", 10, limit).Allowed).To(BeTrue())
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeFalse())
+	})
+
+	It("should not retry the failed store before the retry interval", func() {
+		storeRetryInterval = 10 * time.Second
+		store := &failingStoreT{}
+		rateLimiter.store = store
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeTrue())
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeTrue())
+		Expect(store.calls).To(Equal(1), "the fallback is used until the retry interval")
+
+		now = now.Add(10 * time.Second)
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeTrue())
+		Expect(store.calls).To(Equal(2), "the store is retried after the retry interval")
+		Expect(rateLimiter.Allow("key", 1, limit).Allowed).To(BeTrue())
+		Expect(store.calls).To(Equal(2))
+	})
+})
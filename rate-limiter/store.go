@@ -0,0 +1,123 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+//storeI keeps the event counts of the buckets of rolling windows
+type storeI interface {
+	//add adds events to the bucket of key, if the sum of the counts of the last noOfBuckets buckets would not exceed limit.
+	//It returns the counts of the last noOfBuckets buckets, oldest first, and whether the events were added.
+	add(key string, bucket int64, noOfBuckets, events, limit int, ttl time.Duration) (counts []int, added bool, err error)
+}
+
+type memoryStoreT struct {
+	lock    sync.Mutex
+	windows map[string]map[int64]int
+}
+
+func newMemoryStore() *memoryStoreT {
+	return &memoryStoreT{windows: make(map[string]map[int64]int)}
+}
+
+func (s *memoryStoreT) add(key string, bucket int64, noOfBuckets, events, limit int, _ time.Duration) ([]int, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	window, ok := s.windows[key]
+	if !ok {
+		window = make(map[int64]int)
+		s.windows[key] = window
+	}
+	oldestBucket := bucket - int64(noOfBuckets) + 1
+	counts := make([]int, noOfBuckets)
+	var total int
+	for b, count := range window {
+		if b < oldestBucket {
+			delete(window, b)
+			continue
+		}
+		if b <= bucket {
+			counts[b-oldestBucket] = count
+			total += count
+		}
+	}
+	if total+events > limit {
+		return counts, false, nil
+	}
+	window[bucket] += events
+	counts[noOfBuckets-1] += events
+	return counts, true, nil
+}
+
+//addScript adds events to the hash of the window of a key, with a field for the count of every bucket.
+//Keeping the whole window in a single key makes it safe for redis clusters.
+var addScript = redis.NewScript(`
+local key = KEYS[1]
+local bucket = tonumber(ARGV[1])
+local noOfBuckets = tonumber(ARGV[2])
+local events = tonumber(ARGV[3])
+local limit = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local oldestBucket = bucket - noOfBuckets + 1
+local counts = {}
+for i = 1, noOfBuckets do
+	counts[i] = 0
+end
+local total = 0
+local fields = redis.call('HGETALL', key)
+for i = 1, #fields, 2 do
+	local b = tonumber(fields[i])
+	if b < oldestBucket then
+		redis.call('HDEL', key, fields[i])
+	elseif b <= bucket then
+		local count = tonumber(fields[i + 1])
+		counts[b - oldestBucket + 1] = count
+		total = total + count
+	end
+end
+
+local added = 0
+if total + events <= limit then
+	redis.call('HINCRBY', key, bucket, events)
+	redis.call('EXPIRE', key, ttl)
+	counts[noOfBuckets] = counts[noOfBuckets] + events
+	added = 1
+end
+table.insert(counts, added)
+return counts
+`)
+
+type redisStoreT struct {
+	client redis.UniversalClient
+}
+
+func newRedisStore(client redis.UniversalClient) *redisStoreT {
+	return &redisStoreT{client: client}
+}
+
+func (s *redisStoreT) add(key string, bucket int64, noOfBuckets, events, limit int, ttl time.Duration) ([]int, bool, error) {
+	res, err := addScript.Run(s.client, []string{"rudder_ratelimit:" + key}, bucket, noOfBuckets, events, limit, int64(ttl.Seconds())).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	values, ok := res.([]interface{})
+	if !ok || len(values) != noOfBuckets+1 {
+		return nil, false, fmt.Errorf("unexpected response of rate limit script: %v", res)
+	}
+	counts := make([]int, noOfBuckets)
+	for i := range counts {
+		count, ok := values[i].(int64)
+		if !ok {
+			return nil, false, fmt.Errorf("unexpected count in response of rate limit script: %v", values[i])
+		}
+		counts[i] = int(count)
+	}
+	added, _ := values[noOfBuckets].(int64)
+	return counts, added == 1, nil
+}
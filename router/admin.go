@@ -54,7 +54,7 @@ func (ra *Admin) Status() interface{} {
 		abortedUsersMap := make(map[string]int, 0)
 		for _, worker := range router.workers {
 			worker.abortedUserMutex.RLock()
-			for k, v := range worker.abortedUserIDMap {
+			for k, v := range worker.abortedOrderingKeyMap {
 				abortedUsersMap[k] = v
 			}
 			worker.abortedUserMutex.RUnlock()
@@ -62,6 +62,9 @@ func (ra *Admin) Status() interface{} {
 		if len(abortedUsersMap) > 0 {
 			routerStatus["aborted-usersmap"] = abortedUsersMap
 		}
+		if router.concurrencyLimiter.IsEnabled() {
+			routerStatus["concurrency-limits"] = router.concurrencyLimiter.Status()
+		}
 
 		statusList = append(statusList, routerStatus)
 	}
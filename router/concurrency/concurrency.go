@@ -0,0 +1,146 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var pkgLogger logger.LoggerI
+
+//HandleT keeps an adaptive concurrency limiter per destination of a destination type.
+//It is configured by Router.<destType>.concurrencyLimiter.* falling back to Router.concurrencyLimiter.*
+//
+//Every worker of the router sends one request at a time, so the limit of a destination is at most the number of workers
+//of the router, which is also the default maxLimit.
+type HandleT struct {
+	destinationName string
+	enabled         bool
+	settings        SettingsT
+
+	limitersMu sync.RWMutex
+	limiters   map[string]*Limiter
+	limitStats map[string]stats.RudderStats
+}
+
+func (handle *HandleT) keys(key string) []string {
+	return []string{fmt.Sprintf(`Router.%s.concurrencyLimiter.%s`, handle.destinationName, key), fmt.Sprintf(`Router.concurrencyLimiter.%s`, key)}
+}
+
+//SetUp reads the settings of the limiters of the destination type, whose router has noOfWorkers workers
+func (handle *HandleT) SetUp(destName string, noOfWorkers int) {
+	pkgLogger = logger.NewLogger().Child("router").Child("concurrency")
+	handle.destinationName = destName
+	handle.limiters = map[string]*Limiter{}
+	handle.limitStats = map[string]stats.RudderStats{}
+
+	config.RegisterBoolConfigVariable(false, &handle.enabled, false, handle.keys("enabled")...)
+	config.RegisterStringConfigVariable(AIMD, &handle.settings.Algorithm, false, handle.keys("algorithm")...)
+	config.RegisterIntConfigVariable(20, &handle.settings.InitialLimit, false, 1, handle.keys("initialLimit")...)
+	config.RegisterIntConfigVariable(1, &handle.settings.MinLimit, false, 1, handle.keys("minLimit")...)
+	config.RegisterIntConfigVariable(noOfWorkers, &handle.settings.MaxLimit, false, 1, handle.keys("maxLimit")...)
+	config.RegisterFloat64ConfigVariable(0.9, &handle.settings.BackoffRatio, false, handle.keys("backoffRatio")...)
+	config.RegisterDurationConfigVariable(5, &handle.settings.LatencyThreshold, false, time.Second, handle.keys("latencyThreshold")...)
+
+	if handle.settings.Algorithm != AIMD && handle.settings.Algorithm != Vegas {
+		pkgLogger.Errorf(`[[ %s-router-concurrency-limiter: Invalid algorithm %q, using %s]]`, destName, handle.settings.Algorithm, AIMD)
+		handle.settings.Algorithm = AIMD
+	}
+	if handle.settings.BackoffRatio <= 0 || handle.settings.BackoffRatio >= 1 {
+		pkgLogger.Errorf(`[[ %s-router-concurrency-limiter: Invalid backoffRatio %v, using 0.9]]`, destName, handle.settings.BackoffRatio)
+		handle.settings.BackoffRatio = 0.9
+	}
+	if handle.settings.MaxLimit > noOfWorkers {
+		pkgLogger.Errorf(`[[ %s-router-concurrency-limiter: maxLimit %d is over the %d workers of the router, using %d]]`, destName, handle.settings.MaxLimit, noOfWorkers, noOfWorkers)
+		handle.settings.MaxLimit = noOfWorkers
+	}
+	if handle.settings.MaxLimit < handle.settings.MinLimit {
+		handle.settings.MaxLimit = handle.settings.MinLimit
+	}
+	if handle.enabled {
+		pkgLogger.Infof(`[[ %s-router-concurrency-limiter: Enabled %s limiter with limits %d-%d]]`, destName, handle.settings.Algorithm, handle.settings.MinLimit, handle.settings.MaxLimit)
+	}
+}
+
+//IsEnabled returns whether the requests to the destinations are limited
+func (handle *HandleT) IsEnabled() bool {
+	return handle != nil && handle.enabled
+}
+
+func (handle *HandleT) limiter(destID string) *Limiter {
+	handle.limitersMu.RLock()
+	l, ok := handle.limiters[destID]
+	handle.limitersMu.RUnlock()
+	if ok {
+		return l
+	}
+
+	handle.limitersMu.Lock()
+	defer handle.limitersMu.Unlock()
+	if l, ok = handle.limiters[destID]; !ok {
+		l = NewLimiter(handle.settings)
+		handle.limiters[destID] = l
+		handle.limitStats[destID] = stats.NewTaggedStat("router_concurrency_limit", stats.GaugeType, stats.Tags{
+			"destType": handle.destinationName,
+			"destId":   destID,
+		})
+	}
+	return l
+}
+
+//HasCapacity returns true if a request can be sent to the destination now, on top of the pending ones about to be acquired,
+//which it always can if the limiter is disabled
+func (handle *HandleT) HasCapacity(destID string, pending int) bool {
+	if !handle.IsEnabled() {
+		return true
+	}
+	return handle.limiter(destID).HasCapacity(pending)
+}
+
+//TryAcquire returns true if a request can be sent to the destination now, which it always can if the limiter is disabled.
+//Every acquired request must be released with Release
+func (handle *HandleT) TryAcquire(destID string) bool {
+	if !handle.IsEnabled() {
+		return true
+	}
+	return handle.limiter(destID).TryAcquire()
+}
+
+//Acquire waits until a request can be sent to the destination, or until ctx is done. It doesn't wait if the limiter is disabled.
+//Every acquired request must be released with Release
+func (handle *HandleT) Acquire(ctx context.Context, destID string) error {
+	if !handle.IsEnabled() {
+		return nil
+	}
+	return handle.limiter(destID).Acquire(ctx)
+}
+
+//Release marks a request acquired for the destination as done with the outcome
+func (handle *HandleT) Release(destID string, outcome OutcomeT) {
+	if !handle.IsEnabled() {
+		return
+	}
+	l := handle.limiter(destID)
+	l.Release(outcome)
+
+	handle.limitersMu.RLock()
+	limitStat := handle.limitStats[destID]
+	handle.limitersMu.RUnlock()
+	limitStat.Gauge(l.Status().Limit)
+}
+
+//Status returns the state of the limiters by destination id
+func (handle *HandleT) Status() map[string]LimitStatusT {
+	handle.limitersMu.RLock()
+	defer handle.limitersMu.RUnlock()
+	status := make(map[string]LimitStatusT, len(handle.limiters))
+	for destID, l := range handle.limiters {
+		status[destID] = l.Status()
+	}
+	return status
+}
@@ -0,0 +1,206 @@
+package concurrency
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	//AIMD increases the limit by one while the destination responds in time and without errors,
+	//and multiplies it by the backoff ratio on 429s, 5xxs and responses slower than the latency threshold
+	AIMD = "aimd"
+	//Vegas estimates the requests queued at the destination from the latency over the lowest latency seen,
+	//growing the limit while the queue is short and shrinking it when the queue grows or the destination errors
+	Vegas = "vegas"
+)
+
+//vegasMinLatencyResetSamples is the number of responses after which the lowest latency seen is probed again,
+//so that a destination getting slower for good doesn't keep its limit down
+const vegasMinLatencyResetSamples = 1000
+
+//OutcomeT is the outcome of a request to a destination
+type OutcomeT struct {
+	StatusCode int
+	Latency    time.Duration
+	//RetryAfter is the wait the destination asked for in its Retry-After header
+	RetryAfter time.Duration
+}
+
+func (outcome OutcomeT) failed() bool {
+	return outcome.StatusCode == 429 || outcome.StatusCode >= 500
+}
+
+//SettingsT are the settings of the limiters of the destinations of a destination type
+type SettingsT struct {
+	Algorithm        string
+	InitialLimit     int
+	MinLimit         int
+	MaxLimit         int
+	BackoffRatio     float64
+	LatencyThreshold time.Duration
+}
+
+//Limiter adapts the number of in-flight requests allowed to a destination
+type Limiter struct {
+	settings SettingsT
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	//pausedUntil is when the destination asked to be retried with a Retry-After header
+	pausedUntil time.Time
+	minLatency  time.Duration
+	samples     int
+	//released is closed on every release, waking up the requests waiting to be acquired
+	released chan struct{}
+}
+
+//LimitStatusT is the state of a limiter
+type LimitStatusT struct {
+	Algorithm   string     `json:"algorithm"`
+	Limit       int        `json:"limit"`
+	InFlight    int        `json:"inFlight"`
+	MinLatency  string     `json:"minLatency,omitempty"`
+	PausedUntil *time.Time `json:"pausedUntil,omitempty"`
+}
+
+//NewLimiter returns a limiter with the settings, starting at their initial limit
+func NewLimiter(settings SettingsT) *Limiter {
+	l := &Limiter{settings: settings, released: make(chan struct{})}
+	l.limit = l.clamp(float64(settings.InitialLimit))
+	return l
+}
+
+func (l *Limiter) clamp(limit float64) float64 {
+	return math.Max(float64(l.settings.MinLimit), math.Min(float64(l.settings.MaxLimit), limit))
+}
+
+//HasCapacity returns true if a request can be sent to the destination now, on top of the pending ones about to be acquired.
+//It doesn't count a request as in flight
+func (l *Limiter) HasCapacity(pending int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !time.Now().Before(l.pausedUntil) && l.inFlight+pending < int(l.limit)
+}
+
+//TryAcquire returns true, counting the request as in flight, if a request can be sent to the destination now.
+//It doesn't wait, requests which can't be sent are expected to be retried later
+func (l *Limiter) TryAcquire() bool {
+	acquired, _, _ := l.tryAcquire()
+	return acquired
+}
+
+//tryAcquire is TryAcquire, also returning the channel closed on the next release and the pause of the destination left
+func (l *Limiter) tryAcquire() (acquired bool, released <-chan struct{}, paused time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if paused = time.Until(l.pausedUntil); paused > 0 || l.inFlight >= int(l.limit) {
+		return false, l.released, paused
+	}
+	l.inFlight++
+	return true, nil, 0
+}
+
+//Acquire waits until a request can be sent to the destination, counting it as in flight, or until ctx is done
+func (l *Limiter) Acquire(ctx context.Context) error {
+	for {
+		acquired, released, paused := l.tryAcquire()
+		if acquired {
+			return nil
+		}
+		var pauseEnded <-chan time.Time
+		if paused > 0 {
+			pauseEnded = time.After(paused)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		case <-pauseEnded:
+		}
+	}
+}
+
+//Release marks an acquired request as done, adapting the limit to its outcome
+func (l *Limiter) Release(outcome OutcomeT) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inFlight := l.inFlight
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+
+	if outcome.RetryAfter > 0 {
+		if pausedUntil := time.Now().Add(outcome.RetryAfter); pausedUntil.After(l.pausedUntil) {
+			l.pausedUntil = pausedUntil
+		}
+	}
+
+	switch l.settings.Algorithm {
+	case Vegas:
+		l.limit = l.clamp(l.vegasLimit(outcome))
+	default:
+		l.limit = l.clamp(l.aimdLimit(outcome, inFlight))
+	}
+}
+
+func (l *Limiter) aimdLimit(outcome OutcomeT, inFlight int) float64 {
+	if outcome.failed() || (l.settings.LatencyThreshold > 0 && outcome.Latency > l.settings.LatencyThreshold) {
+		return l.limit * l.settings.BackoffRatio
+	}
+	//only grow the limit while it is being used, otherwise it would grow unbounded when traffic is low
+	if float64(inFlight)*2 >= l.limit {
+		return l.limit + 1
+	}
+	return l.limit
+}
+
+func (l *Limiter) vegasLimit(outcome OutcomeT) float64 {
+	if outcome.failed() {
+		return l.limit * l.settings.BackoffRatio
+	}
+	l.samples++
+	if l.samples > vegasMinLatencyResetSamples {
+		l.samples, l.minLatency = 0, 0
+	}
+	if outcome.Latency <= 0 {
+		return l.limit
+	}
+	if l.minLatency == 0 || outcome.Latency < l.minLatency {
+		l.minLatency = outcome.Latency
+	}
+
+	logLimit := math.Max(1, math.Log10(l.limit))
+	alpha, beta := 3*logLimit, 6*logLimit
+	queueSize := math.Ceil(l.limit * (1 - float64(l.minLatency)/float64(outcome.Latency)))
+	switch {
+	case queueSize <= logLimit:
+		return l.limit + beta
+	case queueSize < alpha:
+		return l.limit + logLimit
+	case queueSize > beta:
+		return l.limit - logLimit
+	}
+	return l.limit
+}
+
+//Status returns the current state of the limiter
+func (l *Limiter) Status() LimitStatusT {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	status := LimitStatusT{
+		Algorithm: l.settings.Algorithm,
+		Limit:     int(l.limit),
+		InFlight:  l.inFlight,
+	}
+	if l.minLatency > 0 {
+		status.MinLatency = l.minLatency.String()
+	}
+	if time.Now().Before(l.pausedUntil) {
+		pausedUntil := l.pausedUntil
+		status.PausedUntil = &pausedUntil
+	}
+	return status
+}
@@ -0,0 +1,138 @@
+package concurrency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var settings = SettingsT{
+	Algorithm:        AIMD,
+	InitialLimit:     4,
+	MinLimit:         1,
+	MaxLimit:         10,
+	BackoffRatio:     0.5,
+	LatencyThreshold: time.Second,
+}
+
+func acquire(t *testing.T, l *Limiter, n int) {
+	for i := 0; i < n; i++ {
+		require.True(t, l.TryAcquire())
+	}
+}
+
+func TestLimiterAIMD(t *testing.T) {
+	l := NewLimiter(settings)
+	acquire(t, l, 4)
+	require.False(t, l.TryAcquire(), "the limit is reached")
+
+	l.Release(OutcomeT{StatusCode: 200, Latency: 100 * time.Millisecond})
+	require.Equal(t, 5, l.Status().Limit, "the limit grows while it is used")
+	require.Equal(t, 3, l.Status().InFlight)
+
+	l.Release(OutcomeT{StatusCode: 503})
+	require.Equal(t, 2, l.Status().Limit, "the limit shrinks on errors")
+	l.Release(OutcomeT{StatusCode: 200, Latency: 2 * time.Second})
+	require.Equal(t, 1, l.Status().Limit, "the limit shrinks on slow responses")
+	l.Release(OutcomeT{StatusCode: 429})
+	require.Equal(t, 1, l.Status().Limit, "the limit doesn't go below the min limit")
+
+	acquire(t, l, 1)
+	l.Release(OutcomeT{StatusCode: 200})
+	require.Equal(t, 2, l.Status().Limit)
+	for i := 0; i < 20; i++ {
+		acquire(t, l, 1)
+		l.Release(OutcomeT{StatusCode: 200})
+	}
+	require.Equal(t, 3, l.Status().Limit, "the limit doesn't grow past twice the requests in flight")
+}
+
+func TestLimiterVegas(t *testing.T) {
+	vegasSettings := settings
+	vegasSettings.Algorithm = Vegas
+	vegasSettings.MaxLimit = 100
+	l := NewLimiter(vegasSettings)
+
+	acquire(t, l, 2)
+	l.Release(OutcomeT{StatusCode: 200, Latency: 100 * time.Millisecond})
+	require.Equal(t, 10, l.Status().Limit, "the limit grows while nothing is queued at the destination")
+	require.Equal(t, "100ms", l.Status().MinLatency)
+
+	l.Release(OutcomeT{StatusCode: 200, Latency: time.Second})
+	require.Equal(t, 9, l.Status().Limit, "the limit shrinks when requests queue at the destination")
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	l := NewLimiter(settings)
+	acquire(t, l, 1)
+	l.Release(OutcomeT{StatusCode: 429, RetryAfter: time.Minute})
+	require.NotNil(t, l.Status().PausedUntil)
+	require.WithinDuration(t, time.Now().Add(time.Minute), *l.Status().PausedUntil, time.Second)
+	require.False(t, l.TryAcquire(), "no request is sent while paused")
+
+	l = NewLimiter(settings)
+	acquire(t, l, 1)
+	l.Release(OutcomeT{StatusCode: 503, RetryAfter: 20 * time.Millisecond})
+	require.Eventually(t, l.TryAcquire, time.Second, 5*time.Millisecond, "requests are sent after the pause")
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	l := NewLimiter(settings)
+	require.True(t, l.HasCapacity(3))
+	require.False(t, l.HasCapacity(4), "pending requests count against the limit")
+	acquire(t, l, 4)
+	require.False(t, l.HasCapacity(0))
+
+	acquired := make(chan error)
+	go func() { acquired <- l.Acquire(context.Background()) }()
+	select {
+	case <-acquired:
+		require.FailNow(t, "acquired over the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	l.Release(OutcomeT{StatusCode: 200, Latency: 100 * time.Millisecond})
+	require.NoError(t, <-acquired, "waiting requests are acquired once one is released")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, NewLimiter(SettingsT{Algorithm: AIMD, InitialLimit: 0, MinLimit: 0}).Acquire(ctx), context.Canceled)
+
+	l = NewLimiter(settings)
+	acquire(t, l, 1)
+	l.Release(OutcomeT{StatusCode: 429, RetryAfter: 20 * time.Millisecond})
+	require.False(t, l.HasCapacity(0), "no request is sent while paused")
+	require.NoError(t, l.Acquire(context.Background()), "waiting requests are acquired after the pause")
+}
+
+func TestHandleLimitsToWorkers(t *testing.T) {
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	t.Setenv(config.TransformKey("Router.WEBHOOK.concurrencyLimiter.enabled"), "true")
+	t.Setenv(config.TransformKey("Router.WEBHOOK.concurrencyLimiter.initialLimit"), "2")
+
+	handle := &HandleT{}
+	handle.SetUp("WEBHOOK", 4)
+	require.Equal(t, 4, handle.settings.MaxLimit, "the max limit defaults to the workers of the router")
+
+	t.Setenv(config.TransformKey("Router.WEBHOOK.concurrencyLimiter.maxLimit"), "200")
+	handle = &HandleT{}
+	handle.SetUp("WEBHOOK", 4)
+	require.Equal(t, 4, handle.settings.MaxLimit, "the max limit can't be over the workers of the router")
+
+	require.True(t, handle.TryAcquire("destination"))
+	require.True(t, handle.TryAcquire("destination"))
+	require.False(t, handle.TryAcquire("destination"), "requests over the limit are not waited for")
+	handle.Release("destination", OutcomeT{StatusCode: 200})
+	require.True(t, handle.TryAcquire("destination"))
+
+	disabled := &HandleT{}
+	require.True(t, disabled.TryAcquire("destination"), "requests are not limited while the limiter is disabled")
+	disabled.Release("destination", OutcomeT{StatusCode: 200})
+}
@@ -24,12 +24,10 @@ const (
 )
 
 var (
-	ObjectStreamDestinations []string
-	KVStoreDestinations      []string
-	Destinations             []string
-	customManagerMap         map[string]*CustomManagerT
-	pkgLogger                logger.LoggerI
-	disableEgress            bool
+	KVStoreDestinations []string
+	customManagerMap    map[string]*CustomManagerT
+	pkgLogger           logger.LoggerI
+	disableEgress       bool
 )
 
 // DestinationManager implements the method to send the events to custom destinations
@@ -60,9 +58,7 @@ func Init() {
 }
 
 func loadConfig() {
-	ObjectStreamDestinations = []string{"KINESIS", "KAFKA", "AZURE_EVENT_HUB", "FIREHOSE", "EVENTBRIDGE", "GOOGLEPUBSUB", "CONFLUENT_CLOUD", "PERSONALIZE", "GOOGLESHEETS", "BQSTREAM"}
 	KVStoreDestinations = []string{"REDIS"}
-	Destinations = append(ObjectStreamDestinations, KVStoreDestinations...)
 	customManagerMap = make(map[string]*CustomManagerT)
 	config.RegisterBoolConfigVariable(false, &disableEgress, false, "disableEgress")
 }
@@ -76,11 +72,15 @@ func (customManager *CustomManagerT) newClient(destID string) error {
 
 	switch customManager.managerType {
 	case STREAM:
-		var producer interface{}
+		var producer streammanager.Producer
 		producer, err = streammanager.NewProducer(destConfig, customManager.destType, streammanager.Opts{
 			Timeout: customManager.timeout,
 		})
 		if err == nil {
+			if err = streammanager.HealthCheck(producer); err != nil {
+				producer.Close()
+				return fmt.Errorf("health check failed: %w", err)
+			}
 			customDestination = &CustomDestination{
 				Config: destConfig,
 				Client: producer,
@@ -105,7 +105,8 @@ func (customManager *CustomManagerT) send(jsonData json.RawMessage, destType str
 	var respBody string
 	switch customManager.managerType {
 	case STREAM:
-		statusCode, _, respBody = streammanager.Produce(jsonData, destType, client, config)
+		producer, _ := client.(streammanager.Producer)
+		statusCode, _, respBody = producer.Produce(jsonData, config)
 	case KV:
 		kvManager, _ := client.(kvstoremanager.KVStoreManager)
 
@@ -174,7 +175,8 @@ func (customManager *CustomManagerT) close(destination backendconfig.Destination
 	customDestination := customManager.destinationsMap[destID]
 	switch customManager.managerType {
 	case STREAM:
-		streammanager.CloseProducer(customDestination.Client, customManager.destType)
+		producer, _ := customDestination.Client.(streammanager.Producer)
+		producer.Close()
 	case KV:
 		kvManager, _ := customDestination.Client.(kvstoremanager.KVStoreManager)
 		kvManager.Close()
@@ -190,7 +192,8 @@ func (customManager *CustomManagerT) refreshClient(destID string) error {
 		pkgLogger.Infof("[CDM %s] [Token Expired] Closing Existing client for destination id: %s", customManager.destType, destID)
 		switch customManager.managerType {
 		case STREAM:
-			streammanager.CloseProducer(customDestination.Client, customManager.destType)
+			producer, _ := customDestination.Client.(streammanager.Producer)
+			producer.Close()
 		case KV:
 			kvManager, _ := customDestination.Client.(kvstoremanager.KVStoreManager)
 			kvManager.Close()
@@ -235,12 +238,13 @@ type Opts struct {
 	Timeout time.Duration
 }
 
-// New returns CustomdestinationManager
+// New returns CustomdestinationManager, for key value store destinations and the stream destinations registered in streammanager
 func New(destType string, o Opts) DestinationManager {
-	if misc.ContainsString(Destinations, destType) {
+	isKVStore := misc.ContainsString(KVStoreDestinations, destType)
+	if isKVStore || streammanager.IsRegistered(destType) {
 
 		managerType := STREAM
-		if misc.ContainsString(KVStoreDestinations, destType) {
+		if isKVStore {
 			managerType = KV
 		}
 
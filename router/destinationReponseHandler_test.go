@@ -69,6 +69,20 @@ var _ = Describe("DestinationReponseHandler", func() {
 			status := jsonHandler.IsSuccessStatus(200, body)
 			Expect(status).To(Equal(200))
 		})
+		It("matches the body of 2xx responses with statusCode rules", func() {
+			config := `{
+			"responseType": "JSON",
+			"rules": {
+			"abortable": [{ "statusCode": 200 }]
+			}
+			}`
+			if err := json.Unmarshal([]byte(config), &rules); err != nil {
+				fmt.Println(err)
+			}
+			jsonHandler = router.New(rules)
+			status := jsonHandler.IsSuccessStatus(200, `{"statusCode": 200}`)
+			Expect(status).To(Equal(400))
+		})
 		It("when responseType is not JSON/TXT, handler will be nil", func() {
 			_ = body
 			config := `{
@@ -3,6 +3,7 @@
 package router
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -10,9 +11,9 @@ import (
 	"io"
 	"mime"
 	"net/http"
-	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rudderlabs/rudder-server/processor/integrations"
@@ -32,6 +33,12 @@ func init() {
 type NetHandleT struct {
 	httpClient sysUtils.HTTPClientI
 	logger     logger.LoggerI
+
+	//transport and timeout of httpClient, which the clients of mtls destinations are built from
+	transport   *http.Transport
+	timeout     time.Duration
+	mtlsClients map[string]mtlsClientT
+	mtlsLock    sync.Mutex
 }
 
 //Network interface
@@ -68,161 +75,149 @@ func (network *NetHandleT) SendPost(ctx context.Context, structData integrations
 	}
 	client := network.httpClient
 	postInfo := structData
-	isRest := postInfo.Type == "REST"
-
-	isMultipart := len(postInfo.Files) > 0
+	auth := requestAuthFrom(ctx)
+	if auth != nil && auth.err != nil {
+		network.logger.Errorf(`500 Unable to authenticate request for URL : "%s": %v`, postInfo.URL, auth.err)
+		return &utils.SendPostResponse{
+			StatusCode:   http.StatusInternalServerError,
+			ResponseBody: []byte(fmt.Sprintf("500 %v", auth.err)),
+		}
+	}
 
-	// going forward we may want to support GraphQL and multipart requests
 	// the files key in the response is specifically to handle the multipart usecase
 	// for type GraphQL may need to support more keys like expected response format etc
 	// in future it's expected that we will build on top of this response type
 	// so, code addition should be done here instead of version bumping of response.
-	if isRest && !isMultipart {
-		requestMethod := postInfo.RequestMethod
-		requestBody := postInfo.Body
-		requestQueryParams := postInfo.QueryParams
-		var bodyFormat string
-		var bodyValue map[string]interface{}
-		for k, v := range requestBody {
-			if len(v.(map[string]interface{})) > 0 {
-				bodyFormat = k
-				bodyValue = v.(map[string]interface{})
-				break
-			}
-
+	body, err := requestBody(postInfo)
+	if err != nil {
+		network.logger.Errorf(`400 Unable to construct request body for URL : "%s": %v`, postInfo.URL, err)
+		return &utils.SendPostResponse{
+			StatusCode:   400,
+			ResponseBody: []byte(fmt.Sprintf("400 %v", err)),
 		}
+	}
 
-		var payload io.Reader
-		// support for JSON and FORM body type
-		if len(bodyValue) > 0 {
-			switch bodyFormat {
-			case "JSON":
-				jsonValue, err := json.Marshal(bodyValue)
-				if err != nil {
-					panic(err)
-				}
-				payload = strings.NewReader(string(jsonValue))
-			case "JSON_ARRAY":
-				// support for JSON ARRAY
-				jsonListStr, ok := bodyValue["batch"].(string)
-				if !ok {
-					return &utils.SendPostResponse{
-						StatusCode:   400,
-						ResponseBody: []byte("400 Unable to parse json list. Unexpected transformer response"),
-					}
-				}
-				payload = strings.NewReader(jsonListStr)
-			case "XML":
-				strValue, ok := bodyValue["payload"].(string)
-				if !ok {
-					return &utils.SendPostResponse{
-						StatusCode:   400,
-						ResponseBody: []byte("400 Unable to construct xml payload. Unexpected transformer response"),
-					}
-				}
-				payload = strings.NewReader(strValue)
-			case "FORM":
-				formValues := url.Values{}
-				for key, val := range bodyValue {
-					formValues.Set(key, fmt.Sprint(val)) // transformer ensures top level string values, still val.(string) would be restrictive
-				}
-				payload = strings.NewReader(formValues.Encode())
-			default:
-				panic(fmt.Errorf("bodyFormat: %s is not supported", bodyFormat))
-			}
-		}
+	requestMethod := postInfo.RequestMethod
+	if requestMethod == "" && strings.ToUpper(postInfo.Type) == requestTypeGraphQL {
+		requestMethod = http.MethodPost
+	}
+	requestQueryParams := postInfo.QueryParams
 
-		req, err := http.NewRequestWithContext(ctx, requestMethod, postInfo.URL, payload)
-		if err != nil {
-			network.logger.Error(fmt.Sprintf(`400 Unable to construct "%s" request for URL : "%s"`, requestMethod, postInfo.URL))
+	//the body is read before making the request, since signing needs it
+	var payload []byte
+	if body.payload != nil {
+		if payload, err = io.ReadAll(body.payload); err != nil {
 			return &utils.SendPostResponse{
 				StatusCode:   400,
-				ResponseBody: []byte(fmt.Sprintf(`400 Unable to construct "%s" request for URL : "%s"`, requestMethod, postInfo.URL)),
+				ResponseBody: []byte(fmt.Sprintf("400 Unable to read request body: %v", err)),
 			}
 		}
+	}
 
-		// add queryparams to the url
-		// support of array type in params is handled if the
-		// response from transformers are "," seperated
-		queryParams := req.URL.Query()
-		for key, val := range requestQueryParams {
-
-			// list := strings.Split(valString, ",")
-			// for _, listItem := range list {
-			// 	queryParams.Add(key, fmt.Sprint(listItem))
-			// }
-			formattedVal := handleQueryParam(val)
-			queryParams.Add(key, formattedVal)
+	req, err := http.NewRequestWithContext(ctx, requestMethod, postInfo.URL, bytes.NewReader(payload))
+	if err != nil {
+		network.logger.Error(fmt.Sprintf(`400 Unable to construct "%s" request for URL : "%s"`, requestMethod, postInfo.URL))
+		return &utils.SendPostResponse{
+			StatusCode:   400,
+			ResponseBody: []byte(fmt.Sprintf(`400 Unable to construct "%s" request for URL : "%s"`, requestMethod, postInfo.URL)),
 		}
+	}
 
-		req.URL.RawQuery = queryParams.Encode()
-		headerKV := postInfo.Headers
-		for key, val := range headerKV {
-			req.Header.Add(key, val.(string))
-		}
+	// add queryparams to the url
+	// support of array type in params is handled if the
+	// response from transformers are "," seperated
+	queryParams := req.URL.Query()
+	for key, val := range requestQueryParams {
 
-		req.Header.Add("User-Agent", "RudderLabs")
+		// list := strings.Split(valString, ",")
+		// for _, listItem := range list {
+		// 	queryParams.Add(key, fmt.Sprint(listItem))
+		// }
+		formattedVal := handleQueryParam(val)
+		queryParams.Add(key, formattedVal)
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return &utils.SendPostResponse{
-				StatusCode:   http.StatusGatewayTimeout,
-				ResponseBody: []byte(fmt.Sprintf(`504 Unable to make "%s" request for URL : "%s"`, requestMethod, postInfo.URL)),
-			}
+	req.URL.RawQuery = queryParams.Encode()
+	headerKV := postInfo.Headers
+	for key, val := range headerKV {
+		req.Header.Add(key, val.(string))
+	}
+	for key, val := range body.headers {
+		if body.overrideHeaders || req.Header.Get(key) == "" {
+			req.Header.Set(key, val)
 		}
+	}
 
-		defer resp.Body.Close()
+	req.Header.Add("User-Agent", "RudderLabs")
 
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return &utils.SendPostResponse{
-				StatusCode:   resp.StatusCode,
-				ResponseBody: []byte(fmt.Sprintf(`Failed to read response body for request for URL : "%s"`, postInfo.URL)),
+	if auth != nil {
+		//signing comes last, so that it covers the final headers and query params
+		now := time.Now()
+		for _, signer := range auth.signers {
+			if err := signer.sign(req, payload, now); err != nil {
+				network.logger.Errorf(`500 Unable to sign request for URL : "%s": %v`, postInfo.URL, err)
+				return &utils.SendPostResponse{
+					StatusCode:   http.StatusInternalServerError,
+					ResponseBody: []byte(fmt.Sprintf("500 Unable to sign request: %v", err)),
+				}
 			}
 		}
-		network.logger.Debug(postInfo.URL, " : ", req.Proto, " : ", resp.Proto, resp.ProtoMajor, resp.ProtoMinor, resp.ProtoAtLeast)
-
-		var contentTypeHeader string
-		if resp != nil && resp.Header != nil {
-			contentTypeHeader = resp.Header.Get("Content-Type")
-		}
-		if contentTypeHeader == "" {
-			//Detecting content type of the respBody
-			contentTypeHeader = http.DetectContentType(respBody)
+		if auth.tlsConfig != nil {
+			client = network.mtlsClient(auth)
 		}
-		mediaType, _, _ := mime.ParseMediaType(contentTypeHeader)
+	}
 
-		// If media type is not in some human readable format (text,json,xml), override the response with an empty string
-		// https://www.iana.org/assignments/media-types/media-types.xhtml
-		isHumanReadable := contentTypeRegex.MatchString(mediaType)
-		if !isHumanReadable {
-			respBody = []byte("redacted due to unsupported content-type")
+	resp, err := client.Do(req)
+	if err != nil {
+		return &utils.SendPostResponse{
+			StatusCode:   http.StatusGatewayTimeout,
+			ResponseBody: []byte(fmt.Sprintf(`504 Unable to make "%s" request for URL : "%s"`, requestMethod, postInfo.URL)),
 		}
+	}
 
-		if err != nil {
-			network.logger.Error("Errored when sending request to the server", err)
-			return &utils.SendPostResponse{
-				StatusCode:          http.StatusGatewayTimeout,
-				ResponseBody:        respBody,
-				ResponseContentType: contentTypeHeader,
-			}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &utils.SendPostResponse{
+			StatusCode:   resp.StatusCode,
+			ResponseBody: []byte(fmt.Sprintf(`Failed to read response body for request for URL : "%s"`, postInfo.URL)),
 		}
+	}
+	network.logger.Debug(postInfo.URL, " : ", req.Proto, " : ", resp.Proto, resp.ProtoMajor, resp.ProtoMinor, resp.ProtoAtLeast)
+
+	var contentTypeHeader string
+	if resp != nil && resp.Header != nil {
+		contentTypeHeader = resp.Header.Get("Content-Type")
+	}
+	if contentTypeHeader == "" {
+		//Detecting content type of the respBody
+		contentTypeHeader = http.DetectContentType(respBody)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentTypeHeader)
+
+	// If media type is not in some human readable format (text,json,xml), override the response with an empty string
+	// https://www.iana.org/assignments/media-types/media-types.xhtml
+	isHumanReadable := contentTypeRegex.MatchString(mediaType)
+	if !isHumanReadable {
+		respBody = []byte("redacted due to unsupported content-type")
+	}
 
+	if err != nil {
+		network.logger.Error("Errored when sending request to the server", err)
 		return &utils.SendPostResponse{
-			StatusCode:          resp.StatusCode,
+			StatusCode:          http.StatusGatewayTimeout,
 			ResponseBody:        respBody,
 			ResponseContentType: contentTypeHeader,
 		}
 	}
 
-	// returning 200 with a message in case of unsupported processing
-	// so that we don't process again. can change this code to anything
-	// to be not picked up by router again
 	return &utils.SendPostResponse{
-		StatusCode:   200,
-		ResponseBody: []byte{},
+		StatusCode:          resp.StatusCode,
+		ResponseBody:        respBody,
+		ResponseContentType: contentTypeHeader,
+		RetryAfter:          utils.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
 	}
-
 }
 
 //Setup initializes the module
@@ -257,4 +252,59 @@ func (network *NetHandleT) Setup(destID string, netClientTimeout time.Duration)
 	network.logger.Info("defaultTransportCopy.MaxIdleConnsPerHost: ", defaultTransportCopy.MaxIdleConnsPerHost)
 	network.logger.Info("netClientTimeout: ", netClientTimeout)
 	network.httpClient = &http.Client{Transport: &defaultTransportCopy, Timeout: netClientTimeout}
+	network.transport = &defaultTransportCopy
+	network.timeout = netClientTimeout
+}
+
+type mtlsClientT struct {
+	configHash string
+	client     *http.Client
+}
+
+//mtlsClientsEvicterI is implemented by network handles caching the clients of mtls destinations
+type mtlsClientsEvicterI interface {
+	evictMTLSClients(auths map[string]*requestAuthT)
+}
+
+//evictMTLSClients closes and forgets the clients of destinations which no longer have the same mtls config
+func (network *NetHandleT) evictMTLSClients(auths map[string]*requestAuthT) {
+	network.mtlsLock.Lock()
+	defer network.mtlsLock.Unlock()
+	for destinationID, cached := range network.mtlsClients {
+		if auth, ok := auths[destinationID]; ok && auth.tlsConfig != nil && auth.configHash == cached.configHash {
+			continue
+		}
+		cached.client.CloseIdleConnections()
+		delete(network.mtlsClients, destinationID)
+	}
+}
+
+//mtlsClient returns the client presenting the client certificate of the auth, creating it on first use and when the auth config changes
+func (network *NetHandleT) mtlsClient(auth *requestAuthT) sysUtils.HTTPClientI {
+	network.mtlsLock.Lock()
+	defer network.mtlsLock.Unlock()
+	cached, ok := network.mtlsClients[auth.destinationID]
+	if ok && cached.configHash == auth.configHash {
+		return cached.client
+	}
+	if ok {
+		cached.client.CloseIdleConnections()
+	}
+	if network.mtlsClients == nil {
+		network.mtlsClients = make(map[string]mtlsClientT)
+	}
+	var transport *http.Transport
+	if network.transport != nil {
+		transport = network.transport.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	tlsConfig := auth.tlsConfig.Clone()
+	if transport.TLSClientConfig != nil {
+		tlsConfig.NextProtos = transport.TLSClientConfig.NextProtos
+	}
+	transport.TLSClientConfig = tlsConfig
+	client := &http.Client{Transport: transport, Timeout: network.timeout}
+	network.mtlsClients[auth.destinationID] = mtlsClientT{configHash: auth.configHash, client: client}
+	return client
 }
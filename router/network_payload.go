@@ -0,0 +1,247 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/rudderlabs/rudder-server/processor/integrations"
+)
+
+//Request types of the transformer responses
+const (
+	requestTypeREST    = "REST"
+	requestTypeGraphQL = "GRAPHQL"
+)
+
+//requestBodyT is the body of a request to a destination, along with the headers describing it.
+//The headers are only set if the transformer response doesn't set them, unless overrideHeaders is set.
+type requestBodyT struct {
+	payload         io.Reader
+	headers         map[string]string
+	overrideHeaders bool
+}
+
+/*
+requestBody returns the body of the request of a transformer response.
+
+REST requests send the first non empty body format of the response:
+
+	JSON        the object as json
+	JSON_ARRAY  the json array in the batch string
+	XML         the xml in the payload string
+	FORM        the object url encoded
+	BINARY      the base64 encoded payload, with the optional contentType
+	GZIP        the payload string gzipped, with the optional contentType
+
+REST requests with files are sent as multipart/form-data, with the FORM or JSON body as fields and a part for every file.
+Files are either base64 encoded contents, or objects with the base64 encoded content and an optional filename and contentType.
+
+GRAPHQL requests send the JSON body, which must have a query, as json.
+*/
+func requestBody(postInfo integrations.PostParametersT) (requestBodyT, error) {
+	switch strings.ToUpper(postInfo.Type) {
+	case requestTypeREST:
+		format, value, err := bodyFormatOf(postInfo.Body)
+		if err != nil {
+			return requestBodyT{}, err
+		}
+		if len(postInfo.Files) > 0 {
+			return multipartBody(format, value, postInfo.Files)
+		}
+		return restBody(format, value)
+	case requestTypeGraphQL:
+		return graphQLBody(postInfo.Body)
+	default:
+		return requestBodyT{}, fmt.Errorf("Unsupported request type %q. Unexpected transformer response", postInfo.Type)
+	}
+}
+
+//bodyFormatOf returns the first non empty body format of the body of a transformer response
+func bodyFormatOf(body map[string]interface{}) (format string, value map[string]interface{}, err error) {
+	//iterating in order, so that responses with several non empty formats always send the same one
+	formats := make([]string, 0, len(body))
+	for k := range body {
+		formats = append(formats, k)
+	}
+	sort.Strings(formats)
+	for _, k := range formats {
+		v, ok := body[k].(map[string]interface{})
+		if !ok {
+			return "", nil, fmt.Errorf("Unable to parse body %s. Unexpected transformer response", k)
+		}
+		if len(v) > 0 {
+			return k, v, nil
+		}
+	}
+	return "", nil, nil
+}
+
+func restBody(bodyFormat string, bodyValue map[string]interface{}) (requestBodyT, error) {
+	if len(bodyValue) == 0 {
+		return requestBodyT{}, nil
+	}
+	switch bodyFormat {
+	case "JSON":
+		jsonValue, err := json.Marshal(bodyValue)
+		if err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to marshal json payload: %w", err)
+		}
+		return requestBodyT{payload: bytes.NewReader(jsonValue)}, nil
+	case "JSON_ARRAY":
+		// support for JSON ARRAY
+		jsonListStr, ok := bodyValue["batch"].(string)
+		if !ok {
+			return requestBodyT{}, fmt.Errorf("Unable to parse json list. Unexpected transformer response")
+		}
+		return requestBodyT{payload: strings.NewReader(jsonListStr)}, nil
+	case "XML":
+		strValue, ok := bodyValue["payload"].(string)
+		if !ok {
+			return requestBodyT{}, fmt.Errorf("Unable to construct xml payload. Unexpected transformer response")
+		}
+		return requestBodyT{payload: strings.NewReader(strValue)}, nil
+	case "FORM":
+		formValues := url.Values{}
+		for key, val := range bodyValue {
+			formValues.Set(key, fmt.Sprint(val)) // transformer ensures top level string values, still val.(string) would be restrictive
+		}
+		return requestBodyT{payload: strings.NewReader(formValues.Encode())}, nil
+	case "BINARY":
+		encoded, ok := bodyValue["payload"].(string)
+		if !ok {
+			return requestBodyT{}, fmt.Errorf("Unable to construct binary payload. Unexpected transformer response")
+		}
+		payload, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to decode base64 binary payload: %w", err)
+		}
+		return requestBodyT{payload: bytes.NewReader(payload), headers: map[string]string{"Content-Type": contentTypeOf(bodyValue, "application/octet-stream")}}, nil
+	case "GZIP":
+		strValue, ok := bodyValue["payload"].(string)
+		if !ok {
+			return requestBodyT{}, fmt.Errorf("Unable to construct gzip payload. Unexpected transformer response")
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write([]byte(strValue)); err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to gzip payload: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to gzip payload: %w", err)
+		}
+		return requestBodyT{payload: &buf, headers: map[string]string{"Content-Type": contentTypeOf(bodyValue, "application/json"), "Content-Encoding": "gzip"}}, nil
+	default:
+		return requestBodyT{}, fmt.Errorf("Unsupported body format %q. Unexpected transformer response", bodyFormat)
+	}
+}
+
+func contentTypeOf(bodyValue map[string]interface{}, defaultContentType string) string {
+	if contentType, ok := bodyValue["contentType"].(string); ok && contentType != "" {
+		return contentType
+	}
+	return defaultContentType
+}
+
+func multipartBody(bodyFormat string, bodyValue map[string]interface{}, files map[string]interface{}) (requestBodyT, error) {
+	if len(bodyValue) > 0 && bodyFormat != "FORM" && bodyFormat != "JSON" {
+		return requestBodyT{}, fmt.Errorf("Unsupported body format %q for multipart requests. Unexpected transformer response", bodyFormat)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, key := range sortedKeys(bodyValue) {
+		var value string
+		switch v := bodyValue[key].(type) {
+		case string:
+			value = v
+		default:
+			if bodyFormat == "FORM" {
+				value = fmt.Sprint(v)
+				break
+			}
+			jsonValue, err := json.Marshal(v)
+			if err != nil {
+				return requestBodyT{}, fmt.Errorf("Unable to marshal multipart field %s: %w", key, err)
+			}
+			value = string(jsonValue)
+		}
+		if err := writer.WriteField(key, value); err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to write multipart field %s: %w", key, err)
+		}
+	}
+
+	for _, key := range sortedKeys(files) {
+		filename, contentType, encoded := key, "application/octet-stream", ""
+		switch file := files[key].(type) {
+		case string:
+			encoded = file
+		case map[string]interface{}:
+			var ok bool
+			if encoded, ok = file["content"].(string); !ok {
+				return requestBodyT{}, fmt.Errorf("Unable to construct multipart file %s without content. Unexpected transformer response", key)
+			}
+			if name, ok := file["filename"].(string); ok && name != "" {
+				filename = name
+			}
+			contentType = contentTypeOf(file, contentType)
+		default:
+			return requestBodyT{}, fmt.Errorf("Unable to construct multipart file %s. Unexpected transformer response", key)
+		}
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to decode base64 content of multipart file %s: %w", key, err)
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(key), escapeQuotes(filename)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to write multipart file %s: %w", key, err)
+		}
+		if _, err := part.Write(content); err != nil {
+			return requestBodyT{}, fmt.Errorf("Unable to write multipart file %s: %w", key, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return requestBodyT{}, fmt.Errorf("Unable to write multipart payload: %w", err)
+	}
+	//the boundary of the payload is in the content type, so it always overrides the content type of the transformer response
+	return requestBodyT{payload: &buf, headers: map[string]string{"Content-Type": writer.FormDataContentType()}, overrideHeaders: true}, nil
+}
+
+func graphQLBody(body map[string]interface{}) (requestBodyT, error) {
+	jsonBody, _ := body["JSON"].(map[string]interface{})
+	if query, _ := jsonBody["query"].(string); query == "" {
+		return requestBodyT{}, fmt.Errorf("Unable to construct graphql request without a query. Unexpected transformer response")
+	}
+	jsonValue, err := json.Marshal(jsonBody)
+	if err != nil {
+		return requestBodyT{}, fmt.Errorf("Unable to marshal graphql payload: %w", err)
+	}
+	return requestBodyT{payload: bytes.NewReader(jsonValue), headers: map[string]string{"Content-Type": "application/json"}}, nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -2,14 +2,32 @@ package router
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
 	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	mocksSysUtils "github.com/rudderlabs/rudder-server/mocks/utils/sysUtils"
 	"github.com/rudderlabs/rudder-server/processor/integrations"
 	"github.com/rudderlabs/rudder-server/utils/logger"
@@ -117,6 +135,214 @@ var _ = Describe("Network", func() {
 
 	})
 
+	Context("Request bodies", func() {
+		var network *NetHandleT
+		var request *http.Request
+		var requestBody []byte
+
+		BeforeEach(func() {
+			network = &NetHandleT{}
+			network.logger = logger.NewLogger().Child("network")
+			network.httpClient = c.mockHTTPClient
+			request, requestBody = nil, nil
+		})
+
+		expectRequest := func() {
+			c.mockHTTPClient.EXPECT().Do(gomock.Any()).Times(1).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+				request = req
+				var err error
+				requestBody, err = io.ReadAll(req.Body)
+				Expect(err).NotTo(HaveOccurred())
+				return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader([]byte(`{}`)))}, nil
+			})
+		}
+
+		postInfo := func(requestType string, body map[string]interface{}, files map[string]interface{}) integrations.PostParametersT {
+			return integrations.PostParametersT{
+				Type:          requestType,
+				URL:           "https://example.com/upload",
+				RequestMethod: "POST",
+				Headers:       map[string]interface{}{"X-Api-Key": "key"},
+				QueryParams:   map[string]interface{}{},
+				Body:          body,
+				Files:         files,
+			}
+		}
+
+		It("sends multipart requests with the fields and files", func() {
+			expectRequest()
+			resp := network.SendPost(context.Background(), postInfo("REST",
+				map[string]interface{}{"FORM": map[string]interface{}{"name": "report"}, "JSON": map[string]interface{}{}},
+				map[string]interface{}{
+					"raw":    base64.StdEncoding.EncodeToString([]byte("raw content")),
+					"report": map[string]interface{}{"filename": "report.csv", "contentType": "text/csv", "content": base64.StdEncoding.EncodeToString([]byte("a,b\n1,2"))},
+				},
+			))
+			Expect(resp.StatusCode).To(Equal(200))
+
+			mediaType, params, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(mediaType).To(Equal("multipart/form-data"))
+			Expect(request.Header.Get("X-Api-Key")).To(Equal("key"))
+
+			form, err := multipart.NewReader(bytes.NewReader(requestBody), params["boundary"]).ReadForm(1 << 20)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(form.Value["name"]).To(Equal([]string{"report"}))
+			Expect(form.File["raw"][0].Filename).To(Equal("raw"))
+			Expect(form.File["report"][0].Filename).To(Equal("report.csv"))
+			Expect(form.File["report"][0].Header.Get("Content-Type")).To(Equal("text/csv"))
+			file, err := form.File["report"][0].Open()
+			Expect(err).NotTo(HaveOccurred())
+			content, _ := io.ReadAll(file)
+			Expect(string(content)).To(Equal("a,b\n1,2"))
+		})
+
+		It("sends graphql requests", func() {
+			expectRequest()
+			info := postInfo("GRAPHQL", map[string]interface{}{"JSON": map[string]interface{}{"query": "mutation { track }", "variables": map[string]interface{}{"id": "1"}}}, map[string]interface{}{})
+			info.RequestMethod = ""
+			Expect(network.SendPost(context.Background(), info).StatusCode).To(Equal(200))
+			Expect(request.Method).To(Equal("POST"))
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(requestBody).To(MatchJSON(`{"query": "mutation { track }", "variables": {"id": "1"}}`))
+		})
+
+		It("sends binary and gzipped bodies", func() {
+			expectRequest()
+			binary := postInfo("REST", map[string]interface{}{"BINARY": map[string]interface{}{"payload": base64.StdEncoding.EncodeToString([]byte{0, 1, 2})}}, map[string]interface{}{})
+			Expect(network.SendPost(context.Background(), binary).StatusCode).To(Equal(200))
+			Expect(requestBody).To(Equal([]byte{0, 1, 2}))
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/octet-stream"))
+
+			expectRequest()
+			gzipped := postInfo("REST", map[string]interface{}{"GZIP": map[string]interface{}{"payload": `{"a":1}`}}, map[string]interface{}{})
+			gzipped.Headers["Content-Type"] = "application/x-ndjson"
+			Expect(network.SendPost(context.Background(), gzipped).StatusCode).To(Equal(200))
+			Expect(request.Header.Get("Content-Encoding")).To(Equal("gzip"))
+			Expect(request.Header.Get("Content-Type")).To(Equal("application/x-ndjson"), "the headers of the transformer response are kept")
+			zr, err := gzip.NewReader(bytes.NewReader(requestBody))
+			Expect(err).NotTo(HaveOccurred())
+			unzipped, _ := io.ReadAll(zr)
+			Expect(string(unzipped)).To(Equal(`{"a":1}`))
+		})
+
+		DescribeTable("fails unsupported requests without sending them",
+			func(info integrations.PostParametersT, responseBody string) {
+				resp := network.SendPost(context.Background(), info)
+				Expect(resp.StatusCode).To(Equal(400))
+				Expect(string(resp.ResponseBody)).To(Equal(responseBody))
+			},
+			Entry("unsupported type", postInfo("SOAP", map[string]interface{}{}, map[string]interface{}{}),
+				`400 Unsupported request type "SOAP". Unexpected transformer response`),
+			Entry("unsupported body format", postInfo("REST", map[string]interface{}{"YAML": map[string]interface{}{"a": 1}}, map[string]interface{}{}),
+				`400 Unsupported body format "YAML". Unexpected transformer response`),
+			Entry("graphql without query", postInfo("GRAPHQL", map[string]interface{}{"JSON": map[string]interface{}{}}, map[string]interface{}{}),
+				`400 Unable to construct graphql request without a query. Unexpected transformer response`),
+			Entry("invalid file", postInfo("REST", map[string]interface{}{}, map[string]interface{}{"file": map[string]interface{}{"filename": "a.txt"}}),
+				`400 Unable to construct multipart file file without content. Unexpected transformer response`),
+			Entry("invalid binary payload", postInfo("REST", map[string]interface{}{"BINARY": map[string]interface{}{"payload": "%%%"}}, map[string]interface{}{}),
+				`400 Unable to decode base64 binary payload: illegal base64 data at input byte 0`),
+		)
+	})
+
+	Context("Request auth", func() {
+		var network *NetHandleT
+		var request *http.Request
+
+		BeforeEach(func() {
+			network = &NetHandleT{}
+			network.logger = logger.NewLogger().Child("network")
+			network.httpClient = c.mockHTTPClient
+			request = nil
+		})
+
+		expectRequest := func() {
+			c.mockHTTPClient.EXPECT().Do(gomock.Any()).Times(1).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+				request = req
+				return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader([]byte(`{}`)))}, nil
+			})
+		}
+
+		authContext := func(requestAuth map[string]interface{}) context.Context {
+			return withRequestAuth(context.Background(), parseRequestAuth(backendconfig.DestinationT{ID: "d1", Config: map[string]interface{}{"requestAuth": requestAuth}}))
+		}
+
+		postInfo := func(url string) integrations.PostParametersT {
+			return integrations.PostParametersT{
+				Type:          "REST",
+				URL:           url,
+				RequestMethod: "POST",
+				Headers:       map[string]interface{}{},
+				QueryParams:   map[string]interface{}{},
+				Body:          map[string]interface{}{"JSON": map[string]interface{}{"event": "track"}},
+				Files:         map[string]interface{}{},
+			}
+		}
+
+		It("signs the body with hmac", func() {
+			expectRequest()
+			ctx := authContext(map[string]interface{}{"hmac": map[string]interface{}{"secret": "secret", "header": "X-Hub-Signature", "prefix": "sha256=", "timestampHeader": "X-Timestamp"}})
+			Expect(network.SendPost(ctx, postInfo("https://example.com")).StatusCode).To(Equal(200))
+
+			mac := hmac.New(sha256.New, []byte("secret"))
+			mac.Write([]byte(request.Header.Get("X-Timestamp") + "." + `{"event":"track"}`))
+			Expect(request.Header.Get("X-Timestamp")).NotTo(BeEmpty())
+			Expect(request.Header.Get("X-Hub-Signature")).To(Equal("sha256=" + hex.EncodeToString(mac.Sum(nil))))
+		})
+
+		It("signs requests with aws signature version 4", func() {
+			expectRequest()
+			ctx := authContext(map[string]interface{}{"awsSigV4": map[string]interface{}{"accessKeyId": "AKID", "secretAccessKey": "secret", "region": "eu-west-1", "service": "execute-api"}})
+			Expect(network.SendPost(ctx, postInfo("https://example.com/track")).StatusCode).To(Equal(200))
+
+			Expect(request.Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=AKID/"))
+			Expect(request.Header.Get("Authorization")).To(ContainSubstring("/eu-west-1/execute-api/aws4_request"))
+			Expect(request.Header.Get("X-Amz-Date")).NotTo(BeEmpty())
+			body, _ := io.ReadAll(request.Body)
+			Expect(body).To(MatchJSON(`{"event":"track"}`), "signing keeps the body")
+		})
+
+		It("presents the client certificate to mtls destinations", func() {
+			clientCert, clientKey := newClientCertificate("rudder-client")
+			clientPool := x509.NewCertPool()
+			Expect(clientPool.AppendCertsFromPEM(clientCert)).To(BeTrue())
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+			}))
+			server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+			server.StartTLS()
+			defer server.Close()
+			serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+			ctx := authContext(map[string]interface{}{"mtls": map[string]interface{}{"clientCertificate": string(clientCert), "clientKey": string(clientKey), "caCertificate": string(serverCert)}})
+			resp := network.SendPost(ctx, postInfo(server.URL))
+			Expect(resp.StatusCode).To(Equal(200))
+			Expect(string(resp.ResponseBody)).To(Equal("rudder-client"))
+			Expect(network.mtlsClient(requestAuthFrom(ctx))).To(BeIdenticalTo(network.mtlsClients["d1"].client), "the client is reused")
+
+			network.evictMTLSClients(map[string]*requestAuthT{"d1": requestAuthFrom(ctx)})
+			Expect(network.mtlsClients).To(HaveKey("d1"), "clients of unchanged configs are kept")
+			network.evictMTLSClients(map[string]*requestAuthT{})
+			Expect(network.mtlsClients).To(BeEmpty(), "clients of destinations without mtls are evicted")
+		})
+
+		DescribeTable("fails requests with invalid auth without sending them, retryably",
+			func(requestAuth map[string]interface{}, responseBody string) {
+				resp := network.SendPost(authContext(requestAuth), postInfo("https://example.com"))
+				Expect(resp.StatusCode).To(Equal(500))
+				Expect(string(resp.ResponseBody)).To(Equal(responseBody))
+			},
+			Entry("hmac without secret", map[string]interface{}{"hmac": map[string]interface{}{}},
+				`500 invalid requestAuth: hmac secret is missing`),
+			Entry("unsupported hmac algorithm", map[string]interface{}{"hmac": map[string]interface{}{"secret": "s", "algorithm": "md5"}},
+				`500 invalid requestAuth: unsupported hmac algorithm "md5"`),
+			Entry("incomplete aws credentials", map[string]interface{}{"awsSigV4": map[string]interface{}{"accessKeyId": "AKID"}},
+				`500 invalid requestAuth: awsSigV4 needs accessKeyId, secretAccessKey, region and service`),
+			Entry("invalid client certificate", map[string]interface{}{"mtls": map[string]interface{}{"clientCertificate": "cert", "clientKey": "key"}},
+				`500 invalid requestAuth: invalid mtls client certificate: tls: failed to find any PEM data in certificate input`),
+		)
+	})
+
 	Context("Verify response bodies are propagated/filtered based on the response's content-type", func() {
 		const mockResponseBody = `[{"full_name": "mock-repo"}]`
 		var network *NetHandleT
@@ -181,3 +407,24 @@ var _ = Describe("Network", func() {
 		)
 	})
 })
+
+// newClientCertificate returns a self signed client certificate and its key, PEM encoded
+func newClientCertificate(commonName string) (cert, key []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	Expect(err).NotTo(HaveOccurred())
+	keyDer, err := x509.MarshalECPrivateKey(privateKey)
+	Expect(err).NotTo(HaveOccurred())
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
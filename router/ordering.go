@@ -0,0 +1,174 @@
+package router
+
+import (
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/router/types"
+	"github.com/rudderlabs/rudder-server/services/stats"
+)
+
+//Delivery ordering modes of the jobs of a destination, set by the deliveryOrdering field of the destination config.
+//Destinations without a mode use perUser, or unordered if guaranteeUserEventOrder is false.
+const (
+	//OrderingPerUser delivers the jobs of a user in order, the jobs of a user wait while an earlier job of the user is failing
+	OrderingPerUser = "perUser"
+	//OrderingPerPartitionKey delivers the jobs with the same value at the orderingPartitionKey path of their payload in order,
+	//jobs without a value at the path are ordered per user.
+	//The payload of a router job is the event as transformed by the processor for the destination, not the event sent by the source,
+	//e.g. body.JSON.groupId for the groupId of the json body of the request of a destination transformed by the processor,
+	//or message.context.groupId for a destination transformed by the router
+	OrderingPerPartitionKey = "perPartitionKey"
+	//OrderingUnordered delivers jobs in any order, failing jobs only hold back themselves.
+	//A worker doesn't pick new unordered jobs while maxFailingUnorderedJobs of its unordered jobs are waiting for a retry,
+	//so that a failing destination isn't sent ever more jobs
+	OrderingUnordered = "unordered"
+)
+
+var orderingModes = []string{OrderingPerUser, OrderingPerPartitionKey, OrderingUnordered}
+
+//orderingT is the delivery ordering of the jobs of a destination
+type orderingT struct {
+	mode string
+	//partitionKey is the path of the value of the job payload jobs are ordered by, for perPartitionKey ordering
+	partitionKey string
+}
+
+//jobOrderingT is the ordering key of a job along with the ordering mode of its destination.
+//It is found once when the job is given to a worker, and handed over with the job, so that the payload of the job is parsed only once
+type jobOrderingT struct {
+	key  string
+	mode string
+}
+
+//orderingStatsT counts the jobs of an ordering mode given to workers, held back behind an earlier job with the same ordering key,
+//or by the backpressure of unordered jobs, and the jobs which passed a failed job with the same ordering key
+type orderingStatsT struct {
+	assigned   stats.RudderStats
+	blocked    stats.RudderStats
+	violations stats.RudderStats
+}
+
+func (rt *HandleT) defaultOrdering() orderingT {
+	if rt.guaranteeUserEventOrder {
+		return orderingT{mode: OrderingPerUser}
+	}
+	return orderingT{mode: OrderingUnordered}
+}
+
+//orderingOf returns the delivery ordering set in the config of the destination
+func (rt *HandleT) orderingOf(destination backendconfig.DestinationT) orderingT {
+	mode, _ := destination.Config["deliveryOrdering"].(string)
+	switch mode {
+	case "":
+	case OrderingPerUser, OrderingUnordered:
+		return orderingT{mode: mode}
+	case OrderingPerPartitionKey:
+		if partitionKey, _ := destination.Config["orderingPartitionKey"].(string); partitionKey != "" {
+			return orderingT{mode: mode, partitionKey: partitionKey}
+		}
+		rt.logger.Errorf("[%v Router] :: orderingPartitionKey is missing from the config of destination %s, using %s delivery ordering", rt.destName, destination.ID, rt.defaultOrdering().mode)
+	default:
+		rt.logger.Errorf("[%v Router] :: Invalid deliveryOrdering %q in the config of destination %s, using %s delivery ordering", rt.destName, mode, destination.ID, rt.defaultOrdering().mode)
+	}
+	return rt.defaultOrdering()
+}
+
+//orderingKey returns the key the job is delivered in order by, along with the ordering mode of its destination.
+//Jobs with an empty key are delivered unordered. The key is read from the payload of the job for perPartitionKey ordering, see OrderingPerPartitionKey
+func (rt *HandleT) orderingKey(job *jobsdb.JobT, destinationID string) (key, mode string) {
+	rt.configSubscriberLock.RLock()
+	ordering, ok := rt.destinationOrdering[destinationID]
+	rt.configSubscriberLock.RUnlock()
+	if !ok {
+		ordering = rt.defaultOrdering()
+	}
+
+	switch ordering.mode {
+	case OrderingUnordered:
+		return "", ordering.mode
+	case OrderingPerPartitionKey:
+		if value := gjson.GetBytes(job.EventPayload, ordering.partitionKey).String(); value != "" {
+			//partition keys are scoped to their destination, so that they don't block jobs of other destinations
+			return destinationID + ":" + value, ordering.mode
+		}
+	}
+	return job.UserID, ordering.mode
+}
+
+//allowAbortedJobsCountForProcessing returns how many jobs with an ordering key whose last job was aborted are given to workers at a time
+func (rt *HandleT) allowAbortedJobsCountForProcessing(mode string) int {
+	if mode == OrderingPerPartitionKey {
+		return rt.allowAbortedPartitionKeyJobsCountForProcessing
+	}
+	return rt.allowAbortedUserJobsCountForProcessing
+}
+
+//jobOrdering returns the ordering of the job, see orderingKey
+func (rt *HandleT) jobOrdering(job *jobsdb.JobT, destinationID string) jobOrderingT {
+	key, mode := rt.orderingKey(job, destinationID)
+	return jobOrderingT{key: key, mode: mode}
+}
+
+//orderingKeyOf returns the ordering key of the job of a destination job, as found when the job was given to the worker
+func (worker *workerT) orderingKeyOf(metadata *types.JobMetadataT) string {
+	if key, ok := worker.jobOrderingKeys[metadata.JobID]; ok {
+		return key
+	}
+	key, _ := worker.rt.orderingKey(metadata.JobT, metadata.DestinationID)
+	return key
+}
+
+//orderingViolated reports a job passing the failed job blockingJobID with the same ordering key,
+//which is only possible if the delivery ordering of the destination changed since the failed job was picked
+func (rt *HandleT) orderingViolated(job *jobsdb.JobT, ordering jobOrderingT, blockingJobID int64) {
+	rt.logger.Errorf("[%v Router] :: job %d of ordering key %v passes the earlier failed job %d of the ordering key", rt.destName, job.JobID, ordering.key, blockingJobID)
+	rt.orderingStats[ordering.mode].violations.Increment()
+}
+
+//setNextRetryTime sets when the failed job can be retried, and counts the unordered jobs waiting for a retry for their backpressure
+func (worker *workerT) setNextRetryTime(jobID int64, orderingKey string, nextRetryTime time.Time) {
+	worker.retryForJobMapMutex.Lock()
+	defer worker.retryForJobMapMutex.Unlock()
+	worker.retryForJobMap[jobID] = nextRetryTime
+	if orderingKey == "" {
+		worker.retryingUnorderedJobs[jobID] = struct{}{}
+	}
+}
+
+//clearNextRetryTime forgets the retry of the job, once it succeeded or was aborted
+func (worker *workerT) clearNextRetryTime(jobID int64) {
+	worker.retryForJobMapMutex.Lock()
+	defer worker.retryForJobMapMutex.Unlock()
+	delete(worker.retryForJobMap, jobID)
+	delete(worker.retryingUnorderedJobs, jobID)
+}
+
+//unorderedBackpressure returns true if the worker can't pick the unordered job, because maxFailingUnorderedJobs of its unordered jobs are waiting for a retry.
+//The jobs waiting for a retry are always picked, so that they free the worker once they succeed or are aborted
+func (worker *workerT) unorderedBackpressure(job *jobsdb.JobT) bool {
+	if worker.rt.maxFailingUnorderedJobs <= 0 {
+		return false
+	}
+	worker.retryForJobMapMutex.RLock()
+	defer worker.retryForJobMapMutex.RUnlock()
+	if _, ok := worker.retryingUnorderedJobs[job.JobID]; ok {
+		return false
+	}
+	return len(worker.retryingUnorderedJobs) >= worker.rt.maxFailingUnorderedJobs
+}
+
+func (rt *HandleT) setupOrderingStats() {
+	rt.orderingStats = map[string]*orderingStatsT{}
+	for _, mode := range orderingModes {
+		tags := stats.Tags{"destType": rt.destName, "ordering": mode}
+		rt.orderingStats[mode] = &orderingStatsT{
+			assigned:   stats.NewTaggedStat("router_ordering_assigned_jobs", stats.CountType, tags),
+			blocked:    stats.NewTaggedStat("router_ordering_blocked_jobs", stats.CountType, tags),
+			violations: stats.NewTaggedStat("router_ordering_violations", stats.CountType, tags),
+		}
+	}
+}
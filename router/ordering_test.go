@@ -0,0 +1,160 @@
+package router
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+type noThrottlerT struct{}
+
+func (*noThrottlerT) CheckLimitReached(string, string, time.Time) bool { return false }
+func (*noThrottlerT) Inc(string, string, time.Time)                    {}
+func (*noThrottlerT) Dec(string, string, int64, time.Time, string)     {}
+func (*noThrottlerT) IsEnabled() bool                                  { return false }
+func (*noThrottlerT) IsUserLevelEnabled() bool                         { return false }
+func (*noThrottlerT) IsDestLevelEnabled() bool                         { return false }
+
+var _ = Describe("Ordering", func() {
+	var rt *HandleT
+
+	newJob := func(jobID int64, userID, destinationID, payload string) *jobsdb.JobT {
+		return &jobsdb.JobT{
+			JobID:        jobID,
+			UserID:       userID,
+			Parameters:   []byte(`{"destination_id":"` + destinationID + `"}`),
+			EventPayload: []byte(payload),
+		}
+	}
+	destination := func(id string, config map[string]interface{}) backendconfig.DestinationT {
+		return backendconfig.DestinationT{ID: id, Config: config}
+	}
+
+	BeforeEach(func() {
+		initRouter()
+		stats.Setup()
+		rt = &HandleT{
+			destName:                               "WEBHOOK",
+			logger:                                 logger.NewLogger(),
+			guaranteeUserEventOrder:                true,
+			noOfWorkers:                            4,
+			allowAbortedUserJobsCountForProcessing: 1,
+			throttler:                              &noThrottlerT{},
+			backgroundCtx:                          context.Background(),
+		}
+		rt.setupOrderingStats()
+		rt.workers = make([]*workerT, rt.noOfWorkers)
+		for i := range rt.workers {
+			rt.workers[i] = &workerT{
+				workerID:              i,
+				rt:                    rt,
+				failedJobIDMap:        map[string]int64{},
+				retryForJobMap:        map[int64]time.Time{},
+				retryingUnorderedJobs: map[int64]struct{}{},
+				jobOrderingKeys:       map[int64]string{},
+				abortedOrderingKeyMap: map[string]int{},
+			}
+		}
+		rt.maxFailingUnorderedJobs = 2
+		rt.destinationOrdering = map[string]orderingT{
+			"user":      rt.orderingOf(destination("user", map[string]interface{}{"deliveryOrdering": OrderingPerUser})),
+			"partition": rt.orderingOf(destination("partition", map[string]interface{}{"deliveryOrdering": OrderingPerPartitionKey, "orderingPartitionKey": "body.JSON.groupId"})),
+			"unordered": rt.orderingOf(destination("unordered", map[string]interface{}{"deliveryOrdering": OrderingUnordered})),
+		}
+	})
+
+	Context("delivery ordering of a destination", func() {
+		It("defaults to guaranteeUserEventOrder", func() {
+			Expect(rt.orderingOf(destination("d", map[string]interface{}{}))).To(Equal(orderingT{mode: OrderingPerUser}))
+			Expect(rt.orderingOf(destination("d", map[string]interface{}{"deliveryOrdering": "invalid"}))).To(Equal(orderingT{mode: OrderingPerUser}))
+			Expect(rt.orderingOf(destination("d", map[string]interface{}{"deliveryOrdering": OrderingPerPartitionKey}))).To(Equal(orderingT{mode: OrderingPerUser}))
+
+			rt.guaranteeUserEventOrder = false
+			Expect(rt.orderingOf(destination("d", map[string]interface{}{}))).To(Equal(orderingT{mode: OrderingUnordered}))
+		})
+
+		It("orders jobs by user, partition key or not at all", func() {
+			key, mode := rt.orderingKey(newJob(1, "u1", "user", `{}`), "user")
+			Expect(key).To(Equal("u1"))
+			Expect(mode).To(Equal(OrderingPerUser))
+
+			key, mode = rt.orderingKey(newJob(1, "u1", "partition", `{"body":{"JSON":{"groupId":"g1"}}}`), "partition")
+			Expect(key).To(Equal("partition:g1"))
+			Expect(mode).To(Equal(OrderingPerPartitionKey))
+			key, _ = rt.orderingKey(newJob(1, "u1", "partition", `{"body":{"JSON":{}}}`), "partition")
+			Expect(key).To(Equal("u1"), "jobs without a partition key are ordered per user")
+
+			key, mode = rt.orderingKey(newJob(1, "u1", "unordered", `{}`), "unordered")
+			Expect(key).To(BeEmpty())
+			Expect(mode).To(Equal(OrderingUnordered))
+
+			key, mode = rt.orderingKey(newJob(1, "u1", "unknown", `{}`), "unknown")
+			Expect(key).To(Equal("u1"))
+			Expect(mode).To(Equal(OrderingPerUser))
+		})
+	})
+
+	Context("finding workers", func() {
+		findWorker := func(job *jobsdb.JobT) *workerT {
+			worker, _ := rt.findWorker(job, time.Now())
+			return worker
+		}
+
+		It("returns the ordering of the job along with its worker", func() {
+			worker, ordering := rt.findWorker(newJob(1, "u1", "partition", `{"body":{"JSON":{"groupId":"g1"}}}`), time.Now())
+			Expect(worker).NotTo(BeNil())
+			Expect(ordering).To(Equal(jobOrderingT{key: "partition:g1", mode: OrderingPerPartitionKey}))
+		})
+
+		It("blocks the jobs with the same partition key behind a failed job", func() {
+			first := newJob(1, "u1", "partition", `{"body":{"JSON":{"groupId":"g1"}}}`)
+			worker := findWorker(first)
+			Expect(worker).NotTo(BeNil())
+			worker.failedJobIDMap["partition:g1"] = first.JobID
+
+			Expect(findWorker(newJob(2, "u2", "partition", `{"body":{"JSON":{"groupId":"g1"}}}`))).To(BeNil())
+			Expect(findWorker(first)).To(Equal(worker), "the failed job is retried")
+			Expect(findWorker(newJob(3, "u1", "partition", `{"body":{"JSON":{"groupId":"g2"}}}`))).NotTo(BeNil(), "other partition keys of the user aren't blocked")
+		})
+
+		It("lets the jobs before a failed job pass, as violations of the ordering", func() {
+			worker := findWorker(newJob(2, "u1", "user", `{}`))
+			Expect(worker).NotTo(BeNil())
+			worker.failedJobIDMap["u1"] = 2
+
+			Expect(findWorker(newJob(1, "u1", "user", `{}`))).To(Equal(worker))
+			Expect(worker.handleJobForPrevFailedKey(newJob(1, "u1", "user", `{}`), jobOrderingT{key: "u1", mode: OrderingPerUser}, 2)).To(BeFalse())
+		})
+
+		It("doesn't block unordered jobs behind failed jobs", func() {
+			first := newJob(1, "u1", "unordered", `{}`)
+			worker := findWorker(first)
+			Expect(worker).To(Equal(rt.workers[1]))
+			worker.setNextRetryTime(first.JobID, "", time.Now().Add(time.Hour))
+
+			Expect(findWorker(first)).To(BeNil(), "the failed job backs off")
+			Expect(findWorker(newJob(2, "u1", "unordered", `{}`))).NotTo(BeNil())
+		})
+
+		It("stops picking unordered jobs while maxFailingUnorderedJobs of them are waiting for a retry", func() {
+			worker := rt.workers[1]
+			worker.setNextRetryTime(1, "", time.Now())
+			worker.setNextRetryTime(5, "", time.Now())
+			worker.setNextRetryTime(9, "u1", time.Now())
+
+			Expect(findWorker(newJob(13, "u1", "unordered", `{}`))).To(BeNil(), "the worker has too many failing unordered jobs")
+			Expect(findWorker(newJob(5, "u1", "unordered", `{}`))).To(Equal(worker), "the failing jobs are retried")
+			Expect(findWorker(newJob(14, "u1", "unordered", `{}`))).To(Equal(rt.workers[2]), "the other workers aren't held back")
+
+			worker.clearNextRetryTime(5)
+			Expect(findWorker(newJob(13, "u1", "unordered", `{}`))).To(Equal(worker))
+		})
+	})
+})
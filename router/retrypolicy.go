@@ -0,0 +1,330 @@
+package router
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+)
+
+//Backoff strategies of a retry policy
+const (
+	//BackoffExponential doubles the wait between attempts, from minBackoff up to maxBackoff
+	BackoffExponential = "exponential"
+	//BackoffFixed waits minBackoff between attempts
+	BackoffFixed = "fixed"
+)
+
+//Classes of the response status codes of destinations
+const (
+	abortStatus = iota
+	retryStatus
+	throttledStatus
+)
+
+//httpStatusCodeRuleKey is the key of the rules of retry policies, matching the http status code of the responses.
+//It differs from the statusCode of the ResponseRules of destination definitions, which match the body of 2xx responses
+const httpStatusCodeRuleKey = "httpStatusCode"
+
+//classOfRulesKey is the class of the status codes of each key of the rules of retry policies
+var classOfRulesKey = map[string]int{"abortable": abortStatus, "retryable": retryStatus, "throttled": throttledStatus}
+
+/*
+retryPolicyOverrideT is a retry policy set by the retryPolicy field of the destination definition config or of the destination config, e.g.
+
+	"retryPolicy": {
+		"backoff": "fixed",
+		"minBackoff": "30s",
+		"maxBackoff": "10m",
+		"jitter": 0.2,
+		"maxAttempts": 10,
+		"maxAge": "6h",
+		"rules": {
+			"abortable": [{"httpStatusCode": 404}, {"httpStatusCode": 410}],
+			"retryable": [{"httpStatusCode": 408}],
+			"throttled": [{"httpStatusCode": 503}]
+		}
+	}
+
+Durations are either duration strings or seconds. The rules only match the http status code of failed responses,
+the body of responses is matched by the ResponseRules of the destination definition. Fields are nil when they are not set, so that zero values override too.
+*/
+type retryPolicyOverrideT struct {
+	backoff     *string
+	minBackoff  *time.Duration
+	maxBackoff  *time.Duration
+	jitter      *float64
+	maxAttempts *int
+	maxAge      *time.Duration
+	//class of the status codes overriding the default classification of 5xx as retryable, 429 as throttled and the rest as abortable
+	statusCodeClasses map[int]int
+}
+
+/*
+retryPolicyT is how the jobs of a destination failing with a retryable status code are retried.
+It is the retryPolicyOverrideT of the destination definition, overridden by the one of the destination, over the router config:
+Router.minRetryBackoff, Router.maxRetryBackoff, Router.<destType>.maxFailedCountForJob and Router.<destType>.retryTimeWindow.
+
+Jobs of destinations setting maxAttempts or maxAge are aborted as soon as they reach any of the limits they set.
+Otherwise they are aborted once they have been attempted maxFailedCountForJob times and have been failing for longer than retryTimeWindow.
+*/
+type retryPolicyT struct {
+	backoff     string
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+	maxAttempts int
+	maxAge      time.Duration
+	//limitsSet is whether maxAttempts and maxAge are set by the destination, a zero limit is then no limit
+	limitsSet         bool
+	statusCodeClasses map[int]int
+}
+
+//parseRetryPolicy returns the retry policy in the retryPolicy field of a destination or destination definition config
+func parseRetryPolicy(destConfig map[string]interface{}) (policy retryPolicyOverrideT, err error) {
+	policyConfig, ok := destConfig["retryPolicy"].(map[string]interface{})
+	if !ok {
+		if _, isSet := destConfig["retryPolicy"]; isSet {
+			return policy, fmt.Errorf("retryPolicy is not an object")
+		}
+		return policy, nil
+	}
+
+	if value, ok := policyConfig["backoff"]; ok {
+		backoff, _ := value.(string)
+		if backoff != BackoffExponential && backoff != BackoffFixed {
+			return policy, fmt.Errorf("invalid backoff %v, expected %s or %s", value, BackoffExponential, BackoffFixed)
+		}
+		policy.backoff = &backoff
+	}
+	for key, d := range map[string]**time.Duration{"minBackoff": &policy.minBackoff, "maxBackoff": &policy.maxBackoff, "maxAge": &policy.maxAge} {
+		if value, ok := policyConfig[key]; ok {
+			duration, err := parseDuration(value)
+			if err != nil {
+				return policy, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			*d = &duration
+		}
+	}
+	if value, ok := policyConfig["jitter"]; ok {
+		jitter, ok := value.(float64)
+		if !ok || jitter < 0 || jitter > 1 {
+			return policy, fmt.Errorf("invalid jitter %v, expected a number between 0 and 1", value)
+		}
+		policy.jitter = &jitter
+	}
+	if value, ok := policyConfig["maxAttempts"]; ok {
+		attempts, ok := value.(float64)
+		if !ok || attempts < 1 || attempts != float64(int(attempts)) {
+			return policy, fmt.Errorf("invalid maxAttempts %v, expected a positive integer", value)
+		}
+		maxAttempts := int(attempts)
+		policy.maxAttempts = &maxAttempts
+	}
+	if value, ok := policyConfig["rules"]; ok {
+		rules, ok := value.(map[string]interface{})
+		if !ok {
+			return policy, fmt.Errorf("rules is not an object")
+		}
+		if policy.statusCodeClasses, err = statusCodeClassesOf(rules); err != nil {
+			return policy, err
+		}
+	}
+	return policy, nil
+}
+
+func parseDuration(value interface{}) (time.Duration, error) {
+	var d time.Duration
+	switch v := value.(type) {
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, err
+		}
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	default:
+		return 0, fmt.Errorf("%v is neither a duration string nor seconds", value)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%v is not positive", value)
+	}
+	return d, nil
+}
+
+//statusCodeClassesOf returns the class of the status codes of the abortable, retryable and throttled rules of a retry policy.
+//They only match the http status code, rules matching the body belong to the ResponseRules of the destination definition.
+func statusCodeClassesOf(rules map[string]interface{}) (map[int]int, error) {
+	var classes map[int]int
+	for key, class := range classOfRulesKey {
+		value, ok := rules[key]
+		if !ok {
+			continue
+		}
+		values, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s rules are not an array", key)
+		}
+		for _, value := range values {
+			rule, _ := value.(map[string]interface{})
+			statusCode, isStatusCodeRule := rule[httpStatusCodeRuleKey]
+			if !isStatusCodeRule || len(rule) > 1 {
+				return nil, fmt.Errorf("%s rule %v does not only match the %s, rules matching the body belong to the responseRules of the destination definition", key, value, httpStatusCodeRuleKey)
+			}
+			var code int
+			switch v := statusCode.(type) {
+			case float64:
+				code = int(v)
+			case string:
+				code, _ = strconv.Atoi(v)
+			}
+			if code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status code %v in %s rules", statusCode, key)
+			}
+			if otherClass, ok := classes[code]; ok && otherClass != class {
+				return nil, fmt.Errorf("status code %d is in several rules", code)
+			}
+			if classes == nil {
+				classes = map[int]int{}
+			}
+			classes[code] = class
+		}
+	}
+	return classes, nil
+}
+
+//merge returns the policy with the fields set in the override policy replaced.
+//Status codes are merged, a code moves to the class the override policy puts it in.
+func (policy retryPolicyOverrideT) merge(override retryPolicyOverrideT) retryPolicyOverrideT {
+	if override.backoff != nil {
+		policy.backoff = override.backoff
+	}
+	if override.minBackoff != nil {
+		policy.minBackoff = override.minBackoff
+	}
+	if override.maxBackoff != nil {
+		policy.maxBackoff = override.maxBackoff
+	}
+	if override.jitter != nil {
+		policy.jitter = override.jitter
+	}
+	if override.maxAttempts != nil {
+		policy.maxAttempts = override.maxAttempts
+	}
+	if override.maxAge != nil {
+		policy.maxAge = override.maxAge
+	}
+
+	classes := map[int]int{}
+	for code, class := range policy.statusCodeClasses {
+		classes[code] = class
+	}
+	for code, class := range override.statusCodeClasses {
+		classes[code] = class
+	}
+	policy.statusCodeClasses = classes
+	return policy
+}
+
+//retryPolicyFor returns the retry policy set for the destination, without the router config defaults
+func (rt *HandleT) retryPolicyFor(destination backendconfig.DestinationT) retryPolicyOverrideT {
+	var policy retryPolicyOverrideT
+	if definitionPolicy, err := parseRetryPolicy(destination.DestinationDefinition.Config); err != nil {
+		rt.logger.Errorf("[%v Router] :: Ignoring invalid retryPolicy of destination definition %s: %v", rt.destName, destination.DestinationDefinition.Name, err)
+	} else {
+		policy = policy.merge(definitionPolicy)
+	}
+	if destinationPolicy, err := parseRetryPolicy(destination.Config); err != nil {
+		rt.logger.Errorf("[%v Router] :: Ignoring invalid retryPolicy of destination %s: %v", rt.destName, destination.ID, err)
+	} else {
+		policy = policy.merge(destinationPolicy)
+	}
+	return policy
+}
+
+//retryPolicyOf returns the retry policy of the destination, falling back to the router config for the fields it doesn't set
+func (rt *HandleT) retryPolicyOf(destinationID string) retryPolicyT {
+	rt.configSubscriberLock.RLock()
+	override := rt.destinationRetryPolicies[destinationID]
+	rt.configSubscriberLock.RUnlock()
+
+	policy := retryPolicyT{
+		backoff:           BackoffExponential,
+		minBackoff:        minRetryBackoff,
+		maxBackoff:        maxRetryBackoff,
+		maxAttempts:       rt.maxFailedCountForJob,
+		maxAge:            rt.retryTimeWindow,
+		statusCodeClasses: override.statusCodeClasses,
+	}
+	if override.backoff != nil {
+		policy.backoff = *override.backoff
+	}
+	if override.minBackoff != nil {
+		policy.minBackoff = *override.minBackoff
+	}
+	if override.maxBackoff != nil {
+		policy.maxBackoff = *override.maxBackoff
+	}
+	if override.jitter != nil {
+		policy.jitter = *override.jitter
+	}
+	if override.maxAttempts != nil || override.maxAge != nil {
+		policy.limitsSet = true
+		policy.maxAttempts, policy.maxAge = 0, 0
+		if override.maxAttempts != nil {
+			policy.maxAttempts = *override.maxAttempts
+		}
+		if override.maxAge != nil {
+			policy.maxAge = *override.maxAge
+		}
+	}
+	return policy
+}
+
+//classify returns whether jobs failing with the status code are aborted, retried or throttled
+func (policy retryPolicyT) classify(statusCode int) int {
+	if class, ok := policy.statusCodeClasses[statusCode]; ok {
+		return class
+	}
+	switch {
+	case statusCode >= 500:
+		return retryStatus
+	case statusCode == 429:
+		return throttledStatus
+	}
+	return abortStatus
+}
+
+//exhausted returns whether a job failing with a retryable status code must be aborted
+func (policy retryPolicyT) exhausted(attemptNum int, failingFor time.Duration) bool {
+	if !policy.limitsSet {
+		return failingFor > policy.maxAge && attemptNum >= policy.maxAttempts
+	}
+	return (policy.maxAttempts > 0 && attemptNum >= policy.maxAttempts) || (policy.maxAge > 0 && failingFor > policy.maxAge)
+}
+
+//durationBeforeNextAttempt returns how long a job waits after its attempt before it is retried
+func (policy retryPolicyT) durationBeforeNextAttempt(attempt int) (d time.Duration) {
+	if policy.backoff == BackoffFixed {
+		d = policy.minBackoff
+	} else {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = policy.minBackoff
+		b.MaxInterval = policy.maxBackoff
+		b.RandomizationFactor = 0
+		b.MaxElapsedTime = 0
+		b.Multiplier = 2
+		b.Reset()
+		for index := 0; index < attempt; index++ {
+			d = b.NextBackOff()
+		}
+	}
+	if policy.jitter > 0 {
+		d = time.Duration(float64(d) * (1 - policy.jitter + 2*policy.jitter*rand.Float64()))
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+package router
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+var _ = Describe("Retry policy", func() {
+	var rt *HandleT
+
+	BeforeEach(func() {
+		initRouter()
+		rt = &HandleT{
+			destName:             "WEBHOOK",
+			logger:               logger.NewLogger(),
+			maxFailedCountForJob: 3,
+			retryTimeWindow:      180 * time.Minute,
+		}
+	})
+
+	Context("parsing", func() {
+		It("parses the retry policy of a config", func() {
+			policy, err := parseRetryPolicy(map[string]interface{}{
+				"retryPolicy": map[string]interface{}{
+					"backoff":     "fixed",
+					"minBackoff":  "30s",
+					"maxBackoff":  600.0,
+					"jitter":      0.2,
+					"maxAttempts": 10.0,
+					"maxAge":      "6h",
+					"rules": map[string]interface{}{
+						"abortable": []interface{}{map[string]interface{}{"httpStatusCode": 404.0}, map[string]interface{}{"httpStatusCode": "410"}},
+						"retryable": []interface{}{map[string]interface{}{"httpStatusCode": 408.0}},
+						"throttled": []interface{}{map[string]interface{}{"httpStatusCode": 503.0}},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*policy.backoff).To(Equal(BackoffFixed))
+			Expect(*policy.minBackoff).To(Equal(30 * time.Second))
+			Expect(*policy.maxBackoff).To(Equal(10 * time.Minute))
+			Expect(*policy.jitter).To(Equal(0.2))
+			Expect(*policy.maxAttempts).To(Equal(10))
+			Expect(*policy.maxAge).To(Equal(6 * time.Hour))
+			Expect(policy.statusCodeClasses).To(Equal(map[int]int{404: abortStatus, 410: abortStatus, 408: retryStatus, 503: throttledStatus}))
+
+			policy, err = parseRetryPolicy(map[string]interface{}{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy).To(Equal(retryPolicyOverrideT{}))
+		})
+
+		It("rejects invalid retry policies", func() {
+			for _, invalid := range []interface{}{
+				"fixed",
+				map[string]interface{}{"backoff": "linear"},
+				map[string]interface{}{"minBackoff": "soon"},
+				map[string]interface{}{"maxAge": -1.0},
+				map[string]interface{}{"jitter": 2.0},
+				map[string]interface{}{"maxAttempts": 1.5},
+				map[string]interface{}{"rules": []interface{}{}},
+				map[string]interface{}{"rules": map[string]interface{}{"abortable": map[string]interface{}{"httpStatusCode": 404.0}}},
+				map[string]interface{}{"rules": map[string]interface{}{"retryable": []interface{}{map[string]interface{}{"httpStatusCode": "timeout"}}}},
+				map[string]interface{}{"rules": map[string]interface{}{"retryable": []interface{}{map[string]interface{}{"httpStatusCode": 200.0, "success": false}}}},
+				map[string]interface{}{"rules": map[string]interface{}{"retryable": []interface{}{map[string]interface{}{"success": false}}}},
+				map[string]interface{}{"rules": map[string]interface{}{"abortable": []interface{}{map[string]interface{}{"statusCode": 400.0}}}},
+				map[string]interface{}{"rules": map[string]interface{}{
+					"abortable": []interface{}{map[string]interface{}{"httpStatusCode": 404.0}},
+					"retryable": []interface{}{map[string]interface{}{"httpStatusCode": 404.0}},
+				}},
+			} {
+				_, err := parseRetryPolicy(map[string]interface{}{"retryPolicy": invalid})
+				Expect(err).To(HaveOccurred(), "%v", invalid)
+			}
+		})
+	})
+
+	Context("merging", func() {
+		It("overrides the destination definition policy with the destination policy", func() {
+			rt.destinationRetryPolicies = map[string]retryPolicyOverrideT{
+				"d1": rt.retryPolicyFor(backendconfig.DestinationT{
+					ID: "d1",
+					Config: map[string]interface{}{
+						"retryPolicy": map[string]interface{}{
+							"maxAttempts": 10.0,
+							"jitter":      0.0,
+							"rules":       map[string]interface{}{"retryable": []interface{}{map[string]interface{}{"httpStatusCode": 404.0}}},
+						},
+					},
+					DestinationDefinition: backendconfig.DestinationDefinitionT{
+						Config: map[string]interface{}{
+							"retryPolicy": map[string]interface{}{
+								"backoff":     "fixed",
+								"minBackoff":  "1m",
+								"maxAttempts": 5.0,
+								"jitter":      0.5,
+								"rules": map[string]interface{}{
+									"abortable": []interface{}{map[string]interface{}{"httpStatusCode": 404.0}, map[string]interface{}{"httpStatusCode": 503.0}},
+									"throttled": []interface{}{map[string]interface{}{"httpStatusCode": 502.0}},
+								},
+							},
+						},
+						ResponseRules: map[string]interface{}{
+							"responseType": "JSON",
+							"rules":        map[string]interface{}{"abortable": []interface{}{map[string]interface{}{"statusCode": 500.0}}},
+						},
+					},
+				}),
+			}
+
+			policy := rt.retryPolicyOf("d1")
+			Expect(policy.backoff).To(Equal(BackoffFixed))
+			Expect(policy.minBackoff).To(Equal(time.Minute))
+			Expect(policy.maxBackoff).To(Equal(maxRetryBackoff))
+			Expect(policy.jitter).To(BeZero(), "a zero jitter overrides the one of the destination definition")
+			Expect(policy.maxAttempts).To(Equal(10))
+			Expect(policy.maxAge).To(BeZero(), "the router retry time window doesn't apply to destinations setting their limits")
+			Expect(policy.classify(404)).To(Equal(retryStatus))
+			Expect(policy.classify(503)).To(Equal(abortStatus))
+			Expect(policy.classify(502)).To(Equal(throttledStatus))
+			Expect(policy.classify(500)).To(Equal(retryStatus), "the statusCode of the response rules matches the body of 2xx responses only")
+
+			Expect(rt.retryPolicyOf("d2")).To(Equal(retryPolicyT{
+				backoff:     BackoffExponential,
+				minBackoff:  minRetryBackoff,
+				maxBackoff:  maxRetryBackoff,
+				maxAttempts: 3,
+				maxAge:      180 * time.Minute,
+			}), "destinations without a retry policy use the router config")
+		})
+	})
+
+	Context("retrying", func() {
+		It("classifies status codes", func() {
+			policy := retryPolicyT{}
+			Expect(policy.classify(500)).To(Equal(retryStatus))
+			Expect(policy.classify(429)).To(Equal(throttledStatus))
+			Expect(policy.classify(400)).To(Equal(abortStatus))
+
+			policy = retryPolicyT{statusCodeClasses: map[int]int{429: retryStatus}}
+			Expect(policy.classify(429)).To(Equal(retryStatus), "retryable throttled jobs are aborted once exhausted")
+		})
+
+		It("aborts jobs after their max attempts or max age", func() {
+			policy := retryPolicyT{maxAttempts: 3, maxAge: time.Hour, limitsSet: true}
+			Expect(policy.exhausted(3, 2*time.Hour)).To(BeTrue())
+			Expect(policy.exhausted(2, 2*time.Hour)).To(BeTrue())
+			Expect(policy.exhausted(3, time.Minute)).To(BeTrue())
+			Expect(policy.exhausted(2, time.Minute)).To(BeFalse())
+
+			policy = retryPolicyT{maxAttempts: 3, limitsSet: true}
+			Expect(policy.exhausted(2, 24*time.Hour)).To(BeFalse(), "limits which are not set don't apply")
+			Expect(policy.exhausted(3, time.Minute)).To(BeTrue())
+		})
+
+		It("aborts jobs after the router max attempts and retry time window without limits set", func() {
+			policy := retryPolicyT{maxAttempts: 3, maxAge: time.Hour}
+			Expect(policy.exhausted(3, 2*time.Hour)).To(BeTrue())
+			Expect(policy.exhausted(2, 2*time.Hour)).To(BeFalse())
+			Expect(policy.exhausted(3, time.Minute)).To(BeFalse())
+		})
+
+		It("backs off", func() {
+			policy := retryPolicyT{backoff: BackoffExponential, minBackoff: 10 * time.Second, maxBackoff: 30 * time.Second}
+			Expect(policy.durationBeforeNextAttempt(1)).To(Equal(10 * time.Second))
+			Expect(policy.durationBeforeNextAttempt(2)).To(Equal(20 * time.Second))
+			Expect(policy.durationBeforeNextAttempt(5)).To(Equal(30 * time.Second))
+
+			policy.backoff = BackoffFixed
+			Expect(policy.durationBeforeNextAttempt(5)).To(Equal(10 * time.Second))
+
+			policy.jitter = 0.5
+			for i := 0; i < 10; i++ {
+				Expect(policy.durationBeforeNextAttempt(1)).To(BeNumerically("~", 10*time.Second, 5*time.Second))
+			}
+		})
+	})
+})
@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
-	"math/rand"
 	"net/http"
 	"sort"
 	"strconv"
@@ -16,9 +15,9 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cenkalti/backoff/v4"
 	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	"github.com/rudderlabs/rudder-server/processor/integrations"
+	"github.com/rudderlabs/rudder-server/router/concurrency"
 	"github.com/rudderlabs/rudder-server/router/customdestinationmanager"
 	customDestinationManager "github.com/rudderlabs/rudder-server/router/customdestinationmanager"
 	"github.com/rudderlabs/rudder-server/router/throttler"
@@ -82,6 +81,7 @@ type HandleT struct {
 	requestQ                               chan *jobsdb.JobT
 	responseQ                              chan jobResponseT
 	jobsDB                                 jobsdb.MultiTenantJobsDB
+	storedJobs                             <-chan []*jobsdb.JobT // subscription to the jobsDB waking up the generator loop, nil if it is polled
 	errorDB                                jobsdb.JobsDB
 	netHandle                              NetHandleI
 	MultitenantI                           tenantStats
@@ -103,6 +103,7 @@ type HandleT struct {
 	failuresMetric                         map[string][]failureMetric
 	customDestinationManager               customdestinationmanager.DestinationManager
 	throttler                              throttler.Throttler
+	concurrencyLimiter                     *concurrency.HandleT
 	throttlerMutex                         sync.RWMutex
 	guaranteeUserEventOrder                bool
 	netClientTimeout                       time.Duration
@@ -110,6 +111,9 @@ type HandleT struct {
 	transformer                            transformer.Transformer
 	configSubscriberLock                   sync.RWMutex
 	destinationsMap                        map[string]*router_utils.BatchDestinationT // destinationID -> destination
+	destinationOrdering                    map[string]orderingT                       // destinationID -> delivery ordering
+	destinationRetryPolicies               map[string]retryPolicyOverrideT            // destinationID -> retry policy set in the backend config
+	destinationRequestAuth                 map[string]*requestAuthT                   // destinationID -> request signing and mtls set in the backend config
 	logger                                 logger.LoggerI
 	batchInputCountStat                    stats.RudderStats
 	batchOutputCountStat                   stats.RudderStats
@@ -121,7 +125,8 @@ type HandleT struct {
 	routerResponseTransformStat            stats.RudderStats
 	noOfWorkers                            int
 	allowAbortedUserJobsCountForProcessing int
-	throttledUserMap                       map[string]struct{} // used before calling findWorker. A temp storage to save <userid> whose job can be throttled.
+	throttledOrderingKeyMap                map[string]struct{} // used before calling findWorker. A temp storage to save <orderingKey> whose job can be throttled.
+	concurrencyLimitedJobs                 map[string]int      // used before calling findWorker. A temp storage to count the jobs assigned per destination, which are about to acquire its concurrency limiter.
 	isBackendConfigInitialized             bool
 	backendConfig                          backendconfig.BackendConfig
 	backendConfigInitialized               chan bool
@@ -139,6 +144,10 @@ type HandleT struct {
 	sourceIDWorkspaceMap                   map[string]string
 	maxDSQuerySize                         int
 
+	allowAbortedPartitionKeyJobsCountForProcessing int
+	maxFailingUnorderedJobs                        int
+	orderingStats                                  map[string]*orderingStatsT
+
 	backgroundGroup  *errgroup.Group
 	backgroundCtx    context.Context
 	backgroundCancel context.CancelFunc
@@ -154,10 +163,11 @@ type HandleT struct {
 }
 
 type jobResponseT struct {
-	status *jobsdb.JobStatusT
-	worker *workerT
-	userID string
-	JobT   *jobsdb.JobT
+	status      *jobsdb.JobStatusT
+	worker      *workerT
+	userID      string
+	orderingKey string
+	JobT        *jobsdb.JobT
 }
 
 //JobParametersT struct holds source id and destination id of a job
@@ -182,6 +192,7 @@ type JobParametersT struct {
 
 type workerMessageT struct {
 	job                *jobsdb.JobT
+	ordering           jobOrderingT
 	throttledAtTime    time.Time
 	workerAssignedTime time.Time
 	resultSetID        int64
@@ -195,10 +206,12 @@ type workerT struct {
 	workerID                   int                     // identifies the worker
 	failedJobs                 int                     // counts the failed jobs of a worker till it gets reset by external channel
 	sleepTime                  time.Duration           // the sleep duration for every job of the worker
-	failedJobIDMap             map[string]int64        // user to failed jobId
+	failedJobIDMap             map[string]int64        // ordering key to failed jobId
 	failedJobIDMutex           sync.RWMutex            // lock to protect structure above
 	retryForJobMap             map[int64]time.Time     // jobID to next retry time map
-	retryForJobMapMutex        sync.RWMutex            // lock to protect structure above
+	retryingUnorderedJobs      map[int64]struct{}      // unordered jobs of retryForJobMap, for their backpressure
+	retryForJobMapMutex        sync.RWMutex            // lock to protect structures above
+	jobOrderingKeys            map[int64]string        // jobID to ordering key of the jobs given to the worker, until they are processed
 	routerJobs                 []types.RouterJobT      // slice to hold router jobs to send to destination transformer
 	destinationJobs            []types.DestinationJobT // slice to hold destination jobs
 	rt                         *HandleT                // handle to router
@@ -206,7 +219,7 @@ type workerT struct {
 	routerDeliveryLatencyStat  stats.RudderStats
 	routerProxyStat            stats.RudderStats
 	batchTimeStat              stats.RudderStats
-	abortedUserIDMap           map[string]int // aborted user to count of jobs allowed map
+	abortedOrderingKeyMap      map[string]int // aborted ordering key to count of jobs allowed map
 	abortedUserMutex           sync.RWMutex
 	jobCountsByDestAndUser     map[string]*destJobCountsT
 	throttledAtTime            time.Time
@@ -225,6 +238,7 @@ var (
 	failedEventsCacheSize                                         int
 	readSleep, minSleep, maxStatusUpdateWait, diagnosisTickerTime time.Duration
 	minRetryBackoff, maxRetryBackoff, jobsBatchTimeout            time.Duration
+	maxRetryAfter                                                 time.Duration
 	noOfJobsToBatchInAWorker                                      int
 	pkgLogger                                                     logger.LoggerI
 	Diagnostics                                                   diagnostics.DiagnosticsI
@@ -332,6 +346,8 @@ func loadConfig() {
 	config.RegisterDurationConfigVariable(time.Duration(60), &diagnosisTickerTime, false, time.Second, []string{"Diagnostics.routerTimePeriod", "Diagnostics.routerTimePeriodInS"}...)
 	config.RegisterDurationConfigVariable(time.Duration(10), &minRetryBackoff, true, time.Second, []string{"Router.minRetryBackoff", "Router.minRetryBackoffInS"}...)
 	config.RegisterDurationConfigVariable(time.Duration(300), &maxRetryBackoff, true, time.Second, []string{"Router.maxRetryBackoff", "Router.maxRetryBackoffInS"}...)
+	//Longest wait asked for by the Retry-After header of a destination which is honored
+	config.RegisterDurationConfigVariable(time.Duration(300), &maxRetryAfter, true, time.Second, "Router.maxRetryAfter")
 	config.RegisterDurationConfigVariable(time.Duration(0), &fixedLoopSleep, true, time.Millisecond, []string{"Router.fixedLoopSleep", "Router.fixedLoopSleepInMS"}...)
 	config.RegisterIntConfigVariable(10, &failedEventsCacheSize, false, 1, "Router.failedEventsCacheSize")
 	config.RegisterStringConfigVariable("", &toAbortDestinationIDs, true, "Router.toAbortDestinationIDs")
@@ -427,6 +443,7 @@ func (worker *workerT) workerProcess() {
 			worker.routerJobs = make([]types.RouterJobT, 0)
 			worker.destinationJobs = make([]types.DestinationJobT, 0)
 			worker.jobCountsByDestAndUser = make(map[string]*destJobCountsT)
+			worker.jobOrderingKeys = make(map[int64]string)
 
 			pkgLogger.Infof("Router worker %d is paused. Dest type: %s", worker.workerID, worker.rt.destName)
 			pause.wg.Done()
@@ -467,24 +484,26 @@ func (worker *workerT) workerProcess() {
 				worker.rt.logger.Error("Unmarshal of job parameters failed. ", string(job.Parameters))
 			}
 
-			var isPrevFailedUser bool
-			var previousFailedJobID int64
-			if worker.rt.guaranteeUserEventOrder {
-				//If there is a failed jobID from this user, we cannot pass future jobs
+			ordering := message.ordering
+			orderingKey := ordering.key
+			if orderingKey != "" {
+				//If there is a failed jobID with this ordering key, we cannot pass future jobs
 				worker.failedJobIDMutex.RLock()
-				previousFailedJobID, isPrevFailedUser = worker.failedJobIDMap[userID]
+				previousFailedJobID, isPrevFailedKey := worker.failedJobIDMap[orderingKey]
 				worker.failedJobIDMutex.RUnlock()
 
-				// mark job as waiting if prev job from same user has not succeeded yet
-				if isPrevFailedUser {
-					markedAsWaiting := worker.handleJobForPrevFailedUser(job, parameters, userID, previousFailedJobID)
+				// mark job as waiting if prev job with the same ordering key has not succeeded yet
+				if isPrevFailedKey {
+					markedAsWaiting := worker.handleJobForPrevFailedKey(job, ordering, previousFailedJobID)
 					if markedAsWaiting {
+						worker.rt.orderingStats[ordering.mode].blocked.Increment()
 						worker.rt.logger.Debugf(`Decrementing in throttle map for destination:%s since job:%d is marked as waiting for user:%s`, parameters.DestinationID, job.JobID, userID)
 						worker.rt.throttler.Dec(parameters.DestinationID, userID, 1, worker.throttledAtTime, throttler.ALL_LEVELS)
 						continue
 					}
 				}
 			}
+			worker.jobOrderingKeys[job.JobID] = orderingKey
 
 			firstAttemptedAt := gjson.GetBytes(job.LastJobStatus.ErrorResponse, "firstAttemptedAt").Str
 
@@ -518,7 +537,7 @@ func (worker *workerT) workerProcess() {
 					Parameters:    []byte(`{}`),
 					WorkspaceId:   job.WorkspaceId,
 				}
-				worker.rt.responseQ <- jobResponseT{status: &status, worker: worker, userID: userID, JobT: job}
+				worker.rt.responseQ <- jobResponseT{status: &status, worker: worker, userID: userID, orderingKey: orderingKey, JobT: job}
 				continue
 			}
 			destination := batchDestination.Destination
@@ -592,15 +611,22 @@ func (worker *workerT) processDestinationJobs() {
 	worker.routerJobs = make([]types.RouterJobT, 0)
 	worker.destinationJobs = make([]types.DestinationJobT, 0)
 	worker.jobCountsByDestAndUser = make(map[string]*destJobCountsT)
+	worker.jobOrderingKeys = make(map[int64]string)
 }
 
-func (worker *workerT) canSendJobToDestination(prevRespStatusCode int, failedUserIDsMap map[string]struct{}, destinationJob types.DestinationJobT) bool {
+func (worker *workerT) canSendJobToDestination(prevRespStatusCode int, failedOrderingKeysMap map[string]struct{}, destinationJob types.DestinationJobT) bool {
 	if prevRespStatusCode == 0 {
 		return true
 	}
 
-	if !worker.rt.guaranteeUserEventOrder {
-		//if guaranteeUserEventOrder is false, letting the next jobs pass
+	orderingKeys := make([]string, 0, len(destinationJob.JobMetadataArray))
+	for i := range destinationJob.JobMetadataArray {
+		if key := worker.orderingKeyOf(&destinationJob.JobMetadataArray[i]); key != "" {
+			orderingKeys = append(orderingKeys, key)
+		}
+	}
+	if len(orderingKeys) == 0 {
+		//if the jobs are unordered, letting the next jobs pass
 		return true
 	}
 
@@ -610,9 +636,9 @@ func (worker *workerT) canSendJobToDestination(prevRespStatusCode int, failedUse
 	}
 
 	//If the destinationJob has come through router transform,
-	//drop the request if it is of a failed user, else send
-	for _, metadata := range destinationJob.JobMetadataArray {
-		if _, ok := failedUserIDsMap[metadata.UserID]; ok {
+	//drop the request if it is of a failed ordering key, else send
+	for _, key := range orderingKeys {
+		if _, ok := failedOrderingKeysMap[key]; ok {
 			return false
 		}
 	}
@@ -645,6 +671,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 	worker.batchTimeStat.Start()
 
 	var respContentType string
+	var respRetryAfter time.Duration
 	var respStatusCode, prevRespStatusCode int
 	var respBody string
 	var respBodyTemp string
@@ -692,7 +719,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 		u2e3 will send
 	*/
 
-	failedUserIDsMap := make(map[string]struct{})
+	failedOrderingKeysMap := make(map[string]struct{})
 	apiCallsCount := make(map[string]*destJobCountsT)
 	routerJobResponses := make([]*RouterJobResponse, 0)
 
@@ -701,10 +728,11 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 	})
 
 	for _, destinationJob := range worker.destinationJobs {
-		var attemptedToSendTheJob bool
+		var attemptedToSendTheJob, acquiredConcurrency bool
+		respRetryAfter = 0
 		respBodyArr := make([]string, 0)
 		if destinationJob.StatusCode == 200 || destinationJob.StatusCode == 0 {
-			if worker.canSendJobToDestination(prevRespStatusCode, failedUserIDsMap, destinationJob) {
+			if worker.canSendJobToDestination(prevRespStatusCode, failedOrderingKeysMap, destinationJob) {
 				diagnosisStartTime := time.Now()
 				destinationID := destinationJob.JobMetadataArray[0].DestinationID
 
@@ -721,7 +749,6 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 					"workspace":   workspaceID,
 				})
 				deliveryLatencyStat.Start()
-				startedAt := time.Now()
 
 				// TODO: remove trackStuckDelivery once we verify it is not needed,
 				//			router_delivery_exceeded_timeout -> goes to zero
@@ -741,7 +768,15 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 				// Assuming 10s maximum latency
 				elapsed := time.Since(worker.localResultSet.resultSetBeginTime)
 				threshold := time.Duration(2.0 * math.Max(float64(worker.localResultSet.timeAlloted), float64(10*time.Second)))
-				if elapsed > threshold {
+				timedOut := elapsed > threshold
+				if !timedOut {
+					//findWorker only assigns the jobs the destination has capacity for, so this rarely waits, e.g. when its limit shrank meanwhile
+					acquiredConcurrency = worker.rt.concurrencyLimiter.Acquire(ctx, destinationID) == nil
+					timedOut = !acquiredConcurrency
+				}
+				//the latency of the destination doesn't include the wait for its capacity
+				startedAt := time.Now()
+				if timedOut {
 					respStatusCode = types.RouterTimedOutStatusCode
 					respBody = fmt.Sprintf("%d Jobs took more time than expected. Will be retried", types.RouterTimedOutStatusCode)
 					worker.rt.logger.Debugf(
@@ -771,7 +806,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 								sendCtx, cancel := context.WithTimeout(ctx, worker.rt.netClientTimeout)
 								defer cancel()
 								//transformer proxy start
-								if worker.rt.transformerProxy {
+								if worker.rt.transformerProxy && !worker.rt.authenticatesRequests(destinationID) {
 									rtl_time := time.Now()
 									respStatusCode, respBodyTemp = worker.rt.transformer.ProxyRequest(ctx, val, worker.rt.destName)
 									worker.routerProxyStat.SendTiming(time.Since(rtl_time))
@@ -790,8 +825,8 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 									}
 								} else {
 									rdl_time := time.Now()
-									resp := worker.rt.netHandle.SendPost(sendCtx, val)
-									respStatusCode, respBodyTemp, respContentType = resp.StatusCode, string(resp.ResponseBody), resp.ResponseContentType
+									resp := worker.rt.sendPost(sendCtx, destinationID, val)
+									respStatusCode, respBodyTemp, respContentType, respRetryAfter = resp.StatusCode, string(resp.ResponseBody), resp.ResponseContentType, resp.RetryAfter
 									// stat end
 									worker.routerDeliveryLatencyStat.SendTiming(time.Since(rdl_time))
 								}
@@ -809,6 +844,13 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 				}
 				ch <- struct{}{}
 				timeTaken := time.Since(startedAt)
+				if acquiredConcurrency {
+					worker.rt.concurrencyLimiter.Release(destinationID, concurrency.OutcomeT{
+						StatusCode: respStatusCode,
+						Latency:    timeTaken,
+						RetryAfter: respRetryAfter,
+					})
+				}
 				if respStatusCode != types.RouterTimedOutStatusCode {
 					worker.rt.MultitenantI.UpdateWorkspaceLatencyMap(worker.rt.destName, workspaceID, float64(timeTaken)/float64(time.Second))
 				}
@@ -840,7 +882,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 			} else {
 				respStatusCode = 500
 				if !worker.rt.enableBatching {
-					respBody = "skipping sending to destination because previous job (of user) in batch is failed."
+					respBody = "skipping sending to destination because previous job (with the same ordering key) in batch is failed."
 				}
 			}
 		} else {
@@ -851,8 +893,10 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 		prevRespStatusCode = respStatusCode
 
 		if !isJobTerminated(respStatusCode) {
-			for _, metadata := range destinationJob.JobMetadataArray {
-				failedUserIDsMap[metadata.UserID] = struct{}{}
+			for i := range destinationJob.JobMetadataArray {
+				if key := worker.orderingKeyOf(&destinationJob.JobMetadataArray[i]); key != "" {
+					failedOrderingKeysMap[key] = struct{}{}
+				}
 			}
 		}
 
@@ -872,6 +916,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 				destinationJobMetadata: &_destinationJobMetadata,
 				respStatusCode:         respStatusCode,
 				respBody:               respBody,
+				respRetryAfter:         respRetryAfter,
 				attemptedToSendTheJob:  attemptedToSendTheJob,
 			})
 		}
@@ -946,7 +991,7 @@ func (worker *workerT) handleWorkerDestinationJobs(ctx context.Context) {
 		status.ErrorResponse = []byte(`{}`)
 		status.ErrorCode = strconv.Itoa(respStatusCode)
 
-		worker.postStatusOnResponseQ(respStatusCode, routerJobResponse.respBody, destinationJob.Message, respContentType, destinationJobMetadata, &status)
+		worker.postStatusOnResponseQ(respStatusCode, routerJobResponse.respBody, destinationJob.Message, respContentType, routerJobResponse.respRetryAfter, destinationJobMetadata, &status)
 
 		worker.sendEventDeliveryStat(destinationJobMetadata, &status, &destinationJob.Destination)
 
@@ -985,6 +1030,7 @@ type RouterJobResponse struct {
 	destinationJobMetadata *types.JobMetadataT
 	respStatusCode         int
 	respBody               string
+	respRetryAfter         time.Duration
 	attemptedToSendTheJob  bool
 	status                 *jobsdb.JobStatusT
 }
@@ -1083,7 +1129,7 @@ func (worker *workerT) updateAbortedMetrics(destinationID, statusCode string) {
 }
 
 func (worker *workerT) postStatusOnResponseQ(respStatusCode int, respBody string, payload json.RawMessage,
-	respContentType string, destinationJobMetadata *types.JobMetadataT, status *jobsdb.JobStatusT) {
+	respContentType string, respRetryAfter time.Duration, destinationJobMetadata *types.JobMetadataT, status *jobsdb.JobStatusT) {
 	//Enhancing status.ErrorResponse with firstAttemptedAt
 	firstAttemptedAtTime := time.Now()
 	if destinationJobMetadata.FirstAttemptedAt != "" {
@@ -1098,24 +1144,23 @@ func (worker *workerT) postStatusOnResponseQ(respStatusCode int, respBody string
 	status.ErrorResponse = router_utils.EnhanceJSON(status.ErrorResponse, "response", respBody)
 	status.ErrorResponse = router_utils.EnhanceJSON(status.ErrorResponse, "content-type", respContentType)
 
+	orderingKey := worker.orderingKeyOf(destinationJobMetadata)
 	if isSuccessStatus(respStatusCode) {
 		atomic.AddUint64(&worker.rt.successCount, 1)
 		status.JobState = jobsdb.Succeeded.State
 		worker.rt.logger.Debugf("[%v Router] :: sending success status to response", worker.rt.destName)
-		worker.rt.responseQ <- jobResponseT{status: status, worker: worker, userID: destinationJobMetadata.UserID, JobT: destinationJobMetadata.JobT}
+		worker.rt.responseQ <- jobResponseT{status: status, worker: worker, userID: destinationJobMetadata.UserID, orderingKey: orderingKey, JobT: destinationJobMetadata.JobT}
 
-		if worker.rt.guaranteeUserEventOrder {
-			//Removing the user from aborted user map
+		if orderingKey != "" {
+			//Removing the ordering key from aborted ordering key map
 			worker.abortedUserMutex.Lock()
-			delete(worker.abortedUserIDMap, destinationJobMetadata.UserID)
+			delete(worker.abortedOrderingKeyMap, orderingKey)
 			worker.abortedUserMutex.Unlock()
 		}
 
 		//Deleting jobID from retryForJobMap. jobID goes into retryForJobMap if it is failed with 5xx or 429.
 		//Its safe to delete from the map, even if jobID is not present.
-		worker.retryForJobMapMutex.Lock()
-		delete(worker.retryForJobMap, destinationJobMetadata.JobID)
-		worker.retryForJobMapMutex.Unlock()
+		worker.clearNextRetryTime(destinationJobMetadata.JobID)
 	} else {
 		//Saving payload to DB only
 		//1. if job failed and
@@ -1138,23 +1183,27 @@ func (worker *workerT) postStatusOnResponseQ(respStatusCode int, respBody string
 
 		worker.rt.failedEventsChan <- *status
 
-		if respStatusCode >= 500 {
+		//the wait before the next attempt is at least the one asked for by the Retry-After header of the destination
+		durationBeforeNextAttempt := func(policy retryPolicyT) time.Duration {
+			d := policy.durationBeforeNextAttempt(status.AttemptNum)
+			if respRetryAfter > d {
+				d = respRetryAfter
+			}
+			return d
+		}
+		retryPolicy := worker.rt.retryPolicyOf(destinationJobMetadata.DestinationID)
+		switch {
+		case retryPolicy.classify(respStatusCode) == retryStatus:
 			timeElapsed := time.Since(firstAttemptedAtTime)
-			if timeElapsed > worker.rt.retryTimeWindow && status.AttemptNum >= worker.rt.maxFailedCountForJob {
+			if retryPolicy.exhausted(status.AttemptNum, timeElapsed) {
 				status.JobState = jobsdb.Aborted.State
-				worker.retryForJobMapMutex.Lock()
-				delete(worker.retryForJobMap, destinationJobMetadata.JobID)
-				worker.retryForJobMapMutex.Unlock()
+				worker.clearNextRetryTime(destinationJobMetadata.JobID)
 			} else {
-				worker.retryForJobMapMutex.Lock()
-				worker.retryForJobMap[destinationJobMetadata.JobID] = time.Now().Add(durationBeforeNextAttempt(status.AttemptNum))
-				worker.retryForJobMapMutex.Unlock()
+				worker.setNextRetryTime(destinationJobMetadata.JobID, orderingKey, time.Now().Add(durationBeforeNextAttempt(retryPolicy)))
 			}
-		} else if respStatusCode == 429 {
-			worker.retryForJobMapMutex.Lock()
-			worker.retryForJobMap[destinationJobMetadata.JobID] = time.Now().Add(durationBeforeNextAttempt(status.AttemptNum))
-			worker.retryForJobMapMutex.Unlock()
-		} else {
+		case retryPolicy.classify(respStatusCode) == throttledStatus:
+			worker.setNextRetryTime(destinationJobMetadata.JobID, orderingKey, time.Now().Add(durationBeforeNextAttempt(retryPolicy)))
+		default:
 			status.JobState = jobsdb.Aborted.State
 		}
 
@@ -1162,43 +1211,44 @@ func (worker *workerT) postStatusOnResponseQ(respStatusCode int, respBody string
 			addToFailedMap = false
 			worker.updateAbortedMetrics(destinationJobMetadata.DestinationID, status.ErrorCode)
 			destinationJobMetadata.JobT.Parameters = misc.UpdateJSONWithNewKeyVal(destinationJobMetadata.JobT.Parameters, "stage", "router")
+			destinationJobMetadata.JobT.Parameters = misc.UpdateJSONWithNewKeyVal(destinationJobMetadata.JobT.Parameters, "status_code", status.ErrorCode)
 			destinationJobMetadata.JobT.Parameters = misc.UpdateJSONWithNewKeyVal(destinationJobMetadata.JobT.Parameters, "reason", status.ErrorResponse) //NOTE: Old key used was "error_response"
 		}
 
-		if worker.rt.guaranteeUserEventOrder {
+		if orderingKey != "" {
 			if addToFailedMap {
 				//#JobOrder (see other #JobOrder comment)
 				worker.failedJobIDMutex.RLock()
-				_, isPrevFailedUser := worker.failedJobIDMap[destinationJobMetadata.UserID]
+				_, isPrevFailedKey := worker.failedJobIDMap[orderingKey]
 				worker.failedJobIDMutex.RUnlock()
-				if !isPrevFailedUser && destinationJobMetadata.UserID != "" {
-					worker.rt.logger.Debugf("[%v Router] :: userId %v failed for the first time adding to map", worker.rt.destName, destinationJobMetadata.UserID)
+				if !isPrevFailedKey {
+					worker.rt.logger.Debugf("[%v Router] :: ordering key %v failed for the first time adding to map", worker.rt.destName, orderingKey)
 					worker.failedJobIDMutex.Lock()
-					worker.failedJobIDMap[destinationJobMetadata.UserID] = destinationJobMetadata.JobID
+					worker.failedJobIDMap[orderingKey] = destinationJobMetadata.JobID
 					worker.failedJobIDMutex.Unlock()
 				}
 			} else {
 				//Job is aborted.
-				//So, adding the user to aborted map, if not already present.
-				//If user is present in the aborted map, decrementing the count.
-				//This map is used to limit the pick up of aborted users's job.
+				//So, adding the ordering key to aborted map, if not already present.
+				//If the ordering key is present in the aborted map, decrementing the count.
+				//This map is used to limit the pick up of jobs of aborted ordering keys.
 				worker.abortedUserMutex.Lock()
-				worker.rt.logger.Debugf("[%v Router] :: adding userID to abortedUserMap : %s", worker.rt.destName, destinationJobMetadata.UserID)
-				count, ok := worker.abortedUserIDMap[destinationJobMetadata.UserID]
+				worker.rt.logger.Debugf("[%v Router] :: adding ordering key to abortedOrderingKeyMap : %s", worker.rt.destName, orderingKey)
+				count, ok := worker.abortedOrderingKeyMap[orderingKey]
 				if !ok {
-					worker.abortedUserIDMap[destinationJobMetadata.UserID] = 0
+					worker.abortedOrderingKeyMap[orderingKey] = 0
 				} else {
 					//Decrementing the count.
-					//This is necessary to let other jobs of the same user to get a worker.
+					//This is necessary to let other jobs with the same ordering key to get a worker.
 					count--
-					worker.abortedUserIDMap[destinationJobMetadata.UserID] = count
+					worker.abortedOrderingKeyMap[orderingKey] = count
 				}
 
 				worker.abortedUserMutex.Unlock()
 			}
 		}
 		worker.rt.logger.Debugf("[%v Router] :: sending failed/aborted state as response", worker.rt.destName)
-		worker.rt.responseQ <- jobResponseT{status: status, worker: worker, userID: destinationJobMetadata.UserID, JobT: destinationJobMetadata.JobT}
+		worker.rt.responseQ <- jobResponseT{status: status, worker: worker, userID: destinationJobMetadata.UserID, orderingKey: orderingKey, JobT: destinationJobMetadata.JobT}
 	}
 }
 
@@ -1260,42 +1310,49 @@ func (worker *workerT) sendDestinationResponseToConfigBackend(payload json.RawMe
 	destinationdebugger.RecordEventDeliveryStatus(destinationJobMetadata.DestinationID, &deliveryStatus)
 }
 
-func (worker *workerT) handleJobForPrevFailedUser(job *jobsdb.JobT, parameters JobParametersT, userID string, previousFailedJobID int64) (markedAsWaiting bool) {
-	// job is behind in queue of failed job from same user
+func (worker *workerT) handleJobForPrevFailedKey(job *jobsdb.JobT, ordering jobOrderingT, previousFailedJobID int64) (markedAsWaiting bool) {
+	orderingKey := ordering.key
+	// job is behind in queue of failed job with the same ordering key
 	if previousFailedJobID < job.JobID {
-		worker.rt.logger.Debugf("[%v Router] :: skipping processing job for userID: %v since prev failed job exists, prev id %v, current id %v", worker.rt.destName, userID, previousFailedJobID, job.JobID)
-		resp := fmt.Sprintf(`{"blocking_id":"%v", "user_id":"%s"}`, previousFailedJobID, userID)
+		worker.rt.logger.Debugf("[%v Router] :: skipping processing job for ordering key: %v since prev failed job exists, prev id %v, current id %v", worker.rt.destName, orderingKey, previousFailedJobID, job.JobID)
+		resp, _ := json.Marshal(map[string]string{"blocking_id": strconv.FormatInt(previousFailedJobID, 10), "user_id": job.UserID, "ordering_key": orderingKey})
 		status := jobsdb.JobStatusT{
 			JobID:         job.JobID,
 			AttemptNum:    job.LastJobStatus.AttemptNum,
 			ExecTime:      time.Now(),
 			RetryTime:     time.Now(),
 			JobState:      jobsdb.Waiting.State,
-			ErrorResponse: []byte(resp), // check
+			ErrorResponse: resp,
 			Parameters:    []byte(`{}`),
 			WorkspaceId:   job.WorkspaceId,
 		}
-		worker.rt.responseQ <- jobResponseT{status: &status, worker: worker, userID: userID, JobT: job}
+		worker.rt.responseQ <- jobResponseT{status: &status, worker: worker, userID: job.UserID, orderingKey: orderingKey, JobT: job}
 		return true
 	}
 	if previousFailedJobID != job.JobID {
-		panic(fmt.Errorf("previousFailedJobID:%d != job.JobID:%d", previousFailedJobID, job.JobID))
+		worker.rt.orderingViolated(job, ordering, previousFailedJobID)
 	}
 	return false
 }
 
-func durationBeforeNextAttempt(attempt int) (d time.Duration) {
-	b := backoff.NewExponentialBackOff()
-	b.InitialInterval = minRetryBackoff
-	b.MaxInterval = maxRetryBackoff
-	b.RandomizationFactor = 0
-	b.MaxElapsedTime = 0
-	b.Multiplier = 2
-	b.Reset()
-	for index := 0; index < attempt; index++ {
-		d = b.NextBackOff()
+//authenticatesRequests returns true if the requests to the destination are signed or use mtls, so they must be sent directly instead of through the transformer proxy
+func (rt *HandleT) authenticatesRequests(destinationID string) bool {
+	rt.configSubscriberLock.RLock()
+	defer rt.configSubscriberLock.RUnlock()
+	_, ok := rt.destinationRequestAuth[destinationID]
+	return ok
+}
+
+//sendPost sends the request to the destination, authenticated as the destination config sets
+func (rt *HandleT) sendPost(ctx context.Context, destinationID string, structData integrations.PostParametersT) *router_utils.SendPostResponse {
+	rt.configSubscriberLock.RLock()
+	ctx = withRequestAuth(ctx, rt.destinationRequestAuth[destinationID])
+	rt.configSubscriberLock.RUnlock()
+	resp := rt.netHandle.SendPost(ctx, structData)
+	if resp.RetryAfter > maxRetryAfter {
+		resp.RetryAfter = maxRetryAfter
 	}
-	return
+	return resp
 }
 
 func (rt *HandleT) addToFailedList(jobStatus jobsdb.JobStatusT) {
@@ -1338,6 +1395,8 @@ func (rt *HandleT) initWorkers() {
 			channel:                   make(chan workerMessageT, noOfJobsPerChannel),
 			failedJobIDMap:            make(map[string]int64),
 			retryForJobMap:            make(map[int64]time.Time),
+			retryingUnorderedJobs:     make(map[int64]struct{}),
+			jobOrderingKeys:           make(map[int64]string),
 			workerID:                  i,
 			failedJobs:                0,
 			sleepTime:                 minSleep,
@@ -1348,7 +1407,7 @@ func (rt *HandleT) initWorkers() {
 			batchTimeStat:             stats.NewTaggedStat("router_batch_time", stats.TimerType, stats.Tags{"destType": rt.destName}),
 			routerDeliveryLatencyStat: stats.NewTaggedStat("router_delivery_latency", stats.TimerType, stats.Tags{"destType": rt.destName}),
 			routerProxyStat:           stats.NewTaggedStat("router_proxy_latency", stats.TimerType, stats.Tags{"destType": rt.destName}),
-			abortedUserIDMap:          make(map[string]int),
+			abortedOrderingKeyMap:     make(map[string]int),
 			jobCountsByDestAndUser:    make(map[string]*destJobCountsT),
 			localResultSet:            &resultSetT{resultSetBeginTime: time.Now()},
 		}
@@ -1378,90 +1437,121 @@ func (rt *HandleT) stopWorkers() {
 	}
 }
 
-func (rt *HandleT) findWorker(job *jobsdb.JobT, throttledAtTime time.Time) (toSendWorker *workerT) {
+func (rt *HandleT) findWorker(job *jobsdb.JobT, throttledAtTime time.Time) (toSendWorker *workerT, ordering jobOrderingT) {
 	if rt.backgroundCtx.Err() != nil {
-		return nil
+		return nil, ordering
 	}
 
 	//checking if this job can be throttled
 	var parameters JobParametersT
 	userID := job.UserID
 
-	//checking if the user is in throttledMap. If yes, returning nil.
-	//this check is done to maintain order.
-	if _, ok := rt.throttledUserMap[userID]; ok {
-		rt.logger.Debugf(`[%v Router] :: Skipping processing of job:%d of user:%s as user has earlier jobs in throttled map`, rt.destName, job.JobID, userID)
-		return nil
-	}
-
 	err := json.Unmarshal(job.Parameters, &parameters)
 
 	if err != nil {
 		rt.logger.Errorf(`[%v Router] :: Unmarshalling parameters failed with the error %v . Returning nil worker`, err)
-		return nil
+		return nil, ordering
+	}
+
+	ordering = rt.jobOrdering(job, parameters.DestinationID)
+	orderingKey := ordering.key
+	orderingStats := rt.orderingStats[ordering.mode]
+
+	//checking if the ordering key is in throttledMap. If yes, returning nil.
+	//this check is done to maintain order.
+	if _, ok := rt.throttledOrderingKeyMap[orderingKey]; ok && orderingKey != "" {
+		rt.logger.Debugf(`[%v Router] :: Skipping processing of job:%d of ordering key:%s as it has earlier jobs in throttled map`, rt.destName, job.JobID, orderingKey)
+		orderingStats.blocked.Increment()
+		return nil, ordering
+	}
+
+	//jobs are only assigned to the destinations with capacity, counting the ones assigned before, so that workers don't wait for it
+	if !rt.concurrencyLimiter.HasCapacity(parameters.DestinationID, rt.concurrencyLimitedJobs[parameters.DestinationID]) {
+		if orderingKey != "" {
+			rt.throttledOrderingKeyMap[orderingKey] = struct{}{}
+		}
+		rt.logger.Debugf(`[%v Router] :: Skipping processing of job:%d as the concurrency limit of destination:%s is reached`, rt.destName, job.JobID, parameters.DestinationID)
+		return nil, ordering
 	}
 
 	if rt.shouldThrottle(parameters.DestinationID, userID, throttledAtTime) {
-		rt.throttledUserMap[userID] = struct{}{}
+		if orderingKey != "" {
+			rt.throttledOrderingKeyMap[orderingKey] = struct{}{}
+		}
 		rt.logger.Debugf(`[%v Router] :: Skipping processing of job:%d of user:%s as throttled limits exceeded`, rt.destName, job.JobID, userID)
-		return nil
+		return nil, ordering
 	}
 
-	if !rt.guaranteeUserEventOrder {
-		//if guaranteeUserEventOrder is false, assigning worker randomly and returning here.
-		return rt.workers[rand.Intn(rt.noOfWorkers)]
+	if orderingKey == "" {
+		//unordered jobs are spread across workers by job id, so that a failed job backs off in the worker it failed in
+		toSendWorker = rt.workers[job.JobID%int64(rt.noOfWorkers)]
+		if toSendWorker.canBackoff(job, userID) {
+			return nil, ordering
+		}
+		if toSendWorker.unorderedBackpressure(job) {
+			rt.logger.Debugf(`[%v Router] :: Skipping processing of job:%d as maxFailingUnorderedJobs(%d) jobs of the worker are waiting for a retry`, rt.destName, job.JobID, rt.maxFailingUnorderedJobs)
+			orderingStats.blocked.Increment()
+			return nil, ordering
+		}
+		orderingStats.assigned.Increment()
+		return toSendWorker, ordering
 	}
 
-	index := int(math.Abs(float64(misc.GetHash(userID) % rt.noOfWorkers)))
+	index := int(math.Abs(float64(misc.GetHash(orderingKey) % rt.noOfWorkers)))
 
 	worker := rt.workers[index]
 
 	//#JobOrder (see other #JobOrder comment)
 	worker.failedJobIDMutex.RLock()
 	defer worker.failedJobIDMutex.RUnlock()
-	blockJobID, found := worker.failedJobIDMap[userID]
+	blockJobID, found := worker.failedJobIDMap[orderingKey]
 	if !found {
-		//not a failed user
-		//checking if he is an aborted user,
-		//if yes returning worker only for 1 job
+		//not a failed ordering key
+		//checking if it is an aborted ordering key,
+		//if yes returning worker only for allowAbortedJobsCountForProcessing jobs
 		worker.abortedUserMutex.Lock()
 		defer worker.abortedUserMutex.Unlock()
-		if count, ok := worker.abortedUserIDMap[userID]; ok {
-			if count >= rt.allowAbortedUserJobsCountForProcessing {
-				rt.logger.Debugf("[%v Router] :: allowed jobs count(%d) >= allowAbortedUserJobsCountForProcessing(%d) for userID %s. returning nil worker", rt.destName, count, rt.allowAbortedUserJobsCountForProcessing, userID)
-				return nil
+		if count, ok := worker.abortedOrderingKeyMap[orderingKey]; ok {
+			allowAbortedJobsCount := rt.allowAbortedJobsCountForProcessing(ordering.mode)
+			if count >= allowAbortedJobsCount {
+				rt.logger.Debugf("[%v Router] :: allowed jobs count(%d) >= allowAbortedJobsCountForProcessing(%d) for ordering key %s. returning nil worker", rt.destName, count, allowAbortedJobsCount, orderingKey)
+				orderingStats.blocked.Increment()
+				return nil, ordering
 			}
 
-			rt.logger.Debugf("[%v Router] :: userID found in abortedUserIDtoJobMap: %s. Allowing jobID: %d. returning worker", rt.destName, userID, job.JobID)
-			// incrementing abortedUserIDMap after all checks of backoff, throttle etc are made
+			rt.logger.Debugf("[%v Router] :: ordering key found in abortedOrderingKeyMap: %s. Allowing jobID: %d. returning worker", rt.destName, orderingKey, job.JobID)
+			// incrementing abortedOrderingKeyMap after all checks of backoff, throttle etc are made
 			// We don't need lock inside this defer func, because we already hold the lock above and this
 			// defer is called before defer Unlock
 			defer func() {
 				if toSendWorker != nil {
-					toSendWorker.abortedUserIDMap[userID] = toSendWorker.abortedUserIDMap[userID] + 1
+					toSendWorker.abortedOrderingKeyMap[orderingKey] = toSendWorker.abortedOrderingKeyMap[orderingKey] + 1
 				}
 			}()
 		}
 		toSendWorker = worker
 	} else {
-		//This job can only be higher than blocking
+		//This job can only be higher than blocking, unless the delivery ordering of the destination changed
 		//We only let the blocking job pass
-		if job.JobID < blockJobID {
-			panic(fmt.Errorf("job.JobID:%d < blockJobID:%d", job.JobID, blockJobID))
-		}
 		if job.JobID == blockJobID {
 			toSendWorker = worker
+		} else if job.JobID < blockJobID {
+			rt.orderingViolated(job, ordering, blockJobID)
+			toSendWorker = worker
+		} else {
+			orderingStats.blocked.Increment()
 		}
 	}
 
 	//checking if this job can be backedoff
 	if toSendWorker != nil {
 		if toSendWorker.canBackoff(job, userID) {
-			return nil
+			return nil, ordering
 		}
+		orderingStats.assigned.Increment()
 	}
 
-	return toSendWorker
+	return toSendWorker, ordering
 	//#EndJobOrder
 }
 
@@ -1638,30 +1728,28 @@ func (rt *HandleT) commitStatusList(responseList *[]jobResponseT) {
 		rt.jobsDB.ReleaseUpdateJobStatusLocks()
 	}
 
-	if rt.guaranteeUserEventOrder {
-		//#JobOrder (see other #JobOrder comment)
-		for _, resp := range *responseList {
-			status := resp.status.JobState
-			userID := resp.userID
-			worker := resp.worker
-			if status == jobsdb.Succeeded.State || status == jobsdb.Aborted.State {
-				worker.failedJobIDMutex.RLock()
-				lastJobID, ok := worker.failedJobIDMap[userID]
-				worker.failedJobIDMutex.RUnlock()
-				if ok && lastJobID == resp.status.JobID {
-					rt.toClearFailJobIDMutex.Lock()
-					rt.logger.Debugf("[%v Router] :: clearing failedJobIDMap for userID: %v", rt.destName, userID)
-					_, ok := rt.toClearFailJobIDMap[worker.workerID]
-					if !ok {
-						rt.toClearFailJobIDMap[worker.workerID] = make([]string, 0)
-					}
-					rt.toClearFailJobIDMap[worker.workerID] = append(rt.toClearFailJobIDMap[worker.workerID], userID)
-					rt.toClearFailJobIDMutex.Unlock()
+	//#JobOrder (see other #JobOrder comment)
+	for _, resp := range *responseList {
+		status := resp.status.JobState
+		orderingKey := resp.orderingKey
+		worker := resp.worker
+		if orderingKey != "" && (status == jobsdb.Succeeded.State || status == jobsdb.Aborted.State) {
+			worker.failedJobIDMutex.RLock()
+			lastJobID, ok := worker.failedJobIDMap[orderingKey]
+			worker.failedJobIDMutex.RUnlock()
+			if ok && lastJobID == resp.status.JobID {
+				rt.toClearFailJobIDMutex.Lock()
+				rt.logger.Debugf("[%v Router] :: clearing failedJobIDMap for ordering key: %v", rt.destName, orderingKey)
+				_, ok := rt.toClearFailJobIDMap[worker.workerID]
+				if !ok {
+					rt.toClearFailJobIDMap[worker.workerID] = make([]string, 0)
 				}
+				rt.toClearFailJobIDMap[worker.workerID] = append(rt.toClearFailJobIDMap[worker.workerID], orderingKey)
+				rt.toClearFailJobIDMutex.Unlock()
 			}
 		}
-		//End #JobOrder
 	}
+	//End #JobOrder
 }
 
 // statusInsertLoop will run in a separate goroutine
@@ -1838,6 +1926,7 @@ func (rt *HandleT) generatorLoop(ctx context.Context) {
 	generatorStat := stats.NewTaggedStat("router_generator_loop", stats.TimerType, stats.Tags{"destType": rt.destName})
 	countStat := stats.NewTaggedStat("router_generator_events", stats.CountType, stats.Tags{"destType": rt.destName})
 
+	rt.subscribeToJobsDB(ctx)
 	timeout := time.After(10 * time.Millisecond)
 	for {
 		select {
@@ -1869,27 +1958,50 @@ func (rt *HandleT) generatorLoop(ctx context.Context) {
 			if timeToSleep < fixedLoopSleep {
 				timeToSleep = fixedLoopSleep
 			}
-			time.Sleep(timeToSleep)
+			rt.sleepUntilJobsStored(timeToSleep)
 		}
 	}
 }
 
+//subscribeToJobsDB makes the generator loop wake up as soon as jobs of the destination type are stored in the jobsdb, instead of
+//sleeping between reads, if the jobsdb announces the jobs stored (see jobsdb.SubscriberI).
+//Jobs are still read by readAndProcess, so the ones to retry are picked up with the new ones
+func (rt *HandleT) subscribeToJobsDB(ctx context.Context) {
+	if subscriber, ok := rt.jobsDB.(jobsdb.SubscriberI); ok && subscriber.StoreNotificationsEnabled() {
+		rt.logger.Infof("[%v Router] :: Subscribing to the jobs stored in the jobsdb", rt.destName)
+		rt.storedJobs = subscriber.Subscribe(ctx, jobsdb.GetQueryParamsT{CustomValFilters: []string{rt.destName}, JobCount: jobQueryBatchSize})
+	}
+}
+
+//sleepUntilJobsStored sleeps for d, or until jobs are stored if the router subscribed to the jobsdb.
+//The router goes back to sleeping for d once the subscription is closed
+func (rt *HandleT) sleepUntilJobsStored(d time.Duration) {
+	select {
+	case _, ok := <-rt.storedJobs:
+		if ok {
+			return
+		}
+		rt.logger.Infof("[%v Router] :: Subscription to the jobsdb was closed, polling it", rt.destName)
+		rt.storedJobs = nil
+		time.Sleep(d)
+	case <-time.After(d):
+	}
+}
+
 func (rt *HandleT) readAndProcess() int {
-	if rt.guaranteeUserEventOrder {
-		//#JobOrder (See comment marked #JobOrder
-		rt.toClearFailJobIDMutex.Lock()
-		for idx := range rt.toClearFailJobIDMap {
-			wrk := rt.workers[idx]
-			wrk.failedJobIDMutex.Lock()
-			for _, userID := range rt.toClearFailJobIDMap[idx] {
-				delete(wrk.failedJobIDMap, userID)
-			}
-			wrk.failedJobIDMutex.Unlock()
+	//#JobOrder (See comment marked #JobOrder
+	rt.toClearFailJobIDMutex.Lock()
+	for idx := range rt.toClearFailJobIDMap {
+		wrk := rt.workers[idx]
+		wrk.failedJobIDMutex.Lock()
+		for _, orderingKey := range rt.toClearFailJobIDMap[idx] {
+			delete(wrk.failedJobIDMap, orderingKey)
 		}
-		rt.toClearFailJobIDMap = make(map[int][]string)
-		rt.toClearFailJobIDMutex.Unlock()
-		//End of #JobOrder
+		wrk.failedJobIDMutex.Unlock()
 	}
+	rt.toClearFailJobIDMap = make(map[int][]string)
+	rt.toClearFailJobIDMutex.Unlock()
+	//End of #JobOrder
 
 	timeOut := rt.routerTimeout
 
@@ -1909,7 +2021,7 @@ func (rt *HandleT) readAndProcess() int {
 
 	if len(combinedList) == 0 {
 		rt.logger.Debugf("RT: DB Read Complete. No RT Jobs to process for destination: %s", rt.destName)
-		time.Sleep(readSleep)
+		rt.sleepUntilJobsStored(readSleep)
 		return 0
 	}
 
@@ -1924,8 +2036,9 @@ func (rt *HandleT) readAndProcess() int {
 
 	//List of jobs which can be processed mapped per channel
 	type workerJobT struct {
-		worker *workerT
-		job    *jobsdb.JobT
+		worker   *workerT
+		job      *jobsdb.JobT
+		ordering jobOrderingT
 	}
 
 	var statusList []*jobsdb.JobStatusT
@@ -1935,7 +2048,8 @@ func (rt *HandleT) readAndProcess() int {
 
 	var toProcess []workerJobT
 
-	rt.throttledUserMap = make(map[string]struct{})
+	rt.throttledOrderingKeyMap = make(map[string]struct{})
+	rt.concurrencyLimitedJobs = make(map[string]int)
 	throttledAtTime := time.Now()
 	//Identify jobs which can be processed
 	for _, job := range combinedList {
@@ -1977,7 +2091,7 @@ func (rt *HandleT) readAndProcess() int {
 			rt.MultitenantI.CalculateSuccessFailureCounts(job.WorkspaceId, rt.destName, false, true)
 			continue
 		}
-		w := rt.findWorker(job, throttledAtTime)
+		w, ordering := rt.findWorker(job, throttledAtTime)
 		if w != nil {
 			status := jobsdb.JobStatusT{
 				JobID:         job.JobID,
@@ -1991,10 +2105,14 @@ func (rt *HandleT) readAndProcess() int {
 				WorkspaceId:   job.WorkspaceId,
 			}
 			statusList = append(statusList, &status)
-			toProcess = append(toProcess, workerJobT{worker: w, job: job})
+			toProcess = append(toProcess, workerJobT{worker: w, job: job, ordering: ordering})
+			if rt.concurrencyLimiter.IsEnabled() {
+				rt.concurrencyLimitedJobs[destID]++
+			}
 		}
 	}
-	rt.throttledUserMap = nil
+	rt.throttledOrderingKeyMap = nil
+	rt.concurrencyLimitedJobs = nil
 
 	//Mark the jobs as executing
 	err := rt.jobsDB.UpdateJobStatus(statusList, []string{rt.destName}, nil)
@@ -2038,7 +2156,7 @@ func (rt *HandleT) readAndProcess() int {
 
 	//Send the jobs to the jobQ
 	for _, wrkJob := range toProcess {
-		wrkJob.worker.channel <- workerMessageT{job: wrkJob.job, throttledAtTime: throttledAtTime, workerAssignedTime: time.Now(), resultSetID: rt.getLastResultSetID()}
+		wrkJob.worker.channel <- workerMessageT{job: wrkJob.job, ordering: wrkJob.ordering, throttledAtTime: throttledAtTime, workerAssignedTime: time.Now(), resultSetID: rt.getLastResultSetID()}
 	}
 
 	return len(toProcess)
@@ -2131,6 +2249,9 @@ func (rt *HandleT) Setup(backendConfig backendconfig.BackendConfig, jobsDB jobsd
 	config.RegisterBoolConfigVariable(false, &rt.saveDestinationResponseOverride, true, saveDestinationResponseOverrideKeys...)
 
 	rt.allowAbortedUserJobsCountForProcessing = getRouterConfigInt("allowAbortedUserJobsCountForProcessing", destName, 1)
+	rt.allowAbortedPartitionKeyJobsCountForProcessing = getRouterConfigInt("allowAbortedPartitionKeyJobsCountForProcessing", destName, 1)
+	rt.maxFailingUnorderedJobs = getRouterConfigInt("maxFailingUnorderedJobs", destName, 100)
+	rt.setupOrderingStats()
 
 	rt.batchInputCountStat = stats.NewTaggedStat("router_batch_num_input_jobs", stats.CountType, stats.Tags{
 		"destType": rt.destName,
@@ -2162,6 +2283,9 @@ func (rt *HandleT) Setup(backendConfig backendconfig.BackendConfig, jobsDB jobsd
 	throttler.SetUp(rt.destName)
 	rt.throttler = &throttler
 
+	rt.concurrencyLimiter = &concurrency.HandleT{}
+	rt.concurrencyLimiter.SetUp(rt.destName, rt.noOfWorkers)
+
 	rt.isBackendConfigInitialized = false
 	rt.backendConfigInitialized = make(chan bool)
 
@@ -2223,6 +2347,9 @@ func (rt *HandleT) backendConfigSubscriber() {
 		config := <-ch
 		rt.configSubscriberLock.Lock()
 		rt.destinationsMap = map[string]*router_utils.BatchDestinationT{}
+		rt.destinationOrdering = map[string]orderingT{}
+		rt.destinationRetryPolicies = map[string]retryPolicyOverrideT{}
+		rt.destinationRequestAuth = map[string]*requestAuthT{}
 		allSources := config.Data.(backendconfig.ConfigT)
 		rt.sourceIDWorkspaceMap = map[string]string{}
 		for _, source := range allSources.Sources {
@@ -2237,6 +2364,14 @@ func (rt *HandleT) backendConfigSubscriber() {
 					if destination.DestinationDefinition.Name == rt.destName {
 						if _, ok := rt.destinationsMap[destination.ID]; !ok {
 							rt.destinationsMap[destination.ID] = &router_utils.BatchDestinationT{Destination: destination, Sources: []backendconfig.SourceT{}}
+							rt.destinationOrdering[destination.ID] = rt.orderingOf(destination)
+							rt.destinationRetryPolicies[destination.ID] = rt.retryPolicyFor(destination)
+							if auth := parseRequestAuth(destination); auth != nil {
+								if auth.err != nil {
+									rt.logger.Errorf("[%v Router] :: requests to destination %s will fail: %v", rt.destName, destination.ID, auth.err)
+								}
+								rt.destinationRequestAuth[destination.ID] = auth
+							}
 						}
 						rt.destinationsMap[destination.ID].Sources = append(rt.destinationsMap[destination.ID].Sources, source)
 
@@ -2248,6 +2383,9 @@ func (rt *HandleT) backendConfigSubscriber() {
 				}
 			}
 		}
+		if evicter, ok := rt.netHandle.(mtlsClientsEvicterI); ok {
+			evicter.evictMTLSClients(rt.destinationRequestAuth)
+		}
 		if !rt.isBackendConfigInitialized {
 			rt.isBackendConfigInitialized = true
 			rt.backendConfigInitialized <- true
@@ -2318,7 +2456,8 @@ func (rt *HandleT) Pause() {
 	for _, worker := range rt.workers {
 		worker.failedJobIDMap = make(map[string]int64)
 		worker.retryForJobMap = make(map[int64]time.Time)
-		worker.abortedUserIDMap = make(map[string]int)
+		worker.retryingUnorderedJobs = make(map[int64]struct{})
+		worker.abortedOrderingKeyMap = make(map[string]int)
 	}
 
 	rt.paused = true
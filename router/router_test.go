@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"os"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -252,6 +253,128 @@ var _ = Describe("Router", func() {
 			router.Shutdown()
 		})
 
+		It("skips jobs without writing their status while the concurrency limit of the destination is reached", func() {
+			for key, value := range map[string]string{"enabled": "true", "initialLimit": "1", "maxLimit": "1"} {
+				envKey := config.TransformKey("Router.GA.concurrencyLimiter." + key)
+				os.Setenv(envKey, value)
+				defer os.Unsetenv(envKey)
+			}
+			mockMultitenantHandle := mocksMultitenant.NewMockMultiTenantI(c.mockCtrl)
+			mockNetHandle := mocksRouter.NewMockNetHandleI(c.mockCtrl)
+			router := &HandleT{
+				Reporting:    &reportingNOOP{},
+				MultitenantI: mockMultitenantHandle,
+				netHandle:    mockNetHandle,
+			}
+			mockMultitenantHandle.EXPECT().UpdateWorkspaceLatencyMap(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			router.Setup(c.mockBackendConfig, c.mockRouterJobsDB, c.mockProcErrorsDB, gaDestinationDefinition)
+			// another request to the destination is in flight
+			Expect(router.concurrencyLimiter.TryAcquire(gaDestinationID)).To(BeTrue())
+
+			gaPayload := `{"body": {"XML": {}, "FORM": {}, "JSON": {}}, "type": "REST", "files": {}, "method": "POST", "params": {"t": "event", "v": "1"}, "userId": "anon_id", "headers": {}, "version": "1", "endpoint": "https://www.google-analytics.com/collect"}`
+			parameters := fmt.Sprintf(`{"source_id": "1fMCVYZboDlYlauh4GFsEo2JU77", "destination_id": "%s", "message_id": "2f548e6d-60f6-44af-a1f4-62b3272445c3", "received_at": "2021-06-28T10:04:48.527+05:30", "transform_at": "processor"}`, gaDestinationID)
+			var toRetryJobsList = []*jobsdb.JobT{
+				{
+					UUID:         uuid.Must(uuid.NewV4()),
+					UserID:       "u1",
+					JobID:        2009,
+					CreatedAt:    time.Date(2020, 04, 28, 13, 26, 00, 00, time.UTC),
+					ExpireAt:     time.Date(2020, 04, 28, 13, 26, 00, 00, time.UTC),
+					CustomVal:    customVal["GA"],
+					EventPayload: []byte(gaPayload),
+					LastJobStatus: jobsdb.JobStatusT{
+						AttemptNum:    1,
+						ErrorResponse: []byte(`{"firstAttemptedAt": "2021-06-28T15:57:30.742+05:30"}`),
+					},
+					Parameters:  []byte(parameters),
+					WorkspaceId: workspaceID,
+				},
+			}
+			var workspaceCount = map[string]int{workspaceID: len(toRetryJobsList)}
+
+			callGetRouterPickupJobs := mockMultitenantHandle.EXPECT().GetRouterPickupJobs(customVal["GA"], gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(workspaceCount, map[string]float64{}).Times(1)
+			callGetAllJobs := c.mockRouterJobsDB.EXPECT().GetAllJobs(workspaceCount,
+				jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal["GA"]}}, 10).Times(1).Return(toRetryJobsList).After(callGetRouterPickupJobs)
+			c.mockRouterJobsDB.EXPECT().UpdateJobStatus(gomock.Any(), []string{customVal["GA"]}, nil).Times(1).After(callGetAllJobs).
+				Do(func(statuses []*jobsdb.JobStatusT, _ interface{}, _ interface{}) {
+					Expect(statuses).To(BeEmpty(), "jobs over the limit are not marked as executing")
+				}).Return(nil)
+
+			mockNetHandle.EXPECT().SendPost(gomock.Any(), gomock.Any()).Times(0)
+			c.mockRouterJobsDB.EXPECT().BeginGlobalTransaction().Times(0)
+			c.mockRouterJobsDB.EXPECT().UpdateJobStatusInTxn(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+			<-router.backendConfigInitialized
+			count := router.readAndProcess()
+			Expect(count).To(Equal(0), "jobs over the limit are skipped without writing any status")
+			_, backingOff := retryTimeOf(router, toRetryJobsList[0].JobID)
+			Expect(backingOff).To(BeFalse())
+			router.Shutdown()
+		})
+
+		It("waits at least as long as the destination asks for with Retry-After before retrying a job", func() {
+			mockMultitenantHandle := mocksMultitenant.NewMockMultiTenantI(c.mockCtrl)
+			mockNetHandle := mocksRouter.NewMockNetHandleI(c.mockCtrl)
+			router := &HandleT{
+				Reporting:    &reportingNOOP{},
+				MultitenantI: mockMultitenantHandle,
+				netHandle:    mockNetHandle,
+			}
+			mockMultitenantHandle.EXPECT().UpdateWorkspaceLatencyMap(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+
+			router.Setup(c.mockBackendConfig, c.mockRouterJobsDB, c.mockProcErrorsDB, gaDestinationDefinition)
+			Expect(router.concurrencyLimiter.IsEnabled()).To(BeFalse())
+
+			gaPayload := `{"body": {"XML": {}, "FORM": {}, "JSON": {}}, "type": "REST", "files": {}, "method": "POST", "params": {"t": "event", "v": "1"}, "userId": "anon_id", "headers": {}, "version": "1", "endpoint": "https://www.google-analytics.com/collect"}`
+			parameters := fmt.Sprintf(`{"source_id": "1fMCVYZboDlYlauh4GFsEo2JU77", "destination_id": "%s", "message_id": "2f548e6d-60f6-44af-a1f4-62b3272445c3", "received_at": "2021-06-28T10:04:48.527+05:30", "transform_at": "processor"}`, gaDestinationID)
+			var unprocessedJobsList = []*jobsdb.JobT{
+				{
+					UUID:         uuid.Must(uuid.NewV4()),
+					UserID:       "u1",
+					JobID:        2010,
+					CreatedAt:    time.Now(),
+					ExpireAt:     time.Now(),
+					CustomVal:    customVal["GA"],
+					EventPayload: []byte(gaPayload),
+					Parameters:   []byte(parameters),
+					WorkspaceId:  workspaceID,
+				},
+			}
+			var workspaceCount = map[string]int{workspaceID: len(unprocessedJobsList)}
+
+			callGetRouterPickupJobs := mockMultitenantHandle.EXPECT().GetRouterPickupJobs(customVal["GA"], gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(workspaceCount, map[string]float64{}).Times(1)
+			callGetAllJobs := c.mockRouterJobsDB.EXPECT().GetAllJobs(workspaceCount,
+				jobsdb.GetQueryParamsT{CustomValFilters: []string{customVal["GA"]}}, 10).Times(1).Return(unprocessedJobsList).After(callGetRouterPickupJobs)
+			c.mockRouterJobsDB.EXPECT().UpdateJobStatus(gomock.Any(), []string{customVal["GA"]}, nil).Times(1).Return(nil).After(callGetAllJobs)
+
+			mockNetHandle.EXPECT().SendPost(gomock.Any(), gomock.Any()).Times(1).Return(
+				&routerUtils.SendPostResponse{StatusCode: 503, ResponseBody: []byte(""), RetryAfter: time.Hour})
+			mockMultitenantHandle.EXPECT().CalculateSuccessFailureCounts(gomock.Any(), gomock.Any(), false, false).AnyTimes()
+
+			done := make(chan struct{})
+			callBeginTransaction := c.mockRouterJobsDB.EXPECT().BeginGlobalTransaction().Times(1).Return(nil)
+			callAcquireLocks := c.mockRouterJobsDB.EXPECT().AcquireUpdateJobStatusLocks().Times(1).After(callBeginTransaction)
+			callUpdateStatus := c.mockRouterJobsDB.EXPECT().UpdateJobStatusInTxn(gomock.Any(), gomock.Any(), []string{customVal["GA"]}, nil).Times(1).After(callAcquireLocks).
+				Do(func(_ interface{}, statuses []*jobsdb.JobStatusT, _ interface{}, _ interface{}) {
+					assertJobStatus(unprocessedJobsList[0], statuses[0], jobsdb.Failed.State, "503", ``, 1)
+				})
+			callCommitTransaction := c.mockRouterJobsDB.EXPECT().CommitTransaction(gomock.Any()).Times(1).After(callUpdateStatus)
+			c.mockRouterJobsDB.EXPECT().ReleaseUpdateJobStatusLocks().DoAndReturn(
+				func() {
+					close(done)
+				},
+			).Times(1).After(callCommitTransaction)
+
+			<-router.backendConfigInitialized
+			count := router.readAndProcess()
+			Expect(count).To(Equal(1))
+			<-done
+			retryAt, _ := retryTimeOf(router, unprocessedJobsList[0].JobID)
+			Expect(retryAt).To(BeTemporally("~", time.Now().Add(maxRetryAfter), 10*time.Second), "Retry-After is honored up to Router.maxRetryAfter")
+			router.Shutdown()
+		})
+
 		It("should abort unprocessed jobs to ga destination because of bad payload", func() {
 			mockMultitenantHandle := mocksMultitenant.NewMockMultiTenantI(c.mockCtrl)
 
@@ -1123,6 +1246,19 @@ func assertRouterJobs(routerJob *types.RouterJobT, job *jobsdb.JobT) {
 	Expect(routerJob.JobMetadata.UserID).To(Equal(job.UserID))
 }
 
+// retryTimeOf returns when the worker of the job retries it, if it is backing off
+func retryTimeOf(router *HandleT, jobID int64) (time.Time, bool) {
+	for _, worker := range router.workers {
+		worker.retryForJobMapMutex.Lock()
+		retryAt, ok := worker.retryForJobMap[jobID]
+		worker.retryForJobMapMutex.Unlock()
+		if ok {
+			return retryAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
 func assertJobStatus(job *jobsdb.JobT, status *jobsdb.JobStatusT, expectedState string, errorCode string, errorResponse string, attemptNum int) {
 	Expect(status.JobID).To(Equal(job.JobID))
 	Expect(status.JobState).To(Equal(expectedState))
@@ -0,0 +1,125 @@
+package ratelimiter
+
+import (
+	"sync"
+	"time"
+)
+
+// fallbackT decides whether a store is used or its local fallback. After the store fails, the fallback is used for retryInterval before trying the store again,
+// so that an unreachable store doesn't slow down every request.
+// The limits shared through the store are split between the nodes sharing them while the fallback is used, so that the nodes together don't exceed them
+type fallbackT struct {
+	retryInterval time.Duration
+	onError       func(err error)
+	nodes         func() int64
+
+	mutex    sync.RWMutex
+	failedAt time.Time
+}
+
+// noOfNodes returns the number of nodes sharing the limits of the store, at least 1
+func (f *fallbackT) noOfNodes() int64 {
+	if f.nodes == nil {
+		return 1
+	}
+	if n := f.nodes(); n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (f *fallbackT) useStore() bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return time.Since(f.failedAt) >= f.retryInterval
+}
+
+func (f *fallbackT) failed(err error) {
+	f.mutex.Lock()
+	f.failedAt = time.Now()
+	f.mutex.Unlock()
+	if f.onError != nil {
+		f.onError(err)
+	}
+}
+
+// FallbackLimitStore is a LimitStore that keeps the limiter data in a local store while its store fails, e.g. while redis is unreachable
+type FallbackLimitStore struct {
+	fallbackT
+	store    LimitStore
+	fallback LimitStore
+}
+
+// NewFallbackLimitStore creates a data store using the fallback store for retryInterval after every error of store. onError is called with the errors of store.
+// nodes returns the number of nodes sharing the limits of store, each of them keeps its share of the limits while it uses the fallback. It can be nil for a single node
+func NewFallbackLimitStore(store, fallback LimitStore, retryInterval time.Duration, onError func(err error), nodes func() int64) *FallbackLimitStore {
+	return &FallbackLimitStore{fallbackT: fallbackT{retryInterval: retryInterval, onError: onError, nodes: nodes}, store: store, fallback: fallback}
+}
+
+// Inc increments current window limit counter for key
+func (f *FallbackLimitStore) Inc(key string, window time.Time) error {
+	if f.useStore() {
+		err := f.store.Inc(key, window)
+		if err == nil {
+			return nil
+		}
+		f.failed(err)
+	}
+	return f.fallback.Inc(key, window)
+}
+
+// Dec decrements current window limit counter for key
+func (f *FallbackLimitStore) Dec(key string, count int64, window time.Time) error {
+	if f.useStore() {
+		err := f.store.Dec(key, count, window)
+		if err == nil {
+			return nil
+		}
+		f.failed(err)
+	}
+	return f.fallback.Dec(key, count, window)
+}
+
+// Get gets value of previous window counter and current window counter for key.
+// The counters of the fallback are multiplied by the number of nodes, so that each node reaches the limit with its share of the events
+func (f *FallbackLimitStore) Get(key string, previousWindow, currentWindow time.Time) (prevValue int64, currValue int64, err error) {
+	if f.useStore() {
+		prevValue, currValue, err = f.store.Get(key, previousWindow, currentWindow)
+		if err == nil {
+			return prevValue, currValue, nil
+		}
+		f.failed(err)
+	}
+	prevValue, currValue, err = f.fallback.Get(key, previousWindow, currentWindow)
+	nodes := f.noOfNodes()
+	return prevValue * nodes, currValue * nodes, err
+}
+
+// FallbackTokenBucketStore is a TokenBucketStore that keeps the buckets in a local store while its store fails, e.g. while redis is unreachable
+type FallbackTokenBucketStore struct {
+	fallbackT
+	store    TokenBucketStore
+	fallback TokenBucketStore
+}
+
+// NewFallbackTokenBucketStore creates a data store using the fallback store for retryInterval after every error of store. onError is called with the errors of store.
+// nodes returns the number of nodes sharing the buckets of store, each of them keeps its share of the buckets while it uses the fallback. It can be nil for a single node
+func NewFallbackTokenBucketStore(store, fallback TokenBucketStore, retryInterval time.Duration, onError func(err error), nodes func() int64) *FallbackTokenBucketStore {
+	return &FallbackTokenBucketStore{fallbackT: fallbackT{retryInterval: retryInterval, onError: onError, nodes: nodes}, store: store, fallback: fallback}
+}
+
+// Take takes count tokens from the bucket of key if it has them, returning whether it had them. Negative counts put tokens back.
+// The buckets of the fallback hold the share of the capacity of the node, of at least a token, and refill at the same share of the rate
+func (f *FallbackTokenBucketStore) Take(key string, count, capacity int64, window time.Duration, now time.Time) (bool, error) {
+	if f.useStore() {
+		taken, err := f.store.Take(key, count, capacity, window, now)
+		if err == nil {
+			return taken, nil
+		}
+		f.failed(err)
+	}
+	if capacity /= f.noOfNodes(); capacity < 1 {
+		capacity = 1
+	}
+	return f.fallback.Take(key, count, capacity, window, now)
+}
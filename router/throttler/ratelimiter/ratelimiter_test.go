@@ -0,0 +1,140 @@
+package ratelimiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	bucket := NewTokenBucket(NewMapTokenBucketStore(time.Minute), 2, time.Second)
+	now := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		taken, err := bucket.Take("key", now)
+		require.NoError(t, err)
+		require.True(t, taken, "the bucket starts full")
+	}
+	taken, _ := bucket.Take("key", now)
+	require.False(t, taken, "the bucket is empty")
+	taken, _ = bucket.Take("other", now)
+	require.True(t, taken, "keys have their own buckets")
+
+	taken, _ = bucket.Take("key", now.Add(500*time.Millisecond))
+	require.True(t, taken, "a token is refilled every window/limit")
+	taken, _ = bucket.Take("key", now.Add(500*time.Millisecond))
+	require.False(t, taken)
+
+	require.NoError(t, bucket.Put("key", 5, now.Add(500*time.Millisecond)))
+	for i := 0; i < 2; i++ {
+		taken, _ = bucket.Take("key", now.Add(500*time.Millisecond))
+		require.True(t, taken, "tokens put back are taken again")
+	}
+	taken, _ = bucket.Take("key", now.Add(500*time.Millisecond))
+	require.False(t, taken, "the bucket doesn't hold more than its capacity")
+
+	taken, _ = bucket.Take("key", now)
+	require.False(t, taken, "the bucket doesn't refill going back in time")
+}
+
+type failingStoreT struct {
+	calls int
+}
+
+var errStore = errors.New("store is unreachable")
+
+func (s *failingStoreT) Inc(string, time.Time) error        { s.calls++; return errStore }
+func (s *failingStoreT) Dec(string, int64, time.Time) error { s.calls++; return errStore }
+func (s *failingStoreT) Get(string, time.Time, time.Time) (int64, int64, error) {
+	s.calls++
+	return 0, 0, errStore
+}
+func (s *failingStoreT) Take(string, int64, int64, time.Duration, time.Time) (bool, error) {
+	s.calls++
+	return false, errStore
+}
+
+func TestFallbackLimitStore(t *testing.T) {
+	var errs []error
+	store := &failingStoreT{}
+	limiter := New(NewFallbackLimitStore(store, NewMapLimitStore(time.Minute, time.Minute), time.Hour, func(err error) { errs = append(errs, err) }, nil), 2, time.Minute)
+	now := time.Now()
+
+	require.NoError(t, limiter.Inc("key", now))
+	require.NoError(t, limiter.Inc("key", now))
+	status, err := limiter.Check("key", now)
+	require.NoError(t, err)
+	require.True(t, status.IsLimited, "the limits are kept locally")
+	require.Equal(t, 1, store.calls, "the store is not retried before the retry interval")
+	require.Equal(t, []error{errStore}, errs)
+}
+
+func TestFallbackTokenBucketStore(t *testing.T) {
+	store := &failingStoreT{}
+	bucket := NewTokenBucket(NewFallbackTokenBucketStore(store, NewMapTokenBucketStore(time.Minute), 0, nil, nil), 1, time.Minute)
+	now := time.Now()
+
+	taken, err := bucket.Take("key", now)
+	require.NoError(t, err)
+	require.True(t, taken)
+	taken, _ = bucket.Take("key", now)
+	require.False(t, taken)
+	require.Equal(t, 2, store.calls, "the store is retried after the retry interval")
+}
+
+func TestFallbackStoresSplitTheLimitsBetweenNodes(t *testing.T) {
+	nodes := func() int64 { return 3 }
+	now := time.Now()
+
+	limiter := New(NewFallbackLimitStore(&failingStoreT{}, NewMapLimitStore(time.Minute, time.Minute), time.Hour, nil, nodes), 6, time.Minute)
+	for i := 0; i < 2; i++ {
+		status, err := limiter.Check("key", now)
+		require.NoError(t, err)
+		require.False(t, status.IsLimited)
+		require.NoError(t, limiter.Inc("key", now))
+	}
+	status, err := limiter.Check("key", now)
+	require.NoError(t, err)
+	require.True(t, status.IsLimited, "every node keeps its share of the limit")
+
+	bucket := NewTokenBucket(NewFallbackTokenBucketStore(&failingStoreT{}, NewMapTokenBucketStore(time.Minute), time.Hour, nil, nodes), 6, time.Minute)
+	for i := 0; i < 2; i++ {
+		taken, err := bucket.Take("key", now)
+		require.NoError(t, err)
+		require.True(t, taken)
+	}
+	taken, _ := bucket.Take("key", now)
+	require.False(t, taken, "every node keeps its share of the capacity")
+	taken, _ = bucket.Take("key", now.Add(30*time.Second))
+	require.True(t, taken, "the buckets refill at the share of the rate")
+	taken, _ = bucket.Take("key", now.Add(30*time.Second))
+	require.False(t, taken)
+
+	bucket = NewTokenBucket(NewFallbackTokenBucketStore(&failingStoreT{}, NewMapTokenBucketStore(time.Minute), time.Hour, nil, func() int64 { return 10 }), 6, time.Minute)
+	taken, _ = bucket.Take("key", now)
+	require.True(t, taken, "the buckets hold at least a token")
+}
+
+func TestRedisStoresFallBackWhenRedisIsUnreachable(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:1", DialTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	now := time.Now()
+
+	_, err := NewTokenBucket(NewRedisTokenBucketStore(client), 1, time.Minute).Take("key", now)
+	require.Error(t, err)
+	_, err = New(NewRedisLimitStore(client, time.Minute), 1, time.Minute).Check("key", now)
+	require.Error(t, err)
+
+	var errs int
+	onError := func(error) { errs++ }
+	bucket := NewTokenBucket(NewFallbackTokenBucketStore(NewRedisTokenBucketStore(client), NewMapTokenBucketStore(time.Minute), time.Hour, onError, nil), 1, time.Minute)
+	taken, err := bucket.Take("key", now)
+	require.NoError(t, err)
+	require.True(t, taken)
+	limiter := New(NewFallbackLimitStore(NewRedisLimitStore(client, time.Minute), NewMapLimitStore(time.Minute, time.Minute), time.Hour, onError, nil), 1, time.Minute)
+	require.NoError(t, limiter.Inc("key", now))
+	require.Equal(t, 2, errs)
+}
@@ -0,0 +1,168 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// RedisLimitStore represents internal limiter data database where data are stored in redis, so that the limits are shared by all the servers using the same redis
+type RedisLimitStore struct {
+	client         redis.UniversalClient
+	expirationTime time.Duration
+}
+
+// NewRedisLimitStore creates new redis data store for internal limiter data. Each window counter expires after expirationTime from its last update
+func NewRedisLimitStore(client redis.UniversalClient, expirationTime time.Duration) *RedisLimitStore {
+	return &RedisLimitStore{client: client, expirationTime: expirationTime}
+}
+
+// redisKey returns the key of the counter of a window. The key is hash tagged, so that all the windows of a key are in the same slot of a redis cluster
+func redisKey(key string, window time.Time) string {
+	return fmt.Sprintf("rudder_router_throttle:{%s}:%d", key, window.Unix())
+}
+
+// incScript increments the counter at KEYS[1] by ARGV[1], not letting it go below zero, and sets it to expire after ARGV[2] milliseconds
+var incScript = redis.NewScript(`
+local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if value < 0 then
+	redis.call('SET', KEYS[1], 0)
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return value
+`)
+
+func (r *RedisLimitStore) incBy(key string, count int64, window time.Time) error {
+	return incScript.Run(r.client, []string{redisKey(key, window)}, count, r.expirationTime.Milliseconds()).Err()
+}
+
+// Inc increments current window limit counter for key
+func (r *RedisLimitStore) Inc(key string, window time.Time) error {
+	return r.incBy(key, 1, window)
+}
+
+// Dec decrements current window limit counter for key
+func (r *RedisLimitStore) Dec(key string, count int64, window time.Time) error {
+	return r.incBy(key, -count, window)
+}
+
+// Get gets value of previous window counter and current window counter for key
+func (r *RedisLimitStore) Get(key string, previousWindow, currentWindow time.Time) (prevValue int64, currValue int64, err error) {
+	values, err := r.client.MGet(redisKey(key, previousWindow), redisKey(key, currentWindow)).Result()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(values) != 2 {
+		return 0, 0, fmt.Errorf("unexpected limit counters %v of key %s", values, key)
+	}
+	counters := make([]int64, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return 0, 0, fmt.Errorf("unexpected limit counter %v of key %s", value, key)
+		}
+		if _, err := fmt.Sscan(s, &counters[i]); err != nil {
+			return 0, 0, fmt.Errorf("unexpected limit counter %q of key %s: %w", s, key, err)
+		}
+	}
+	return counters[0], counters[1], nil
+}
+
+// takeScript takes ARGV[1] tokens from the bucket at KEYS[1], which holds up to ARGV[2] tokens and refills from empty in ARGV[3] milliseconds.
+// Negative counts put tokens back. ARGV[4] is the current time in milliseconds, the bucket doesn't refill while it is behind the last update,
+// so that clock skews between servers can't add tokens.
+var takeScript = redis.NewScript(`
+local count = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1])
+local updated = tonumber(bucket[2])
+if tokens == nil or updated == nil then
+	tokens = capacity
+	updated = now
+end
+if now > updated then
+	tokens = math.min(capacity, tokens + (now - updated) * capacity / window)
+	updated = now
+end
+
+local taken = 0
+if count <= tokens then
+	tokens = math.min(capacity, tokens - count)
+	taken = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', updated)
+redis.call('PEXPIRE', KEYS[1], 2 * window)
+return taken
+`)
+
+// RedisTokenBucketStore keeps token buckets in redis, so that the buckets are shared by all the servers using the same redis
+type RedisTokenBucketStore struct {
+	client redis.UniversalClient
+}
+
+// NewRedisTokenBucketStore creates new redis data store for token buckets
+func NewRedisTokenBucketStore(client redis.UniversalClient) *RedisTokenBucketStore {
+	return &RedisTokenBucketStore{client: client}
+}
+
+// Take takes count tokens from the bucket of key, if it has them. Negative counts put tokens back
+func (r *RedisTokenBucketStore) Take(key string, count, capacity int64, window time.Duration, now time.Time) (bool, error) {
+	taken, err := takeScript.Run(r.client, []string{"rudder_router_token_bucket:" + key}, count, capacity, window.Milliseconds(), now.UnixNano()/int64(time.Millisecond)).Int64()
+	if err != nil {
+		return false, err
+	}
+	return taken == 1, nil
+}
+
+// nodesScript registers the node ARGV[1] at ARGV[2] milliseconds in the sorted set KEYS[1], forgets the nodes which didn't register after ARGV[3] milliseconds
+// and returns the number of nodes. The set expires after ARGV[4] milliseconds without registrations
+var nodesScript = redis.NewScript(`
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', '(' .. ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// RedisNodes counts the nodes sharing limits in redis, so that they can split the limits between them while redis is unreachable.
+// Every node registers itself more often than every ttl, nodes which don't are not counted anymore
+type RedisNodes struct {
+	count    int64 // first field, for the alignment of the atomic operations
+	client   redis.UniversalClient
+	nodeID   string
+	ttl      time.Duration
+	minNodes int64
+}
+
+// NewRedisNodes creates the counter of the nodes sharing limits in redis, nodeID must be unique among them.
+// minNodes is the number of nodes until the node manages to register
+func NewRedisNodes(client redis.UniversalClient, nodeID string, ttl time.Duration, minNodes int64) *RedisNodes {
+	return &RedisNodes{client: client, nodeID: nodeID, ttl: ttl, minNodes: minNodes, count: minNodes}
+}
+
+// Register registers the node and counts the nodes registered
+func (n *RedisNodes) Register(now time.Time) error {
+	nowMillis := now.UnixNano() / int64(time.Millisecond)
+	count, err := nodesScript.Run(n.client, []string{"rudder_router_throttle_nodes"}, n.nodeID, nowMillis, nowMillis-n.ttl.Milliseconds(), n.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&n.count, count)
+	return nil
+}
+
+// Count returns the number of nodes as of the last registration, at least minNodes
+func (n *RedisNodes) Count() int64 {
+	if count := atomic.LoadInt64(&n.count); count > n.minNodes {
+		return count
+	}
+	return n.minNodes
+}
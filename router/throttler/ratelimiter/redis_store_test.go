@@ -0,0 +1,112 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/require"
+
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+)
+
+func TestRedisStores(t *testing.T) {
+	address := testutils.SetupRedis(t)
+	newClient := func() redis.UniversalClient {
+		client := redis.NewClient(&redis.Options{Addr: address})
+		t.Cleanup(func() { client.Close() })
+		return client
+	}
+	// servers sharing the same redis
+	client1, client2 := newClient(), newClient()
+	now := time.Now()
+
+	t.Run("limit store", func(t *testing.T) {
+		store1, store2 := NewRedisLimitStore(client1, time.Minute), NewRedisLimitStore(client2, time.Minute)
+		previous, current := now.Add(-time.Minute), now
+
+		prevValue, currValue, err := store1.Get("limit", previous, current)
+		require.NoError(t, err)
+		require.Equal(t, []int64{0, 0}, []int64{prevValue, currValue}, "missing counters are zero")
+
+		require.NoError(t, store1.Inc("limit", previous))
+		require.NoError(t, store1.Inc("limit", current))
+		require.NoError(t, store2.Inc("limit", current))
+		prevValue, currValue, err = store2.Get("limit", previous, current)
+		require.NoError(t, err)
+		require.Equal(t, []int64{1, 2}, []int64{prevValue, currValue}, "the counters are shared")
+
+		require.NoError(t, store1.Dec("limit", 5, current))
+		_, currValue, err = store1.Get("limit", previous, current)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), currValue, "the counters don't go below zero")
+		require.NoError(t, store1.Inc("limit", current))
+		_, currValue, _ = store1.Get("limit", previous, current)
+		require.Equal(t, int64(1), currValue)
+
+		ttl, err := client1.PTTL(redisKey("limit", current)).Result()
+		require.NoError(t, err)
+		require.True(t, ttl > 0 && ttl <= time.Minute, "the counters expire, ttl is %v", ttl)
+	})
+
+	t.Run("limiter", func(t *testing.T) {
+		limiter1, limiter2 := New(NewRedisLimitStore(client1, 2*time.Minute), 2, time.Minute), New(NewRedisLimitStore(client2, 2*time.Minute), 2, time.Minute)
+		require.NoError(t, limiter1.Inc("limiter", now))
+		require.NoError(t, limiter2.Inc("limiter", now))
+		status, err := limiter1.Check("limiter", now)
+		require.NoError(t, err)
+		require.True(t, status.IsLimited, "the servers share the limit")
+	})
+
+	t.Run("token bucket store", func(t *testing.T) {
+		bucket1, bucket2 := NewTokenBucket(NewRedisTokenBucketStore(client1), 2, time.Second), NewTokenBucket(NewRedisTokenBucketStore(client2), 2, time.Second)
+
+		taken, err := bucket1.Take("bucket", now)
+		require.NoError(t, err)
+		require.True(t, taken, "the bucket starts full")
+		taken, err = bucket2.Take("bucket", now)
+		require.NoError(t, err)
+		require.True(t, taken)
+		taken, _ = bucket1.Take("bucket", now)
+		require.False(t, taken, "the servers share the bucket")
+		taken, _ = bucket1.Take("other", now)
+		require.True(t, taken, "keys have their own buckets")
+
+		taken, _ = bucket2.Take("bucket", now.Add(500*time.Millisecond))
+		require.True(t, taken, "a token is refilled every window/limit")
+		taken, _ = bucket2.Take("bucket", now.Add(500*time.Millisecond))
+		require.False(t, taken)
+
+		require.NoError(t, bucket1.Put("bucket", 5, now.Add(500*time.Millisecond)))
+		for i := 0; i < 2; i++ {
+			taken, _ = bucket2.Take("bucket", now.Add(500*time.Millisecond))
+			require.True(t, taken, "tokens put back are taken again")
+		}
+		taken, _ = bucket2.Take("bucket", now.Add(500*time.Millisecond))
+		require.False(t, taken, "the bucket doesn't hold more than its capacity")
+
+		taken, _ = bucket1.Take("bucket", now)
+		require.False(t, taken, "the bucket doesn't refill going back in time")
+
+		ttl, err := client1.PTTL("rudder_router_token_bucket:bucket").Result()
+		require.NoError(t, err)
+		require.True(t, ttl > 0 && ttl <= 2*time.Second, "the buckets expire, ttl is %v", ttl)
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		nodes1, nodes2 := NewRedisNodes(client1, "node-1", time.Minute, 1), NewRedisNodes(client2, "node-2", time.Minute, 1)
+		require.Equal(t, int64(1), nodes1.Count(), "the nodes count minNodes until they register")
+
+		require.NoError(t, nodes1.Register(now))
+		require.Equal(t, int64(1), nodes1.Count())
+		require.NoError(t, nodes2.Register(now))
+		require.Equal(t, int64(2), nodes2.Count())
+		require.NoError(t, nodes1.Register(now.Add(time.Second)))
+		require.Equal(t, int64(2), nodes1.Count(), "registering again doesn't count the node twice")
+
+		require.NoError(t, nodes1.Register(now.Add(time.Minute+time.Second)))
+		require.Equal(t, int64(1), nodes1.Count(), "the nodes which don't register anymore are not counted")
+
+		require.Equal(t, int64(3), NewRedisNodes(client1, "node-3", time.Minute, 3).Count(), "the nodes count at least minNodes")
+	})
+}
@@ -0,0 +1,101 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucketStore is the interface that represents the data store of token buckets. Buckets hold up to capacity tokens and refill from empty in window
+type TokenBucketStore interface {
+	// Take takes count tokens from the bucket of key if it has them, returning whether it had them. Negative counts put tokens back
+	Take(key string, count, capacity int64, window time.Duration, now time.Time) (taken bool, err error)
+}
+
+// TokenBucket is a rate-limiter letting through bursts of up to requestsLimit requests, refilling at requestsLimit requests per windowSize
+type TokenBucket struct {
+	dataStore     TokenBucketStore
+	requestsLimit int64
+	windowSize    time.Duration
+}
+
+// NewTokenBucket creates new token bucket rate limiter, e.g. requestsLimit: 5 and windowSize: 1*time.Minute means that limiter allows up to 5 requests per minute
+func NewTokenBucket(dataStore TokenBucketStore, requestsLimit int64, windowSize time.Duration) *TokenBucket {
+	return &TokenBucket{
+		dataStore:     dataStore,
+		requestsLimit: requestsLimit,
+		windowSize:    windowSize,
+	}
+}
+
+// Take takes a token from the bucket of key, returning whether the bucket had one. It returns error when limiter data could not be updated
+func (b *TokenBucket) Take(key string, currentTime time.Time) (bool, error) {
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+	return b.dataStore.Take(key, 1, b.requestsLimit, b.windowSize, currentTime)
+}
+
+// Put puts count tokens back into the bucket of key, for the requests that were not made after all
+func (b *TokenBucket) Put(key string, count int64, currentTime time.Time) error {
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
+	_, err := b.dataStore.Take(key, -count, b.requestsLimit, b.windowSize, currentTime)
+	return err
+}
+
+type bucketValue struct {
+	tokens  float64
+	updated time.Time
+	window  time.Duration
+}
+
+// MapTokenBucketStore keeps token buckets in golang maps
+type MapTokenBucketStore struct {
+	data  map[string]bucketValue
+	mutex sync.Mutex
+}
+
+// NewMapTokenBucketStore creates new in-memory data store for token buckets. Buckets refilled up are removed with a period specified by the flushInterval argument
+func NewMapTokenBucketStore(flushInterval time.Duration) (m *MapTokenBucketStore) {
+	m = &MapTokenBucketStore{data: make(map[string]bucketValue)}
+	go func() {
+		ticker := time.NewTicker(flushInterval)
+		for range ticker.C {
+			m.mutex.Lock()
+			for key, val := range m.data {
+				if val.updated.Add(val.window).Before(time.Now()) {
+					delete(m.data, key)
+				}
+			}
+			m.mutex.Unlock()
+		}
+	}()
+	return m
+}
+
+// Take takes count tokens from the bucket of key if it has them, returning whether it had them. Negative counts put tokens back
+func (m *MapTokenBucketStore) Take(key string, count, capacity int64, window time.Duration, now time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	bucket, ok := m.data[key]
+	if !ok {
+		bucket = bucketValue{tokens: float64(capacity), updated: now}
+	}
+	bucket.window = window
+	if now.After(bucket.updated) {
+		refilled := float64(now.Sub(bucket.updated)) * float64(capacity) / float64(window)
+		bucket.tokens = math.Min(float64(capacity), bucket.tokens+refilled)
+		bucket.updated = now
+	}
+
+	var taken bool
+	if float64(count) <= bucket.tokens {
+		bucket.tokens = math.Min(float64(capacity), bucket.tokens-float64(count))
+		taken = true
+	}
+	m.data[key] = bucket
+	return taken, nil
+}
@@ -2,11 +2,19 @@ package throttler
 
 import (
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
+	"github.com/go-redis/redis"
+	uuid "github.com/gofrs/uuid"
+
 	"github.com/rudderlabs/rudder-server/config"
 	"github.com/rudderlabs/rudder-server/router/throttler/ratelimiter"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/kvstoremanager"
 	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/types"
 )
 
 const (
@@ -15,6 +23,22 @@ const (
 	ALL_LEVELS        = "all"
 )
 
+const (
+	//SlidingWindowAlgorithm limits the events in a sliding window of timeWindow
+	SlidingWindowAlgorithm = "slidingWindow"
+	//TokenBucketAlgorithm lets through bursts of up to limit events, refilling at limit events per timeWindow.
+	//Checking the limit takes the token of the event, so servers sharing the buckets can't go over the limit together.
+	TokenBucketAlgorithm = "tokenBucket"
+)
+
+const (
+	//MemoryStore keeps the limits of every server in its memory
+	MemoryStore = "memory"
+	//RedisStore keeps the limits in redis, so that they are shared by all the servers.
+	//The limits are kept in memory while redis is unreachable, every server keeping its share of the limits among the servers registered in redis.
+	RedisStore = "redis"
+)
+
 //Throttler is an interface for throttling functions
 type Throttler interface {
 	CheckLimitReached(destID string, userID string, currentTime time.Time) bool
@@ -30,6 +54,7 @@ type Limiter struct {
 	eventLimit  int
 	timeWindow  time.Duration
 	ratelimiter *ratelimiter.RateLimiter
+	tokenBucket *ratelimiter.TokenBucket
 }
 
 type Settings struct {
@@ -42,11 +67,115 @@ type Settings struct {
 //HandleT is a Handle for event limiter
 type HandleT struct {
 	destinationName string
+	algorithm       string
 	destLimiter     *Limiter
 	userLimiter     *Limiter
 }
 
-var pkgLogger logger.LoggerI
+var (
+	pkgLogger          logger.LoggerI
+	storeType          string
+	redisRetryInterval time.Duration
+	redisMinNodes      int
+	redisClientOnce    sync.Once
+	redisClient        redis.UniversalClient
+	redisNodes         *ratelimiter.RedisNodes
+)
+
+func loadStoreConfig() {
+	config.RegisterStringConfigVariable(MemoryStore, &storeType, false, "Router.throttler.store")
+	config.RegisterDurationConfigVariable(time.Duration(10), &redisRetryInterval, false, time.Second, "Router.throttler.redis.retryInterval")
+	config.RegisterIntConfigVariable(1, &redisMinNodes, false, 1, "Router.throttler.redis.minNodes")
+}
+
+//getRedisClient returns the client of the redis shared by the throttlers of all destination types
+func getRedisClient() redis.UniversalClient {
+	redisClientOnce.Do(func() {
+		redisClient = kvstoremanager.NewRedisClient(types.ConfigT{
+			"address":       config.GetString("Router.throttler.redis.address", "localhost:6379"),
+			"password":      config.GetString("Router.throttler.redis.password", ""),
+			"database":      config.GetString("Router.throttler.redis.database", "0"),
+			"clusterMode":   config.GetBool("Router.throttler.redis.clusterMode", false),
+			"secure":        config.GetBool("Router.throttler.redis.secure", false),
+			"skipVerify":    config.GetBool("Router.throttler.redis.skipVerify", false),
+			"caCertificate": config.GetString("Router.throttler.redis.caCertificate", ""),
+		})
+		redisNodes = newRedisNodes(redisClient)
+	})
+	return redisClient
+}
+
+//newRedisNodes registers the server in redis every redisRetryInterval, to count the servers sharing the limits.
+//Servers which stop registering are not counted anymore after 3 intervals
+func newRedisNodes(client redis.UniversalClient) *ratelimiter.RedisNodes {
+	hostname, _ := os.Hostname()
+	nodes := ratelimiter.NewRedisNodes(client, hostname+"-"+uuid.Must(uuid.NewV4()).String(), 3*redisRetryInterval, int64(redisMinNodes))
+	rruntime.Go(func() {
+		for {
+			if err := nodes.Register(time.Now()); err != nil {
+				pkgLogger.Errorf(`[[ router-throttler: Error in registering the server in redis: %v]]`, err)
+			}
+			time.Sleep(redisRetryInterval)
+		}
+	})
+	return nodes
+}
+
+func (throttler *HandleT) onRedisError(err error) {
+	pkgLogger.Errorf(`[[ %s-router-throttler: Error in redis, falling back to local limits for %v: %v]]`, throttler.destinationName, redisRetryInterval, err)
+}
+
+func (throttler *HandleT) setUpLimiter(limiter *Limiter) {
+	useRedis := storeType == RedisStore
+	if !useRedis && storeType != MemoryStore {
+		pkgLogger.Errorf(`[[ %s-router-throttler: Unknown store %q, using the %s store]]`, throttler.destinationName, storeType, MemoryStore)
+	}
+
+	if throttler.algorithm == TokenBucketAlgorithm {
+		var dataStore ratelimiter.TokenBucketStore = ratelimiter.NewMapTokenBucketStore(10 * time.Second)
+		if useRedis {
+			client := getRedisClient()
+			dataStore = ratelimiter.NewFallbackTokenBucketStore(ratelimiter.NewRedisTokenBucketStore(client), dataStore, redisRetryInterval, throttler.onRedisError, redisNodes.Count)
+		}
+		limiter.tokenBucket = ratelimiter.NewTokenBucket(dataStore, int64(limiter.eventLimit), limiter.timeWindow)
+		return
+	}
+
+	var dataStore ratelimiter.LimitStore = ratelimiter.NewMapLimitStore(2*limiter.timeWindow, 10*time.Second)
+	if useRedis {
+		client := getRedisClient()
+		dataStore = ratelimiter.NewFallbackLimitStore(ratelimiter.NewRedisLimitStore(client, 2*limiter.timeWindow), dataStore, redisRetryInterval, throttler.onRedisError, redisNodes.Count)
+	}
+	limiter.ratelimiter = ratelimiter.New(dataStore, int64(limiter.eventLimit), limiter.timeWindow)
+}
+
+//limitReached returns whether the limit of key is reached. With the token bucket algorithm, it takes a token of key if the limit is not reached.
+func (limiter *Limiter) limitReached(key string, currentTime time.Time) (bool, error) {
+	if limiter.tokenBucket != nil {
+		taken, err := limiter.tokenBucket.Take(key, currentTime)
+		return !taken, err
+	}
+	limitStatus, err := limiter.ratelimiter.Check(key, currentTime)
+	if err != nil {
+		return false, err
+	}
+	return limitStatus.IsLimited, nil
+}
+
+func (limiter *Limiter) inc(key string, currentTime time.Time) {
+	//the tokens of the token buckets are taken when checking the limit
+	if limiter.tokenBucket == nil {
+		_ = limiter.ratelimiter.Inc(key, currentTime)
+	}
+}
+
+func (limiter *Limiter) dec(key string, count int64, currentTime time.Time) {
+	if limiter.tokenBucket != nil {
+		_ = limiter.tokenBucket.Put(key, count, currentTime)
+		return
+	}
+	_ = limiter.ratelimiter.Dec(key, count, currentTime)
+}
 
 func (throttler *HandleT) setLimits() {
 	destName := throttler.destinationName
@@ -79,6 +208,7 @@ func (throttler *HandleT) setLimits() {
 //SetUp eventLimiter
 func (throttler *HandleT) SetUp(destName string) {
 	pkgLogger = logger.NewLogger().Child("router").Child("throttler")
+	loadStoreConfig()
 	throttler.destinationName = destName
 	throttler.destLimiter = &Limiter{}
 	throttler.userLimiter = &Limiter{}
@@ -86,40 +216,47 @@ func (throttler *HandleT) SetUp(destName string) {
 	// check if it has throttling config for destination
 	throttler.setLimits()
 
+	config.RegisterStringConfigVariable(SlidingWindowAlgorithm, &throttler.algorithm, false, []string{fmt.Sprintf(`Router.throttler.%s.algorithm`, destName), `Router.throttler.algorithm`}...)
+	if throttler.algorithm != SlidingWindowAlgorithm && throttler.algorithm != TokenBucketAlgorithm {
+		pkgLogger.Errorf(`[[ %s-router-throttler: Unknown algorithm %q, using %s]]`, destName, throttler.algorithm, SlidingWindowAlgorithm)
+		throttler.algorithm = SlidingWindowAlgorithm
+	}
+
 	if throttler.destLimiter.enabled {
-		dataStore := ratelimiter.NewMapLimitStore(2*throttler.destLimiter.timeWindow, 10*time.Second)
-		throttler.destLimiter.ratelimiter = ratelimiter.New(dataStore, int64(throttler.destLimiter.eventLimit), throttler.destLimiter.timeWindow)
+		throttler.setUpLimiter(throttler.destLimiter)
 	}
 
 	if throttler.userLimiter.enabled {
-		dataStore := ratelimiter.NewMapLimitStore(2*throttler.userLimiter.timeWindow, 10*time.Second)
-		throttler.userLimiter.ratelimiter = ratelimiter.New(dataStore, int64(throttler.userLimiter.eventLimit), throttler.userLimiter.timeWindow)
+		throttler.setUpLimiter(throttler.userLimiter)
 	}
 }
 
-//LimitReached returns true if number of events in the rolling window is less than the max events allowed, else false
+//LimitReached returns true if number of events in the rolling window is less than the max events allowed, else false.
+//With the token bucket algorithm, the tokens of the event are taken when the limit is not reached.
 func (throttler *HandleT) CheckLimitReached(destID string, userID string, currentTime time.Time) bool {
 	var destLevelLimitReached bool
 	if throttler.destLimiter.enabled {
-		destKey := throttler.getDestKey(destID)
-		limitStatus, err := throttler.destLimiter.ratelimiter.Check(destKey, currentTime)
+		limitReached, err := throttler.destLimiter.limitReached(throttler.getDestKey(destID), currentTime)
 		if err != nil {
 			// TODO: handle this
 			pkgLogger.Errorf(`[[ %s-router-throttler: Error checking limitStatus: %v]]`, throttler.destinationName, err)
 		} else {
-			destLevelLimitReached = limitStatus.IsLimited
+			destLevelLimitReached = limitReached
 		}
 	}
 
 	var userLevelLimitReached bool
 	if !destLevelLimitReached && throttler.userLimiter.enabled {
-		userKey := throttler.getUserKey(destID, userID)
-		limitStatus, err := throttler.userLimiter.ratelimiter.Check(userKey, currentTime)
+		limitReached, err := throttler.userLimiter.limitReached(throttler.getUserKey(destID, userID), currentTime)
 		if err != nil {
 			// TODO: handle this
 			pkgLogger.Errorf(`[[ %s-router-throttler: Error checking limitStatus: %v]]`, throttler.destinationName, err)
 		} else {
-			userLevelLimitReached = limitStatus.IsLimited
+			userLevelLimitReached = limitReached
+		}
+		//the event is not sent, giving back the destination token taken for it
+		if userLevelLimitReached && throttler.destLimiter.enabled && throttler.destLimiter.tokenBucket != nil {
+			throttler.destLimiter.dec(throttler.getDestKey(destID), 1, currentTime)
 		}
 	}
 
@@ -130,12 +267,10 @@ func (throttler *HandleT) CheckLimitReached(destID string, userID string, curren
 //If destID or userID passed is empty, we don't increment the counters.
 func (throttler *HandleT) Inc(destID string, userID string, currentTime time.Time) {
 	if throttler.destLimiter.enabled && destID != "" {
-		destKey := throttler.getDestKey(destID)
-		_ = throttler.destLimiter.ratelimiter.Inc(destKey, currentTime)
+		throttler.destLimiter.inc(throttler.getDestKey(destID), currentTime)
 	}
 	if throttler.userLimiter.enabled && userID != "" {
-		userKey := throttler.getUserKey(destID, userID)
-		_ = throttler.userLimiter.ratelimiter.Inc(userKey, currentTime)
+		throttler.userLimiter.inc(throttler.getUserKey(destID, userID), currentTime)
 	}
 }
 
@@ -143,12 +278,10 @@ func (throttler *HandleT) Inc(destID string, userID string, currentTime time.Tim
 //If destID or userID passed is empty, we don't decrement the counters.
 func (throttler *HandleT) Dec(destID string, userID string, count int64, currentTime time.Time, atLevel string) {
 	if throttler.destLimiter.enabled && destID != "" && (atLevel == ALL_LEVELS || atLevel == DESTINATION_LEVEL) {
-		destKey := throttler.getDestKey(destID)
-		_ = throttler.destLimiter.ratelimiter.Dec(destKey, count, currentTime)
+		throttler.destLimiter.dec(throttler.getDestKey(destID), count, currentTime)
 	}
 	if throttler.userLimiter.enabled && userID != "" && (atLevel == ALL_LEVELS || atLevel == USER_LEVEL) {
-		userKey := throttler.getUserKey(destID, userID)
-		_ = throttler.userLimiter.ratelimiter.Dec(userKey, count, currentTime)
+		throttler.userLimiter.dec(throttler.getUserKey(destID, userID), count, currentTime)
 	}
 }
 
@@ -0,0 +1,37 @@
+package throttler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/router/throttler/ratelimiter"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+func TestTokenBucketThrottler(t *testing.T) {
+	config.Load()
+	logger.Init()
+	pkgLogger = logger.NewLogger()
+	newLimiter := func(limit int) *Limiter {
+		return &Limiter{
+			enabled:     true,
+			eventLimit:  limit,
+			timeWindow:  time.Minute,
+			tokenBucket: ratelimiter.NewTokenBucket(ratelimiter.NewMapTokenBucketStore(time.Minute), int64(limit), time.Minute),
+		}
+	}
+	throttler := &HandleT{destinationName: "WEBHOOK", algorithm: TokenBucketAlgorithm, destLimiter: newLimiter(2), userLimiter: newLimiter(1)}
+	now := time.Now()
+
+	require.False(t, throttler.CheckLimitReached("dest", "u1", now))
+	throttler.Inc("dest", "u1", now)
+	require.True(t, throttler.CheckLimitReached("dest", "u1", now), "u1 took its token")
+	require.False(t, throttler.CheckLimitReached("dest", "u2", now), "the destination token is given back when the user limit is reached")
+	require.True(t, throttler.CheckLimitReached("dest", "u3", now), "the destination tokens are taken")
+
+	throttler.Dec("dest", "u1", 1, now, ALL_LEVELS)
+	require.False(t, throttler.CheckLimitReached("dest", "u1", now), "tokens of events not sent are given back")
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"encoding/base64"
+	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -32,6 +34,26 @@ type SendPostResponse struct {
 	StatusCode          int
 	ResponseContentType string
 	ResponseBody        []byte
+	//RetryAfter is the wait asked for by the Retry-After header of the response, if any
+	RetryAfter time.Duration
+}
+
+//ParseRetryAfter returns the wait asked for by a Retry-After header, given either in seconds or as an http date
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
 }
 
 func Init() {
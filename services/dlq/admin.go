@@ -0,0 +1,102 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/rudderlabs/rudder-server/admin"
+)
+
+//RPCHandler is the DLQ admin rpc handler
+type RPCHandler struct{}
+
+var registerAdminHandlerOnce sync.Once
+
+func registerAdminHandler() {
+	registerAdminHandlerOnce.Do(func() {
+		admin.RegisterAdminHandler("DLQ", &RPCHandler{})
+	})
+}
+
+func setupInstance() (*HandleT, error) {
+	instanceLock.RLock()
+	defer instanceLock.RUnlock()
+	if instance == nil {
+		return nil, fmt.Errorf("dead-letter queue is not set up on this server")
+	}
+	return instance, nil
+}
+
+func replyJSON(reply *string, v interface{}) error {
+	response, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	*reply = string(response)
+	return nil
+}
+
+func recoverRPC(err *error) {
+	if r := recover(); r != nil {
+		pkgLogger.Error(r)
+		*err = fmt.Errorf("internal Rudder server error: %v", r)
+	}
+}
+
+//List replies with the jobs of the dead-letter queue matching the filter
+func (handler *RPCHandler) List(filter FilterT, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	jobs, err := handle.List(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, jobs)
+}
+
+//Inspect replies with a job of the dead-letter queue along with the actions taken on it
+func (handler *RPCHandler) Inspect(id int64, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	result, err := handle.Inspect(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, result)
+}
+
+//Retry stores the pending jobs aborted by the router matching the request as new router jobs
+func (handler *RPCHandler) Retry(request ActionRequestT, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	result, err := handle.Retry(context.Background(), request)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, result)
+}
+
+//Discard marks the pending jobs matching the request as discarded
+func (handler *RPCHandler) Discard(request ActionRequestT, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	result, err := handle.Discard(context.Background(), request)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, result)
+}
@@ -0,0 +1,616 @@
+/*
+Package dlq indexes the jobs of the proc_error jobsdb into a dead-letter queue, so that they can be browsed, retried or discarded.
+
+The proc_error jobsdb holds two kinds of jobs:
+
+	processor_error: events failed by the processor at the user transformer, tracking plan validation, event filter or destination transformer
+	router_aborted:  router and batch router jobs aborted for their destination
+
+Jobs are indexed by workspace, source, destination, error code and failure time when the processor stash reads them.
+Pending jobs aborted by the router can be retried, i.e. stored again as new jobs of the router or batch router jobsdb they were aborted from.
+Processor errors can't be sent to the router, they are replayed into the gateway with the replay API instead.
+Any pending job can be discarded with a reason. Every retry and discard is recorded in the dlq_audit table.
+Jobs and audit records older than DLQ.retentionPeriod are deleted.
+
+The dead-letter queue is off by default (DLQ.enabled) and only exposed through the DLQ admin rpc.
+*/
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/replay"
+	migrator "github.com/rudderlabs/rudder-server/services/sql-migrator"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+//Kinds of the jobs of the dead-letter queue
+const (
+	KindProcessorError = "processor_error"
+	KindRouterAborted  = "router_aborted"
+)
+
+//States of the jobs of the dead-letter queue
+const (
+	StatePending   = "pending"
+	StateRetried   = "retried"
+	StateDiscarded = "discarded"
+)
+
+//Actions recorded in the audit of the dead-letter queue
+const (
+	ActionRetry   = "retry"
+	ActionDiscard = "discard"
+)
+
+//Stages router jobs are aborted at, jobs are retried into the jobsdb of their stage
+const (
+	RouterStage      = "router"
+	BatchRouterStage = "batch_router"
+)
+
+var (
+	pkgLogger       logger.LoggerI
+	enabled         bool
+	maxListLimit    int
+	maxBulkJobs     int
+	retentionPeriod time.Duration
+	cleanupInterval time.Duration
+)
+
+func Init() {
+	loadConfig()
+	pkgLogger = logger.NewLogger().Child("dlq")
+}
+
+func loadConfig() {
+	config.RegisterBoolConfigVariable(false, &enabled, false, "DLQ.enabled")
+	config.RegisterIntConfigVariable(1000, &maxListLimit, true, 1, "DLQ.maxListLimit")
+	config.RegisterIntConfigVariable(10000, &maxBulkJobs, true, 1, "DLQ.maxBulkJobs")
+	config.RegisterDurationConfigVariable(720, &retentionPeriod, true, time.Hour, "DLQ.retentionPeriod")
+	config.RegisterDurationConfigVariable(60, &cleanupInterval, true, time.Minute, "DLQ.cleanupInterval")
+}
+
+//Storer is the part of a jobsdb retried jobs are stored into, in the transaction marking them as retried
+type Storer interface {
+	AcquireStoreLock()
+	ReleaseStoreLock()
+	StoreInTxn(txn *sql.Tx, jobList []*jobsdb.JobT) error
+}
+
+//JobT is a proc_error job indexed in the dead-letter queue
+type JobT struct {
+	ID              int64     `json:"id"`
+	JobUUID         uuid.UUID `json:"jobUuid"`
+	JobID           int64     `json:"jobId"`
+	Kind            string    `json:"kind"`
+	Stage           string    `json:"stage"`
+	WorkspaceID     string    `json:"workspaceId"`
+	SourceID        string    `json:"sourceId"`
+	DestinationID   string    `json:"destinationId"`
+	DestinationType string    `json:"destinationType"`
+	ErrorCode       string    `json:"errorCode"`
+	Error           string    `json:"error"`
+	UserID          string    `json:"userId"`
+	//Parameters and EventPayload of the proc_error job are only returned when inspecting a job
+	Parameters   json.RawMessage `json:"parameters,omitempty"`
+	EventPayload json.RawMessage `json:"eventPayload,omitempty"`
+	EventCount   int             `json:"eventCount"`
+	State        string          `json:"state"`
+	//FailedAt is when the proc_error job was stored
+	FailedAt  time.Time `json:"failedAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+//FilterT selects jobs of the dead-letter queue, empty fields match any job
+type FilterT struct {
+	IDs           []int64 `json:"ids"`
+	WorkspaceID   string  `json:"workspaceId"`
+	SourceID      string  `json:"sourceId"`
+	DestinationID string  `json:"destinationId"`
+	ErrorCode     string  `json:"errorCode"`
+	Kind          string  `json:"kind"`
+	Stage         string  `json:"stage"`
+	//State is ignored by retries and discards, which only act on pending jobs
+	State string `json:"state"`
+	//From and To limit the jobs to the ones failed in [From, To)
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	//AfterID and Limit page through the jobs, ordered by id. Limit defaults to, and is capped at, DLQ.maxListLimit
+	AfterID int64 `json:"afterId"`
+	Limit   int   `json:"limit"`
+}
+
+//ActionRequestT selects the pending jobs to retry or discard, along with who does it and why
+type ActionRequestT struct {
+	FilterT
+	Actor string `json:"actor"`
+	//Reason is required to discard jobs
+	Reason string `json:"reason"`
+}
+
+//ActionResultT is the outcome of a retry or discard
+type ActionResultT struct {
+	AuditID int64  `json:"auditId"`
+	Action  string `json:"action"`
+	//Selected is the number of pending jobs matching the request, up to DLQ.maxBulkJobs
+	Selected int `json:"selected"`
+	//Done is the number of jobs retried or discarded
+	Done int `json:"done"`
+	//Skipped jobs can't be retried to the router, e.g. processor errors, and are left pending
+	Skipped int `json:"skipped"`
+}
+
+//AuditT is a retry or discard recorded in the audit of the dead-letter queue
+type AuditT struct {
+	ID        int64           `json:"id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Reason    string          `json:"reason"`
+	Filter    json.RawMessage `json:"filter"`
+	DLQJobIDs []int64         `json:"dlqJobIds"`
+	JobCount  int             `json:"jobCount"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+//InspectResultT is a job of the dead-letter queue along with the actions taken on it
+type InspectResultT struct {
+	Job   JobT     `json:"job"`
+	Audit []AuditT `json:"audit"`
+}
+
+//HandleT indexes proc_error jobs into the dead-letter queue and acts on them
+type HandleT struct {
+	dbHandle *sql.DB
+	//jobsDBs jobs aborted at a stage are retried into
+	jobsDBs map[string]Storer
+}
+
+var (
+	instanceLock sync.RWMutex
+	instance     *HandleT
+)
+
+//New creates a dead-letter queue stored in dbHandle, the database of the jobsdbs. Jobs aborted by nil jobsdbs can't be retried
+func New(dbHandle *sql.DB, routerDB, batchRouterDB Storer) *HandleT {
+	jobsDBs := map[string]Storer{}
+	for stage, jobsDB := range map[string]Storer{RouterStage: routerDB, BatchRouterStage: batchRouterDB} {
+		if jobsDB != nil {
+			jobsDBs[stage] = jobsDB
+		}
+	}
+	return &HandleT{dbHandle: dbHandle, jobsDBs: jobsDBs}
+}
+
+//Setup creates the dead-letter queue tables, makes the dead-letter queue available to the processor stash and the DLQ admin rpc,
+//and deletes expired jobs until ctx is done
+func Setup(ctx context.Context, routerDB, batchRouterDB *jobsdb.HandleT) {
+	if !enabled {
+		return
+	}
+	dbHandle, err := sql.Open("postgres", jobsdb.GetConnectionString())
+	if err != nil {
+		panic(fmt.Errorf("could not connect to the dead-letter queue db: %w", err))
+	}
+	m := &migrator.Migrator{
+		Handle:                     dbHandle,
+		MigrationsTable:            "dlq_migrations",
+		ShouldForceSetLowerVersion: config.GetBool("SQLMigrator.forceSetLowerVersion", false),
+	}
+	if err := m.Migrate("dlq"); err != nil {
+		panic(fmt.Errorf("could not run dlq migrations: %w", err))
+	}
+
+	handle := New(dbHandle, storer(routerDB), storer(batchRouterDB))
+	instanceLock.Lock()
+	instance = handle
+	instanceLock.Unlock()
+	registerAdminHandler()
+	rruntime.Go(func() {
+		handle.cleanupLoop(ctx)
+	})
+}
+
+//cleanupLoop deletes the expired jobs and audit records every DLQ.cleanupInterval until ctx is done
+func (handle *HandleT) cleanupLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cleanupInterval):
+		}
+		if _, err := handle.Cleanup(ctx); err != nil && ctx.Err() == nil {
+			pkgLogger.Errorf("Failed to delete expired dead-letter queue jobs: %v", err)
+		}
+	}
+}
+
+//Cleanup deletes the jobs failed, and the audit records created, more than DLQ.retentionPeriod ago. It returns the number of jobs deleted
+func (handle *HandleT) Cleanup(ctx context.Context) (int64, error) {
+	expiredBefore := time.Now().Add(-retentionPeriod)
+	result, err := handle.dbHandle.ExecContext(ctx, `DELETE FROM dlq_jobs WHERE failed_at < $1`, expiredBefore)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired dead-letter queue jobs: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	if _, err := handle.dbHandle.ExecContext(ctx, `DELETE FROM dlq_audit WHERE created_at < $1`, expiredBefore); err != nil {
+		return deleted, fmt.Errorf("deleting expired dead-letter queue audit: %w", err)
+	}
+	if deleted > 0 {
+		pkgLogger.Infof("Deleted %d dead-letter queue jobs failed before %v", deleted, expiredBefore)
+	}
+	stats.NewStat("dlq_jobs_expired", stats.CountType).Count(int(deleted))
+	return deleted, nil
+}
+
+//storer avoids wrapping nil jobsdbs into non nil interfaces
+func storer(handle *jobsdb.HandleT) Storer {
+	if handle == nil {
+		return nil
+	}
+	return handle
+}
+
+//Index adds the proc_error jobs to the dead-letter queue, if it is set up. Jobs already indexed are ignored
+func Index(jobs []*jobsdb.JobT) error {
+	instanceLock.RLock()
+	handle := instance
+	instanceLock.RUnlock()
+	if handle == nil {
+		return nil
+	}
+	return handle.Index(context.Background(), jobs)
+}
+
+//Index adds the proc_error jobs to the dead-letter queue. Jobs already indexed are ignored
+func (handle *HandleT) Index(ctx context.Context, jobs []*jobsdb.JobT) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	txn, err := handle.dbHandle.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = txn.Rollback() }()
+
+	stmt, err := txn.PrepareContext(ctx, `INSERT INTO dlq_jobs (job_uuid, job_id, kind, stage, workspace_id, source_id, destination_id, destination_type, error_code, error, user_id, parameters, event_payload, event_count, state, failed_at) `+
+		`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) ON CONFLICT (job_uuid) DO NOTHING`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var indexed int64
+	for _, job := range jobs {
+		entry := entryOf(job)
+		result, err := stmt.ExecContext(ctx, entry.JobUUID, entry.JobID, entry.Kind, entry.Stage, entry.WorkspaceID, entry.SourceID, entry.DestinationID, entry.DestinationType,
+			entry.ErrorCode, entry.Error, entry.UserID, string(entry.Parameters), string(entry.EventPayload), entry.EventCount, entry.State, entry.FailedAt)
+		if err != nil {
+			return fmt.Errorf("indexing proc_error job %d: %w", job.JobID, err)
+		}
+		rows, _ := result.RowsAffected()
+		indexed += rows
+	}
+	if err := txn.Commit(); err != nil {
+		return err
+	}
+	stats.NewStat("dlq_jobs_indexed", stats.CountType).Count(int(indexed))
+	return nil
+}
+
+//entryOf returns the dead-letter queue entry of a proc_error job
+func entryOf(job *jobsdb.JobT) JobT {
+	kind := KindRouterAborted
+	if replay.IsProcessorError(job) {
+		kind = KindProcessorError
+	}
+	parameters := gjson.ParseBytes(job.Parameters)
+	errorMessage := parameters.Get("error").String()
+	if errorMessage == "" {
+		errorMessage = parameters.Get("reason").String()
+	}
+	failedAt := job.CreatedAt
+	if failedAt.IsZero() {
+		failedAt = time.Now()
+	}
+	return JobT{
+		JobUUID:         job.UUID,
+		JobID:           job.JobID,
+		Kind:            kind,
+		Stage:           parameters.Get("stage").String(),
+		WorkspaceID:     job.WorkspaceId,
+		SourceID:        parameters.Get("source_id").String(),
+		DestinationID:   parameters.Get("destination_id").String(),
+		DestinationType: job.CustomVal,
+		ErrorCode:       parameters.Get("status_code").String(),
+		Error:           errorMessage,
+		UserID:          job.UserID,
+		Parameters:      json.RawMessage(job.Parameters),
+		EventPayload:    json.RawMessage(job.EventPayload),
+		EventCount:      job.EventCount,
+		State:           StatePending,
+		FailedAt:        failedAt,
+	}
+}
+
+//isEmpty returns true if the filter matches every job
+func (filter FilterT) isEmpty() bool {
+	return len(filter.IDs) == 0 && filter.WorkspaceID == "" && filter.SourceID == "" && filter.DestinationID == "" &&
+		filter.ErrorCode == "" && filter.Kind == "" && filter.Stage == "" && filter.From.IsZero() && filter.To.IsZero()
+}
+
+//limit returns the number of jobs to list for the filter
+func (filter FilterT) limit() int {
+	if filter.Limit <= 0 || filter.Limit > maxListLimit {
+		return maxListLimit
+	}
+	return filter.Limit
+}
+
+//conditions returns the where clause of the jobs matching the filter, along with its arguments
+func (filter FilterT) conditions() (string, []interface{}) {
+	clause := "id > $1"
+	args := []interface{}{filter.AfterID}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		clause += fmt.Sprintf(" AND "+condition, len(args))
+	}
+	if len(filter.IDs) > 0 {
+		add("id = ANY($%d)", pq.Array(filter.IDs))
+	}
+	for _, column := range []struct{ name, value string }{
+		{"workspace_id", filter.WorkspaceID},
+		{"source_id", filter.SourceID},
+		{"destination_id", filter.DestinationID},
+		{"error_code", filter.ErrorCode},
+		{"kind", filter.Kind},
+		{"stage", filter.Stage},
+		{"state", filter.State},
+	} {
+		if column.value != "" {
+			add(column.name+" = $%d", column.value)
+		}
+	}
+	if !filter.From.IsZero() {
+		add("failed_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("failed_at < $%d", filter.To)
+	}
+	return clause, args
+}
+
+const summaryColumns = `id, job_uuid, job_id, kind, stage, workspace_id, source_id, destination_id, destination_type, error_code, error, user_id, event_count, state, failed_at, created_at, updated_at`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSummary(row scanner, job *JobT, extra ...interface{}) error {
+	return row.Scan(append([]interface{}{&job.ID, &job.JobUUID, &job.JobID, &job.Kind, &job.Stage, &job.WorkspaceID, &job.SourceID, &job.DestinationID, &job.DestinationType,
+		&job.ErrorCode, &job.Error, &job.UserID, &job.EventCount, &job.State, &job.FailedAt, &job.CreatedAt, &job.UpdatedAt}, extra...)...)
+}
+
+//List returns the jobs matching the filter, ordered by id, without their parameters and payload
+func (handle *HandleT) List(ctx context.Context, filter FilterT) ([]JobT, error) {
+	clause, args := filter.conditions()
+	args = append(args, filter.limit())
+	sqlStatement := fmt.Sprintf(`SELECT %s FROM dlq_jobs WHERE %s ORDER BY id LIMIT $%d`, summaryColumns, clause, len(args))
+	rows, err := handle.dbHandle.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing dead-letter queue jobs: %w", err)
+	}
+	defer rows.Close()
+
+	jobs := []JobT{}
+	for rows.Next() {
+		var job JobT
+		if err := scanSummary(rows, &job); err != nil {
+			return nil, fmt.Errorf("scanning dead-letter queue jobs: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+//ErrNotFound is returned when inspecting a job which is not in the dead-letter queue
+var ErrNotFound = errors.New("job not found in the dead-letter queue")
+
+//Inspect returns a job of the dead-letter queue, with its parameters and payload, along with the actions taken on it
+func (handle *HandleT) Inspect(ctx context.Context, id int64) (InspectResultT, error) {
+	var result InspectResultT
+	var parameters, payload []byte
+	row := handle.dbHandle.QueryRowContext(ctx, fmt.Sprintf(`SELECT %s, parameters, event_payload FROM dlq_jobs WHERE id = $1`, summaryColumns), id)
+	if err := scanSummary(row, &result.Job, &parameters, &payload); err != nil {
+		if err == sql.ErrNoRows {
+			return result, ErrNotFound
+		}
+		return result, fmt.Errorf("inspecting dead-letter queue job %d: %w", id, err)
+	}
+	result.Job.Parameters, result.Job.EventPayload = parameters, payload
+
+	rows, err := handle.dbHandle.QueryContext(ctx, `SELECT id, action, actor, reason, filter, dlq_job_ids, job_count, created_at FROM dlq_audit WHERE dlq_job_ids @> ARRAY[$1::BIGINT] ORDER BY id`, id)
+	if err != nil {
+		return result, fmt.Errorf("selecting audit of dead-letter queue job %d: %w", id, err)
+	}
+	defer rows.Close()
+	result.Audit = []AuditT{}
+	for rows.Next() {
+		var audit AuditT
+		var filter []byte
+		if err := rows.Scan(&audit.ID, &audit.Action, &audit.Actor, &audit.Reason, &filter, pq.Array(&audit.DLQJobIDs), &audit.JobCount, &audit.CreatedAt); err != nil {
+			return result, fmt.Errorf("scanning audit of dead-letter queue job %d: %w", id, err)
+		}
+		audit.Filter = filter
+		result.Audit = append(result.Audit, audit)
+	}
+	return result, rows.Err()
+}
+
+//validate returns an error if the request can't be acted on
+func (request ActionRequestT) validate(action string) error {
+	if request.FilterT.isEmpty() {
+		return errors.New("at least one filter is required to act on dead-letter queue jobs")
+	}
+	if action == ActionDiscard && request.Reason == "" {
+		return errors.New("reason is required to discard dead-letter queue jobs")
+	}
+	return nil
+}
+
+//Retry stores the pending jobs aborted by the router or batch router matching the request as new jobs of the jobsdb they were aborted from,
+//in the transaction marking them as retried. Processor errors are skipped and left pending
+func (handle *HandleT) Retry(ctx context.Context, request ActionRequestT) (ActionResultT, error) {
+	for _, jobsDB := range handle.jobsDBs {
+		jobsDB.AcquireStoreLock()
+		defer jobsDB.ReleaseStoreLock()
+	}
+	return handle.act(ctx, ActionRetry, request, func(txn *sql.Tx, jobs []JobT) ([]int64, error) {
+		retriedJobs := map[string][]*jobsdb.JobT{}
+		var retried []int64
+		for i := range jobs {
+			if jobs[i].Kind != KindRouterAborted {
+				continue
+			}
+			stage := jobs[i].Stage
+			if stage == "" {
+				stage = RouterStage
+			}
+			if _, ok := handle.jobsDBs[stage]; !ok {
+				continue
+			}
+			retriedJobs[stage] = append(retriedJobs[stage], retryJob(jobs[i]))
+			retried = append(retried, jobs[i].ID)
+		}
+		for stage, jobList := range retriedJobs {
+			if err := handle.jobsDBs[stage].StoreInTxn(txn, jobList); err != nil {
+				return nil, fmt.Errorf("storing retried jobs into the %s jobsdb: %w", stage, err)
+			}
+		}
+		return retried, nil
+	})
+}
+
+//Discard marks the pending jobs matching the request as discarded
+func (handle *HandleT) Discard(ctx context.Context, request ActionRequestT) (ActionResultT, error) {
+	return handle.act(ctx, ActionDiscard, request, func(_ *sql.Tx, jobs []JobT) ([]int64, error) {
+		discarded := make([]int64, 0, len(jobs))
+		for i := range jobs {
+			discarded = append(discarded, jobs[i].ID)
+		}
+		return discarded, nil
+	})
+}
+
+//act locks up to DLQ.maxBulkJobs pending jobs matching the request, calls fn with them and the transaction locking them,
+//and marks the jobs whose ids fn returns as retried or discarded, recording the action in the audit, in the same transaction
+func (handle *HandleT) act(ctx context.Context, action string, request ActionRequestT, fn func(txn *sql.Tx, jobs []JobT) ([]int64, error)) (ActionResultT, error) {
+	result := ActionResultT{Action: action}
+	if err := request.validate(action); err != nil {
+		return result, err
+	}
+
+	txn, err := handle.dbHandle.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = txn.Rollback() }()
+
+	filter := request.FilterT
+	filter.State, filter.AfterID = StatePending, 0
+	clause, args := filter.conditions()
+	args = append(args, maxBulkJobs)
+	sqlStatement := fmt.Sprintf(`SELECT %s, parameters, event_payload FROM dlq_jobs WHERE %s ORDER BY id LIMIT $%d FOR UPDATE SKIP LOCKED`, summaryColumns, clause, len(args))
+	rows, err := txn.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return result, fmt.Errorf("selecting dead-letter queue jobs to %s: %w", action, err)
+	}
+	var jobs []JobT
+	for rows.Next() {
+		var job JobT
+		var parameters, payload []byte
+		if err := scanSummary(rows, &job, &parameters, &payload); err != nil {
+			rows.Close()
+			return result, fmt.Errorf("scanning dead-letter queue jobs to %s: %w", action, err)
+		}
+		job.Parameters, job.EventPayload = parameters, payload
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	result.Selected = len(jobs)
+	if len(jobs) == 0 {
+		return result, nil
+	}
+
+	ids, err := fn(txn, jobs)
+	if err != nil {
+		return result, err
+	}
+	result.Done, result.Skipped = len(ids), len(jobs)-len(ids)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	state := StateRetried
+	if action == ActionDiscard {
+		state = StateDiscarded
+	}
+	if _, err := txn.ExecContext(ctx, `UPDATE dlq_jobs SET state = $1, updated_at = NOW() WHERE id = ANY($2)`, state, pq.Array(ids)); err != nil {
+		return result, fmt.Errorf("marking dead-letter queue jobs as %s: %w", state, err)
+	}
+	filterJSON, err := json.Marshal(request.FilterT)
+	if err != nil {
+		return result, err
+	}
+	err = txn.QueryRowContext(ctx, `INSERT INTO dlq_audit (action, actor, reason, filter, dlq_job_ids, job_count) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		action, request.Actor, request.Reason, string(filterJSON), pq.Array(ids), len(ids)).Scan(&result.AuditID)
+	if err != nil {
+		return result, fmt.Errorf("recording %s of dead-letter queue jobs: %w", action, err)
+	}
+	if err := txn.Commit(); err != nil {
+		pkgLogger.Errorf("Failed to commit %s of %d dead-letter queue jobs: %v", action, len(ids), err)
+		return result, err
+	}
+
+	pkgLogger.Infof("%s of %d dead-letter queue jobs by %q recorded with audit id %d, skipped %d", action, result.Done, request.Actor, result.AuditID, result.Skipped)
+	stats.NewTaggedStat("dlq_jobs_acted_on", stats.CountType, stats.Tags{"action": action}).Count(result.Done)
+	return result, nil
+}
+
+//retryJob returns a new router job with the payload of a job aborted by the router or batch router
+func retryJob(job JobT) *jobsdb.JobT {
+	parameters := []byte(job.Parameters)
+	for _, key := range []string{"stage", "reason", "status_code"} {
+		if updated, err := sjson.DeleteBytes(parameters, key); err == nil {
+			parameters = updated
+		}
+	}
+	return &jobsdb.JobT{
+		UUID:         uuid.Must(uuid.NewV4()),
+		UserID:       job.UserID,
+		Parameters:   parameters,
+		CustomVal:    job.DestinationType,
+		EventPayload: []byte(job.EventPayload),
+		EventCount:   job.EventCount,
+		WorkspaceId:  job.WorkspaceID,
+	}
+}
@@ -0,0 +1,152 @@
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	migrator "github.com/rudderlabs/rudder-server/services/sql-migrator"
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+)
+
+// tableStorerT stores retried jobs into a table of the dead-letter queue database, failing if err is set
+type tableStorerT struct {
+	err error
+}
+
+func (*tableStorerT) AcquireStoreLock() {}
+func (*tableStorerT) ReleaseStoreLock() {}
+
+func (storer *tableStorerT) StoreInTxn(txn *sql.Tx, jobList []*jobsdb.JobT) error {
+	for _, job := range jobList {
+		if _, err := txn.Exec(`INSERT INTO retried_jobs (uuid, parameters) VALUES ($1, $2)`, job.UUID, string(job.Parameters)); err != nil {
+			return err
+		}
+	}
+	return storer.err
+}
+
+func TestDLQ(t *testing.T) {
+	db, _ := testutils.SetupPostgres(t)
+	initDLQ()
+	require.NoError(t, (&migrator.Migrator{Handle: db, MigrationsTable: "dlq_migrations"}).Migrate("dlq"))
+	_, err := db.Exec(`CREATE TABLE retried_jobs (uuid UUID NOT NULL, parameters JSONB NOT NULL)`)
+	require.NoError(t, err)
+
+	routerDB := &tableStorerT{}
+	handle := New(db, routerDB, nil)
+	ctx := context.Background()
+
+	newJob := func(destinationID, parameters, payload string, createdAt time.Time) *jobsdb.JobT {
+		return &jobsdb.JobT{
+			UUID:         uuid.Must(uuid.NewV4()),
+			JobID:        1,
+			UserID:       "user",
+			CreatedAt:    createdAt,
+			CustomVal:    "WEBHOOK",
+			Parameters:   []byte(`{"source_id":"source","destination_id":"` + destinationID + `",` + parameters + `}`),
+			EventPayload: []byte(payload),
+			EventCount:   1,
+			WorkspaceId:  "workspace",
+		}
+	}
+	retriedJobs := func() (count int) {
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM retried_jobs`).Scan(&count))
+		return
+	}
+	stateOf := func(destinationID string) (states []string) {
+		jobs, err := handle.List(ctx, FilterT{DestinationID: destinationID})
+		require.NoError(t, err)
+		for _, job := range jobs {
+			states = append(states, job.State)
+		}
+		return
+	}
+
+	now := time.Now()
+	jobs := []*jobsdb.JobT{
+		newJob("d1", `"stage":"router","status_code":"500","reason":"{}"`, `{"body":{}}`, now),
+		newJob("d1", `"stage":"dest_transformer","status_code":400,"error":"invalid"`, `[{"messageId":"1"}]`, now),
+		newJob("d2", `"stage":"router","status_code":"500","reason":"{}"`, `{"body":{}}`, now),
+		newJob("d3", `"stage":"batch_router","status_code":"500","reason":"{}"`, `{"body":{}}`, now.Add(-2*retentionPeriod)),
+	}
+
+	t.Run("index", func(t *testing.T) {
+		require.NoError(t, handle.Index(ctx, jobs))
+		require.NoError(t, handle.Index(ctx, jobs[:1]), "jobs already indexed are ignored")
+		indexed, err := handle.List(ctx, FilterT{})
+		require.NoError(t, err)
+		require.Len(t, indexed, 4)
+		require.Equal(t, KindRouterAborted, indexed[0].Kind)
+		require.Equal(t, KindProcessorError, indexed[1].Kind)
+
+		inspected, err := handle.Inspect(ctx, indexed[0].ID)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"body":{}}`, string(inspected.Job.EventPayload))
+		_, err = handle.Inspect(ctx, 0)
+		require.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("retry stores jobs in the transaction marking them as retried", func(t *testing.T) {
+		routerDB.err = errors.New("store failed")
+		_, err := handle.Retry(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d1"}, Actor: "tester"})
+		require.Error(t, err)
+		require.Zero(t, retriedJobs(), "failed retries are rolled back")
+		require.Equal(t, []string{StatePending, StatePending}, stateOf("d1"))
+
+		routerDB.err = nil
+		result, err := handle.Retry(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d1"}, Actor: "tester"})
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Selected)
+		require.Equal(t, 1, result.Done)
+		require.Equal(t, 1, result.Skipped, "processor errors can't be retried")
+		require.NotZero(t, result.AuditID)
+		require.Equal(t, 1, retriedJobs())
+		require.Equal(t, []string{StateRetried, StatePending}, stateOf("d1"))
+
+		var parameters string
+		require.NoError(t, db.QueryRow(`SELECT parameters FROM retried_jobs`).Scan(&parameters))
+		require.JSONEq(t, `{"source_id":"source","destination_id":"d1"}`, parameters)
+
+		result, err = handle.Retry(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d1"}, Actor: "tester"})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.Done, "retried jobs are not retried again")
+		require.Equal(t, 1, retriedJobs())
+
+		result, err = handle.Retry(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d3"}, Actor: "tester"})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Skipped, "jobs of the batch router can't be retried without its jobsdb")
+	})
+
+	t.Run("discard is audited", func(t *testing.T) {
+		_, err := handle.Discard(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d2"}})
+		require.Error(t, err, "a reason is required")
+
+		result, err := handle.Discard(ctx, ActionRequestT{FilterT: FilterT{DestinationID: "d2"}, Actor: "tester", Reason: "bad credentials"})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Done)
+		require.Equal(t, []string{StateDiscarded}, stateOf("d2"))
+
+		jobs, err := handle.List(ctx, FilterT{DestinationID: "d2"})
+		require.NoError(t, err)
+		inspected, err := handle.Inspect(ctx, jobs[0].ID)
+		require.NoError(t, err)
+		require.Len(t, inspected.Audit, 1)
+		require.Equal(t, ActionDiscard, inspected.Audit[0].Action)
+		require.Equal(t, "bad credentials", inspected.Audit[0].Reason)
+	})
+
+	t.Run("cleanup deletes expired jobs", func(t *testing.T) {
+		deleted, err := handle.Cleanup(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), deleted)
+		require.Empty(t, stateOf("d3"))
+		require.Len(t, stateOf("d1"), 2)
+	})
+}
@@ -0,0 +1,103 @@
+package dlq
+
+import (
+	"testing"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+func initDLQ() {
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	Init()
+}
+
+func Test_entryOf(t *testing.T) {
+	initDLQ()
+	createdAt := time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC)
+	newJob := func(parameters, payload string) *jobsdb.JobT {
+		return &jobsdb.JobT{
+			UUID:         uuid.Must(uuid.NewV4()),
+			JobID:        7,
+			UserID:       "user",
+			CreatedAt:    createdAt,
+			CustomVal:    "WEBHOOK",
+			Parameters:   []byte(parameters),
+			EventPayload: []byte(payload),
+			EventCount:   1,
+			WorkspaceId:  "workspace",
+		}
+	}
+
+	processorError := newJob(`{"source_id":"source","destination_id":"destination","stage":"dest_transformer","status_code":400,"error":"invalid event"}`, `[{"messageId":"1"}]`)
+	entry := entryOf(processorError)
+	require.Equal(t, KindProcessorError, entry.Kind)
+	require.Equal(t, "dest_transformer", entry.Stage)
+	require.Equal(t, "400", entry.ErrorCode)
+	require.Equal(t, "invalid event", entry.Error)
+	require.Equal(t, "workspace", entry.WorkspaceID)
+	require.Equal(t, "source", entry.SourceID)
+	require.Equal(t, "destination", entry.DestinationID)
+	require.Equal(t, "WEBHOOK", entry.DestinationType)
+	require.Equal(t, StatePending, entry.State)
+	require.Equal(t, createdAt, entry.FailedAt)
+	require.Equal(t, processorError.UUID, entry.JobUUID)
+
+	routerAborted := newJob(`{"source_id":"source","destination_id":"destination","stage":"router","status_code":"500","reason":"{\"response\":\"timeout\"}"}`, `{"body":{}}`)
+	entry = entryOf(routerAborted)
+	require.Equal(t, KindRouterAborted, entry.Kind)
+	require.Equal(t, RouterStage, entry.Stage)
+	require.Equal(t, "500", entry.ErrorCode)
+	require.Equal(t, `{"response":"timeout"}`, entry.Error)
+}
+
+func Test_filterConditions(t *testing.T) {
+	initDLQ()
+	from := time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)
+	clause, args := FilterT{AfterID: 10}.conditions()
+	require.Equal(t, "id > $1", clause)
+	require.Equal(t, []interface{}{int64(10)}, args)
+
+	clause, args = FilterT{IDs: []int64{1, 2}, DestinationID: "destination", ErrorCode: "400", State: StatePending, From: from}.conditions()
+	require.Equal(t, "id > $1 AND id = ANY($2) AND destination_id = $3 AND error_code = $4 AND state = $5 AND failed_at >= $6", clause)
+	require.Equal(t, []interface{}{int64(0), pq.Array([]int64{1, 2}), "destination", "400", StatePending, from}, args)
+
+	require.Equal(t, maxListLimit, FilterT{}.limit())
+	require.Equal(t, maxListLimit, FilterT{Limit: maxListLimit + 1}.limit())
+	require.Equal(t, 5, FilterT{Limit: 5}.limit())
+}
+
+func Test_validateActionRequest(t *testing.T) {
+	require.Error(t, ActionRequestT{}.validate(ActionRetry), "a filter is required")
+	require.Error(t, ActionRequestT{FilterT: FilterT{State: StatePending}}.validate(ActionRetry), "state alone doesn't filter pending jobs")
+	require.NoError(t, ActionRequestT{FilterT: FilterT{DestinationID: "destination"}}.validate(ActionRetry))
+	require.Error(t, ActionRequestT{FilterT: FilterT{DestinationID: "destination"}}.validate(ActionDiscard), "a reason is required")
+	require.NoError(t, ActionRequestT{FilterT: FilterT{DestinationID: "destination"}, Reason: "bad credentials"}.validate(ActionDiscard))
+}
+
+func Test_retryJob(t *testing.T) {
+	job := JobT{
+		UserID:          "user",
+		WorkspaceID:     "workspace",
+		DestinationType: "WEBHOOK",
+		Parameters:      []byte(`{"source_id":"source","destination_id":"destination","stage":"router","status_code":"500","reason":"{}"}`),
+		EventPayload:    []byte(`{"body":{}}`),
+		EventCount:      1,
+	}
+	retried := retryJob(job)
+	require.NotEqual(t, uuid.Nil, retried.UUID)
+	require.JSONEq(t, `{"source_id":"source","destination_id":"destination"}`, string(retried.Parameters))
+	require.Equal(t, "WEBHOOK", retried.CustomVal)
+	require.Equal(t, "workspace", retried.WorkspaceId)
+	require.Equal(t, "user", retried.UserID)
+	require.JSONEq(t, `{"body":{}}`, string(retried.EventPayload))
+}
@@ -85,6 +85,15 @@ func (manager *AzureBlobStorageManager) getContainerURL() (azblob.ContainerURL,
 
 // Upload passed in file to Azure Blob Storage
 func (manager *AzureBlobStorageManager) Upload(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, false, prefixes...)
+}
+
+// UploadIfNotExists uploads the file with an If-None-Match condition on the blob, see ConditionalUploader
+func (manager *AzureBlobStorageManager) UploadIfNotExists(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, true, prefixes...)
+}
+
+func (manager *AzureBlobStorageManager) upload(ctx context.Context, file *os.File, ifNotExists bool, prefixes ...string) (UploadOutput, error) {
 	containerURL, err := manager.getContainerURL()
 	if err != nil {
 		return UploadOutput{}, err
@@ -115,10 +124,18 @@ func (manager *AzureBlobStorageManager) Upload(ctx context.Context, file *os.Fil
 
 	// Here's how to upload a blob.
 	blobURL := containerURL.NewBlockBlobURL(fileName)
-	_, err = azblob.UploadFileToBlockBlob(ctx, file, blobURL, azblob.UploadToBlockBlobOptions{
+	uploadOptions := azblob.UploadToBlockBlobOptions{
 		BlockSize:   4 * 1024 * 1024,
-		Parallelism: 16})
+		Parallelism: 16}
+	if ifNotExists {
+		uploadOptions.AccessConditions.ModifiedAccessConditions.IfNoneMatch = azblob.ETagAny
+	}
+	_, err = azblob.UploadFileToBlockBlob(ctx, file, blobURL, uploadOptions)
 	if err != nil {
+		if serr, ok := err.(azblob.StorageError); ok && ifNotExists &&
+			(serr.ServiceCode() == azblob.ServiceCodeBlobAlreadyExists || serr.ServiceCode() == azblob.ServiceCodeConditionNotMet) {
+			return UploadOutput{}, ErrKeyExists
+		}
 		return UploadOutput{}, err
 	}
 
@@ -289,6 +289,14 @@ func TestFileManager(t *testing.T) {
 				uploadOutputs = append(uploadOutputs, uploadOutput)
 				filePtr.Close()
 			}
+			//uploading a file again fails with a conditional upload
+			if uploader, ok := fm.(filemanager.ConditionalUploader); ok {
+				filePtr, err := os.Open(fileList[0])
+				require.NoError(t, err, "error while opening testData file to upload")
+				_, err = uploader.UploadIfNotExists(context.TODO(), filePtr)
+				filePtr.Close()
+				require.ErrorIs(t, err, filemanager.ErrKeyExists)
+			}
 			//list files using ListFilesWithPrefix
 			originalFileObject, err := fm.ListFilesWithPrefix(context.TODO(), "", 1000)
 			require.Equal(t, len(fileList), len(originalFileObject), "actual number of files different than expected")
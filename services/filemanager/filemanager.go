@@ -16,6 +16,7 @@ import (
 var (
 	DefaultFileManagerFactory FileManagerFactory
 	ErrKeyNotFound            = errors.New("NoSuchKey")
+	ErrKeyExists              = errors.New("KeyExists")
 )
 
 type FileManagerFactoryT struct{}
@@ -46,6 +47,12 @@ type FileManager interface {
 	SetTimeout(timeout *time.Duration)
 }
 
+// ConditionalUploader is implemented by the file managers which can upload a file only if no object exists at its key,
+// atomically. UploadIfNotExists fails with ErrKeyExists otherwise.
+type ConditionalUploader interface {
+	UploadIfNotExists(context.Context, *os.File, ...string) (UploadOutput, error)
+}
+
 // SettingsT sets configuration for FileManager
 type SettingsT struct {
 	Provider string
@@ -2,12 +2,15 @@ package filemanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/iterator"
 
 	"cloud.google.com/go/storage"
@@ -22,6 +25,15 @@ func (manager *GCSManager) objectURL(objAttrs *storage.ObjectAttrs) string {
 }
 
 func (manager *GCSManager) Upload(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, false, prefixes...)
+}
+
+// UploadIfNotExists uploads the file with a DoesNotExist precondition on the generation of the object, see ConditionalUploader
+func (manager *GCSManager) UploadIfNotExists(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, true, prefixes...)
+}
+
+func (manager *GCSManager) upload(ctx context.Context, file *os.File, ifNotExists bool, prefixes ...string) (UploadOutput, error) {
 	splitFileName := strings.Split(file.Name(), "/")
 	fileName := ""
 	if len(prefixes) > 0 {
@@ -45,14 +57,24 @@ func (manager *GCSManager) Upload(ctx context.Context, file *os.File, prefixes .
 	defer cancel()
 
 	obj := client.Bucket(manager.Config.Bucket).Object(fileName)
-	w := obj.NewWriter(ctx)
+	writeObj := obj
+	if ifNotExists {
+		writeObj = obj.If(storage.Conditions{DoesNotExist: true})
+	}
+	w := writeObj.NewWriter(ctx)
 	defer func() error {
 		return w.Close()
 	}()
 	if _, err := io.Copy(w, file); err != nil {
 		return UploadOutput{}, err
 	}
-	w.Close()
+	if err := w.Close(); ifNotExists && err != nil {
+		var apiErr *googleapi.Error
+		if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+			return UploadOutput{}, ErrKeyExists
+		}
+		return UploadOutput{}, err
+	}
 
 	attrs, err := obj.Attrs(ctx)
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -21,6 +23,15 @@ import (
 
 // Upload passed in file to s3
 func (manager *S3Manager) Upload(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, false, prefixes...)
+}
+
+// UploadIfNotExists uploads passed in file to s3 with an If-None-Match precondition, see ConditionalUploader
+func (manager *S3Manager) UploadIfNotExists(ctx context.Context, file *os.File, prefixes ...string) (UploadOutput, error) {
+	return manager.upload(ctx, file, true, prefixes...)
+}
+
+func (manager *S3Manager) upload(ctx context.Context, file *os.File, ifNotExists bool, prefixes ...string) (UploadOutput, error) {
 	splitFileName := strings.Split(file.Name(), "/")
 	fileName := ""
 
@@ -51,6 +62,9 @@ func (manager *S3Manager) Upload(ctx context.Context, file *os.File, prefixes ..
 		return UploadOutput{}, fmt.Errorf(`error starting S3 session: %v`, err)
 	}
 	s3manager := awsS3Manager.NewUploader(uploadSession)
+	if ifNotExists {
+		s3manager.RequestOptions = append(s3manager.RequestOptions, request.WithSetRequestHeaders(map[string]string{"If-None-Match": "*"}))
+	}
 
 	ctx, cancel := context.WithTimeout(ctx, getSafeTimeout(manager.Timeout))
 	defer cancel()
@@ -60,6 +74,10 @@ func (manager *S3Manager) Upload(ctx context.Context, file *os.File, prefixes ..
 		if awsError, ok := err.(awserr.Error); ok && awsError.Code() == "MissingRegion" {
 			err = fmt.Errorf(fmt.Sprintf(`Bucket '%s' not found.`, manager.Config.Bucket))
 		}
+		// 409 is returned when a concurrent conditional upload of the key is in progress
+		if reqErr, ok := err.(awserr.RequestFailure); ok && ifNotExists && (reqErr.StatusCode() == http.StatusPreconditionFailed || reqErr.StatusCode() == http.StatusConflict) {
+			err = ErrKeyExists
+		}
 		return UploadOutput{}, err
 	}
 
@@ -25,21 +25,34 @@ func init() {
 }
 
 func (m *redisManagerT) Connect() {
+	m.clusterMode, m.client, m.clusterClient = newRedisClients(m.config)
+}
+
+//NewRedisClient returns a client of the redis configured the same way as the redis of kv store destinations
+func NewRedisClient(config types.ConfigT) redis.UniversalClient {
+	clusterMode, client, clusterClient := newRedisClients(config)
+	if clusterMode {
+		return clusterClient
+	}
+	return client
+}
+
+func newRedisClients(config types.ConfigT) (clusterMode bool, client *redis.Client, clusterClient *redis.ClusterClient) {
 	var ok bool
-	if m.clusterMode, ok = m.config["clusterMode"].(bool); !ok {
+	if clusterMode, ok = config["clusterMode"].(bool); !ok {
 		// setting redis to cluster mode by default if setting missing in config
-		m.clusterMode = true
+		clusterMode = true
 	}
-	shouldSecureConn, _ := m.config["secure"].(bool)
-	addr, _ := m.config["address"].(string)
-	password, _ := m.config["password"].(string)
+	shouldSecureConn, _ := config["secure"].(bool)
+	addr, _ := config["address"].(string)
+	password, _ := config["password"].(string)
 
 	tlsConfig := tls.Config{}
 	if shouldSecureConn {
-		if skipServerCertCheck, ok := m.config["skipVerify"].(bool); ok && skipServerCertCheck {
+		if skipServerCertCheck, ok := config["skipVerify"].(bool); ok && skipServerCertCheck {
 			tlsConfig.InsecureSkipVerify = true
 		}
-		if serverCACert, ok := m.config["caCertificate"].(string); ok && len(strings.TrimSpace(serverCACert)) > 0 {
+		if serverCACert, ok := config["caCertificate"].(string); ok && len(strings.TrimSpace(serverCACert)) > 0 {
 			caCert := []byte(serverCACert)
 			caCertPool := x509.NewCertPool()
 			caCertPool.AppendCertsFromPEM(caCert)
@@ -47,7 +60,7 @@ func (m *redisManagerT) Connect() {
 		}
 	}
 
-	if m.clusterMode {
+	if clusterMode {
 		addrs := strings.Split(addr, ",")
 		for i := range addrs {
 			addrs[i] = strings.TrimSpace(addrs[i])
@@ -59,10 +72,10 @@ func (m *redisManagerT) Connect() {
 		if shouldSecureConn {
 			opts.TLSConfig = &tlsConfig
 		}
-		m.clusterClient = redis.NewClusterClient(&opts)
+		clusterClient = redis.NewClusterClient(&opts)
 	} else {
 		var db int
-		if dbStr, ok := m.config["database"].(string); ok {
+		if dbStr, ok := config["database"].(string); ok {
 			db, _ = strconv.Atoi(dbStr)
 		}
 		opts := redis.Options{
@@ -73,8 +86,9 @@ func (m *redisManagerT) Connect() {
 		if shouldSecureConn {
 			opts.TLSConfig = &tlsConfig
 		}
-		m.client = redis.NewClient(&opts)
+		client = redis.NewClient(&opts)
 	}
+	return clusterMode, client, clusterClient
 }
 
 func (m *redisManagerT) Close() error {
@@ -0,0 +1,164 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/rudderlabs/rudder-server/admin"
+)
+
+//RPCHandler is the Replay admin rpc handler
+type RPCHandler struct{}
+
+var registerAdminHandlerOnce sync.Once
+
+func registerAdminHandler() {
+	registerAdminHandlerOnce.Do(func() {
+		admin.RegisterAdminHandler("Replay", &RPCHandler{})
+	})
+}
+
+func setupInstance() (*HandleT, error) {
+	instanceLock.RLock()
+	defer instanceLock.RUnlock()
+	if instance == nil {
+		return nil, fmt.Errorf("replay is not set up on this server")
+	}
+	return instance, nil
+}
+
+func replyJSON(reply *string, v interface{}) error {
+	response, err := json.MarshalIndent(v, "", " ")
+	if err != nil {
+		return err
+	}
+	*reply = string(response)
+	return nil
+}
+
+func recoverRPC(err *error) {
+	if r := recover(); r != nil {
+		pkgLogger.Error(r)
+		*err = fmt.Errorf("internal Rudder server error: %v", r)
+	}
+}
+
+//Replay starts a replay job of the jobs matching the request, replying with the JobStatusT of the job
+func (handler *RPCHandler) Replay(request RequestT, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	status, err := handle.Start(request)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, status)
+}
+
+//Status replies with the JobStatusT of a replay job
+func (handler *RPCHandler) Status(id string, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	status, err := handle.Status(id)
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, status)
+}
+
+//List replies with the JobStatusT of the running and last finished replay jobs
+func (handler *RPCHandler) List(_ string, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	return replyJSON(reply, handle.List())
+}
+
+//Cancel cancels a running replay job, jobs already replayed are not removed
+func (handler *RPCHandler) Cancel(id string, reply *string) (err error) {
+	defer recoverRPC(&err)
+	handle, err := setupInstance()
+	if err != nil {
+		return err
+	}
+	if err := handle.Cancel(id); err != nil {
+		return err
+	}
+	*reply = fmt.Sprintf("replay job %s cancelled", id)
+	return nil
+}
+
+//httpPath is the path of the replay endpoint, replay jobs are at httpPath/<id>
+const httpPath = "/v1/replay"
+
+/*
+HTTPHandler serves the replay jobs, like the Replay admin rpc:
+
+	POST   /v1/replay       starts a replay job of the jobs matching the RequestT in the body, responding with its JobStatusT
+	GET    /v1/replay       responds with the JobStatusT of the running and last finished replay jobs
+	GET    /v1/replay/<id>  responds with the JobStatusT of a replay job
+	DELETE /v1/replay/<id>  cancels a running replay job, jobs already replayed are not removed
+*/
+func HTTPHandler(w http.ResponseWriter, r *http.Request) {
+	handle, err := setupInstance()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	handle.ServeHTTP(w, r)
+}
+
+func (handle *HandleT) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, httpPath), "/")
+	switch {
+	case id == "" && r.Method == http.MethodPost:
+		var request RequestT
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, fmt.Sprintf("invalid replay request: %v", err), http.StatusBadRequest)
+			return
+		}
+		status, err := handle.Start(request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondJSON(w, http.StatusAccepted, status)
+	case id == "" && r.Method == http.MethodGet:
+		respondJSON(w, http.StatusOK, handle.List())
+	case id != "" && r.Method == http.MethodGet:
+		status, err := handle.Status(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondJSON(w, http.StatusOK, status)
+	case id != "" && r.Method == http.MethodDelete:
+		if err := handle.Cancel(id); errors.Is(err, ErrJobNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func respondJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,151 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+
+	"github.com/rudderlabs/rudder-server/rruntime"
+)
+
+//States of replay jobs
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+//JobStatusT is the status of a replay job started with Start
+type JobStatusT struct {
+	ID      string   `json:"id"`
+	Request RequestT `json:"request"`
+	State   string   `json:"state"`
+	//Result counts the jobs replayed so far
+	Result     ResultT   `json:"result"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+//replayJobT is a replay running in the background
+type replayJobT struct {
+	status JobStatusT
+	cancel context.CancelFunc
+}
+
+//ErrJobNotFound is returned for replay jobs which are not known to this server
+var ErrJobNotFound = errors.New("replay job not found")
+
+/*
+Start validates the request and replays the jobs matching it in the background, returning the status of the replay job.
+Up to Replay.maxRunningJobs replay jobs run at the same time and the last Replay.maxFinishedJobs finished ones are kept for their status.
+*/
+func (handle *HandleT) Start(request RequestT) (JobStatusT, error) {
+	if _, _, _, err := handle.plan(request); err != nil {
+		return JobStatusT{}, err
+	}
+
+	handle.replayJobsLock.Lock()
+	defer handle.replayJobsLock.Unlock()
+	var running int
+	for _, job := range handle.replayJobs {
+		if job.status.State == JobRunning {
+			running++
+		}
+	}
+	if running >= maxRunningJobs {
+		return JobStatusT{}, fmt.Errorf("%d replay jobs are already running", running)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &replayJobT{
+		status: JobStatusT{
+			ID:        uuid.Must(uuid.NewV4()).String(),
+			Request:   request,
+			State:     JobRunning,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+	}
+	handle.replayJobs[job.status.ID] = job
+	handle.pruneReplayJobs()
+
+	rruntime.Go(func() {
+		defer cancel()
+		result, err := handle.Replay(ctx, request)
+
+		handle.replayJobsLock.Lock()
+		defer handle.replayJobsLock.Unlock()
+		job.status.Result, job.status.FinishedAt = result, time.Now()
+		switch {
+		case err == nil:
+			job.status.State = JobSucceeded
+		case ctx.Err() != nil:
+			job.status.State, job.status.Error = JobCancelled, err.Error()
+		default:
+			job.status.State, job.status.Error = JobFailed, err.Error()
+			pkgLogger.Errorf("Replay job %s failed after replaying %d jobs: %v", job.status.ID, result.Replayed, err)
+		}
+	})
+	return job.status, nil
+}
+
+//Status returns the status of a replay job
+func (handle *HandleT) Status(id string) (JobStatusT, error) {
+	handle.replayJobsLock.Lock()
+	defer handle.replayJobsLock.Unlock()
+	job, ok := handle.replayJobs[id]
+	if !ok {
+		return JobStatusT{}, ErrJobNotFound
+	}
+	return job.status, nil
+}
+
+//List returns the status of the replay jobs, ordered by start time
+func (handle *HandleT) List() []JobStatusT {
+	handle.replayJobsLock.Lock()
+	defer handle.replayJobsLock.Unlock()
+	statuses := make([]JobStatusT, 0, len(handle.replayJobs))
+	for _, job := range handle.replayJobs {
+		statuses = append(statuses, job.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].StartedAt.Before(statuses[j].StartedAt) })
+	return statuses
+}
+
+//Cancel stops a running replay job. Jobs already replayed are kept
+func (handle *HandleT) Cancel(id string) error {
+	handle.replayJobsLock.Lock()
+	defer handle.replayJobsLock.Unlock()
+	job, ok := handle.replayJobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if job.status.State != JobRunning {
+		return fmt.Errorf("replay job %s is already %s", id, job.status.State)
+	}
+	job.cancel()
+	return nil
+}
+
+//pruneReplayJobs forgets the oldest finished replay jobs beyond Replay.maxFinishedJobs. replayJobsLock must be held
+func (handle *HandleT) pruneReplayJobs() {
+	var finished []*replayJobT
+	for _, job := range handle.replayJobs {
+		if job.status.State != JobRunning {
+			finished = append(finished, job)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].status.FinishedAt.Before(finished[j].status.FinishedAt) })
+	for _, job := range finished[:len(finished)-maxFinishedJobs] {
+		delete(handle.replayJobs, job.status.ID)
+	}
+}
@@ -0,0 +1,405 @@
+/*
+Package replay re-inserts the jobs of a source, stored in the gw, rt or proc_error jobsdb, as new jobs.
+
+Jobs are replayed either into the gateway jobsdb, so that their events are processed again for all the destinations of the source,
+or into the router jobsdb, so that they are sent again to a single destination:
+
+	gw         -> gw: gateway jobs are copied as they are
+	rt         -> rt: router jobs of the destination are copied as they are
+	proc_error -> gw: events failed by the processor are wrapped into a gateway batch of the source
+	proc_error -> rt: router jobs aborted for the destination are copied without the parameters of their failure
+
+Replays are started as background jobs through the Replay admin rpc, or the /v1/replay endpoint of the admin server of the gateway,
+which are only available if Replay.api.enabled. Jobs are replayed into gw through the jobsdb of the gateway, since it manages the datasets of gw,
+so they can't be replayed into gw on servers without a gateway.
+Replayed jobs get new uuids and job ids. Events replayed into the gateway jobsdb are dropped by the processor,
+if it deduplicates events and their message ids were processed within the deduplication window.
+*/
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/rruntime"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/pubsub"
+)
+
+//Tables jobs are replayed from and into
+const (
+	GatewayTable   = "gw"
+	RouterTable    = "rt"
+	ProcErrorTable = "proc_error"
+)
+
+//gatewayCustomVal is the custom_val of the jobs stored by the gateway
+const gatewayCustomVal = "GW"
+
+var (
+	pkgLogger       logger.LoggerI
+	enabledAPI      bool
+	maxRunningJobs  int
+	maxFinishedJobs int
+)
+
+func Init() {
+	loadConfig()
+	pkgLogger = logger.NewLogger().Child("replay")
+}
+
+func loadConfig() {
+	config.RegisterBoolConfigVariable(false, &enabledAPI, false, "Replay.api.enabled")
+	config.RegisterIntConfigVariable(2, &maxRunningJobs, true, 1, "Replay.maxRunningJobs")
+	config.RegisterIntConfigVariable(100, &maxFinishedJobs, true, 1, "Replay.maxFinishedJobs")
+}
+
+//JobsDB is the part of a jobsdb jobs are replayed from and into
+type JobsDB interface {
+	SelectJobs(ctx context.Context, opts jobsdb.RestoreOptsT, fn func(jobs []*jobsdb.JobT) error) (int, error)
+	ReadBackedUpJobs(ctx context.Context, opts jobsdb.RestoreOptsT, fn func(jobs []*jobsdb.JobT) error) (int, error)
+	Store(jobList []*jobsdb.JobT) error
+}
+
+//RequestT selects the jobs to replay and where to replay them
+type RequestT struct {
+	SourceID string `json:"sourceId"`
+	//DestinationID is required for replays into the router jobsdb
+	DestinationID string `json:"destinationId"`
+	//From and To limit the jobs to the ones created in [From, To). To defaults to now
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	//States of the jobs to replay, jobs without any status are in not_picked_yet state. Empty replays jobs in any state
+	States []string `json:"states"`
+	//Table jobs are replayed from: gw, rt or proc_error
+	Table string `json:"table"`
+	//Target jobs are replayed into: gw or rt
+	Target string `json:"target"`
+	//IncludeBackups replays the jobs matching the request from the backups of Table in object storage too
+	IncludeBackups bool `json:"includeBackups"`
+	//InstanceID whose backups are read, defaults to INSTANCE_ID
+	InstanceID string `json:"instanceId"`
+}
+
+//ResultT counts the jobs of a replay
+type ResultT struct {
+	Selected int `json:"selected"`
+	Replayed int `json:"replayed"`
+	//Skipped jobs matched the request but can't be replayed into the target, e.g. jobs aborted by the router can't be processed again
+	Skipped int `json:"skipped"`
+}
+
+//HandleT replays jobs between the jobsdbs of this server
+type HandleT struct {
+	//jobsDBs are the jobsdbs jobs are replayed from, targets the ones they are replayed into
+	jobsDBs       map[string]JobsDB
+	targets       map[string]JobsDB
+	backendConfig backendconfig.BackendConfig
+
+	sourcesLock sync.RWMutex
+	sources     map[string]backendconfig.SourceT
+
+	replayJobsLock sync.Mutex
+	replayJobs     map[string]*replayJobT
+}
+
+//convertFunc converts a selected job to the job replayed, returning false if the job can't be replayed
+type convertFunc func(job *jobsdb.JobT) (*jobsdb.JobT, bool)
+
+var (
+	instanceLock sync.RWMutex
+	instance     *HandleT
+)
+
+//New creates a replay handler for the jobsdbs. Nil jobsdbs can't be replayed from or into
+func New(gatewayDB, routerDB, procErrorDB JobsDB, backendConfig backendconfig.BackendConfig) *HandleT {
+	jobsDBs := map[string]JobsDB{}
+	targets := map[string]JobsDB{}
+	for table, jobsDB := range map[string]JobsDB{GatewayTable: gatewayDB, RouterTable: routerDB, ProcErrorTable: procErrorDB} {
+		if jobsDB != nil {
+			jobsDBs[table] = jobsDB
+			targets[table] = jobsDB
+		}
+	}
+	return &HandleT{
+		jobsDBs:       jobsDBs,
+		targets:       targets,
+		backendConfig: backendConfig,
+		sources:       map[string]backendconfig.SourceT{},
+		replayJobs:    map[string]*replayJobT{},
+	}
+}
+
+/*
+Setup makes the jobsdbs available to the Replay admin rpc and http endpoint, if Replay.api.enabled.
+gatewayWriteDB is the jobsdb the gateway stores its jobs with, jobs are replayed into gw through it only, as it adds the datasets of gw.
+It is nil on servers without a gateway, where jobs can be replayed from gw, through gatewayDB, but not into it.
+*/
+func Setup(gatewayDB, gatewayWriteDB, routerDB, procErrorDB *jobsdb.HandleT, backendConfig backendconfig.BackendConfig) {
+	if !enabledAPI {
+		return
+	}
+	if gatewayDB == nil {
+		gatewayDB = gatewayWriteDB
+	}
+	handle := New(jobsDB(gatewayDB), jobsDB(routerDB), jobsDB(procErrorDB), backendConfig)
+	if gatewayWriteDB == nil {
+		delete(handle.targets, GatewayTable)
+	} else {
+		handle.targets[GatewayTable] = gatewayWriteDB
+	}
+	rruntime.Go(func() {
+		handle.backendConfigSubscriber()
+	})
+
+	instanceLock.Lock()
+	instance = handle
+	instanceLock.Unlock()
+	registerAdminHandler()
+}
+
+//jobsDB avoids wrapping nil jobsdbs into non nil interfaces
+func jobsDB(handle *jobsdb.HandleT) JobsDB {
+	if handle == nil {
+		return nil
+	}
+	return handle
+}
+
+func (handle *HandleT) backendConfigSubscriber() {
+	ch := make(chan pubsub.DataEvent)
+	handle.backendConfig.Subscribe(ch, backendconfig.TopicProcessConfig)
+	for config := range ch {
+		sources := map[string]backendconfig.SourceT{}
+		for _, source := range config.Data.(backendconfig.ConfigT).Sources {
+			sources[source.ID] = source
+		}
+		handle.sourcesLock.Lock()
+		handle.sources = sources
+		handle.sourcesLock.Unlock()
+	}
+}
+
+func (handle *HandleT) source(sourceID string) (backendconfig.SourceT, bool) {
+	handle.sourcesLock.RLock()
+	defer handle.sourcesLock.RUnlock()
+	source, ok := handle.sources[sourceID]
+	return source, ok
+}
+
+//Replay stores the jobs matching the request as new jobs of the target jobsdb
+func (handle *HandleT) Replay(ctx context.Context, request RequestT) (ResultT, error) {
+	var result ResultT
+	from, target, convert, err := handle.plan(request)
+	if err != nil {
+		return result, err
+	}
+
+	opts := jobsdb.RestoreOptsT{
+		From:       request.From,
+		To:         request.To,
+		InstanceID: request.InstanceID,
+		SourceIDs:  []string{request.SourceID},
+		States:     request.States,
+	}
+	if request.DestinationID != "" {
+		opts.DestinationIDs = []string{request.DestinationID}
+	}
+
+	replayJobs := func(jobs []*jobsdb.JobT) error {
+		result.Selected += len(jobs)
+		replayedJobs := make([]*jobsdb.JobT, 0, len(jobs))
+		for _, job := range jobs {
+			replayedJob, ok := convert(job)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			replayedJobs = append(replayedJobs, replayedJob)
+		}
+		if len(replayedJobs) == 0 {
+			return nil
+		}
+		if err := target.Store(replayedJobs); err != nil {
+			return fmt.Errorf("storing replayed jobs into %s: %w", request.Target, err)
+		}
+		result.Replayed += len(replayedJobs)
+		return nil
+	}
+
+	pkgLogger.Infof("Replaying jobs of source %s, destination %q from %s into %s between %v and %v", request.SourceID, request.DestinationID, request.Table, request.Target, request.From, request.To)
+	defer func() {
+		tags := stats.Tags{"source": request.SourceID, "from": request.Table, "target": request.Target}
+		stats.NewTaggedStat("replay_jobs_replayed", stats.CountType, tags).Count(result.Replayed)
+		stats.NewTaggedStat("replay_jobs_skipped", stats.CountType, tags).Count(result.Skipped)
+	}()
+
+	if _, err := from.SelectJobs(ctx, opts, replayJobs); err != nil {
+		return result, fmt.Errorf("replaying jobs from %s: %w", request.Table, err)
+	}
+	if request.IncludeBackups {
+		if _, err := from.ReadBackedUpJobs(ctx, opts, replayJobs); err != nil {
+			return result, fmt.Errorf("replaying jobs from backups of %s: %w", request.Table, err)
+		}
+	}
+	pkgLogger.Infof("Replayed %d jobs of source %s from %s into %s, skipped %d", result.Replayed, request.SourceID, request.Table, request.Target, result.Skipped)
+	return result, nil
+}
+
+//plan validates the request and returns the jobsdbs jobs are replayed from and into, along with their conversion
+func (handle *HandleT) plan(request RequestT) (from, target JobsDB, convert convertFunc, err error) {
+	if convert, err = handle.converter(request); err != nil {
+		return nil, nil, nil, err
+	}
+	var ok bool
+	if from, ok = handle.jobsDBs[request.Table]; !ok {
+		return nil, nil, nil, fmt.Errorf("jobs can't be replayed from %s on this server", request.Table)
+	}
+	if target, ok = handle.targets[request.Target]; !ok {
+		return nil, nil, nil, fmt.Errorf("jobs can't be replayed into %s on this server", request.Target)
+	}
+	return from, target, convert, nil
+}
+
+//converter validates the request and returns the conversion of the jobs it selects
+func (handle *HandleT) converter(request RequestT) (convertFunc, error) {
+	if request.SourceID == "" {
+		return nil, errors.New("source id is required")
+	}
+	if request.From.IsZero() {
+		return nil, errors.New("start of the time range is required")
+	}
+
+	switch request.Target {
+	case GatewayTable:
+		switch request.Table {
+		case GatewayTable:
+			if request.DestinationID != "" {
+				return nil, errors.New("gateway jobs can't be filtered by destination")
+			}
+			return copyJob, nil
+		case ProcErrorTable:
+			source, ok := handle.source(request.SourceID)
+			if !ok {
+				return nil, fmt.Errorf("source %s not found in the workspace config", request.SourceID)
+			}
+			return func(job *jobsdb.JobT) (*jobsdb.JobT, bool) {
+				return toGatewayJob(job, source)
+			}, nil
+		}
+	case RouterTable:
+		if request.DestinationID == "" {
+			return nil, errors.New("destination id is required for replays into the router")
+		}
+		switch request.Table {
+		case RouterTable:
+			return copyJob, nil
+		case ProcErrorTable:
+			return func(job *jobsdb.JobT) (*jobsdb.JobT, bool) {
+				if IsProcessorError(job) {
+					return nil, false
+				}
+				return toRouterJob(job)
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("invalid target %q, jobs can be replayed into %s or %s", request.Target, GatewayTable, RouterTable)
+	}
+	return nil, fmt.Errorf("jobs of %s can't be replayed into %s", request.Table, request.Target)
+}
+
+//copyJob returns a new job with the same events as job
+func copyJob(job *jobsdb.JobT) (*jobsdb.JobT, bool) {
+	return &jobsdb.JobT{
+		UUID:         uuid.Must(uuid.NewV4()),
+		UserID:       job.UserID,
+		Parameters:   job.Parameters,
+		CustomVal:    job.CustomVal,
+		EventPayload: job.EventPayload,
+		EventCount:   job.EventCount,
+		WorkspaceId:  job.WorkspaceId,
+	}, true
+}
+
+//abortedParameters are added to the parameters of router jobs aborted into the proc_error jobsdb
+var abortedParameters = []string{"stage", "reason", "status_code"}
+
+//toRouterJob returns a new router job with the events of a router job aborted into the proc_error jobsdb
+func toRouterJob(job *jobsdb.JobT) (*jobsdb.JobT, bool) {
+	routerJob, _ := copyJob(job)
+	parameters := []byte(job.Parameters)
+	for _, key := range abortedParameters {
+		updated, err := sjson.DeleteBytes(parameters, key)
+		if err != nil {
+			pkgLogger.Errorf("Failed to remove %s from the parameters of proc_error job %d: %v", key, job.JobID, err)
+			return nil, false
+		}
+		parameters = updated
+	}
+	routerJob.Parameters = parameters
+	return routerJob, true
+}
+
+//IsProcessorError returns true for the proc_error jobs of events failed by the processor,
+//as opposed to the router jobs aborted by the router and batch router, whose payload is a single transformed event
+func IsProcessorError(job *jobsdb.JobT) bool {
+	switch gjson.GetBytes(job.Parameters, "stage").String() {
+	case "", "router", "batch_router":
+		return false
+	}
+	return gjson.ParseBytes(job.EventPayload).IsArray()
+}
+
+//toGatewayJob wraps the events failed by the processor in a proc_error job into a gateway batch of the source
+func toGatewayJob(job *jobsdb.JobT, source backendconfig.SourceT) (*jobsdb.JobT, bool) {
+	if !IsProcessorError(job) {
+		return nil, false
+	}
+	events := gjson.ParseBytes(job.EventPayload)
+	if !events.IsArray() || len(events.Array()) == 0 {
+		return nil, false
+	}
+
+	receivedAt := job.CreatedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	payload, err := sjson.SetRawBytes([]byte(`{}`), "batch", job.EventPayload)
+	if err == nil {
+		payload, err = sjson.SetBytes(payload, "writeKey", source.WriteKey)
+	}
+	if err == nil {
+		payload, err = sjson.SetBytes(payload, "requestIP", "")
+	}
+	if err == nil {
+		payload, err = sjson.SetBytes(payload, "receivedAt", receivedAt.Format(misc.RFC3339Milli))
+	}
+	if err != nil {
+		pkgLogger.Errorf("Failed to wrap events of proc_error job %d into a gateway batch: %v", job.JobID, err)
+		return nil, false
+	}
+
+	parameters, _ := sjson.SetBytes([]byte(`{}`), "source_id", source.ID)
+	parameters, _ = sjson.SetBytes(parameters, "source_job_run_id", gjson.GetBytes(job.Parameters, "source_job_run_id").String())
+	return &jobsdb.JobT{
+		UUID:         uuid.Must(uuid.NewV4()),
+		UserID:       job.UserID,
+		Parameters:   parameters,
+		CustomVal:    gatewayCustomVal,
+		EventPayload: payload,
+		EventCount:   len(events.Array()),
+		WorkspaceId:  job.WorkspaceId,
+	}, true
+}
@@ -0,0 +1,268 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/jobsdb"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+)
+
+type fakeJobsDBT struct {
+	jobs       []*jobsdb.JobT
+	backups    []*jobsdb.JobT
+	stored     []*jobsdb.JobT
+	lastOpts   jobsdb.RestoreOptsT
+	readBackup bool
+}
+
+func (jd *fakeJobsDBT) SelectJobs(_ context.Context, opts jobsdb.RestoreOptsT, fn func(jobs []*jobsdb.JobT) error) (int, error) {
+	jd.lastOpts = opts
+	return len(jd.jobs), fn(jd.jobs)
+}
+
+func (jd *fakeJobsDBT) ReadBackedUpJobs(_ context.Context, _ jobsdb.RestoreOptsT, fn func(jobs []*jobsdb.JobT) error) (int, error) {
+	jd.readBackup = true
+	return len(jd.backups), fn(jd.backups)
+}
+
+func (jd *fakeJobsDBT) Store(jobList []*jobsdb.JobT) error {
+	jd.stored = append(jd.stored, jobList...)
+	return nil
+}
+
+func initReplay() {
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	Init()
+}
+
+func newTestJob(parameters, payload string) *jobsdb.JobT {
+	return &jobsdb.JobT{
+		UUID:         uuid.Must(uuid.NewV4()),
+		JobID:        1,
+		UserID:       "user",
+		CreatedAt:    time.Date(2022, 4, 1, 10, 0, 0, 0, time.UTC),
+		CustomVal:    "WEBHOOK",
+		Parameters:   []byte(parameters),
+		EventPayload: []byte(payload),
+		EventCount:   1,
+		WorkspaceId:  "workspace",
+	}
+}
+
+func Test_Replay(t *testing.T) {
+	initReplay()
+	from := time.Now().Add(-time.Hour)
+
+	t.Run("gateway jobs are copied into the gateway", func(t *testing.T) {
+		gatewayDB := &fakeJobsDBT{jobs: []*jobsdb.JobT{newTestJob(`{"source_id":"source"}`, `{"batch":[]}`)}}
+		handle := New(gatewayDB, nil, nil, nil)
+
+		result, err := handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: GatewayTable, Target: GatewayTable, States: []string{"aborted"}})
+		require.NoError(t, err)
+		require.Equal(t, ResultT{Selected: 1, Replayed: 1}, result)
+		require.Equal(t, jobsdb.RestoreOptsT{From: from, SourceIDs: []string{"source"}, States: []string{"aborted"}}, gatewayDB.lastOpts)
+		require.False(t, gatewayDB.readBackup)
+
+		require.Len(t, gatewayDB.stored, 1)
+		require.NotEqual(t, gatewayDB.jobs[0].UUID, gatewayDB.stored[0].UUID, "replayed jobs get new uuids")
+		require.Equal(t, gatewayDB.jobs[0].EventPayload, gatewayDB.stored[0].EventPayload)
+		require.Zero(t, gatewayDB.stored[0].JobID)
+
+		_, err = handle.Replay(context.Background(), RequestT{SourceID: "source", DestinationID: "destination", From: from, Table: GatewayTable, Target: GatewayTable})
+		require.Error(t, err, "gateway jobs aren't specific to a destination")
+
+		delete(handle.targets, GatewayTable)
+		_, err = handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: GatewayTable, Target: GatewayTable})
+		require.Error(t, err, "jobs are only replayed into gw through the jobsdb of the gateway")
+	})
+
+	t.Run("router jobs are copied into the router, including backups", func(t *testing.T) {
+		routerDB := &fakeJobsDBT{
+			jobs:    []*jobsdb.JobT{newTestJob(`{"source_id":"source","destination_id":"destination"}`, `{}`)},
+			backups: []*jobsdb.JobT{newTestJob(`{"source_id":"source","destination_id":"destination"}`, `{}`)},
+		}
+		handle := New(nil, routerDB, nil, nil)
+
+		result, err := handle.Replay(context.Background(), RequestT{SourceID: "source", DestinationID: "destination", From: from, Table: RouterTable, Target: RouterTable, IncludeBackups: true})
+		require.NoError(t, err)
+		require.Equal(t, ResultT{Selected: 2, Replayed: 2}, result)
+		require.Equal(t, []string{"destination"}, routerDB.lastOpts.DestinationIDs)
+		require.True(t, routerDB.readBackup)
+
+		_, err = handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: RouterTable, Target: RouterTable})
+		require.Error(t, err, "destination is required")
+		_, err = handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: RouterTable, Target: GatewayTable})
+		require.Error(t, err, "router jobs can't be processed again")
+		_, err = handle.Replay(context.Background(), RequestT{SourceID: "source", DestinationID: "destination", From: from, Table: ProcErrorTable, Target: RouterTable})
+		require.Error(t, err, "proc_error jobsdb is not available")
+	})
+
+	t.Run("proc_error jobs are replayed depending on the stage they failed at", func(t *testing.T) {
+		processorError := newTestJob(`{"source_id":"source","destination_id":"destination","stage":"dest_transformer","source_job_run_id":"run"}`, `[{"messageId":"1"},{"messageId":"2"}]`)
+		routerAborted := newTestJob(`{"source_id":"source","destination_id":"destination","stage":"router","reason":"{}","status_code":"400"}`, `{"body":{}}`)
+		gatewayDB, routerDB := &fakeJobsDBT{}, &fakeJobsDBT{}
+		procErrorDB := &fakeJobsDBT{jobs: []*jobsdb.JobT{processorError, routerAborted}}
+		handle := New(gatewayDB, routerDB, procErrorDB, nil)
+
+		_, err := handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: ProcErrorTable, Target: GatewayTable})
+		require.Error(t, err, "source is not in the workspace config")
+
+		handle.sources = map[string]backendconfig.SourceT{"source": {ID: "source", WriteKey: "writeKey"}}
+		result, err := handle.Replay(context.Background(), RequestT{SourceID: "source", From: from, Table: ProcErrorTable, Target: GatewayTable})
+		require.NoError(t, err)
+		require.Equal(t, ResultT{Selected: 2, Replayed: 1, Skipped: 1}, result)
+		require.Len(t, gatewayDB.stored, 1)
+		gatewayJob := gatewayDB.stored[0]
+		require.Equal(t, gatewayCustomVal, gatewayJob.CustomVal)
+		require.Equal(t, 2, gatewayJob.EventCount)
+		require.JSONEq(t, `{"source_id":"source","source_job_run_id":"run"}`, string(gatewayJob.Parameters))
+		require.Equal(t, "writeKey", gjson.GetBytes(gatewayJob.EventPayload, "writeKey").String())
+		require.Equal(t, "2022-04-01T10:00:00.000Z", gjson.GetBytes(gatewayJob.EventPayload, "receivedAt").String())
+		require.JSONEq(t, `[{"messageId":"1"},{"messageId":"2"}]`, gjson.GetBytes(gatewayJob.EventPayload, "batch").Raw)
+
+		result, err = handle.Replay(context.Background(), RequestT{SourceID: "source", DestinationID: "destination", From: from, Table: ProcErrorTable, Target: RouterTable})
+		require.NoError(t, err)
+		require.Equal(t, ResultT{Selected: 2, Replayed: 1, Skipped: 1}, result)
+		require.Len(t, routerDB.stored, 1)
+		require.Equal(t, routerAborted.EventPayload, routerDB.stored[0].EventPayload)
+		require.JSONEq(t, `{"source_id":"source","destination_id":"destination"}`, string(routerDB.stored[0].Parameters), "parameters of the failure are removed")
+	})
+}
+
+func Test_ReplayJobs(t *testing.T) {
+	initReplay()
+	routerDB := &fakeJobsDBT{jobs: []*jobsdb.JobT{newTestJob(`{"source_id":"source","destination_id":"destination"}`, `{}`)}}
+	handle := New(nil, routerDB, nil, nil)
+	request := RequestT{SourceID: "source", DestinationID: "destination", From: time.Now().Add(-time.Hour), Table: RouterTable, Target: RouterTable}
+
+	waitFinished := func(id string) JobStatusT {
+		var status JobStatusT
+		require.Eventually(t, func() bool {
+			var err error
+			status, err = handle.Status(id)
+			require.NoError(t, err)
+			return status.State != JobRunning
+		}, time.Second, time.Millisecond)
+		return status
+	}
+
+	t.Run("replays run in the background", func(t *testing.T) {
+		status, err := handle.Start(request)
+		require.NoError(t, err)
+		require.NotEmpty(t, status.ID)
+
+		status = waitFinished(status.ID)
+		require.Equal(t, JobSucceeded, status.State)
+		require.Equal(t, ResultT{Selected: 1, Replayed: 1}, status.Result)
+		require.False(t, status.FinishedAt.IsZero())
+		require.Len(t, handle.List(), 1)
+		require.Error(t, handle.Cancel(status.ID), "finished jobs can't be cancelled")
+	})
+
+	t.Run("invalid requests are rejected before starting", func(t *testing.T) {
+		invalid := request
+		invalid.DestinationID = ""
+		_, err := handle.Start(invalid)
+		require.Error(t, err)
+		_, err = handle.Status("unknown")
+		require.Equal(t, ErrJobNotFound, err)
+	})
+
+	t.Run("running replays can be cancelled", func(t *testing.T) {
+		blockedDB := &blockingJobsDBT{fakeJobsDBT: routerDB, started: make(chan struct{})}
+		handle.jobsDBs[RouterTable] = blockedDB
+		defer func() { handle.jobsDBs[RouterTable] = routerDB }()
+
+		status, err := handle.Start(request)
+		require.NoError(t, err)
+		<-blockedDB.started
+		require.NoError(t, handle.Cancel(status.ID))
+		require.Equal(t, JobCancelled, waitFinished(status.ID).State)
+	})
+
+	t.Run("only the last finished jobs are kept", func(t *testing.T) {
+		maxFinishedJobs = 1
+		defer func() { maxFinishedJobs = 100 }()
+		first, err := handle.Start(request)
+		require.NoError(t, err)
+		waitFinished(first.ID)
+		last, err := handle.Start(request)
+		require.NoError(t, err)
+		waitFinished(last.ID)
+		status, err := handle.Start(request)
+		require.NoError(t, err)
+		waitFinished(status.ID)
+		_, err = handle.Status(first.ID)
+		require.Equal(t, ErrJobNotFound, err)
+		_, err = handle.Status(last.ID)
+		require.NoError(t, err)
+	})
+}
+
+// blockingJobsDBT blocks selecting jobs until the replay is cancelled
+type blockingJobsDBT struct {
+	*fakeJobsDBT
+	started chan struct{}
+}
+
+func (jd *blockingJobsDBT) SelectJobs(ctx context.Context, _ jobsdb.RestoreOptsT, _ func(jobs []*jobsdb.JobT) error) (int, error) {
+	close(jd.started)
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func Test_HTTPHandler(t *testing.T) {
+	initReplay()
+	routerDB := &fakeJobsDBT{jobs: []*jobsdb.JobT{newTestJob(`{"source_id":"source","destination_id":"destination"}`, `{}`)}}
+	handle := New(nil, routerDB, nil, nil)
+	srv := httptest.NewServer(handle)
+	defer srv.Close()
+
+	request := func(method, path, body string) (*http.Response, JobStatusT) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var status JobStatusT
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusAccepted {
+			_ = json.NewDecoder(resp.Body).Decode(&status)
+		}
+		return resp, status
+	}
+
+	from := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	resp, status := request(http.MethodPost, "/v1/replay", `{"sourceId":"source","destinationId":"destination","from":"`+from+`","table":"rt","target":"rt"}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, "replays run in the background")
+	require.NotEmpty(t, status.ID)
+
+	require.Eventually(t, func() bool {
+		resp, status = request(http.MethodGet, "/v1/replay/"+status.ID, "")
+		return resp.StatusCode == http.StatusOK && status.State == JobSucceeded
+	}, time.Second, time.Millisecond)
+	require.Equal(t, ResultT{Selected: 1, Replayed: 1}, status.Result)
+
+	resp, _ = request(http.MethodGet, "/v1/replay", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = request(http.MethodDelete, "/v1/replay/"+status.ID, "")
+	require.Equal(t, http.StatusConflict, resp.StatusCode, "finished jobs can't be cancelled")
+	resp, _ = request(http.MethodGet, "/v1/replay/unknown", "")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = request(http.MethodPost, "/v1/replay", `{"sourceId":"source","from":"`+from+`","table":"rt","target":"rt"}`)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode, "invalid requests are rejected before starting")
+}
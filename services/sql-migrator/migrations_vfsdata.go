@@ -23,6 +23,38 @@ var MigrationAssets = func() http.FileSystem {
 			name:    "/",
 			modTime: time.Date(2021, 12, 9, 14, 21, 9, 256036373, time.UTC),
 		},
+		"/dlq": &vfsgen۰DirInfo{
+			name:    "dlq",
+			modTime: time.Date(2026, 10, 18, 8, 7, 48, 8525970, time.UTC),
+		},
+		"/dlq/000001_create_dlq_tables.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "000001_create_dlq_tables.down.sql",
+			modTime:          time.Date(2026, 10, 18, 8, 7, 50, 165291702, time.UTC),
+			uncompressedSize: 94,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xd2\xd5\xd5\xe5\xd2\xd5\xd5\x55\x70\x49\x4d\x4c\xd1\xcd\x49\x2d\x29\x49\x2d\x52\x28\x2c\x4d\x2d\x4d\x05\x89\x72\x71\xb9\x04\xf9\x07\x28\x84\x38\x3a\xf9\xb8\x2a\x78\xba\x29\xb8\x46\x78\x06\x87\x04\x2b\xa4\xe4\x14\xc6\x27\x96\xa6\x64\x96\x58\xe3\x96\xcf\xca\x4f\x2a\xb6\xe6\x02\x0c\x00\x11\x69\xf6\x8d\x5e\x00\x00\x00"),
+		},
+		"/dlq/000001_create_dlq_tables.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "000001_create_dlq_tables.up.sql",
+			modTime:          time.Date(2026, 10, 18, 8, 7, 48, 8525970, time.UTC),
+			uncompressedSize: 1428,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa4\x94\x41\x73\x9b\x30\x10\x85\xcf\xf0\x2b\xf6\x16\x33\x03\x87\xce\x74\x7a\xc9\x09\xc7\x8a\xa3\x16\x8b\x14\x44\xe3\xb4\xd3\x61\x14\xb4\xe9\x28\xa1\x88\x08\xd1\x36\xff\xbe\x03\x71\xa9\x99\x1a\xe3\xb6\x47\x78\x1f\xda\xe5\xed\x5b\x05\x41\xe0\x06\x41\x00\x2b\x14\x32\x28\xd1\x5a\x34\xf0\xd4\x62\x8b\xdd\x5b\xd7\xbd\x48\x48\xc8\x09\xf0\x70\x19\x11\xa0\x97\xc0\x62\x0e\x64\x4b\x53\x9e\x82\x2c\x9f\xf2\x07\x7d\xd7\xc0\xc2\x75\x1c\x25\x61\x49\xd7\x29\x49\x68\x18\xc1\x75\x42\x37\x61\x72\x0b\xef\xc8\xad\xef\x3a\xce\x83\xbe\xcb\xdb\x56\x49\xc8\x32\xba\xea\x4f\x60\x59\x14\x41\xc6\xe8\xfb\x8c\xfc\x02\x5e\x0e\xa0\x8c\x0f\x40\xa7\x3c\xaa\x4a\xc2\x87\x30\xb9\xb8\x0a\x93\xc5\x9b\xd7\xde\x48\x6c\xac\xf8\x82\x07\x55\x58\x91\xcb\x30\x8b\x38\x9c\x9d\x75\xe0\x77\x6d\x1e\x9b\x5a\x14\xd8\x55\xe1\x64\xcb\xa7\xc0\x46\xb7\x66\x9e\x92\xd8\x58\x55\x09\xab\x74\xf5\x37\xa8\x7d\xae\xf1\x28\x8c\xc6\x68\x93\x17\x5a\x9e\x80\x1d\x25\xda\x06\xcd\x5c\x67\xb5\x30\xe2\x2b\x5a\x34\x0d\xbc\x4d\x63\xb6\x1c\x39\x8b\xdf\xb0\xb2\x79\x2d\x9e\x4b\x2d\xe4\xa4\x5e\xe8\xb6\xb2\x40\x19\x27\x6b\x92\xfc\x59\xe7\xd5\x6e\x46\x16\x27\x27\x78\x2f\x54\x89\x32\x17\x16\x38\xdd\x90\x94\x87\x9b\x6b\xb8\xa1\xfc\xaa\x7f\x84\x8f\x31\x23\x23\xbc\x30\x28\xec\x89\xfc\xd0\x06\x8b\x6f\x16\x5e\xef\x4a\x2d\xff\xf1\x6b\xd7\x71\xbc\xf3\x61\x17\x28\x5b\x91\xed\xc4\x2e\xe4\xf7\xaa\xb4\x9d\xf9\x95\xc4\x1f\x10\xb3\xbd\x25\xd9\x0f\xa1\x0f\x43\xd2\x7c\x18\xc7\xc9\x87\xdf\x31\xf0\x61\x30\xe8\xd4\xfa\xbd\xdf\x87\xca\xf7\x82\x0f\x4a\x7a\xe7\xb3\x5b\x2d\x5a\xa9\xec\xec\x5a\x8b\xa2\x6b\x79\x72\xb6\xa2\xb0\x33\x31\x35\x28\x1a\x5d\x1d\x45\x5e\xec\x3c\x90\xc0\xdd\x8f\xe5\x4a\x36\xbb\x7b\xe3\xd3\xe7\x11\xd0\x89\x87\x03\xfa\x7f\x49\x3a\x2d\x0b\xbd\x83\xf9\x5e\x93\xe3\x91\xf4\x32\x64\x29\x65\x6b\x58\x53\x06\x8b\x3d\xd2\x3b\x77\x7f\x0e\x00\x02\xed\x61\x2f\x94\x05\x00\x00"),
+		},
+		"/dlq/000002_add_dlq_retention_indexes.down.sql": &vfsgen۰CompressedFileInfo{
+			name:             "000002_add_dlq_retention_indexes.down.sql",
+			modTime:          time.Date(2026, 10, 18, 9, 27, 41, 258558269, time.UTC),
+			uncompressedSize: 159,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x74\xcd\x31\x0e\xc2\x30\x0c\x85\xe1\x3d\xa7\xf0\x05\x7c\x02\xd6\x16\x29\x0b\x20\xca\xd0\xcd\x32\xf8\x55\x04\x45\x89\x9a\x3a\x12\xc7\x47\x1d\x18\x18\x58\x9f\xde\xa7\x9f\x99\x03\x33\x53\x2c\x86\x37\x36\xaa\x0b\xf9\x13\xd4\xe0\x28\x9e\x6a\xf9\x0e\x06\x35\xce\x70\x47\xa3\xb5\xa3\x63\x57\x21\x0c\xd7\xf3\x85\xe2\x69\x18\x67\x8a\x47\x1a\xe7\x38\xdd\x26\xb2\xbc\x8a\x76\x4b\x2e\x8f\x06\x75\x98\xa8\x4b\xda\x03\x87\xff\xe0\x55\xef\x9b\x2c\x9a\xf2\xcf\xfd\x33\x00\x9f\xcf\xd5\x5d\x9f\x00\x00\x00"),
+		},
+		"/dlq/000002_add_dlq_retention_indexes.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "000002_add_dlq_retention_indexes.up.sql",
+			modTime:          time.Date(2026, 10, 18, 9, 27, 41, 256788167, time.UTC),
+			uncompressedSize: 222,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x84\xcd\xc1\x0a\x82\x40\x14\x85\xe1\xfd\x3c\xc5\x59\xea\xe2\x3e\x81\xab\xa8\x09\x66\xa3\x90\x2e\xdc\x0d\x53\x73\xa4\x09\x51\xd4\x2b\xf4\xf8\x61\x90\x6d\x82\xb6\xf7\xe3\xfe\x47\x44\x8c\x88\xc0\x0d\x91\x4f\x2e\x18\x3b\xe8\x9d\x98\xa9\x1c\x34\x8d\xc3\xe7\x10\x19\xa2\xf4\x54\xe5\x8c\x69\xe5\xca\xed\xcb\x98\xe3\xc5\x1e\x1a\x0b\x57\x9e\x6c\x0b\x77\x46\x59\x35\xb0\xad\xab\x9b\x1a\xb1\x9f\xfc\x63\xbc\x2e\xbe\x0b\xa9\x67\xf4\x41\x7d\xda\x46\x50\x95\xbb\x21\xdb\x31\x2f\xfe\xd6\xc2\x1a\x93\xfa\xdb\xcc\xa0\x3f\x7a\x6f\x45\xf6\xe5\xbc\x30\xaf\x01\x00\xc3\xac\x9b\x2a\xde\x00\x00\x00"),
+		},
 		"/jobsdb": &vfsgen۰DirInfo{
 			name:    "jobsdb",
 			modTime: time.Date(2022, 1, 21, 8, 44, 37, 650807635, time.UTC),
@@ -104,6 +136,16 @@ var MigrationAssets = func() http.FileSystem {
 
 			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x34\x8e\xc1\x0a\x82\x40\x18\x84\xef\x3e\xc5\x20\x1d\x0a\xc2\x17\xe8\x14\xb9\x81\x17\x8d\xf4\xe0\x6d\xd9\xf4\x37\x2c\xd3\xda\xdd\x2c\xf8\xf9\xdf\x3d\x4a\x9b\xcb\x0c\x7c\xc3\x30\xcc\xd6\xf4\x67\x42\x14\x1b\x6f\x1c\x79\x27\x12\x00\x00\x33\xda\x06\xf4\xc0\x22\x3a\x58\x6a\xda\x37\x42\xeb\x43\x88\xe0\x87\xbf\xda\x1d\xd5\xb6\x50\x48\xd2\x58\x95\x48\xf6\x48\xb3\x02\xaa\x4c\xf2\x22\x47\xf5\x74\x7e\xb8\x8d\xa6\xd3\x53\x22\xab\x99\x23\x11\x64\x29\x98\xff\x93\x22\xfa\x32\x9c\xdc\x8c\x96\x53\x55\x8f\xa6\x5b\xbf\x06\x7b\x75\x77\x53\x51\x5b\xaf\x36\xf3\x1f\xea\x6b\x91\x60\xf6\x4f\x00\x00\x00\xff\xff\x25\xf9\xe2\x9b\xb7\x00\x00\x00"),
 		},
+		"/jobsdb/000008_add_retention_column.down.tmpl": &vfsgen۰FileInfo{
+			name:    "000008_add_retention_column.down.tmpl",
+			modTime: time.Date(2026, 10, 18, 9, 33, 45, 599758335, time.UTC),
+			content: []byte("\x7b\x7b\x72\x61\x6e\x67\x65\x20\x2e\x44\x61\x74\x61\x73\x65\x74\x73\x7d\x7d\x0a\x20\x20\x20\x20\x41\x4c\x54\x45\x52\x20\x54\x41\x42\x4c\x45\x20\x7b\x7b\x24\x2e\x50\x72\x65\x66\x69\x78\x7d\x7d\x5f\x6a\x6f\x62\x73\x5f\x7b\x7b\x2e\x7d\x7d\x20\x44\x52\x4f\x50\x20\x43\x4f\x4c\x55\x4d\x4e\x20\x49\x46\x20\x45\x58\x49\x53\x54\x53\x20\x72\x65\x74\x65\x6e\x74\x69\x6f\x6e\x3b\x0a\x7b\x7b\x65\x6e\x64\x7d\x7d\x0a"),
+		},
+		"/jobsdb/000008_add_retention_column.up.tmpl": &vfsgen۰FileInfo{
+			name:    "000008_add_retention_column.up.tmpl",
+			modTime: time.Date(2026, 10, 18, 9, 33, 45, 598542421, time.UTC),
+			content: []byte("\x7b\x7b\x72\x61\x6e\x67\x65\x20\x2e\x44\x61\x74\x61\x73\x65\x74\x73\x7d\x7d\x0a\x20\x20\x20\x20\x41\x4c\x54\x45\x52\x20\x54\x41\x42\x4c\x45\x20\x7b\x7b\x24\x2e\x50\x72\x65\x66\x69\x78\x7d\x7d\x5f\x6a\x6f\x62\x73\x5f\x7b\x7b\x2e\x7d\x7d\x20\x41\x44\x44\x20\x43\x4f\x4c\x55\x4d\x4e\x20\x49\x46\x20\x4e\x4f\x54\x20\x45\x58\x49\x53\x54\x53\x20\x72\x65\x74\x65\x6e\x74\x69\x6f\x6e\x20\x49\x4e\x54\x45\x52\x56\x41\x4c\x3b\x0a\x7b\x7b\x65\x6e\x64\x7d\x7d\x0a"),
+		},
 		"/node": &vfsgen۰DirInfo{
 			name:    "node",
 			modTime: time.Date(2021, 10, 29, 10, 50, 52, 396404742, time.UTC),
@@ -337,14 +379,28 @@ var MigrationAssets = func() http.FileSystem {
 
 			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x94\x8f\x41\xca\x83\x30\x10\x46\xf7\x39\xc5\xec\xfc\x05\x73\x02\x57\x3f\x35\x05\x37\x5a\xd4\x85\xbb\x21\x98\xa1\x0d\xb4\x49\x30\x23\x6d\xc1\xc3\x17\x29\xb4\xda\x55\xbb\x1a\x18\x1e\xef\xe3\x09\x29\x85\x94\x70\x3d\xe1\x14\xce\x5e\x9b\xb8\x3c\xc4\xae\x51\xff\x9d\x82\xb2\x2a\x54\x0f\xe5\x1e\xaa\xba\x03\xd5\x97\x6d\xd7\xae\x48\x34\x14\xd9\x3a\xcd\xd6\x3b\xe4\x7b\x20\xb4\x0e\xc3\xe8\x8f\x23\xc5\x88\x91\x35\x4f\x5b\xc6\x1a\x74\xfa\x42\x31\xe8\x81\x70\xf0\x6e\xd0\x4c\x4e\x33\x19\xb4\xce\xd0\x0d\xea\x6a\x65\x87\xbf\x4f\x7d\x06\x2b\x7f\x06\xcf\x81\x6c\xcb\x59\x03\xf3\x0c\x09\x26\xcb\x79\x8d\xa5\x69\x2e\x44\xd1\xd4\x87\x77\xd1\x17\x35\xbf\x17\xe4\xe2\x11\x00\x00\xff\xff\x14\xb0\xfa\x98\x4f\x01\x00\x00"),
 		},
+		"/warehouse/000015_create_wh_upload_audit_events.up.sql": &vfsgen۰CompressedFileInfo{
+			name:             "000015_create_wh_upload_audit_events.up.sql",
+			modTime:          time.Date(2026, 10, 18, 8, 57, 31, 942468946, time.UTC),
+			uncompressedSize: 466,
+
+			compressedContent: []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8c\x90\xc1\x4a\x33\x31\x14\x85\xf7\x79\x8a\xbb\xec\xc0\x9f\xdd\x8f\x9b\xae\xd2\x1a\x35\x38\x93\x96\x4c\x94\xe9\x2a\xdc\x36\x17\x0c\xd4\x4c\x9d\x24\x55\xdf\x5e\xec\x40\x1d\x84\xaa\xdb\x73\xbe\x73\x16\x1f\xe7\x8c\x73\x78\x7d\x72\xe5\xb0\xef\xd1\x3b\x2c\x3e\x64\x47\x47\x8a\x39\x31\xce\x19\x5b\x1a\x29\xac\x04\x2b\x16\xb5\x04\x75\x03\x7a\x65\x41\x76\xaa\xb5\xed\x85\x15\xcc\x18\x00\x40\xf0\xb0\x50\xb7\xad\x34\x4a\xd4\xb0\x36\xaa\x11\x66\x03\xf7\x72\xf3\xef\xd4\x7e\x4d\x47\x4e\x69\x7b\x7a\xd6\x0f\x75\x3d\x12\xb8\xcb\xa1\x8f\xf0\x28\xcc\xf2\x4e\x98\xd9\xd5\xff\xea\x1b\x90\x71\xbb\x27\x17\xf1\x99\xc0\xca\xce\x8e\xe1\x61\xa0\x63\xe8\x4b\x72\x29\x63\x2e\xe9\x87\xf9\xaf\xc0\x40\x98\xfa\x38\xf9\x1e\xe8\xa5\x50\xca\xe4\xdd\xf6\x7d\x12\xef\x06\xc2\xcf\x10\x33\x58\xd5\xc8\xd6\x8a\x66\x7d\xbe\xaa\xe6\x67\x83\x4a\x5f\xcb\xee\x4f\x06\xdd\xd4\x8e\x0b\xd1\xd3\x1b\xac\xf4\x45\xdd\x53\xba\x9a\xb3\x8f\x01\x00\xf2\x44\x92\xb3\xd2\x01\x00\x00"),
+		},
 	}
 	fs["/"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
+		fs["/dlq"].(os.FileInfo),
 		fs["/jobsdb"].(os.FileInfo),
 		fs["/node"].(os.FileInfo),
 		fs["/pg_notifier_queue"].(os.FileInfo),
 		fs["/reports"].(os.FileInfo),
 		fs["/warehouse"].(os.FileInfo),
 	}
+	fs["/dlq"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
+		fs["/dlq/000001_create_dlq_tables.down.sql"].(os.FileInfo),
+		fs["/dlq/000001_create_dlq_tables.up.sql"].(os.FileInfo),
+		fs["/dlq/000002_add_dlq_retention_indexes.down.sql"].(os.FileInfo),
+		fs["/dlq/000002_add_dlq_retention_indexes.up.sql"].(os.FileInfo),
+	}
 	fs["/jobsdb"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
 		fs["/jobsdb/000001_create_tables.down.tmpl"].(os.FileInfo),
 		fs["/jobsdb/000001_create_tables.up.tmpl"].(os.FileInfo),
@@ -359,6 +415,8 @@ var MigrationAssets = func() http.FileSystem {
 		fs["/jobsdb/000006_alter_dataset_table.down.tmpl"].(os.FileInfo),
 		fs["/jobsdb/000006_alter_dataset_table.up.tmpl"].(os.FileInfo),
 		fs["/jobsdb/000007_add_index_rt_table.up.tmpl"].(os.FileInfo),
+		fs["/jobsdb/000008_add_retention_column.down.tmpl"].(os.FileInfo),
+		fs["/jobsdb/000008_add_retention_column.up.tmpl"].(os.FileInfo),
 	}
 	fs["/node"].(*vfsgen۰DirInfo).entries = []os.FileInfo{
 		fs["/node/000001_create_event_schema.down.sql"].(os.FileInfo),
@@ -400,6 +458,7 @@ var MigrationAssets = func() http.FileSystem {
 		fs["/warehouse/000012_add_mergedSchema_to_wh_uploads.up.sql"].(os.FileInfo),
 		fs["/warehouse/000013_add_in_progress_to_wh_uploads.up.sql"].(os.FileInfo),
 		fs["/warehouse/000014_add_and_drop_wh_uploads_index_for_in_progress.up.sql"].(os.FileInfo),
+		fs["/warehouse/000015_create_wh_upload_audit_events.up.sql"].(os.FileInfo),
 	}
 
 	return fs
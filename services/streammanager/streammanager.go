@@ -3,6 +3,8 @@ package streammanager
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/rudderlabs/rudder-server/services/streammanager/bqstream"
@@ -19,105 +21,151 @@ type Opts struct {
 	Timeout time.Duration
 }
 
-// NewProducer delegates the call to the appropriate based on parameter destination for creating producer
-func NewProducer(destinationConfig interface{}, destType string, o Opts) (interface{}, error) {
+// Producer sends events to a stream destination
+type Producer interface {
+	// Produce sends an event to the destination and returns the status code, status and response of the destination
+	Produce(jsonData json.RawMessage, destConfig interface{}) (int, string, string)
+	// Close releases the resources of the producer
+	Close() error
+}
+
+// HealthChecker is implemented by producers that can check whether their destination is reachable
+type HealthChecker interface {
+	HealthCheck() error
+}
+
+// Factory creates a producer for the config of a destination
+type Factory func(destinationConfig interface{}, o Opts) (Producer, error)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = map[string]Factory{}
+)
+
+// Register makes a factory of producers available for a destination type.
+// It panics if the destination type is already registered, or the factory is nil.
+func Register(destType string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	if factory == nil {
+		panic(fmt.Sprintf("streammanager: Register factory of %s is nil", destType))
+	}
+	if _, ok := factories[destType]; ok {
+		panic(fmt.Sprintf("streammanager: Register called twice for %s", destType))
+	}
+	factories[destType] = factory
+}
+
+// IsRegistered returns true if a factory of producers is registered for the destination type
+func IsRegistered(destType string) bool {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	_, ok := factories[destType]
+	return ok
+}
 
-	switch destType {
-	case "AZURE_EVENT_HUB":
+// RegisteredDestinations returns the sorted destination types with a registered factory of producers
+func RegisteredDestinations() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+	destTypes := make([]string, 0, len(factories))
+	for destType := range factories {
+		destTypes = append(destTypes, destType)
+	}
+	sort.Strings(destTypes)
+	return destTypes
+}
+
+// NewProducer creates a producer for the destination with the factory registered for its type
+func NewProducer(destinationConfig interface{}, destType string, o Opts) (Producer, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[destType]
+	factoriesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("No provider configured for StreamManager") //404, "No provider configured for StreamManager", ""
+	}
+	return factory(destinationConfig, o)
+}
+
+// HealthCheck checks the destination of the producer, if the producer supports health checks
+func HealthCheck(producer Producer) error {
+	if checker, ok := producer.(HealthChecker); ok {
+		return checker.HealthCheck()
+	}
+	return nil
+}
+
+// clientProducerT adapts the client and the package level functions of the built-in stream destinations to Producer
+type clientProducerT struct {
+	client  interface{}
+	produce func(jsonData json.RawMessage, producer interface{}, destConfig interface{}) (int, string, string)
+	close   func(producer interface{}) error
+}
+
+func (producer *clientProducerT) Produce(jsonData json.RawMessage, destConfig interface{}) (int, string, string) {
+	return producer.produce(jsonData, producer.client, destConfig)
+}
+
+func (producer *clientProducerT) Close() error {
+	if producer.close == nil {
+		return nil
+	}
+	return producer.close(producer.client)
+}
+
+func init() {
+	Register("AZURE_EVENT_HUB", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := kafka.NewProducerForAzureEventHub(destinationConfig, kafka.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "CONFLUENT_CLOUD":
+		return &clientProducerT{client: producer, produce: kafka.Produce, close: kafka.CloseProducer}, err
+	})
+	Register("CONFLUENT_CLOUD", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := kafka.NewProducerForConfluentCloud(destinationConfig, kafka.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "EVENTBRIDGE":
+		return &clientProducerT{client: producer, produce: kafka.Produce, close: kafka.CloseProducer}, err
+	})
+	Register("EVENTBRIDGE", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := eventbridge.NewProducer(destinationConfig, eventbridge.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "FIREHOSE":
+		return &clientProducerT{client: producer, produce: eventbridge.Produce}, err
+	})
+	Register("FIREHOSE", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := firehose.NewProducer(destinationConfig, firehose.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "KAFKA":
+		return &clientProducerT{client: producer, produce: firehose.Produce}, err
+	})
+	Register("KAFKA", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := kafka.NewProducer(destinationConfig, kafka.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "KINESIS":
+		return &clientProducerT{client: producer, produce: kafka.Produce, close: kafka.CloseProducer}, err
+	})
+	Register("KINESIS", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := kinesis.NewProducer(destinationConfig, kinesis.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "GOOGLEPUBSUB":
+		return &clientProducerT{client: producer, produce: kinesis.Produce}, err
+	})
+	Register("GOOGLEPUBSUB", func(destinationConfig interface{}, _ Opts) (Producer, error) {
 		producer, err := googlepubsub.NewProducer(destinationConfig)
-		return producer, err
-	case "GOOGLESHEETS":
+		return &clientProducerT{client: producer, produce: googlepubsub.Produce, close: googlepubsub.CloseProducer}, err
+	})
+	Register("GOOGLESHEETS", func(destinationConfig interface{}, _ Opts) (Producer, error) {
 		producer, err := googlesheets.NewProducer(destinationConfig)
-		return producer, err
-	case "PERSONALIZE":
+		return &clientProducerT{client: producer, produce: googlesheets.Produce}, err
+	})
+	Register("PERSONALIZE", func(destinationConfig interface{}, o Opts) (Producer, error) {
 		producer, err := personalize.NewProducer(destinationConfig, personalize.Opts{
 			Timeout: o.Timeout,
 		})
-		return producer, err
-	case "BQSTREAM":
+		return &clientProducerT{client: producer, produce: personalize.Produce}, err
+	})
+	Register("BQSTREAM", func(destinationConfig interface{}, _ Opts) (Producer, error) {
 		producer, err := bqstream.NewProducer(destinationConfig)
-		return producer, err
-	default:
-		return nil, fmt.Errorf("No provider configured for StreamManager") //404, "No provider configured for StreamManager", ""
-	}
-
-}
-
-// CloseProducer delegates the call to the appropriate manager based on parameter destination to close a given producer
-func CloseProducer(producer interface{}, destType string) error {
-
-	switch destType {
-	case "KINESIS", "FIREHOSE", "EVENTBRIDGE", "PERSONALIZE", "GOOGLESHEETS":
-		return nil
-	case "BQSTREAM":
-		return bqstream.CloseProducer(producer)
-	case "KAFKA", "AZURE_EVENT_HUB", "CONFLUENT_CLOUD":
-		err := kafka.CloseProducer(producer)
-		return err
-	case "GOOGLEPUBSUB":
-		err := googlepubsub.CloseProducer(producer)
-		return err
-	default:
-		return fmt.Errorf("No provider configured for StreamManager") //404, "No provider configured for StreamManager", ""
-	}
-
-}
-
-type StreamProducer interface {
-	Produce(jsonData json.RawMessage, producer interface{}, destConfig interface{}) (int, string, string)
-}
-
-// Produce delegates call to appropriate manager based on parameter destination
-func Produce(jsonData json.RawMessage, destType string, producer interface{}, config interface{}) (int, string, string) {
-	switch destType {
-	case "KINESIS":
-		return kinesis.Produce(jsonData, producer, config)
-	case "KAFKA", "AZURE_EVENT_HUB", "CONFLUENT_CLOUD":
-		return kafka.Produce(jsonData, producer, config)
-	case "FIREHOSE":
-		return firehose.Produce(jsonData, producer, config)
-	case "EVENTBRIDGE":
-		return eventbridge.Produce(jsonData, producer, config)
-	case "GOOGLEPUBSUB":
-		return googlepubsub.Produce(jsonData, producer, config)
-	case "GOOGLESHEETS":
-		return googlesheets.Produce(jsonData, producer, config)
-	case "PERSONALIZE":
-		return personalize.Produce(jsonData, producer, config)
-	case "BQSTREAM":
-		return bqstream.Produce(jsonData, producer, config)
-	default:
-		return 404, "No provider configured for StreamManager", "No provider configured for StreamManager"
-	}
-
+		return &clientProducerT{client: producer, produce: bqstream.Produce, close: bqstream.CloseProducer}, err
+	})
 }
@@ -0,0 +1,107 @@
+package streammanager_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/rudderlabs/rudder-server/services/streammanager"
+)
+
+type fakeProducerT struct {
+	healthErr error
+	produced  []json.RawMessage
+	closed    bool
+}
+
+func (producer *fakeProducerT) Produce(jsonData json.RawMessage, _ interface{}) (int, string, string) {
+	if producer.closed {
+		return 400, "Failure", "producer is closed"
+	}
+	producer.produced = append(producer.produced, jsonData)
+	return 200, "Success", "Message delivered"
+}
+
+func (producer *fakeProducerT) Close() error {
+	producer.closed = true
+	return nil
+}
+
+func (producer *fakeProducerT) HealthCheck() error {
+	return producer.healthErr
+}
+
+func init() {
+	streammanager.Register("FAKE_STREAM", func(destinationConfig interface{}, _ streammanager.Opts) (streammanager.Producer, error) {
+		if destinationConfig == nil {
+			return nil, errors.New("config is missing")
+		}
+		return &fakeProducerT{}, nil
+	})
+}
+
+// assertProducerContract asserts the behaviour every producer created by the factory of destType must have
+func assertProducerContract(destType string, destinationConfig interface{}, event json.RawMessage) {
+	It("should create producers for the destination type", func() {
+		Expect(streammanager.IsRegistered(destType)).To(BeTrue())
+		producer, err := streammanager.NewProducer(destinationConfig, destType, streammanager.Opts{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer).NotTo(BeNil())
+	})
+
+	It("should produce events and report their status", func() {
+		producer, err := streammanager.NewProducer(destinationConfig, destType, streammanager.Opts{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(streammanager.HealthCheck(producer)).To(Succeed())
+
+		statusCode, status, respBody := producer.Produce(event, destinationConfig)
+		Expect(statusCode).To(Equal(200))
+		Expect(status).NotTo(BeEmpty())
+		Expect(respBody).NotTo(BeEmpty())
+	})
+
+	It("should close producers", func() {
+		producer, err := streammanager.NewProducer(destinationConfig, destType, streammanager.Opts{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(producer.Close()).To(Succeed())
+	})
+}
+
+var _ = Describe("Streammanager", func() {
+	Context("Registry", func() {
+		It("should register the built-in stream destinations", func() {
+			for _, destType := range []string{"AZURE_EVENT_HUB", "BQSTREAM", "CONFLUENT_CLOUD", "EVENTBRIDGE", "FIREHOSE", "GOOGLEPUBSUB", "GOOGLESHEETS", "KAFKA", "KINESIS", "PERSONALIZE"} {
+				Expect(streammanager.IsRegistered(destType)).To(BeTrue(), destType)
+			}
+			Expect(streammanager.RegisteredDestinations()).To(ContainElement("FAKE_STREAM"))
+		})
+
+		It("should fail to create producers for unknown destination types", func() {
+			_, err := streammanager.NewProducer(map[string]interface{}{}, "UNKNOWN", streammanager.Opts{})
+			Expect(err).To(HaveOccurred())
+			Expect(streammanager.IsRegistered("UNKNOWN")).To(BeFalse())
+		})
+
+		It("should return the errors of factories", func() {
+			_, err := streammanager.NewProducer(nil, "FAKE_STREAM", streammanager.Opts{})
+			Expect(err).To(MatchError("config is missing"))
+		})
+
+		It("should not register a destination type twice", func() {
+			Expect(func() {
+				streammanager.Register("KAFKA", func(interface{}, streammanager.Opts) (streammanager.Producer, error) { return nil, nil })
+			}).To(Panic())
+			Expect(func() { streammanager.Register("NIL_STREAM", nil) }).To(Panic())
+		})
+
+		It("should only health check producers supporting it", func() {
+			Expect(streammanager.HealthCheck(&fakeProducerT{healthErr: errors.New("unreachable")})).To(MatchError("unreachable"))
+		})
+	})
+
+	Context("Producer contract", func() {
+		assertProducerContract("FAKE_STREAM", map[string]interface{}{}, json.RawMessage(`{"message": {"event": "test"}}`))
+	})
+})
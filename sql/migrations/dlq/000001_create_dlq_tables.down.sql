@@ -0,0 +1,6 @@
+---
+--- Dead-letter queue
+---
+
+DROP TABLE IF EXISTS dlq_audit;
+DROP TABLE IF EXISTS dlq_jobs;
@@ -0,0 +1,42 @@
+---
+--- Dead-letter queue
+---
+
+CREATE TABLE IF NOT EXISTS dlq_jobs (
+		id BIGSERIAL PRIMARY KEY,
+		job_uuid UUID NOT NULL UNIQUE,
+		job_id BIGINT NOT NULL,
+		kind VARCHAR(64) NOT NULL,
+		stage VARCHAR(64) NOT NULL DEFAULT '',
+		workspace_id TEXT NOT NULL DEFAULT '',
+		source_id TEXT NOT NULL DEFAULT '',
+		destination_id TEXT NOT NULL DEFAULT '',
+		destination_type TEXT NOT NULL DEFAULT '',
+		error_code TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL DEFAULT '',
+		parameters JSONB NOT NULL,
+		event_payload JSONB NOT NULL,
+		event_count INTEGER NOT NULL DEFAULT 1,
+		state VARCHAR(64) NOT NULL,
+		failed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+
+CREATE INDEX IF NOT EXISTS dlq_jobs_filter_index ON dlq_jobs (workspace_id, source_id, destination_id, error_code, failed_at);
+
+CREATE INDEX IF NOT EXISTS dlq_jobs_state_index ON dlq_jobs (state, id);
+
+CREATE TABLE IF NOT EXISTS dlq_audit (
+		id BIGSERIAL PRIMARY KEY,
+		action VARCHAR(64) NOT NULL,
+		actor TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT '',
+		filter JSONB NOT NULL,
+		dlq_job_ids BIGINT[] NOT NULL,
+		job_count INTEGER NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		);
+
+CREATE INDEX IF NOT EXISTS dlq_audit_dlq_job_ids_index ON dlq_audit USING GIN (dlq_job_ids);
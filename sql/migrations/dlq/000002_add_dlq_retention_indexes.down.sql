@@ -0,0 +1,6 @@
+---
+--- Indexes of the retention of the dead-letter queue
+---
+
+DROP INDEX IF EXISTS dlq_audit_created_at_index;
+DROP INDEX IF EXISTS dlq_jobs_failed_at_index;
@@ -0,0 +1,7 @@
+---
+--- Indexes of the retention of the dead-letter queue
+---
+
+CREATE INDEX IF NOT EXISTS dlq_jobs_failed_at_index ON dlq_jobs (failed_at);
+
+CREATE INDEX IF NOT EXISTS dlq_audit_created_at_index ON dlq_audit (created_at);
@@ -0,0 +1,16 @@
+--
+-- wh_upload_audit_events
+--
+
+CREATE TABLE IF NOT EXISTS wh_upload_audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    wh_upload_id BIGINT NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    table_name TEXT,
+    previous_status VARCHAR(64) NOT NULL,
+    status VARCHAR(64) NOT NULL,
+    reason TEXT,
+    requested_by TEXT,
+    created_at TIMESTAMP NOT NULL);
+
+CREATE INDEX IF NOT EXISTS wh_upload_audit_events_wh_upload_id_index ON wh_upload_audit_events (wh_upload_id);
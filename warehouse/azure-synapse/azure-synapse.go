@@ -254,6 +254,11 @@ func (as *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 			extraColumns = append(extraColumns, column)
 		}
 	}
+	mergeKeys, err := warehouseutils.GetMergeKeys(as.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	fileNames, err := as.DownloadLoadFiles(tableName)
 	defer misc.RemoveFilePaths(fileNames...)
 	if err != nil {
@@ -453,6 +458,14 @@ func (as *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	if tableName == warehouseutils.DiscardsTable {
 		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, as.Namespace, tableName, "table_name", "column_name")
 	}
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKey = mergeKeys[0]
+		partitionKey = strings.Join(mergeKeys, ", ")
+		for _, key := range mergeKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s"`, as.Namespace, tableName, key)
+		}
+	}
 	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, as.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
 	pkgLogger.Infof("AZ: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = txn.Exec(sqlStatement)
@@ -247,6 +247,10 @@ func (bq *HandleT) loadTable(tableName string, forceLoad bool, getLoadFileLocFro
 	} else {
 		loadFiles = bq.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
 	}
+	mergeKeys, err := warehouseutils.GetMergeKeys(bq.Warehouse, tableName, bq.Uploader.GetTableSchemaInUpload(tableName))
+	if err != nil {
+		return stagingLoadTable, err
+	}
 	gcsLocations := warehouseutils.GetGCSLocations(loadFiles, warehouseutils.GCSLocationOptionsT{})
 	pkgLogger.Infof("BQ: Loading data into table: %s in bigquery dataset: %s in project: %s from %v", tableName, bq.Namespace, bq.ProjectID, loadFiles)
 	gcsRef := bigquery.NewGCSReference(gcsLocations...)
@@ -330,6 +334,12 @@ func (bq *HandleT) loadTable(tableName string, forceLoad bool, getLoadFileLocFro
 			partitionKey = column
 		}
 
+		// in merge mode, rows are replaced by the rows with the same merge key
+		if len(mergeKeys) > 0 {
+			primaryKey = strings.Join(mergeKeys, ",")
+			partitionKey = strings.Join(mergeKeys, ", ")
+		}
+
 		tableColMap := bq.Uploader.GetTableSchemaInWarehouse(tableName)
 		var tableColNames []string
 		for colName := range tableColMap {
@@ -383,7 +393,7 @@ func (bq *HandleT) loadTable(tableName string, forceLoad bool, getLoadFileLocFro
 		return
 	}
 
-	if !isDedupEnabled {
+	if !bq.dedupEnabled() {
 		err = loadTableByAppend()
 		return
 	}
@@ -452,7 +462,7 @@ func (bq *HandleT) LoadUserTables() (errorMap map[string]error) {
 	bqIdentifiesTable := bqTable(warehouseutils.IdentifiesTable)
 	partition := fmt.Sprintf("TIMESTAMP('%s')", identifyLoadTable.partitionDate)
 	var identifiesFrom string
-	if isDedupEnabled {
+	if bq.dedupEnabled() {
 		identifiesFrom = fmt.Sprintf(`%s WHERE user_id IS NOT NULL %s`, bqTable(identifyLoadTable.stagingTableName), loadedAtFilter())
 	} else {
 		identifiesFrom = fmt.Sprintf(`%s WHERE _PARTITIONTIME = %s AND user_id IS NOT NULL %s`, bqIdentifiesTable, partition, loadedAtFilter())
@@ -572,7 +582,7 @@ func (bq *HandleT) LoadUserTables() (errorMap map[string]error) {
 		}
 	}
 
-	if !isDedupEnabled {
+	if !bq.dedupEnabled() {
 		loadUserTableByAppend()
 		return
 	}
@@ -612,6 +622,11 @@ func Init() {
 	pkgLogger = logger.NewLogger().Child("warehouse").Child("bigquery")
 }
 
+//dedupEnabled returns whether the tables are loaded by merging staging tables, which is the case for destinations in merge load mode
+func (bq *HandleT) dedupEnabled() bool {
+	return isDedupEnabled || warehouseutils.IsMergeLoadMode(bq.Warehouse)
+}
+
 func (bq *HandleT) removePartitionExpiry() (err error) {
 	partitionExpiryUpdatedLock.Lock()
 	defer partitionExpiryUpdatedLock.Unlock()
@@ -637,10 +652,10 @@ func (bq *HandleT) removePartitionExpiry() (err error) {
 }
 
 func (bq *HandleT) CrashRecover(warehouse warehouseutils.WarehouseT) (err error) {
-	if !isDedupEnabled {
+	bq.Warehouse = warehouse
+	if !bq.dedupEnabled() {
 		return
 	}
-	bq.Warehouse = warehouse
 	bq.Namespace = warehouse.Namespace
 	bq.ProjectID = strings.TrimSpace(warehouseutils.GetConfigValue(GCPProjectID, bq.Warehouse))
 	bq.Db, err = bq.connect(BQCredentialsT{
@@ -542,6 +542,8 @@ func (ch *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	}
 	defer misc.RemoveFilePaths(fileNames...)
 
+	ch.checkMergeKeys(tableName, tableSchemaInUpload)
+
 	operation := func() error {
 		tableError := ch.loadTablesFromFilesNamesWithRetry(tableName, tableSchemaInUpload, fileNames, chStats)
 		err = tableError.err
@@ -563,6 +565,30 @@ func (ch *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	return
 }
 
+// checkMergeKeys warns if the table isn't deduplicated on its merge keys in merge mode.
+// The sorting key of ReplacingMergeTree tables can't be changed, so tables created before the destination switched to merge mode
+// are still deduplicated on received_at and id, until they are recreated.
+func (ch *HandleT) checkMergeKeys(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT) {
+	mergeKeys, err := warehouseutils.GetMergeKeys(ch.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil || len(mergeKeys) == 0 {
+		return
+	}
+	var sortingKey string
+	sqlStatement := `SELECT sorting_key FROM system.tables WHERE database = ? AND name = ?`
+	if err = ch.Db.QueryRow(sqlStatement, ch.Namespace, tableName).Scan(&sortingKey); err != nil {
+		pkgLogger.Warnf("%s Failed to fetch the sorting key of the table: %v", ch.GetLogIdentifier(tableName), err)
+		return
+	}
+	if sortingKey != strings.Join(mergeKeys, ", ") {
+		pkgLogger.Warnf("%s Table is deduplicated on %s instead of the merge keys %v, since it was created before merge mode", ch.GetLogIdentifier(tableName), sortingKey, mergeKeys)
+		ch.stats.NewTaggedStat("warehouse.clickhouse.mergeKeysNotApplied", stats.CountType, map[string]string{
+			"destination": ch.Warehouse.Destination.ID,
+			"namespace":   ch.Warehouse.Namespace,
+			"tableName":   tableName,
+		}).Count(1)
+	}
+}
+
 type tableError struct {
 	enableRetry bool
 	err         error
@@ -807,14 +833,30 @@ func (ch *HandleT) CreateTable(tableName string, columns map[string]string) (err
 	if tableName == warehouseutils.UsersTable {
 		return ch.createUsersTable(tableName, columns)
 	}
+	// in merge mode, rows with the same merge key are replaced by the row received last when the parts are merged.
+	// ReplacingMergeTree only replaces rows asynchronously, within a partition, i.e. rows received on the same day,
+	// so queries need FINAL to exclude the duplicates not merged yet, and duplicates received on different days are kept.
+	// Tables created before the destination switched to merge mode keep their sorting key, see checkMergeKeys.
+	mergeKeys, err := warehouseutils.GetMergeKeys(ch.Warehouse, tableName, columns)
+	if err != nil {
+		return
+	}
+	versionColumn := ""
+	if len(mergeKeys) > 0 {
+		sortKeyFields = mergeKeys
+		versionColumn = "received_at"
+	}
 	clusterClause := ""
 	engine := "ReplacingMergeTree"
-	engineOptions := ""
+	engineOptions := versionColumn
 	cluster := warehouseutils.GetConfigValue(Cluster, ch.Warehouse)
 	if len(strings.TrimSpace(cluster)) > 0 {
 		clusterClause = fmt.Sprintf(`ON CLUSTER "%s"`, cluster)
 		engine = fmt.Sprintf(`%s%s`, "Replicated", engine)
 		engineOptions = `'/clickhouse/{cluster}/tables/{database}/{table}', '{replica}'`
+		if versionColumn != "" {
+			engineOptions = fmt.Sprintf(`%s, %s`, engineOptions, versionColumn)
+		}
 	}
 	sqlStatement = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s"."%s" %s ( %v )  ENGINE = %s(%s) ORDER BY %s PARTITION BY toDate(%s)`, ch.Namespace, tableName, clusterClause, ColumnsWithDataTypes(tableName, columns, sortKeyFields), engine, engineOptions, getSortKeyTuple(sortKeyFields), partitionField)
 
@@ -58,11 +58,21 @@ func (wh *HandleT) AlterColumn(tableName string, columnName string, columnType s
 }
 
 func (wh *HandleT) LoadTable(tableName string) error {
+	if loader, ok := wh.SchemaRepository.(schemarepository.TableLoader); ok {
+		return loader.LoadTable(tableName)
+	}
 	pkgLogger.Infof("Skipping load for table %s : %s is a datalake destination", tableName, wh.Warehouse.Destination.ID)
 	return nil
 }
 
 func (wh *HandleT) LoadUserTables() map[string]error {
+	if loader, ok := wh.SchemaRepository.(schemarepository.TableLoader); ok {
+		errorMap := map[string]error{warehouseutils.IdentifiesTable: loader.LoadTable(warehouseutils.IdentifiesTable)}
+		if len(wh.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable)) > 0 {
+			errorMap[warehouseutils.UsersTable] = loader.LoadTable(warehouseutils.UsersTable)
+		}
+		return errorMap
+	}
 	pkgLogger.Infof("Skipping load for user tables : %s is a datalake destination", wh.Warehouse.Destination.ID)
 	// return map with nil error entries for identifies and users(if any) tables
 	// this is so that they are marked as succeeded
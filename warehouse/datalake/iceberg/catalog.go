@@ -0,0 +1,169 @@
+package iceberg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrNoSuchTable is returned when loading a table which doesn't exist
+	ErrNoSuchTable = errors.New("iceberg: table does not exist")
+	//ErrTableExists is returned when creating a table which already exists
+	ErrTableExists = errors.New("iceberg: table already exists")
+	//ErrCommitFailed is returned when the table was changed since it was loaded, the changes have to be applied again on the new metadata
+	ErrCommitFailed = errors.New("iceberg: commit failed, table was changed concurrently")
+)
+
+//Table is the metadata of a table, as last loaded from or committed to its catalog
+type Table struct {
+	Namespace        string
+	Name             string
+	MetadataLocation string
+	Metadata         *TableMetadata
+}
+
+//Catalog keeps the current metadata of the tables, and swaps it atomically on commits
+type Catalog interface {
+	CreateNamespace(ctx context.Context, namespace string) error
+	LoadTable(ctx context.Context, namespace string, name string) (*Table, error)
+	//CreateTable creates an unpartitioned table at the location with the columns, in rudder types
+	CreateTable(ctx context.Context, namespace string, name string, location string, columns map[string]string) (*Table, error)
+	//CommitTable replaces the metadata of the base table with the metadata, failing with ErrCommitFailed if the table changed since base was loaded
+	CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error)
+}
+
+const versionHintFile = "version-hint.text"
+
+/*
+FileCatalog keeps the metadata of the tables next to their data, as <location>/metadata/v<N>.metadata.json, with the current
+version N in <location>/metadata/version-hint.text, the layout of the hadoop catalog of iceberg.
+
+Commits create the metadata file of the next version with a conditional write, which fails if a concurrent commit created it
+first, so the metadata files are the source of truth. The version hint is written after, and may lag behind: the current version
+is found by probing the versions after the hint.
+*/
+type FileCatalog struct {
+	io       ConditionalFileIO
+	location func(namespace string, name string) string
+}
+
+//NewFileCatalog returns the file catalog of the tables whose location is returned by location
+func NewFileCatalog(io ConditionalFileIO, location func(namespace string, name string) string) *FileCatalog {
+	return &FileCatalog{io: io, location: location}
+}
+
+func (c *FileCatalog) CreateNamespace(_ context.Context, _ string) error {
+	return nil
+}
+
+func (c *FileCatalog) metadataLocation(tableLocation string, version int) string {
+	return fmt.Sprintf("%s/metadata/v%d.metadata.json", tableLocation, version)
+}
+
+//currentVersion returns the latest version whose metadata file exists, starting from the version hint, or ErrNotFound if there is none
+func (c *FileCatalog) currentVersion(ctx context.Context, tableLocation string) (int, error) {
+	var version int
+	hint, err := c.io.Read(ctx, fmt.Sprintf("%s/metadata/%s", tableLocation, versionHintFile))
+	if err == nil {
+		if version, err = strconv.Atoi(strings.TrimSpace(string(hint))); err != nil {
+			return 0, fmt.Errorf("iceberg: invalid version hint of table at %s: %w", tableLocation, err)
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	for {
+		_, err := c.io.Read(ctx, c.metadataLocation(tableLocation, version+1))
+		if errors.Is(err, ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		version++
+	}
+	if version == 0 {
+		return 0, ErrNotFound
+	}
+	return version, nil
+}
+
+func (c *FileCatalog) LoadTable(ctx context.Context, namespace string, name string) (*Table, error) {
+	tableLocation := c.location(namespace, name)
+	version, err := c.currentVersion(ctx, tableLocation)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNoSuchTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	metadataLocation := c.metadataLocation(tableLocation, version)
+	data, err := c.io.Read(ctx, metadataLocation)
+	if err != nil {
+		return nil, fmt.Errorf("iceberg: failed to read metadata of table %s.%s: %w", namespace, name, err)
+	}
+	metadata, err := ParseTableMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Table{Namespace: namespace, Name: name, MetadataLocation: metadataLocation, Metadata: metadata}, nil
+}
+
+func (c *FileCatalog) CreateTable(ctx context.Context, namespace string, name string, location string, columns map[string]string) (*Table, error) {
+	if _, err := c.LoadTable(ctx, namespace, name); err == nil {
+		return nil, ErrTableExists
+	} else if !errors.Is(err, ErrNoSuchTable) {
+		return nil, err
+	}
+	metadata, err := NewTableMetadata(location, columns)
+	if err != nil {
+		return nil, err
+	}
+	table, err := c.writeVersion(ctx, namespace, name, 1, metadata)
+	if errors.Is(err, ErrExists) {
+		return nil, ErrTableExists
+	}
+	return table, err
+}
+
+func (c *FileCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	tableLocation := c.location(base.Namespace, base.Name)
+	version, err := c.currentVersion(ctx, tableLocation)
+	if err != nil {
+		return nil, err
+	}
+	if c.metadataLocation(tableLocation, version) != base.MetadataLocation {
+		return nil, ErrCommitFailed
+	}
+
+	metadata.MetadataLog = append(metadata.MetadataLog, MetadataLogEntry{TimestampMs: base.Metadata.LastUpdatedMs, MetadataFile: base.MetadataLocation})
+	table, err := c.writeVersion(ctx, base.Namespace, base.Name, version+1, metadata)
+	if errors.Is(err, ErrExists) {
+		return nil, ErrCommitFailed
+	}
+	return table, err
+}
+
+//writeVersion creates the metadata file of the version, failing with ErrExists if it was created already, and then updates the version hint
+func (c *FileCatalog) writeVersion(ctx context.Context, namespace string, name string, version int, metadata *TableMetadata) (*Table, error) {
+	tableLocation := c.location(namespace, name)
+	metadata.LastUpdatedMs = time.Now().UnixNano() / int64(time.Millisecond)
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	metadataLocation := c.metadataLocation(tableLocation, version)
+	if err := c.io.WriteIfNotExists(ctx, metadataLocation, data); err != nil {
+		if errors.Is(err, ErrExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("iceberg: failed to write metadata of table %s.%s: %w", namespace, name, err)
+	}
+	//the version is committed once its metadata file exists, failing to write the hint only makes the next loads probe one more version
+	_ = c.io.Write(ctx, fmt.Sprintf("%s/metadata/%s", tableLocation, versionHintFile), []byte(strconv.Itoa(version)))
+	return &Table{Namespace: namespace, Name: name, MetadataLocation: metadataLocation, Metadata: metadata}, nil
+}
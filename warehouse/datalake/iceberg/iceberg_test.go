@@ -0,0 +1,441 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/linkedin/goavro/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+//memIO is an in memory FileIO
+type memIO struct {
+	lock  sync.Mutex
+	files map[string][]byte
+}
+
+func newMemIO() *memIO {
+	return &memIO{files: map[string][]byte{}}
+}
+
+func (m *memIO) Read(_ context.Context, location string) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	data, ok := m.files[location]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (m *memIO) Write(_ context.Context, location string, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.files[location] = data
+	return nil
+}
+
+func (m *memIO) WriteIfNotExists(_ context.Context, location string, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.files[location]; ok {
+		return ErrExists
+	}
+	m.files[location] = data
+	return nil
+}
+
+func (m *memIO) Location(key string) string {
+	return "s3://bucket/" + key
+}
+
+func tableLocation(namespace string, name string) string {
+	return fmt.Sprintf("s3://bucket/rudder-datalake/%s/%s", namespace, name)
+}
+
+func manifestPaths(io FileIO, snapshot *Snapshot) []string {
+	data, err := io.Read(context.Background(), snapshot.ManifestList)
+	Expect(err).NotTo(HaveOccurred())
+	manifests, err := decodeManifestList(data)
+	Expect(err).NotTo(HaveOccurred())
+	var paths []string
+	for _, manifest := range manifests {
+		paths = append(paths, manifest.Path)
+	}
+	return paths
+}
+
+//restCatalogServer is a rest catalog keeping the metadata of the tables in memory, applying the updates of commits
+type restCatalogServer struct {
+	lock     sync.Mutex
+	tables   map[string]*TableMetadata
+	versions map[string]int
+	token    string
+}
+
+func (s *restCatalogServer) respond(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *restCatalogServer) loadResult(key string) map[string]interface{} {
+	return map[string]interface{}{"metadata-location": fmt.Sprintf("%s/metadata/v%d.metadata.json", s.tables[key].Location, s.versions[key]), "metadata": s.tables[key]}
+}
+
+func (s *restCatalogServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		s.respond(w, http.StatusUnauthorized, map[string]interface{}{"error": map[string]interface{}{"message": "unauthorized", "type": "NotAuthorizedException", "code": 401}})
+		return
+	}
+	if r.URL.Path == "/v1/config" {
+		s.respond(w, http.StatusOK, map[string]interface{}{"defaults": map[string]string{}, "overrides": map[string]string{"prefix": r.URL.Query().Get("warehouse")}})
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/lake/namespaces"), "/")
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.respond(w, http.StatusOK, map[string]interface{}{})
+	case len(parts) == 3 && r.Method == http.MethodPost:
+		var req restCreateTableRequest
+		Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+		key := parts[1] + "." + req.Name
+		if _, ok := s.tables[key]; ok {
+			s.respond(w, http.StatusConflict, map[string]interface{}{"error": map[string]interface{}{"message": "exists", "type": "AlreadyExistsException", "code": 409}})
+			return
+		}
+		Expect(req.Properties[FormatVersionProperty]).To(Equal("1"))
+		columns := map[string]string{}
+		for _, field := range req.Schema.Fields {
+			var icebergType string
+			Expect(json.Unmarshal(field.Type, &icebergType)).To(Succeed())
+			columns[field.Name] = icebergDataTypesMapToRudder[icebergType]
+		}
+		metadata, err := NewTableMetadata(req.Location, columns)
+		Expect(err).NotTo(HaveOccurred())
+		s.tables[key] = metadata
+		s.versions[key] = 1
+		s.respond(w, http.StatusOK, s.loadResult(key))
+	case len(parts) == 4 && r.Method == http.MethodGet:
+		key := parts[1] + "." + parts[3]
+		if _, ok := s.tables[key]; !ok {
+			s.respond(w, http.StatusNotFound, map[string]interface{}{"error": map[string]interface{}{"message": "not found", "type": "NoSuchTableException", "code": 404}})
+			return
+		}
+		s.respond(w, http.StatusOK, s.loadResult(key))
+	case len(parts) == 4 && r.Method == http.MethodPost:
+		key := parts[1] + "." + parts[3]
+		metadata := s.tables[key]
+		var req struct {
+			Requirements []map[string]interface{} `json:"requirements"`
+			Updates      []map[string]json.RawMessage
+		}
+		Expect(json.NewDecoder(r.Body).Decode(&req)).To(Succeed())
+		for _, requirement := range req.Requirements {
+			if requirement["type"] == "assert-ref-snapshot-id" {
+				var current interface{}
+				if metadata.CurrentSnapshotID != nil {
+					current = float64(*metadata.CurrentSnapshotID)
+				}
+				if requirement["snapshot-id"] != current {
+					s.respond(w, http.StatusConflict, map[string]interface{}{"error": map[string]interface{}{"message": "branch main has changed", "type": "CommitFailedException", "code": 409}})
+					return
+				}
+			}
+		}
+		for _, update := range req.Updates {
+			var action string
+			Expect(json.Unmarshal(update["action"], &action)).To(Succeed())
+			switch action {
+			case "add-schema":
+				var schema Schema
+				Expect(json.Unmarshal(update["schema"], &schema)).To(Succeed())
+				Expect(json.Unmarshal(update["last-column-id"], &metadata.LastColumnID)).To(Succeed())
+				metadata.Schemas = append(metadata.Schemas, schema)
+			case "set-current-schema":
+				var schemaID int
+				Expect(json.Unmarshal(update["schema-id"], &schemaID)).To(Succeed())
+				if schemaID == -1 {
+					schemaID = metadata.Schemas[len(metadata.Schemas)-1].SchemaID
+				}
+				metadata.CurrentSchemaID = schemaID
+				current := metadata.CurrentSchema()
+				metadata.Schema = &current
+			case "set-properties":
+				var properties map[string]string
+				Expect(json.Unmarshal(update["updates"], &properties)).To(Succeed())
+				for k, v := range properties {
+					metadata.Properties[k] = v
+				}
+			case "add-snapshot":
+				var snapshot Snapshot
+				Expect(json.Unmarshal(update["snapshot"], &snapshot)).To(Succeed())
+				metadata.Snapshots = append(metadata.Snapshots, snapshot)
+			case "set-snapshot-ref":
+				var snapshotID int64
+				Expect(json.Unmarshal(update["snapshot-id"], &snapshotID)).To(Succeed())
+				metadata.CurrentSnapshotID = &snapshotID
+			default:
+				Fail("unexpected update " + action)
+			}
+		}
+		s.versions[key]++
+		s.respond(w, http.StatusOK, s.loadResult(key))
+	default:
+		s.respond(w, http.StatusBadRequest, map[string]interface{}{})
+	}
+}
+
+var _ = Describe("Iceberg", func() {
+	ctx := context.Background()
+	columns := map[string]string{"id": "string", "received_at": "datetime", "revenue": "float", "count": "int", "is_test": "boolean"}
+	dataFiles := []DataFile{
+		{Location: "s3://bucket/rudder-datalake/ns/tracks/2022/01/01/00/load.1.parquet", RecordCount: 10, SizeInBytes: 1000},
+		{Location: "s3://bucket/rudder-datalake/ns/tracks/2022/01/01/00/load.2.parquet", RecordCount: 5, SizeInBytes: 500},
+	}
+
+	Context("Metadata", func() {
+		It("should map the columns to fields, and back", func() {
+			metadata, err := NewTableMetadata(tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metadata.LastColumnID).To(Equal(5))
+			Expect(metadata.Columns()).To(Equal(columns))
+
+			var mapping []nameMappingField
+			Expect(json.Unmarshal([]byte(metadata.Properties[NameMappingProperty]), &mapping)).To(Succeed())
+			Expect(mapping).To(HaveLen(5))
+			Expect(mapping[0]).To(Equal(nameMappingField{FieldID: 1, Names: []string{"count"}}))
+		})
+
+		It("should evolve the schema with the new columns only", func() {
+			metadata, err := NewTableMetadata(tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			changed, err := metadata.AddColumns(map[string]string{"id": "string", "context_ip": "string"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeTrue())
+			Expect(metadata.CurrentSchemaID).To(Equal(1))
+			Expect(metadata.Schemas).To(HaveLen(2))
+			Expect(metadata.CurrentSchema().Fields[5].ID).To(Equal(6))
+			Expect(metadata.CurrentSchema().Fields[5].Name).To(Equal("context_ip"))
+			Expect(metadata.Properties[NameMappingProperty]).To(ContainSubstring(`{"field-id":6,"names":["context_ip"]}`))
+
+			changed, err = metadata.AddColumns(map[string]string{"context_ip": "string"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changed).To(BeFalse())
+		})
+
+		It("should fail on unsupported types", func() {
+			_, err := NewTableMetadata(tableLocation("ns", "tracks"), map[string]string{"a": "array"})
+			Expect(err).To(MatchError(ContainSubstring("unsupported type array")))
+		})
+	})
+
+	Context("Manifests", func() {
+		It("should write manifests with the field ids of the iceberg schema", func() {
+			metadata, err := NewTableMetadata(tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			data, err := encodeManifest(metadata.CurrentSchema(), 42, dataFiles)
+			Expect(err).NotTo(HaveOccurred())
+
+			reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reader.Codec().Schema()).To(ContainSubstring(`"field-id": 100`))
+			Expect(string(reader.MetaData()["partition-spec-id"])).To(Equal("0"))
+			Expect(string(reader.MetaData()["schema"])).To(ContainSubstring(`"name":"received_at","required":false,"type":"timestamptz"`))
+			var entries []map[string]interface{}
+			for reader.Scan() {
+				datum, err := reader.Read()
+				Expect(err).NotTo(HaveOccurred())
+				entries = append(entries, datum.(map[string]interface{}))
+			}
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[0]["snapshot_id"]).To(Equal(int64(42)))
+			Expect(entries[1]["data_file"].(map[string]interface{})["record_count"]).To(Equal(int64(5)))
+		})
+	})
+
+	Context("File catalog", func() {
+		var (
+			io      *memIO
+			catalog *FileCatalog
+		)
+
+		BeforeEach(func() {
+			io = newMemIO()
+			catalog = NewFileCatalog(io, tableLocation)
+		})
+
+		It("should create and load tables", func() {
+			_, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).To(Equal(ErrNoSuchTable))
+
+			table, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(table.MetadataLocation).To(Equal("s3://bucket/rudder-datalake/ns/tracks/metadata/v1.metadata.json"))
+			Expect(string(io.files["s3://bucket/rudder-datalake/ns/tracks/metadata/version-hint.text"])).To(Equal("1"))
+
+			_, err = catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).To(Equal(ErrTableExists))
+
+			loaded, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Metadata.TableUUID).To(Equal(table.Metadata.TableUUID))
+			Expect(loaded.Metadata.Columns()).To(Equal(columns))
+		})
+
+		It("should append the files of every upload in a snapshot, once", func() {
+			table, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+
+			table, err = AppendFiles(ctx, catalog, io, table, dataFiles[:1])
+			Expect(err).NotTo(HaveOccurred())
+			first := table.Metadata.CurrentSnapshot()
+			Expect(first.Summary).To(HaveKeyWithValue("operation", "append"))
+			Expect(first.Summary).To(HaveKeyWithValue("total-records", "10"))
+			Expect(first.ParentSnapshotID).To(BeNil())
+
+			table, err = AppendFiles(ctx, catalog, io, table, dataFiles[1:])
+			Expect(err).NotTo(HaveOccurred())
+			second := table.Metadata.CurrentSnapshot()
+			Expect(*second.ParentSnapshotID).To(Equal(first.SnapshotID))
+			Expect(second.Summary).To(HaveKeyWithValue("total-records", "15"))
+			Expect(second.Summary).To(HaveKeyWithValue("total-data-files", "2"))
+			Expect(manifestPaths(io, second)).To(HaveLen(2))
+			Expect(manifestPaths(io, second)[1]).To(Equal(manifestPaths(io, first)[0]))
+			Expect(table.MetadataLocation).To(HaveSuffix("/v3.metadata.json"))
+			Expect(table.Metadata.MetadataLog).To(HaveLen(2))
+
+			retried, err := AppendFiles(ctx, catalog, io, table, dataFiles[1:])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(retried.MetadataLocation).To(Equal(table.MetadataLocation))
+			Expect(retried.Metadata.Snapshots).To(HaveLen(2))
+		})
+
+		It("should commit again on the latest metadata when the table changed", func() {
+			stale, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			latest, err := AddColumns(ctx, catalog, stale, map[string]string{"context_ip": "string"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = catalog.CommitTable(ctx, stale, stale.Metadata.Clone())
+			Expect(err).To(Equal(ErrCommitFailed))
+
+			table, err := AppendFiles(ctx, catalog, io, stale, dataFiles)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(table.Metadata.CurrentSchemaID).To(Equal(latest.Metadata.CurrentSchemaID))
+			Expect(table.Metadata.Columns()).To(HaveKey("context_ip"))
+			Expect(table.Metadata.CurrentSnapshot().Summary).To(HaveKeyWithValue("added-data-files", "2"))
+		})
+
+		It("should fail all but one of the concurrent commits on the same metadata", func() {
+			base, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+
+			var (
+				wg        sync.WaitGroup
+				committed int32
+			)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					_, err := catalog.CommitTable(ctx, base, base.Metadata.Clone())
+					if err == nil {
+						atomic.AddInt32(&committed, 1)
+						return
+					}
+					Expect(err).To(Equal(ErrCommitFailed))
+				}()
+			}
+			wg.Wait()
+			Expect(committed).To(Equal(int32(1)))
+		})
+
+		It("should load the latest version when the version hint lags behind", func() {
+			table, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			table, err = AddColumns(ctx, catalog, table, map[string]string{"context_ip": "string"})
+			Expect(err).NotTo(HaveOccurred())
+			io.files["s3://bucket/rudder-datalake/ns/tracks/metadata/version-hint.text"] = []byte("1")
+
+			loaded, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.MetadataLocation).To(Equal(table.MetadataLocation))
+
+			_, err = catalog.CommitTable(ctx, loaded, loaded.Metadata.Clone())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(io.files["s3://bucket/rudder-datalake/ns/tracks/metadata/version-hint.text"])).To(Equal("3"))
+		})
+	})
+
+	Context("REST catalog", func() {
+		var (
+			io      *memIO
+			server  *httptest.Server
+			catalog *RESTCatalog
+		)
+
+		BeforeEach(func() {
+			io = newMemIO()
+			server = httptest.NewServer(&restCatalogServer{tables: map[string]*TableMetadata{}, versions: map[string]int{}, token: "secret"})
+			catalog = NewRESTCatalog(server.URL, "secret", "lake", server.Client())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should create tables, evolve their schema and append files", func() {
+			Expect(catalog.CreateNamespace(ctx, "ns")).To(Succeed())
+			_, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).To(Equal(ErrNoSuchTable))
+
+			table, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).To(Equal(ErrTableExists))
+
+			table, err = AddColumns(ctx, catalog, table, map[string]string{"context_ip": "string"})
+			Expect(err).NotTo(HaveOccurred())
+			table, err = AppendFiles(ctx, catalog, io, table, dataFiles)
+			Expect(err).NotTo(HaveOccurred())
+
+			loaded, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.MetadataLocation).To(HaveSuffix("/v3.metadata.json"))
+			Expect(loaded.Metadata.Columns()).To(HaveKeyWithValue("context_ip", "string"))
+			Expect(loaded.Metadata.Properties[NameMappingProperty]).To(ContainSubstring("context_ip"))
+			Expect(loaded.Metadata.CurrentSnapshot().SnapshotID).To(Equal(table.Metadata.CurrentSnapshot().SnapshotID))
+			Expect(manifestPaths(io, loaded.Metadata.CurrentSnapshot())).To(HaveLen(1))
+		})
+
+		It("should append again on the latest snapshot when the table changed", func() {
+			stale, err := catalog.CreateTable(ctx, "ns", "tracks", tableLocation("ns", "tracks"), columns)
+			Expect(err).NotTo(HaveOccurred())
+			latest, err := AppendFiles(ctx, catalog, io, stale, dataFiles[:1])
+			Expect(err).NotTo(HaveOccurred())
+
+			table, err := AppendFiles(ctx, catalog, io, stale, dataFiles[1:])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*table.Metadata.CurrentSnapshot().ParentSnapshotID).To(Equal(latest.Metadata.CurrentSnapshot().SnapshotID))
+			Expect(manifestPaths(io, table.Metadata.CurrentSnapshot())).To(HaveLen(2))
+		})
+
+		It("should fail with the error of the catalog", func() {
+			catalog = NewRESTCatalog(server.URL, "wrong", "lake", server.Client())
+			_, err := catalog.LoadTable(ctx, "ns", "tracks")
+			Expect(err).To(MatchError(ContainSubstring("status 401: NotAuthorizedException: unauthorized")))
+		})
+	})
+})
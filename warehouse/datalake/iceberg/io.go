@@ -0,0 +1,175 @@
+package iceberg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/Azure/azure-storage-blob-go/azblob"
+
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+)
+
+var (
+	//ErrNotFound is returned when reading a file which doesn't exist
+	ErrNotFound = errors.New("iceberg: file not found")
+	//ErrExists is returned when creating a file which already exists
+	ErrExists = errors.New("iceberg: file already exists")
+)
+
+//FileIO reads and writes the metadata files of the tables, addressed by the uri of their location
+type FileIO interface {
+	Read(ctx context.Context, location string) ([]byte, error)
+	Write(ctx context.Context, location string, data []byte) error
+	//Location returns the uri of the object with the key in the object storage
+	Location(key string) string
+}
+
+//ConditionalFileIO is a FileIO which can create a file only if it doesn't exist yet, atomically
+type ConditionalFileIO interface {
+	FileIO
+	//WriteIfNotExists writes the data to the location, failing with ErrExists if there is a file there already
+	WriteIfNotExists(ctx context.Context, location string, data []byte) error
+}
+
+//objectStorageIO is the FileIO of the object storage of a datalake destination
+type objectStorageIO struct {
+	fm   filemanager.FileManager
+	base string
+}
+
+//conditionalObjectStorageIO is the FileIO of the object storages supporting conditional uploads
+type conditionalObjectStorageIO struct {
+	*objectStorageIO
+	uploader filemanager.ConditionalUploader
+}
+
+/*
+NewObjectStorageIO returns the FileIO of the bucket or container of the object storage config, with s3://, gs:// or abfss:// uris.
+It is a ConditionalFileIO if the file manager of the object storage supports conditional uploads.
+*/
+func NewObjectStorageIO(provider string, config map[string]interface{}) (FileIO, error) {
+	fm, err := filemanager.DefaultFileManagerFactory.New(&filemanager.SettingsT{
+		Provider: provider,
+		Config:   config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var base string
+	switch provider {
+	case "S3":
+		bucket, _ := config["bucketName"].(string)
+		base = fmt.Sprintf("s3://%s/", bucket)
+	case "GCS":
+		bucket, _ := config["bucketName"].(string)
+		base = fmt.Sprintf("gs://%s/", bucket)
+	case "AZURE_BLOB":
+		container, _ := config["containerName"].(string)
+		account, _ := config["accountName"].(string)
+		base = fmt.Sprintf("abfss://%s@%s.dfs.core.windows.net/", container, account)
+	default:
+		return nil, fmt.Errorf("iceberg: unsupported object storage %s", provider)
+	}
+	io := &objectStorageIO{fm: fm, base: base}
+	if uploader, ok := fm.(filemanager.ConditionalUploader); ok {
+		return &conditionalObjectStorageIO{objectStorageIO: io, uploader: uploader}, nil
+	}
+	return io, nil
+}
+
+func (o *objectStorageIO) Location(key string) string {
+	return o.base + strings.TrimPrefix(key, "/")
+}
+
+func (o *objectStorageIO) key(location string) (string, error) {
+	if !strings.HasPrefix(location, o.base) {
+		return "", fmt.Errorf("iceberg: location %s is not in %s", location, o.base)
+	}
+	return strings.TrimPrefix(location, o.base), nil
+}
+
+func (o *objectStorageIO) Read(ctx context.Context, location string) ([]byte, error) {
+	key, err := o.key(location)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp("", "iceberg-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := o.fm.Download(ctx, file, key); err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return os.ReadFile(file.Name())
+}
+
+//Write uploads the data to the key of the location, which the file manager puts under its configured prefix
+func (o *objectStorageIO) Write(ctx context.Context, location string, data []byte) error {
+	return o.write(ctx, location, data, o.fm.Upload)
+}
+
+func (o *conditionalObjectStorageIO) WriteIfNotExists(ctx context.Context, location string, data []byte) error {
+	err := o.write(ctx, location, data, o.uploader.UploadIfNotExists)
+	if errors.Is(err, filemanager.ErrKeyExists) {
+		return ErrExists
+	}
+	return err
+}
+
+func (o *objectStorageIO) write(ctx context.Context, location string, data []byte, upload func(context.Context, *os.File, ...string) (filemanager.UploadOutput, error)) error {
+	key, err := o.key(location)
+	if err != nil {
+		return err
+	}
+	dir, name := filepath.Split(key)
+	if prefix := o.fm.GetConfiguredPrefix(); prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+		if !strings.HasPrefix(dir, prefix) {
+			return fmt.Errorf("iceberg: location %s is not under the prefix %s", location, prefix)
+		}
+		dir = strings.TrimPrefix(dir, prefix)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "iceberg-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	file, err := os.Create(filepath.Join(tmpDir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+	var prefixes []string
+	if dir = strings.Trim(dir, "/"); dir != "" {
+		prefixes = append(prefixes, dir)
+	}
+	_, err = upload(ctx, file, prefixes...)
+	return err
+}
+
+func isNotFound(err error) bool {
+	if errors.Is(err, filemanager.ErrKeyNotFound) || errors.Is(err, storage.ErrObjectNotExist) {
+		return true
+	}
+	var storageErr azblob.StorageError
+	return errors.As(err, &storageErr) && storageErr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+}
@@ -0,0 +1,246 @@
+package iceberg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+//manifestEntrySchema is the avro schema of the entries of format version 1 manifests, of unpartitioned tables
+const manifestEntrySchema = `{
+	"type": "record",
+	"name": "manifest_entry",
+	"fields": [
+		{"name": "status", "type": "int", "field-id": 0},
+		{"name": "snapshot_id", "type": "long", "field-id": 1},
+		{"name": "data_file", "field-id": 2, "type": {
+			"type": "record",
+			"name": "r2",
+			"fields": [
+				{"name": "file_path", "type": "string", "doc": "Location URI with FS scheme", "field-id": 100},
+				{"name": "file_format", "type": "string", "doc": "File format name: avro, orc, or parquet", "field-id": 101},
+				{"name": "partition", "type": {"type": "record", "name": "r102", "fields": []}, "field-id": 102},
+				{"name": "record_count", "type": "long", "doc": "Number of records in the file", "field-id": 103},
+				{"name": "file_size_in_bytes", "type": "long", "doc": "Total file size in bytes", "field-id": 104},
+				{"name": "block_size_in_bytes", "type": "long", "field-id": 105}
+			]
+		}}
+	]
+}`
+
+//manifestFileSchema is the avro schema of the manifest lists of format version 1
+const manifestFileSchema = `{
+	"type": "record",
+	"name": "manifest_file",
+	"fields": [
+		{"name": "manifest_path", "type": "string", "doc": "Location URI with FS scheme", "field-id": 500},
+		{"name": "manifest_length", "type": "long", "doc": "Total file size in bytes", "field-id": 501},
+		{"name": "partition_spec_id", "type": "int", "doc": "Spec ID used to write", "field-id": 502},
+		{"name": "added_snapshot_id", "type": ["null", "long"], "default": null, "doc": "Snapshot ID that added the manifest", "field-id": 503},
+		{"name": "added_data_files_count", "type": ["null", "int"], "default": null, "doc": "Added entry count", "field-id": 504},
+		{"name": "existing_data_files_count", "type": ["null", "int"], "default": null, "doc": "Existing entry count", "field-id": 505},
+		{"name": "deleted_data_files_count", "type": ["null", "int"], "default": null, "doc": "Deleted entry count", "field-id": 506},
+		{"name": "added_rows_count", "type": ["null", "long"], "default": null, "doc": "Added rows count", "field-id": 512},
+		{"name": "existing_rows_count", "type": ["null", "long"], "default": null, "doc": "Existing rows count", "field-id": 513},
+		{"name": "deleted_rows_count", "type": ["null", "long"], "default": null, "doc": "Deleted rows count", "field-id": 514}
+	]
+}`
+
+//manifest entry statuses
+const (
+	entryStatusExisting = 0
+	entryStatusAdded    = 1
+)
+
+//defaultBlockSize is the deprecated block size of the data files, which format version 1 requires
+const defaultBlockSize = 64 * 1024 * 1024
+
+//DataFile is a parquet file with the rows of a table
+type DataFile struct {
+	Location    string
+	RecordCount int64
+	SizeInBytes int64
+}
+
+//ManifestFile is a manifest listed in the manifest list of a snapshot
+type ManifestFile struct {
+	Path                   string
+	Length                 int64
+	PartitionSpecID        int32
+	AddedSnapshotID        *int64
+	AddedDataFilesCount    *int32
+	ExistingDataFilesCount *int32
+	DeletedDataFilesCount  *int32
+	AddedRowsCount         *int64
+	ExistingRowsCount      *int64
+	DeletedRowsCount       *int64
+}
+
+var (
+	manifestEntryCodec = mustCodec(manifestEntrySchema)
+	manifestFileCodec  = mustCodec(manifestFileSchema)
+)
+
+func mustCodec(schema string) *goavro.Codec {
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		panic(fmt.Errorf("iceberg: invalid avro schema: %w", err))
+	}
+	return codec
+}
+
+//encodeManifest returns the manifest of the data files added by the snapshot
+func encodeManifest(schema Schema, snapshotID int64, dataFiles []DataFile) ([]byte, error) {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]interface{}, 0, len(dataFiles))
+	for _, dataFile := range dataFiles {
+		entries = append(entries, map[string]interface{}{
+			"status":      int32(entryStatusAdded),
+			"snapshot_id": snapshotID,
+			"data_file": map[string]interface{}{
+				"file_path":           dataFile.Location,
+				"file_format":         "PARQUET",
+				"partition":           map[string]interface{}{},
+				"record_count":        dataFile.RecordCount,
+				"file_size_in_bytes":  dataFile.SizeInBytes,
+				"block_size_in_bytes": int64(defaultBlockSize),
+			},
+		})
+	}
+	return encodeOCF(manifestEntryCodec, entries, map[string][]byte{
+		"schema":            schemaJSON,
+		"schema-id":         []byte(strconv.Itoa(schema.SchemaID)),
+		"partition-spec":    []byte("[]"),
+		"partition-spec-id": []byte("0"),
+		"format-version":    []byte(strconv.Itoa(FormatVersion)),
+		"content":           []byte("data"),
+	})
+}
+
+//encodeManifestList returns the manifest list of the snapshot
+func encodeManifestList(snapshot Snapshot, manifests []ManifestFile) ([]byte, error) {
+	records := make([]interface{}, 0, len(manifests))
+	for _, manifest := range manifests {
+		records = append(records, map[string]interface{}{
+			"manifest_path":             manifest.Path,
+			"manifest_length":           manifest.Length,
+			"partition_spec_id":         manifest.PartitionSpecID,
+			"added_snapshot_id":         optional("long", manifest.AddedSnapshotID),
+			"added_data_files_count":    optional("int", manifest.AddedDataFilesCount),
+			"existing_data_files_count": optional("int", manifest.ExistingDataFilesCount),
+			"deleted_data_files_count":  optional("int", manifest.DeletedDataFilesCount),
+			"added_rows_count":          optional("long", manifest.AddedRowsCount),
+			"existing_rows_count":       optional("long", manifest.ExistingRowsCount),
+			"deleted_rows_count":        optional("long", manifest.DeletedRowsCount),
+		})
+	}
+	metadata := map[string][]byte{
+		"snapshot-id":    []byte(strconv.FormatInt(snapshot.SnapshotID, 10)),
+		"format-version": []byte(strconv.Itoa(FormatVersion)),
+	}
+	if snapshot.ParentSnapshotID != nil {
+		metadata["parent-snapshot-id"] = []byte(strconv.FormatInt(*snapshot.ParentSnapshotID, 10))
+	}
+	return encodeOCF(manifestFileCodec, records, metadata)
+}
+
+//decodeManifestList returns the manifests of a manifest list. Columns of newer format versions are left out.
+func decodeManifestList(data []byte) ([]ManifestFile, error) {
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("iceberg: invalid manifest list: %w", err)
+	}
+	var manifests []ManifestFile
+	for reader.Scan() {
+		datum, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("iceberg: invalid manifest list: %w", err)
+		}
+		record, ok := datum.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("iceberg: invalid manifest list record %T", datum)
+		}
+		manifest := ManifestFile{
+			AddedSnapshotID:        optionalLong(record["added_snapshot_id"]),
+			AddedDataFilesCount:    optionalInt(record["added_data_files_count"]),
+			ExistingDataFilesCount: optionalInt(record["existing_data_files_count"]),
+			DeletedDataFilesCount:  optionalInt(record["deleted_data_files_count"]),
+			AddedRowsCount:         optionalLong(record["added_rows_count"]),
+			ExistingRowsCount:      optionalLong(record["existing_rows_count"]),
+			DeletedRowsCount:       optionalLong(record["deleted_rows_count"]),
+		}
+		manifest.Path, _ = record["manifest_path"].(string)
+		manifest.Length, _ = record["manifest_length"].(int64)
+		manifest.PartitionSpecID, _ = record["partition_spec_id"].(int32)
+		if manifest.Path == "" {
+			return nil, fmt.Errorf("iceberg: manifest list record without manifest_path")
+		}
+		manifests = append(manifests, manifest)
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("iceberg: invalid manifest list: %w", err)
+	}
+	return manifests, nil
+}
+
+func encodeOCF(codec *goavro.Codec, records []interface{}, metadata map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               &buf,
+		Codec:           codec,
+		CompressionName: goavro.CompressionDeflateLabel,
+		MetaData:        metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writer.Append(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//optional returns the avro union of an optional value, which goavro takes as a map of the type to the value
+func optional(avroType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case *int64:
+		if v == nil {
+			return nil
+		}
+		return goavro.Union(avroType, *v)
+	case *int32:
+		if v == nil {
+			return nil
+		}
+		return goavro.Union(avroType, *v)
+	}
+	return nil
+}
+
+func optionalLong(datum interface{}) *int64 {
+	union, ok := datum.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if v, ok := union["long"].(int64); ok {
+		return &v
+	}
+	return nil
+}
+
+func optionalInt(datum interface{}) *int32 {
+	union, ok := datum.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	if v, ok := union["int"].(int32); ok {
+		return &v
+	}
+	return nil
+}
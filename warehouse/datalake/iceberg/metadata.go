@@ -0,0 +1,322 @@
+// Package iceberg keeps datalake tables in the Apache Iceberg table format: the parquet load files of every upload are
+// committed to their tables as a snapshot, with the manifests and the table metadata written next to the data files.
+package iceberg
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+)
+
+const (
+	//FormatVersion is the version of the table format of the tables created, and the only one supported
+	FormatVersion = 1
+
+	//NameMappingProperty maps the columns of the data files to the fields of the table, since the parquet load files don't have field ids
+	NameMappingProperty = "schema.name-mapping.default"
+	//FormatVersionProperty is the table property setting the format version of the tables created by rest catalogs
+	FormatVersionProperty = "format-version"
+
+	mainBranch = "main"
+)
+
+var rudderDataTypesMapToIceberg = map[string]string{
+	"boolean":  "boolean",
+	"int":      "long",
+	"bigint":   "long",
+	"float":    "double",
+	"string":   "string",
+	"text":     "string",
+	"json":     "string",
+	"datetime": "timestamptz",
+}
+
+var icebergDataTypesMapToRudder = map[string]string{
+	"boolean":     "boolean",
+	"int":         "int",
+	"long":        "int",
+	"float":       "float",
+	"double":      "float",
+	"string":      "string",
+	"timestamptz": "datetime",
+	"timestamp":   "datetime",
+}
+
+//TableMetadata is the metadata of a table, in format version 1 with the optional fields of format version 2 which keep the metadata readable by newer readers
+type TableMetadata struct {
+	FormatVersion      int                    `json:"format-version"`
+	TableUUID          string                 `json:"table-uuid"`
+	Location           string                 `json:"location"`
+	LastUpdatedMs      int64                  `json:"last-updated-ms"`
+	LastColumnID       int                    `json:"last-column-id"`
+	Schema             *Schema                `json:"schema,omitempty"`
+	Schemas            []Schema               `json:"schemas"`
+	CurrentSchemaID    int                    `json:"current-schema-id"`
+	PartitionSpec      []PartitionField       `json:"partition-spec"`
+	PartitionSpecs     []PartitionSpec        `json:"partition-specs"`
+	DefaultSpecID      int                    `json:"default-spec-id"`
+	LastPartitionID    int                    `json:"last-partition-id"`
+	Properties         map[string]string      `json:"properties,omitempty"`
+	CurrentSnapshotID  *int64                 `json:"current-snapshot-id,omitempty"`
+	Snapshots          []Snapshot             `json:"snapshots"`
+	SnapshotLog        []SnapshotLogEntry     `json:"snapshot-log"`
+	MetadataLog        []MetadataLogEntry     `json:"metadata-log"`
+	SortOrders         json.RawMessage        `json:"sort-orders,omitempty"`
+	DefaultSortOrderID *int                   `json:"default-sort-order-id,omitempty"`
+	Refs               map[string]SnapshotRef `json:"refs,omitempty"`
+}
+
+type Schema struct {
+	Type     string  `json:"type"`
+	SchemaID int     `json:"schema-id"`
+	Fields   []Field `json:"fields"`
+}
+
+//Field is a top level field of a schema. Its type is kept as is, so that fields with nested types added by other writers are preserved
+type Field struct {
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Required bool            `json:"required"`
+	Type     json.RawMessage `json:"type"`
+	Doc      string          `json:"doc,omitempty"`
+}
+
+type PartitionSpec struct {
+	SpecID int              `json:"spec-id"`
+	Fields []PartitionField `json:"fields"`
+}
+
+type PartitionField struct {
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+	SourceID  int    `json:"source-id"`
+	FieldID   int    `json:"field-id"`
+}
+
+type Snapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number,omitempty"`
+	TimestampMs      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaID         *int              `json:"schema-id,omitempty"`
+}
+
+type SnapshotLogEntry struct {
+	TimestampMs int64 `json:"timestamp-ms"`
+	SnapshotID  int64 `json:"snapshot-id"`
+}
+
+type MetadataLogEntry struct {
+	TimestampMs  int64  `json:"timestamp-ms"`
+	MetadataFile string `json:"metadata-file"`
+}
+
+type SnapshotRef struct {
+	SnapshotID int64  `json:"snapshot-id"`
+	Type       string `json:"type"`
+}
+
+type nameMappingField struct {
+	FieldID int      `json:"field-id"`
+	Names   []string `json:"names"`
+}
+
+//NewTableMetadata returns the metadata of a new unpartitioned table at location, with a schema of the columns
+func NewTableMetadata(location string, columns map[string]string) (*TableMetadata, error) {
+	m := &TableMetadata{
+		FormatVersion:   FormatVersion,
+		TableUUID:       uuid.Must(uuid.NewV4()).String(),
+		Location:        location,
+		LastUpdatedMs:   time.Now().UnixNano() / int64(time.Millisecond),
+		Schemas:         []Schema{},
+		PartitionSpec:   []PartitionField{},
+		PartitionSpecs:  []PartitionSpec{{SpecID: 0, Fields: []PartitionField{}}},
+		LastPartitionID: 999,
+		Properties:      map[string]string{},
+		Snapshots:       []Snapshot{},
+		SnapshotLog:     []SnapshotLogEntry{},
+		MetadataLog:     []MetadataLogEntry{},
+	}
+	schema := Schema{Type: "struct", SchemaID: 0, Fields: []Field{}}
+	for _, name := range sortedKeys(columns) {
+		field, err := m.newField(name, columns[name])
+		if err != nil {
+			return nil, err
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	m.setSchema(schema)
+	return m, nil
+}
+
+func (m *TableMetadata) newField(name string, columnType string) (Field, error) {
+	icebergType, ok := rudderDataTypesMapToIceberg[columnType]
+	if !ok {
+		return Field{}, fmt.Errorf("iceberg: unsupported type %s of column %s", columnType, name)
+	}
+	m.LastColumnID++
+	typeJSON, _ := json.Marshal(icebergType)
+	return Field{ID: m.LastColumnID, Name: name, Type: typeJSON}, nil
+}
+
+func (m *TableMetadata) setSchema(schema Schema) {
+	m.Schemas = append(m.Schemas, schema)
+	m.CurrentSchemaID = schema.SchemaID
+	m.Schema = &m.Schemas[len(m.Schemas)-1]
+	m.updateNameMapping()
+}
+
+//updateNameMapping maps the column names of the data files to the fields of the current schema
+func (m *TableMetadata) updateNameMapping() {
+	mapping := []nameMappingField{}
+	for _, field := range m.CurrentSchema().Fields {
+		mapping = append(mapping, nameMappingField{FieldID: field.ID, Names: []string{field.Name}})
+	}
+	mappingJSON, _ := json.Marshal(mapping)
+	if m.Properties == nil {
+		m.Properties = map[string]string{}
+	}
+	m.Properties[NameMappingProperty] = string(mappingJSON)
+}
+
+//CurrentSchema returns the current schema of the table
+func (m *TableMetadata) CurrentSchema() Schema {
+	for _, schema := range m.Schemas {
+		if schema.SchemaID == m.CurrentSchemaID {
+			return schema
+		}
+	}
+	if m.Schema != nil {
+		return *m.Schema
+	}
+	return Schema{Type: "struct", Fields: []Field{}}
+}
+
+//Columns returns the columns of the current schema with their rudder types, leaving out the columns of types rudder doesn't write
+func (m *TableMetadata) Columns() map[string]string {
+	columns := map[string]string{}
+	for _, field := range m.CurrentSchema().Fields {
+		var icebergType string
+		if err := json.Unmarshal(field.Type, &icebergType); err != nil {
+			continue
+		}
+		if columnType, ok := icebergDataTypesMapToRudder[icebergType]; ok {
+			columns[field.Name] = columnType
+		}
+	}
+	return columns
+}
+
+//AddColumns adds a new current schema with the columns missing from the current one, and returns whether there were any
+func (m *TableMetadata) AddColumns(columns map[string]string) (bool, error) {
+	current := m.CurrentSchema()
+	existing := map[string]bool{}
+	for _, field := range current.Fields {
+		existing[field.Name] = true
+	}
+
+	schema := Schema{Type: "struct", Fields: append([]Field{}, current.Fields...)}
+	for _, schema := range m.Schemas {
+		if schema.SchemaID >= current.SchemaID {
+			current.SchemaID = schema.SchemaID
+		}
+	}
+	schema.SchemaID = current.SchemaID + 1
+	for _, name := range sortedKeys(columns) {
+		if existing[name] {
+			continue
+		}
+		field, err := m.newField(name, columns[name])
+		if err != nil {
+			return false, err
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	if len(schema.Fields) == len(current.Fields) {
+		return false, nil
+	}
+	m.setSchema(schema)
+	return true, nil
+}
+
+//CurrentSnapshot returns the current snapshot of the table, nil if it has none
+func (m *TableMetadata) CurrentSnapshot() *Snapshot {
+	if m.CurrentSnapshotID == nil {
+		return nil
+	}
+	for i := range m.Snapshots {
+		if m.Snapshots[i].SnapshotID == *m.CurrentSnapshotID {
+			return &m.Snapshots[i]
+		}
+	}
+	return nil
+}
+
+//AddSnapshot adds the snapshot to the table and makes it the current one
+func (m *TableMetadata) AddSnapshot(snapshot Snapshot) {
+	m.Snapshots = append(m.Snapshots, snapshot)
+	m.SnapshotLog = append(m.SnapshotLog, SnapshotLogEntry{TimestampMs: snapshot.TimestampMs, SnapshotID: snapshot.SnapshotID})
+	m.CurrentSnapshotID = &snapshot.SnapshotID
+	if m.Refs == nil {
+		m.Refs = map[string]SnapshotRef{}
+	}
+	m.Refs[mainBranch] = SnapshotRef{SnapshotID: snapshot.SnapshotID, Type: "branch"}
+	m.LastUpdatedMs = snapshot.TimestampMs
+}
+
+//Clone returns a deep copy of the metadata, to be changed and committed
+func (m *TableMetadata) Clone() *TableMetadata {
+	data, err := json.Marshal(m)
+	if err != nil {
+		panic(fmt.Errorf("iceberg: failed to marshal table metadata: %w", err))
+	}
+	clone, err := ParseTableMetadata(data)
+	if err != nil {
+		panic(fmt.Errorf("iceberg: failed to unmarshal table metadata: %w", err))
+	}
+	return clone
+}
+
+//ParseTableMetadata parses the json table metadata
+func ParseTableMetadata(data []byte) (*TableMetadata, error) {
+	var m TableMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("iceberg: invalid table metadata: %w", err)
+	}
+	if m.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("iceberg: unsupported table format version %d", m.FormatVersion)
+	}
+	//tables without snapshots have a current snapshot id of -1 in the metadata written by some writers
+	if m.CurrentSnapshotID != nil && *m.CurrentSnapshotID == -1 {
+		m.CurrentSnapshotID = nil
+	}
+	if len(m.Schemas) == 0 && m.Schema != nil {
+		m.Schemas = []Schema{*m.Schema}
+		m.CurrentSchemaID = m.Schema.SchemaID
+	}
+	current := m.CurrentSchema()
+	m.Schema = &current
+	return &m, nil
+}
+
+//newSnapshotID returns a random positive snapshot id
+func newSnapshotID() int64 {
+	id := uuid.Must(uuid.NewV4())
+	return int64(binary.BigEndian.Uint64(id[:8]) & math.MaxInt64)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,317 @@
+package iceberg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+//RESTCatalog is a catalog implementing the iceberg rest catalog api, which commits the changes of the tables atomically
+type RESTCatalog struct {
+	url       string
+	token     string
+	warehouse string
+	client    *http.Client
+
+	prefixLock   sync.Mutex
+	prefixLoaded bool
+	prefix       string
+}
+
+//NewRESTCatalog returns the rest catalog at url, authenticating with the bearer token if set
+func NewRESTCatalog(catalogURL string, token string, warehouse string, client *http.Client) *RESTCatalog {
+	return &RESTCatalog{url: strings.TrimSuffix(catalogURL, "/"), token: token, warehouse: warehouse, client: client}
+}
+
+type restError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+type restTableIdentifier struct {
+	Namespace []string `json:"namespace"`
+	Name      string   `json:"name"`
+}
+
+type restLoadTableResult struct {
+	MetadataLocation string          `json:"metadata-location"`
+	Metadata         json.RawMessage `json:"metadata"`
+}
+
+type restCreateTableRequest struct {
+	Name          string            `json:"name"`
+	Location      string            `json:"location,omitempty"`
+	Schema        Schema            `json:"schema"`
+	PartitionSpec PartitionSpec     `json:"partition-spec"`
+	Properties    map[string]string `json:"properties,omitempty"`
+}
+
+type restCommitTableRequest struct {
+	Identifier   restTableIdentifier      `json:"identifier"`
+	Requirements []map[string]interface{} `json:"requirements"`
+	Updates      []map[string]interface{} `json:"updates"`
+}
+
+//statusError is the error of a request to the catalog which failed with the status
+type statusError struct {
+	status  int
+	message string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("iceberg: rest catalog responded with status %d: %s", e.status, e.message)
+}
+
+func (c *RESTCatalog) do(ctx context.Context, method string, path string, body interface{}, response interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("iceberg: rest catalog request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("iceberg: failed to read rest catalog response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp restError
+		message := string(respBody)
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error.Message != "" {
+			message = fmt.Sprintf("%s: %s", errResp.Error.Type, errResp.Error.Message)
+		}
+		return &statusError{status: resp.StatusCode, message: message}
+	}
+	if response == nil || len(respBody) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("iceberg: invalid rest catalog response: %w", err)
+	}
+	return nil
+}
+
+func statusOf(err error) int {
+	if statusErr, ok := err.(*statusError); ok {
+		return statusErr.status
+	}
+	return 0
+}
+
+//pathPrefix returns the prefix of the paths of the catalog, from the config of the catalog for the warehouse
+func (c *RESTCatalog) pathPrefix(ctx context.Context) (string, error) {
+	c.prefixLock.Lock()
+	defer c.prefixLock.Unlock()
+	if c.prefixLoaded {
+		return c.prefix, nil
+	}
+
+	var config struct {
+		Defaults  map[string]string `json:"defaults"`
+		Overrides map[string]string `json:"overrides"`
+	}
+	path := "/v1/config"
+	if c.warehouse != "" {
+		path += "?warehouse=" + url.QueryEscape(c.warehouse)
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &config); err != nil {
+		return "", err
+	}
+	prefix := config.Defaults["prefix"]
+	if override, ok := config.Overrides["prefix"]; ok {
+		prefix = override
+	}
+	if prefix != "" {
+		c.prefix = "/" + strings.Trim(prefix, "/")
+	}
+	c.prefixLoaded = true
+	return c.prefix, nil
+}
+
+func (c *RESTCatalog) namespacesPath(ctx context.Context) (string, error) {
+	prefix, err := c.pathPrefix(ctx)
+	if err != nil {
+		return "", err
+	}
+	return "/v1" + prefix + "/namespaces", nil
+}
+
+func (c *RESTCatalog) tablesPath(ctx context.Context, namespace string) (string, error) {
+	namespaces, err := c.namespacesPath(ctx)
+	if err != nil {
+		return "", err
+	}
+	return namespaces + "/" + url.PathEscape(namespace) + "/tables", nil
+}
+
+func (c *RESTCatalog) CreateNamespace(ctx context.Context, namespace string) error {
+	path, err := c.namespacesPath(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.do(ctx, http.MethodPost, path, map[string]interface{}{"namespace": []string{namespace}, "properties": map[string]string{}}, nil)
+	if statusOf(err) == http.StatusConflict {
+		return nil
+	}
+	return err
+}
+
+func (c *RESTCatalog) LoadTable(ctx context.Context, namespace string, name string) (*Table, error) {
+	path, err := c.tablesPath(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var result restLoadTableResult
+	err = c.do(ctx, http.MethodGet, path+"/"+url.PathEscape(name), nil, &result)
+	if statusOf(err) == http.StatusNotFound {
+		return nil, ErrNoSuchTable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tableOf(namespace, name, result)
+}
+
+func (c *RESTCatalog) CreateTable(ctx context.Context, namespace string, name string, location string, columns map[string]string) (*Table, error) {
+	path, err := c.tablesPath(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := NewTableMetadata(location, columns)
+	if err != nil {
+		return nil, err
+	}
+	properties := map[string]string{FormatVersionProperty: fmt.Sprint(FormatVersion)}
+	for k, v := range metadata.Properties {
+		properties[k] = v
+	}
+	var result restLoadTableResult
+	err = c.do(ctx, http.MethodPost, path, restCreateTableRequest{
+		Name:          name,
+		Location:      location,
+		Schema:        metadata.CurrentSchema(),
+		PartitionSpec: metadata.PartitionSpecs[0],
+		Properties:    properties,
+	}, &result)
+	if statusOf(err) == http.StatusConflict {
+		return nil, ErrTableExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tableOf(namespace, name, result)
+}
+
+//CommitTable sends the changes from the base metadata as updates, with the requirements that the table is unchanged since base was loaded
+func (c *RESTCatalog) CommitTable(ctx context.Context, base *Table, metadata *TableMetadata) (*Table, error) {
+	path, err := c.tablesPath(ctx, base.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseSnapshotID interface{}
+	if base.Metadata.CurrentSnapshotID != nil {
+		baseSnapshotID = *base.Metadata.CurrentSnapshotID
+	}
+	request := restCommitTableRequest{
+		Identifier: restTableIdentifier{Namespace: []string{base.Namespace}, Name: base.Name},
+		Requirements: []map[string]interface{}{
+			{"type": "assert-table-uuid", "uuid": base.Metadata.TableUUID},
+			{"type": "assert-ref-snapshot-id", "ref": mainBranch, "snapshot-id": baseSnapshotID},
+			{"type": "assert-current-schema-id", "current-schema-id": base.Metadata.CurrentSchemaID},
+			{"type": "assert-last-assigned-field-id", "last-assigned-field-id": base.Metadata.LastColumnID},
+		},
+		Updates: tableUpdates(base.Metadata, metadata),
+	}
+	if len(request.Updates) == 0 {
+		return base, nil
+	}
+
+	var result restLoadTableResult
+	err = c.do(ctx, http.MethodPost, path+"/"+url.PathEscape(base.Name), request, &result)
+	if statusOf(err) == http.StatusConflict {
+		return nil, ErrCommitFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tableOf(base.Namespace, base.Name, result)
+}
+
+//tableUpdates returns the updates of the rest catalog api changing the base metadata to the metadata
+func tableUpdates(base *TableMetadata, metadata *TableMetadata) []map[string]interface{} {
+	updates := []map[string]interface{}{}
+
+	baseSchemas := map[int]bool{}
+	for _, schema := range base.Schemas {
+		baseSchemas[schema.SchemaID] = true
+	}
+	for _, schema := range metadata.Schemas {
+		if !baseSchemas[schema.SchemaID] {
+			updates = append(updates, map[string]interface{}{"action": "add-schema", "schema": schema, "last-column-id": metadata.LastColumnID})
+		}
+	}
+	if metadata.CurrentSchemaID != base.CurrentSchemaID {
+		schemaID := metadata.CurrentSchemaID
+		if !baseSchemas[schemaID] {
+			//the catalog may assign another id to the schema added
+			schemaID = -1
+		}
+		updates = append(updates, map[string]interface{}{"action": "set-current-schema", "schema-id": schemaID})
+	}
+
+	properties := map[string]string{}
+	for k, v := range metadata.Properties {
+		if baseValue, ok := base.Properties[k]; !ok || baseValue != v {
+			properties[k] = v
+		}
+	}
+	if len(properties) > 0 {
+		updates = append(updates, map[string]interface{}{"action": "set-properties", "updates": properties})
+	}
+
+	baseSnapshots := map[int64]bool{}
+	for _, snapshot := range base.Snapshots {
+		baseSnapshots[snapshot.SnapshotID] = true
+	}
+	for _, snapshot := range metadata.Snapshots {
+		if !baseSnapshots[snapshot.SnapshotID] {
+			updates = append(updates, map[string]interface{}{"action": "add-snapshot", "snapshot": snapshot})
+		}
+	}
+	if current := metadata.CurrentSnapshotID; current != nil && (base.CurrentSnapshotID == nil || *base.CurrentSnapshotID != *current) {
+		updates = append(updates, map[string]interface{}{"action": "set-snapshot-ref", "ref-name": mainBranch, "type": "branch", "snapshot-id": *current})
+	}
+	return updates
+}
+
+func tableOf(namespace string, name string, result restLoadTableResult) (*Table, error) {
+	metadata, err := ParseTableMetadata(result.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &Table{Namespace: namespace, Name: name, MetadataLocation: result.MetadataLocation, Metadata: metadata}, nil
+}
@@ -0,0 +1,181 @@
+package iceberg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+)
+
+const (
+	//LoadFilesHashProperty is the summary property of the snapshots appending load files, with the hash of their locations.
+	//Appending files already appended by a snapshot of the table is skipped, which keeps retried uploads from duplicating rows.
+	LoadFilesHashProperty = "rudder.load-files-hash"
+
+	commitRetries = 4
+)
+
+//updateFunc changes the metadata to commit, and returns whether there was any change
+type updateFunc func(metadata *TableMetadata) (bool, error)
+
+//commit applies the update to the current metadata of the table and commits it, again on the new metadata if the table changed concurrently
+func commit(ctx context.Context, catalog Catalog, table *Table, update updateFunc) (*Table, error) {
+	var err error
+	for attempt := 0; attempt < commitRetries; attempt++ {
+		if attempt > 0 {
+			if table, err = catalog.LoadTable(ctx, table.Namespace, table.Name); err != nil {
+				return nil, err
+			}
+		}
+		metadata := table.Metadata.Clone()
+		changed, err := update(metadata)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			return table, nil
+		}
+		committed, err := catalog.CommitTable(ctx, table, metadata)
+		if errors.Is(err, ErrCommitFailed) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return committed, nil
+	}
+	return nil, fmt.Errorf("iceberg: failed to commit table %s.%s after %d attempts: %w", table.Namespace, table.Name, commitRetries, ErrCommitFailed)
+}
+
+//AddColumns commits a new schema of the table with the columns it doesn't have yet
+func AddColumns(ctx context.Context, catalog Catalog, table *Table, columns map[string]string) (*Table, error) {
+	return commit(ctx, catalog, table, func(metadata *TableMetadata) (bool, error) {
+		return metadata.AddColumns(columns)
+	})
+}
+
+//AppendFiles commits a snapshot of the table adding the data files, so that they are visible to readers all at once
+func AppendFiles(ctx context.Context, catalog Catalog, io FileIO, table *Table, dataFiles []DataFile) (*Table, error) {
+	if len(dataFiles) == 0 {
+		return table, nil
+	}
+	filesHash := dataFilesHash(dataFiles)
+	snapshotID := newSnapshotID()
+	commitUUID := uuid.Must(uuid.NewV4()).String()
+
+	var addedRecords, addedSize int64
+	for _, dataFile := range dataFiles {
+		addedRecords += dataFile.RecordCount
+		addedSize += dataFile.SizeInBytes
+	}
+
+	var manifest *ManifestFile
+	attempt := 0
+	return commit(ctx, catalog, table, func(metadata *TableMetadata) (bool, error) {
+		attempt++
+		for _, snapshot := range metadata.Snapshots {
+			if snapshot.Summary[LoadFilesHashProperty] == filesHash {
+				return false, nil
+			}
+		}
+
+		//the manifest of the files doesn't depend on the metadata, it is written once and listed by every attempt
+		if manifest == nil {
+			data, err := encodeManifest(metadata.CurrentSchema(), snapshotID, dataFiles)
+			if err != nil {
+				return false, fmt.Errorf("iceberg: failed to encode manifest: %w", err)
+			}
+			path := fmt.Sprintf("%s/metadata/%s-m0.avro", metadata.Location, commitUUID)
+			if err := io.Write(ctx, path, data); err != nil {
+				return false, fmt.Errorf("iceberg: failed to write manifest: %w", err)
+			}
+			addedFiles := int32(len(dataFiles))
+			zero, zeroRows := int32(0), int64(0)
+			manifest = &ManifestFile{
+				Path:                   path,
+				Length:                 int64(len(data)),
+				AddedSnapshotID:        &snapshotID,
+				AddedDataFilesCount:    &addedFiles,
+				ExistingDataFilesCount: &zero,
+				DeletedDataFilesCount:  &zero,
+				AddedRowsCount:         &addedRecords,
+				ExistingRowsCount:      &zeroRows,
+				DeletedRowsCount:       &zeroRows,
+			}
+		}
+
+		manifests := []ManifestFile{*manifest}
+		parent := metadata.CurrentSnapshot()
+		summary := map[string]string{
+			"operation":           "append",
+			LoadFilesHashProperty: filesHash,
+			"added-data-files":    strconv.Itoa(len(dataFiles)),
+			"added-records":       strconv.FormatInt(addedRecords, 10),
+			"added-files-size":    strconv.FormatInt(addedSize, 10),
+			"total-data-files":    strconv.Itoa(len(dataFiles)),
+			"total-records":       strconv.FormatInt(addedRecords, 10),
+			"total-files-size":    strconv.FormatInt(addedSize, 10),
+		}
+		snapshot := Snapshot{
+			SnapshotID:  snapshotID,
+			TimestampMs: time.Now().UnixNano() / int64(time.Millisecond),
+			Summary:     summary,
+		}
+		schemaID := metadata.CurrentSchemaID
+		snapshot.SchemaID = &schemaID
+		if parent != nil {
+			parentID := parent.SnapshotID
+			snapshot.ParentSnapshotID = &parentID
+			data, err := io.Read(ctx, parent.ManifestList)
+			if err != nil {
+				return false, fmt.Errorf("iceberg: failed to read manifest list of snapshot %d: %w", parent.SnapshotID, err)
+			}
+			parentManifests, err := decodeManifestList(data)
+			if err != nil {
+				return false, err
+			}
+			manifests = append(manifests, parentManifests...)
+			for _, key := range []string{"total-data-files", "total-records", "total-files-size"} {
+				total, totalErr := strconv.ParseInt(parent.Summary[key], 10, 64)
+				added, addedErr := strconv.ParseInt(summary["added"+key[len("total"):]], 10, 64)
+				if totalErr != nil || addedErr != nil {
+					//totals are optional, and can't be known if the parent doesn't have them
+					delete(summary, key)
+					continue
+				}
+				summary[key] = strconv.FormatInt(total+added, 10)
+			}
+		}
+
+		data, err := encodeManifestList(snapshot, manifests)
+		if err != nil {
+			return false, fmt.Errorf("iceberg: failed to encode manifest list: %w", err)
+		}
+		snapshot.ManifestList = fmt.Sprintf("%s/metadata/snap-%d-%d-%s.avro", metadata.Location, snapshotID, attempt, commitUUID)
+		if err := io.Write(ctx, snapshot.ManifestList, data); err != nil {
+			return false, fmt.Errorf("iceberg: failed to write manifest list: %w", err)
+		}
+		metadata.AddSnapshot(snapshot)
+		return true, nil
+	})
+}
+
+func dataFilesHash(dataFiles []DataFile) string {
+	locations := make([]string, 0, len(dataFiles))
+	for _, dataFile := range dataFiles {
+		locations = append(locations, dataFile.Location)
+	}
+	sort.Strings(locations)
+	hash := sha256.New()
+	for _, location := range locations {
+		hash.Write([]byte(location))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,186 @@
+package schemarepository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/datalake/iceberg"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var (
+	// config
+	TableFormatConfig             = "tableFormat"
+	IcebergCatalogTypeConfig      = "icebergCatalogType"
+	IcebergCatalogURLConfig       = "icebergCatalogURL"
+	IcebergCatalogTokenConfig     = "icebergCatalogToken"
+	IcebergCatalogWarehouseConfig = "icebergCatalogWarehouse"
+
+	TableFormatIceberg        = "iceberg"
+	IcebergFileCatalogType    = "file"
+	IcebergRESTCatalogType    = "rest"
+	icebergDefaultCatalogType = IcebergFileCatalogType
+)
+
+/*
+IcebergSchemaRepository keeps the tables of the namespace as iceberg tables, at the location of their load files in the object storage.
+The load files of every upload are committed to their tables in a single snapshot, which readers see all at once.
+The metadata of the tables is kept by a file catalog next to the data, or by the rest catalog set in the destination config.
+*/
+type IcebergSchemaRepository struct {
+	warehouse     warehouseutils.WarehouseT
+	uploader      warehouseutils.UploaderI
+	catalog       iceberg.Catalog
+	io            iceberg.FileIO
+	objectStorage string
+	storageConfig map[string]interface{}
+}
+
+func NewIcebergSchemaRepository(wh warehouseutils.WarehouseT, uploader warehouseutils.UploaderI) (*IcebergSchemaRepository, error) {
+	objectStorage := warehouseutils.ObjectStorageType(wh.Destination.DestinationDefinition.Name, wh.Destination.Config, uploader.UseRudderStorage())
+	storageConfig := misc.GetObjectStorageConfig(misc.ObjectStorageOptsT{
+		Provider:         objectStorage,
+		Config:           wh.Destination.Config,
+		UseRudderStorage: uploader.UseRudderStorage(),
+	})
+	io, err := iceberg.NewObjectStorageIO(objectStorage, storageConfig)
+	if err != nil {
+		return nil, err
+	}
+	ic := IcebergSchemaRepository{
+		warehouse:     wh,
+		uploader:      uploader,
+		io:            io,
+		objectStorage: objectStorage,
+		storageConfig: storageConfig,
+	}
+
+	catalogType := warehouseutils.GetConfigValue(IcebergCatalogTypeConfig, wh)
+	if catalogType == "" {
+		catalogType = icebergDefaultCatalogType
+	}
+	switch catalogType {
+	case IcebergFileCatalogType:
+		conditionalIO, ok := io.(iceberg.ConditionalFileIO)
+		if !ok {
+			return nil, fmt.Errorf("iceberg file catalog of destination %s needs conditional writes, which %s doesn't support: use a rest catalog", wh.Destination.ID, objectStorage)
+		}
+		ic.catalog = iceberg.NewFileCatalog(conditionalIO, ic.tableLocation)
+	case IcebergRESTCatalogType:
+		catalogURL := warehouseutils.GetConfigValue(IcebergCatalogURLConfig, wh)
+		if catalogURL == "" {
+			return nil, fmt.Errorf("iceberg rest catalog of destination %s has no url", wh.Destination.ID)
+		}
+		client := &http.Client{Timeout: config.GetDuration("Warehouse.datalake.iceberg.catalogTimeout", 30, time.Second)}
+		ic.catalog = iceberg.NewRESTCatalog(catalogURL, warehouseutils.GetConfigValue(IcebergCatalogTokenConfig, wh), warehouseutils.GetConfigValue(IcebergCatalogWarehouseConfig, wh), client)
+	default:
+		return nil, fmt.Errorf("unsupported iceberg catalog type %s of destination %s", catalogType, wh.Destination.ID)
+	}
+	return &ic, nil
+}
+
+//tableLocation returns the uri of the folder of the load files of the table, under the configured prefix of the object storage
+func (ic *IcebergSchemaRepository) tableLocation(namespace string, tableName string) string {
+	key := warehouseutils.GetTablePathInObjectStorage(namespace, tableName)
+	if prefix, _ := ic.storageConfig["prefix"].(string); strings.Trim(prefix, "/") != "" {
+		key = fmt.Sprintf("%s/%s", strings.Trim(prefix, "/"), key)
+	}
+	return ic.io.Location(key)
+}
+
+//FetchSchema returns the schema of the tables of the local schema which exist in the catalog.
+//Tables missing from the local schema are created by the upload, creating them is a no-op for tables which exist.
+func (ic *IcebergSchemaRepository) FetchSchema(warehouse warehouseutils.WarehouseT) (warehouseutils.SchemaT, error) {
+	schema := warehouseutils.SchemaT{}
+	for tableName := range ic.uploader.GetLocalSchema() {
+		table, err := ic.catalog.LoadTable(context.TODO(), warehouse.Namespace, tableName)
+		if errors.Is(err, iceberg.ErrNoSuchTable) {
+			continue
+		}
+		if err != nil {
+			return schema, err
+		}
+		schema[tableName] = table.Metadata.Columns()
+	}
+	return schema, nil
+}
+
+func (ic *IcebergSchemaRepository) CreateSchema() (err error) {
+	return ic.catalog.CreateNamespace(context.TODO(), ic.warehouse.Namespace)
+}
+
+//CreateTable creates the iceberg table, or adds the missing columns if it exists, so that retried uploads succeed
+func (ic *IcebergSchemaRepository) CreateTable(tableName string, columnMap map[string]string) (err error) {
+	ctx := context.TODO()
+	_, err = ic.catalog.CreateTable(ctx, ic.warehouse.Namespace, tableName, ic.tableLocation(ic.warehouse.Namespace, tableName), columnMap)
+	if !errors.Is(err, iceberg.ErrTableExists) {
+		return err
+	}
+	table, err := ic.catalog.LoadTable(ctx, ic.warehouse.Namespace, tableName)
+	if err != nil {
+		return err
+	}
+	_, err = iceberg.AddColumns(ctx, ic.catalog, table, columnMap)
+	return err
+}
+
+func (ic *IcebergSchemaRepository) AddColumn(tableName string, columnName string, columnType string) (err error) {
+	ctx := context.TODO()
+	table, err := ic.catalog.LoadTable(ctx, ic.warehouse.Namespace, tableName)
+	if err != nil {
+		return fmt.Errorf("Failed to add column: %w", err)
+	}
+	_, err = iceberg.AddColumns(ctx, ic.catalog, table, map[string]string{columnName: columnType})
+	return err
+}
+
+//AlterColumn is a no-op, the columns altered by uploads have the same type in iceberg
+func (ic *IcebergSchemaRepository) AlterColumn(tableName string, columnName string, columnType string) (err error) {
+	pkgLogger.Infof("Skipping alter of column %s of iceberg table %s.%s to %s", columnName, ic.warehouse.Namespace, tableName, columnType)
+	return nil
+}
+
+//LoadTable commits the load files of the table in the upload to the iceberg table.
+//The files of an upload are only appended once, loading them again after a failure doesn't duplicate them.
+func (ic *IcebergSchemaRepository) LoadTable(tableName string) error {
+	ctx := context.TODO()
+	var dataFiles []iceberg.DataFile
+	for _, loadFile := range ic.uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName}) {
+		objectName, err := warehouseutils.GetObjectName(loadFile.Location, ic.storageConfig, ic.objectStorage)
+		if err != nil {
+			return err
+		}
+		var metadata struct {
+			ContentLength int64 `json:"content_length"`
+		}
+		if len(loadFile.Metadata) == 0 {
+			return fmt.Errorf("load file %s has no metadata", loadFile.Location)
+		}
+		if err := json.Unmarshal(loadFile.Metadata, &metadata); err != nil {
+			return fmt.Errorf("invalid metadata of load file %s: %w", loadFile.Location, err)
+		}
+		dataFiles = append(dataFiles, iceberg.DataFile{
+			Location:    ic.io.Location(objectName),
+			RecordCount: loadFile.TotalRows,
+			SizeInBytes: metadata.ContentLength,
+		})
+	}
+
+	table, err := ic.catalog.LoadTable(ctx, ic.warehouse.Namespace, tableName)
+	if err != nil {
+		return err
+	}
+	table, err = iceberg.AppendFiles(ctx, ic.catalog, ic.io, table, dataFiles)
+	if err != nil {
+		return err
+	}
+	pkgLogger.Infof("Committed %d load files to iceberg table %s.%s of destination %s: %s", len(dataFiles), ic.warehouse.Namespace, tableName, ic.warehouse.Destination.ID, table.MetadataLocation)
+	return nil
+}
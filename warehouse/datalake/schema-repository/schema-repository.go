@@ -44,7 +44,15 @@ type SchemaRepository interface {
 	AlterColumn(tableName string, columnName string, columnType string) (err error)
 }
 
+//TableLoader is implemented by the schema repositories which load the files of the tables themselves
+type TableLoader interface {
+	LoadTable(tableName string) error
+}
+
 func NewSchemaRepository(wh warehouseutils.WarehouseT, uploader warehouseutils.UploaderI) (SchemaRepository, error) {
+	if warehouseutils.GetConfigValue(TableFormatConfig, wh) == TableFormatIceberg {
+		return NewIcebergSchemaRepository(wh, uploader)
+	}
 	if warehouseutils.GetConfigValueBoolString(UseGlueConfig, wh) == "true" && misc.HasAWSRegionInConfig(wh.Destination.Config) {
 		return NewGlueSchemaRepository(wh)
 	}
@@ -443,6 +443,12 @@ func (dl *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	// Getting sorted column keys from tableSchemaInUpload
 	sortedColumnKeys := warehouseutils.SortColumnKeysFromColumnMap(tableSchemaInUpload)
 
+	// Getting the merge keys of the table, if the destination is in merge load mode
+	mergeKeys, err := warehouseutils.GetMergeKeys(dl.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	// Creating staging table
 	stagingTableName = misc.TruncateStr(fmt.Sprintf(`%s%s_%s`, stagingTablePrefix, strings.ReplaceAll(uuid.Must(uuid.NewV4()).String(), "-", ""), tableName), 127)
 	err = dl.CreateTable(stagingTableName, tableSchemaAfterUpload)
@@ -513,20 +519,31 @@ func (dl *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	if column, ok := primaryKeyMap[tableName]; ok {
 		primaryKey = column
 	}
+	primaryKeys := []string{primaryKey}
+
+	// In merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKeys = mergeKeys
+	}
+	var joinClauses []string
+	for _, key := range primaryKeys {
+		joinClauses = append(joinClauses, fmt.Sprintf(`MAIN.%[1]s = STAGING.%[1]s`, key))
+	}
 
 	// Creating merge sql statement to copy from staging table to the main table
 	sqlStatement = fmt.Sprintf(`MERGE INTO %[1]s.%[2]s AS MAIN
                                        USING ( SELECT * FROM ( SELECT *, row_number() OVER (PARTITION BY %[4]s ORDER BY RECEIVED_AT DESC) AS _rudder_staging_row_number FROM %[1]s.%[3]s ) AS q WHERE _rudder_staging_row_number = 1) AS STAGING
-									   ON MAIN.%[4]s = STAGING.%[4]s
+									   ON %[8]s
 									   WHEN MATCHED THEN UPDATE SET %[5]s
 									   WHEN NOT MATCHED THEN INSERT (%[6]s) VALUES (%[7]s);`,
 		dl.Namespace,
 		tableName,
 		stagingTableName,
-		primaryKey,
+		strings.Join(primaryKeys, ", "),
 		columnsWithValues(sortedColumnKeys),
 		columnNames(sortedColumnKeys),
 		stagingColumnNames(sortedColumnKeys),
+		strings.Join(joinClauses, " AND "),
 	)
 	pkgLogger.Infof("%v Inserting records using staging table with SQL: %s\n", dl.GetLogIdentifier(tableName), sqlStatement)
 
@@ -12,6 +12,7 @@ import (
 	"github.com/rudderlabs/rudder-server/warehouse/postgres"
 	"github.com/rudderlabs/rudder-server/warehouse/redshift"
 	"github.com/rudderlabs/rudder-server/warehouse/snowflake"
+	"github.com/rudderlabs/rudder-server/warehouse/sqlite"
 
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
@@ -68,6 +69,9 @@ func New(destType string) (ManagerI, error) {
 	case warehouseutils.DELTALAKE:
 		var dl deltalake.HandleT
 		return &dl, nil
+	case warehouseutils.SQLITE:
+		var sl sqlite.HandleT
+		return &sl, nil
 	}
 	return nil, fmt.Errorf("Provider of type %s is not configured for WarehouseManager", destType)
 }
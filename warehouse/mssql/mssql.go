@@ -247,6 +247,11 @@ func (ms *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	sortedColumnKeys := warehouseutils.SortColumnKeysFromColumnMap(tableSchemaInUpload)
 	sortedColumnString := strings.Join(sortedColumnKeys, ", ")
 
+	mergeKeys, err := warehouseutils.GetMergeKeys(ms.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	fileNames, err := ms.DownloadLoadFiles(tableName)
 	defer misc.RemoveFilePaths(fileNames...)
 	if err != nil {
@@ -443,6 +448,14 @@ func (ms *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	if tableName == warehouseutils.DiscardsTable {
 		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, ms.Namespace, tableName, "table_name", "column_name")
 	}
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKey = mergeKeys[0]
+		partitionKey = strings.Join(mergeKeys, ", ")
+		for _, key := range mergeKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s"`, ms.Namespace, tableName, key)
+		}
+	}
 	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" FROM "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, ms.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
 	pkgLogger.Infof("MS: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = txn.Exec(sqlStatement)
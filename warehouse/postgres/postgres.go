@@ -226,6 +226,11 @@ func (pg *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	sortedColumnKeys := warehouseutils.SortColumnKeysFromColumnMap(tableSchemaInUpload)
 	sortedColumnString := strings.Join(sortedColumnKeys, ", ")
 
+	mergeKeys, err := warehouseutils.GetMergeKeys(pg.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	fileNames, err := pg.DownloadLoadFiles(tableName)
 	defer misc.RemoveFilePaths(fileNames...)
 	if err != nil {
@@ -335,6 +340,14 @@ func (pg *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	if tableName == warehouseutils.DiscardsTable {
 		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s" AND _source.%[4]s = "%[1]s"."%[2]s"."%[4]s"`, pg.Namespace, tableName, "table_name", "column_name")
 	}
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKey = mergeKeys[0]
+		partitionKey = strings.Join(mergeKeys, ", ")
+		for _, key := range mergeKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND _source.%[3]s = "%[1]s"."%[2]s"."%[3]s"`, pg.Namespace, tableName, key)
+		}
+	}
 	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s"."%[2]s" USING "%[1]s"."%[3]s" as  _source where (_source.%[4]s = "%[1]s"."%[2]s"."%[4]s" %[5]s)`, pg.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
 	pkgLogger.Infof("PG: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
 	_, err = txn.Exec(sqlStatement)
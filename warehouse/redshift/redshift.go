@@ -257,6 +257,11 @@ func (rs *HandleT) dropStagingTables(stagingTableNames []string) {
 }
 
 func (rs *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, tableSchemaAfterUpload warehouseutils.TableSchemaT, skipTempTableDelete bool) (stagingTableName string, err error) {
+	mergeKeys, err := warehouseutils.GetMergeKeys(rs.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	manifestLocation, err := rs.generateManifest(tableName, tableSchemaInUpload)
 	if err != nil {
 		return
@@ -345,6 +350,14 @@ func (rs *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 	if tableName == warehouseutils.DiscardsTable {
 		additionalJoinClause = fmt.Sprintf(`AND _source.%[3]s = %[1]s.%[2]s.%[3]s AND _source.%[4]s = %[1]s.%[2]s.%[4]s`, rs.Namespace, tableName, "table_name", "column_name")
 	}
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKey = mergeKeys[0]
+		partitionKey = strings.Join(mergeKeys, ", ")
+		for _, key := range mergeKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND _source.%[3]s = %[1]s.%[2]s.%[3]s`, rs.Namespace, tableName, key)
+		}
+	}
 
 	sqlStatement = fmt.Sprintf(`DELETE FROM %[1]s."%[2]s" using %[1]s."%[3]s" _source where (_source.%[4]s = %[1]s.%[2]s.%[4]s %[5]s)`, rs.Namespace, tableName, stagingTableName, primaryKey, additionalJoinClause)
 	pkgLogger.Infof("RS: Dedup records for table:%s using staging table: %s\n", tableName, sqlStatement)
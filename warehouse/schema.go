@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 
+	"github.com/rudderlabs/rudder-server/services/stats"
 	"github.com/rudderlabs/rudder-server/utils/misc"
 	"github.com/rudderlabs/rudder-server/utils/timeutil"
 	"github.com/rudderlabs/rudder-server/warehouse/manager"
@@ -226,8 +228,12 @@ func (sh *SchemaHandleT) isIDResolutionEnabled() bool {
 	return warehouseutils.IDResolutionEnabled() && misc.ContainsString(warehouseutils.IdentityEnabledWarehouses, sh.warehouse.Type)
 }
 
-func (sh *SchemaHandleT) consolidateStagingFilesSchemaUsingWarehouseSchema() warehouseutils.SchemaT {
+func (sh *SchemaHandleT) consolidateStagingFilesSchemaUsingWarehouseSchema() (warehouseutils.SchemaT, error) {
 	schemaInLocalDB := sh.localSchema
+	schemaPolicy, err := warehouseutils.GetSchemaPolicy(sh.warehouse.Destination.Config)
+	if err != nil {
+		return nil, err
+	}
 
 	consolidatedSchema := warehouseutils.SchemaT{}
 	count := 0
@@ -273,6 +279,16 @@ func (sh *SchemaHandleT) consolidateStagingFilesSchemaUsingWarehouseSchema() war
 		}
 	}
 
+	excludedColumns, unhashableColumns := applySchemaPolicy(schemaPolicy, consolidatedSchema, schemaInLocalDB)
+	for tableName, columns := range excludedColumns {
+		pkgLogger.Warnf("[WH]: Schema policy excluded the columns %v of table %s from upload for %s", columns, tableName, sh.warehouse.Identifier)
+		warehouseutils.DestStat(stats.CountType, "schema_policy_excluded_columns", sh.warehouse.Destination.ID).Count(len(columns))
+	}
+	for tableName, columns := range unhashableColumns {
+		pkgLogger.Errorf("[WH]: Schema policy hashes the columns %v of table %s, which are not strings in the warehouse, excluded them from upload for %s", columns, tableName, sh.warehouse.Identifier)
+		warehouseutils.DestStat(stats.CountType, "schema_policy_unhashable_columns", sh.warehouse.Destination.ID).Count(len(columns))
+	}
+
 	// add rudder_discards Schema
 	consolidatedSchema[sh.safeName(warehouseutils.DiscardsTable)] = sh.getDiscardsSchema()
 
@@ -284,7 +300,77 @@ func (sh *SchemaHandleT) consolidateStagingFilesSchemaUsingWarehouseSchema() war
 		}
 	}
 
-	return consolidatedSchema
+	return consolidatedSchema, nil
+}
+
+/*
+applySchemaPolicy enforces the schema policy of the destination on the schema of the upload, returning the columns it excluded by table.
+The columns which are denied, not allowed, dropped or above the max column count of their table are removed,
+the new columns get their locked type and hashed columns are strings. Columns already in the warehouse keep their type.
+Hashed columns which are already in the warehouse with another type than string are removed and returned as unhashable,
+as their hashes would be discarded on every upload.
+*/
+func applySchemaPolicy(policy warehouseutils.SchemaPolicyT, uploadSchema, schemaInWarehouse warehouseutils.SchemaT) (excludedColumns, unhashableColumns map[string][]string) {
+	excludedColumns, unhashableColumns = map[string][]string{}, map[string][]string{}
+	if policy.IsEmpty() {
+		return
+	}
+	for tableName, columnMap := range uploadSchema {
+		if !warehouseutils.IsPolicyTable(tableName) {
+			continue
+		}
+		var newColumns []string
+		for columnName := range columnMap {
+			if policy.IsColumnExcluded(tableName, columnName) {
+				delete(columnMap, columnName)
+				excludedColumns[tableName] = append(excludedColumns[tableName], columnName)
+				continue
+			}
+			action, isPII := policy.PIIAction(tableName, columnName)
+			isHashed := isPII && action == warehouseutils.HashPIIAction
+			if columnType, ok := schemaInWarehouse[tableName][columnName]; ok {
+				if isHashed && !warehouseutils.IsHashableColumnType(columnType) {
+					delete(columnMap, columnName)
+					unhashableColumns[tableName] = append(unhashableColumns[tableName], columnName)
+				}
+				continue
+			}
+			newColumns = append(newColumns, columnName)
+			if isHashed {
+				columnMap[columnName] = "string"
+			} else if lockedType, ok := policy.LockedColumnType(tableName, columnName); ok {
+				columnMap[columnName] = lockedType
+			}
+		}
+
+		if policy.MaxColumnCount == 0 {
+			continue
+		}
+		// new columns are added in order of their names, with the columns needed to load the table first, until the table has max columns
+		sort.Slice(newColumns, func(i, j int) bool {
+			iProtected, jProtected := warehouseutils.IsPolicyProtectedColumn(newColumns[i]), warehouseutils.IsPolicyProtectedColumn(newColumns[j])
+			if iProtected != jProtected {
+				return iProtected
+			}
+			return newColumns[i] < newColumns[j]
+		})
+		columnCount := len(schemaInWarehouse[tableName])
+		for _, columnName := range newColumns {
+			if columnCount < policy.MaxColumnCount || warehouseutils.IsPolicyProtectedColumn(columnName) {
+				columnCount++
+				continue
+			}
+			delete(columnMap, columnName)
+			excludedColumns[tableName] = append(excludedColumns[tableName], columnName)
+		}
+	}
+	for tableName := range excludedColumns {
+		sort.Strings(excludedColumns[tableName])
+	}
+	for tableName := range unhashableColumns {
+		sort.Strings(unhashableColumns[tableName])
+	}
+	return
 }
 
 // hasSchemaChanged Default behaviour is to do the deep equals.
@@ -0,0 +1,78 @@
+package warehouse
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+var _ = Describe("Schema", func() {
+	Describe("applySchemaPolicy", func() {
+		var policy warehouseutils.SchemaPolicyT
+		BeforeEach(func() {
+			var err error
+			policy, err = warehouseutils.GetSchemaPolicy(map[string]interface{}{
+				warehouseutils.SchemaPolicyConfig: `{
+					"lockedColumnTypes": {"tracks": {"revenue": "float", "plan": "string"}},
+					"deniedColumns": {"*": ["context_ip"]},
+					"piiColumns": {"*": {"email": "hash", "phone": "drop"}}
+				}`,
+				warehouseutils.PIIHashSecretConfig: "secret",
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should not change the schema without a policy", func() {
+			uploadSchema := warehouseutils.SchemaT{"tracks": {"id": "string", "context_ip": "string"}}
+			excludedColumns, _ := applySchemaPolicy(warehouseutils.SchemaPolicyT{}, uploadSchema, warehouseutils.SchemaT{})
+			Expect(excludedColumns).To(BeEmpty())
+			Expect(uploadSchema).To(Equal(warehouseutils.SchemaT{"tracks": {"id": "string", "context_ip": "string"}}))
+		})
+
+		It("should exclude, hash and lock the columns of the upload", func() {
+			uploadSchema := warehouseutils.SchemaT{
+				"tracks":                     {"id": "string", "revenue": "int", "plan": "int", "email": "string", "phone": "int", "context_ip": "string"},
+				"identifies":                 {"id": "string", "email": "int"},
+				warehouseutils.DiscardsTable: {"column_name": "string", "phone": "string"},
+			}
+			schemaInWarehouse := warehouseutils.SchemaT{"tracks": {"plan": "int"}}
+			excludedColumns, unhashableColumns := applySchemaPolicy(policy, uploadSchema, schemaInWarehouse)
+			Expect(excludedColumns).To(Equal(map[string][]string{"tracks": {"context_ip", "phone"}}))
+			Expect(unhashableColumns).To(BeEmpty())
+			Expect(uploadSchema).To(Equal(warehouseutils.SchemaT{
+				"tracks":                     {"id": "string", "revenue": "float", "plan": "int", "email": "string"},
+				"identifies":                 {"id": "string", "email": "string"},
+				warehouseutils.DiscardsTable: {"column_name": "string", "phone": "string"},
+			}))
+		})
+
+		It("should only add new columns up to the max column count of the table", func() {
+			policy.MaxColumnCount = 4
+			uploadSchema := warehouseutils.SchemaT{
+				"tracks": {"id": "string", "received_at": "datetime", "b_prop": "string", "a_prop": "string", "c_prop": "string", "existing": "string"},
+				"pages":  {"id": "string", "received_at": "datetime", "a_prop": "string"},
+			}
+			schemaInWarehouse := warehouseutils.SchemaT{"tracks": {"existing": "string", "other": "string"}}
+			excludedColumns, _ := applySchemaPolicy(policy, uploadSchema, schemaInWarehouse)
+			Expect(excludedColumns).To(Equal(map[string][]string{"tracks": {"a_prop", "b_prop", "c_prop"}}))
+			Expect(uploadSchema["tracks"]).To(Equal(map[string]string{"id": "string", "received_at": "datetime", "existing": "string"}))
+			Expect(uploadSchema["pages"]).To(HaveLen(3))
+		})
+
+		It("should exclude hashed columns which are not strings in the warehouse", func() {
+			uploadSchema := warehouseutils.SchemaT{
+				"tracks":     {"id": "string", "email": "string"},
+				"identifies": {"id": "string", "email": "string"},
+			}
+			schemaInWarehouse := warehouseutils.SchemaT{"tracks": {"email": "int"}, "identifies": {"email": "string"}}
+			excludedColumns, unhashableColumns := applySchemaPolicy(policy, uploadSchema, schemaInWarehouse)
+			Expect(excludedColumns).To(BeEmpty())
+			Expect(unhashableColumns).To(Equal(map[string][]string{"tracks": {"email"}}))
+			Expect(uploadSchema).To(Equal(warehouseutils.SchemaT{
+				"tracks":     {"id": "string"},
+				"identifies": {"id": "string", "email": "string"},
+			}))
+		})
+	})
+})
@@ -360,6 +360,10 @@ func processStagingFile(job PayloadT, workerIndex int) (loadFileUploadOutputs []
 	}
 
 	sortedTableColumnMap := job.getSortedColumnMapForAllTables()
+	schemaPolicy, err := warehouseutils.GetSchemaPolicy(job.DestinationConfig)
+	if err != nil {
+		return loadFileUploadOutputs, err
+	}
 
 	reader, endOfFile := jobRun.setStagingFileReader()
 	if endOfFile {
@@ -423,13 +427,23 @@ func processStagingFile(job PayloadT, workerIndex int) (loadFileUploadOutputs []
 				continue
 			}
 			columnInfo, ok := batchRouterEvent.GetColumnInfo(columnName)
-			if !ok {
+			if !ok || schemaPolicy.IsColumnExcluded(tableName, columnName) {
 				eventLoader.AddEmptyColumn(columnName)
 				continue
 			}
 			columnType := columnInfo.ColumnType
 			columnVal := columnInfo.ColumnVal
 
+			// hash pii before any type handling, so its clear text isn't written to the table or the discards
+			if action, ok := schemaPolicy.PIIAction(tableName, columnName); ok && action == warehouseutils.HashPIIAction {
+				if columnVal == nil {
+					eventLoader.AddEmptyColumn(columnName)
+					continue
+				}
+				columnType = "string"
+				columnVal = schemaPolicy.HashColumnValue(columnVal)
+			}
+
 			if columnType == "int" || columnType == "bigint" {
 				floatVal, ok := columnVal.(float64)
 				if !ok {
@@ -0,0 +1,115 @@
+package warehouse
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/services/stats"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+func TestProcessStagingFileSchemaPolicy(t *testing.T) {
+	minio := testutils.SetupMinio(t)
+	config.Load()
+	logger.Init()
+	stats.Setup()
+	loadConfig()
+	if pkgLogger == nil {
+		pkgLogger = logger.NewLogger().Child("warehouse")
+	}
+
+	destinationConfig := map[string]interface{}{
+		"bucketProvider":  "MINIO",
+		"bucketName":      "staging",
+		"endPoint":        minio.Endpoint,
+		"accessKeyID":     minio.AccessKeyID,
+		"secretAccessKey": minio.SecretAccessKey,
+		"useSSL":          false,
+		warehouseutils.SchemaPolicyConfig: map[string]interface{}{
+			"piiColumns": map[string]interface{}{"*": map[string]interface{}{"email": "hash", "phone": "drop"}},
+		},
+		warehouseutils.PIIHashSecretConfig: "secret",
+	}
+	fileManager, err := filemanager.New(&filemanager.SettingsT{Provider: "MINIO", Config: destinationConfig})
+	require.NoError(t, err)
+
+	// tracks loads the hashed email, identifies has an int email column so the hashed email is discarded
+	stagingFilePath := filepath.Join(t.TempDir(), "staging.json.gz")
+	stagingFile, err := os.Create(stagingFilePath)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(stagingFile)
+	for _, line := range []string{
+		`{"metadata":{"table":"tracks","columns":{"id":"string","received_at":"datetime","email":"string","phone":"int"}},"data":{"id":"1","received_at":"2022-04-01T10:00:00.000Z","email":"user@example.com","phone":14155550100}}`,
+		`{"metadata":{"table":"identifies","columns":{"id":"string","received_at":"datetime","email":"string"}},"data":{"id":"2","received_at":"2022-04-01T10:00:00.000Z","email":"user@example.com"}}`,
+	} {
+		_, err = gzipWriter.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, stagingFile.Close())
+	stagingFile, err = os.Open(stagingFilePath)
+	require.NoError(t, err)
+	uploadOutput, err := fileManager.Upload(context.Background(), stagingFile, "rudder-warehouse-staging-logs")
+	require.NoError(t, stagingFile.Close())
+	require.NoError(t, err)
+
+	job := PayloadT{
+		UploadID:            1,
+		StagingFileID:       1,
+		StagingFileLocation: uploadOutput.ObjectName,
+		UploadSchema: map[string]map[string]string{
+			"tracks":                     {"id": "string", "received_at": "datetime", "email": "string", "phone": "int", "uuid_ts": "datetime"},
+			"identifies":                 {"id": "string", "received_at": "datetime", "email": "int", "uuid_ts": "datetime"},
+			warehouseutils.DiscardsTable: warehouseutils.DiscardsSchema,
+		},
+		SourceID:          "source",
+		DestinationID:     "destination",
+		DestinationType:   warehouseutils.POSTGRES,
+		DestinationConfig: destinationConfig,
+		LoadFileType:      warehouseutils.LOAD_FILE_TYPE_CSV,
+	}
+	loadFileUploadOutputs, err := processStagingFile(job, 0)
+	require.NoError(t, err)
+
+	policy, err := warehouseutils.GetSchemaPolicy(destinationConfig)
+	require.NoError(t, err)
+	hashedEmail := policy.HashColumnValue("user@example.com")
+	loadFiles := map[string]string{}
+	for _, output := range loadFileUploadOutputs {
+		objectName, err := fileManager.GetObjectNameFromLocation(output.Location)
+		require.NoError(t, err)
+		loadFile, err := os.Create(filepath.Join(t.TempDir(), output.TableName+".csv.gz"))
+		require.NoError(t, err)
+		require.NoError(t, fileManager.Download(context.Background(), loadFile, objectName))
+		require.NoError(t, loadFile.Close())
+		// the file is downloaded to its path, read it from there
+		loadFile, err = os.Open(loadFile.Name())
+		require.NoError(t, err)
+		gzipReader, err := gzip.NewReader(loadFile)
+		require.NoError(t, err)
+		content, err := io.ReadAll(gzipReader)
+		require.NoError(t, err)
+		require.NoError(t, loadFile.Close())
+		loadFiles[output.TableName] = string(content)
+	}
+
+	require.Contains(t, loadFiles, "tracks")
+	require.Contains(t, loadFiles, warehouseutils.DiscardsTable)
+	require.Contains(t, loadFiles["tracks"], hashedEmail)
+	require.Contains(t, loadFiles[warehouseutils.DiscardsTable], hashedEmail, "the hashed email of identifies is discarded")
+	for tableName, content := range loadFiles {
+		require.False(t, strings.Contains(content, "user@example.com"), "clear email in the load file of %s", tableName)
+		require.False(t, strings.Contains(content, "14155550100"), "clear phone in the load file of %s", tableName)
+	}
+}
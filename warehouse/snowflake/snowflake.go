@@ -228,6 +228,11 @@ func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 		defer dbHandle.Close()
 	}
 
+	mergeKeys, err := warehouseutils.GetMergeKeys(sf.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
 	// sort column names
 	keys := reflect.ValueOf(tableSchemaInUpload).MapKeys()
 	strkeys := make([]string, len(keys))
@@ -310,6 +315,20 @@ func (sf *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutil
 
 	keepLatestRecordOnDedup := sf.Uploader.ShouldOnDedupUseNewRecord()
 
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		var quotedKeys []string
+		for _, key := range mergeKeys {
+			quotedKeys = append(quotedKeys, fmt.Sprintf(`"%s"`, warehouseutils.ToProviderCase(warehouseutils.SNOWFLAKE, key)))
+		}
+		primaryKey = warehouseutils.ToProviderCase(warehouseutils.SNOWFLAKE, mergeKeys[0])
+		partitionKey = strings.Join(quotedKeys, ", ")
+		for _, key := range quotedKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND original.%[1]s = staging.%[1]s`, key)
+		}
+		keepLatestRecordOnDedup = true
+	}
+
 	if keepLatestRecordOnDedup {
 		sqlStatement = fmt.Sprintf(`MERGE INTO "%[1]s" AS original
 									USING (
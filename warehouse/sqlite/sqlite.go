@@ -0,0 +1,965 @@
+// Package sqlite is an embedded warehouse keeping every namespace of a destination in a SQLite database file,
+// so that small setups and tests can load events without any warehouse infrastructure.
+//
+// The database files are kept in a persistent directory on local disk, or in the object storage of the destination.
+// The database files kept in object storage are downloaded before loading, and uploaded back after every change.
+// The load files are gzipped csv files, or parquet files if Warehouse.sqlite.useParquetLoadFiles is set.
+package sqlite
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	uuid "github.com/gofrs/uuid"
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/services/filemanager"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/warehouse/client"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	stagingTablePrefix            string
+	pkgLogger                     logger.LoggerI
+	skipComputingUserLatestTraits bool
+	databaseDir                   string
+	busyTimeout                   time.Duration
+)
+
+const (
+	//databasePath is the directory of the database files of the destination, one per namespace
+	databasePath = "databasePath"
+	//databaseObjectStoragePath is the path of the database files of the destination in its object storage, if they are kept there
+	databaseObjectStoragePath = "databaseObjectStoragePath"
+	//maxDatabaseObjectsListed is the number of objects listed in the object storage directory of the destination to find a database file
+	maxDatabaseObjectsListed = 1000
+)
+
+//The declared types of the columns. SQLite only has storage classes, but keeps the declared types,
+//which also give the columns their affinity: JSON_TEXT has the text affinity, so that json numbers are kept as they are
+var rudderDataTypesMapToSQLite = map[string]string{
+	"int":      "INTEGER",
+	"float":    "REAL",
+	"string":   "TEXT",
+	"datetime": "DATETIME",
+	"boolean":  "BOOLEAN",
+	"json":     "JSON_TEXT",
+}
+
+var sqliteDataTypesMapToRudder = map[string]string{
+	"INTEGER":   "int",
+	"INT":       "int",
+	"BIGINT":    "int",
+	"REAL":      "float",
+	"DOUBLE":    "float",
+	"NUMERIC":   "float",
+	"TEXT":      "string",
+	"VARCHAR":   "string",
+	"DATETIME":  "datetime",
+	"TIMESTAMP": "datetime",
+	"BOOLEAN":   "boolean",
+	"JSON_TEXT": "json",
+	"JSON":      "json",
+}
+
+type HandleT struct {
+	Db            *sql.DB
+	Namespace     string
+	ObjectStorage string
+	Warehouse     warehouseutils.WarehouseT
+	Uploader      warehouseutils.UploaderI
+
+	//downloadLoadFiles downloads the load files of a table, DownloadLoadFiles unless overridden in tests
+	downloadLoadFiles func(tableName string) ([]string, error)
+}
+
+var primaryKeyMap = map[string]string{
+	warehouseutils.UsersTable:      "id",
+	warehouseutils.IdentifiesTable: "id",
+	warehouseutils.DiscardsTable:   "row_id",
+}
+var partitionKeyMap = map[string]string{
+	warehouseutils.UsersTable:      "id",
+	warehouseutils.IdentifiesTable: "id",
+	warehouseutils.DiscardsTable:   "row_id, column_name, table_name",
+}
+
+func Init() {
+	loadConfig()
+	pkgLogger = logger.NewLogger().Child("warehouse").Child("sqlite")
+}
+
+func loadConfig() {
+	stagingTablePrefix = "rudder_staging_"
+	config.RegisterBoolConfigVariable(false, &skipComputingUserLatestTraits, true, "Warehouse.sqlite.skipComputingUserLatestTraits")
+	config.RegisterStringConfigVariable("", &databaseDir, false, "Warehouse.sqlite.databaseDir")
+	config.RegisterDurationConfigVariable(time.Duration(30), &busyTimeout, true, time.Second, "Warehouse.sqlite.busyTimeout")
+}
+
+//objectStoragePathOf returns the path of the database files of the warehouse in its object storage, empty if they are kept on local disk
+func objectStoragePathOf(warehouse warehouseutils.WarehouseT) string {
+	return strings.Trim(warehouseutils.GetConfigValue(databaseObjectStoragePath, warehouse), "/")
+}
+
+//databaseDirOf returns the directory of the database files of the warehouse. It is the databasePath of the destination config,
+//falling back to a directory per destination in Warehouse.sqlite.databaseDir.
+//The database files kept in object storage are only copied there while they are used, in the tmp directory if neither is set.
+//Otherwise the directory must be persistent, so there is no fallback
+func databaseDirOf(warehouse warehouseutils.WarehouseT) (string, error) {
+	if dir := warehouseutils.GetConfigValue(databasePath, warehouse); dir != "" {
+		return dir, nil
+	}
+	dir := databaseDir
+	if dir == "" {
+		if objectStoragePathOf(warehouse) == "" {
+			return "", fmt.Errorf("no persistent path for the database files of destination %s: set the %s or %s of the destination, or Warehouse.sqlite.databaseDir", warehouse.Destination.ID, databasePath, databaseObjectStoragePath)
+		}
+		tmpDirPath, err := misc.CreateTMPDIR()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(tmpDirPath, "rudder-warehouse-sqlite")
+	}
+	return filepath.Join(dir, warehouse.Destination.ID), nil
+}
+
+//databaseFileOf returns the database file of the namespace of the warehouse
+func databaseFileOf(warehouse warehouseutils.WarehouseT) (string, error) {
+	dir, err := databaseDirOf(warehouse)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, warehouse.Namespace+".db"), nil
+}
+
+//Connect opens the database file, creating it if it doesn't exist
+func Connect(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite connection error : (%v)", err)
+	}
+	//sqlite serializes writes anyway, and the pragmas are per connection
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{fmt.Sprintf(`PRAGMA busy_timeout = %d`, busyTimeout.Milliseconds()), `PRAGMA journal_mode = WAL`} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("sqlite connection error : (%v)", err)
+		}
+	}
+	return db, nil
+}
+
+//connect opens the database file of the namespace, downloading it first if it is kept in object storage
+func (sl *HandleT) connect() (*sql.DB, error) {
+	path, err := databaseFileOf(sl.Warehouse)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if objectStoragePathOf(sl.Warehouse) != "" {
+		if err := sl.downloadDatabase(path); err != nil {
+			return nil, err
+		}
+	}
+	return Connect(path)
+}
+
+//fileManager returns the file manager of the object storage of the destination, or of the rudder storage
+func (sl *HandleT) fileManager(useRudderStorage bool) (filemanager.FileManager, error) {
+	storageProvider := warehouseutils.ObjectStorageType(sl.Warehouse.Destination.DestinationDefinition.Name, sl.Warehouse.Destination.Config, useRudderStorage)
+	return filemanager.New(&filemanager.SettingsT{
+		Provider: storageProvider,
+		Config: misc.GetObjectStorageConfig(misc.ObjectStorageOptsT{
+			Provider:         storageProvider,
+			Config:           sl.Warehouse.Destination.Config,
+			UseRudderStorage: useRudderStorage,
+		}),
+	})
+}
+
+//databaseObjectKey returns the key of the database file of the namespace in the object storage of the destination,
+//as uploaded by uploadDatabase
+func (sl *HandleT) databaseObjectKey(fm filemanager.FileManager) string {
+	var parts []string
+	if prefix := strings.Trim(fm.GetConfiguredPrefix(), "/"); prefix != "" {
+		parts = append(parts, prefix)
+	}
+	parts = append(parts, objectStoragePathOf(sl.Warehouse), sl.Warehouse.Destination.ID, sl.Warehouse.Namespace+".db")
+	return strings.Join(parts, "/")
+}
+
+//downloadDatabase replaces the file at path by the database file of the namespace kept in object storage, if there is one yet
+func (sl *HandleT) downloadDatabase(path string) error {
+	for _, file := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	fm, err := sl.fileManager(false)
+	if err != nil {
+		return err
+	}
+	key := sl.databaseObjectKey(fm)
+	//listing the directory of the destination, as some file managers only list the objects after the prefix
+	objects, err := fm.ListFilesWithPrefix(context.TODO(), key[:strings.LastIndex(key, "/")+1], maxDatabaseObjectsListed)
+	if err != nil {
+		return fmt.Errorf("listing database file %s in object storage: %w", key, err)
+	}
+	var found bool
+	for _, object := range objects {
+		if object.Key == key {
+			found = true
+			break
+		}
+	}
+	if !found {
+		pkgLogger.Infof("SQLITE: No database file %s in object storage for destination:%s yet", key, sl.Warehouse.Destination.ID)
+		return nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = fm.Download(context.TODO(), file, key)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("downloading database file %s from object storage: %w", key, err)
+	}
+	return nil
+}
+
+//persist uploads the database file of the namespace to object storage after a change, if it is kept there
+func (sl *HandleT) persist() error {
+	if objectStoragePathOf(sl.Warehouse) == "" {
+		return nil
+	}
+	//moving the changes of the write-ahead log into the database file, which is the only one uploaded
+	if _, err := sl.Db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return err
+	}
+	path, err := databaseFileOf(sl.Warehouse)
+	if err != nil {
+		return err
+	}
+	fm, err := sl.fileManager(false)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err = fm.Upload(context.TODO(), file, objectStoragePathOf(sl.Warehouse), sl.Warehouse.Destination.ID); err != nil {
+		pkgLogger.Errorf("SQLITE: Error uploading database file %s to object storage for destination:%s: %v", path, sl.Warehouse.Destination.ID, err)
+		return err
+	}
+	return nil
+}
+
+func ColumnsWithDataTypes(columns map[string]string, prefix string) string {
+	var arr []string
+	for name, dataType := range columns {
+		arr = append(arr, fmt.Sprintf(`"%s%s" %s`, prefix, name, rudderDataTypesMapToSQLite[dataType]))
+	}
+	return strings.Join(arr[:], ",")
+}
+
+func quotedColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = fmt.Sprintf(`"%s"`, column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (sl *HandleT) IsEmpty(warehouse warehouseutils.WarehouseT) (empty bool, err error) {
+	return
+}
+
+func (sl *HandleT) DownloadLoadFiles(tableName string) ([]string, error) {
+	objects := sl.Uploader.GetLoadFilesMetadata(warehouseutils.GetLoadFilesOptionsT{Table: tableName})
+	downloader, err := sl.fileManager(sl.Uploader.UseRudderStorage())
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error in setting up a downloader for destionationID : %s Error : %v", sl.Warehouse.Destination.ID, err)
+		return nil, err
+	}
+	var fileNames []string
+	for _, object := range objects {
+		objectName, err := warehouseutils.GetObjectName(object.Location, sl.Warehouse.Destination.Config, sl.ObjectStorage)
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error in converting object location to object key for table:%s: %s,%v", tableName, object.Location, err)
+			return nil, err
+		}
+		dirName := fmt.Sprintf(`/%s/`, misc.RudderWarehouseLoadUploadsTmp)
+		tmpDirPath, err := misc.CreateTMPDIR()
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error in creating tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return nil, err
+		}
+		objectPath := tmpDirPath + dirName + fmt.Sprintf(`%s_%s_%d/`, sl.Warehouse.Destination.DestinationDefinition.Name, sl.Warehouse.Destination.ID, time.Now().Unix()) + objectName
+		err = os.MkdirAll(filepath.Dir(objectPath), os.ModePerm)
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error in making tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return nil, err
+		}
+		objectFile, err := os.Create(objectPath)
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error in creating file in tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return nil, err
+		}
+		err = downloader.Download(context.TODO(), objectFile, objectName)
+		if err != nil {
+			objectFile.Close()
+			pkgLogger.Errorf("SQLITE: Error in downloading file in tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return nil, err
+		}
+		fileName := objectFile.Name()
+		if err = objectFile.Close(); err != nil {
+			pkgLogger.Errorf("SQLITE: Error in closing downloaded file in tmp directory for downloading load file for table:%s: %s, %v", tableName, object.Location, err)
+			return nil, err
+		}
+		fileNames = append(fileNames, fileName)
+	}
+	return fileNames, nil
+}
+
+func (sl *HandleT) stagingTableName(tableName string) string {
+	return misc.TruncateStr(fmt.Sprintf(`%s%s_%s`, stagingTablePrefix, tableName, strings.ReplaceAll(uuid.Must(uuid.NewV4()).String(), "-", "")), 127)
+}
+
+//loadFileReaderI reads the rows of a load file, as the fields of a csv load file
+type loadFileReaderI interface {
+	Read(columnNames []string) (record []string, err error)
+	Close() error
+}
+
+//gzipCSVReaderT reads the rows of a gzipped csv load file
+type gzipCSVReaderT struct {
+	file       *os.File
+	gzipReader *gzip.Reader
+	csvReader  *csv.Reader
+}
+
+func (r *gzipCSVReaderT) Read(columnNames []string) (record []string, err error) {
+	return r.csvReader.Read()
+}
+
+func (r *gzipCSVReaderT) Close() error {
+	r.gzipReader.Close()
+	return r.file.Close()
+}
+
+//openLoadFile opens a load file of the upload, a parquet file or a gzipped csv file depending on the load file type of the upload
+func (sl *HandleT) openLoadFile(path string) (loadFileReaderI, error) {
+	if sl.Uploader.GetLoadFileType() == warehouseutils.LOAD_FILE_TYPE_PARQUET {
+		parquetReader, err := warehouseutils.NewParquetReader(path)
+		if err != nil {
+			return nil, err
+		}
+		return parquetReader, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipCSVReaderT{file: file, gzipReader: gzipReader, csvReader: csv.NewReader(gzipReader)}, nil
+}
+
+//csvValue returns the value of a csv load file field to insert in a column of the type
+func csvValue(value string, columnType string) interface{} {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	if columnType == "boolean" {
+		switch value {
+		case "true":
+			return 1
+		case "false":
+			return 0
+		}
+	}
+	return value
+}
+
+func (sl *HandleT) loadTable(tableName string, tableSchemaInUpload warehouseutils.TableSchemaT, skipTempTableDelete bool) (stagingTableName string, err error) {
+	pkgLogger.Infof("SQLITE: Starting load for table:%s", tableName)
+
+	// sort column names
+	sortedColumnKeys := warehouseutils.SortColumnKeysFromColumnMap(tableSchemaInUpload)
+	sortedColumnString := quotedColumns(sortedColumnKeys)
+
+	mergeKeys, err := warehouseutils.GetMergeKeys(sl.Warehouse, tableName, tableSchemaInUpload)
+	if err != nil {
+		return
+	}
+
+	download := sl.downloadLoadFiles
+	if download == nil {
+		download = sl.DownloadLoadFiles
+	}
+	fileNames, err := download(tableName)
+	defer misc.RemoveFilePaths(fileNames...)
+	if err != nil {
+		return
+	}
+
+	txn, err := sl.Db.Begin()
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error while beginning a transaction in db for loading in table:%s: %v", tableName, err)
+		return
+	}
+	// create staging table, without rows but with the columns of the table
+	stagingTableName = sl.stagingTableName(tableName)
+	sqlStatement := fmt.Sprintf(`CREATE TABLE "%s" AS SELECT * FROM "%s" WHERE 0`, stagingTableName, tableName)
+	pkgLogger.Debugf("SQLITE: Creating staging table for table:%s at %s\n", tableName, sqlStatement)
+	_, err = txn.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error creating staging table for table:%s: %v\n", tableName, err)
+		txn.Rollback()
+		return
+	}
+	if !skipTempTableDelete {
+		defer sl.dropStagingTable(stagingTableName)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(sortedColumnKeys)), ", ")
+	stmt, err := txn.Prepare(fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s)`, stagingTableName, sortedColumnString, placeholders))
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error while preparing statement for transaction in db for loading in staging table:%s: %v", stagingTableName, err)
+		txn.Rollback()
+		return
+	}
+	defer stmt.Close()
+	for _, objectFileName := range fileNames {
+		var loadFileReader loadFileReaderI
+		loadFileReader, err = sl.openLoadFile(objectFileName)
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error opening load file:%s while loading to table %s: %v", objectFileName, tableName, err)
+			txn.Rollback()
+			return
+		}
+		var rowsProcessedCount int
+		for {
+			var record []string
+			record, err = loadFileReader.Read(sortedColumnKeys)
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+					pkgLogger.Debugf("SQLITE: File reading completed while reading load file for loading in staging table:%s: %s", stagingTableName, objectFileName)
+					break
+				}
+				pkgLogger.Errorf("SQLITE: Error while reading load file %s for loading in staging table:%s: %v", objectFileName, stagingTableName, err)
+				loadFileReader.Close()
+				txn.Rollback()
+				return
+			}
+			if len(sortedColumnKeys) != len(record) {
+				err = fmt.Errorf(`Load file CSV columns for a row mismatch number found in upload schema. Columns in CSV row: %d, Columns in upload schema of table-%s: %d. Processed rows in csv file until mismatch: %d`, len(record), tableName, len(sortedColumnKeys), rowsProcessedCount)
+				pkgLogger.Error(err)
+				loadFileReader.Close()
+				txn.Rollback()
+				return
+			}
+			recordInterface := make([]interface{}, len(record))
+			for i, value := range record {
+				recordInterface[i] = csvValue(value, tableSchemaInUpload[sortedColumnKeys[i]])
+			}
+			_, err = stmt.Exec(recordInterface...)
+			if err != nil {
+				pkgLogger.Errorf("SQLITE: Error in exec statement for loading in staging table:%s: %v", stagingTableName, err)
+				loadFileReader.Close()
+				txn.Rollback()
+				return
+			}
+			rowsProcessedCount++
+		}
+		loadFileReader.Close()
+	}
+
+	// deduplication process
+	primaryKey := "id"
+	if column, ok := primaryKeyMap[tableName]; ok {
+		primaryKey = column
+	}
+	partitionKey := "id"
+	if column, ok := partitionKeyMap[tableName]; ok {
+		partitionKey = column
+	}
+	var additionalJoinClause string
+	if tableName == warehouseutils.DiscardsTable {
+		additionalJoinClause = fmt.Sprintf(`AND _source.%[2]s = "%[1]s".%[2]s AND _source.%[3]s = "%[1]s".%[3]s`, tableName, "table_name", "column_name")
+	}
+	// in merge mode, rows are replaced by the rows with the same merge key
+	if len(mergeKeys) > 0 {
+		primaryKey = mergeKeys[0]
+		partitionKey = strings.Join(mergeKeys, ", ")
+		for _, key := range mergeKeys[1:] {
+			additionalJoinClause += fmt.Sprintf(` AND _source.%[2]s = "%[1]s".%[2]s`, tableName, key)
+		}
+	}
+	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s" WHERE EXISTS (SELECT 1 FROM "%[2]s" AS _source WHERE _source.%[3]s = "%[1]s".%[3]s %[4]s)`, tableName, stagingTableName, primaryKey, additionalJoinClause)
+	pkgLogger.Infof("SQLITE: Deduplicate records for table:%s using staging table: %s\n", tableName, sqlStatement)
+	_, err = txn.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error deleting from original table for dedup: %v\n", err)
+		txn.Rollback()
+		return
+	}
+	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s" (%[2]s) SELECT %[2]s FROM ( SELECT *, row_number() OVER (PARTITION BY %[4]s ORDER BY received_at DESC) AS _rudder_staging_row_number FROM "%[3]s" ) AS _ WHERE _rudder_staging_row_number = 1`, tableName, sortedColumnString, stagingTableName, partitionKey)
+	pkgLogger.Infof("SQLITE: Inserting records for table:%s using staging table: %s\n", tableName, sqlStatement)
+	_, err = txn.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error inserting into original table: %v\n", err)
+		txn.Rollback()
+		return
+	}
+
+	if err = txn.Commit(); err != nil {
+		pkgLogger.Errorf("SQLITE: Error while committing transaction as there was error while loading staging table:%s: %v", stagingTableName, err)
+		txn.Rollback()
+		return
+	}
+
+	pkgLogger.Infof("SQLITE: Complete load for table:%s", tableName)
+	return
+}
+
+func (sl *HandleT) loadUserTables() (errorMap map[string]error) {
+	errorMap = map[string]error{warehouseutils.IdentifiesTable: nil}
+	pkgLogger.Infof("SQLITE: Starting load for identifies and users tables\n")
+	identifyStagingTable, err := sl.loadTable(warehouseutils.IdentifiesTable, sl.Uploader.GetTableSchemaInUpload(warehouseutils.IdentifiesTable), true)
+	defer sl.dropStagingTable(identifyStagingTable)
+	if err != nil {
+		errorMap[warehouseutils.IdentifiesTable] = err
+		return
+	}
+
+	if len(sl.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable)) == 0 {
+		return
+	}
+	errorMap[warehouseutils.UsersTable] = nil
+
+	if skipComputingUserLatestTraits {
+		_, err := sl.loadTable(warehouseutils.UsersTable, sl.Uploader.GetTableSchemaInUpload(warehouseutils.UsersTable), false)
+		if err != nil {
+			errorMap[warehouseutils.UsersTable] = err
+		}
+		return
+	}
+
+	unionStagingTableName := sl.stagingTableName("users_identifies_union")
+	stagingTableName := sl.stagingTableName(warehouseutils.UsersTable)
+	defer sl.dropStagingTable(stagingTableName)
+	defer sl.dropStagingTable(unionStagingTableName)
+
+	userColMap := sl.Uploader.GetTableSchemaInWarehouse(warehouseutils.UsersTable)
+	var userColNames, firstValProps []string
+	for colName := range userColMap {
+		if colName == "id" {
+			continue
+		}
+		userColNames = append(userColNames, colName)
+		//the latest non null value of every trait
+		firstValProps = append(firstValProps, fmt.Sprintf(`(
+								SELECT "%[1]s" FROM "%[2]s" AS staging_table
+								WHERE x.id = staging_table.id AND "%[1]s" IS NOT NULL
+								ORDER BY received_at DESC
+								LIMIT 1) AS "%[1]s"`, colName, unionStagingTableName))
+	}
+
+	sqlStatement := fmt.Sprintf(`CREATE TABLE "%[4]s" AS
+									SELECT id, %[3]s FROM "%[1]s" WHERE id IN (SELECT user_id FROM "%[2]s" WHERE user_id IS NOT NULL)
+									UNION
+									SELECT user_id, %[3]s FROM "%[2]s" WHERE user_id IS NOT NULL`,
+		warehouseutils.UsersTable, identifyStagingTable, quotedColumns(userColNames), unionStagingTableName)
+	pkgLogger.Infof("SQLITE: Creating staging table for union of users table with identify staging table: %s\n", sqlStatement)
+	_, err = sl.Db.Exec(sqlStatement)
+	if err != nil {
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+
+	sqlStatement = fmt.Sprintf(`CREATE TABLE "%[1]s" AS SELECT DISTINCT * FROM (SELECT x.id, %[2]s FROM "%[3]s" AS x)`,
+		stagingTableName,
+		strings.Join(firstValProps, ","),
+		unionStagingTableName,
+	)
+	pkgLogger.Debugf("SQLITE: Creating staging table for users: %s\n", sqlStatement)
+	_, err = sl.Db.Exec(sqlStatement)
+	if err != nil {
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+
+	// BEGIN TRANSACTION
+	tx, err := sl.Db.Begin()
+	if err != nil {
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+
+	sqlStatement = fmt.Sprintf(`DELETE FROM "%[1]s" WHERE id IN (SELECT id FROM "%[2]s")`, warehouseutils.UsersTable, stagingTableName)
+	pkgLogger.Infof("SQLITE: Dedup records for table:%s using staging table: %s\n", warehouseutils.UsersTable, sqlStatement)
+	_, err = tx.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error deleting from original table for dedup: %v\n", err)
+		tx.Rollback()
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+
+	sqlStatement = fmt.Sprintf(`INSERT INTO "%[1]s" (%[3]s) SELECT %[3]s FROM "%[2]s"`, warehouseutils.UsersTable, stagingTableName, quotedColumns(append([]string{"id"}, userColNames...)))
+	pkgLogger.Infof("SQLITE: Inserting records for table:%s using staging table: %s\n", warehouseutils.UsersTable, sqlStatement)
+	_, err = tx.Exec(sqlStatement)
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error inserting into users table from staging table: %v\n", err)
+		tx.Rollback()
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error in transaction commit for users table: %v\n", err)
+		tx.Rollback()
+		errorMap[warehouseutils.UsersTable] = err
+		return
+	}
+	return
+}
+
+//CreateSchema creates the directory of the database files, the database file of the namespace is created on connecting
+func (sl *HandleT) CreateSchema() (err error) {
+	dir, err := databaseDirOf(sl.Warehouse)
+	if err != nil {
+		return err
+	}
+	pkgLogger.Infof("SQLITE: Creating database directory %s for SQLITE:%s", dir, sl.Warehouse.Destination.ID)
+	return os.MkdirAll(dir, os.ModePerm)
+}
+
+func (sl *HandleT) dropStagingTable(stagingTableName string) {
+	if stagingTableName == "" {
+		return
+	}
+	pkgLogger.Infof("SQLITE: dropping table %+v\n", stagingTableName)
+	_, err := sl.Db.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, stagingTableName))
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error dropping staging table %s in sqlite: %v", stagingTableName, err)
+	}
+}
+
+func (sl *HandleT) CreateTable(tableName string, columnMap map[string]string) (err error) {
+	sqlStatement := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS "%s" ( %v )`, tableName, ColumnsWithDataTypes(columnMap, ""))
+	pkgLogger.Infof("SQLITE: Creating table in sqlite for SQLITE:%s : %v", sl.Warehouse.Destination.ID, sqlStatement)
+	if _, err = sl.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	return sl.persist()
+}
+
+func (sl *HandleT) AddColumn(tableName string, columnName string, columnType string) (err error) {
+	//sqlite doesn't support ADD COLUMN IF NOT EXISTS
+	var exists bool
+	err = sl.Db.QueryRow(`SELECT EXISTS (SELECT 1 FROM pragma_table_info(?) WHERE name = ?)`, tableName, columnName).Scan(&exists)
+	if err != nil {
+		return
+	}
+	if exists {
+		pkgLogger.Infof("SQLITE: Skipping adding column %s to table %s since it already exists", columnName, tableName)
+		return
+	}
+	sqlStatement := fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, tableName, columnName, rudderDataTypesMapToSQLite[columnType])
+	pkgLogger.Infof("SQLITE: Adding column in sqlite for SQLITE:%s : %v", sl.Warehouse.Destination.ID, sqlStatement)
+	if _, err = sl.Db.Exec(sqlStatement); err != nil {
+		return
+	}
+	return sl.persist()
+}
+
+func (sl *HandleT) AlterColumn(tableName string, columnName string, columnType string) (err error) {
+	return
+}
+
+//TestConnection checks that the database directory is writable, and the object storage path too if the database files are kept there
+func (sl *HandleT) TestConnection(warehouse warehouseutils.WarehouseT) (err error) {
+	sl.Warehouse = warehouse
+	dir, err := databaseDirOf(warehouse)
+	if err != nil {
+		return err
+	}
+	if objectStoragePathOf(warehouse) != "" {
+		if err = sl.testObjectStorage(dir); err != nil {
+			return err
+		}
+	}
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	testFile, err := os.CreateTemp(dir, "rudder-test-connection-*.db")
+	if err != nil {
+		return err
+	}
+	testFile.Close()
+	defer os.Remove(testFile.Name())
+
+	db, err := Connect(testFile.Name())
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), warehouseutils.TestConnectionTimeout)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
+//testObjectStorage uploads a test file to the object storage path of the database files, and deletes it
+func (sl *HandleT) testObjectStorage(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	testFile, err := os.CreateTemp(dir, "rudder-test-connection-*.db")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(testFile.Name())
+	defer testFile.Close()
+
+	fm, err := sl.fileManager(false)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.TODO(), warehouseutils.TestConnectionTimeout)
+	defer cancel()
+	uploadOutput, err := fm.Upload(ctx, testFile, objectStoragePathOf(sl.Warehouse), sl.Warehouse.Destination.ID)
+	if err != nil {
+		return fmt.Errorf("uploading to the object storage path of the database files: %w", err)
+	}
+	return fm.DeleteObjects(ctx, []string{uploadOutput.ObjectName})
+}
+
+func (sl *HandleT) Setup(warehouse warehouseutils.WarehouseT, uploader warehouseutils.UploaderI) (err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	sl.Uploader = uploader
+	sl.ObjectStorage = warehouseutils.ObjectStorageType(warehouseutils.SQLITE, warehouse.Destination.Config, sl.Uploader.UseRudderStorage())
+
+	sl.Db, err = sl.connect()
+	return err
+}
+
+//CrashRecover drops the staging tables left in the database file. The database files kept in object storage never have any,
+//as they are only uploaded once the staging tables are dropped
+func (sl *HandleT) CrashRecover(warehouse warehouseutils.WarehouseT) (err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	if objectStoragePathOf(warehouse) != "" {
+		return nil
+	}
+	path, err := databaseFileOf(warehouse)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	sl.Db, err = Connect(path)
+	if err != nil {
+		return err
+	}
+	defer sl.Db.Close()
+	sl.dropDanglingStagingTables()
+	return
+}
+
+func (sl *HandleT) dropDanglingStagingTables() bool {
+	sqlStatement := `SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE ?`
+	rows, err := sl.Db.Query(sqlStatement, stagingTablePrefix+"%")
+	if err != nil {
+		pkgLogger.Errorf("WH: SQLITE: Error dropping dangling staging tables in SQLITE: %v\nQuery: %s\n", err, sqlStatement)
+		return false
+	}
+
+	var stagingTableNames []string
+	for rows.Next() {
+		var tableName string
+		err := rows.Scan(&tableName)
+		if err != nil {
+			panic(fmt.Errorf("Failed to scan result from query: %s\nwith Error : %w", sqlStatement, err))
+		}
+		stagingTableNames = append(stagingTableNames, tableName)
+	}
+	//the only connection is used by the rows until they are closed
+	rows.Close()
+	pkgLogger.Infof("WH: SQLITE: Dropping dangling staging tables: %+v  %+v\n", len(stagingTableNames), stagingTableNames)
+	delSuccess := true
+	for _, stagingTableName := range stagingTableNames {
+		_, err := sl.Db.Exec(fmt.Sprintf(`DROP TABLE "%s"`, stagingTableName))
+		if err != nil {
+			pkgLogger.Errorf("WH: SQLITE: Error dropping dangling staging table: %s in SQLITE: %v\n", stagingTableName, err)
+			delSuccess = false
+		}
+	}
+	return delSuccess
+}
+
+// FetchSchema reads the tables of the database file of the namespace and returns their schema
+func (sl *HandleT) FetchSchema(warehouse warehouseutils.WarehouseT) (schema warehouseutils.SchemaT, err error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	schema = make(warehouseutils.SchemaT)
+	path, err := databaseFileOf(warehouse)
+	if err != nil {
+		return
+	}
+	if objectStoragePathOf(warehouse) != "" {
+		//reading the schema from a copy of its own, as an upload might be using the local copy
+		if err = os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return
+		}
+		tmpDir, err := os.MkdirTemp(filepath.Dir(path), "fetch-schema-")
+		if err != nil {
+			return schema, err
+		}
+		defer os.RemoveAll(tmpDir)
+		path = filepath.Join(tmpDir, filepath.Base(path))
+		if err = sl.downloadDatabase(path); err != nil {
+			return schema, err
+		}
+	}
+	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+		pkgLogger.Infof("SQLITE: No database file %s, while fetching schema from destination:%v", path, sl.Warehouse.Identifier)
+		return schema, nil
+	}
+	dbHandle, err := Connect(path)
+	if err != nil {
+		return
+	}
+	defer dbHandle.Close()
+
+	sqlStatement := `SELECT m.name, p.name, p.type FROM sqlite_master AS m LEFT JOIN pragma_table_info(m.name) AS p WHERE m.type = 'table' AND m.name NOT LIKE ?`
+	rows, err := dbHandle.Query(sqlStatement, stagingTablePrefix+"%")
+	if err != nil {
+		pkgLogger.Errorf("SQLITE: Error in fetching schema from sqlite destination:%v, query: %v", sl.Warehouse.Destination.ID, sqlStatement)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tName, cName, cType sql.NullString
+		err = rows.Scan(&tName, &cName, &cType)
+		if err != nil {
+			pkgLogger.Errorf("SQLITE: Error in processing fetched schema from sqlite destination:%v", sl.Warehouse.Destination.ID)
+			return
+		}
+		if _, ok := schema[tName.String]; !ok {
+			schema[tName.String] = make(map[string]string)
+		}
+		if cName.Valid && cType.Valid {
+			if datatype, ok := sqliteDataTypesMapToRudder[strings.ToUpper(cType.String)]; ok {
+				schema[tName.String][cName.String] = datatype
+			}
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+func (sl *HandleT) LoadUserTables() map[string]error {
+	errorMap := sl.loadUserTables()
+	if errorMap[warehouseutils.IdentifiesTable] != nil {
+		return errorMap
+	}
+	//the identifies are loaded even if the users are not, so they are persisted anyway
+	if err := sl.persist(); err != nil {
+		for tableName := range errorMap {
+			errorMap[tableName] = err
+		}
+	}
+	return errorMap
+}
+
+func (sl *HandleT) LoadTable(tableName string) error {
+	_, err := sl.loadTable(tableName, sl.Uploader.GetTableSchemaInUpload(tableName), false)
+	if err != nil {
+		return err
+	}
+	return sl.persist()
+}
+
+//Cleanup drops the staging tables left and closes the database. The local copy of a database file kept in object storage is removed
+func (sl *HandleT) Cleanup() {
+	if sl.Db != nil {
+		sl.dropDanglingStagingTables()
+		sl.Db.Close()
+	}
+	if objectStoragePathOf(sl.Warehouse) != "" {
+		if path, err := databaseFileOf(sl.Warehouse); err == nil {
+			misc.RemoveFilePaths(path, path+"-wal", path+"-shm")
+		}
+	}
+}
+
+func (sl *HandleT) LoadIdentityMergeRulesTable() (err error) {
+	return
+}
+
+func (sl *HandleT) LoadIdentityMappingsTable() (err error) {
+	return
+}
+
+func (sl *HandleT) DownloadIdentityRules(*misc.GZipWriter) (err error) {
+	return
+}
+
+func (sl *HandleT) GetTotalCountInTable(tableName string) (total int64, err error) {
+	sqlStatement := fmt.Sprintf(`SELECT count(*) FROM "%s"`, tableName)
+	err = sl.Db.QueryRow(sqlStatement).Scan(&total)
+	if err != nil {
+		pkgLogger.Errorf(`SQLITE: Error getting total count in table %s:%s`, sl.Namespace, tableName)
+	}
+	return
+}
+
+func (sl *HandleT) Connect(warehouse warehouseutils.WarehouseT) (client.Client, error) {
+	sl.Warehouse = warehouse
+	sl.Namespace = warehouse.Namespace
+	sl.ObjectStorage = warehouseutils.ObjectStorageType(
+		warehouseutils.SQLITE,
+		warehouse.Destination.Config,
+		misc.IsConfiguredToUseRudderObjectStorage(sl.Warehouse.Destination.Config),
+	)
+	dbHandle, err := sl.connect()
+	if err != nil {
+		return client.Client{}, err
+	}
+
+	return client.Client{Type: client.SQLClient, SQL: dbHandle}, err
+}
+
+func (sl *HandleT) LoadTestTable(client *client.Client, location string, warehouse warehouseutils.WarehouseT, stagingTableName string, payloadMap map[string]interface{}, format string) (err error) {
+	sqlStatement := fmt.Sprintf(`INSERT INTO "%s" ("id", "val") VALUES (?, ?)`, stagingTableName)
+	_, err = client.SQL.Exec(sqlStatement, payloadMap["id"], payloadMap["val"])
+	return
+}
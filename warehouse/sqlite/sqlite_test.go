@@ -0,0 +1,343 @@
+package sqlite
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+)
+
+type uploaderT struct {
+	warehouseutils.UploaderI
+	schema       warehouseutils.SchemaT
+	loadFileType string
+}
+
+func (u *uploaderT) GetTableSchemaInUpload(tableName string) warehouseutils.TableSchemaT {
+	return u.schema[tableName]
+}
+
+func (u *uploaderT) GetTableSchemaInWarehouse(tableName string) warehouseutils.TableSchemaT {
+	return u.schema[tableName]
+}
+
+func (u *uploaderT) UseRudderStorage() bool {
+	return false
+}
+
+func (u *uploaderT) GetLoadFileType() string {
+	if u.loadFileType == "" {
+		return warehouseutils.LOAD_FILE_TYPE_CSV
+	}
+	return u.loadFileType
+}
+
+var _ = Describe("SQLite", func() {
+	var (
+		dir       string
+		sl        *HandleT
+		uploader  *uploaderT
+		warehouse warehouseutils.WarehouseT
+		loadFiles map[string][][]string
+	)
+
+	//writeLoadFile writes the rows in a gzipped csv load file, with the columns sorted by name
+	writeLoadFile := func(rows [][]string) string {
+		f, err := os.CreateTemp(dir, "load-file-*.csv.gz")
+		Expect(err).NotTo(HaveOccurred())
+		gzipWriter := gzip.NewWriter(f)
+		Expect(csv.NewWriter(gzipWriter).WriteAll(rows)).To(Succeed())
+		Expect(gzipWriter.Close()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+		return f.Name()
+	}
+
+	//writeParquetLoadFile writes the rows in a parquet load file of the table schema, with the columns sorted by name
+	writeParquetLoadFile := func(schema warehouseutils.TableSchemaT, rows [][]interface{}) string {
+		f, err := os.CreateTemp(dir, "load-file-*.parquet")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(f.Close()).To(Succeed())
+		writer, err := warehouseutils.CreateParquetWriter(schema, f.Name(), warehouseutils.SQLITE)
+		Expect(err).NotTo(HaveOccurred())
+		for _, row := range rows {
+			Expect(writer.WriteRow(row)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+		return f.Name()
+	}
+
+	query := func(sqlStatement string) [][]interface{} {
+		rows, err := sl.Db.Query(sqlStatement)
+		Expect(err).NotTo(HaveOccurred())
+		defer rows.Close()
+		columns, _ := rows.Columns()
+		var result [][]interface{}
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			pointers := make([]interface{}, len(columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			Expect(rows.Scan(pointers...)).To(Succeed())
+			result = append(result, values)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "sqlite-warehouse")
+		Expect(err).NotTo(HaveOccurred())
+		warehouse = warehouseutils.WarehouseT{
+			Namespace:   "rudder_namespace",
+			Destination: backendconfig.DestinationT{ID: "d1", Config: map[string]interface{}{"databasePath": dir}},
+		}
+		uploader = &uploaderT{schema: warehouseutils.SchemaT{}}
+		loadFiles = map[string][][]string{}
+		sl = &HandleT{}
+		sl.downloadLoadFiles = func(tableName string) ([]string, error) {
+			return []string{writeLoadFile(loadFiles[tableName])}, nil
+		}
+		Expect(sl.Setup(warehouse, uploader)).To(Succeed())
+		Expect(sl.CreateSchema()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		sl.Cleanup()
+		os.RemoveAll(dir)
+	})
+
+	It("creates and evolves tables and fetches their schema", func() {
+		schema, err := sl.FetchSchema(warehouse)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema).To(BeEmpty())
+
+		Expect(sl.CreateTable("tracks", map[string]string{"id": "string", "received_at": "datetime", "count": "int"})).To(Succeed())
+		Expect(sl.AddColumn("tracks", "context", "json")).To(Succeed())
+		Expect(sl.AddColumn("tracks", "context", "json")).To(Succeed(), "adding an existing column does nothing")
+		Expect(sl.AddColumn("tracks", "flag", "boolean")).To(Succeed())
+		Expect(sl.AddColumn("tracks", "price", "float")).To(Succeed())
+
+		schema, err = sl.FetchSchema(warehouse)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema).To(Equal(warehouseutils.SchemaT{"tracks": {
+			"id": "string", "received_at": "datetime", "count": "int", "context": "json", "flag": "boolean", "price": "float",
+		}}))
+		Expect(filepath.Join(dir, "rudder_namespace.db")).To(BeAnExistingFile())
+	})
+
+	It("loads the load files, keeping the latest row of every id", func() {
+		uploader.schema["tracks"] = map[string]string{"id": "string", "received_at": "datetime", "count": "int", "flag": "boolean", "context": "json"}
+		Expect(sl.CreateTable("tracks", uploader.schema["tracks"])).To(Succeed())
+
+		//columns sorted by name: context, count, flag, id, received_at
+		loadFiles["tracks"] = [][]string{
+			{`{"a":1}`, "1", "true", "e1", "2022-01-01T00:00:00.000Z"},
+			{`{"a":2}`, "2", "false", "e1", "2022-01-02T00:00:00.000Z"},
+			{"", "3", "", "e2", "2022-01-01T00:00:00.000Z"},
+		}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		Expect(query(`SELECT id, count, flag, context FROM tracks ORDER BY id`)).To(Equal([][]interface{}{
+			{"e1", int64(2), int64(0), `{"a":2}`},
+			{"e2", int64(3), nil, nil},
+		}))
+
+		loadFiles["tracks"] = [][]string{{"", "4", "true", "e2", "2022-01-03T00:00:00.000Z"}}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		total, err := sl.GetTotalCountInTable("tracks")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(int64(2)))
+		Expect(query(`SELECT count FROM tracks WHERE id = 'e2'`)).To(Equal([][]interface{}{{int64(4)}}), "rows of loaded ids are replaced")
+		Expect(query(`SELECT name FROM sqlite_master WHERE name LIKE 'rudder_staging_%'`)).To(BeEmpty())
+	})
+
+	It("keeps the latest row of every merge key in merge load mode", func() {
+		warehouse.Destination.Config[warehouseutils.LoadModeConfig] = warehouseutils.MergeLoadMode
+		warehouse.Destination.Config[warehouseutils.MergeKeyConfig] = "user_id, event"
+		uploader.schema["tracks"] = map[string]string{"event": "string", "id": "string", "received_at": "datetime", "user_id": "string"}
+		Expect(sl.CreateTable("tracks", uploader.schema["tracks"])).To(Succeed())
+
+		//columns sorted by name: event, id, received_at, user_id
+		loadFiles["tracks"] = [][]string{
+			{"signup", "e1", "2022-01-01T00:00:00.000Z", "u1"},
+			{"signup", "e2", "2022-01-02T00:00:00.000Z", "u1"},
+			{"login", "e3", "2022-01-01T00:00:00.000Z", "u1"},
+		}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		loadFiles["tracks"] = [][]string{{"login", "e4", "2022-01-03T00:00:00.000Z", "u1"}}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		Expect(query(`SELECT event, id FROM tracks ORDER BY event`)).To(Equal([][]interface{}{
+			{"login", "e4"},
+			{"signup", "e2"},
+		}))
+
+		//tables without the merge key columns are deduplicated on id
+		warehouse.Destination.Config[warehouseutils.MergeKeyConfig] = "anonymous_id"
+		loadFiles["tracks"] = [][]string{{"signup", "e4", "2022-01-04T00:00:00.000Z", "u1"}}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		Expect(query(`SELECT event, id FROM tracks ORDER BY id`)).To(Equal([][]interface{}{
+			{"signup", "e2"},
+			{"signup", "e4"},
+		}))
+	})
+
+	It("fails loading load files not matching the upload schema", func() {
+		uploader.schema["tracks"] = map[string]string{"id": "string", "received_at": "datetime"}
+		Expect(sl.CreateTable("tracks", uploader.schema["tracks"])).To(Succeed())
+		loadFiles["tracks"] = [][]string{{"e1", "2022-01-01T00:00:00.000Z", "extra"}}
+		Expect(sl.LoadTable("tracks")).To(MatchError(ContainSubstring("Load file CSV columns for a row mismatch")))
+	})
+
+	It("loads parquet load files", func() {
+		uploader.loadFileType = warehouseutils.LOAD_FILE_TYPE_PARQUET
+		uploader.schema["tracks"] = map[string]string{"id": "string", "received_at": "datetime", "count": "int", "flag": "boolean", "price": "float", "context": "json"}
+		Expect(sl.CreateTable("tracks", uploader.schema["tracks"])).To(Succeed())
+
+		//columns sorted by name: context, count, flag, id, price, received_at
+		var rows [][]interface{}
+		for _, row := range [][]interface{}{
+			{map[string]interface{}{"a": 1}, 1, true, "e1", 1.5, "2022-01-01T00:00:00.000Z"},
+			{map[string]interface{}{"a": 2}, 2, false, "e1", 2.5, "2022-01-02T00:00:00.000Z"},
+			{nil, 3, nil, "e2", nil, "2022-01-01T00:00:00.000Z"},
+		} {
+			for i, columnName := range []string{"context", "count", "flag", "id", "price", "received_at"} {
+				if row[i] == nil {
+					continue
+				}
+				var err error
+				row[i], err = warehouseutils.GetParquetValue(row[i], uploader.schema["tracks"][columnName])
+				Expect(err).NotTo(HaveOccurred())
+			}
+			rows = append(rows, row)
+		}
+		sl.downloadLoadFiles = func(tableName string) ([]string, error) {
+			return []string{writeParquetLoadFile(uploader.schema[tableName], rows)}, nil
+		}
+		Expect(sl.LoadTable("tracks")).To(Succeed())
+		Expect(query(`SELECT id, count, flag, price, context, received_at FROM tracks ORDER BY id`)).To(Equal([][]interface{}{
+			{"e1", int64(2), int64(0), 2.5, `{"a":2}`, time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{"e2", int64(3), nil, nil, nil, time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		}))
+	})
+
+	It("computes the latest traits of the users from the identifies", func() {
+		uploader.schema[warehouseutils.IdentifiesTable] = map[string]string{"id": "string", "user_id": "string", "received_at": "datetime", "name": "string", "email": "string"}
+		uploader.schema[warehouseutils.UsersTable] = map[string]string{"id": "string", "received_at": "datetime", "name": "string", "email": "string"}
+		Expect(sl.CreateTable(warehouseutils.IdentifiesTable, uploader.schema[warehouseutils.IdentifiesTable])).To(Succeed())
+		Expect(sl.CreateTable(warehouseutils.UsersTable, uploader.schema[warehouseutils.UsersTable])).To(Succeed())
+		_, err := sl.Db.Exec(`INSERT INTO users (id, received_at, name, email) VALUES ('u1', '2022-01-01T00:00:00.000Z', 'old', 'u1@example.com')`)
+		Expect(err).NotTo(HaveOccurred())
+
+		//columns sorted by name: email, id, name, received_at, user_id
+		loadFiles[warehouseutils.IdentifiesTable] = [][]string{
+			{"", "i1", "new", "2022-01-02T00:00:00.000Z", "u1"},
+			{"u2@example.com", "i2", "second", "2022-01-02T00:00:00.000Z", "u2"},
+			{"", "i3", "latest", "2022-01-03T00:00:00.000Z", "u2"},
+		}
+		Expect(sl.LoadUserTables()).To(Equal(map[string]error{warehouseutils.IdentifiesTable: nil, warehouseutils.UsersTable: nil}))
+		Expect(query(`SELECT id, name, email FROM users ORDER BY id`)).To(Equal([][]interface{}{
+			{"u1", "new", "u1@example.com"},
+			{"u2", "latest", "u2@example.com"},
+		}))
+		total, err := sl.GetTotalCountInTable(warehouseutils.IdentifiesTable)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(int64(3)))
+	})
+
+	It("drops dangling staging tables on crash recovery", func() {
+		_, err := sl.Db.Exec(`CREATE TABLE rudder_staging_tracks_1 (id TEXT)`)
+		Expect(err).NotTo(HaveOccurred())
+		sl.Db.Close()
+
+		recovered := &HandleT{}
+		Expect(recovered.CrashRecover(warehouse)).To(Succeed())
+		Expect(sl.Setup(warehouse, uploader)).To(Succeed())
+		Expect(query(`SELECT name FROM sqlite_master WHERE name LIKE 'rudder_staging_%'`)).To(BeEmpty())
+	})
+
+	It("tests the connection by writing to the database directory", func() {
+		Expect(sl.TestConnection(warehouse)).To(Succeed())
+		warehouse.Destination.Config["databasePath"] = filepath.Join(dir, "rudder_namespace.db", "nested")
+		Expect(sl.TestConnection(warehouse)).NotTo(Succeed())
+	})
+
+	It("requires a persistent path for the database files", func() {
+		delete(warehouse.Destination.Config, "databasePath")
+		Expect(sl.TestConnection(warehouse)).To(MatchError(ContainSubstring("no persistent path for the database files of destination d1")))
+		Expect((&HandleT{}).Setup(warehouse, uploader)).NotTo(Succeed())
+	})
+})
+
+func TestObjectStorage(t *testing.T) {
+	minio := testutils.SetupMinio(t)
+	config.Load()
+	logger.Init()
+	warehouseutils.Init()
+	Init()
+
+	warehouse := warehouseutils.WarehouseT{
+		Namespace: "rudder_namespace",
+		Destination: backendconfig.DestinationT{
+			ID:                    "d1",
+			DestinationDefinition: backendconfig.DestinationDefinitionT{Name: warehouseutils.SQLITE},
+			Config: map[string]interface{}{
+				"bucketProvider":          "MINIO",
+				"bucketName":              "sqlite",
+				"endPoint":                minio.Endpoint,
+				"accessKeyID":             minio.AccessKeyID,
+				"secretAccessKey":         minio.SecretAccessKey,
+				"useSSL":                  false,
+				databaseObjectStoragePath: "warehouse/",
+			},
+		},
+	}
+	uploader := &uploaderT{schema: warehouseutils.SchemaT{"tracks": {"id": "string", "received_at": "datetime"}}}
+
+	// the test connection creates the bucket
+	sl := &HandleT{}
+	require.NoError(t, sl.TestConnection(warehouse))
+	schema, err := sl.FetchSchema(warehouse)
+	require.NoError(t, err)
+	require.Empty(t, schema)
+
+	require.NoError(t, sl.Setup(warehouse, uploader))
+	sl.downloadLoadFiles = func(tableName string) ([]string, error) {
+		f, err := os.CreateTemp(t.TempDir(), "load-file-*.csv.gz")
+		require.NoError(t, err)
+		gzipWriter := gzip.NewWriter(f)
+		require.NoError(t, csv.NewWriter(gzipWriter).WriteAll([][]string{{"e1", "2022-01-01T00:00:00.000Z"}}))
+		require.NoError(t, gzipWriter.Close())
+		require.NoError(t, f.Close())
+		return []string{f.Name()}, nil
+	}
+	require.NoError(t, sl.CreateSchema())
+	require.NoError(t, sl.CreateTable("tracks", uploader.schema["tracks"]))
+	require.NoError(t, sl.LoadTable("tracks"))
+	path, err := databaseFileOf(warehouse)
+	require.NoError(t, err)
+	sl.Cleanup()
+	require.NoFileExists(t, path, "the local copy of the database file is removed")
+
+	// a new upload sees the schema and the rows kept in object storage
+	sl = &HandleT{}
+	schema, err = sl.FetchSchema(warehouse)
+	require.NoError(t, err)
+	require.Equal(t, warehouseutils.SchemaT{"tracks": {"id": "string", "received_at": "datetime"}}, schema)
+	require.NoError(t, sl.Setup(warehouse, uploader))
+	defer sl.Cleanup()
+	total, err := sl.GetTotalCountInTable("tracks")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+}
@@ -61,6 +61,7 @@ const (
 	UserTableUploadExportingFailed     = "exporting_user_tables_failed"
 	IdentityTableUploadExportingFailed = "exporting_identities_failed"
 	TableUploadExported                = "exported_data"
+	TableUploadSkipped                 = "skipped"
 )
 
 const (
@@ -125,6 +126,8 @@ type UploadJobT struct {
 	uploadLock          sync.Mutex
 	hasAllTablesSkipped bool
 	tableUploadStatuses []*TableUploadStatusT
+	retryTables         []string
+	regenerateLoadFiles bool
 }
 
 type UploadColumnT struct {
@@ -169,6 +172,7 @@ func setMaxParallelLoads() {
 		warehouseutils.SNOWFLAKE:  config.GetInt("Warehouse.snowflake.maxParallelLoads", 3),
 		warehouseutils.CLICKHOUSE: config.GetInt("Warehouse.clickhouse.maxParallelLoads", 3),
 		warehouseutils.DELTALAKE:  config.GetInt("Warehouse.deltalake.maxParallelLoads", 3),
+		warehouseutils.SQLITE:     config.GetInt("Warehouse.sqlite.maxParallelLoads", 1),
 	}
 	columnCountThresholds = map[string]int{
 		warehouseutils.AZURE_SYNAPSE: config.GetInt("Warehouse.azure_synapse.columnCountThreshold", 800),
@@ -178,6 +182,7 @@ func setMaxParallelLoads() {
 		warehouseutils.POSTGRES:      config.GetInt("Warehouse.postgres.columnCountThreshold", 1200),
 		warehouseutils.RS:            config.GetInt("Warehouse.redshift.columnCountThreshold", 1200),
 		warehouseutils.SNOWFLAKE:     config.GetInt("Warehouse.snowflake.columnCountThreshold", 1600),
+		warehouseutils.SQLITE:        config.GetInt("Warehouse.sqlite.columnCountThreshold", 1600),
 	}
 }
 
@@ -212,7 +217,11 @@ func (job *UploadJobT) trackLongRunningUpload() chan struct{} {
 }
 
 func (job *UploadJobT) generateUploadSchema(schemaHandle *SchemaHandleT) error {
-	schemaHandle.uploadSchema = schemaHandle.consolidateStagingFilesSchemaUsingWarehouseSchema()
+	uploadSchema, err := schemaHandle.consolidateStagingFilesSchemaUsingWarehouseSchema()
+	if err != nil {
+		return err
+	}
+	schemaHandle.uploadSchema = uploadSchema
 	if job.upload.LoadFileType == warehouseutils.LOAD_FILE_TYPE_PARQUET {
 		// set merged schema if the loadFileType is parquet
 		mergedSchema := mergeUploadAndLocalSchemas(schemaHandle.uploadSchema, schemaHandle.localSchema)
@@ -222,7 +231,7 @@ func (job *UploadJobT) generateUploadSchema(schemaHandle *SchemaHandleT) error {
 		}
 	}
 	// set upload schema
-	err := job.setUploadSchema(schemaHandle.uploadSchema)
+	err = job.setUploadSchema(schemaHandle.uploadSchema)
 	return err
 }
 
@@ -334,6 +343,7 @@ func (job *UploadJobT) run() (err error) {
 	job.uploadLock.Lock()
 	defer job.uploadLock.Unlock()
 	job.setUploadColumns(UploadColumnsOpts{Fields: []UploadColumnT{UploadColumnT{Column: UploadLastExecAtField, Value: timeutil.Now()}, UploadColumnT{Column: UploadInProgress, Value: true}}})
+	job.consumeUploadActions()
 
 	if len(job.stagingFiles) == 0 {
 		err := fmt.Errorf("No staging files found")
@@ -402,7 +412,7 @@ func (job *UploadJobT) run() (err error) {
 		case GeneratedLoadFiles:
 			newStatus = nextUploadState.failed
 			// generate load files for all staging files(including succeeded) if hasSchemaChanged or if its snowflake(to have all load files in same folder in bucket) or set via toml/env
-			generateAll := hasSchemaChanged || misc.ContainsString(warehousesToAlwaysRegenerateAllLoadFilesOnResume, job.warehouse.Type) || config.GetBool("Warehouse.alwaysRegenerateAllLoadFiles", true)
+			generateAll := hasSchemaChanged || job.regenerateLoadFiles || misc.ContainsString(warehousesToAlwaysRegenerateAllLoadFilesOnResume, job.warehouse.Type) || config.GetBool("Warehouse.alwaysRegenerateAllLoadFiles", true)
 			var startLoadFileID, endLoadFileID int64
 			startLoadFileID, endLoadFileID, err = job.createLoadFiles(generateAll)
 			if err != nil {
@@ -465,6 +475,13 @@ func (job *UploadJobT) run() (err error) {
 					wg.Done()
 					return
 				}
+				if notRetriedErrors, retried := job.notRetriedTableErrors(userTables, currentJobSucceededTables); !retried {
+					loadErrorLock.Lock()
+					loadErrors = append(loadErrors, notRetriedErrors...)
+					loadErrorLock.Unlock()
+					wg.Done()
+					return
+				}
 				err = job.exportUserTables()
 				if err != nil {
 					loadErrorLock.Lock()
@@ -485,6 +502,13 @@ func (job *UploadJobT) run() (err error) {
 					wg.Done()
 					return
 				}
+				if notRetriedErrors, retried := job.notRetriedTableErrors(identityTables, currentJobSucceededTables); !retried {
+					loadErrorLock.Lock()
+					loadErrors = append(loadErrors, notRetriedErrors...)
+					loadErrorLock.Unlock()
+					wg.Done()
+					return
+				}
 				err = job.exportIdentities()
 				if err != nil {
 					loadErrorLock.Lock()
@@ -643,10 +667,12 @@ func (job *UploadJobT) exportRegularTables(specialTables []string) (err error) {
 func areAllTableSkipErrors(loadErrors []error) bool {
 	res := true
 	for _, lErr := range loadErrors {
-		if _, ok := lErr.(*TableSkipError); !ok {
-			res = false
-			break
+		switch lErr.(type) {
+		case *TableSkipError, *TableNotRetriedError:
+			continue
 		}
+		res = false
+		break
 	}
 	return res
 }
@@ -762,7 +788,7 @@ func (job *UploadJobT) getTablesToSkip() (map[string]*TableUploadIDInfoT, map[st
 					error:    tableStatus.error,
 				}
 			}
-			if uploadID == job.upload.ID && status == TableUploadExported { //Current upload and table upload succeeded
+			if uploadID == job.upload.ID && (status == TableUploadExported || status == TableUploadSkipped) { //Current upload and table upload succeeded or skipped manually
 				currentlySucceededTableMap[tableName] = true
 			}
 		}
@@ -877,6 +903,11 @@ func (job *UploadJobT) loadAllTablesExcept(skipLoadForTables []string) []error {
 			}
 			continue
 		}
+		if !job.isTableRetried(tableName) {
+			loadErrors = append(loadErrors, &TableNotRetriedError{tableName: tableName, retryTables: job.retryTables})
+			wg.Done()
+			continue
+		}
 		tName := tableName
 		loadChan <- struct{}{}
 		rruntime.GoForWarehouse(func() {
@@ -1863,12 +1894,12 @@ func (job *UploadJobT) GetLoadFilesMetadata(options warehouseutils.GetLoadFilesO
 	sqlStatement := fmt.Sprintf(`
 		WITH row_numbered_load_files as (
 			SELECT
-				location, metadata,
+				location, metadata, COALESCE(total_events, 0) AS total_events,
 				row_number() OVER (PARTITION BY staging_file_id, table_name ORDER BY id DESC) AS row_number
 				FROM %[1]s
 				WHERE staging_file_id IN (%[2]v) %[3]s
 		)
-		SELECT location, metadata
+		SELECT location, metadata, total_events
 			FROM row_numbered_load_files
 			WHERE
 				row_number=1
@@ -1885,13 +1916,15 @@ func (job *UploadJobT) GetLoadFilesMetadata(options warehouseutils.GetLoadFilesO
 	for rows.Next() {
 		var location string
 		var metadata json.RawMessage
-		err := rows.Scan(&location, &metadata)
+		var totalRows int64
+		err := rows.Scan(&location, &metadata, &totalRows)
 		if err != nil {
 			panic(fmt.Errorf("Failed to scan result from query: %s\nwith Error : %w", sqlStatement, err))
 		}
 		loadFiles = append(loadFiles, warehouseutils.LoadFileT{
-			Location: location,
-			Metadata: metadata,
+			Location:  location,
+			Metadata:  metadata,
+			TotalRows: totalRows,
 		})
 	}
 	return
@@ -0,0 +1,352 @@
+package warehouse
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	proto "github.com/rudderlabs/rudder-server/proto/warehouse"
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/rudderlabs/rudder-server/utils/timeutil"
+	warehouseutils "github.com/rudderlabs/rudder-server/warehouse/utils"
+	"github.com/tidwall/gjson"
+)
+
+//manual actions on uploads, requested through the warehouse api
+const (
+	SkipTableUploadAction           = "skip_table"
+	RegenerateLoadFilesUploadAction = "regenerate_load_files"
+	RetryTableUploadAction          = "retry_table"
+	AbortUploadAction               = "abort"
+)
+
+//metadata of the uploads, with the manual actions applied by their next attempt
+const (
+	retryTablesMetadataKey         = "retryTables"
+	regenerateLoadFilesMetadataKey = "regenerateLoadFiles"
+)
+
+type UploadActionReqT struct {
+	WorkspaceID string
+	UploadId    int64
+	Action      string
+	TableName   string
+	Reason      string
+	RequestedBy string
+	API         UploadAPIT
+}
+
+//uploadActionRequestT is the body of the requests of manual actions on uploads to the warehouse http api
+type uploadActionRequestT struct {
+	UploadID    int64  `json:"upload_id"`
+	WorkspaceID string `json:"workspace_id"`
+	Action      string `json:"action"`
+	TableName   string `json:"table_name"`
+	Reason      string `json:"reason"`
+	RequestedBy string `json:"requested_by"`
+}
+
+//uploadActionError is an error of a manual action on an upload caused by the request, as opposed to a failure of the warehouse db
+type uploadActionError struct {
+	statusCode int
+	message    string
+}
+
+func (e *uploadActionError) Error() string {
+	return e.message
+}
+
+func newUploadActionError(statusCode int, format string, args ...interface{}) *uploadActionError {
+	return &uploadActionError{statusCode: statusCode, message: fmt.Sprintf(format, args...)}
+}
+
+//uploadActionStatusCode returns the status code of the response to a failed action, 500 for the failures of the warehouse db
+func uploadActionStatusCode(err error) int {
+	var actionErr *uploadActionError
+	if errors.As(err, &actionErr) {
+		return actionErr.statusCode
+	}
+	return http.StatusInternalServerError
+}
+
+func isTableUploadAction(action string) bool {
+	return action == SkipTableUploadAction || action == RetryTableUploadAction
+}
+
+func (actionReq UploadActionReqT) validateReq() error {
+	if !actionReq.API.enabled || actionReq.API.log == nil || actionReq.API.dbHandle == nil {
+		return errors.New(fmt.Sprint(`warehouse api's are not initialized`))
+	}
+	if actionReq.UploadId < 1 {
+		return errors.New(fmt.Sprint(`upload_id is empty or should be greater than 0 `))
+	}
+	switch actionReq.Action {
+	case SkipTableUploadAction, RegenerateLoadFilesUploadAction, RetryTableUploadAction, AbortUploadAction:
+	default:
+		return fmt.Errorf(`invalid action %q, should be one of %s, %s, %s or %s`, actionReq.Action, SkipTableUploadAction, RegenerateLoadFilesUploadAction, RetryTableUploadAction, AbortUploadAction)
+	}
+	if isTableUploadAction(actionReq.Action) && strings.TrimSpace(actionReq.TableName) == "" {
+		return fmt.Errorf(`table_name is required for action %s`, actionReq.Action)
+	}
+	return nil
+}
+
+/*
+uploadStateOrder returns the position in the state machine of the state of the status, -1 if the status isn't of a state of the state machine.
+*/
+func uploadStateOrder(status string) int {
+	order := 0
+	for state := stateTransitions[Waiting]; state != nil; state = state.nextState {
+		if status == state.inProgress || status == state.failed || status == state.completed {
+			return order
+		}
+		order++
+	}
+	return -1
+}
+
+/*
+resumeFromCheckpoint returns the status of an upload resuming from the checkpoint, the completed status of a state.
+Uploads which haven't gone past the checkpoint keep their status, as none of the states after the checkpoint ran yet.
+*/
+func resumeFromCheckpoint(status string, checkpoint string) string {
+	if uploadStateOrder(status) > uploadStateOrder(checkpoint) {
+		return checkpoint
+	}
+	return status
+}
+
+//addRetryTable adds the table to the tables retried by the next attempt of the upload, set in its metadata
+func addRetryTable(metadata map[string]interface{}, tableName string) {
+	var retryTables []string
+	switch tables := metadata[retryTablesMetadataKey].(type) {
+	case []string:
+		retryTables = tables
+	case []interface{}:
+		for _, table := range tables {
+			if name, ok := table.(string); ok {
+				retryTables = append(retryTables, name)
+			}
+		}
+	}
+	if !misc.ContainsString(retryTables, tableName) {
+		retryTables = append(retryTables, tableName)
+	}
+	metadata[retryTablesMetadataKey] = retryTables
+}
+
+/*
+ApplyUploadAction applies a manual action to an upload which isn't running, and records it as an audit event of the upload:
+  - skip_table marks the table as skipped, the upload doesn't load it and later uploads of the namespace are not blocked by it anymore
+  - retry_table resets the failed table, and the next attempt of the upload only loads it
+  - regenerate_load_files generates the load files of all the staging files of the upload again in the next attempt
+  - abort marks the upload as aborted
+Uploads resume right away from the checkpoint of the action, without running again the states before it.
+Invalid requests and actions not applicable to the upload fail with a 4xx status code, failures of the warehouse db with 500.
+*/
+func (actionReq UploadActionReqT) ApplyUploadAction() (response *proto.TriggerWhUploadsResponse, err error) {
+	defer func() {
+		if err != nil {
+			response = &proto.TriggerWhUploadsResponse{
+				Message:    err.Error(),
+				StatusCode: int32(uploadActionStatusCode(err)),
+			}
+		}
+	}()
+	if validationErr := actionReq.validateReq(); validationErr != nil {
+		err = newUploadActionError(http.StatusBadRequest, "%s", validationErr.Error())
+		return
+	}
+
+	txn, err := actionReq.API.dbHandle.Begin()
+	if err != nil {
+		actionReq.API.log.Errorf(`[WH]: Failed to begin transaction for action %s on upload:%d: %v`, actionReq.Action, actionReq.UploadId, err)
+		return
+	}
+	defer func() {
+		if err != nil {
+			txn.Rollback()
+		}
+	}()
+
+	var sourceID, status string
+	var inProgress bool
+	var metadataJSON json.RawMessage
+	sqlStatement := fmt.Sprintf(`SELECT source_id, status, in_progress, metadata FROM %s WHERE id = $1 FOR UPDATE`, warehouseutils.WarehouseUploadsTable)
+	err = txn.QueryRow(sqlStatement, actionReq.UploadId).Scan(&sourceID, &status, &inProgress, &metadataJSON)
+	if err == sql.ErrNoRows {
+		err = newUploadActionError(http.StatusNotFound, `upload %d not found`, actionReq.UploadId)
+		return
+	}
+	if err != nil {
+		actionReq.API.log.Errorf(err.Error())
+		return
+	}
+	uploadReq := UploadReqT{UploadId: actionReq.UploadId, WorkspaceID: actionReq.WorkspaceID, API: actionReq.API}
+	if !uploadReq.authorizeSource(sourceID) {
+		pkgLogger.Errorf(`Unauthorized request for upload:%d with sourceId:%s in workspaceId:%s`, actionReq.UploadId, sourceID, actionReq.WorkspaceID)
+		err = newUploadActionError(http.StatusBadRequest, "Unauthorized request")
+		return
+	}
+	if inProgress {
+		err = newUploadActionError(http.StatusConflict, `upload %d is in progress`, actionReq.UploadId)
+		return
+	}
+	if status == ExportedData || status == Aborted {
+		err = newUploadActionError(http.StatusConflict, `upload %d is already %s`, actionReq.UploadId, status)
+		return
+	}
+
+	if isTableUploadAction(actionReq.Action) {
+		var tableStatus string
+		sqlStatement = fmt.Sprintf(`SELECT status FROM %s WHERE wh_upload_id = $1 AND table_name = $2 FOR UPDATE`, warehouseutils.WarehouseTableUploadsTable)
+		err = txn.QueryRow(sqlStatement, actionReq.UploadId, actionReq.TableName).Scan(&tableStatus)
+		if err == sql.ErrNoRows {
+			err = newUploadActionError(http.StatusNotFound, `table %s is not in upload %d`, actionReq.TableName, actionReq.UploadId)
+			return
+		}
+		if err != nil {
+			actionReq.API.log.Errorf(err.Error())
+			return
+		}
+		if tableStatus == TableUploadExported {
+			err = newUploadActionError(http.StatusConflict, `table %s of upload %d is already exported`, actionReq.TableName, actionReq.UploadId)
+			return
+		}
+	}
+
+	var metadata map[string]interface{}
+	if unmarshallErr := json.Unmarshal(metadataJSON, &metadata); unmarshallErr != nil || metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+
+	now := timeutil.Now()
+	var newStatus string
+	switch actionReq.Action {
+	case SkipTableUploadAction:
+		sqlStatement = fmt.Sprintf(`UPDATE %s SET status=$1, updated_at=$2 WHERE wh_upload_id=$3 AND table_name=$4`, warehouseutils.WarehouseTableUploadsTable)
+		_, err = txn.Exec(sqlStatement, TableUploadSkipped, now, actionReq.UploadId, actionReq.TableName)
+		newStatus = resumeFromCheckpoint(status, CreatedRemoteSchema)
+	case RetryTableUploadAction:
+		sqlStatement = fmt.Sprintf(`UPDATE %s SET status=$1, error=$2, updated_at=$3 WHERE wh_upload_id=$4 AND table_name=$5`, warehouseutils.WarehouseTableUploadsTable)
+		_, err = txn.Exec(sqlStatement, Waiting, "{}", now, actionReq.UploadId, actionReq.TableName)
+		addRetryTable(metadata, actionReq.TableName)
+		newStatus = resumeFromCheckpoint(status, CreatedRemoteSchema)
+	case RegenerateLoadFilesUploadAction:
+		metadata[regenerateLoadFilesMetadataKey] = true
+		newStatus = resumeFromCheckpoint(status, CreatedTableUploads)
+	case AbortUploadAction:
+		newStatus = Aborted
+	}
+	if err != nil {
+		actionReq.API.log.Errorf(err.Error())
+		return
+	}
+	if newStatus != Aborted {
+		// pick the upload in the next scheduling, as triggerUploadNow does
+		metadata["nextRetryTime"] = now.Add(-time.Hour * 1).Format(time.RFC3339)
+		metadata["retried"] = true
+		metadata["priority"] = 50
+	}
+	metadataJSON, err = json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	sqlStatement = fmt.Sprintf(`UPDATE %s SET status=$1, metadata=$2, updated_at=$3 WHERE id=$4`, warehouseutils.WarehouseUploadsTable)
+	_, err = txn.Exec(sqlStatement, newStatus, metadataJSON, now, actionReq.UploadId)
+	if err != nil {
+		actionReq.API.log.Errorf(err.Error())
+		return
+	}
+
+	var tableName sql.NullString
+	if isTableUploadAction(actionReq.Action) {
+		tableName = sql.NullString{String: actionReq.TableName, Valid: true}
+	}
+	sqlStatement = fmt.Sprintf(`INSERT INTO %s (wh_upload_id, action, table_name, previous_status, status, reason, requested_by, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`, warehouseutils.WarehouseUploadAuditEventsTable)
+	_, err = txn.Exec(sqlStatement, actionReq.UploadId, actionReq.Action, tableName, status, newStatus, actionReq.Reason, actionReq.RequestedBy, now)
+	if err != nil {
+		actionReq.API.log.Errorf(err.Error())
+		return
+	}
+	if err = txn.Commit(); err != nil {
+		actionReq.API.log.Errorf(`[WH]: Failed to commit action %s on upload:%d: %v`, actionReq.Action, actionReq.UploadId, err)
+		return
+	}
+	pkgLogger.Infof(`[WH]: Applied action %s on upload:%d table:%q requested by %q, status changed from %s to %s. Reason: %s`, actionReq.Action, actionReq.UploadId, actionReq.TableName, actionReq.RequestedBy, status, newStatus, actionReq.Reason)
+
+	response = &proto.TriggerWhUploadsResponse{
+		Message:    fmt.Sprintf("Applied %s successfully", actionReq.Action),
+		StatusCode: 200,
+	}
+	return
+}
+
+/*
+consumeUploadActions reads the manual actions applied by this attempt from the metadata of the upload,
+and removes them from it so that the next attempts run as usual.
+*/
+func (job *UploadJobT) consumeUploadActions() {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(job.upload.Metadata, &metadata); err != nil {
+		return
+	}
+	_, hasRetryTables := metadata[retryTablesMetadataKey]
+	_, hasRegenerateLoadFiles := metadata[regenerateLoadFilesMetadataKey]
+	if !hasRetryTables && !hasRegenerateLoadFiles {
+		return
+	}
+
+	job.retryTables = nil
+	for _, table := range gjson.GetBytes(job.upload.Metadata, retryTablesMetadataKey).Array() {
+		job.retryTables = append(job.retryTables, table.String())
+	}
+	job.regenerateLoadFiles = gjson.GetBytes(job.upload.Metadata, regenerateLoadFilesMetadataKey).Bool()
+	pkgLogger.Infof(`[WH]: Upload:%d retrying tables %v, regenerating load files: %t`, job.upload.ID, job.retryTables, job.regenerateLoadFiles)
+
+	delete(metadata, retryTablesMetadataKey)
+	delete(metadata, regenerateLoadFilesMetadataKey)
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return
+	}
+	job.upload.Metadata = metadataJSON
+	err = job.setUploadColumns(UploadColumnsOpts{Fields: []UploadColumnT{{Column: "metadata", Value: metadataJSON}}})
+	if err != nil {
+		pkgLogger.Errorf(`[WH]: Failed to remove manual actions from metadata of upload:%d: %v`, job.upload.ID, err)
+	}
+}
+
+//isTableRetried returns whether the attempt loads the table, all the tables are loaded unless some are retried manually
+func (job *UploadJobT) isTableRetried(tableName string) bool {
+	return len(job.retryTables) == 0 || misc.ContainsString(job.retryTables, tableName)
+}
+
+//notRetriedTableErrors returns the errors of the tables which haven't been exported and are not loaded by the attempt, nil if any of the tables is retried
+func (job *UploadJobT) notRetriedTableErrors(tableNames []string, succeededTables map[string]bool) (loadErrors []error, retried bool) {
+	for _, tableName := range tableNames {
+		if job.isTableRetried(tableName) {
+			return nil, true
+		}
+	}
+	for _, tableName := range tableNames {
+		if _, ok := job.upload.UploadSchema[tableName]; ok && !succeededTables[tableName] {
+			loadErrors = append(loadErrors, &TableNotRetriedError{tableName: tableName, retryTables: job.retryTables})
+		}
+	}
+	return loadErrors, false
+}
+
+//TableNotRetriedError is the error of the tables not loaded by an attempt retrying other tables of the upload, the next attempt loads them
+type TableNotRetriedError struct {
+	tableName   string
+	retryTables []string
+}
+
+func (tnre *TableNotRetriedError) Error() string {
+	return fmt.Sprintf("Skipping %s table because the attempt only retried the tables: %s", tnre.tableName, strings.Join(tnre.retryTables, ", "))
+}
@@ -0,0 +1,145 @@
+package warehouse
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/rudderlabs/rudder-server/config"
+	"github.com/rudderlabs/rudder-server/utils/logger"
+	testutils "github.com/rudderlabs/rudder-server/utils/tests"
+)
+
+func TestApplyUploadActionDB(t *testing.T) {
+	db, _ := testutils.SetupPostgres(t)
+	config.Load()
+	logger.Init()
+	if pkgLogger == nil {
+		pkgLogger = logger.NewLogger().Child("warehouse")
+	}
+	setupTables(db)
+	sourceIDsByWorkspaceLock.Lock()
+	sourceIDsByWorkspace = map[string][]string{"w1": {"s1"}}
+	sourceIDsByWorkspaceLock.Unlock()
+	api := UploadAPIT{enabled: true, log: logger.NewLogger(), dbHandle: db}
+
+	createUpload := func(status string, inProgress bool, tables map[string]string) int64 {
+		var uploadID int64
+		err := db.QueryRow(`INSERT INTO wh_uploads (source_id, namespace, destination_id, destination_type, status, schema, metadata, in_progress, created_at, updated_at)
+			VALUES ('s1', 'ns', 'd1', 'POSTGRES', $1, '{}', '{"nextRetryTime": "2100-01-01T00:00:00Z"}', $2, NOW(), NOW()) RETURNING id`, status, inProgress).Scan(&uploadID)
+		require.NoError(t, err)
+		for tableName, tableStatus := range tables {
+			_, err = db.Exec(`INSERT INTO wh_table_uploads (wh_upload_id, table_name, status, error, created_at, updated_at) VALUES ($1, $2, $3, '{"exporting_data_failed": {"errors": ["failed"]}}', NOW(), NOW())`, uploadID, tableName, tableStatus)
+			require.NoError(t, err)
+		}
+		return uploadID
+	}
+	apply := func(uploadID int64, action, tableName string) (*UploadActionReqT, int32) {
+		actionReq := &UploadActionReqT{WorkspaceID: "w1", UploadId: uploadID, Action: action, TableName: tableName, Reason: "test", RequestedBy: "tester", API: api}
+		response, _ := actionReq.ApplyUploadAction()
+		return actionReq, response.StatusCode
+	}
+	uploadOf := func(uploadID int64) (status string, metadata map[string]interface{}) {
+		var metadataJSON json.RawMessage
+		require.NoError(t, db.QueryRow(`SELECT status, metadata FROM wh_uploads WHERE id = $1`, uploadID).Scan(&status, &metadataJSON))
+		require.NoError(t, json.Unmarshal(metadataJSON, &metadata))
+		return
+	}
+	tableStatusOf := func(uploadID int64, tableName string) (status string) {
+		require.NoError(t, db.QueryRow(`SELECT status FROM wh_table_uploads WHERE wh_upload_id = $1 AND table_name = $2`, uploadID, tableName).Scan(&status))
+		return
+	}
+	auditEventsOf := func(uploadID int64) (events [][]string) {
+		rows, err := db.Query(`SELECT action, COALESCE(table_name, ''), previous_status, status, reason, requested_by FROM wh_upload_audit_events WHERE wh_upload_id = $1 ORDER BY id`, uploadID)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			event := make([]string, 6)
+			require.NoError(t, rows.Scan(&event[0], &event[1], &event[2], &event[3], &event[4], &event[5]))
+			events = append(events, event)
+		}
+		return
+	}
+
+	t.Run("skip and retry tables", func(t *testing.T) {
+		uploadID := createUpload("exporting_data_failed", false, map[string]string{"tracks": TableUploadExportingFailed, "pages": TableUploadExportingFailed, "identifies": TableUploadExported})
+
+		_, statusCode := apply(uploadID, SkipTableUploadAction, "tracks")
+		require.Equal(t, int32(http.StatusOK), statusCode)
+		require.Equal(t, TableUploadSkipped, tableStatusOf(uploadID, "tracks"))
+		status, metadata := uploadOf(uploadID)
+		require.Equal(t, CreatedRemoteSchema, status)
+		require.Equal(t, true, metadata["retried"])
+		require.NotEqual(t, "2100-01-01T00:00:00Z", metadata["nextRetryTime"])
+
+		_, statusCode = apply(uploadID, RetryTableUploadAction, "pages")
+		require.Equal(t, int32(http.StatusOK), statusCode)
+		require.Equal(t, Waiting, tableStatusOf(uploadID, "pages"))
+		_, metadata = uploadOf(uploadID)
+		require.Equal(t, []interface{}{"pages"}, metadata[retryTablesMetadataKey])
+
+		_, statusCode = apply(uploadID, RetryTableUploadAction, "identifies")
+		require.Equal(t, int32(http.StatusConflict), statusCode, "exported tables can't be retried")
+		_, statusCode = apply(uploadID, SkipTableUploadAction, "users")
+		require.Equal(t, int32(http.StatusNotFound), statusCode)
+
+		require.Equal(t, [][]string{
+			{SkipTableUploadAction, "tracks", "exporting_data_failed", CreatedRemoteSchema, "test", "tester"},
+			{RetryTableUploadAction, "pages", CreatedRemoteSchema, CreatedRemoteSchema, "test", "tester"},
+		}, auditEventsOf(uploadID))
+	})
+
+	t.Run("regenerate load files and abort", func(t *testing.T) {
+		uploadID := createUpload("exporting_data_failed", false, nil)
+
+		_, statusCode := apply(uploadID, RegenerateLoadFilesUploadAction, "")
+		require.Equal(t, int32(http.StatusOK), statusCode)
+		status, metadata := uploadOf(uploadID)
+		require.Equal(t, CreatedTableUploads, status)
+		require.Equal(t, true, metadata[regenerateLoadFilesMetadataKey])
+
+		_, statusCode = apply(uploadID, AbortUploadAction, "")
+		require.Equal(t, int32(http.StatusOK), statusCode)
+		status, _ = uploadOf(uploadID)
+		require.Equal(t, Aborted, status)
+
+		_, statusCode = apply(uploadID, AbortUploadAction, "")
+		require.Equal(t, int32(http.StatusConflict), statusCode, "aborted uploads can't be changed")
+		require.Len(t, auditEventsOf(uploadID), 2)
+	})
+
+	t.Run("rejected actions are not applied", func(t *testing.T) {
+		uploadID := createUpload("exporting_data_failed", true, map[string]string{"tracks": TableUploadExportingFailed})
+		_, statusCode := apply(uploadID, SkipTableUploadAction, "tracks")
+		require.Equal(t, int32(http.StatusConflict), statusCode, "uploads in progress can't be changed")
+		require.Equal(t, TableUploadExportingFailed, tableStatusOf(uploadID, "tracks"))
+		require.Empty(t, auditEventsOf(uploadID))
+
+		actionReq, _ := apply(uploadID, AbortUploadAction, "")
+		actionReq.WorkspaceID = "w2"
+		response, err := actionReq.ApplyUploadAction()
+		require.Error(t, err)
+		require.Equal(t, int32(http.StatusBadRequest), response.StatusCode)
+
+		_, statusCode = apply(0, AbortUploadAction, "")
+		require.Equal(t, int32(http.StatusBadRequest), statusCode)
+	})
+
+	t.Run("rolls back the action if it can't be recorded", func(t *testing.T) {
+		uploadID := createUpload("exporting_data_failed", false, map[string]string{"tracks": TableUploadExportingFailed})
+		_, err := db.Exec(`ALTER TABLE wh_upload_audit_events RENAME TO wh_upload_audit_events_renamed`)
+		require.NoError(t, err)
+		defer func() {
+			_, err := db.Exec(`ALTER TABLE wh_upload_audit_events_renamed RENAME TO wh_upload_audit_events`)
+			require.NoError(t, err)
+		}()
+
+		_, statusCode := apply(uploadID, SkipTableUploadAction, "tracks")
+		require.Equal(t, int32(http.StatusInternalServerError), statusCode)
+		require.Equal(t, TableUploadExportingFailed, tableStatusOf(uploadID, "tracks"))
+		status, _ := uploadOf(uploadID)
+		require.Equal(t, "exporting_data_failed", status)
+	})
+}
@@ -0,0 +1,129 @@
+package warehouseutils
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rudderlabs/rudder-server/utils/misc"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+//parquetReadBatchSize is the number of rows read from every column of a parquet load file at a time
+const parquetReadBatchSize = 1000
+
+//parquetReader reads the rows of a parquet load file as the values of a csv load file:
+//nulls are empty, datetimes are formatted like misc.RFC3339Milli, and the other values are formatted as in json
+type parquetReader struct {
+	file     source.ParquetFile
+	reader   *reader.ParquetReader
+	rowsLeft int64
+	//rows holds the rows of the batch read, next is the index of the next row to return
+	rows [][]string
+	next int
+}
+
+//NewParquetReader opens the parquet load file at path, Close must be called once the rows are read
+func NewParquetReader(path string) (*parquetReader, error) {
+	file, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &parquetReader{file: file, reader: pr, rowsLeft: pr.GetNumRows()}, nil
+}
+
+//Read returns the values of the columns of the next row, or io.EOF once all the rows are read
+func (p *parquetReader) Read(columnNames []string) (record []string, err error) {
+	if p.next == len(p.rows) {
+		if err = p.readBatch(columnNames); err != nil {
+			return nil, err
+		}
+	}
+	record = p.rows[p.next]
+	p.next++
+	return record, nil
+}
+
+func (p *parquetReader) readBatch(columnNames []string) error {
+	if p.rowsLeft <= 0 {
+		return io.EOF
+	}
+	noOfRows := p.rowsLeft
+	if noOfRows > parquetReadBatchSize {
+		noOfRows = parquetReadBatchSize
+	}
+	rows := make([][]string, noOfRows)
+	for i := range rows {
+		rows[i] = make([]string, len(columnNames))
+	}
+	for j, columnName := range columnNames {
+		path := p.reader.SchemaHandler.GetRootExName() + common.PAR_GO_PATH_DELIMITER + columnName
+		values, _, _, err := p.reader.ReadColumnByPath(path, noOfRows)
+		if err != nil {
+			return fmt.Errorf("failed to read column %s of parquet load file: %w", columnName, err)
+		}
+		if int64(len(values)) != noOfRows {
+			return fmt.Errorf("read %d values of column %s of parquet load file instead of %d", len(values), columnName, noOfRows)
+		}
+		element := p.schemaElementOf(path)
+		for i, value := range values {
+			rows[i][j] = parquetValueString(value, element)
+		}
+	}
+	p.rowsLeft -= noOfRows
+	p.rows = rows
+	p.next = 0
+	return nil
+}
+
+func (p *parquetReader) schemaElementOf(path string) *parquet.SchemaElement {
+	inPath, err := p.reader.SchemaHandler.ConvertToInPathStr(path)
+	if err != nil {
+		return nil
+	}
+	index, ok := p.reader.SchemaHandler.MapIndex[inPath]
+	if !ok {
+		return nil
+	}
+	return p.reader.SchemaHandler.SchemaElements[index]
+}
+
+//parquetValueString formats a value of a parquet load file as the value of a csv load file
+func parquetValueString(value interface{}, element *parquet.SchemaElement) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case int64:
+		if element != nil && element.ConvertedType != nil && *element.ConvertedType == parquet.ConvertedType_TIMESTAMP_MICROS {
+			return types.TIMESTAMP_MICROSToTime(v, true).Format(misc.RFC3339Milli)
+		}
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func (p *parquetReader) Close() error {
+	p.reader.ReadStop()
+	return p.file.Close()
+}
@@ -52,6 +52,14 @@ var rudderDataTypeToParquetDataType = map[string]map[string]string{
 		"string":   PARQUET_STRING,
 		"datetime": PARQUET_TIMESTAMP_MICROS,
 	},
+	SQLITE: {
+		"int":      PARQUET_INT_64,
+		"boolean":  PARQUET_BOOLEAN,
+		"float":    PARQUET_DOUBLE,
+		"string":   PARQUET_STRING,
+		"datetime": PARQUET_TIMESTAMP_MICROS,
+		"json":     PARQUET_STRING,
+	},
 }
 
 type ParquetWriter struct {
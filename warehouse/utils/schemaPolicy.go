@@ -0,0 +1,252 @@
+package warehouseutils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//SchemaPolicyConfig is the key of the schema policy in the destination config
+const SchemaPolicyConfig = "schemaPolicy"
+
+//PIIHashSecretConfig is the key of the secret pii columns are hashed with in the destination config
+const PIIHashSecretConfig = "piiHashSecret"
+
+//AllTablesPolicyKey applies the policies set on it to all the tables of the destination
+const AllTablesPolicyKey = "*"
+
+//actions on the pii columns of the schema policy
+const (
+	HashPIIAction = "hash"
+	DropPIIAction = "drop"
+)
+
+//policyProtectedColumns are needed to load the tables and can't be excluded or hashed by a schema policy
+var policyProtectedColumns = map[string]bool{
+	"id":          true,
+	"received_at": true,
+	"uuid_ts":     true,
+	"loaded_at":   true,
+	"user_id":     true,
+}
+
+var policyColumnTypes = map[string]bool{
+	"boolean":  true,
+	"int":      true,
+	"bigint":   true,
+	"float":    true,
+	"string":   true,
+	"text":     true,
+	"datetime": true,
+	"json":     true,
+}
+
+/*
+SchemaPolicyT are the rules applied to the columns of the tables of a destination, set by the schemaPolicy of the destination config:
+	"schemaPolicy": {
+		"lockedColumnTypes": {"tracks": {"revenue": "float"}},
+		"allowedColumns": {"pages": ["id", "received_at", "path"]},
+		"deniedColumns": {"*": ["context_ip"]},
+		"maxColumnCount": 200,
+		"piiColumns": {"*": {"email": "hash", "phone": "drop"}}
+	}
+Tables are keyed by their name or * for all tables, table and column names are matched case insensitively.
+The rudder tables (discards and identity tables) aren't subject to the policy.
+Hashed pii columns are keyed by the piiHashSecret of the destination config, which is required to hash columns.
+*/
+type SchemaPolicyT struct {
+	LockedColumnTypes map[string]map[string]string `json:"lockedColumnTypes"`
+	AllowedColumns    map[string][]string          `json:"allowedColumns"`
+	DeniedColumns     map[string][]string          `json:"deniedColumns"`
+	MaxColumnCount    int                          `json:"maxColumnCount"`
+	PIIColumns        map[string]map[string]string `json:"piiColumns"`
+
+	hashSecret []byte
+}
+
+//GetSchemaPolicy returns the schema policy in the destination config, which is either an object or its json
+func GetSchemaPolicy(config interface{}) (policy SchemaPolicyT, err error) {
+	configMap, ok := config.(map[string]interface{})
+	if !ok || configMap[SchemaPolicyConfig] == nil {
+		return policy, nil
+	}
+	var rawPolicy []byte
+	switch value := configMap[SchemaPolicyConfig].(type) {
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return policy, nil
+		}
+		rawPolicy = []byte(value)
+	default:
+		rawPolicy, err = json.Marshal(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid schema policy: %w", err)
+		}
+	}
+	err = json.Unmarshal(rawPolicy, &policy)
+	if err != nil {
+		return SchemaPolicyT{}, fmt.Errorf("invalid schema policy: %w", err)
+	}
+	if secret, ok := configMap[PIIHashSecretConfig].(string); ok {
+		policy.hashSecret = []byte(secret)
+	}
+	return policy, policy.validate()
+}
+
+func (policy SchemaPolicyT) validate() error {
+	if policy.MaxColumnCount < 0 {
+		return fmt.Errorf("invalid schema policy: maxColumnCount %d is negative", policy.MaxColumnCount)
+	}
+	for tableName, columns := range policy.LockedColumnTypes {
+		for columnName, columnType := range columns {
+			if !policyColumnTypes[columnType] {
+				return fmt.Errorf("invalid schema policy: locked type %q of column %s in table %s", columnType, columnName, tableName)
+			}
+		}
+	}
+	for tableName, columns := range policy.PIIColumns {
+		for columnName, action := range columns {
+			if action != HashPIIAction && action != DropPIIAction {
+				return fmt.Errorf("invalid schema policy: pii action %q of column %s in table %s", action, columnName, tableName)
+			}
+			if policyProtectedColumns[strings.ToLower(columnName)] {
+				return fmt.Errorf("invalid schema policy: column %s is needed to load the tables and can't be hashed or dropped", columnName)
+			}
+			if action == HashPIIAction && len(policy.hashSecret) == 0 {
+				return fmt.Errorf("invalid schema policy: column %s in table %s can't be hashed without a %s in the destination config", columnName, tableName, PIIHashSecretConfig)
+			}
+		}
+	}
+	return nil
+}
+
+//IsEmpty returns whether the policy has no rules
+func (policy SchemaPolicyT) IsEmpty() bool {
+	return len(policy.LockedColumnTypes) == 0 && len(policy.AllowedColumns) == 0 && len(policy.DeniedColumns) == 0 && policy.MaxColumnCount == 0 && len(policy.PIIColumns) == 0
+}
+
+//IsHashableColumnType returns whether hashed values can be loaded into a column of the type
+func IsHashableColumnType(columnType string) bool {
+	return columnType == "string" || columnType == "text"
+}
+
+//IsPolicyTable returns whether the table is subject to the policy
+func IsPolicyTable(tableName string) bool {
+	switch strings.ToLower(tableName) {
+	case DiscardsTable, IdentityMergeRulesTable, IdentityMappingsTable:
+		return false
+	}
+	return true
+}
+
+//IsPolicyProtectedColumn returns whether the column is needed to load the tables, and is never excluded by a policy
+func IsPolicyProtectedColumn(columnName string) bool {
+	return policyProtectedColumns[strings.ToLower(columnName)]
+}
+
+//tableRules returns the rules of the table, followed by the rules for all tables
+func tableRules(rules map[string][]string, tableName string) (tableColumns []string, allTablesColumns []string) {
+	for key, columns := range rules {
+		if key == AllTablesPolicyKey {
+			allTablesColumns = columns
+		} else if strings.EqualFold(key, tableName) {
+			tableColumns = columns
+		}
+	}
+	return
+}
+
+func containsColumn(columns []string, columnName string) bool {
+	for _, column := range columns {
+		if strings.EqualFold(column, columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+//columnRule returns the rule of the column in the table, falling back to its rule in all tables
+func columnRule(rules map[string]map[string]string, tableName, columnName string) (rule string, ok bool) {
+	var allTablesRule string
+	var hasAllTablesRule bool
+	for ruleTableName, columns := range rules {
+		isAllTables := ruleTableName == AllTablesPolicyKey
+		if !isAllTables && !strings.EqualFold(ruleTableName, tableName) {
+			continue
+		}
+		for ruleColumnName, columnRule := range columns {
+			if !strings.EqualFold(ruleColumnName, columnName) {
+				continue
+			}
+			if !isAllTables {
+				return columnRule, true
+			}
+			allTablesRule, hasAllTablesRule = columnRule, true
+		}
+	}
+	return allTablesRule, hasAllTablesRule
+}
+
+//LockedColumnType returns the type the column is locked to
+func (policy SchemaPolicyT) LockedColumnType(tableName, columnName string) (columnType string, ok bool) {
+	if !IsPolicyTable(tableName) {
+		return "", false
+	}
+	return columnRule(policy.LockedColumnTypes, tableName, columnName)
+}
+
+//PIIAction returns whether the values of the column are hashed or dropped
+func (policy SchemaPolicyT) PIIAction(tableName, columnName string) (action string, ok bool) {
+	if !IsPolicyTable(tableName) || IsPolicyProtectedColumn(columnName) {
+		return "", false
+	}
+	return columnRule(policy.PIIColumns, tableName, columnName)
+}
+
+//IsColumnExcluded returns whether the column isn't loaded, as it's denied, not allowed or dropped
+func (policy SchemaPolicyT) IsColumnExcluded(tableName, columnName string) bool {
+	if !IsPolicyTable(tableName) || IsPolicyProtectedColumn(columnName) {
+		return false
+	}
+	if action, ok := policy.PIIAction(tableName, columnName); ok && action == DropPIIAction {
+		return true
+	}
+	deniedColumns, allTablesDeniedColumns := tableRules(policy.DeniedColumns, tableName)
+	if containsColumn(deniedColumns, columnName) || containsColumn(allTablesDeniedColumns, columnName) {
+		return true
+	}
+	// the allowlist of the table takes precedence over the one of all tables
+	allowedColumns, allTablesAllowedColumns := tableRules(policy.AllowedColumns, tableName)
+	if allowedColumns != nil {
+		return !containsColumn(allowedColumns, columnName)
+	}
+	if allTablesAllowedColumns != nil {
+		return !containsColumn(allTablesAllowedColumns, columnName)
+	}
+	return false
+}
+
+//HashColumnValue returns the hex encoded hmac-sha256 of the value keyed by the pii hash secret of the destination,
+//so pii isn't loaded in clear text and can't be looked up from the hashes of known values
+func (policy SchemaPolicyT) HashColumnValue(columnVal interface{}) string {
+	var value string
+	switch v := columnVal.(type) {
+	case string:
+		value = v
+	case float64:
+		// json numbers are decoded as float64, format them without exponent so that phone numbers hash as their digits
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		marshalledVal, _ := json.Marshal(v)
+		value = string(marshalledVal)
+	default:
+		value = fmt.Sprintf("%v", v)
+	}
+	mac := hmac.New(sha256.New, policy.hashSecret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
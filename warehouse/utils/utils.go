@@ -41,6 +41,7 @@ const (
 	S3_DATALAKE    = "S3_DATALAKE"
 	GCS_DATALAKE   = "GCS_DATALAKE"
 	AZURE_DATALAKE = "AZURE_DATALAKE"
+	SQLITE         = "SQLITE"
 )
 
 const (
@@ -53,11 +54,12 @@ const (
 
 // warehouse table names
 const (
-	WarehouseStagingFilesTable = "wh_staging_files"
-	WarehouseLoadFilesTable    = "wh_load_files"
-	WarehouseUploadsTable      = "wh_uploads"
-	WarehouseTableUploadsTable = "wh_table_uploads"
-	WarehouseSchemasTable      = "wh_schemas"
+	WarehouseStagingFilesTable      = "wh_staging_files"
+	WarehouseLoadFilesTable         = "wh_load_files"
+	WarehouseUploadsTable           = "wh_uploads"
+	WarehouseTableUploadsTable      = "wh_table_uploads"
+	WarehouseSchemasTable           = "wh_schemas"
+	WarehouseUploadAuditEventsTable = "wh_upload_audit_events"
 )
 
 const (
@@ -71,6 +73,14 @@ const (
 	ExcludeWindowEndTime    = "excludeWindowEndTime"
 )
 
+// load modes of the destinations, set by the loadMode of the destination config
+const (
+	LoadModeConfig = "loadMode"
+	MergeKeyConfig = "mergeKey"
+	AppendLoadMode = "append"
+	MergeLoadMode  = "merge"
+)
+
 const (
 	UsersTable      = "users"
 	UsersView       = "users_view"
@@ -102,6 +112,7 @@ var WHDestNameMap = map[string]string{
 	GCS_DATALAKE:   "gcs_datalake",
 	AZURE_DATALAKE: "azure_datalake",
 	AZURE_SYNAPSE:  "azure_synapse",
+	SQLITE:         "sqlite",
 }
 
 var ObjectStorageMap = map[string]string{
@@ -134,11 +145,12 @@ const (
 )
 
 var (
-	pkgLogger              logger.LoggerI
-	useParquetLoadFilesRS  bool
-	TimeWindowDestinations []string
-	WarehouseDestinations  []string
-	TestConnectionTimeout  time.Duration
+	pkgLogger                 logger.LoggerI
+	useParquetLoadFilesRS     bool
+	useParquetLoadFilesSQLite bool
+	TimeWindowDestinations    []string
+	WarehouseDestinations     []string
+	TestConnectionTimeout     time.Duration
 )
 
 func Init() {
@@ -150,11 +162,12 @@ func Init() {
 func loadConfig() {
 	IdentityEnabledWarehouses = []string{SNOWFLAKE, BQ}
 	TimeWindowDestinations = []string{S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE}
-	WarehouseDestinations = []string{RS, BQ, SNOWFLAKE, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE, S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE, DELTALAKE}
+	WarehouseDestinations = []string{RS, BQ, SNOWFLAKE, POSTGRES, CLICKHOUSE, MSSQL, AZURE_SYNAPSE, S3_DATALAKE, GCS_DATALAKE, AZURE_DATALAKE, DELTALAKE, SQLITE}
 	config.RegisterBoolConfigVariable(false, &enableIDResolution, false, "Warehouse.enableIDResolution")
 	config.RegisterInt64ConfigVariable(3600, &AWSCredsExpiryInS, true, 1, "Warehouse.awsCredsExpiryInS")
 	config.RegisterIntConfigVariable(10240, &maxStagingFileReadBufferCapacityInK, false, 1, "Warehouse.maxStagingFileReadBufferCapacityInK")
 	config.RegisterBoolConfigVariable(false, &useParquetLoadFilesRS, true, "Warehouse.useParquetLoadFilesRS")
+	config.RegisterBoolConfigVariable(false, &useParquetLoadFilesSQLite, true, "Warehouse.sqlite.useParquetLoadFiles")
 }
 
 type WarehouseT struct {
@@ -213,8 +226,9 @@ type GetLoadFilesOptionsT struct {
 }
 
 type LoadFileT struct {
-	Location string
-	Metadata json.RawMessage
+	Location  string
+	Metadata  json.RawMessage
+	TotalRows int64
 }
 
 func IDResolutionEnabled() bool {
@@ -666,6 +680,57 @@ func GetConfigValueAsMap(key string, config map[string]interface{}) map[string]i
 	return value
 }
 
+//IsMergeLoadMode returns whether the destination loads its event tables in merge mode, replacing the rows with the same merge key
+func IsMergeLoadMode(warehouse WarehouseT) bool {
+	return GetConfigValue(LoadModeConfig, warehouse) == MergeLoadMode
+}
+
+var mergeKeyColumnRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+/*
+GetMergeKeys returns the columns on which the rows of the table are deduplicated in merge mode, nil if the destination
+doesn't load in merge mode or the table is deduplicated on its own key (users, discards and the identity tables).
+The key is id, the column of the messageId of the events, unless the destination config sets the comma separated columns of its mergeKey.
+Tables without all the columns of the configured key, e.g. the tables of events without the columns of the key, are deduplicated on id.
+Loading fails if the key has invalid column names, or if the table has no id column to fall back to.
+*/
+func GetMergeKeys(warehouse WarehouseT, tableName string, tableSchemaInUpload TableSchemaT) ([]string, error) {
+	if !IsMergeLoadMode(warehouse) {
+		return nil, nil
+	}
+	switch strings.ToLower(tableName) {
+	case UsersTable, DiscardsTable, IdentityMergeRulesTable, IdentityMappingsTable:
+		return nil, nil
+	}
+	mergeKey := GetConfigValue(MergeKeyConfig, warehouse)
+	if strings.TrimSpace(mergeKey) == "" {
+		mergeKey = "id"
+	}
+	columns := map[string]bool{}
+	for column := range tableSchemaInUpload {
+		columns[strings.ToLower(column)] = true
+	}
+	var keys []string
+	var missingColumns bool
+	for _, key := range strings.Split(mergeKey, ",") {
+		key = strings.TrimSpace(key)
+		if !mergeKeyColumnRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid merge key column %q of destination %s", key, warehouse.Destination.ID)
+		}
+		if !columns[strings.ToLower(key)] {
+			missingColumns = true
+		}
+		keys = append(keys, key)
+	}
+	if missingColumns {
+		if !columns["id"] {
+			return nil, fmt.Errorf("merge key columns %s and id are not in table %s", mergeKey, tableName)
+		}
+		return []string{"id"}, nil
+	}
+	return keys, nil
+}
+
 func SortColumnKeysFromColumnMap(columnMap map[string]string) []string {
 	columnKeys := make([]string, 0, len(columnMap))
 	for k := range columnMap {
@@ -883,6 +948,11 @@ func GetLoadFileType(wh string) string {
 		return LOAD_FILE_TYPE_PARQUET
 	case DELTALAKE:
 		return LOAD_FILE_TYPE_CSV
+	case SQLITE:
+		if useParquetLoadFilesSQLite {
+			return LOAD_FILE_TYPE_PARQUET
+		}
+		return LOAD_FILE_TYPE_CSV
 	default:
 		return LOAD_FILE_TYPE_CSV
 	}
@@ -901,6 +971,11 @@ func GetLoadFileFormat(whType string) string {
 		return "csv.gz"
 	case DELTALAKE:
 		return "csv.gz"
+	case SQLITE:
+		if useParquetLoadFilesSQLite {
+			return "parquet"
+		}
+		return "csv.gz"
 	default:
 		return "csv.gz"
 	}
@@ -4,6 +4,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	backendconfig "github.com/rudderlabs/rudder-server/config/backend-config"
 	. "github.com/rudderlabs/rudder-server/warehouse/utils"
 )
 
@@ -131,6 +132,152 @@ var _ = Describe("Utils", func() {
 		})
 	})
 
+	Describe("GetMergeKeys", func() {
+		schema := TableSchemaT{"id": "string", "user_id": "string", "event": "string", "received_at": "datetime"}
+		warehouse := func(config map[string]interface{}) WarehouseT {
+			return WarehouseT{Destination: backendconfig.DestinationT{ID: "d1", Config: config}}
+		}
+
+		It("should return no keys if the destination is not in merge load mode", func() {
+			keys, err := GetMergeKeys(warehouse(map[string]interface{}{}), "tracks", schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(BeNil())
+
+			keys, err = GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: AppendLoadMode}), "tracks", schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(BeNil())
+		})
+
+		It("should return no keys for the users and discards tables", func() {
+			for _, tableName := range []string{UsersTable, DiscardsTable, "USERS"} {
+				keys, err := GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode, MergeKeyConfig: "user_id"}), tableName, schema)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(keys).To(BeNil())
+			}
+		})
+
+		It("should default to the id column", func() {
+			keys, err := GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode}), "tracks", schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{"id"}))
+		})
+
+		It("should return the configured composite key", func() {
+			keys, err := GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode, MergeKeyConfig: " user_id, EVENT "}), "tracks", schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{"user_id", "EVENT"}))
+		})
+
+		It("should fall back to the id column for tables without the columns of the key", func() {
+			keys, err := GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode, MergeKeyConfig: "user_id,anonymous_id"}), "tracks", schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{"id"}))
+		})
+
+		It("should fail for invalid columns and tables without id", func() {
+			_, err := GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode, MergeKeyConfig: "id; DROP TABLE tracks"}), "tracks", schema)
+			Expect(err).To(MatchError(ContainSubstring("invalid merge key column")))
+
+			_, err = GetMergeKeys(warehouse(map[string]interface{}{LoadModeConfig: MergeLoadMode, MergeKeyConfig: "anonymous_id"}), "tracks", TableSchemaT{"event": "string"})
+			Expect(err).To(MatchError("merge key columns anonymous_id and id are not in table tracks"))
+		})
+	})
+
+	Describe("SchemaPolicy", func() {
+		policyConfig := map[string]interface{}{
+			SchemaPolicyConfig: map[string]interface{}{
+				"lockedColumnTypes": map[string]interface{}{"tracks": map[string]interface{}{"revenue": "float"}},
+				"allowedColumns":    map[string]interface{}{"pages": []interface{}{"path"}},
+				"deniedColumns":     map[string]interface{}{"*": []interface{}{"context_ip"}},
+				"maxColumnCount":    float64(200),
+				"piiColumns":        map[string]interface{}{"*": map[string]interface{}{"email": "hash", "phone": "drop"}, "identifies": map[string]interface{}{"phone": "hash"}},
+			},
+			PIIHashSecretConfig: "secret",
+		}
+
+		It("should return an empty policy if the destination has none", func() {
+			policy, err := GetSchemaPolicy(map[string]interface{}{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.IsEmpty()).To(BeTrue())
+			Expect(policy.IsColumnExcluded("tracks", "context_ip")).To(BeFalse())
+
+			policy, err = GetSchemaPolicy(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.IsEmpty()).To(BeTrue())
+		})
+
+		It("should parse the policy from an object or its json", func() {
+			policy, err := GetSchemaPolicy(policyConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.MaxColumnCount).To(Equal(200))
+
+			jsonPolicy, err := GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"maxColumnCount": 200, "piiColumns": {"*": {"email": "hash"}}}`, PIIHashSecretConfig: "secret"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(jsonPolicy.MaxColumnCount).To(Equal(200))
+			action, isPII := jsonPolicy.PIIAction("tracks", "email")
+			Expect(isPII).To(BeTrue())
+			Expect(action).To(Equal(HashPIIAction))
+		})
+
+		It("should fail for invalid policies", func() {
+			_, err := GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"maxColumnCount": "many"}`})
+			Expect(err).To(MatchError(ContainSubstring("invalid schema policy")))
+
+			_, err = GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"lockedColumnTypes": {"tracks": {"revenue": "decimal"}}}`})
+			Expect(err).To(MatchError(`invalid schema policy: locked type "decimal" of column revenue in table tracks`))
+
+			_, err = GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"piiColumns": {"*": {"email": "mask"}}}`})
+			Expect(err).To(MatchError(`invalid schema policy: pii action "mask" of column email in table *`))
+
+			_, err = GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"piiColumns": {"*": {"ID": "hash"}}}`, PIIHashSecretConfig: "secret"})
+			Expect(err).To(MatchError(ContainSubstring("column ID is needed to load the tables")))
+
+			_, err = GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"piiColumns": {"*": {"email": "hash"}}}`})
+			Expect(err).To(MatchError(ContainSubstring("can't be hashed without a piiHashSecret")))
+			_, err = GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: `{"piiColumns": {"*": {"phone": "drop"}}}`})
+			Expect(err).NotTo(HaveOccurred(), "dropped columns don't need a secret")
+		})
+
+		It("should match the rules of the table before the ones of all tables", func() {
+			policy, err := GetSchemaPolicy(policyConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			lockedType, locked := policy.LockedColumnType("TRACKS", "REVENUE")
+			Expect(locked).To(BeTrue())
+			Expect(lockedType).To(Equal("float"))
+			_, locked = policy.LockedColumnType("pages", "revenue")
+			Expect(locked).To(BeFalse())
+
+			action, _ := policy.PIIAction("tracks", "phone")
+			Expect(action).To(Equal(DropPIIAction))
+			action, _ = policy.PIIAction("identifies", "phone")
+			Expect(action).To(Equal(HashPIIAction))
+			_, isPII := policy.PIIAction(DiscardsTable, "email")
+			Expect(isPII).To(BeFalse())
+
+			Expect(policy.IsColumnExcluded("tracks", "phone")).To(BeTrue())
+			Expect(policy.IsColumnExcluded("identifies", "phone")).To(BeFalse())
+			Expect(policy.IsColumnExcluded("tracks", "CONTEXT_IP")).To(BeTrue())
+			Expect(policy.IsColumnExcluded("tracks", "event")).To(BeFalse())
+			Expect(policy.IsColumnExcluded("pages", "path")).To(BeFalse())
+			Expect(policy.IsColumnExcluded("pages", "title")).To(BeTrue())
+			Expect(policy.IsColumnExcluded("pages", "received_at")).To(BeFalse(), "columns needed to load are always allowed")
+			Expect(policy.IsColumnExcluded(DiscardsTable, "context_ip")).To(BeFalse())
+		})
+
+		It("should hash the values with the secret of the destination", func() {
+			policy, err := GetSchemaPolicy(policyConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.HashColumnValue("user@example.com")).To(Equal("febca656b1fa2234083628d174f250dd85728259017890916cb6ffc0712340de"))
+			Expect(policy.HashColumnValue(float64(14155550100))).To(Equal(policy.HashColumnValue("14155550100")))
+			Expect(policy.HashColumnValue(true)).To(Equal(policy.HashColumnValue("true")))
+
+			otherPolicy, err := GetSchemaPolicy(map[string]interface{}{SchemaPolicyConfig: policyConfig[SchemaPolicyConfig], PIIHashSecretConfig: "other secret"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(otherPolicy.HashColumnValue("user@example.com")).NotTo(Equal(policy.HashColumnValue("user@example.com")))
+		})
+	})
+
 	// Describe("Compare Schemas", func() {
 	// 	Context("GetSchemaDiff", func() {
 	// 		var currentSchema map[string]map[string]string
@@ -145,7 +145,7 @@ func loadConfig() {
 	config.RegisterIntConfigVariable(960, &stagingFilesBatchSize, true, 1, "Warehouse.stagingFilesBatchSize")
 	config.RegisterInt64ConfigVariable(1800, &uploadFreqInS, true, 1, "Warehouse.uploadFreqInS")
 	config.RegisterDurationConfigVariable(time.Duration(5), &mainLoopSleep, true, time.Second, []string{"Warehouse.mainLoopSleep", "Warehouse.mainLoopSleepInS"}...)
-	crashRecoverWarehouses = []string{warehouseutils.RS, warehouseutils.POSTGRES, warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE, warehouseutils.DELTALAKE}
+	crashRecoverWarehouses = []string{warehouseutils.RS, warehouseutils.POSTGRES, warehouseutils.MSSQL, warehouseutils.AZURE_SYNAPSE, warehouseutils.DELTALAKE, warehouseutils.SQLITE}
 	inRecoveryMap = map[string]bool{}
 	lastProcessedMarkerMap = map[string]int64{}
 	config.RegisterStringConfigVariable("embedded", &warehouseMode, false, "Warehouse.mode")
@@ -1458,6 +1458,45 @@ func triggerUploadHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+func uploadActionHandler(w http.ResponseWriter, r *http.Request) {
+	pkgLogger.LogRequest(r)
+
+	// read body
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error reading body: %v", err)
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	// unmarshall body
+	var uploadActionReq uploadActionRequestT
+	err = json.Unmarshal(body, &uploadActionReq)
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Error unmarshalling body: %v", err)
+		http.Error(w, "can't unmarshall body", http.StatusBadRequest)
+		return
+	}
+
+	actionReq := UploadActionReqT{
+		UploadId:    uploadActionReq.UploadID,
+		WorkspaceID: uploadActionReq.WorkspaceID,
+		Action:      uploadActionReq.Action,
+		TableName:   uploadActionReq.TableName,
+		Reason:      uploadActionReq.Reason,
+		RequestedBy: uploadActionReq.RequestedBy,
+		API:         UploadAPI,
+	}
+	response, err := actionReq.ApplyUploadAction()
+	if err != nil {
+		pkgLogger.Errorf("[WH]: Failed to apply action %s on upload:%d: %v", actionReq.Action, actionReq.UploadId, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(int(response.StatusCode))
+	json.NewEncoder(w).Encode(response)
+}
+
 func TriggerUploadHandler(sourceID string, destID string) error {
 
 	// return error if source id and dest id is empty
@@ -1581,6 +1620,8 @@ func startWebHandler(ctx context.Context) error {
 			mux.HandleFunc("/v1/warehouse/pending-events", pendingEventsHandler)
 			// triggers uploads for a source
 			mux.HandleFunc("/v1/warehouse/trigger-upload", triggerUploadHandler)
+			// applies manual actions on uploads, like skipping or retrying their tables
+			mux.HandleFunc("/v1/warehouse/upload-action", uploadActionHandler)
 			mux.HandleFunc("/databricksVersion", databricksVersionHandler)
 			pkgLogger.Infof("WH: Starting warehouse master service in %d", webPort)
 		} else {
@@ -2,8 +2,12 @@ package warehouse
 
 import (
 	"context"
+	"net/http"
+
 	"github.com/rudderlabs/rudder-server/warehouse/configuration_testing"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 
@@ -68,3 +72,32 @@ func (w *warehousegrpc) Validate(ctx context.Context, req *proto.WHValidationReq
 	handleT := configuration_testing.CTHandleT{}
 	return handleT.Validating(req)
 }
+
+func (w *warehousegrpc) ApplyWHUploadAction(context context.Context, request *proto.WHUploadActionRequest) (*proto.TriggerWhUploadsResponse, error) {
+	actionReq := UploadActionReqT{
+		UploadId:    request.UploadId,
+		WorkspaceID: request.WorkspaceId,
+		Action:      request.Action,
+		TableName:   request.TableName,
+		Reason:      request.Reason,
+		RequestedBy: request.RequestedBy,
+		API:         UploadAPI,
+	}
+	res, err := actionReq.ApplyUploadAction()
+	if err != nil {
+		return res, status.Error(uploadActionGRPCCode(res.StatusCode), err.Error())
+	}
+	return res, nil
+}
+
+func uploadActionGRPCCode(statusCode int32) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.FailedPrecondition
+	}
+	return codes.Internal
+}